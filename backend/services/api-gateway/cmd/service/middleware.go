@@ -112,6 +112,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 		}
 		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
 		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor")
 
 		if strings.ToUpper(r.Method) == "OPTIONS" {
 			w.WriteHeader(http.StatusNoContent)
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -220,17 +221,80 @@ func setupRouter(cfg Config) *chi.Mux {
 		r.Method(http.MethodPatch, "/playlists/{id}", playlistProxy)
 		r.Method(http.MethodGet, "/playlists/{id}", playlistProxy)
 		r.Method(http.MethodDelete, "/playlists/{id}", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/restore", playlistProxy)
+		r.Method(http.MethodGet, "/users/me/trash", playlistProxy)
+
+		r.Method(http.MethodGet, "/users/me/devices", playlistProxy)
+		r.Method(http.MethodPost, "/users/me/devices", playlistProxy)
+		r.Method(http.MethodPatch, "/users/me/devices/{deviceId}", playlistProxy)
+		r.Method(http.MethodPost, "/users/me/devices/{deviceId}/heartbeat", playlistProxy)
+		r.Method(http.MethodDelete, "/users/me/devices/{deviceId}", playlistProxy)
 
 		r.Method(http.MethodPost, "/playlists/{id}/tracks", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/tracks:batch", playlistProxy)
 		r.Method(http.MethodPatch, "/playlists/{id}/tracks/{trackId}", playlistProxy)
 		r.Method(http.MethodDelete, "/playlists/{id}/tracks/{trackId}", playlistProxy)
 
 		r.Method(http.MethodPost, "/playlists/{id}/tracks/{trackId}/vote", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/tracks/{trackId}/reactions", playlistProxy)
+		r.Method(http.MethodDelete, "/playlists/{id}/tracks/{trackId}/reactions", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/tracks/{trackId}/comments", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/tracks/{trackId}/comments", playlistProxy)
+		r.Method(http.MethodDelete, "/playlists/{id}/tracks/{trackId}/comments/{commentId}", playlistProxy)
 		r.Method(http.MethodPost, "/playlists/{id}/next", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/queue", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/player", playlistProxy)
+		r.Method(http.MethodDelete, "/playlists/{id}/player", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/player/heartbeat", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/active-device", playlistProxy)
+		r.Method(http.MethodPut, "/playlists/{id}/active-device", playlistProxy)
+		r.Method(http.MethodDelete, "/playlists/{id}/active-device", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/delegations", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/delegations", playlistProxy)
+		r.Method(http.MethodDelete, "/playlists/{id}/delegations/{userId}", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/presence", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/skip-votes", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/skip-votes", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/history", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/history/stats", playlistProxy)
 
 		r.Method(http.MethodGet, "/playlists/{id}/invites", playlistProxy)
 		r.Method(http.MethodPost, "/playlists/{id}/invites", playlistProxy)
 		r.Method(http.MethodDelete, "/playlists/{id}/invites/{userId}", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/invites/pending", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/invites/accept", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/invites/decline", playlistProxy)
+		r.Method(http.MethodGet, "/users/me/playlist-invites", playlistProxy)
+
+		r.Method(http.MethodPost, "/playlists/{id}/join-links", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/join-links", playlistProxy)
+		r.Method(http.MethodDelete, "/playlists/{id}/join-links/{code}", playlistProxy)
+
+		r.Method(http.MethodPost, "/playlists/{id}/moderation/kick", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/moderation/bans", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/moderation/bans", playlistProxy)
+		r.Method(http.MethodDelete, "/playlists/{id}/moderation/bans/{userId}", playlistProxy)
+		r.Method(http.MethodPut, "/playlists/{id}/moderation/locks/{target}", playlistProxy)
+		r.Method(http.MethodDelete, "/playlists/{id}/moderation/locks/{target}", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/moderation/remove-tracks", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/moderation/log", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/content-rules", playlistProxy)
+		r.Method(http.MethodPut, "/playlists/{id}/content-rules", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/edit-license", playlistProxy)
+		r.Method(http.MethodPut, "/playlists/{id}/edit-license", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/smart", playlistProxy)
+		r.Method(http.MethodPut, "/playlists/{id}/smart", playlistProxy)
+		r.Method(http.MethodDelete, "/playlists/{id}/smart", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/smart/refresh", playlistProxy)
+		r.Method(http.MethodGet, "/playlists/{id}/suggestions", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/suggestions", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/suggestions/{suggestionId}/approve", playlistProxy)
+		r.Method(http.MethodPost, "/playlists/{id}/suggestions/{suggestionId}/reject", playlistProxy)
+
+		r.Method(http.MethodPost, "/playlists/{id}/follow", playlistProxy)
+		r.Method(http.MethodDelete, "/playlists/{id}/follow", playlistProxy)
+		r.Method(http.MethodGet, "/users/me/followed-playlists", playlistProxy)
+		r.Method(http.MethodGet, "/users/me/feed", playlistProxy)
 	})
 
 	// Events & Voting
@@ -245,11 +309,23 @@ func setupRouter(cfg Config) *chi.Mux {
 
 		r.Method(http.MethodPost, "/events/{id}/transfer-ownership", voteProxy)
 		r.Method(http.MethodDelete, "/events/{id}", voteProxy)
+		r.Method(http.MethodPost, "/events/{id}/restore", voteProxy)
 
 		r.Method(http.MethodGet, "/events/{id}/invites", voteProxy)
 		r.Method(http.MethodPost, "/events/{id}/invites", voteProxy)
 		r.Method(http.MethodDelete, "/events/{id}/invites/{userId}", voteProxy)
 
+		r.Method(http.MethodPost, "/events/{id}/join-links", voteProxy)
+		r.Method(http.MethodGet, "/events/{id}/join-links", voteProxy)
+		r.Method(http.MethodDelete, "/events/{id}/join-links/{code}", voteProxy)
+
+		r.Method(http.MethodPost, "/events/{id}/moderation/kick", voteProxy)
+		r.Method(http.MethodGet, "/events/{id}/moderation/bans", voteProxy)
+		r.Method(http.MethodPost, "/events/{id}/moderation/bans", voteProxy)
+		r.Method(http.MethodDelete, "/events/{id}/moderation/bans/{userId}", voteProxy)
+		r.Method(http.MethodPut, "/events/{id}/moderation/locks/{target}", voteProxy)
+		r.Method(http.MethodDelete, "/events/{id}/moderation/locks/{target}", voteProxy)
+
 		r.Method(http.MethodPost, "/events/{id}/vote", voteProxy)
 		r.Method(http.MethodDelete, "/events/{id}/vote", voteProxy)
 		r.Method(http.MethodDelete, "/events/{id}/votes", voteProxy)
@@ -258,6 +334,13 @@ func setupRouter(cfg Config) *chi.Mux {
 		r.Method(http.MethodGet, "/stats", voteProxy)
 	})
 
+	// Join codes are minted by playlist-service and vote-service.
+	api.Group(func(r chi.Router) {
+		r.Use(jwtAuthMiddleware(cfg.JWTSecret))
+		r.Use(rateLimitMiddleware(getenvInt("JOIN_RPS", 5), rateKeyUserOrIP, "join"))
+		r.Post("/join/{code}", joinHandler(playlistProxy, voteProxy))
+	})
+
 	// Mock routes
 	api.Mount("/mock", mockProxy)
 
@@ -266,8 +349,25 @@ func setupRouter(cfg Config) *chi.Mux {
 		r.Use(jwtAuthMiddleware(cfg.JWTSecret))
 		r.Use(rateLimitMiddleware(getenvInt("MUSIC_AUTHED_RPS", 30), rateKeyUserOrIP, "music_authed"))
 		r.Method(http.MethodGet, "/music/search", musicProxy)
+		r.Method(http.MethodGet, "/music/tracks/{provider}/{id}", musicProxy)
 	})
 
 	r.Mount("/", api)
 	return r
 }
+
+// joinHandler forwards POST /join/{code} to the service that minted the
+// code: playlist codes start with "P", event codes with "E".
+func joinHandler(playlistProxy, voteProxy http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := strings.ToUpper(chi.URLParam(r, "code"))
+		switch {
+		case strings.HasPrefix(code, "P"):
+			playlistProxy.ServeHTTP(w, r)
+		case strings.HasPrefix(code, "E"):
+			voteProxy.ServeHTTP(w, r)
+		default:
+			writeError(w, http.StatusNotFound, "join code not found or no longer valid")
+		}
+	}
+}
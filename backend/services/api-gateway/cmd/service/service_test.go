@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -145,3 +146,49 @@ func TestProxyErrorHandling(t *testing.T) {
 		t.Errorf("unexpected error message: %s", resp["error"])
 	}
 }
+
+func TestJoin_RoutesByCodePrefix(t *testing.T) {
+	backend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, "/join/") {
+				t.Errorf("unexpected path %q", r.URL.Path)
+			}
+			w.Header().Set("X-Backend", name)
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	playlists := backend("playlist")
+	defer playlists.Close()
+	votes := backend("vote")
+	defer votes.Close()
+
+	secret := []byte("test-secret")
+	r := setupRouter(Config{
+		PlaylistURL:  playlists.URL,
+		VoteURL:      votes.URL,
+		RateLimitRPS: 1000,
+		JWTSecret:    secret,
+	})
+	token := makeAccessTokenForTest(t, secret, "user-123")
+
+	tests := []struct {
+		code        string
+		wantStatus  int
+		wantBackend string
+	}{
+		{code: "PABCD234", wantStatus: http.StatusOK, wantBackend: "playlist"},
+		{code: "eabcd234", wantStatus: http.StatusOK, wantBackend: "vote"},
+		{code: "XABCD234", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/join/"+tt.code, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus || w.Header().Get("X-Backend") != tt.wantBackend {
+			t.Errorf("%s: got %d from %q, want %d from %q",
+				tt.code, w.Code, w.Header().Get("X-Backend"), tt.wantStatus, tt.wantBackend)
+		}
+	}
+}
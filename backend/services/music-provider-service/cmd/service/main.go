@@ -42,6 +42,7 @@ func main() {
 
 	r.Get("/health", srv.HandleHealth)
 	r.Get("/music/search", srv.HandleSearch)
+	r.Get("/music/tracks/{provider}/{id}", srv.HandleLookupTrack)
 
 	log.Printf("music-provider-service listening on :%s", port)
 	if err := http.ListenAndServe(":"+port, r); err != nil {
@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	trackCacheTTL         = 24 * time.Hour
+	missingTrackCacheTTL  = 10 * time.Minute
+	trackCacheNotFound    = "not_found"
+	trackCacheNotEmbedded = "not_embeddable"
+)
+
+var youtubeIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{6,32}$`)
+
+// cachedTrack is what is stored under a track cache key: either the track or
+// the reason it cannot be played.
+type cachedTrack struct {
+	Item  *MusicSearchItem `json:"item,omitempty"`
+	Error string           `json:"error,omitempty"`
+}
+
+// HandleLookupTrack returns the canonical metadata of a track.
+// GET /music/tracks/{provider}/{id}
+func (s *Server) HandleLookupTrack(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	providerParam := strings.ToLower(strings.TrimSpace(chi.URLParam(r, "provider")))
+	if providerParam != "youtube" {
+		writeError(w, http.StatusBadRequest, "unsupported provider")
+		return
+	}
+	id := strings.TrimSpace(chi.URLParam(r, "id"))
+	if !youtubeIDPattern.MatchString(id) {
+		writeError(w, http.StatusNotFound, ErrTrackNotFound.Error())
+		return
+	}
+
+	// --- CACHE KEY ---
+	cacheKey := "music:track:" + providerParam + ":" + id
+	if s.rdb != nil {
+		if cached, err := s.rdb.Get(ctx, cacheKey).Result(); err == nil {
+			var entry cachedTrack
+			if json.Unmarshal([]byte(cached), &entry) == nil {
+				writeLookup(w, entry)
+				return
+			}
+		}
+	}
+
+	item, err := s.provider.LookupTrack(ctx, id)
+	var entry cachedTrack
+	ttl := trackCacheTTL
+	switch {
+	case errors.Is(err, ErrTrackNotFound):
+		entry.Error = trackCacheNotFound
+		ttl = missingTrackCacheTTL
+	case errors.Is(err, ErrTrackNotEmbeddable):
+		entry.Error = trackCacheNotEmbedded
+		ttl = missingTrackCacheTTL
+	case err != nil:
+		writeError(w, http.StatusBadGateway, "failed to query provider")
+		return
+	default:
+		entry.Item = item
+	}
+
+	if s.rdb != nil {
+		if data, err := json.Marshal(entry); err == nil {
+			s.rdb.Set(ctx, cacheKey, data, ttl)
+		}
+	}
+	writeLookup(w, entry)
+}
+
+func writeLookup(w http.ResponseWriter, entry cachedTrack) {
+	switch {
+	case entry.Error == trackCacheNotEmbedded:
+		writeError(w, http.StatusUnprocessableEntity, ErrTrackNotEmbeddable.Error())
+	case entry.Error != "" || entry.Item == nil:
+		writeError(w, http.StatusNotFound, ErrTrackNotFound.Error())
+	default:
+		writeJSON(w, http.StatusOK, entry.Item)
+	}
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func lookupRouter(srv *Server) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/music/tracks/{provider}/{id}", srv.HandleLookupTrack)
+	return r
+}
+
+func TestHandleLookupTrack(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockP := new(MockProvider)
+		srv := NewServer(mockP, nil)
+
+		expected := &MusicSearchItem{
+			Title:           "Canonical Title",
+			Artist:          "Channel",
+			Provider:        "youtube",
+			ProviderTrackID: "dQw4w9WgXcQ",
+			ThumbnailURL:    "http://example.com/thumb.jpg",
+			DurationMs:      213000,
+		}
+		mockP.On("LookupTrack", mock.Anything, "dQw4w9WgXcQ").Return(expected, nil)
+
+		req, _ := http.NewRequest("GET", "/music/tracks/youtube/dQw4w9WgXcQ", nil)
+		rr := httptest.NewRecorder()
+		lookupRouter(srv).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var got MusicSearchItem
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+		assert.Equal(t, *expected, got)
+		mockP.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockP := new(MockProvider)
+		srv := NewServer(mockP, nil)
+		mockP.On("LookupTrack", mock.Anything, "missing123").Return(nil, ErrTrackNotFound)
+
+		req, _ := http.NewRequest("GET", "/music/tracks/youtube/missing123", nil)
+		rr := httptest.NewRecorder()
+		lookupRouter(srv).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Contains(t, rr.Body.String(), "track not found")
+	})
+
+	t.Run("not embeddable", func(t *testing.T) {
+		mockP := new(MockProvider)
+		srv := NewServer(mockP, nil)
+		mockP.On("LookupTrack", mock.Anything, "blocked123").Return(nil, ErrTrackNotEmbeddable)
+
+		req, _ := http.NewRequest("GET", "/music/tracks/youtube/blocked123", nil)
+		rr := httptest.NewRecorder()
+		lookupRouter(srv).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+		assert.Contains(t, rr.Body.String(), "cannot be embedded")
+	})
+
+	t.Run("malformed id", func(t *testing.T) {
+		srv := NewServer(new(MockProvider), nil)
+
+		req, _ := http.NewRequest("GET", "/music/tracks/youtube/bad%20id!", nil)
+		rr := httptest.NewRecorder()
+		lookupRouter(srv).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("unsupported provider", func(t *testing.T) {
+		srv := NewServer(new(MockProvider), nil)
+
+		req, _ := http.NewRequest("GET", "/music/tracks/spotify/abc123", nil)
+		rr := httptest.NewRecorder()
+		lookupRouter(srv).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		mockP := new(MockProvider)
+		srv := NewServer(mockP, nil)
+		mockP.On("LookupTrack", mock.Anything, "abc123").Return(nil, errors.New("provider down"))
+
+		req, _ := http.NewRequest("GET", "/music/tracks/youtube/abc123", nil)
+		rr := httptest.NewRecorder()
+		lookupRouter(srv).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadGateway, rr.Code)
+	})
+}
@@ -24,6 +24,14 @@ func (m *MockProvider) SearchTracks(ctx context.Context, query string, limit int
 	return args.Get(0).([]MusicSearchItem), args.Error(1)
 }
 
+func (m *MockProvider) LookupTrack(ctx context.Context, trackID string) (*MusicSearchItem, error) {
+	args := m.Called(ctx, trackID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*MusicSearchItem), args.Error(1)
+}
+
 func TestHandleSearch(t *testing.T) {
 	t.Run("success youtube", func(t *testing.T) {
 		mockP := new(MockProvider)
@@ -1,5 +1,7 @@
 package provider
 
+import "errors"
+
 type MusicSearchItem struct {
 	Title           string `json:"title"`
 	Artist          string `json:"artist"`          // channel / artist name
@@ -12,3 +14,8 @@ type MusicSearchItem struct {
 type SearchResponse struct {
 	Items []MusicSearchItem `json:"items"`
 }
+
+var (
+	ErrTrackNotFound      = errors.New("track not found")
+	ErrTrackNotEmbeddable = errors.New("track cannot be embedded")
+)
@@ -9,6 +9,9 @@ import (
 
 type Provider interface {
 	SearchTracks(ctx context.Context, query string, limit int) ([]MusicSearchItem, error)
+	// LookupTrack returns the canonical metadata of one track. It fails with
+	// ErrTrackNotFound or ErrTrackNotEmbeddable when the track cannot be played.
+	LookupTrack(ctx context.Context, trackID string) (*MusicSearchItem, error)
 }
 
 type Server struct {
@@ -141,15 +141,7 @@ func (c *YouTubeClient) fetchDurations(ctx context.Context, ids []string) (map[s
 	val.Set("id", strings.Join(ids, ","))
 	val.Set("key", c.apiKey)
 
-	// Construct base URL for videos endpoint
-	// Assuming searchURL is like "https://www.googleapis.com/youtube/v3/search"
-	// We need "https://www.googleapis.com/youtube/v3/videos"
-	baseURL := "https://www.googleapis.com/youtube/v3/videos"
-	if len(c.searchURL) > 7 && c.searchURL[len(c.searchURL)-7:] == "/search" {
-		baseURL = c.searchURL[:len(c.searchURL)-7] + "/videos"
-	}
-
-	reqURL := baseURL + "?" + val.Encode()
+	reqURL := c.videosURL() + "?" + val.Encode()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
 		return nil, err
@@ -177,6 +169,98 @@ func (c *YouTubeClient) fetchDurations(ctx context.Context, ids []string) (map[s
 	return durations, nil
 }
 
+// videosURL derives the videos endpoint from the search one.
+func (c *YouTubeClient) videosURL() string {
+	// Assuming searchURL is like "https://www.googleapis.com/youtube/v3/search"
+	// We need "https://www.googleapis.com/youtube/v3/videos"
+	baseURL := "https://www.googleapis.com/youtube/v3/videos"
+	if len(c.searchURL) > 7 && c.searchURL[len(c.searchURL)-7:] == "/search" {
+		baseURL = c.searchURL[:len(c.searchURL)-7] + "/videos"
+	}
+	return baseURL
+}
+
+type ytVideoDetailsResponse struct {
+	Items []struct {
+		ID      string `json:"id"`
+		Snippet struct {
+			Title        string `json:"title"`
+			ChannelTitle string `json:"channelTitle"`
+			Thumbnails   struct {
+				Default struct {
+					URL string `json:"url"`
+				} `json:"default"`
+				Medium struct {
+					URL string `json:"url"`
+				} `json:"medium"`
+				High struct {
+					URL string `json:"url"`
+				} `json:"high"`
+			} `json:"thumbnails"`
+		} `json:"snippet"`
+		ContentDetails struct {
+			Duration string `json:"duration"`
+		} `json:"contentDetails"`
+		Status struct {
+			PrivacyStatus string `json:"privacyStatus"`
+			Embeddable    bool   `json:"embeddable"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// LookupTrack fetches a single video by ID.
+func (c *YouTubeClient) LookupTrack(ctx context.Context, trackID string) (*MusicSearchItem, error) {
+	val := url.Values{}
+	val.Set("part", "snippet,contentDetails,status")
+	val.Set("id", trackID)
+	val.Set("key", c.apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.videosURL()+"?"+val.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("youtube videos status %d", resp.StatusCode)
+	}
+
+	var body ytVideoDetailsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if len(body.Items) == 0 || body.Items[0].Status.PrivacyStatus == "private" {
+		return nil, ErrTrackNotFound
+	}
+
+	it := body.Items[0]
+	if !it.Status.Embeddable {
+		return nil, ErrTrackNotEmbeddable
+	}
+
+	thumbs := it.Snippet.Thumbnails
+	thumb := thumbs.High.URL
+	if thumb == "" {
+		thumb = thumbs.Medium.URL
+	}
+	if thumb == "" {
+		thumb = thumbs.Default.URL
+	}
+
+	return &MusicSearchItem{
+		Title:           it.Snippet.Title,
+		Artist:          it.Snippet.ChannelTitle,
+		Provider:        "youtube",
+		ProviderTrackID: it.ID,
+		ThumbnailURL:    thumb,
+		DurationMs:      parseISO8601Duration(it.ContentDetails.Duration),
+	}, nil
+}
+
 func parseISO8601Duration(duration string) int {
 	// Simple regex parser for PT#M#S or PT#H#M#S
 	re := regexp.MustCompile(`PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?`)
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
@@ -118,3 +119,69 @@ func TestSearchTracks(t *testing.T) {
 		t.Errorf("Expected vid2 duration 90000, got %d", items[1].DurationMs)
 	}
 }
+
+func TestLookupTrack(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantErr  error
+		wantDur  int
+		wantName string
+	}{
+		{
+			name: "embeddable",
+			body: `{"items": [{
+				"id": "vid1",
+				"snippet": { "title": "Track 1", "channelTitle": "Artist 1", "thumbnails": { "medium": { "url": "http://img" } } },
+				"contentDetails": { "duration": "PT4M2S" },
+				"status": { "privacyStatus": "public", "embeddable": true }
+			}]}`,
+			wantDur:  242000,
+			wantName: "Track 1",
+		},
+		{
+			name: "not embeddable",
+			body: `{"items": [{
+				"id": "vid1",
+				"snippet": { "title": "Track 1" },
+				"status": { "privacyStatus": "public", "embeddable": false }
+			}]}`,
+			wantErr: ErrTrackNotEmbeddable,
+		},
+		{
+			name:    "missing",
+			body:    `{"items": []}`,
+			wantErr: ErrTrackNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewYouTubeClient("apikey", "https://mock.com/search")
+			client.http = NewMockClient(func(req *http.Request) *http.Response {
+				if !strings.HasSuffix(req.URL.Path, "/videos") || req.URL.Query().Get("id") != "vid1" {
+					return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader(""))}
+				}
+				return &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(strings.NewReader(tt.body)),
+					Header:     make(http.Header),
+				}
+			})
+
+			item, err := client.LookupTrack(context.Background(), "vid1")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LookupTrack returned error: %v", err)
+			}
+			if item.Title != tt.wantName || item.DurationMs != tt.wantDur || item.ThumbnailURL != "http://img" {
+				t.Errorf("unexpected item: %+v", item)
+			}
+		})
+	}
+}
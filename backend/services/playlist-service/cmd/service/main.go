@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	playlist "playlist-service/internal/playlist"
@@ -40,8 +41,40 @@ func main() {
 
 	s := playlist.NewServer(pool, rdb)
 
-	// Start background ticker for auto-advancing tracks
-	s.StartTicker(ctx)
+	musicProviderURL := getenv("MUSIC_PROVIDER_SERVICE_URL", "http://music-provider-service:3007")
+	userServiceURL := getenv("USER_SERVICE_URL", "http://user-service:3005")
+
+	// Radio mode refills low queues from music-provider-service
+	s.EnableRadio(musicProviderURL, userServiceURL)
+
+	// Owners delegate playback control to their friends only
+	s.EnableFriends(userServiceURL)
+
+	// Resolve added tracks against the provider; backfill older ones
+	s.EnableTrackLookup(musicProviderURL)
+	s.StartTrackBackfill(ctx)
+
+	// Drop invitations nobody answered in time
+	s.StartInviteCleanup(ctx)
+
+	// Restoring a playlist from the trash restores its event; purge the
+	// trash after the retention period
+	s.EnableEventRestore(getenv("VOTE_SERVICE_URL", "http://vote-service:3003"))
+	s.StartTrashPurge(ctx)
+
+	// Regenerate smart playlists whose rules ask for scheduled refreshes
+	s.StartSmartRefresh(ctx)
+
+	// Announce the next track shortly before the current one ends so
+	// players can pre-buffer it
+	upNextLead, err := strconv.Atoi(getenv("UP_NEXT_LEAD_SECONDS", "10"))
+	if err != nil || upNextLead < 0 {
+		log.Fatalf("playlist-service: invalid UP_NEXT_LEAD_SECONDS: %q", os.Getenv("UP_NEXT_LEAD_SECONDS"))
+	}
+	s.EnableUpNext(time.Duration(upNextLead) * time.Second)
+
+	// Arm per-playlist timers that advance tracks when they end
+	s.StartScheduler(ctx)
 
 	r := s.Router(
 		middleware.RequestID,
@@ -0,0 +1,208 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trackSearcher finds tracks for radio mode.
+type trackSearcher interface {
+	SearchTracks(ctx context.Context, query string, limit int) ([]trackInput, error)
+}
+
+// trackResolver returns the canonical metadata of a provider track.
+type trackResolver interface {
+	ResolveTrack(ctx context.Context, provider, trackID string) (trackInput, error)
+}
+
+var (
+	errTrackNotFound      = errors.New("track not found at provider")
+	errTrackNotEmbeddable = errors.New("track cannot be embedded")
+)
+
+// preferencesFetcher returns the music preferences of a user.
+type preferencesFetcher interface {
+	Preferences(ctx context.Context, userID string) (musicPreferences, error)
+}
+
+// musicPreferences mirrors the preferences of a user-service profile.
+type musicPreferences struct {
+	Genres  []string `json:"genres"`
+	Artists []string `json:"artists"`
+	Moods   []string `json:"moods"`
+}
+
+// friendLister returns the user IDs of a user's friends.
+type friendLister interface {
+	Friends(ctx context.Context, userID string) ([]string, error)
+}
+
+// eventRestorer restores the vote-service event linked to a playlist.
+type eventRestorer interface {
+	RestoreEvent(ctx context.Context, userID, eventID string) error
+}
+
+var serviceHTTPClient = &http.Client{Timeout: 5 * time.Second}
+
+// musicProviderClient calls GET /music/search on music-provider-service.
+type musicProviderClient struct {
+	baseURL string
+}
+
+func (c *musicProviderClient) SearchTracks(ctx context.Context, query string, limit int) ([]trackInput, error) {
+	v := url.Values{}
+	v.Set("query", query)
+	v.Set("limit", strconv.Itoa(limit))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(c.baseURL, "/")+"/music/search?"+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := serviceHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("music search failed: %d", resp.StatusCode)
+	}
+
+	var res struct {
+		Items []trackInput `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+// ResolveTrack calls GET /music/tracks/{provider}/{id}, which caches lookups.
+func (c *musicProviderClient) ResolveTrack(ctx context.Context, provider, trackID string) (trackInput, error) {
+	u := strings.TrimRight(c.baseURL, "/") + "/music/tracks/" + url.PathEscape(provider) + "/" + url.PathEscape(trackID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return trackInput{}, err
+	}
+
+	resp, err := serviceHTTPClient.Do(req)
+	if err != nil {
+		return trackInput{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return trackInput{}, errTrackNotFound
+	case http.StatusUnprocessableEntity:
+		return trackInput{}, errTrackNotEmbeddable
+	default:
+		return trackInput{}, fmt.Errorf("track lookup failed: %d", resp.StatusCode)
+	}
+
+	var in trackInput
+	if err := json.NewDecoder(resp.Body).Decode(&in); err != nil {
+		return trackInput{}, err
+	}
+	return in, nil
+}
+
+// userServiceClient reads a user's profile from user-service on their behalf.
+type userServiceClient struct {
+	baseURL string
+}
+
+func (c *userServiceClient) Preferences(ctx context.Context, userID string) (musicPreferences, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(c.baseURL, "/")+"/users/me", nil)
+	if err != nil {
+		return musicPreferences{}, err
+	}
+	req.Header.Set("X-User-Id", userID)
+
+	resp, err := serviceHTTPClient.Do(req)
+	if err != nil {
+		return musicPreferences{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return musicPreferences{}, fmt.Errorf("get profile failed: %d", resp.StatusCode)
+	}
+
+	var res struct {
+		Preferences musicPreferences `json:"preferences"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return musicPreferences{}, err
+	}
+	return res.Preferences, nil
+}
+
+// Friends calls GET /users/me/friends on behalf of userID.
+func (c *userServiceClient) Friends(ctx context.Context, userID string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(c.baseURL, "/")+"/users/me/friends", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-User-Id", userID)
+
+	resp, err := serviceHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list friends failed: %d", resp.StatusCode)
+	}
+
+	var res struct {
+		Items []struct {
+			UserID string `json:"userId"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(res.Items))
+	for _, it := range res.Items {
+		ids = append(ids, it.UserID)
+	}
+	return ids, nil
+}
+
+// voteServiceClient calls vote-service on behalf of a user.
+type voteServiceClient struct {
+	baseURL string
+}
+
+// RestoreEvent calls POST /events/{id}/restore. A 404 means the playlist has
+// no event in the trash, so there is nothing to restore.
+func (c *voteServiceClient) RestoreEvent(ctx context.Context, userID, eventID string) error {
+	u := strings.TrimRight(c.baseURL, "/") + "/events/" + url.PathEscape(eventID) + "/restore"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-User-Id", userID)
+
+	resp, err := serviceHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("restore event failed: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package playlist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxContentRuleEntries = 200
+	maxContentRuleLength  = 200
+
+	// contentProviderManual stands for tracks added without a provider in
+	// ContentRules.AllowedProviders.
+	contentProviderManual = "manual"
+)
+
+// Content rules are checked whenever a track is queued: on POST
+// /playlists/{id}/tracks, in batches and by radio mode. A rejected track
+// gets a 422 naming the rule it breaks. The owner and co-owners may queue it
+// anyway with "overrideRules": true; radio mode skips it.
+
+// decodeContentRules parses a content_rules column; an empty document
+// means no rules.
+func decodeContentRules(doc []byte) (ContentRules, error) {
+	var rules ContentRules
+	if len(doc) == 0 {
+		return rules, nil
+	}
+	if err := json.Unmarshal(doc, &rules); err != nil {
+		return ContentRules{}, fmt.Errorf("decode content rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (r ContentRules) clone() ContentRules {
+	r.BlockedArtists = append([]string(nil), r.BlockedArtists...)
+	r.BlockedKeywords = append([]string(nil), r.BlockedKeywords...)
+	r.AllowedProviders = append([]string(nil), r.AllowedProviders...)
+	return r
+}
+
+// normalize trims and de-duplicates the rules and validates them. It
+// returns a client-facing error message, or "" when the rules are valid.
+// Lists are never nil afterwards so they encode as [].
+func (r *ContentRules) normalize() string {
+	var msg string
+	clean := func(field string, list []string, lower bool) []string {
+		out := []string{}
+		seen := map[string]bool{}
+		for _, v := range list {
+			v = strings.TrimSpace(v)
+			if lower {
+				v = strings.ToLower(v)
+			}
+			if v == "" || seen[strings.ToLower(v)] {
+				continue
+			}
+			if len(v) > maxContentRuleLength && msg == "" {
+				msg = fmt.Sprintf("%s entries must be at most %d characters", field, maxContentRuleLength)
+			}
+			seen[strings.ToLower(v)] = true
+			out = append(out, v)
+		}
+		if len(out) > maxContentRuleEntries && msg == "" {
+			msg = fmt.Sprintf("%s must have at most %d entries", field, maxContentRuleEntries)
+		}
+		return out
+	}
+	r.BlockedArtists = clean("blockedArtists", r.BlockedArtists, false)
+	r.BlockedKeywords = clean("blockedKeywords", r.BlockedKeywords, false)
+	r.AllowedProviders = clean("allowedProviders", r.AllowedProviders, true)
+	if msg != "" {
+		return msg
+	}
+
+	for _, a := range r.BlockedArtists {
+		if normalizeArtist(a) == "" {
+			return fmt.Sprintf("blocked artist %q has no letters or digits", a)
+		}
+	}
+	for _, k := range r.BlockedKeywords {
+		if ruleText(k) == "" {
+			return fmt.Sprintf("blocked keyword %q has no letters or digits", k)
+		}
+	}
+	if r.MaxDurationMs < 0 {
+		return "maxDurationMs must be >= 0"
+	}
+	for _, p := range r.AllowedProviders {
+		if p != "youtube" && p != contentProviderManual {
+			return fmt.Sprintf("unknown provider %q (allowed: \"youtube\", %q)", p, contentProviderManual)
+		}
+	}
+	return ""
+}
+
+// violation returns why in breaks the rules, or "" when it may be queued.
+// Artists and keywords match whole words, ignoring case and punctuation:
+// "Band" blocks "Band feat. Singer" but not "Bandits", and "explicit"
+// blocks "Song (Explicit)". Tracks of unknown duration pass the duration
+// rule.
+func (r ContentRules) violation(in trackInput) string {
+	if len(r.AllowedProviders) > 0 {
+		provider := in.Provider
+		if provider == "" {
+			provider = contentProviderManual
+		}
+		allowed := false
+		for _, p := range r.AllowedProviders {
+			allowed = allowed || p == provider
+		}
+		if !allowed {
+			if in.Provider == "" {
+				return "tracks without a provider are not allowed in this playlist"
+			}
+			return fmt.Sprintf("provider %q is not allowed in this playlist", in.Provider)
+		}
+	}
+
+	artist := " " + normalizeArtist(in.Artist) + " "
+	for _, a := range r.BlockedArtists {
+		if strings.Contains(artist, " "+normalizeArtist(a)+" ") {
+			return fmt.Sprintf("artist %q is blocked in this playlist", a)
+		}
+	}
+
+	title := " " + ruleText(in.Title) + " "
+	for _, k := range r.BlockedKeywords {
+		if strings.Contains(title, " "+ruleText(k)+" ") {
+			return fmt.Sprintf("titles containing %q are blocked in this playlist", k)
+		}
+	}
+
+	if r.MaxDurationMs > 0 && in.DurationMs > r.MaxDurationMs {
+		return fmt.Sprintf("track is longer than the %s allowed in this playlist",
+			(time.Duration(r.MaxDurationMs) * time.Millisecond).Round(time.Second))
+	}
+	return ""
+}
+
+// ruleText lowercases s and keeps only letters and digits separated by
+// single spaces. Unlike normalizeTrackText it keeps bracketed annotations,
+// where markers such as "(Explicit)" usually are.
+func ruleText(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// checkContentRules rejects in if it breaks rules, unless a moderator asked
+// to override them. Denials are returned as *httpError.
+func checkContentRules(rules ContentRules, in trackInput, moderator bool) error {
+	if in.OverrideRules {
+		if !moderator {
+			return &httpError{status: http.StatusForbidden, msg: "only the owner and co-owners can override content rules"}
+		}
+		return nil
+	}
+	if reason := rules.violation(in); reason != "" {
+		return &httpError{status: http.StatusUnprocessableEntity, msg: "blocked by content rules: " + reason}
+	}
+	return nil
+}
+
+// handleGetContentRules returns the content rules of a playlist to anyone
+// who can see it, so clients can warn before adding a track.
+// GET /playlists/{id}/content-rules
+func (s *Server) handleGetContentRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "content rules access")
+		return
+	}
+
+	rules, err := s.store.ContentRules(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "get content rules")
+		return
+	}
+	rules.normalize()
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// handlePutContentRules replaces the content rules of a playlist. Only the
+// owner and co-owners may change them; tracks already queued are kept.
+// PUT /playlists/{id}/content-rules
+// Body: ContentRules
+func (s *Server) handlePutContentRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	var rules ContentRules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if msg := rules.normalize(); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if _, err := s.checkModeratorAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "content rules access")
+		return
+	}
+
+	if err := s.store.SetContentRules(ctx, playlistID, rules); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "playlist not found")
+			return
+		}
+		writeHTTPError(w, err, "set content rules")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.content_rules_updated",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"rules":      rules,
+		},
+	})
+	s.recordModeration(ctx, playlistID, userID, moderationContentRules, "", "")
+
+	writeJSON(w, http.StatusOK, rules)
+}
@@ -0,0 +1,193 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestContentRulesViolation(t *testing.T) {
+	rules := ContentRules{
+		BlockedArtists:   []string{"Band", "DJ Loud"},
+		BlockedKeywords:  []string{"explicit", "radio edit"},
+		MaxDurationMs:    5 * 60 * 1000,
+		AllowedProviders: []string{"youtube"},
+	}
+	yt := func(title, artist string, durationMs int) trackInput {
+		return trackInput{Title: title, Artist: artist, Provider: "youtube", ProviderTrack: "vid", DurationMs: durationMs}
+	}
+
+	tests := []struct {
+		name string
+		in   trackInput
+		want string // substring of the reason; "" when allowed
+	}{
+		{name: "Allowed", in: yt("Song", "Singer", 180000)},
+		{name: "Blocked Artist", in: yt("Song", "band", 0), want: `artist "Band"`},
+		{name: "Blocked Artist Featured", in: yt("Song", "Singer feat. Band", 0), want: `artist "Band"`},
+		{name: "Blocked Artist Channel", in: yt("Song", "DJ Loud - Topic", 0), want: `artist "DJ Loud"`},
+		{name: "Artist Prefix Only", in: yt("Song", "Bandits", 0)},
+		{name: "Keyword In Brackets", in: yt("Song (Explicit)", "Singer", 0), want: `"explicit"`},
+		{name: "Keyword Phrase", in: yt("Song - Radio Edit", "Singer", 0), want: `"radio edit"`},
+		{name: "Keyword Inside Word", in: yt("Inexplicit", "Singer", 0)},
+		{name: "Too Long", in: yt("Song", "Singer", 400000), want: "longer than the 5m0s"},
+		{name: "Unknown Duration", in: yt("Song", "Singer", 0)},
+		{name: "No Provider", in: trackInput{Title: "Song", Artist: "Singer"}, want: "without a provider"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rules.violation(tt.in)
+			if tt.want == "" && got != "" {
+				t.Errorf("expected no violation, got %q", got)
+			}
+			if tt.want != "" && !strings.Contains(got, tt.want) {
+				t.Errorf("expected violation containing %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	manual := ContentRules{AllowedProviders: []string{contentProviderManual}}
+	if got := manual.violation(trackInput{Title: "Song"}); got != "" {
+		t.Errorf("manual track with manual allowed: %q", got)
+	}
+	if got := manual.violation(yt("Song", "Singer", 0)); !strings.Contains(got, `provider "youtube"`) {
+		t.Errorf("youtube track with manual only: %q", got)
+	}
+}
+
+func TestContentRulesNormalize(t *testing.T) {
+	rules := ContentRules{
+		BlockedArtists:   []string{" Band ", "band", ""},
+		AllowedProviders: []string{"YouTube"},
+	}
+	if msg := rules.normalize(); msg != "" {
+		t.Fatalf("normalize: %s", msg)
+	}
+	if len(rules.BlockedArtists) != 1 || rules.BlockedArtists[0] != "Band" || rules.AllowedProviders[0] != "youtube" {
+		t.Errorf("normalized = %+v", rules)
+	}
+	if rules.BlockedKeywords == nil {
+		t.Error("expected empty keyword list, got nil")
+	}
+
+	for _, bad := range []ContentRules{
+		{AllowedProviders: []string{"spotify"}},
+		{BlockedKeywords: []string{"!!!"}},
+		{MaxDurationMs: -1},
+		{BlockedArtists: []string{strings.Repeat("a", maxContentRuleLength+1)}},
+	} {
+		if msg := bad.normalize(); msg == "" {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestHandleContentRules(t *testing.T) {
+	h, pl := newModerationTestServer(t)
+	path := "/playlists/" + pl.ID + "/content-rules"
+	rules := ContentRules{BlockedArtists: []string{"Band"}, BlockedKeywords: []string{"explicit"}}
+
+	if w := serveAs(h, "ed-1", "PUT", path, rules); w.Code != http.StatusForbidden {
+		t.Errorf("editor setting rules: got %d", w.Code)
+	}
+	if w := serveAs(h, "co-1", "PUT", path, ContentRules{AllowedProviders: []string{"spotify"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid rules: got %d", w.Code)
+	}
+	if w := serveAs(h, "co-1", "PUT", "/playlists/"+missingID+"/content-rules", rules); w.Code != http.StatusNotFound {
+		t.Errorf("rules of missing playlist: got %d", w.Code)
+	}
+	if w := serveAs(h, "co-1", "PUT", path, rules); w.Code != http.StatusOK {
+		t.Fatalf("set rules: got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	w := serveAs(h, "u-2", "GET", path, nil)
+	var got ContentRules
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if w.Code != http.StatusOK || len(got.BlockedArtists) != 1 || got.BlockedArtists[0] != "Band" {
+		t.Errorf("get rules = %d %+v", w.Code, got)
+	}
+
+	w = serveAs(h, "owner-1", "GET", "/playlists/"+pl.ID+"/moderation/log", nil)
+	var entries []ModerationEntry
+	_ = json.NewDecoder(w.Body).Decode(&entries)
+	if len(entries) != 1 || entries[0].Action != moderationContentRules || entries[0].ActorID != "co-1" {
+		t.Errorf("moderation log = %+v", entries)
+	}
+}
+
+func TestHandleAddTrack_ContentRules(t *testing.T) {
+	h, pl := newModerationTestServer(t)
+	base := "/playlists/" + pl.ID
+	serveAs(h, "owner-1", "PUT", base+"/content-rules", ContentRules{BlockedArtists: []string{"Band"}})
+	blocked := map[string]any{"title": "Song", "artist": "Band"}
+	override := map[string]any{"title": "Song", "artist": "Band", "overrideRules": true}
+
+	w := serveAs(h, "ed-1", "POST", base+"/tracks", blocked)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `artist \"Band\" is blocked`) {
+		t.Errorf("blocked track: got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if w := serveAs(h, "ed-1", "POST", base+"/tracks", override); w.Code != http.StatusForbidden {
+		t.Errorf("editor overriding: got %d", w.Code)
+	}
+	if w := serveAs(h, "ed-1", "POST", base+"/tracks", map[string]any{"title": "Song", "artist": "Singer"}); w.Code != http.StatusCreated {
+		t.Errorf("allowed track: got %d", w.Code)
+	}
+	if w := serveAs(h, "co-1", "POST", base+"/tracks", override); w.Code != http.StatusCreated {
+		t.Errorf("co-owner overriding: got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleBatchTracks_ContentRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		override bool
+		wantCode int
+	}{
+		{name: "Rejected", userID: "user-2", wantCode: http.StatusUnprocessableEntity},
+		{name: "Override By Editor", userID: "user-2", override: true, wantCode: http.StatusForbidden},
+		{name: "Override By Owner", userID: "user-1", override: true, wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &MockTx{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+					if strings.Contains(sql, "source = 'radio'") {
+						return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+					}
+					return &MockRow{}
+				},
+				ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+					return pgconn.CommandTag{}, nil
+				},
+			}
+			mockDB := batchOwnerDB(tx)
+			ownerRow := mockDB.QueryRowFunc
+			mockDB.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+				if strings.Contains(sql, "SELECT content_rules") {
+					return &MockRow{ScanFunc: func(dest ...any) error {
+						*dest[0].(*[]byte) = []byte(`{"blockedKeywords":["explicit"]}`)
+						return nil
+					}}
+				}
+				return ownerRow(ctx, sql, args...)
+			}
+
+			body := map[string]any{"operations": []map[string]any{{
+				"op":    "add",
+				"track": map[string]any{"title": "Song (Explicit)", "overrideRules": tt.override},
+			}}}
+			w := serveAs(NewServer(mockDB, nil).Router(), tt.userID, "POST", "/playlists/pl-1/tracks:batch", body)
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected %d, got %d. Body: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
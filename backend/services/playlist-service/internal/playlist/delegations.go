@@ -0,0 +1,185 @@
+package playlist
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxDelegationMinutes caps timed delegations; longer ones last until
+// revoked.
+const maxDelegationMinutes = 7 * 24 * 60
+
+// Owners delegate control of a playlist's player to specific users, e.g. a
+// friend running the party while they are away. A delegate may do whatever
+// checkPlaybackControl allows, even on a private playlist or one they may
+// not edit, until the delegation expires or is revoked. Delegates receive
+// "playlist.control_delegated" and "playlist.control_revoked" events; a
+// revoked delegate's player stops driving playback once its heartbeats are
+// rejected.
+
+// EnableFriends reads friendships from user-service, caching them for
+// friendCacheTTL: control can then be delegated to the owner's friends,
+// and friends-only playlists become visible to them (see visibility.go).
+// Without it delegations are refused.
+func (s *Server) EnableFriends(userServiceURL string) {
+	s.friends = newFriendCache(&userServiceClient{baseURL: userServiceURL}, friendCacheTTL)
+}
+
+// handleListDelegations lists the delegations in effect, newest first.
+// GET /playlists/{id}/delegations
+func (s *Server) handleListDelegations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkOwnerAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "list delegations access")
+		return
+	}
+	delegations, err := s.store.ListDelegations(ctx, playlistID, time.Now())
+	if err != nil {
+		writeHTTPError(w, err, "list delegations")
+		return
+	}
+	writeJSON(w, http.StatusOK, delegations)
+}
+
+// handleCreateDelegation delegates playback control to a user, replacing an
+// earlier delegation to them.
+// POST /playlists/{id}/delegations
+// Body: {"userId": "...", "minutes": 120} (minutes optional, 1-10080;
+// without it the delegation lasts until revoked)
+func (s *Server) handleCreateDelegation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	var body struct {
+		UserID  string `json:"userId"`
+		Minutes *int   `json:"minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	body.UserID = strings.TrimSpace(body.UserID)
+	if body.UserID == "" {
+		writeError(w, http.StatusBadRequest, "userId is required")
+		return
+	}
+	if body.Minutes != nil && (*body.Minutes < 1 || *body.Minutes > maxDelegationMinutes) {
+		writeError(w, http.StatusBadRequest, "minutes must be between 1 and "+strconv.Itoa(maxDelegationMinutes))
+		return
+	}
+
+	if err := s.checkOwnerAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "delegation access")
+		return
+	}
+	if body.UserID == userID {
+		writeError(w, http.StatusBadRequest, "cannot delegate to yourself")
+		return
+	}
+	mod, err := s.store.ModerationState(ctx, playlistID, body.UserID)
+	if err != nil {
+		writeHTTPError(w, err, "delegation moderation state")
+		return
+	}
+	if mod.Banned {
+		writeError(w, http.StatusConflict, "user is banned from this playlist")
+		return
+	}
+	if s.friends == nil {
+		writeError(w, http.StatusServiceUnavailable, "friendships are unavailable")
+		return
+	}
+	friends, err := s.friends.Friends(ctx, userID)
+	if err != nil {
+		log.Printf("playlist-service: delegation friends: %v", err)
+		writeError(w, http.StatusBadGateway, "could not load friends from user-service")
+		return
+	}
+	if !slices.Contains(friends, body.UserID) {
+		writeError(w, http.StatusForbidden, "playback control can only be delegated to friends")
+		return
+	}
+
+	d := PlaybackDelegation{PlaylistID: playlistID, UserID: body.UserID, GrantedBy: userID}
+	if body.Minutes != nil {
+		expires := time.Now().Add(time.Duration(*body.Minutes) * time.Minute).UTC()
+		d.ExpiresAt = &expires
+	}
+	if err := s.store.SetDelegation(ctx, &d); err != nil {
+		writeHTTPError(w, err, "set delegation")
+		return
+	}
+
+	// Notify the delegate.
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.control_delegated",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"userId":     d.UserID,
+			"delegation": d,
+		},
+	})
+
+	writeJSON(w, http.StatusCreated, d)
+}
+
+// handleDeleteDelegation revokes a delegation. The owner may revoke any; a
+// delegate may hand back their own.
+// DELETE /playlists/{id}/delegations/{userId}
+func (s *Server) handleDeleteDelegation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+	targetID := chi.URLParam(r, "userId")
+
+	if targetID != userID {
+		if err := s.checkOwnerAccess(ctx, playlistID, userID); err != nil {
+			writeHTTPError(w, err, "revoke delegation access")
+			return
+		}
+	}
+	err := s.store.RemoveDelegation(ctx, playlistID, targetID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "delegation not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "revoke delegation")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.control_revoked",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"userId":     targetID,
+			"revokedBy":  userID,
+		},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,155 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type fakeFriends struct {
+	friends map[string][]string
+	err     error
+}
+
+func (f fakeFriends) Friends(ctx context.Context, userID string) ([]string, error) {
+	return f.friends[userID], f.err
+}
+
+// newDelegationTestServer returns a memory-backed server with a private
+// playlist owned by owner-1 whose first track is playing. friend-1 is
+// owner-1's friend.
+func newDelegationTestServer(t *testing.T) (*Server, http.Handler, Playlist) {
+	t.Helper()
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	pl := Playlist{OwnerID: "owner-1", Name: "Party", IsPublic: false, EditMode: editModeInvited}
+	if err := store.CreatePlaylist(ctx, &pl, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"One", "Two", "Three"} {
+		if _, err := store.AddTrack(ctx, pl.ID, trackInput{Title: title, Artist: "Band"}, trackSourceUser); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServerWithStore(store, nil)
+	srv.friends = fakeFriends{friends: map[string][]string{"owner-1": {"friend-1"}}}
+	return srv, srv.Router(), pl
+}
+
+func TestHandleDelegations(t *testing.T) {
+	_, h, pl := newDelegationTestServer(t)
+	base := "/playlists/" + pl.ID
+
+	if w := serveAs(h, "friend-1", "POST", base+"/next", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("next before delegating: got %d", w.Code)
+	}
+
+	w := serveAs(h, "owner-1", "POST", base+"/delegations", map[string]any{"userId": "friend-1", "minutes": 60})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var d PlaybackDelegation
+	_ = json.NewDecoder(w.Body).Decode(&d)
+	if d.UserID != "friend-1" || d.GrantedBy != "owner-1" || d.ExpiresAt == nil || time.Until(*d.ExpiresAt).Round(time.Minute) != time.Hour {
+		t.Errorf("delegation = %+v", d)
+	}
+
+	// The delegate controls playback of the private playlist.
+	if w := serveAs(h, "friend-1", "POST", base+"/next", nil); w.Code != http.StatusOK {
+		t.Errorf("next as delegate: got %d", w.Code)
+	}
+	if w := serveAs(h, "friend-1", "POST", base+"/player/heartbeat", map[string]any{"playerId": "pc-1", "state": playerPlaying}); w.Code != http.StatusOK {
+		t.Errorf("heartbeat as delegate: got %d", w.Code)
+	}
+
+	w = serveAs(h, "owner-1", "GET", base+"/delegations", nil)
+	var list []PlaybackDelegation
+	_ = json.NewDecoder(w.Body).Decode(&list)
+	if w.Code != http.StatusOK || len(list) != 1 || list[0].UserID != "friend-1" {
+		t.Errorf("list = %d %+v", w.Code, list)
+	}
+	if w := serveAs(h, "friend-1", "GET", base+"/delegations", nil); w.Code != http.StatusForbidden {
+		t.Errorf("list as delegate: got %d", w.Code)
+	}
+
+	// The delegate hands control back.
+	if w := serveAs(h, "friend-1", "DELETE", base+"/delegations/friend-1", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("revoke own delegation: got %d", w.Code)
+	}
+	if w := serveAs(h, "friend-1", "POST", base+"/next", nil); w.Code != http.StatusForbidden {
+		t.Errorf("next after revoking: got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "DELETE", base+"/delegations/friend-1", nil); w.Code != http.StatusNotFound {
+		t.Errorf("revoke twice: got %d", w.Code)
+	}
+}
+
+func TestHandleCreateDelegation_Validation(t *testing.T) {
+	srv, h, pl := newDelegationTestServer(t)
+	srv.friends = fakeFriends{friends: map[string][]string{"owner-1": {"friend-1", "banned-1"}}}
+	path := "/playlists/" + pl.ID + "/delegations"
+
+	serveAs(h, "owner-1", "POST", "/playlists/"+pl.ID+"/moderation/bans", map[string]string{"userId": "banned-1"})
+
+	tests := []struct {
+		name   string
+		userID string
+		path   string
+		body   map[string]any
+		want   int
+	}{
+		{name: "No User", path: path, body: map[string]any{"userId": "friend-1"}, want: http.StatusUnauthorized},
+		{name: "No Delegate", userID: "owner-1", path: path, body: map[string]any{}, want: http.StatusBadRequest},
+		{name: "Invalid Minutes", userID: "owner-1", path: path, body: map[string]any{"userId": "friend-1", "minutes": 0}, want: http.StatusBadRequest},
+		{name: "Not Owner", userID: "friend-1", path: path, body: map[string]any{"userId": "friend-2"}, want: http.StatusForbidden},
+		{name: "Missing Playlist", userID: "owner-1", path: "/playlists/" + missingID + "/delegations", body: map[string]any{"userId": "friend-1"}, want: http.StatusNotFound},
+		{name: "Self", userID: "owner-1", path: path, body: map[string]any{"userId": "owner-1"}, want: http.StatusBadRequest},
+		{name: "Banned", userID: "owner-1", path: path, body: map[string]any{"userId": "banned-1"}, want: http.StatusConflict},
+		{name: "Not A Friend", userID: "owner-1", path: path, body: map[string]any{"userId": "stranger"}, want: http.StatusForbidden},
+		{name: "Friend Until Revoked", userID: "owner-1", path: path, body: map[string]any{"userId": "friend-1"}, want: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serveAs(h, tt.userID, "POST", tt.path, tt.body); w.Code != tt.want {
+				t.Errorf("expected %d, got %d. Body: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleCreateDelegation_FriendsUnavailable(t *testing.T) {
+	srv, h, pl := newDelegationTestServer(t)
+	path := "/playlists/" + pl.ID + "/delegations"
+
+	srv.friends = fakeFriends{err: errors.New("connection refused")}
+	if w := serveAs(h, "owner-1", "POST", path, map[string]any{"userId": "friend-1"}); w.Code != http.StatusBadGateway {
+		t.Errorf("user-service down: got %d", w.Code)
+	}
+	srv.friends = nil
+	if w := serveAs(h, "owner-1", "POST", path, map[string]any{"userId": "friend-1"}); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("friends disabled: got %d", w.Code)
+	}
+}
+
+func TestHandleDelegations_BannedDelegate(t *testing.T) {
+	_, h, pl := newDelegationTestServer(t)
+	base := "/playlists/" + pl.ID
+
+	if w := serveAs(h, "owner-1", "POST", base+"/delegations", map[string]any{"userId": "friend-1"}); w.Code != http.StatusCreated {
+		t.Fatalf("delegate: got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if w := serveAs(h, "owner-1", "POST", base+"/moderation/bans", map[string]string{"userId": "friend-1"}); w.Code != http.StatusCreated {
+		t.Fatalf("ban: got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if w := serveAs(h, "friend-1", "POST", base+"/next", nil); w.Code != http.StatusForbidden {
+		t.Errorf("next as banned delegate: got %d", w.Code)
+	}
+}
@@ -0,0 +1,399 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Device capabilities.
+const (
+	capabilityPlayback = "playback" // outputs audio
+	capabilityRemote   = "remote"   // controls playback
+	capabilityVolume   = "volume"   // exposes a volume control
+)
+
+var deviceCapabilities = []string{capabilityPlayback, capabilityRemote, capabilityVolume}
+
+var devicePlatforms = []string{"web", "ios", "android", "desktop", "speaker"}
+
+const (
+	// deviceOnlineWindow is how long after its latest heartbeat a device
+	// counts as online. Devices send one about every 20 seconds.
+	deviceOnlineWindow = 60 * time.Second
+
+	maxDeviceNameLength = 100
+	maxDevicesPerUser   = 25
+)
+
+// Users register each app instance they run as a device. Per playlist,
+// anyone who may control playback picks the active output device: only it
+// may attach as the playlist's player (see player.go) and output audio,
+// while the other devices act as remotes. Every change publishes
+// "player.device_changed".
+
+// online reports whether d sent a heartbeat within deviceOnlineWindow.
+func (d Device) online(now time.Time) bool {
+	return now.Sub(d.LastSeenAt) < deviceOnlineWindow
+}
+
+// normalizeCapabilities validates capabilities and drops duplicates.
+func normalizeCapabilities(in []string) ([]string, bool) {
+	out := []string{}
+	for _, c := range in {
+		if !slices.Contains(deviceCapabilities, c) {
+			return nil, false
+		}
+		if !slices.Contains(out, c) {
+			out = append(out, c)
+		}
+	}
+	return out, true
+}
+
+// deviceBody is the body of POST and PATCH /users/me/devices.
+type deviceBody struct {
+	Name         *string   `json:"name"`
+	Platform     *string   `json:"platform"`
+	Capabilities *[]string `json:"capabilities"`
+}
+
+// apply validates the fields set in b and copies them to d.
+func (b deviceBody) apply(d *Device) error {
+	if b.Name != nil {
+		name := strings.TrimSpace(*b.Name)
+		if name == "" || len(name) > maxDeviceNameLength {
+			return &httpError{status: http.StatusBadRequest, msg: "name must be between 1 and 100 characters"}
+		}
+		d.Name = name
+	}
+	if b.Platform != nil {
+		if !slices.Contains(devicePlatforms, *b.Platform) {
+			return &httpError{status: http.StatusBadRequest, msg: `invalid platform (must be "web", "ios", "android", "desktop" or "speaker")`}
+		}
+		d.Platform = *b.Platform
+	}
+	if b.Capabilities != nil {
+		caps, ok := normalizeCapabilities(*b.Capabilities)
+		if !ok {
+			return &httpError{status: http.StatusBadRequest, msg: `invalid capability (must be "playback", "remote" or "volume")`}
+		}
+		d.Capabilities = caps
+	}
+	return nil
+}
+
+// handleListDevices lists the caller's devices, most recently seen first.
+// GET /users/me/devices
+func (s *Server) handleListDevices(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+
+	devices, err := s.store.ListDevices(r.Context(), userID)
+	if err != nil {
+		writeHTTPError(w, err, "list devices")
+		return
+	}
+	writeJSON(w, http.StatusOK, devices)
+}
+
+// handleRegisterDevice registers a device of the caller.
+// POST /users/me/devices
+// Body: {"name": "Bar PC", "platform": "desktop", "capabilities": ["playback"]}
+func (s *Server) handleRegisterDevice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+
+	var body deviceBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if body.Name == nil || body.Platform == nil {
+		writeError(w, http.StatusBadRequest, "name and platform are required")
+		return
+	}
+	d := Device{UserID: userID, Capabilities: []string{}}
+	if err := body.apply(&d); err != nil {
+		writeHTTPError(w, err, "register device")
+		return
+	}
+
+	devices, err := s.store.ListDevices(ctx, userID)
+	if err != nil {
+		writeHTTPError(w, err, "list devices")
+		return
+	}
+	if len(devices) >= maxDevicesPerUser {
+		writeError(w, http.StatusConflict, "too many devices; remove an old one first")
+		return
+	}
+	if err := s.store.RegisterDevice(ctx, &d); err != nil {
+		writeHTTPError(w, err, "register device")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, d)
+}
+
+// handleUpdateDevice renames a device of the caller or changes its
+// capabilities.
+// PATCH /users/me/devices/{deviceId}
+// Body: {"name": "...", "platform": "...", "capabilities": [...]} (all optional)
+func (s *Server) handleUpdateDevice(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+
+	var body deviceBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	d, err := s.store.UpdateDevice(r.Context(), userID, chi.URLParam(r, "deviceId"), body.apply)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "device not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "update device")
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// handleDeviceHeartbeat keeps a device of the caller online.
+// POST /users/me/devices/{deviceId}/heartbeat
+func (s *Server) handleDeviceHeartbeat(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+
+	now := time.Now()
+	d, err := s.store.UpdateDevice(r.Context(), userID, chi.URLParam(r, "deviceId"), func(d *Device) error {
+		d.LastSeenAt = now
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "device not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "device heartbeat")
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+// handleDeleteDevice removes a device of the caller.
+// DELETE /users/me/devices/{deviceId}
+func (s *Server) handleDeleteDevice(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+
+	err := s.store.DeleteDevice(r.Context(), userID, chi.URLParam(r, "deviceId"))
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "device not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "delete device")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetActiveDevice returns the active output device of a playlist.
+// GET /playlists/{id}/active-device
+func (s *Server) handleGetActiveDevice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "active device access")
+		return
+	}
+	deviceID, err := s.store.ActiveDevice(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "get active device")
+		return
+	}
+	if deviceID == "" {
+		writeJSON(w, http.StatusOK, map[string]any{"device": nil, "online": false})
+		return
+	}
+	d, err := s.store.Device(ctx, deviceID)
+	if err != nil {
+		writeHTTPError(w, err, "get device")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"device": d, "online": d.online(time.Now())})
+}
+
+// handleSetActiveDevice makes a device the active output device of a
+// playlist. The device must be online, able to play audio and belong to a
+// user who may control playback. A player attached from another device is
+// detached so the chosen one can take over.
+// PUT /playlists/{id}/active-device
+// Body: {"deviceId": "..."}
+func (s *Server) handleSetActiveDevice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	var body struct {
+		DeviceID string `json:"deviceId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if body.DeviceID == "" {
+		writeError(w, http.StatusBadRequest, "deviceId is required")
+		return
+	}
+
+	if err := s.checkPlaybackControl(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "active device access")
+		return
+	}
+	d, err := s.store.Device(ctx, body.DeviceID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "device not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "get device")
+		return
+	}
+	if !slices.Contains(d.Capabilities, capabilityPlayback) {
+		writeError(w, http.StatusBadRequest, "device cannot play audio")
+		return
+	}
+	if !d.online(time.Now()) {
+		writeError(w, http.StatusConflict, "device is offline")
+		return
+	}
+	if d.UserID != userID {
+		var he *httpError
+		if err := s.checkPlaybackControl(ctx, playlistID, d.UserID); errors.As(err, &he) {
+			writeError(w, http.StatusForbidden, "the device's user may not control playback")
+			return
+		} else if err != nil {
+			writeHTTPError(w, err, "device user access")
+			return
+		}
+	}
+
+	previous, err := s.store.ActiveDevice(ctx, playlistID)
+	if err != nil {
+		writeHTTPError(w, err, "get active device")
+		return
+	}
+	if err := s.store.SetActiveDevice(ctx, playlistID, d.ID); err != nil {
+		writeHTTPError(w, err, "set active device")
+		return
+	}
+	_, err = s.store.UpdatePlayer(ctx, playlistID, func(cur *PlayerState) (*PlayerState, error) {
+		if cur != nil && cur.PlayerID != d.ID {
+			return nil, nil
+		}
+		return cur, nil
+	})
+	if err != nil {
+		writeHTTPError(w, err, "detach player")
+		return
+	}
+
+	s.publishDeviceChanged(ctx, playlistID, userID, previous, &d)
+
+	writeJSON(w, http.StatusOK, map[string]any{"device": d, "online": true})
+}
+
+// handleClearActiveDevice clears the active output device, letting any
+// device attach as the player again.
+// DELETE /playlists/{id}/active-device
+func (s *Server) handleClearActiveDevice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkPlaybackControl(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "active device access")
+		return
+	}
+	previous, err := s.store.ActiveDevice(ctx, playlistID)
+	if err != nil {
+		writeHTTPError(w, err, "get active device")
+		return
+	}
+	if previous == "" {
+		writeError(w, http.StatusNotFound, "no active device")
+		return
+	}
+	if err := s.store.SetActiveDevice(ctx, playlistID, ""); err != nil {
+		writeHTTPError(w, err, "clear active device")
+		return
+	}
+
+	s.publishDeviceChanged(ctx, playlistID, userID, previous, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishDeviceChanged publishes "player.device_changed"; d is nil when the
+// active device was cleared.
+func (s *Server) publishDeviceChanged(ctx context.Context, playlistID, userID, previousID string, d *Device) {
+	payload := map[string]any{
+		"playlistId":       playlistID,
+		"deviceId":         nil,
+		"device":           d,
+		"previousDeviceId": previousID,
+		"changedBy":        userID,
+	}
+	if d != nil {
+		payload["deviceId"] = d.ID
+	}
+	s.publishEvent(ctx, map[string]any{
+		"type":    "player.device_changed",
+		"payload": payload,
+	})
+}
@@ -0,0 +1,163 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func registerDevice(t *testing.T, h http.Handler, userID, name string, capabilities ...string) Device {
+	t.Helper()
+	w := serveAs(h, userID, "POST", "/users/me/devices", map[string]any{
+		"name":         name,
+		"platform":     "web",
+		"capabilities": capabilities,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register %s: got %d. Body: %s", name, w.Code, w.Body.String())
+	}
+	var d Device
+	_ = json.NewDecoder(w.Body).Decode(&d)
+	return d
+}
+
+func TestHandleDevices(t *testing.T) {
+	h := NewServerWithStore(NewMemoryStore(), nil).Router()
+
+	d := registerDevice(t, h, "u-1", " Bar PC ", capabilityPlayback, capabilityVolume, capabilityPlayback)
+	if d.ID == "" || d.Name != "Bar PC" || len(d.Capabilities) != 2 {
+		t.Errorf("registered device = %+v", d)
+	}
+
+	w := serveAs(h, "u-1", "PATCH", "/users/me/devices/"+d.ID, map[string]any{"name": "Venue PC"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("rename: got %d", w.Code)
+	}
+	if w := serveAs(h, "u-2", "PATCH", "/users/me/devices/"+d.ID, map[string]any{"name": "Mine"}); w.Code != http.StatusNotFound {
+		t.Errorf("rename another user's device: got %d", w.Code)
+	}
+	if w := serveAs(h, "u-1", "POST", "/users/me/devices/"+d.ID+"/heartbeat", nil); w.Code != http.StatusOK {
+		t.Errorf("heartbeat: got %d", w.Code)
+	}
+
+	w = serveAs(h, "u-1", "GET", "/users/me/devices", nil)
+	var list []Device
+	_ = json.NewDecoder(w.Body).Decode(&list)
+	if len(list) != 1 || list[0].Name != "Venue PC" {
+		t.Errorf("list = %+v", list)
+	}
+	w = serveAs(h, "u-2", "GET", "/users/me/devices", nil)
+	list = nil
+	_ = json.NewDecoder(w.Body).Decode(&list)
+	if len(list) != 0 {
+		t.Errorf("list of another user = %+v", list)
+	}
+
+	if w := serveAs(h, "u-1", "DELETE", "/users/me/devices/"+d.ID, nil); w.Code != http.StatusNoContent {
+		t.Errorf("delete: got %d", w.Code)
+	}
+	if w := serveAs(h, "u-1", "DELETE", "/users/me/devices/"+d.ID, nil); w.Code != http.StatusNotFound {
+		t.Errorf("delete twice: got %d", w.Code)
+	}
+}
+
+func TestHandleRegisterDevice_Validation(t *testing.T) {
+	h := NewServerWithStore(NewMemoryStore(), nil).Router()
+
+	tests := []struct {
+		name   string
+		userID string
+		body   map[string]any
+		want   int
+	}{
+		{name: "No User", body: map[string]any{"name": "PC", "platform": "web"}, want: http.StatusUnauthorized},
+		{name: "No Name", userID: "u-1", body: map[string]any{"platform": "web"}, want: http.StatusBadRequest},
+		{name: "Blank Name", userID: "u-1", body: map[string]any{"name": " ", "platform": "web"}, want: http.StatusBadRequest},
+		{name: "Invalid Platform", userID: "u-1", body: map[string]any{"name": "PC", "platform": "toaster"}, want: http.StatusBadRequest},
+		{name: "Invalid Capability", userID: "u-1", body: map[string]any{"name": "PC", "platform": "web", "capabilities": []string{"fly"}}, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serveAs(h, tt.userID, "POST", "/users/me/devices", tt.body); w.Code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleActiveDevice(t *testing.T) {
+	h, store, pl := newPlayerTestServer(t)
+	path := "/playlists/" + pl.ID + "/active-device"
+
+	pc := registerDevice(t, h, "owner-1", "Bar PC", capabilityPlayback)
+	remote := registerDevice(t, h, "owner-1", "Phone", capabilityRemote)
+	listenerPC := registerDevice(t, h, "listener", "Laptop", capabilityPlayback)
+
+	// A player attached from the phone is handed over to the PC.
+	if code, _ := heartbeat(t, h, "owner-1", pl.ID, map[string]any{"playerId": remote.ID, "state": playerPlaying}); code != http.StatusOK {
+		t.Fatalf("attach from the phone: got %d", code)
+	}
+
+	if w := serveAs(h, "owner-1", "PUT", path, map[string]string{"deviceId": remote.ID}); w.Code != http.StatusBadRequest {
+		t.Errorf("device without playback: got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "PUT", path, map[string]string{"deviceId": listenerPC.ID}); w.Code != http.StatusForbidden {
+		t.Errorf("device of a listener: got %d", w.Code)
+	}
+	if w := serveAs(h, "listener", "PUT", path, map[string]string{"deviceId": listenerPC.ID}); w.Code != http.StatusForbidden {
+		t.Errorf("listener selecting: got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "PUT", path, map[string]string{"deviceId": missingID}); w.Code != http.StatusNotFound {
+		t.Errorf("missing device: got %d", w.Code)
+	}
+
+	if w := serveAs(h, "owner-1", "PUT", path, map[string]string{"deviceId": pc.ID}); w.Code != http.StatusOK {
+		t.Fatalf("select the PC: got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if player, _ := store.Player(context.Background(), pl.ID); player != nil {
+		t.Errorf("expected the phone's player to be detached, got %+v", player)
+	}
+
+	// Only the active device may play.
+	if code, _ := heartbeat(t, h, "owner-1", pl.ID, map[string]any{"playerId": remote.ID, "state": playerPlaying}); code != http.StatusConflict {
+		t.Errorf("phone playing: got %d", code)
+	}
+	if code, _ := heartbeat(t, h, "owner-1", pl.ID, map[string]any{"playerId": pc.ID, "state": playerPlaying}); code != http.StatusOK {
+		t.Errorf("PC playing: got %d", code)
+	}
+
+	w := serveAs(h, "listener", "GET", path, nil)
+	var got struct {
+		Device *Device `json:"device"`
+		Online bool    `json:"online"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if w.Code != http.StatusOK || got.Device == nil || got.Device.ID != pc.ID || !got.Online {
+		t.Errorf("GET active device = %d %+v", w.Code, got)
+	}
+
+	if w := serveAs(h, "owner-1", "DELETE", path, nil); w.Code != http.StatusNoContent {
+		t.Errorf("clear: got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "DELETE", path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("clear twice: got %d", w.Code)
+	}
+}
+
+func TestHandleSetActiveDevice_Offline(t *testing.T) {
+	h, store, pl := newPlayerTestServer(t)
+	pc := registerDevice(t, h, "owner-1", "Bar PC", capabilityPlayback)
+	if _, err := store.UpdateDevice(context.Background(), "owner-1", pc.ID, func(d *Device) error {
+		d.LastSeenAt = time.Now().Add(-deviceOnlineWindow)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := serveAs(h, "owner-1", "PUT", "/playlists/"+pl.ID+"/active-device", map[string]string{"deviceId": pc.ID}); w.Code != http.StatusConflict {
+		t.Errorf("offline device: got %d", w.Code)
+	}
+}
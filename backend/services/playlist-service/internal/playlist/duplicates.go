@@ -0,0 +1,105 @@
+package playlist
+
+import (
+	"strings"
+	"unicode"
+)
+
+func isValidDuplicatePolicy(p string) bool {
+	switch p {
+	case duplicatePolicyAllow, duplicatePolicyReject, duplicatePolicyMergeVote, duplicatePolicyAllowAfterPlayed:
+		return true
+	}
+	return false
+}
+
+// duplicateMatch is an existing track that a new track collides with.
+type duplicateMatch struct {
+	ID     string
+	Status string
+}
+
+// candidateTrack is the identity of a track about to be added.
+type candidateTrack struct {
+	Title           string
+	Artist          string
+	Provider        string
+	ProviderTrackID string
+}
+
+// duplicateStatuses lists the statuses of the existing tracks a new track
+// may not duplicate under policy; nil means duplicates are allowed.
+func duplicateStatuses(policy string) []string {
+	switch policy {
+	case duplicatePolicyReject:
+		return []string{"queued", "playing", "played"}
+	case duplicatePolicyMergeVote, duplicatePolicyAllowAfterPlayed:
+		return []string{"queued", "playing"}
+	}
+	return nil
+}
+
+// fuzzyMatches reports whether an existing track with the given title and
+// artist is the same song as c. A missing artist on either side matches any
+// artist.
+func (c candidateTrack) fuzzyMatches(title, artist string) bool {
+	if normalizeTrackText(title) != normalizeTrackText(c.Title) {
+		return false
+	}
+	a, b := normalizeArtist(c.Artist), normalizeArtist(artist)
+	return a == "" || b == "" || a == b
+}
+
+// duplicateRejectReason explains why a duplicate was refused.
+func duplicateRejectReason(policy, status string) string {
+	if status == "playing" {
+		return "track is already playing"
+	}
+	if policy == duplicatePolicyReject {
+		return "track is already in the playlist"
+	}
+	return "track is already queued"
+}
+
+// normalizeTrackText lowercases s, drops bracketed annotations such as
+// "(Official Video)" or "[Lyrics]" and keeps only letters and digits
+// separated by single spaces.
+func normalizeTrackText(s string) string {
+	var b strings.Builder
+	depth := 0
+	space := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r == '(' || r == '[' || r == '{':
+			depth++
+			continue
+		case r == ')' || r == ']' || r == '}':
+			if depth > 0 {
+				depth--
+			}
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		} else {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// normalizeArtist is normalizeTrackText plus removal of YouTube channel
+// suffixes ("Artist - Topic", "ArtistVEVO").
+func normalizeArtist(s string) string {
+	n := normalizeTrackText(s)
+	n = strings.TrimSuffix(n, " topic")
+	n = strings.TrimSuffix(n, "vevo")
+	return strings.TrimSpace(n)
+}
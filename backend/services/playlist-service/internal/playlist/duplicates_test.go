@@ -0,0 +1,140 @@
+package playlist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestNormalizeTrackText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Bohemian Rhapsody", "bohemian rhapsody"},
+		{"Bohemian Rhapsody (Official Video)", "bohemian rhapsody"},
+		{"  Bohemian   Rhapsody [Remastered 2011] ", "bohemian rhapsody"},
+		{"Don't Stop Me Now", "don t stop me now"},
+		{"(Intro)", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeTrackText(tt.in); got != tt.want {
+			t.Errorf("normalizeTrackText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if got := normalizeArtist("Queen - Topic"); got != "queen" {
+		t.Errorf("normalizeArtist topic = %q", got)
+	}
+	if got := normalizeArtist("QueenVEVO"); got != "queen" {
+		t.Errorf("normalizeArtist vevo = %q", got)
+	}
+}
+
+func TestHandleAddTrack_DuplicatePolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		dupStatus string
+		wantCode  int
+	}{
+		{name: "Reject", policy: duplicatePolicyReject, dupStatus: "played", wantCode: http.StatusConflict},
+		{name: "Allow After Played - Still Queued", policy: duplicatePolicyAllowAfterPlayed, dupStatus: "queued", wantCode: http.StatusConflict},
+		{name: "Merge Vote - Playing", policy: duplicatePolicyMergeVote, dupStatus: "playing", wantCode: http.StatusConflict},
+		{name: "Merge Vote - Queued", policy: duplicatePolicyMergeVote, dupStatus: "queued", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inserted := false
+			mockDB := &MockDB{}
+			mockDB.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+				if row, ok := smartRulesRow(sql); ok {
+					return row
+				}
+				if row, ok := moderationStateRow(sql); ok {
+					return row
+				}
+				if row, ok := contentRulesRow(sql); ok {
+					return row
+				}
+				switch {
+				case strings.Contains(sql, "SELECT owner_id"):
+					return &MockRow{ScanFunc: func(dest ...any) error {
+						*dest[0].(*string) = "user-1"
+						*dest[1].(*bool) = true
+						*dest[2].(*string) = "everyone"
+						return nil
+					}}
+				}
+				return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected query: " + sql) }}
+			}
+			// The duplicate check and the insert or vote share a transaction.
+			mockDB.BeginTxFunc = func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+				return &MockTx{
+					ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+						return pgconn.CommandTag{}, nil
+					},
+					QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+						switch {
+						case strings.Contains(sql, "SELECT duplicate_policy"):
+							return &MockRow{ScanFunc: func(dest ...any) error {
+								*dest[0].(*string) = tt.policy
+								return nil
+							}}
+						case strings.Contains(sql, "provider_track_id = $3"):
+							return &MockRow{ScanFunc: func(dest ...any) error {
+								*dest[0].(*string) = "tr-existing"
+								*dest[1].(*string) = tt.dupStatus
+								return nil
+							}}
+						case strings.Contains(sql, "INSERT INTO tracks"):
+							inserted = true
+							return &MockRow{}
+						case strings.Contains(sql, "SET vote_count = vote_count + 1"):
+							return &MockRow{ScanFunc: func(dest ...any) error {
+								*dest[0].(*int) = 2
+								*dest[1].(*string) = "playing" // skip reorder in this test
+								return nil
+							}}
+						}
+						return &MockRow{ScanFunc: func(dest ...any) error {
+							*dest[0].(*string) = "tr-existing"
+							return nil
+						}}
+					},
+				}, nil
+			}
+
+			srv := NewServer(mockDB, nil)
+			r := chi.NewRouter()
+			r.Post("/playlists/{id}/tracks", srv.handleAddTrack)
+
+			body, _ := json.Marshal(map[string]any{
+				"title":           "Song",
+				"provider":        "youtube",
+				"providerTrackId": "vid-1",
+			})
+			req := httptest.NewRequest("POST", "/playlists/pl-1/tracks", bytes.NewReader(body))
+			req.Header.Set("X-User-Id", "user-1")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("expected %d, got %d. Body: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if inserted {
+				t.Errorf("duplicate track must not be inserted")
+			}
+		})
+	}
+}
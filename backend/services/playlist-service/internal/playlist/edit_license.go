@@ -0,0 +1,255 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // the runtime image has no zoneinfo
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxEditLicenseRadiusM = 50000
+	earthRadiusM          = 6371000.0
+)
+
+// The edit license is checked on top of EditMode whenever the track list
+// changes: adding, moving and deleting tracks and in batches. Clients send
+// their position as "lat" and "lng", like votes on geo_time events do; in
+// the JSON body, or in the query of DELETE requests. A denial is a 403
+// naming the restriction. The owner and co-owners may always edit, so they
+// can manage the queue from anywhere.
+
+// clientLocation is the position a client reports with an edit.
+type clientLocation struct {
+	Lat *float64 `json:"lat,omitempty"`
+	Lng *float64 `json:"lng,omitempty"`
+}
+
+// queryLocation reads ?lat=&lng=; unparsable values count as missing.
+func queryLocation(r *http.Request) clientLocation {
+	parse := func(key string) *float64 {
+		v, err := strconv.ParseFloat(r.URL.Query().Get(key), 64)
+		if err != nil {
+			return nil
+		}
+		return &v
+	}
+	return clientLocation{Lat: parse("lat"), Lng: parse("lng")}
+}
+
+func (l EditLicense) clone() EditLicense {
+	if l.Lat != nil {
+		lat := *l.Lat
+		l.Lat = &lat
+	}
+	if l.Lng != nil {
+		lng := *l.Lng
+		l.Lng = &lng
+	}
+	if l.RadiusM != nil {
+		radius := *l.RadiusM
+		l.RadiusM = &radius
+	}
+	return l
+}
+
+// normalize trims the license and validates it. It returns a client-facing
+// error message, or "" when the license is valid.
+func (l *EditLicense) normalize() string {
+	l.OpensAt = strings.TrimSpace(l.OpensAt)
+	l.ClosesAt = strings.TrimSpace(l.ClosesAt)
+	l.TimeZone = strings.TrimSpace(l.TimeZone)
+
+	geo := l.Lat != nil || l.Lng != nil || l.RadiusM != nil
+	switch {
+	case geo && (l.Lat == nil || l.Lng == nil || l.RadiusM == nil):
+		return "lat, lng and radiusM must be set together"
+	case geo && (*l.Lat < -90 || *l.Lat > 90):
+		return "lat must be between -90 and 90"
+	case geo && (*l.Lng < -180 || *l.Lng > 180):
+		return "lng must be between -180 and 180"
+	case geo && (*l.RadiusM < 1 || *l.RadiusM > maxEditLicenseRadiusM):
+		return fmt.Sprintf("radiusM must be between 1 and %d", maxEditLicenseRadiusM)
+	}
+
+	if (l.OpensAt == "") != (l.ClosesAt == "") {
+		return "opensAt and closesAt must be set together"
+	}
+	if l.OpensAt == "" {
+		if l.TimeZone != "" {
+			return "timeZone requires opensAt and closesAt"
+		}
+		return ""
+	}
+	opens, ok1 := minuteOfDay(l.OpensAt)
+	closes, ok2 := minuteOfDay(l.ClosesAt)
+	switch {
+	case !ok1 || !ok2:
+		return `opensAt and closesAt must be times like "18:00"`
+	case opens == closes:
+		return "opensAt and closesAt must differ"
+	}
+	if _, err := time.LoadLocation(l.TimeZone); err != nil {
+		return fmt.Sprintf("unknown timeZone %q", l.TimeZone)
+	}
+	return ""
+}
+
+// minuteOfDay parses "HH:MM".
+func minuteOfDay(s string) (int, bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// violation returns why a client at loc may not edit at now, or "" when it
+// may. Opening hours that wrap past midnight, e.g. 18:00-02:00, span two
+// days.
+func (l EditLicense) violation(loc clientLocation, now time.Time) string {
+	if l.OpensAt != "" {
+		zone, err := time.LoadLocation(l.TimeZone)
+		if err != nil {
+			zone = time.UTC
+		}
+		local := now.In(zone)
+		minute := local.Hour()*60 + local.Minute()
+		opens, _ := minuteOfDay(l.OpensAt)
+		closes, _ := minuteOfDay(l.ClosesAt)
+		open := opens <= minute && minute < closes
+		if opens > closes {
+			open = minute >= opens || minute < closes
+		}
+		if !open {
+			return fmt.Sprintf("this playlist can only be edited between %s and %s (%s)", l.OpensAt, l.ClosesAt, zone)
+		}
+	}
+
+	if l.Lat != nil && l.Lng != nil && l.RadiusM != nil {
+		if loc.Lat == nil || loc.Lng == nil {
+			return "location (lat, lng) is required to edit this playlist"
+		}
+		if distanceM(*l.Lat, *l.Lng, *loc.Lat, *loc.Lng) > float64(*l.RadiusM) {
+			return fmt.Sprintf("this playlist can only be edited within %d m of the venue", *l.RadiusM)
+		}
+	}
+	return ""
+}
+
+// distanceM is the great-circle distance between two points, in meters.
+func distanceM(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := func(d float64) float64 { return d * math.Pi / 180 }
+
+	dLat := rad(lat2 - lat1)
+	dLng := rad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return earthRadiusM * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// checkEditLicense rejects edits from clients the playlist's edit license
+// excludes, unless the user moderates the playlist. Denials are returned as
+// *httpError.
+func (s *Server) checkEditLicense(ctx context.Context, playlistID string, moderator bool, loc clientLocation) error {
+	if moderator {
+		return nil
+	}
+	license, err := s.store.EditLicense(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		return &httpError{status: http.StatusNotFound, msg: "playlist not found"}
+	}
+	if err != nil {
+		return err
+	}
+	if reason := license.violation(loc, time.Now()); reason != "" {
+		return &httpError{status: http.StatusForbidden, msg: reason}
+	}
+	return nil
+}
+
+// handleGetEditLicense returns the edit license of a playlist to anyone who
+// can see it, so clients know when to send their location.
+// GET /playlists/{id}/edit-license
+func (s *Server) handleGetEditLicense(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "edit license access")
+		return
+	}
+
+	license, err := s.store.EditLicense(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "get edit license")
+		return
+	}
+	writeJSON(w, http.StatusOK, license)
+}
+
+// handlePutEditLicense replaces the edit license of a playlist; {} lifts
+// it. Only the owner and co-owners may change it.
+// PUT /playlists/{id}/edit-license
+// Body: EditLicense
+func (s *Server) handlePutEditLicense(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	var license EditLicense
+	if err := json.NewDecoder(r.Body).Decode(&license); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if msg := license.normalize(); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if _, err := s.checkModeratorAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "edit license access")
+		return
+	}
+
+	if err := s.store.SetEditLicense(ctx, playlistID, license); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "playlist not found")
+			return
+		}
+		writeHTTPError(w, err, "set edit license")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.edit_license_updated",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"license":    license,
+		},
+	})
+	s.recordModeration(ctx, playlistID, userID, moderationEditLicense, "", "")
+
+	writeJSON(w, http.StatusOK, license)
+}
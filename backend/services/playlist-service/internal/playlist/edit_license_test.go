@@ -0,0 +1,220 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestEditLicenseViolation(t *testing.T) {
+	lat, lng, radius := 48.85, 2.35, 150
+	venue := EditLicense{Lat: &lat, Lng: &lng, RadiusM: &radius}
+	night := EditLicense{OpensAt: "18:00", ClosesAt: "02:00", TimeZone: "Europe/Paris"}
+	at := func(lat, lng float64) clientLocation { return clientLocation{Lat: &lat, Lng: &lng} }
+	// 20:00 and 14:00 in Paris (UTC+2 in summer).
+	evening := time.Date(2026, 7, 3, 18, 0, 0, 0, time.UTC)
+	afternoon := time.Date(2026, 7, 3, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		license EditLicense
+		loc     clientLocation
+		now     time.Time
+		want    string // substring of the reason; "" when allowed
+	}{
+		{name: "No License", now: afternoon},
+		{name: "At The Venue", license: venue, loc: at(48.8505, 2.35), now: afternoon},
+		{name: "Outside The Radius", license: venue, loc: at(48.86, 2.35), now: afternoon, want: "within 150 m"},
+		{name: "No Location", license: venue, now: afternoon, want: "location (lat, lng) is required"},
+		{name: "Half A Location", license: venue, loc: clientLocation{Lat: &lat}, now: afternoon, want: "location (lat, lng) is required"},
+		{name: "Opening Hours", license: night, now: evening},
+		{name: "After Midnight", license: night, now: time.Date(2026, 7, 3, 23, 30, 0, 0, time.UTC)},
+		{name: "Closed", license: night, now: afternoon, want: "between 18:00 and 02:00 (Europe/Paris)"},
+		{name: "Closing Time", license: night, now: time.Date(2026, 7, 4, 0, 0, 0, 0, time.UTC), want: "between 18:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.license.violation(tt.loc, tt.now)
+			if tt.want == "" && got != "" {
+				t.Errorf("expected no violation, got %q", got)
+			}
+			if tt.want != "" && !strings.Contains(got, tt.want) {
+				t.Errorf("expected violation containing %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEditLicenseNormalize(t *testing.T) {
+	license := EditLicense{OpensAt: " 09:00 ", ClosesAt: "17:30"}
+	if msg := license.normalize(); msg != "" {
+		t.Fatalf("normalize: %s", msg)
+	}
+	if license.OpensAt != "09:00" {
+		t.Errorf("normalized = %+v", license)
+	}
+
+	lat, lng := 48.85, 2.35
+	bad90, zero, tooFar := 91.0, 0, maxEditLicenseRadiusM+1
+	for _, bad := range []EditLicense{
+		{Lat: &lat, Lng: &lng},
+		{Lat: &bad90, Lng: &lng, RadiusM: &tooFar},
+		{Lat: &lat, Lng: &lng, RadiusM: &zero},
+		{OpensAt: "18:00"},
+		{OpensAt: "25:00", ClosesAt: "02:00"},
+		{OpensAt: "18:00", ClosesAt: "18:00"},
+		{OpensAt: "18:00", ClosesAt: "02:00", TimeZone: "Mars/Olympus"},
+		{TimeZone: "Europe/Paris"},
+	} {
+		if msg := bad.normalize(); msg == "" {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestHandleEditLicense(t *testing.T) {
+	h, pl := newModerationTestServer(t)
+	base := "/playlists/" + pl.ID
+	path := base + "/edit-license"
+	license := map[string]any{"lat": 48.85, "lng": 2.35, "radiusM": 150}
+
+	if w := serveAs(h, "ed-1", "PUT", path, license); w.Code != http.StatusForbidden {
+		t.Errorf("editor setting the license: got %d", w.Code)
+	}
+	if w := serveAs(h, "co-1", "PUT", path, map[string]any{"lat": 48.85}); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid license: got %d", w.Code)
+	}
+	if w := serveAs(h, "co-1", "PUT", "/playlists/"+missingID+"/edit-license", license); w.Code != http.StatusNotFound {
+		t.Errorf("license of missing playlist: got %d", w.Code)
+	}
+	if w := serveAs(h, "co-1", "PUT", path, license); w.Code != http.StatusOK {
+		t.Fatalf("set license: got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	w := serveAs(h, "u-2", "GET", path, nil)
+	var got EditLicense
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if w.Code != http.StatusOK || got.RadiusM == nil || *got.RadiusM != 150 {
+		t.Errorf("get license = %d %+v", w.Code, got)
+	}
+
+	w = serveAs(h, "owner-1", "GET", base+"/moderation/log", nil)
+	var entries []ModerationEntry
+	_ = json.NewDecoder(w.Body).Decode(&entries)
+	if len(entries) != 1 || entries[0].Action != moderationEditLicense || entries[0].ActorID != "co-1" {
+		t.Errorf("moderation log = %+v", entries)
+	}
+}
+
+func TestHandleTracks_EditLicense(t *testing.T) {
+	h, pl := newModerationTestServer(t)
+	base := "/playlists/" + pl.ID
+	serveAs(h, "owner-1", "PUT", base+"/edit-license", map[string]any{"lat": 48.85, "lng": 2.35, "radiusM": 150})
+
+	add := func(userID string, body map[string]any) string {
+		t.Helper()
+		w := serveAs(h, userID, "POST", base+"/tracks", body)
+		var tr Track
+		_ = json.NewDecoder(w.Body).Decode(&tr)
+		if w.Code != http.StatusCreated {
+			return ""
+		}
+		return tr.ID
+	}
+
+	if w := serveAs(h, "u-2", "POST", base+"/tracks", map[string]any{"title": "Song"}); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "location") {
+		t.Errorf("adding without a location: got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if id := add("u-2", map[string]any{"title": "Song", "lat": 48.86, "lng": 2.35}); id != "" {
+		t.Error("adding away from the venue was allowed")
+	}
+	trackID := add("u-2", map[string]any{"title": "Song", "lat": 48.8505, "lng": 2.35})
+	if trackID == "" {
+		t.Fatal("adding at the venue was rejected")
+	}
+	if id := add("co-1", map[string]any{"title": "Remote"}); id == "" {
+		t.Error("co-owner adding without a location was rejected")
+	}
+
+	track := base + "/tracks/" + trackID
+	if w := serveAs(h, "u-2", "PATCH", track, map[string]any{"newPosition": 1}); w.Code != http.StatusForbidden {
+		t.Errorf("moving without a location: got %d", w.Code)
+	}
+	if w := serveAs(h, "u-2", "PATCH", track, map[string]any{"newPosition": 1, "lat": 48.8505, "lng": 2.35}); w.Code != http.StatusOK {
+		t.Errorf("moving at the venue: got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if w := serveAs(h, "u-2", "DELETE", track+"?lat=48.86&lng=2.35", nil); w.Code != http.StatusForbidden {
+		t.Errorf("deleting away from the venue: got %d", w.Code)
+	}
+	if w := serveAs(h, "u-2", "DELETE", track+"?lat=48.8505&lng=2.35", nil); w.Code != http.StatusNoContent {
+		t.Errorf("deleting at the venue: got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	// Outside opening hours even people at the venue cannot edit.
+	now := time.Now().UTC()
+	closed := map[string]any{"opensAt": now.Add(2 * time.Hour).Format("15:04"), "closesAt": now.Add(3 * time.Hour).Format("15:04")}
+	serveAs(h, "owner-1", "PUT", base+"/edit-license", closed)
+	if w := serveAs(h, "u-2", "POST", base+"/tracks", map[string]any{"title": "Late"}); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "only be edited between") {
+		t.Errorf("adding while closed: got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleBatchTracks_EditLicense(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		location map[string]any
+		wantCode int
+	}{
+		{name: "No Location", userID: "user-2", wantCode: http.StatusForbidden},
+		{name: "Away", userID: "user-2", location: map[string]any{"lat": 48.86, "lng": 2.35}, wantCode: http.StatusForbidden},
+		{name: "At The Venue", userID: "user-2", location: map[string]any{"lat": 48.8505, "lng": 2.35}, wantCode: http.StatusOK},
+		{name: "Owner", userID: "user-1", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &MockTx{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+					if strings.Contains(sql, "source = 'radio'") {
+						return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+					}
+					return &MockRow{}
+				},
+				ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+					return pgconn.CommandTag{}, nil
+				},
+			}
+			mockDB := batchOwnerDB(tx)
+			ownerRow := mockDB.QueryRowFunc
+			mockDB.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+				if strings.Contains(sql, "SELECT edit_license") {
+					return &MockRow{ScanFunc: func(dest ...any) error {
+						*dest[0].(*[]byte) = []byte(`{"lat":48.85,"lng":2.35,"radiusM":150}`)
+						return nil
+					}}
+				}
+				return ownerRow(ctx, sql, args...)
+			}
+
+			body := map[string]any{"operations": []map[string]any{{
+				"op":    "add",
+				"track": map[string]any{"title": "Song"},
+			}}}
+			for k, v := range tt.location {
+				body[k] = v
+			}
+			w := serveAs(NewServer(mockDB, nil).Router(), tt.userID, "POST", "/playlists/pl-1/tracks:batch", body)
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected %d, got %d. Body: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package playlist
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultFeedLimit = 50
+	maxFeedLimit     = 200
+)
+
+// handleFollowPlaylist makes the current user follow a playlist they can
+// see. Following twice is a no-op.
+// POST /playlists/{id}/follow
+func (s *Server) handleFollowPlaylist(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "follow playlist access")
+		return
+	}
+
+	changed, err := s.store.SetFollow(ctx, playlistID, userID, true)
+	if err != nil {
+		log.Printf("playlist-service: follow playlist: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	s.respondFollowState(w, r, playlistID, userID, true, changed, "playlist.followed")
+}
+
+// handleUnfollowPlaylist stops following a playlist. It works even if the
+// playlist is no longer visible to the user.
+// DELETE /playlists/{id}/follow
+func (s *Server) handleUnfollowPlaylist(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	changed, err := s.store.SetFollow(ctx, playlistID, userID, false)
+	if err != nil {
+		log.Printf("playlist-service: unfollow playlist: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	s.respondFollowState(w, r, playlistID, userID, false, changed, "playlist.unfollowed")
+}
+
+// respondFollowState answers a follow/unfollow with the new follower count
+// and, if the state changed, publishes eventType so the follower's clients
+// start (or stop) listening to the playlist.
+func (s *Server) respondFollowState(w http.ResponseWriter, r *http.Request, playlistID, userID string, following, changed bool, eventType string) {
+	ctx := r.Context()
+
+	count, err := s.store.FollowerCount(ctx, playlistID)
+	if err != nil {
+		log.Printf("playlist-service: follower count: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if changed {
+		s.publishEvent(ctx, map[string]any{
+			"type": eventType,
+			"payload": map[string]any{
+				"playlistId":    playlistID,
+				"userId":        userID,
+				"followerCount": count,
+			},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"playlistId":    playlistID,
+		"following":     following,
+		"followerCount": count,
+	})
+}
+
+// handleListFollowedPlaylists lists the playlists the current user follows,
+// most recently followed first.
+// GET /users/me/followed-playlists
+func (s *Server) handleListFollowedPlaylists(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+
+	playlists, err := s.store.ListFollowedPlaylists(ctx, userID)
+	if err != nil {
+		log.Printf("playlist-service: list followed playlists: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, playlists)
+}
+
+// handleFeed lists recent changes across the playlists the current user
+// follows, newest first: tracks added by users (radio tracks are left out)
+// and tracks that started playing. Pass the "at" of the last item as
+// ?before= to get the next page.
+// GET /users/me/feed
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+
+	limit := defaultFeedLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxFeedLimit {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 200")
+			return
+		}
+		limit = n
+	}
+	before := time.Now()
+	if v := r.URL.Query().Get("before"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "before must be an RFC 3339 time")
+			return
+		}
+		before = t
+	}
+
+	items, err := s.store.Feed(ctx, userID, before, limit)
+	if err != nil {
+		log.Printf("playlist-service: feed: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// trackAddedItem is the feed item for a track a user added.
+func trackAddedItem(tr Track) FeedItem {
+	return FeedItem{
+		Type:       "track.added",
+		PlaylistID: tr.PlaylistID,
+		TrackID:    tr.ID,
+		Title:      tr.Title,
+		Artist:     tr.Artist,
+		At:         tr.CreatedAt,
+	}
+}
+
+// notifyFollowers publishes a "feed.item" event addressed to the followers
+// who can see the playlist. Clients pick it up when their user is in
+// followerIds, so following a playlist subscribes to its activity without
+// opening it.
+func (s *Server) notifyFollowers(ctx context.Context, item FeedItem) {
+	if s.rdb == nil {
+		return
+	}
+
+	name, followerIDs, err := s.store.Followers(ctx, item.PlaylistID)
+	if err != nil {
+		log.Printf("playlist-service: notify followers: %v", err)
+		return
+	}
+	if len(followerIDs) == 0 {
+		return
+	}
+	item.PlaylistName = name
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "feed.item",
+		"payload": map[string]any{
+			"playlistId":  item.PlaylistID,
+			"followerIds": followerIDs,
+			"item":        item,
+		},
+	})
+}
@@ -0,0 +1,210 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestHandleFollowPlaylist(t *testing.T) {
+	tests := []struct {
+		name      string
+		isPublic  bool
+		wantCode  int
+		wantCount int
+	}{
+		{name: "Public", isPublic: true, wantCode: http.StatusOK, wantCount: 4},
+		{name: "Private", isPublic: false, wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inserted := false
+			mockDB := &MockDB{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+					switch {
+					case strings.Contains(sql, "SELECT owner_id"):
+						return &MockRow{ScanFunc: func(dest ...any) error {
+							*dest[0].(*string) = "owner-1"
+							*dest[1].(*bool) = tt.isPublic
+							*dest[2].(*string) = editModeEveryone
+							return nil
+						}}
+					case strings.Contains(sql, "FROM playlist_members"):
+						return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+					case strings.Contains(sql, "COUNT(*)::int FROM playlist_follows"):
+						return &MockRow{ScanFunc: func(dest ...any) error {
+							*dest[0].(*int) = 4
+							return nil
+						}}
+					}
+					return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected query: " + sql) }}
+				},
+				ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+					if strings.Contains(sql, "INSERT INTO playlist_follows") && args[1] == "u-2" {
+						inserted = true
+					}
+					return pgconn.NewCommandTag("INSERT 0 1"), nil
+				},
+			}
+			srv := NewServer(mockDB, nil)
+
+			req := httptest.NewRequest("POST", "/playlists/pl-1/follow", nil)
+			req.Header.Set("X-User-Id", "u-2")
+			w := httptest.NewRecorder()
+			srv.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d. Body: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if inserted != (tt.wantCode == http.StatusOK) {
+				t.Errorf("inserted = %v", inserted)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Following     bool `json:"following"`
+				FollowerCount int  `json:"followerCount"`
+			}
+			_ = json.NewDecoder(w.Body).Decode(&resp)
+			if !resp.Following || resp.FollowerCount != tt.wantCount {
+				t.Errorf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestHandleUnfollowPlaylist(t *testing.T) {
+	deleted := false
+	mockDB := &MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*int) = 0
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "DELETE FROM playlist_follows") && args[0] == "pl-1" && args[1] == "u-2" {
+				deleted = true
+			}
+			return pgconn.NewCommandTag("DELETE 1"), nil
+		},
+	}
+	srv := NewServer(mockDB, nil)
+
+	req := httptest.NewRequest("DELETE", "/playlists/pl-1/follow", nil)
+	req.Header.Set("X-User-Id", "u-2")
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if !deleted {
+		t.Error("expected the follow to be deleted")
+	}
+	if !strings.Contains(w.Body.String(), `"following":false`) {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+}
+
+func TestHandleListFollowedPlaylists(t *testing.T) {
+	mockDB := &MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if !strings.Contains(sql, "FROM playlist_follows f") || args[0] != "u-2" {
+				return nil, errors.New("unexpected query: " + sql)
+			}
+			return &MockRows{Data: [][]any{
+				{"pl-1", "owner-1", "Friday", "", true, editModeEveryone, time.Now(), 12, 3},
+			}, Idx: -1}, nil
+		},
+	}
+	srv := NewServer(mockDB, nil)
+
+	req := httptest.NewRequest("GET", "/users/me/followed-playlists", nil)
+	req.Header.Set("X-User-Id", "u-2")
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var playlists []Playlist
+	_ = json.NewDecoder(w.Body).Decode(&playlists)
+	if len(playlists) != 1 || playlists[0].TrackCount != 12 || playlists[0].FollowerCount != 3 {
+		t.Errorf("unexpected playlists: %+v", playlists)
+	}
+}
+
+func TestHandleFeed(t *testing.T) {
+	before := time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantLimit any
+		wantAt    time.Time
+	}{
+		{name: "Page", query: "?limit=2&before=" + before.Format(time.RFC3339), wantCode: http.StatusOK, wantLimit: 2, wantAt: before},
+		{name: "Bad Limit", query: "?limit=0", wantCode: http.StatusBadRequest},
+		{name: "Bad Before", query: "?before=yesterday", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotArgs []any
+			mockDB := &MockDB{
+				QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+					if !strings.Contains(sql, "t.source = 'user'") || !strings.Contains(sql, "FROM play_history") {
+						return nil, errors.New("unexpected query: " + sql)
+					}
+					gotArgs = args
+					return &MockRows{Data: [][]any{
+						{"playback.started", "pl-1", "Friday", "tr-2", "Song B", "Artist", before.Add(-time.Minute)},
+						{"track.added", "pl-1", "Friday", "tr-2", "Song B", "Artist", before.Add(-time.Hour)},
+					}, Idx: -1}, nil
+				},
+			}
+			srv := NewServer(mockDB, nil)
+
+			req := httptest.NewRequest("GET", "/users/me/feed"+tt.query, nil)
+			req.Header.Set("X-User-Id", "u-2")
+			w := httptest.NewRecorder()
+			srv.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d. Body: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if gotArgs[0] != "u-2" || !gotArgs[1].(time.Time).Equal(tt.wantAt) || gotArgs[2] != tt.wantLimit {
+				t.Errorf("unexpected args: %v", gotArgs)
+			}
+			var items []FeedItem
+			_ = json.NewDecoder(w.Body).Decode(&items)
+			if len(items) != 2 || items[0].Type != "playback.started" || items[1].PlaylistName != "Friday" {
+				t.Errorf("unexpected items: %+v", items)
+			}
+		})
+	}
+}
+
+func TestTrackAddedItem(t *testing.T) {
+	now := time.Now()
+	item := trackAddedItem(Track{ID: "tr-1", PlaylistID: "pl-1", Title: "Song", Artist: "Band", CreatedAt: now})
+	want := FeedItem{Type: "track.added", PlaylistID: "pl-1", TrackID: "tr-1", Title: "Song", Artist: "Band", At: now}
+	if item != want {
+		t.Errorf("trackAddedItem() = %+v, want %+v", item, want)
+	}
+}
@@ -1,16 +1,25 @@
 package playlist
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
 )
 
+const (
+	defaultInviteTTL = 7 * 24 * time.Hour
+	maxInviteTTL     = 30 * 24 * time.Hour
+	// inviteCleanupInterval is how often expired invitations are deleted.
+	inviteCleanupInterval = 10 * time.Minute
+)
+
+// handleListInvites lists the members of a playlist.
 func (s *Server) handleListInvites(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userID := r.Header.Get("X-User-Id")
@@ -25,7 +34,7 @@ func (s *Server) handleListInvites(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ownerID, isPublic, _, err := s.getPlaylistAccessInfo(ctx, playlistID)
-	if errors.Is(err, pgx.ErrNoRows) {
+	if errors.Is(err, ErrNotFound) {
 		writeError(w, http.StatusNotFound, "playlist not found")
 		return
 	}
@@ -35,7 +44,7 @@ func (s *Server) handleListInvites(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Access Rule: Owner OR Public Playlist OR User is Invited
+	// Access Rule: Owner OR Public Playlist OR User is Invited OR Friend of a friends-only playlist's owner
 	// For public playlists, anyone can see the participant list (requirements: "list of participants... on which one can click").
 	allowed := false
 	if userID == ownerID {
@@ -52,6 +61,9 @@ func (s *Server) handleListInvites(w http.ResponseWriter, r *http.Request) {
 		}
 		if isMember {
 			allowed = true
+		} else if allowed, err = s.viewableAsFriend(ctx, playlistID, userID); err != nil {
+			writeHTTPError(w, err, "list invites friends check")
+			return
 		}
 	}
 
@@ -60,38 +72,19 @@ func (s *Server) handleListInvites(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := s.db.Query(ctx, `
-		SELECT user_id, created_at
-		FROM playlist_members
-		WHERE playlist_id = $1
-		ORDER BY created_at ASC
-	`, playlistID)
+	invites, err := s.store.ListMembers(ctx, playlistID)
 	if err != nil {
 		log.Printf("playlist-service: list invites query: %v", err)
 		writeError(w, http.StatusInternalServerError, "database error")
 		return
 	}
-	defer rows.Close()
-
-	invites := []PlaylistInvite{}
-	for rows.Next() {
-		var inv PlaylistInvite
-		if err := rows.Scan(&inv.UserID, &inv.CreatedAt); err != nil {
-			log.Printf("playlist-service: list invites scan: %v", err)
-			writeError(w, http.StatusInternalServerError, "database error")
-			return
-		}
-		invites = append(invites, inv)
-	}
-	if err := rows.Err(); err != nil {
-		log.Printf("playlist-service: list invites rows: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
 
 	writeJSON(w, http.StatusOK, invites)
 }
 
+// handleAddInvite invites a user to a playlist. The owner's invitations stay
+// pending until the invitee accepts them; users joining a public playlist, or
+// a friends-only one of a friend, themselves become members right away.
 func (s *Server) handleAddInvite(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userID := r.Header.Get("X-User-Id")
@@ -106,7 +99,9 @@ func (s *Server) handleAddInvite(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var body struct {
-		UserID string `json:"userId"`
+		UserID         string  `json:"userId"`
+		Role           *string `json:"role"`           // optional, default "editor"
+		ExpiresInHours *int    `json:"expiresInHours"` // optional, default 7 days
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid JSON body")
@@ -118,8 +113,25 @@ func (s *Server) handleAddInvite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	role := memberRoleEditor
+	if body.Role != nil {
+		role = strings.ToLower(strings.TrimSpace(*body.Role))
+		if role != memberRoleEditor && role != memberRoleViewer && role != memberRoleCoOwner {
+			writeError(w, http.StatusBadRequest, `invalid role (must be "editor", "viewer" or "co-owner")`)
+			return
+		}
+	}
+	ttl := defaultInviteTTL
+	if body.ExpiresInHours != nil {
+		ttl = time.Duration(*body.ExpiresInHours) * time.Hour
+		if ttl <= 0 || ttl > maxInviteTTL {
+			writeError(w, http.StatusBadRequest, "expiresInHours must be between 1 and 720")
+			return
+		}
+	}
+
 	ownerID, isPublic, _, err := s.getPlaylistAccessInfo(ctx, playlistID)
-	if errors.Is(err, pgx.ErrNoRows) {
+	if errors.Is(err, ErrNotFound) {
 		writeError(w, http.StatusNotFound, "playlist not found")
 		return
 	}
@@ -130,41 +142,91 @@ func (s *Server) handleAddInvite(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Permission logic:
-	// 1. Owner can invite anyone.
-	// 2. Anyone can invite THEMSELVES (join) if the playlist is Public.
-	allowed := false
-	if userID == ownerID {
-		allowed = true
-	} else if isPublic && body.UserID == userID {
-		allowed = true
-	}
+	// 1. Owner can invite anyone; the invitee has to accept.
+	// 2. Anyone can invite THEMSELVES (join) if the playlist is Public,
+	//    and the owner's friends if it is friends-only.
+	if userID != ownerID {
+		if body.UserID != userID {
+			writeError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		if !isPublic {
+			friend, err := s.viewableAsFriend(ctx, playlistID, userID)
+			if err != nil {
+				writeHTTPError(w, err, "add invite friends check")
+				return
+			}
+			if !friend {
+				writeError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+		}
+		if err := s.checkRestrictions(ctx, playlistID, userID, false, ""); err != nil {
+			writeHTTPError(w, err, "add invite join restrictions")
+			return
+		}
 
-	if !allowed {
-		writeError(w, http.StatusForbidden, "forbidden")
+		if err := s.store.AddMember(ctx, playlistID, userID, memberRoleEditor); err != nil {
+			log.Printf("playlist-service: add invite join: %v", err)
+			writeError(w, http.StatusInternalServerError, "database error")
+			return
+		}
+
+		s.publishEvent(ctx, map[string]any{
+			"type": "playlist.member_joined",
+			"payload": map[string]any{
+				"playlistId": playlistID,
+				"userId":     userID,
+				"role":       memberRoleEditor,
+			},
+		})
+
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	_, err = s.db.Exec(ctx, `
-		INSERT INTO playlist_members (playlist_id, user_id)
-		VALUES ($1, $2)
-		ON CONFLICT (playlist_id, user_id) DO NOTHING
-	`, playlistID, body.UserID)
+	if body.UserID == ownerID {
+		writeError(w, http.StatusBadRequest, "cannot invite the playlist owner")
+		return
+	}
+	isMember, err := s.userIsInvited(ctx, playlistID, body.UserID)
 	if err != nil {
+		log.Printf("playlist-service: add invite check member: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if isMember {
+		writeError(w, http.StatusConflict, "user is already a member")
+		return
+	}
+	if st, err := s.store.ModerationState(ctx, playlistID, body.UserID); err != nil {
+		writeHTTPError(w, err, "add invite ban check")
+		return
+	} else if st.Banned {
+		writeError(w, http.StatusConflict, "user is banned from this playlist")
+		return
+	}
+
+	// Re-inviting refreshes the pending invitation.
+	inv := PlaylistInvitation{PlaylistID: playlistID, UserID: body.UserID, InvitedBy: userID, Role: role}
+	if err := s.store.InviteMember(ctx, &inv, ttl); err != nil {
 		log.Printf("playlist-service: add invite insert: %v", err)
 		writeError(w, http.StatusInternalServerError, "database error")
 		return
 	}
 
+	// Notify the invitee.
 	event := map[string]any{
 		"type": "playlist.invited",
 		"payload": map[string]any{
 			"playlistId": playlistID,
 			"userId":     body.UserID,
+			"invitation": inv,
 		},
 	}
 	s.publishEvent(ctx, event)
 
-	w.WriteHeader(http.StatusNoContent)
+	writeJSON(w, http.StatusCreated, inv)
 }
 
 func (s *Server) handleDeleteInvite(w http.ResponseWriter, r *http.Request) {
@@ -182,7 +244,7 @@ func (s *Server) handleDeleteInvite(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ownerID, _, _, err := s.getPlaylistAccessInfo(ctx, playlistID)
-	if errors.Is(err, pgx.ErrNoRows) {
+	if errors.Is(err, ErrNotFound) {
 		writeError(w, http.StatusNotFound, "playlist not found")
 		return
 	}
@@ -196,10 +258,8 @@ func (s *Server) handleDeleteInvite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := s.db.Exec(ctx, `
-		DELETE FROM playlist_members
-		WHERE playlist_id = $1 AND user_id = $2
-	`, playlistID, targetUserID); err != nil {
+	// Removes the member, or revokes a pending invitation.
+	if err := s.store.RemoveMember(ctx, playlistID, targetUserID); err != nil {
 		log.Printf("playlist-service: delete invite: %v", err)
 		writeError(w, http.StatusInternalServerError, "database error")
 		return
@@ -216,3 +276,146 @@ func (s *Server) handleDeleteInvite(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// handleListPendingInvites lists the unanswered invitations of a playlist.
+// Only the owner can see them.
+// GET /playlists/{id}/invites/pending
+func (s *Server) handleListPendingInvites(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	ownerID, _, _, err := s.getPlaylistAccessInfo(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		log.Printf("playlist-service: list pending invites fetch playlist: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	if userID != ownerID {
+		writeError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	invites, err := s.store.ListInvitations(ctx, playlistID)
+	if err != nil {
+		log.Printf("playlist-service: list pending invites: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	writeJSON(w, http.StatusOK, invites)
+}
+
+// handleListMyInvites lists the pending invitations of the current user.
+// GET /users/me/playlist-invites
+func (s *Server) handleListMyInvites(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+
+	invites, err := s.store.ListUserInvitations(ctx, userID)
+	if err != nil {
+		log.Printf("playlist-service: list my invites: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	writeJSON(w, http.StatusOK, invites)
+}
+
+// handleAcceptInvite makes the current user a member of the playlist with
+// the role of their pending invitation.
+// POST /playlists/{id}/invites/accept
+func (s *Server) handleAcceptInvite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	member, err := s.store.AcceptInvitation(ctx, playlistID, userID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "invitation not found or expired")
+		return
+	}
+	if err != nil {
+		log.Printf("playlist-service: accept invite: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.member_joined",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"userId":     userID,
+			"role":       member.Role,
+		},
+	})
+
+	writeJSON(w, http.StatusOK, member)
+}
+
+// handleDeclineInvite drops the current user's pending invitation.
+// POST /playlists/{id}/invites/decline
+func (s *Server) handleDeclineInvite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	err := s.store.DeclineInvitation(ctx, playlistID, userID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "invitation not found or expired")
+		return
+	}
+	if err != nil {
+		log.Printf("playlist-service: decline invite: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.invite_declined",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"userId":     userID,
+		},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// StartInviteCleanup deletes expired invitations periodically until ctx is
+// cancelled. Expired invitations are already ignored by every query; this
+// only keeps the table small.
+func (s *Server) StartInviteCleanup(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(inviteCleanupInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.store.DeleteExpiredInvitations(ctx); err != nil {
+				log.Printf("playlist-service: invite cleanup: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -29,36 +29,324 @@ func TestHandleAddInvite_Success(t *testing.T) {
 	playlistID := "pl-001"
 	inviteeID := "user-456"
 
-	// 1. getPlaylistAccessInfo
+	expires := time.Now().Add(defaultInviteTTL)
+	var inviteArgs []any
 	mockDB.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
-		return &MockRow{
-			ScanFunc: func(dest ...any) error {
+		if row, ok := moderationStateRow(sql); ok {
+			return row
+		}
+		switch {
+		// 1. getPlaylistAccessInfo
+		case strings.Contains(sql, "SELECT owner_id"):
+			return &MockRow{ScanFunc: func(dest ...any) error {
 				// owner_id, is_public, edit_mode
 				*dest[0].(*string) = userID
 				*dest[1].(*bool) = false
 				*dest[2].(*string) = "invited"
 				return nil
-			},
+			}}
+		// 2. Invitee is not a member yet
+		case strings.Contains(sql, "FROM playlist_members"):
+			return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		// 3. Pending invitation, never a membership
+		case strings.Contains(sql, "INSERT INTO playlist_invitations"):
+			inviteArgs = args
+			return &MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*string) = userID
+				*dest[1].(*string) = args[3].(string)
+				*dest[2].(*time.Time) = time.Now()
+				*dest[3].(*time.Time) = expires
+				*dest[4].(*string) = "Road trip"
+				return nil
+			}}
 		}
+		return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected query: " + sql) }}
 	}
-
-	// 2. Insert Invite
 	mockDB.ExecFunc = func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
-		if strings.Contains(sql, "INSERT INTO playlist_members") {
-			return pgconn.CommandTag{}, nil
-		}
-		return pgconn.CommandTag{}, errors.New("unexpected exec")
+		return pgconn.CommandTag{}, errors.New("unexpected exec: " + sql)
 	}
 
-	body, _ := json.Marshal(map[string]string{"userId": inviteeID})
+	body, _ := json.Marshal(map[string]string{"userId": inviteeID, "role": "viewer"})
 	req := httptest.NewRequest("POST", fmt.Sprintf("/playlists/%s/invites", playlistID), bytes.NewReader(body))
 	req.Header.Set("X-User-Id", userID)
 
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 Created, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if inviteArgs[1] != inviteeID || inviteArgs[2] != userID || inviteArgs[3] != memberRoleViewer {
+		t.Errorf("unexpected invitation args: %v", inviteArgs)
+	}
+	var inv PlaylistInvitation
+	_ = json.NewDecoder(w.Body).Decode(&inv)
+	if inv.UserID != inviteeID || inv.Role != memberRoleViewer || inv.PlaylistName != "Road trip" {
+		t.Errorf("unexpected invitation: %+v", inv)
+	}
+}
+
+func TestHandleAddInvite_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		isPublic bool
+		member   bool
+		body     map[string]any
+		wantCode int
+	}{
+		{name: "Invalid role", userID: "owner-1", body: map[string]any{"userId": "u-2", "role": "admin"}, wantCode: http.StatusBadRequest},
+		{name: "Expiry too long", userID: "owner-1", body: map[string]any{"userId": "u-2", "expiresInHours": 10000}, wantCode: http.StatusBadRequest},
+		{name: "Owner", userID: "owner-1", body: map[string]any{"userId": "owner-1"}, wantCode: http.StatusBadRequest},
+		{name: "Already member", userID: "owner-1", member: true, body: map[string]any{"userId": "u-2"}, wantCode: http.StatusConflict},
+		{name: "Non-owner invites someone else", userID: "u-3", isPublic: true, body: map[string]any{"userId": "u-2"}, wantCode: http.StatusForbidden},
+		{name: "Self-join private playlist", userID: "u-2", body: map[string]any{"userId": "u-2"}, wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+					if strings.Contains(sql, "FROM playlist_members") {
+						if tt.member {
+							return &MockRow{ScanFunc: func(dest ...any) error {
+								*dest[0].(*string) = memberRoleEditor
+								return nil
+							}}
+						}
+						return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+					}
+					if strings.Contains(sql, "SELECT owner_id") {
+						return &MockRow{ScanFunc: func(dest ...any) error {
+							*dest[0].(*string) = "owner-1"
+							*dest[1].(*bool) = tt.isPublic
+							*dest[2].(*string) = editModeInvited
+							return nil
+						}}
+					}
+					return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected query: " + sql) }}
+				},
+			}
+			srv := NewServer(mockDB, nil)
+
+			body, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest("POST", "/playlists/pl-1/invites", bytes.NewReader(body))
+			req.Header.Set("X-User-Id", tt.userID)
+			w := httptest.NewRecorder()
+			srv.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected %d, got %d. Body: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleAddInvite_SelfJoinPublic(t *testing.T) {
+	joined := false
+	mockDB := &MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if row, ok := moderationStateRow(sql); ok {
+				return row
+			}
+			return &MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*string) = "owner-1"
+				*dest[1].(*bool) = true
+				*dest[2].(*string) = editModeEveryone
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "INSERT INTO playlist_members") && args[1] == "u-2" {
+				joined = true
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	srv := NewServer(mockDB, nil)
+
+	body, _ := json.Marshal(map[string]string{"userId": "u-2"})
+	req := httptest.NewRequest("POST", "/playlists/pl-1/invites", bytes.NewReader(body))
+	req.Header.Set("X-User-Id", "u-2")
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
 	if w.Code != http.StatusNoContent {
-		t.Errorf("Expected 204 No Content, got %d", w.Code)
+		t.Fatalf("Expected 204 No Content, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if !joined {
+		t.Error("expected the user to join right away")
+	}
+}
+
+func TestHandleAcceptInvite(t *testing.T) {
+	var memberArgs []any
+	committed := false
+	joined := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mockDB := &MockDB{
+		BeginTxFunc: func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+			return &MockTx{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+					switch {
+					case strings.Contains(sql, "DELETE FROM playlist_invitations") && strings.Contains(sql, "expires_at > now()"):
+						return &MockRow{ScanFunc: func(dest ...any) error {
+							*dest[0].(*string) = memberRoleViewer
+							return nil
+						}}
+					case strings.Contains(sql, "INSERT INTO playlist_members"):
+						memberArgs = args
+						return &MockRow{ScanFunc: func(dest ...any) error {
+							*dest[0].(*string) = args[1].(string)
+							*dest[1].(*string) = args[2].(string)
+							*dest[2].(*time.Time) = joined
+							return nil
+						}}
+					}
+					return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected query: " + sql) }}
+				},
+				CommitFunc: func(ctx context.Context) error {
+					committed = true
+					return nil
+				},
+			}, nil
+		},
+	}
+	srv := NewServer(mockDB, nil)
+
+	req := httptest.NewRequest("POST", "/playlists/pl-1/invites/accept", nil)
+	req.Header.Set("X-User-Id", "u-2")
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 OK, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if !committed || len(memberArgs) != 3 || memberArgs[1] != "u-2" || memberArgs[2] != memberRoleViewer {
+		t.Errorf("expected membership with the invited role, got %v (committed=%v)", memberArgs, committed)
+	}
+	var member PlaylistInvite
+	json.NewDecoder(w.Body).Decode(&member)
+	if member.UserID != "u-2" || member.Role != memberRoleViewer || !member.CreatedAt.Equal(joined) {
+		t.Errorf("expected the stored membership, got %+v", member)
+	}
+}
+
+func TestHandleAcceptInvite_Expired(t *testing.T) {
+	mockDB := &MockDB{
+		BeginTxFunc: func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+			return &MockTx{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+					return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+				},
+				ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+					t.Errorf("unexpected exec: %s", sql)
+					return pgconn.CommandTag{}, nil
+				},
+			}, nil
+		},
+	}
+	srv := NewServer(mockDB, nil)
+
+	req := httptest.NewRequest("POST", "/playlists/pl-1/invites/accept", nil)
+	req.Header.Set("X-User-Id", "u-2")
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+}
+
+func TestHandleDeclineInvite(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected string
+		wantCode int
+	}{
+		{name: "Pending", affected: "DELETE 1", wantCode: http.StatusNoContent},
+		{name: "None", affected: "DELETE 0", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+					if !strings.Contains(sql, "DELETE FROM playlist_invitations") || args[1] != "u-2" {
+						t.Errorf("unexpected exec: %s %v", sql, args)
+					}
+					return pgconn.NewCommandTag(tt.affected), nil
+				},
+			}
+			srv := NewServer(mockDB, nil)
+
+			req := httptest.NewRequest("POST", "/playlists/pl-1/invites/decline", nil)
+			req.Header.Set("X-User-Id", "u-2")
+			w := httptest.NewRecorder()
+			srv.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleListMyInvites(t *testing.T) {
+	now := time.Now()
+	mockDB := &MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if strings.Contains(sql, "FROM playlist_invitations") && strings.Contains(sql, "i.user_id = $1") && args[0] == "u-2" {
+				return &MockRows{Data: [][]any{
+					{"pl-1", "Road trip", "u-2", "owner-1", memberRoleEditor, now, now.Add(time.Hour)},
+				}, Idx: -1}, nil
+			}
+			return nil, errors.New("unexpected query: " + sql)
+		},
+	}
+	srv := NewServer(mockDB, nil)
+
+	req := httptest.NewRequest("GET", "/users/me/playlist-invites", nil)
+	req.Header.Set("X-User-Id", "u-2")
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 OK, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var invites []PlaylistInvitation
+	_ = json.NewDecoder(w.Body).Decode(&invites)
+	if len(invites) != 1 || invites[0].PlaylistName != "Road trip" || invites[0].InvitedBy != "owner-1" {
+		t.Errorf("unexpected invites: %+v", invites)
+	}
+}
+
+func TestCheckEditAccess_ViewerRole(t *testing.T) {
+	mockDB := &MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if row, ok := smartRulesRow(sql); ok {
+				return row
+			}
+			if strings.Contains(sql, "FROM playlist_members") {
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = memberRoleViewer
+					return nil
+				}}
+			}
+			return &MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*string) = "owner-1"
+				*dest[1].(*bool) = false
+				*dest[2].(*string) = editModeInvited
+				return nil
+			}}
+		},
+	}
+	srv := NewServer(mockDB, nil)
+
+	if err := srv.checkViewAccess(context.Background(), "pl-1", "u-2"); err != nil {
+		t.Errorf("viewer should see the playlist, got %v", err)
+	}
+	var he *httpError
+	if _, err := srv.checkEditAccess(context.Background(), "pl-1", "u-2", lockQueue, clientLocation{}); !errors.As(err, &he) || he.status != http.StatusForbidden {
+		t.Errorf("viewer should not edit, got %v", err)
 	}
 }
 
@@ -88,8 +376,8 @@ func TestHandleListInvites_Success(t *testing.T) {
 		if strings.Contains(sql, "FROM playlist_members") {
 			return &MockRows{
 				Data: [][]any{
-					{"user-1", time.Now()},
-					{"user-2", time.Now()},
+					{"user-1", memberRoleEditor, time.Now()},
+					{"user-2", memberRoleViewer, time.Now()},
 				},
 				Idx: -1,
 			}, nil
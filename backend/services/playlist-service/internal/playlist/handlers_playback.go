@@ -8,110 +8,99 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
 )
 
-// handleNextTrack skips to the next track in the queue.
-// POST /playlists/{id}/next
+// errPlaybackChanged is returned by advancePlayback when the track it was
+// asked to finish is no longer due: it was skipped, is still playing, or
+// another instance is advancing the playlist right now.
+var errPlaybackChanged = errors.New("playback changed")
+
 // NextTrack advances the playlist to the next track.
-// This is used by the HTTP handler and the background ticker.
-func (s *Server) NextTrack(ctx context.Context, playlistID string) (map[string]any, error) {
-	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
-	if err != nil {
-		log.Printf("playlist-service: next track begin tx: %v", err)
-		return nil, err
-	}
-	defer tx.Rollback(ctx)
+// This is used by the HTTP handler; reason is recorded in the play history
+// of the track that stops (playEndSkipped or playEndFinished).
+func (s *Server) NextTrack(ctx context.Context, playlistID, reason string) (map[string]any, error) {
+	return s.advancePlayback(ctx, playlistID, reason, "")
+}
 
-	// 1. Get current state
-	var currentTrackID *string
-	err = tx.QueryRow(ctx, `SELECT current_track_id FROM playlists WHERE id = $1 FOR UPDATE`, playlistID).Scan(&currentTrackID)
+// advancePlayback implements NextTrack. When expectTrackID is set (scheduler
+// path) the playlist only advances if expectTrackID is still playing and has
+// reached its end; PostgresStore claims the playlist row with SKIP LOCKED so
+// concurrent instances never skip twice.
+func (s *Server) advancePlayback(ctx context.Context, playlistID, reason, expectTrackID string) (map[string]any, error) {
+	now := time.Now()
+	state, err := s.store.AdvancePlayback(ctx, playlistID, reason, expectTrackID, now)
 	if err != nil {
-		log.Printf("playlist-service: next track get current: %v", err)
-		return nil, err
-	}
-
-	// 2. Update old track to 'played'
-	if currentTrackID != nil {
-		_, err = tx.Exec(ctx, `UPDATE tracks SET status = 'played' WHERE id = $1`, *currentTrackID)
-		if err != nil {
-			log.Printf("playlist-service: next track update old: %v", err)
-			return nil, err
+		if !errors.Is(err, errPlaybackChanged) {
+			log.Printf("playlist-service: next track: %v", err)
 		}
+		return nil, err
 	}
 
-	// 3. Find next 'queued' track
-	var nextTrackID string
-	var nextTrackDurationMs int
-	err = tx.QueryRow(ctx, `
-		SELECT id, duration_ms
-		FROM tracks
-		WHERE playlist_id = $1 AND status = 'queued'
-		ORDER BY position ASC
-		LIMIT 1
-		FOR UPDATE
-	`, playlistID).Scan(&nextTrackID, &nextTrackDurationMs)
-
 	updatedState := map[string]any{
 		"playlistId":       playlistID,
 		"currentTrackId":   nil,
 		"playingStartedAt": nil,
 		"status":           "stopped",
 	}
-
-	if errors.Is(err, pgx.ErrNoRows) {
-		// End of playlist
-		_, err = tx.Exec(ctx, `
-			UPDATE playlists 
-			SET current_track_id = NULL, playing_started_at = NULL 
-			WHERE id = $1
-		`, playlistID)
-		if err != nil {
-			log.Printf("playlist-service: next track clear playlist: %v", err)
-			return nil, err
-		}
-	} else if err != nil {
-		log.Printf("playlist-service: next track find next: %v", err)
-		return nil, err
-	} else {
-		// Found next track
-		now := time.Now()
-		_, err = tx.Exec(ctx, `
-			UPDATE tracks SET status = 'playing' WHERE id = $1
-		`, nextTrackID)
-		if err != nil {
-			log.Printf("playlist-service: next track set playing: %v", err)
-			return nil, err
-		}
-
-		_, err = tx.Exec(ctx, `
-			UPDATE playlists 
-			SET current_track_id = $2, playing_started_at = $3 
-			WHERE id = $1
-		`, playlistID, nextTrackID, now)
-		if err != nil {
-			log.Printf("playlist-service: next track update playlist: %v", err)
-			return nil, err
-		}
-
-		updatedState["currentTrackId"] = nextTrackID
-		updatedState["playingStartedAt"] = now
+	next := state.Current
+	if next != nil {
+		updatedState["currentTrackId"] = next.ID
+		updatedState["playingStartedAt"] = state.StartedAt
 		updatedState["status"] = "playing"
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		log.Printf("playlist-service: next track commit: %v", err)
-		return nil, err
+	if next != nil && next.DurationMs > 0 {
+		s.scheduler.arm(playlistID, next.ID, state.StartedAt.Add(time.Duration(next.DurationMs)*time.Millisecond))
+	} else {
+		s.scheduler.disarm(playlistID)
 	}
+	// Only the instance that claimed the advance tops up the radio queue,
+	// so the next advance finds a track; it calls the music provider in the
+	// background.
+	s.triggerRadioFill(playlistID)
 
 	s.publishEvent(ctx, map[string]any{
 		"type":    "player.state_changed",
 		"payload": updatedState,
 	})
+	if next != nil {
+		s.notifyFollowers(ctx, FeedItem{
+			Type:       "playback.started",
+			PlaylistID: playlistID,
+			TrackID:    next.ID,
+			Title:      next.Title,
+			Artist:     next.Artist,
+			At:         state.StartedAt,
+		})
+	}
 
 	return updatedState, nil
 }
 
+// checkPlaybackControl allows the users who may control the player of a
+// playlist: the owner, the users who may edit it and the users it was
+// delegated to (see delegations.go), unless they are banned. Denials are
+// returned as *httpError.
+func (s *Server) checkPlaybackControl(ctx context.Context, playlistID, userID string) error {
+	_, err := s.checkEditAccess(ctx, playlistID, userID, "", clientLocation{})
+	if err != errEditDenied {
+		return err
+	}
+
+	// A ban also revokes control delegated before it.
+	if err := s.checkRestrictions(ctx, playlistID, userID, false, ""); err != nil {
+		return err
+	}
+	delegated, err := s.store.HasDelegation(ctx, playlistID, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !delegated {
+		return errEditDenied
+	}
+	return nil
+}
+
 // handleNextTrack skips to the next track in the queue.
 // POST /playlists/{id}/next
 func (s *Server) handleNextTrack(w http.ResponseWriter, r *http.Request) {
@@ -129,49 +118,22 @@ func (s *Server) handleNextTrack(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 1. Check access (HTTP only)
-	ownerID, isPublic, editMode, err := s.getPlaylistAccessInfo(ctx, playlistID)
-	if errors.Is(err, pgx.ErrNoRows) {
-		writeError(w, http.StatusNotFound, "playlist not found")
-		return
-	}
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "database error")
+	if err := s.checkPlaybackControl(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "next track access")
 		return
 	}
 
-	invited := false
-	if userID != "" && userID != ownerID {
-		invited, err = s.userIsInvited(ctx, playlistID, userID)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "database error")
-			return
-		}
-	}
-
-	if !isPublic && userID != ownerID && !invited {
-		writeError(w, http.StatusForbidden, "forbidden")
+	// 2. Invoke reuseable logic
+	updatedState, err := s.NextTrack(ctx, playlistID, playEndSkipped)
+	switch {
+	case errors.Is(err, errPlaybackChanged):
+		writeError(w, http.StatusConflict, "playback is being changed, try again")
 		return
-	}
-
-	allowControl := false
-	if userID == ownerID {
-		allowControl = true
-	} else if editMode == editModeEveryone {
-		allowControl = true
-	} else if editMode == editModeInvited && invited {
-		allowControl = true
-	}
-
-	if !allowControl {
-		writeError(w, http.StatusForbidden, "forbidden")
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, "playlist not found")
 		return
-	}
-
-	// 2. Invoke reuseable logic
-	updatedState, err := s.NextTrack(ctx, playlistID)
-	if err != nil {
-		// Assuming logged inside NextTrack
-		writeError(w, http.StatusInternalServerError, "internal error")
+	case err != nil:
+		writeHTTPError(w, err, "next track")
 		return
 	}
 
@@ -130,7 +130,7 @@ func TestNextTrack_Logic_End(t *testing.T) {
 		}, nil
 	}
 
-	state, err := srv.NextTrack(context.Background(), playlistID)
+	state, err := srv.NextTrack(context.Background(), playlistID, playEndSkipped)
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -184,6 +184,9 @@ func TestHandleNextTrack_Errors(t *testing.T) {
 			userID:     "outsider",
 			mockSetup: func(m *MockDB) {
 				m.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+					if row, ok := moderationStateRow(sql); ok {
+						return row
+					}
 					if strings.Contains(sql, "FROM playlists") {
 						return &MockRow{ScanFunc: func(dest ...any) error {
 							*dest[0].(*string) = "owner"
@@ -192,12 +195,32 @@ func TestHandleNextTrack_Errors(t *testing.T) {
 							return nil
 						}}
 					}
+					if strings.Contains(sql, "FROM playlist_delegations") {
+						return &MockRow{ScanFunc: func(dest ...any) error {
+							*dest[0].(*bool) = false
+							return nil
+						}}
+					}
 					// check invite
 					return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
 				}
 			},
 			wantCode: http.StatusForbidden,
 		},
+		{
+			name:       "Trashed While Advancing",
+			playlistID: "pl-1",
+			userID:     "user-1",
+			mockSetup:  advanceFailsDB(pgx.ErrNoRows),
+			wantCode:   http.StatusNotFound,
+		},
+		{
+			name:       "Database Error",
+			playlistID: "pl-1",
+			userID:     "user-1",
+			mockSetup:  advanceFailsDB(errors.New("connection reset")),
+			wantCode:   http.StatusInternalServerError,
+		},
 	}
 
 	for _, tt := range tests {
@@ -241,3 +264,26 @@ func TestHandleNextTrack_Errors(t *testing.T) {
 		})
 	}
 }
+
+// advanceFailsDB lets user-1 control pl-1 and fails reading its current
+// track with err.
+func advanceFailsDB(err error) func(*MockDB) {
+	return func(m *MockDB) {
+		m.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if row, ok := moderationStateRow(sql); ok {
+				return row
+			}
+			return &MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*string) = "user-1"
+				*dest[1].(*bool) = true
+				*dest[2].(*string) = "everyone"
+				return nil
+			}}
+		}
+		m.BeginTxFunc = func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+			return &MockTx{QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+				return &MockRow{ScanFunc: func(dest ...any) error { return err }}
+			}}, nil
+		}
+	}
+}
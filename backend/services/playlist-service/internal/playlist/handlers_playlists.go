@@ -6,58 +6,50 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
 )
 
+// handleListPlaylists lists the playlists visible to the caller (public ones,
+// owned ones, the ones the caller is a member of and friends-only ones of
+// the caller's friends), newest first by default.
+// GET /playlists?filter=&editMode=&sort=&q=&limit=&cursor=
+// The cursor of the next page, if any, is returned in the X-Next-Cursor header.
 func (s *Server) handleListPlaylists(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userID := r.Header.Get("X-User-Id")
 
-	// Query: public playlists OR playlists I own OR playlists I'm invited to
-	rows, err := s.db.Query(ctx, `
-		SELECT p.id, p.owner_id, p.name, p.description, p.is_public, p.edit_mode, p.created_at
-		FROM playlists p
-		LEFT JOIN playlist_members pm ON p.id = pm.playlist_id AND pm.user_id = $1
-		WHERE p.is_public = TRUE
-		   OR ($1 <> '' AND p.owner_id = $1)
-		   OR ($1 <> '' AND pm.user_id IS NOT NULL)
-		ORDER BY p.created_at DESC
-		LIMIT 200
-	`, userID)
-	if err != nil {
-		log.Printf("playlist-service: list playlists: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
+	q, msg := parseListPlaylistsQuery(r.URL.Query())
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
 		return
 	}
-	defer rows.Close()
-
-	playlists := []Playlist{}
-	for rows.Next() {
-		var pl Playlist
-		if err := rows.Scan(
-			&pl.ID,
-			&pl.OwnerID,
-			&pl.Name,
-			&pl.Description,
-			&pl.IsPublic,
-			&pl.EditMode,
-			&pl.CreatedAt,
-		); err != nil {
-			log.Printf("playlist-service: list playlists scan: %v", err)
-			writeError(w, http.StatusInternalServerError, "database error")
-			return
-		}
-		playlists = append(playlists, pl)
+	if userID == "" && (q.Filter == listFilterOwned || q.Filter == listFilterMember) {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
 	}
 
-	if err := rows.Err(); err != nil {
-		log.Printf("playlist-service: list playlists rows: %v", err)
+	// Listing without friends' playlists beats failing when user-service is
+	// down.
+	friendIDs, err := s.friendIDs(ctx, userID)
+	if err != nil {
+		log.Printf("playlist-service: list playlists friends: %v", err)
+	}
+	q.FriendIDs = friendIDs
+
+	playlists, err := s.store.ListPlaylists(ctx, q, userID)
+	if err != nil {
+		log.Printf("playlist-service: list playlists: %v", err)
 		writeError(w, http.StatusInternalServerError, "database error")
 		return
 	}
 
+	if len(playlists) > q.Limit {
+		playlists = playlists[:q.Limit]
+		w.Header().Set("X-Next-Cursor", cursorFor(q.Sort, playlists[len(playlists)-1]).encode())
+	}
+
 	writeJSON(w, http.StatusOK, playlists)
 }
 
@@ -74,7 +66,19 @@ func (s *Server) handleCreatePlaylist(w http.ResponseWriter, r *http.Request) {
 		Name        string  `json:"name"`
 		Description string  `json:"description"`
 		IsPublic    *bool   `json:"isPublic"`
-		EditMode    *string `json:"editMode"` // optional, default "everyone"
+		Visibility  *string `json:"visibility"` // optional, overrides isPublic
+		EditMode    *string `json:"editMode"`   // optional, default "everyone"
+
+		DuplicatePolicy *string `json:"duplicatePolicy"` // optional, default "allow"
+		FuzzyDuplicates *bool   `json:"fuzzyDuplicates"`
+
+		RadioEnabled  *bool `json:"radioEnabled"`
+		RadioMinQueue *int  `json:"radioMinQueue"` // optional, default 3
+
+		SkipVotePercent   *int `json:"skipVotePercent"` // optional, default 50
+		SkipVoteThreshold *int `json:"skipVoteThreshold"`
+
+		Smart *SmartRules `json:"smart"` // optional, makes it a smart playlist
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid JSON body")
@@ -97,32 +101,85 @@ func (s *Server) handleCreatePlaylist(w http.ResponseWriter, r *http.Request) {
 	if body.IsPublic != nil {
 		isPublic = *body.IsPublic
 	}
+	var visibility string
+	if body.Visibility != nil {
+		visibility = strings.ToLower(strings.TrimSpace(*body.Visibility))
+		if !isValidVisibility(visibility) {
+			writeError(w, http.StatusBadRequest, invalidVisibilityMsg)
+			return
+		}
+	}
 
 	editMode := editModeEveryone
 	if body.EditMode != nil {
 		em := strings.ToLower(strings.TrimSpace(*body.EditMode))
-		if em != editModeEveryone && em != editModeInvited {
-			writeError(w, http.StatusBadRequest, `invalid editMode (must be "everyone" or "invited")`)
+		if !isValidEditMode(em) {
+			writeError(w, http.StatusBadRequest, invalidEditModeMsg)
 			return
 		}
 		editMode = em
 	}
 
-	var pl Playlist
-	err := s.db.QueryRow(ctx, `
-		INSERT INTO playlists (owner_id, name, description, is_public, edit_mode)
-		VALUES ($1,$2,$3,$4,$5)
-		RETURNING id, owner_id, name, description, is_public, edit_mode, created_at
-	`, ownerID, body.Name, body.Description, isPublic, editMode).Scan(
-		&pl.ID,
-		&pl.OwnerID,
-		&pl.Name,
-		&pl.Description,
-		&pl.IsPublic,
-		&pl.EditMode,
-		&pl.CreatedAt,
-	)
-	if err != nil {
+	duplicatePolicy := duplicatePolicyAllow
+	if body.DuplicatePolicy != nil {
+		dp := strings.ToLower(strings.TrimSpace(*body.DuplicatePolicy))
+		if !isValidDuplicatePolicy(dp) {
+			writeError(w, http.StatusBadRequest, `invalid duplicatePolicy (must be "allow", "reject", "merge_vote" or "allow_after_played")`)
+			return
+		}
+		duplicatePolicy = dp
+	}
+	fuzzyDuplicates := body.FuzzyDuplicates != nil && *body.FuzzyDuplicates
+
+	radioEnabled := body.RadioEnabled != nil && *body.RadioEnabled
+	radioMinQueue := defaultRadioMinQueue
+	if body.RadioMinQueue != nil {
+		if !isValidRadioMinQueue(*body.RadioMinQueue) {
+			writeError(w, http.StatusBadRequest, "radioMinQueue must be between 1 and 20")
+			return
+		}
+		radioMinQueue = *body.RadioMinQueue
+	}
+
+	skipVotePercent := defaultSkipVotePercent
+	if body.SkipVotePercent != nil {
+		if !isValidSkipVotePercent(*body.SkipVotePercent) {
+			writeError(w, http.StatusBadRequest, "skipVotePercent must be between 0 and 99")
+			return
+		}
+		skipVotePercent = *body.SkipVotePercent
+	}
+	skipVoteThreshold := 0
+	if body.SkipVoteThreshold != nil {
+		if !isValidSkipVoteThreshold(*body.SkipVoteThreshold) {
+			writeError(w, http.StatusBadRequest, "skipVoteThreshold must be between 0 and 1000")
+			return
+		}
+		skipVoteThreshold = *body.SkipVoteThreshold
+	}
+	if body.Smart != nil {
+		if msg := body.Smart.normalize(); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+	}
+
+	pl := Playlist{
+		OwnerID:         ownerID,
+		Name:            body.Name,
+		Description:     body.Description,
+		IsPublic:        isPublic,
+		Visibility:      visibility,
+		EditMode:        editMode,
+		DuplicatePolicy: duplicatePolicy,
+		FuzzyDuplicates: fuzzyDuplicates,
+		RadioEnabled:    radioEnabled,
+		RadioMinQueue:   radioMinQueue,
+
+		SkipVotePercent:   skipVotePercent,
+		SkipVoteThreshold: skipVoteThreshold,
+	}
+	if err := s.store.CreatePlaylist(ctx, &pl, body.Smart); err != nil {
 		log.Printf("playlist-service: create playlist: %v", err)
 		writeError(w, http.StatusInternalServerError, "database error")
 		return
@@ -136,6 +193,9 @@ func (s *Server) handleCreatePlaylist(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 	s.publishEvent(ctx, event)
+	if body.Smart != nil {
+		s.triggerSmartRefresh(pl.ID)
+	}
 
 	writeJSON(w, http.StatusCreated, pl)
 }
@@ -159,107 +219,122 @@ func (s *Server) handlePatchPlaylist(w http.ResponseWriter, r *http.Request) {
 		Name        *string `json:"name"`
 		Description *string `json:"description"`
 		IsPublic    *bool   `json:"isPublic"`
+		Visibility  *string `json:"visibility"`
 		EditMode    *string `json:"editMode"`
+
+		DuplicatePolicy *string `json:"duplicatePolicy"`
+		FuzzyDuplicates *bool   `json:"fuzzyDuplicates"`
+
+		RadioEnabled  *bool `json:"radioEnabled"`
+		RadioMinQueue *int  `json:"radioMinQueue"`
+
+		SkipVotePercent   *int `json:"skipVotePercent"`
+		SkipVoteThreshold *int `json:"skipVoteThreshold"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid JSON body")
 		return
 	}
 
-	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
-	if err != nil {
-		log.Printf("playlist-service: begin tx: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-	defer tx.Rollback(ctx)
-
-	var existing Playlist
-	err = tx.QueryRow(ctx, `
-		SELECT id, owner_id, name, description, is_public, edit_mode, created_at
-		FROM playlists
-		WHERE id = $1
-	`, playlistID).Scan(
-		&existing.ID,
-		&existing.OwnerID,
-		&existing.Name,
-		&existing.Description,
-		&existing.IsPublic,
-		&existing.EditMode,
-		&existing.CreatedAt,
-	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		writeError(w, http.StatusNotFound, "playlist not found")
-		return
-	}
-	if err != nil {
-		log.Printf("playlist-service: fetch playlist: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	if existing.OwnerID != userID {
-		writeError(w, http.StatusForbidden, "forbidden")
-		return
-	}
+	var radioTurnedOn bool
+	pl, err := s.store.UpdatePlaylist(ctx, playlistID, func(existing *Playlist) error {
+		if existing.OwnerID != userID {
+			return &httpError{status: http.StatusForbidden, msg: "forbidden"}
+		}
 
-	if body.Name != nil {
-		name := strings.TrimSpace(*body.Name)
-		if name == "" || len(name) > 200 {
-			writeError(w, http.StatusBadRequest, "name must be between 1 and 200 characters")
-			return
+		if body.Name != nil {
+			name := strings.TrimSpace(*body.Name)
+			if name == "" || len(name) > 200 {
+				return &httpError{status: http.StatusBadRequest, msg: "name must be between 1 and 200 characters"}
+			}
+			existing.Name = name
 		}
-		existing.Name = name
-	}
-	if body.Description != nil {
-		desc := strings.TrimSpace(*body.Description)
-		if len(desc) > 1000 {
-			writeError(w, http.StatusBadRequest, "description is too long")
-			return
+		if body.Description != nil {
+			desc := strings.TrimSpace(*body.Description)
+			if len(desc) > 1000 {
+				return &httpError{status: http.StatusBadRequest, msg: "description is too long"}
+			}
+			existing.Description = desc
 		}
-		existing.Description = desc
-	}
-	if body.IsPublic != nil {
-		existing.IsPublic = *body.IsPublic
-	}
-	if body.EditMode != nil {
-		em := strings.ToLower(strings.TrimSpace(*body.EditMode))
-		if em != editModeEveryone && em != editModeInvited {
-			writeError(w, http.StatusBadRequest, `invalid editMode (must be "everyone" or "invited")`)
-			return
+		if body.IsPublic != nil {
+			existing.IsPublic = *body.IsPublic
 		}
-		existing.EditMode = em
-	}
-
-	_, err = tx.Exec(ctx, `
-		UPDATE playlists
-		SET name = $2,
-			description = $3,
-			is_public = $4,
-			edit_mode = $5
-		WHERE id = $1
-	`, existing.ID, existing.Name, existing.Description, existing.IsPublic, existing.EditMode)
-	if err != nil {
-		log.Printf("playlist-service: update playlist: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
+		if body.Visibility != nil {
+			v := strings.ToLower(strings.TrimSpace(*body.Visibility))
+			if !isValidVisibility(v) {
+				return &httpError{status: http.StatusBadRequest, msg: invalidVisibilityMsg}
+			}
+			existing.Visibility = v
+		}
+		if body.EditMode != nil {
+			em := strings.ToLower(strings.TrimSpace(*body.EditMode))
+			if !isValidEditMode(em) {
+				return &httpError{status: http.StatusBadRequest, msg: invalidEditModeMsg}
+			}
+			existing.EditMode = em
+		}
+		if body.DuplicatePolicy != nil {
+			dp := strings.ToLower(strings.TrimSpace(*body.DuplicatePolicy))
+			if !isValidDuplicatePolicy(dp) {
+				return &httpError{status: http.StatusBadRequest, msg: `invalid duplicatePolicy (must be "allow", "reject", "merge_vote" or "allow_after_played")`}
+			}
+			existing.DuplicatePolicy = dp
+		}
+		if existing.DuplicatePolicy == "" {
+			existing.DuplicatePolicy = duplicatePolicyAllow
+		}
+		if body.FuzzyDuplicates != nil {
+			existing.FuzzyDuplicates = *body.FuzzyDuplicates
+		}
+		radioTurnedOn = body.RadioEnabled != nil && *body.RadioEnabled && !existing.RadioEnabled
+		if body.RadioEnabled != nil {
+			existing.RadioEnabled = *body.RadioEnabled
+		}
+		if body.RadioMinQueue != nil {
+			if !isValidRadioMinQueue(*body.RadioMinQueue) {
+				return &httpError{status: http.StatusBadRequest, msg: "radioMinQueue must be between 1 and 20"}
+			}
+			existing.RadioMinQueue = *body.RadioMinQueue
+		}
+		if existing.RadioMinQueue == 0 {
+			existing.RadioMinQueue = defaultRadioMinQueue
+		}
+		if body.SkipVotePercent != nil {
+			if !isValidSkipVotePercent(*body.SkipVotePercent) {
+				return &httpError{status: http.StatusBadRequest, msg: "skipVotePercent must be between 0 and 99"}
+			}
+			existing.SkipVotePercent = *body.SkipVotePercent
+		}
+		if body.SkipVoteThreshold != nil {
+			if !isValidSkipVoteThreshold(*body.SkipVoteThreshold) {
+				return &httpError{status: http.StatusBadRequest, msg: "skipVoteThreshold must be between 0 and 1000"}
+			}
+			existing.SkipVoteThreshold = *body.SkipVoteThreshold
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
 		return
 	}
-
-	if err := tx.Commit(ctx); err != nil {
-		log.Printf("playlist-service: commit tx: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
+	if err != nil {
+		writeHTTPError(w, err, "update playlist")
 		return
 	}
 
 	event := map[string]any{
 		"type": "playlist.updated",
 		"payload": map[string]any{
-			"playlist": existing,
+			"playlist": pl,
 		},
 	}
 	s.publishEvent(ctx, event)
 
-	writeJSON(w, http.StatusOK, existing)
+	if radioTurnedOn {
+		s.triggerRadioFill(pl.ID)
+	}
+
+	writeJSON(w, http.StatusOK, pl)
 }
 
 func (s *Server) handleGetPlaylist(w http.ResponseWriter, r *http.Request) {
@@ -271,23 +346,8 @@ func (s *Server) handleGetPlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var pl Playlist
-	err := s.db.QueryRow(ctx, `
-		SELECT id, owner_id, name, description, is_public, edit_mode, created_at, current_track_id, playing_started_at
-		FROM playlists
-		WHERE id = $1
-	`, playlistID).Scan(
-		&pl.ID,
-		&pl.OwnerID,
-		&pl.Name,
-		&pl.Description,
-		&pl.IsPublic,
-		&pl.EditMode,
-		&pl.CreatedAt,
-		&pl.CurrentTrackID,
-		&pl.PlayingStartedAt,
-	)
-	if errors.Is(err, pgx.ErrNoRows) {
+	pl, err := s.store.GetPlaylist(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
 		writeError(w, http.StatusNotFound, "playlist not found")
 		return
 	}
@@ -299,7 +359,10 @@ func (s *Server) handleGetPlaylist(w http.ResponseWriter, r *http.Request) {
 
 	// Visibility rule:
 	//   - public playlists are visible to everyone;
+	//   - friends-only playlists also to the owner's friends;
 	//   - private playlists are only visible to the owner or invited users.
+	// Friends who have not joined may look but not edit.
+	asFriend := false
 	if !pl.IsPublic && userID != pl.OwnerID {
 		invited, err := s.userIsInvited(ctx, playlistID, userID)
 		if err != nil {
@@ -308,53 +371,44 @@ func (s *Server) handleGetPlaylist(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if !invited {
-			writeError(w, http.StatusForbidden, "playlist is private")
-			return
+			asFriend, err = s.isFriendOfOwner(ctx, pl.Visibility, pl.OwnerID, userID)
+			if err != nil {
+				writeHTTPError(w, err, "get playlist friends check")
+				return
+			}
+			if !asFriend {
+				writeError(w, http.StatusForbidden, "playlist is private")
+				return
+			}
 		}
 	}
 
-	rows, err := s.db.Query(ctx, `
-    SELECT t.id, t.playlist_id, t.title, t.artist, t.position, t.created_at,
-           t.provider, t.provider_track_id, t.thumbnail_url, t.duration_ms, t.vote_count, t.status,
-           (tv.user_id IS NOT NULL) as is_voted
-    FROM tracks t
-    LEFT JOIN track_votes tv ON t.id = tv.track_id AND tv.user_id = $2
-    WHERE t.playlist_id = $1
-    ORDER BY t.position ASC
-  `, playlistID, userID)
+	tracks, err := s.store.ListTracks(ctx, playlistID, userID)
 	if err != nil {
 		log.Printf("playlist-service: list tracks: %v", err)
 		writeError(w, http.StatusInternalServerError, "database error")
 		return
 	}
-	defer rows.Close()
-
-	tracks := []Track{}
-	for rows.Next() {
-		var tr Track
-		if err := rows.Scan(
-			&tr.ID,
-			&tr.PlaylistID,
-			&tr.Title,
-			&tr.Artist,
-			&tr.Position,
-			&tr.CreatedAt,
-			&tr.Provider,
-			&tr.ProviderTrackID,
-			&tr.ThumbnailURL,
-			&tr.DurationMs,
-			&tr.VoteCount,
-			&tr.Status,
-			&tr.IsVoted,
-		); err != nil {
-			log.Printf("playlist-service: list tracks scan: %v", err)
-			writeError(w, http.StatusInternalServerError, "database error")
-			return
-		}
-		tracks = append(tracks, tr)
+
+	pl.TrackCount = len(tracks)
+
+	mod, err := s.store.ModerationState(ctx, playlistID, userID)
+	if err != nil {
+		log.Printf("playlist-service: get playlist moderation state: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	now := time.Now()
+	if lockActive(mod.QueueLockedUntil, now) {
+		pl.QueueLockedUntil = mod.QueueLockedUntil
 	}
-	if err := rows.Err(); err != nil {
-		log.Printf("playlist-service: list tracks rows: %v", err)
+	if lockActive(mod.VotingLockedUntil, now) {
+		pl.VotingLockedUntil = mod.VotingLockedUntil
+	}
+
+	smart, err := s.store.SmartRules(ctx, playlistID)
+	if err != nil {
+		log.Printf("playlist-service: get playlist smart rules: %v", err)
 		writeError(w, http.StatusInternalServerError, "database error")
 		return
 	}
@@ -362,21 +416,37 @@ func (s *Server) handleGetPlaylist(w http.ResponseWriter, r *http.Request) {
 	canEdit := (userID != "" && userID == pl.OwnerID)
 	if !canEdit && userID != "" {
 		if pl.EditMode == editModeEveryone {
-			canEdit = true
+			canEdit = !asFriend
 		} else {
-			invited, _ := s.userIsInvited(ctx, playlistID, userID)
-			canEdit = invited
+			role, err := s.memberRole(ctx, playlistID, userID)
+			if err != nil {
+				log.Printf("playlist-service: get playlist member role: %v", err)
+				writeError(w, http.StatusInternalServerError, "database error")
+				return
+			}
+			canEdit = editModeNeedsRole(pl.EditMode) && roleCanEdit(role)
 		}
 	}
+	if mod.Banned {
+		canEdit = false
+	}
+	canSuggest := userID != "" && pl.EditMode == editModeSuggest && !mod.Banned && !asFriend
+	// The tracks of smart playlists come from their rules.
+	if smart != nil {
+		canEdit, canSuggest = false, false
+	}
 
 	writeJSON(w, http.StatusOK, map[string]any{
-		"playlist": pl,
-		"tracks":   tracks,
-		"canEdit":  canEdit,
+		"playlist":   pl,
+		"tracks":     tracks,
+		"canEdit":    canEdit,
+		"canSuggest": canSuggest,
+		"smart":      smart,
 	})
 }
 
-// handleDeletePlaylist deletes a playlist. Only the owner can delete.
+// handleDeletePlaylist moves a playlist to the trash, where its owner can
+// restore it until it is purged (see trash.go). Only the owner can delete.
 func (s *Server) handleDeletePlaylist(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userID := r.Header.Get("X-User-Id")
@@ -391,43 +461,21 @@ func (s *Server) handleDeletePlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
-	if err != nil {
-		log.Printf("playlist-service: delete playlist begin tx: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-	defer tx.Rollback(ctx)
-
-	var ownerID string
-	err = tx.QueryRow(ctx, "SELECT owner_id FROM playlists WHERE id = $1", playlistID).Scan(&ownerID)
-	if errors.Is(err, pgx.ErrNoRows) {
+	err := s.store.DeletePlaylist(ctx, playlistID, func(pl Playlist) error {
+		if pl.OwnerID != userID {
+			return &httpError{status: http.StatusForbidden, msg: "forbidden"}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
 		writeError(w, http.StatusNotFound, "playlist not found")
 		return
 	}
 	if err != nil {
-		log.Printf("playlist-service: delete playlist fetch: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	if ownerID != userID {
-		writeError(w, http.StatusForbidden, "forbidden")
-		return
-	}
-
-	_, err = tx.Exec(ctx, "DELETE FROM playlists WHERE id = $1", playlistID)
-	if err != nil {
-		log.Printf("playlist-service: delete playlist exec: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		log.Printf("playlist-service: delete playlist commit: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
+		writeHTTPError(w, err, "delete playlist")
 		return
 	}
+	s.scheduler.disarm(playlistID)
 
 	// Notify realtime
 	event := map[string]any{
@@ -437,4 +485,4 @@ func (s *Server) handleDeletePlaylist(w http.ResponseWriter, r *http.Request) {
 	s.publishEvent(ctx, event)
 
 	w.WriteHeader(http.StatusNoContent)
-}
\ No newline at end of file
+}
@@ -28,7 +28,7 @@ func TestHandleListPlaylists_Success(t *testing.T) {
 			return &MockRows{
 				Data: [][]any{
 					{
-						"pl-1", "user-1", "Public List", "Desc", true, "everyone", time.Now(),
+						"pl-1", "user-1", "Public List", "Desc", true, "everyone", time.Now(), 3, nil, 2, "public",
 					},
 				},
 				Idx: -1,
@@ -8,7 +8,6 @@ import (
 	"strings"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
 )
 
 func (s *Server) handleAddTrack(w http.ResponseWriter, r *http.Request) {
@@ -25,130 +24,50 @@ func (s *Server) handleAddTrack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ownerID, isPublic, editMode, err := s.getPlaylistAccessInfo(ctx, playlistID)
-	if errors.Is(err, pgx.ErrNoRows) {
-		writeError(w, http.StatusNotFound, "playlist not found")
+	var body trackInput
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
 		return
 	}
-	if err != nil {
-		log.Printf("playlist-service: add track fetch playlist: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
+	body.addedBy = userID
+	if msg := body.normalize(); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
 		return
 	}
-
-	invited := false
-	if userID != "" && userID != ownerID {
-		invited, err = s.userIsInvited(ctx, playlistID, userID)
-		if err != nil {
-			log.Printf("playlist-service: add track invited check: %v", err)
-			writeError(w, http.StatusInternalServerError, "database error")
-			return
-		}
-	}
-
-	// Private playlist: only owner or invited can access at all.
-	if !isPublic && userID != ownerID && !invited {
-		writeError(w, http.StatusForbidden, "forbidden")
+	moderator, err := s.checkEditAccess(ctx, playlistID, userID, lockQueue, body.clientLocation)
+	if err != nil {
+		writeHTTPError(w, err, "add track access")
 		return
 	}
-
-	// License for edit rights.
-	if userID != ownerID {
-		if editMode == editModeInvited && !invited {
-			writeError(w, http.StatusForbidden, "forbidden")
-			return
-		}
-		// editModeEveryone: any authenticated user with access is allowed.
-	}
-
-	var body struct {
-		Title         string `json:"title"`
-		Artist        string `json:"artist"`
-		Provider      string `json:"provider"`
-		ProviderTrack string `json:"providerTrackId"`
-		ThumbnailURL  string `json:"thumbnailUrl"`
-		DurationMs    int    `json:"durationMs"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
+	if err := s.resolveTrack(ctx, &body); err != nil {
+		writeHTTPError(w, err, "add track resolve")
 		return
 	}
 
-	body.Title = strings.TrimSpace(body.Title)
-	body.Artist = strings.TrimSpace(body.Artist)
-	body.Provider = strings.TrimSpace(strings.ToLower(body.Provider))
-	body.ProviderTrack = strings.TrimSpace(body.ProviderTrack)
-	body.ThumbnailURL = strings.TrimSpace(body.ThumbnailURL)
-
-	if body.Title == "" || len(body.Title) > 300 {
-		writeError(w, http.StatusBadRequest, "title must be between 1 and 300 characters")
+	rules, err := s.store.ContentRules(ctx, playlistID)
+	if err != nil {
+		log.Printf("playlist-service: add track content rules: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
 		return
 	}
-	if len(body.Artist) > 200 {
-		writeError(w, http.StatusBadRequest, "artist is too long")
+	if err := checkContentRules(rules, body, moderator); err != nil {
+		writeHTTPError(w, err, "add track content rules")
 		return
 	}
 
-	if body.Provider != "" {
-		if body.Provider != "youtube" {
-			writeError(w, http.StatusBadRequest, "unsupported provider (only \"youtube\" is allowed)")
-			return
-		}
-		if body.ProviderTrack == "" {
-			writeError(w, http.StatusBadRequest, "providerTrackId is required when provider is set")
-			return
-		}
+	// Duplicates merged as votes obey the voting lock, like votes.
+	var mergeDenied *httpError
+	if err := s.checkRestrictions(ctx, playlistID, userID, moderator, lockVoting); err != nil && !errors.As(err, &mergeDenied) {
+		writeHTTPError(w, err, "add track restrictions")
+		return
 	}
-
-	var tr Track
-	err = s.db.QueryRow(ctx, `
-      INSERT INTO tracks (
-          playlist_id,
-          title,
-          artist,
-          position,
-          provider,
-          provider_track_id,
-          thumbnail_url,
-          duration_ms,
-          vote_count,
-          status
-      )
-      VALUES (
-          $1, $2, $3,
-          COALESCE(
-              (SELECT MAX(position)+1 FROM tracks WHERE playlist_id = $1),
-              0
-          ),
-          $4, $5, $6, $7, 0, 'queued'
-      )
-      RETURNING id, playlist_id, title, artist, position, created_at,
-                provider, provider_track_id, thumbnail_url, duration_ms, vote_count, status
-  `,
-		playlistID,
-		body.Title,
-		body.Artist,
-		body.Provider,
-		body.ProviderTrack,
-		body.ThumbnailURL,
-		body.DurationMs,
-	).Scan(
-		&tr.ID,
-		&tr.PlaylistID,
-		&tr.Title,
-		&tr.Artist,
-		&tr.Position,
-		&tr.CreatedAt,
-		&tr.Provider,
-		&tr.ProviderTrackID,
-		&tr.ThumbnailURL,
-		&tr.DurationMs,
-		&tr.VoteCount,
-		&tr.Status,
-	)
+	tr, merged, err := s.store.AddTrackUnlessDuplicate(ctx, playlistID, userID, body, mergeDenied)
 	if err != nil {
-		log.Printf("playlist-service: add track insert: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
+		writeHTTPError(w, err, "add track insert")
+		return
+	}
+	if merged {
+		s.respondMergedVote(w, r, playlistID, tr)
 		return
 	}
 
@@ -160,10 +79,36 @@ func (s *Server) handleAddTrack(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 	s.publishEvent(ctx, event)
+	s.notifyFollowers(ctx, trackAddedItem(tr))
 
 	writeJSON(w, http.StatusCreated, tr)
 }
 
+// respondMergedVote reports a re-added queued track that was turned into a
+// vote for the existing one (duplicate policy "merge_vote"). It responds 200
+// with the existing track instead of 201.
+func (s *Server) respondMergedVote(w http.ResponseWriter, r *http.Request, playlistID string, tr Track) {
+	ctx := r.Context()
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "track.updated",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"trackId":    tr.ID,
+			"voteCount":  tr.VoteCount,
+		},
+	})
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.reordered",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+		},
+	})
+
+	tr.IsVoted = true
+	writeJSON(w, http.StatusOK, tr)
+}
+
 // handleMoveTrack reorders a track within its playlist (concurrency-sensitive).
 func (s *Server) handleMoveTrack(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -182,6 +127,7 @@ func (s *Server) handleMoveTrack(w http.ResponseWriter, r *http.Request) {
 
 	var body struct {
 		NewPosition int `json:"newPosition"`
+		clientLocation
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid JSON body")
@@ -192,158 +138,35 @@ func (s *Server) handleMoveTrack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ownerID, isPublic, editMode, err := s.getPlaylistAccessInfo(ctx, playlistID)
-	if errors.Is(err, pgx.ErrNoRows) {
-		writeError(w, http.StatusNotFound, "playlist not found")
-		return
-	}
-	if err != nil {
-		log.Printf("playlist-service: move track fetch playlist: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	invited := false
-	if userID != "" && userID != ownerID {
-		invited, err = s.userIsInvited(ctx, playlistID, userID)
-		if err != nil {
-			log.Printf("playlist-service: move track invited check: %v", err)
-			writeError(w, http.StatusInternalServerError, "database error")
-			return
-		}
-	}
-
-	if !isPublic && userID != ownerID && !invited {
-		writeError(w, http.StatusForbidden, "forbidden")
-		return
-	}
-	if userID != ownerID {
-		if editMode == editModeInvited && !invited {
-			writeError(w, http.StatusForbidden, "forbidden")
-			return
-		}
-	}
-
-	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
-	if err != nil {
-		log.Printf("playlist-service: move track begin tx: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
+	if _, err := s.checkEditAccess(ctx, playlistID, userID, lockQueue, body.clientLocation); err != nil {
+		writeHTTPError(w, err, "move track access")
 		return
 	}
-	defer tx.Rollback(ctx)
 
-	var currentPos int
-	var trackPlaylistID string
-	err = tx.QueryRow(ctx, `
-		SELECT playlist_id, position
-		FROM tracks
-		WHERE id = $1 AND playlist_id = $2
-		FOR UPDATE
-	`, trackID, playlistID).Scan(&trackPlaylistID, &currentPos)
-	if errors.Is(err, pgx.ErrNoRows) {
+	currentPos, newPos, err := s.store.MoveTrack(ctx, playlistID, trackID, body.NewPosition)
+	if errors.Is(err, ErrNotFound) {
 		writeError(w, http.StatusNotFound, "track not found")
 		return
 	}
 	if err != nil {
-		log.Printf("playlist-service: move track fetch track: %v", err)
+		log.Printf("playlist-service: move track: %v", err)
 		writeError(w, http.StatusInternalServerError, "database error")
 		return
 	}
 
-	var total int
-	if err := tx.QueryRow(ctx, `
-		SELECT COUNT(*) FROM tracks WHERE playlist_id = $1
-	`, playlistID).Scan(&total); err != nil {
-		log.Printf("playlist-service: move track count: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-	if total <= 0 {
-		writeError(w, http.StatusConflict, "no tracks to move")
-		return
-	}
-
-	newPos := body.NewPosition
-	if newPos >= total {
-		newPos = total - 1
-	}
-	if newPos == currentPos {
-		if err := tx.Commit(ctx); err != nil {
-			log.Printf("playlist-service: move track commit noop: %v", err)
-			writeError(w, http.StatusInternalServerError, "database error")
-			return
+	if newPos != currentPos {
+		event := map[string]any{
+			"type": "track.moved",
+			"payload": map[string]any{
+				"playlistId": playlistID,
+				"trackId":    trackID,
+				"from":       currentPos,
+				"to":         newPos,
+			},
 		}
-		writeJSON(w, http.StatusOK, map[string]any{
-			"trackId": trackID,
-			"from":    currentPos,
-			"to":      newPos,
-		})
-		return
+		s.publishEvent(ctx, event)
 	}
 
-	// Move to temporary position to avoid unique constraint violation
-	_, err = tx.Exec(ctx, `
-		UPDATE tracks
-		SET position = -1
-		WHERE id = $2 AND playlist_id = $1
-	`, playlistID, trackID)
-	if err != nil {
-		log.Printf("playlist-service: move track temp: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	if newPos > currentPos {
-		_, err = tx.Exec(ctx, `
-			UPDATE tracks
-			SET position = position - 1
-			WHERE playlist_id = $1
-			  AND position > $2
-			  AND position <= $3
-		`, playlistID, currentPos, newPos)
-	} else {
-		_, err = tx.Exec(ctx, `
-			UPDATE tracks
-			SET position = position + 1
-			WHERE playlist_id = $1
-			  AND position >= $3
-			  AND position < $2
-		`, playlistID, currentPos, newPos)
-	}
-	if err != nil {
-		log.Printf("playlist-service: move track shift: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	_, err = tx.Exec(ctx, `
-		UPDATE tracks
-		SET position = $3
-		WHERE id = $2 AND playlist_id = $1
-	`, playlistID, trackID, newPos)
-	if err != nil {
-		log.Printf("playlist-service: move track set position: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		log.Printf("playlist-service: move track commit: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	event := map[string]any{
-		"type": "track.moved",
-		"payload": map[string]any{
-			"playlistId": playlistID,
-			"trackId":    trackID,
-			"from":       currentPos,
-			"to":         newPos,
-		},
-	}
-	s.publishEvent(ctx, event)
-
 	writeJSON(w, http.StatusOK, map[string]any{
 		"trackId": trackID,
 		"from":    currentPos,
@@ -366,84 +189,18 @@ func (s *Server) handleDeleteTrack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ownerID, isPublic, editMode, err := s.getPlaylistAccessInfo(ctx, playlistID)
-	if errors.Is(err, pgx.ErrNoRows) {
-		writeError(w, http.StatusNotFound, "playlist not found")
+	if _, err := s.checkEditAccess(ctx, playlistID, userID, lockQueue, queryLocation(r)); err != nil {
+		writeHTTPError(w, err, "delete track access")
 		return
 	}
-	if err != nil {
-		log.Printf("playlist-service: delete track fetch playlist: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	invited := false
-	if userID != "" && userID != ownerID {
-		invited, err = s.userIsInvited(ctx, playlistID, userID)
-		if err != nil {
-			log.Printf("playlist-service: delete track invited check: %v", err)
-			writeError(w, http.StatusInternalServerError, "database error")
-			return
-		}
-	}
 
-	if !isPublic && userID != ownerID && !invited {
-		writeError(w, http.StatusForbidden, "forbidden")
-		return
-	}
-	if userID != ownerID {
-		if editMode == editModeInvited && !invited {
-			writeError(w, http.StatusForbidden, "forbidden")
-			return
-		}
-	}
-
-	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
-	if err != nil {
-		log.Printf("playlist-service: delete track begin tx: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-	defer tx.Rollback(ctx)
-
-	var pos int
-	err = tx.QueryRow(ctx, `
-		SELECT position
-		FROM tracks
-		WHERE id = $1 AND playlist_id = $2
-		FOR UPDATE
-	`, trackID, playlistID).Scan(&pos)
-	if errors.Is(err, pgx.ErrNoRows) {
+	pos, err := s.store.DeleteTrack(ctx, playlistID, trackID)
+	if errors.Is(err, ErrNotFound) {
 		writeError(w, http.StatusNotFound, "track not found")
 		return
 	}
 	if err != nil {
-		log.Printf("playlist-service: delete track fetch: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	if _, err := tx.Exec(ctx, `
-		DELETE FROM tracks
-		WHERE id = $1 AND playlist_id = $2
-	`, trackID, playlistID); err != nil {
-		log.Printf("playlist-service: delete track delete: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	if _, err := tx.Exec(ctx, `
-		UPDATE tracks
-		SET position = position - 1
-		WHERE playlist_id = $1 AND position > $2
-	`, playlistID, pos); err != nil {
-		log.Printf("playlist-service: delete track compact: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		log.Printf("playlist-service: delete track commit: %v", err)
+		log.Printf("playlist-service: delete track: %v", err)
 		writeError(w, http.StatusInternalServerError, "database error")
 		return
 	}
@@ -460,3 +217,59 @@ func (s *Server) handleDeleteTrack(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// trackInput is the client-supplied description of a track to add.
+type trackInput struct {
+	Title         string `json:"title"`
+	Artist        string `json:"artist"`
+	Provider      string `json:"provider"`
+	ProviderTrack string `json:"providerTrackId"`
+	ThumbnailURL  string `json:"thumbnailUrl"`
+	DurationMs    int    `json:"durationMs"`
+	// OverrideRules queues the track despite the playlist's content rules;
+	// only moderators may set it.
+	OverrideRules bool `json:"overrideRules,omitempty"`
+	// clientLocation is checked against the edit license when the track is
+	// added on its own; batches send one location for all operations.
+	clientLocation
+
+	// resolved is set once the metadata comes from the music provider.
+	resolved bool
+	// addedBy is the user queueing the track; empty for radio tracks.
+	addedBy string
+}
+
+// normalize trims the input and validates it. It returns a client-facing
+// error message, or "" when the input is valid.
+func (in *trackInput) normalize() string {
+	in.Title = strings.TrimSpace(in.Title)
+	in.Artist = strings.TrimSpace(in.Artist)
+	in.Provider = strings.TrimSpace(strings.ToLower(in.Provider))
+	in.ProviderTrack = strings.TrimSpace(in.ProviderTrack)
+	in.ThumbnailURL = strings.TrimSpace(in.ThumbnailURL)
+
+	if in.Title == "" || len(in.Title) > 300 {
+		return "title must be between 1 and 300 characters"
+	}
+	if len(in.Artist) > 200 {
+		return "artist is too long"
+	}
+	if in.Provider != "" {
+		if in.Provider != "youtube" {
+			return "unsupported provider (only \"youtube\" is allowed)"
+		}
+		if in.ProviderTrack == "" {
+			return "providerTrackId is required when provider is set"
+		}
+	}
+	return ""
+}
+
+func (in trackInput) candidate() candidateTrack {
+	return candidateTrack{
+		Title:           in.Title,
+		Artist:          in.Artist,
+		Provider:        in.Provider,
+		ProviderTrackID: in.ProviderTrack,
+	}
+}
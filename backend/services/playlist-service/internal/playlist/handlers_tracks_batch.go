@@ -0,0 +1,242 @@
+package playlist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxBatchOperations = 100
+
+const (
+	batchOpAdd    = "add"
+	batchOpRemove = "remove"
+	batchOpMove   = "move"
+)
+
+// batchOperation is one entry of a POST /playlists/{id}/tracks:batch request.
+type batchOperation struct {
+	Op          string      `json:"op"` // "add" | "remove" | "move"
+	Track       *trackInput `json:"track,omitempty"`
+	TrackID     string      `json:"trackId,omitempty"`
+	NewPosition *int        `json:"newPosition,omitempty"`
+}
+
+// batchResult reports the outcome of one batch operation.
+type batchResult struct {
+	Index   int    `json:"index"`
+	Op      string `json:"op"`
+	Status  string `json:"status"` // "ok" | "merged" | "failed" | "skipped"
+	Error   string `json:"error,omitempty"`
+	TrackID string `json:"trackId,omitempty"`
+	Track   *Track `json:"track,omitempty"`
+	From    *int   `json:"from,omitempty"`
+	To      *int   `json:"to,omitempty"`
+}
+
+// handleBatchTracks applies a list of add/remove/move operations in a single
+// transaction. Either every operation is applied or none is; the response
+// always lists a result per operation.
+// POST /playlists/{id}/tracks:batch
+func (s *Server) handleBatchTracks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+
+	playlistID := chi.URLParam(r, "id")
+	if playlistID == "" {
+		writeError(w, http.StatusBadRequest, "missing playlist id")
+		return
+	}
+
+	var body struct {
+		Operations []batchOperation `json:"operations"`
+		clientLocation
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if len(body.Operations) == 0 {
+		writeError(w, http.StatusBadRequest, "operations must not be empty")
+		return
+	}
+	if len(body.Operations) > maxBatchOperations {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d operations are allowed", maxBatchOperations))
+		return
+	}
+
+	results := make([]batchResult, len(body.Operations))
+	invalid := false
+	for i := range body.Operations {
+		op := &body.Operations[i]
+		op.Op = strings.ToLower(strings.TrimSpace(op.Op))
+		results[i] = batchResult{Index: i, Op: op.Op, Status: "skipped", TrackID: op.TrackID}
+		if msg := op.validate(); msg != "" {
+			results[i].Status = "failed"
+			results[i].Error = msg
+			invalid = true
+		}
+	}
+	if invalid {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":   "invalid operations",
+			"results": results,
+		})
+		return
+	}
+
+	moderator, err := s.checkEditAccess(ctx, playlistID, userID, lockQueue, body.clientLocation)
+	if err != nil {
+		writeHTTPError(w, err, "batch tracks access")
+		return
+	}
+
+	// Provider lookups happen before the transaction.
+	for i := range body.Operations {
+		op := &body.Operations[i]
+		if op.Op != batchOpAdd {
+			continue
+		}
+		op.Track.addedBy = userID
+		err := s.resolveTrack(ctx, op.Track)
+		var he *httpError
+		if errors.As(err, &he) {
+			results[i].Status = "failed"
+			results[i].Error = he.msg
+			writeJSON(w, he.status, map[string]any{
+				"error":   fmt.Sprintf("operation %d failed: %s", i, he.msg),
+				"results": results,
+			})
+			return
+		}
+		if err != nil {
+			writeHTTPError(w, err, "batch tracks resolve")
+			return
+		}
+	}
+
+	rules, err := s.store.ContentRules(ctx, playlistID)
+	if err != nil {
+		log.Printf("playlist-service: batch tracks content rules: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+	var mergeDenied *httpError
+	for _, op := range body.Operations {
+		if op.Op != batchOpAdd {
+			continue
+		}
+		// Duplicates merged as votes obey the voting lock, like votes.
+		if err := s.checkRestrictions(ctx, playlistID, userID, moderator, lockVoting); err != nil && !errors.As(err, &mergeDenied) {
+			writeHTTPError(w, err, "batch tracks restrictions")
+			return
+		}
+		break
+	}
+	for i, op := range body.Operations {
+		if op.Op != batchOpAdd {
+			continue
+		}
+		var he *httpError
+		if errors.As(checkContentRules(rules, *op.Track, moderator), &he) {
+			results[i].Status = "failed"
+			results[i].Error = he.msg
+			writeJSON(w, he.status, map[string]any{
+				"error":   fmt.Sprintf("operation %d failed: %s", i, he.msg),
+				"results": results,
+			})
+			return
+		}
+	}
+
+	err = s.store.ApplyTrackBatch(ctx, playlistID, userID, body.Operations, mergeDenied, results)
+	var opErr *batchOpError
+	if errors.As(err, &opErr) {
+		writeJSON(w, opErr.err.status, map[string]any{
+			"error":   fmt.Sprintf("operation %d failed: %s", opErr.index, opErr.err.msg),
+			"results": results,
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("playlist-service: batch tracks: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.batch_updated",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"results":    results,
+		},
+	})
+	for _, res := range results {
+		if res.Op == batchOpAdd && res.Status == "ok" && res.Track != nil {
+			s.notifyFollowers(ctx, trackAddedItem(*res.Track))
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+// batchOpError reports the operation that failed a batch. Its result is
+// marked failed and the results of the operations before it are reset to
+// skipped, since nothing was applied.
+type batchOpError struct {
+	index int
+	err   *httpError
+}
+
+func (e *batchOpError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.index, e.err.msg)
+}
+
+// failBatch records the failure of operation i in results and returns the
+// *batchOpError for it.
+func failBatch(results []batchResult, i int, he *httpError) error {
+	for j := 0; j < i; j++ {
+		results[j].Status = "skipped"
+		results[j].Track = nil
+		results[j].From = nil
+		results[j].To = nil
+	}
+	results[i].Status = "failed"
+	results[i].Error = he.msg
+	return &batchOpError{index: i, err: he}
+}
+
+// validate checks the shape of an operation and normalizes added tracks. It
+// returns a client-facing error message, or "" when the operation is valid.
+func (op *batchOperation) validate() string {
+	switch op.Op {
+	case batchOpAdd:
+		if op.Track == nil {
+			return "track is required for add"
+		}
+		return op.Track.normalize()
+	case batchOpRemove:
+		if strings.TrimSpace(op.TrackID) == "" {
+			return "trackId is required for remove"
+		}
+	case batchOpMove:
+		if strings.TrimSpace(op.TrackID) == "" {
+			return "trackId is required for move"
+		}
+		if op.NewPosition == nil || *op.NewPosition < 0 {
+			return "newPosition must be >= 0"
+		}
+	default:
+		return `op must be "add", "remove" or "move"`
+	}
+	return ""
+}
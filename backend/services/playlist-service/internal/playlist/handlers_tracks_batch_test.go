@@ -0,0 +1,178 @@
+package playlist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func batchOwnerDB(tx *MockTx) *MockDB {
+	return &MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if strings.Contains(sql, "SELECT owner_id") {
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "user-1"
+					*dest[1].(*bool) = true
+					*dest[2].(*string) = "everyone"
+					return nil
+				}}
+			}
+			return &MockRow{}
+		},
+		BeginTxFunc: func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+			return tx, nil
+		},
+	}
+}
+
+func TestHandleBatchTracks_Success(t *testing.T) {
+	committed := false
+	tx := &MockTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			switch {
+			case strings.Contains(sql, "INSERT INTO tracks"):
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "tr-new"
+					return nil
+				}}
+			case strings.Contains(sql, "SELECT position, total"):
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*int) = 3
+					*dest[1].(*int) = 5
+					return nil
+				}}
+			case strings.Contains(sql, "source = 'radio'"):
+				return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+			}
+			return &MockRow{}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			return pgconn.CommandTag{}, nil
+		},
+		CommitFunc: func(ctx context.Context) error {
+			committed = true
+			return nil
+		},
+	}
+	srv := NewServer(batchOwnerDB(tx), nil)
+
+	body, _ := json.Marshal(map[string]any{
+		"operations": []map[string]any{
+			{"op": "add", "track": map[string]any{"title": "New Song"}},
+			{"op": "remove", "trackId": "tr-old"},
+			{"op": "move", "trackId": "tr-2", "newPosition": 0},
+		},
+	})
+	req := httptest.NewRequest("POST", "/playlists/pl-1/tracks:batch", bytes.NewReader(body))
+	req.Header.Set("X-User-Id", "user-1")
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 OK, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if !committed {
+		t.Errorf("expected transaction to be committed")
+	}
+
+	var resp struct {
+		Results []batchResult `json:"results"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(resp.Results))
+	}
+	for _, res := range resp.Results {
+		if res.Status != "ok" {
+			t.Errorf("op %d: expected ok, got %s (%s)", res.Index, res.Status, res.Error)
+		}
+	}
+	if resp.Results[0].TrackID != "tr-new" {
+		t.Errorf("expected added track id tr-new, got %s", resp.Results[0].TrackID)
+	}
+	if resp.Results[2].From == nil || *resp.Results[2].From != 3 || *resp.Results[2].To != 0 {
+		t.Errorf("unexpected move result: %+v", resp.Results[2])
+	}
+}
+
+func TestHandleBatchTracks_FailureRollsBack(t *testing.T) {
+	committed := false
+	tx := &MockTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if strings.Contains(sql, "SELECT position") {
+				return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+			}
+			return &MockRow{}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			return pgconn.CommandTag{}, nil
+		},
+		CommitFunc: func(ctx context.Context) error {
+			committed = true
+			return nil
+		},
+	}
+	srv := NewServer(batchOwnerDB(tx), nil)
+
+	body, _ := json.Marshal(map[string]any{
+		"operations": []map[string]any{
+			{"op": "add", "track": map[string]any{"title": "New Song"}},
+			{"op": "remove", "trackId": "tr-missing"},
+			{"op": "add", "track": map[string]any{"title": "Never Applied"}},
+		},
+	})
+	req := httptest.NewRequest("POST", "/playlists/pl-1/tracks:batch", bytes.NewReader(body))
+	req.Header.Set("X-User-Id", "user-1")
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if committed {
+		t.Errorf("transaction must not be committed when an operation fails")
+	}
+
+	var resp struct {
+		Results []batchResult `json:"results"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	want := []string{"skipped", "failed", "skipped"}
+	for i, res := range resp.Results {
+		if res.Status != want[i] {
+			t.Errorf("op %d: expected %s, got %s", i, want[i], res.Status)
+		}
+	}
+}
+
+func TestHandleBatchTracks_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "Empty", body: `{"operations":[]}`},
+		{name: "Unknown Op", body: `{"operations":[{"op":"shuffle"}]}`},
+		{name: "Add Without Track", body: `{"operations":[{"op":"add"}]}`},
+		{name: "Move Without Position", body: `{"operations":[{"op":"move","trackId":"tr-1"}]}`},
+		{name: "Invalid Title", body: `{"operations":[{"op":"add","track":{"title":"  "}}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(&MockDB{}, nil)
+			req := httptest.NewRequest("POST", "/playlists/pl-1/tracks:batch", strings.NewReader(tt.body))
+			req.Header.Set("X-User-Id", "user-1")
+			w := httptest.NewRecorder()
+			srv.Router().ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d", w.Code)
+			}
+		})
+	}
+}
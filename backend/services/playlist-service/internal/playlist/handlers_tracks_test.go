@@ -29,6 +29,15 @@ func TestHandleAddTrack_Success(t *testing.T) {
 	// Mock DB Expectations
 	// 1. getPlaylistAccessInfo: SELECT owner_id ...
 	mockDB.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+		if row, ok := smartRulesRow(sql); ok {
+			return row
+		}
+		if row, ok := moderationStateRow(sql); ok {
+			return row
+		}
+		if row, ok := contentRulesRow(sql); ok {
+			return row
+		}
 		if strings.Contains(sql, "SELECT owner_id") {
 			return &MockRow{
 				ScanFunc: func(dest ...any) error {
@@ -40,7 +49,21 @@ func TestHandleAddTrack_Success(t *testing.T) {
 				},
 			}
 		}
-		// 2. Insert Track: INSERT INTO tracks ...
+		// 2. Duplicate policy: default "allow" skips the duplicate lookup
+		if strings.Contains(sql, "SELECT duplicate_policy") {
+			return &MockRow{
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "allow"
+					*dest[1].(*bool) = false
+					return nil
+				},
+			}
+		}
+		// No radio tracks to jump ahead of
+		if strings.Contains(sql, "source = 'radio'") {
+			return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		}
+		// 3. Insert Track: INSERT INTO tracks ...
 		if strings.Contains(sql, "INSERT INTO tracks") {
 			return &MockRow{
 				ScanFunc: func(dest ...any) error {
@@ -94,6 +117,12 @@ func TestHandleMoveTrack_Success(t *testing.T) {
 
 	// 1. Check Access (QueryRow)
 	mockDB.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+		if row, ok := smartRulesRow(sql); ok {
+			return row
+		}
+		if row, ok := moderationStateRow(sql); ok {
+			return row
+		}
 		if strings.Contains(sql, "SELECT owner_id") {
 			return &MockRow{
 				ScanFunc: func(dest ...any) error {
@@ -115,21 +144,22 @@ func TestHandleMoveTrack_Success(t *testing.T) {
 
 	// Tx Operations
 	mockTx.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
-		// 3. Select existing pos: SELECT playlist_id, position ...
-		if strings.Contains(sql, "SELECT playlist_id, position") {
+		// 3. Select existing pos and count: SELECT position, total ...
+		if strings.Contains(sql, "SELECT position, total") {
 			return &MockRow{
 				ScanFunc: func(dest ...any) error {
-					*dest[0].(*string) = playlistID
-					*dest[1].(*int) = currentPos
+					*dest[0].(*int) = currentPos
+					*dest[1].(*int) = totalTracks
 					return nil
 				},
 			}
 		}
-		// 4. Count: SELECT COUNT(*) ...
-		if strings.Contains(sql, "SELECT COUNT(*)") {
+		// 4. Neighbour keys: SELECT sort_key ...
+		if strings.Contains(sql, "SELECT sort_key") {
 			return &MockRow{
 				ScanFunc: func(dest ...any) error {
-					*dest[0].(*int) = totalTracks
+					keys := map[string]int64{"track-1": sortKeyGap, "track-2": 2 * sortKeyGap}
+					*dest[0].(*int64) = keys[args[0].(string)]
 					return nil
 				},
 			}
@@ -137,10 +167,16 @@ func TestHandleMoveTrack_Success(t *testing.T) {
 		return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected tx query") }}
 	}
 
+	mockTx.QueryFunc = func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		// Neighbours of the new position
+		if strings.Contains(sql, "ORDER BY sort_key, id") {
+			return &MockRows{Data: [][]any{{"track-1"}, {"track-2"}}, Idx: -1}, nil
+		}
+		return nil, errors.New("unexpected tx query")
+	}
+
 	mockTx.ExecFunc = func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
-		// 5. Shift existing tracks: UPDATE tracks SET position ...
-		// 6. Set new position: UPDATE tracks SET position = $3 ...
-		// We can just return success
+		// 5. Lock playlist, 6. set the new sort key
 		return pgconn.CommandTag{}, nil
 	}
 
@@ -187,7 +223,7 @@ func TestHandleAddTrack_Forbidden(t *testing.T) {
 				},
 			}
 		}
-		if strings.Contains(sql, "SELECT user_id") { // Invited check
+		if strings.Contains(sql, "FROM playlist_members") { // Invited check
 			return &MockRow{
 				ScanFunc: func(dest ...any) error {
 					return pgx.ErrNoRows // Not invite
@@ -305,6 +341,12 @@ func TestHandleAddTrack_Errors(t *testing.T) {
 			body:       nil,
 			mockSetup: func(m *MockDB) {
 				m.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+					if row, ok := smartRulesRow(sql); ok {
+						return row
+					}
+					if row, ok := moderationStateRow(sql); ok {
+						return row
+					}
 					if strings.Contains(sql, "FROM playlists") {
 						return &MockRow{ScanFunc: func(dest ...any) error {
 							*dest[0].(*string) = "user-1"
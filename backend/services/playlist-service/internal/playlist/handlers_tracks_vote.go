@@ -4,12 +4,9 @@ import (
 	"errors"
 	"log"
 	"net/http"
-	"sort"
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 // handleVoteTrack handles upvoting a track.
@@ -29,180 +26,22 @@ func (s *Server) handleVoteTrack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 1. Check access
-	ownerID, isPublic, editMode, err := s.getPlaylistAccessInfo(ctx, playlistID)
-	if errors.Is(err, pgx.ErrNoRows) {
-		writeError(w, http.StatusNotFound, "playlist not found")
+	if _, err := s.checkEditAccess(ctx, playlistID, userID, lockVoting, clientLocation{}); err != nil {
+		writeHTTPError(w, err, "vote track access")
 		return
 	}
-	if err != nil {
-		log.Printf("playlist-service: vote track fetch playlist: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	invited := false
-	if userID != "" && userID != ownerID {
-		invited, err = s.userIsInvited(ctx, playlistID, userID)
-		if err != nil {
-			log.Printf("playlist-service: vote track invited check: %v", err)
-			writeError(w, http.StatusInternalServerError, "database error")
-			return
-		}
-	}
-
-	if !isPublic && userID != ownerID && !invited {
-		writeError(w, http.StatusForbidden, "forbidden")
-		return
-	}
-
-	// Enforce editMode for voting
-	if userID != ownerID {
-		if editMode == editModeInvited && !invited {
-			writeError(w, http.StatusForbidden, "this playlist requires an invitation to vote")
-			return
-		}
-	}
-
-	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
-	if err != nil {
-		log.Printf("playlist-service: vote track begin tx: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-	defer tx.Rollback(ctx)
 
-	// 2. Prevent duplicate votes
-	_, err = tx.Exec(ctx, `
-		INSERT INTO track_votes (track_id, user_id)
-		VALUES ($1, $2)
-	`, trackID, userID)
-	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-			// Already voted. Return current vote count.
-			var currentVotes int
-			_ = tx.QueryRow(ctx, "SELECT vote_count FROM tracks WHERE id = $1", trackID).Scan(&currentVotes)
-			writeError(w, http.StatusConflict, "already voted") // 409
-			return
-		}
-		log.Printf("playlist-service: vote track insert vote: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
+	tr, err := s.store.VoteTrack(ctx, playlistID, trackID, userID)
+	if errors.Is(err, errAlreadyVoted) {
+		writeError(w, http.StatusConflict, "already voted") // 409
 		return
 	}
-
-	// 3. Increment vote count
-	var newVoteCount int
-	var status string
-	err = tx.QueryRow(ctx, `
-		UPDATE tracks
-		SET vote_count = vote_count + 1
-		WHERE id = $1 AND playlist_id = $2
-		RETURNING vote_count, status
-	`, trackID, playlistID).Scan(&newVoteCount, &status)
-
-	if errors.Is(err, pgx.ErrNoRows) {
+	if errors.Is(err, ErrNotFound) {
 		writeError(w, http.StatusNotFound, "track not found")
 		return
 	}
 	if err != nil {
-		log.Printf("playlist-service: vote track update: %v", err)
-		writeError(w, http.StatusInternalServerError, "database error")
-		return
-	}
-
-	if status == "queued" {
-		// 3. Reorder tracks based on votes
-		params := []any{playlistID}
-		rows, err := tx.Query(ctx, `
-			SELECT id, vote_count, created_at, position
-			FROM tracks
-			WHERE playlist_id = $1 AND status = 'queued'
-			ORDER BY position ASC
-			FOR UPDATE
-		`, params...)
-		if err != nil {
-			log.Printf("playlist-service: vote track select all: %v", err)
-			writeError(w, http.StatusInternalServerError, "database error")
-			return
-		}
-
-		type trackSortInfo struct {
-			ID        string
-			VoteCount int
-			CreatedAt time.Time
-			Position  int
-		}
-
-		var tracks []trackSortInfo
-		for rows.Next() {
-			var t trackSortInfo
-			if err := rows.Scan(&t.ID, &t.VoteCount, &t.CreatedAt, &t.Position); err != nil {
-				rows.Close()
-				log.Printf("playlist-service: vote track scan ids: %v", err)
-				writeError(w, http.StatusInternalServerError, "database error")
-				return
-			}
-			tracks = append(tracks, t)
-		}
-		rows.Close()
-
-		// Stable sort
-		sort.SliceStable(tracks, func(i, j int) bool {
-			if tracks[i].VoteCount != tracks[j].VoteCount {
-				return tracks[i].VoteCount > tracks[j].VoteCount
-			}
-			return tracks[i].CreatedAt.Before(tracks[j].CreatedAt)
-		})
-
-		// Find start position (after any playing/played tracks)
-		var startPos int = 0
-		err = tx.QueryRow(ctx, `
-			SELECT COALESCE(MAX(position) + 1, 0)
-			FROM tracks
-			WHERE playlist_id = $1 AND status != 'queued'
-		`, playlistID).Scan(&startPos)
-		if err != nil {
-			log.Printf("playlist-service: vote track get start pos: %v", err)
-			writeError(w, http.StatusInternalServerError, "database error")
-			return
-		}
-
-		// Update positions
-		// Optimization: Only update if position changed?
-		// But simpler to update all for correctness in this complex flow.
-		// To avoid unique constraint violation, update to negative first?
-		// Or since we have a list of all IDs, just update them.
-
-		// NOTE: Updating position on same table with unique index in loop can fail.
-		// Safe strategy:
-		// 1. Set all target positions to (-position - 1000000)
-		// 2. Set them to correct new position
-
-		// Step 1: Temporary move out of way
-		for _, t := range tracks {
-			_, err := tx.Exec(ctx, `UPDATE tracks SET position = -1 * position - 1000000 WHERE id = $1`, t.ID)
-			if err != nil {
-				log.Printf("playlist-service: vote track temp move: %v", err)
-				writeError(w, http.StatusInternalServerError, "database error")
-				return
-			}
-		}
-
-		// Step 2: Set correct order
-		for i, t := range tracks {
-			newPos := startPos + i
-			_, err := tx.Exec(ctx, `UPDATE tracks SET position = $1 WHERE id = $2`, newPos, t.ID)
-			if err != nil {
-				log.Printf("playlist-service: vote track final move: %v", err)
-				writeError(w, http.StatusInternalServerError, "database error")
-				return
-			}
-		}
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		log.Printf("playlist-service: vote track commit: %v", err)
+		log.Printf("playlist-service: vote track: %v", err)
 		writeError(w, http.StatusInternalServerError, "database error")
 		return
 	}
@@ -212,11 +51,11 @@ func (s *Server) handleVoteTrack(w http.ResponseWriter, r *http.Request) {
 		"payload": map[string]any{
 			"playlistId": playlistID,
 			"trackId":    trackID,
-			"voteCount":  newVoteCount,
+			"voteCount":  tr.VoteCount,
 		},
 	})
 
-	if status == "queued" {
+	if tr.Status == "queued" {
 		s.publishEvent(ctx, map[string]any{
 			"type": "playlist.reordered",
 			"payload": map[string]any{
@@ -225,5 +64,44 @@ func (s *Server) handleVoteTrack(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{"voteCount": newVoteCount})
+	writeJSON(w, http.StatusOK, map[string]any{"voteCount": tr.VoteCount})
+}
+
+var errAlreadyVoted = errors.New("already voted")
+
+// queuedTrack is the sort information of a queued track.
+type queuedTrack struct {
+	ID        string
+	VoteCount int
+	CreatedAt time.Time
+	SortKey   int64
+	Source    string
+}
+
+// singleMove reports whether desired is current with only movedID relocated
+// and every other queued track above floorKey. It returns the index of
+// movedID in desired, or -1 when nothing has to move.
+func singleMove(current, desired []queuedTrack, movedID string, floorKey int64) (int, bool) {
+	target := -1
+	ci := 0
+	for di, t := range desired {
+		if t.ID == movedID {
+			target = di
+			continue
+		}
+		if ci < len(current) && current[ci].ID == movedID {
+			ci++
+		}
+		if ci >= len(current) || current[ci].ID != t.ID || current[ci].SortKey <= floorKey {
+			return 0, false
+		}
+		ci++
+	}
+	if target < 0 {
+		return 0, false
+	}
+	if target < len(current) && current[target].ID == movedID && current[target].SortKey > floorKey {
+		return -1, true
+	}
+	return target, true
 }
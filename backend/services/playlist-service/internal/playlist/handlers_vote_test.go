@@ -28,6 +28,9 @@ func TestHandleVoteTrack_Success(t *testing.T) {
 
 	// 1. Access Check
 	mockDB.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+		if row, ok := moderationStateRow(sql); ok {
+			return row
+		}
 		if strings.Contains(sql, "FROM playlists") {
 			return &MockRow{ScanFunc: func(dest ...any) error {
 				*dest[0].(*string) = "user-1" // Owner matches request user
@@ -127,6 +130,9 @@ func TestHandleVoteTrack_Errors(t *testing.T) {
 			userID:     "user-1",
 			mockSetup: func(m *MockDB) {
 				m.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+					if row, ok := moderationStateRow(sql); ok {
+						return row
+					}
 					if strings.Contains(sql, "FROM playlists") {
 						return &MockRow{ScanFunc: func(dest ...any) error {
 							*dest[0].(*string) = "user-1" // Owner
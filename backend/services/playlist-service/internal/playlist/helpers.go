@@ -5,37 +5,152 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"net/http"
 
-	"github.com/jackc/pgx/v5"
 	"github.com/redis/go-redis/v9"
 )
 
 func (s *Server) getPlaylistAccessInfo(ctx context.Context, playlistID string) (ownerID string, isPublic bool, editMode string, err error) {
-	err = s.db.QueryRow(ctx, `
-		SELECT owner_id, is_public, edit_mode
-		FROM playlists
-		WHERE id = $1
-	`, playlistID).Scan(&ownerID, &isPublic, &editMode)
-	return
+	return s.store.PlaylistAccess(ctx, playlistID)
 }
 
 func (s *Server) userIsInvited(ctx context.Context, playlistID, userID string) (bool, error) {
-	if userID == "" {
-		return false, nil
-	}
-	var uid string
-	err := s.db.QueryRow(ctx, `
-		SELECT user_id
-		FROM playlist_members
-		WHERE playlist_id = $1 AND user_id = $2
-	`, playlistID, userID).Scan(&uid)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return false, nil
+	role, err := s.memberRole(ctx, playlistID, userID)
+	return role != "", err
+}
+
+// memberRole returns the role of a playlist member, or "" for non-members.
+func (s *Server) memberRole(ctx context.Context, playlistID, userID string) (string, error) {
+	return s.store.MemberRole(ctx, playlistID, userID)
+}
+
+// httpError carries the HTTP status a handler should respond with.
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+// writeHTTPError responds with the status of an *httpError, or logs err and
+// responds 500 otherwise.
+func writeHTTPError(w http.ResponseWriter, err error, op string) {
+	var he *httpError
+	if errors.As(err, &he) {
+		writeError(w, he.status, he.msg)
+		return
+	}
+	log.Printf("playlist-service: %s: %v", op, err)
+	writeError(w, http.StatusInternalServerError, "database error")
+}
+
+// errEditDenied is returned by checkEditAccess to users the playlist
+// visibility or EditMode rules keep from editing it.
+var errEditDenied = &httpError{status: http.StatusForbidden, msg: "forbidden"}
+
+// checkEditAccess applies the playlist visibility and EditMode rules, bans
+// and, unless the user moderates the playlist, the lock on target: lockQueue
+// for changes to the track list, lockVoting for votes and "" for none.
+// Changes to the track list also obey the edit license at loc, and nobody
+// makes them by hand on smart playlists. It reports whether the user
+// moderates the playlist (see isModerator). Denials are returned as
+// *httpError; users who may not edit get errEditDenied when target is "".
+func (s *Server) checkEditAccess(ctx context.Context, playlistID, userID, target string, loc clientLocation) (bool, error) {
+	ownerID, isPublic, editMode, err := s.getPlaylistAccessInfo(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		return false, &httpError{status: http.StatusNotFound, msg: "playlist not found"}
 	}
 	if err != nil {
 		return false, err
 	}
-	return true, nil
+
+	if userID == ownerID {
+		// The owner can be neither banned nor locked out.
+		if target == lockQueue {
+			return true, s.checkNotSmart(ctx, playlistID)
+		}
+		return true, nil
+	}
+
+	role, err := s.memberRole(ctx, playlistID, userID)
+	if err != nil {
+		return false, err
+	}
+	if !isPublic && role == "" {
+		return false, errEditDenied
+	}
+	if editModeNeedsRole(editMode) && !roleCanEdit(role) {
+		switch {
+		case target == lockVoting:
+			return false, &httpError{status: http.StatusForbidden, msg: "this playlist requires an invitation to vote"}
+		case target == lockQueue && editMode == editModeSuggest:
+			return false, &httpError{status: http.StatusForbidden, msg: "only editors can change the track list; suggest tracks instead"}
+		}
+		return false, errEditDenied
+	}
+
+	moderator := isModerator(userID, ownerID, role)
+	if target == lockQueue {
+		if err := s.checkNotSmart(ctx, playlistID); err != nil {
+			return false, err
+		}
+	}
+	if err := s.checkRestrictions(ctx, playlistID, userID, moderator, target); err != nil {
+		return false, err
+	}
+	if target == lockQueue {
+		if err := s.checkEditLicense(ctx, playlistID, moderator, loc); err != nil {
+			return false, err
+		}
+	}
+	return moderator, nil
+}
+
+// checkOwnerAccess allows only the playlist owner. Denials are returned as
+// *httpError.
+func (s *Server) checkOwnerAccess(ctx context.Context, playlistID, userID string) error {
+	ownerID, _, _, err := s.getPlaylistAccessInfo(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		return &httpError{status: http.StatusNotFound, msg: "playlist not found"}
+	}
+	if err != nil {
+		return err
+	}
+	if userID != ownerID {
+		return &httpError{status: http.StatusForbidden, msg: "forbidden"}
+	}
+	return nil
+}
+
+// checkViewAccess applies the playlist visibility rule: public playlists are
+// visible to everyone, friends-only ones also to the owner's friends and
+// private ones only to the owner and invited users.
+func (s *Server) checkViewAccess(ctx context.Context, playlistID, userID string) error {
+	ownerID, isPublic, _, err := s.getPlaylistAccessInfo(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		return &httpError{status: http.StatusNotFound, msg: "playlist not found"}
+	}
+	if err != nil {
+		return err
+	}
+	if isPublic || userID == ownerID {
+		return nil
+	}
+
+	invited, err := s.userIsInvited(ctx, playlistID, userID)
+	if err != nil || invited {
+		return err
+	}
+	friend, err := s.viewableAsFriend(ctx, playlistID, userID)
+	if err != nil {
+		return err
+	}
+	if !friend {
+		return &httpError{status: http.StatusForbidden, msg: "playlist is private"}
+	}
+	return nil
 }
 
 func (s *Server) publishEvent(ctx context.Context, event map[string]any) {
@@ -0,0 +1,116 @@
+package playlist
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+	topArtistsLimit     = 10
+)
+
+// parseTimeParam parses an optional RFC 3339 query parameter.
+func parseTimeParam(r *http.Request, name string) (*time.Time, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return nil, false
+	}
+	return &t, true
+}
+
+// GET /playlists/{id}/history?limit=&before=
+// Lists past and current playbacks, most recent first. "before" is the
+// startedAt of the last entry of the previous page.
+func (s *Server) handleListHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	playlistID := chi.URLParam(r, "id")
+	if playlistID == "" {
+		writeError(w, http.StatusBadRequest, "missing playlist id")
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxHistoryLimit {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxHistoryLimit))
+			return
+		}
+		limit = n
+	}
+	before, ok := parseTimeParam(r, "before")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "before must be an RFC 3339 timestamp")
+		return
+	}
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "history access")
+		return
+	}
+
+	entries, err := s.store.ListPlayHistory(ctx, playlistID, before, limit)
+	if err != nil {
+		writeHTTPError(w, err, "list history")
+		return
+	}
+	for i := range entries {
+		if e := &entries[i]; e.EndedAt != nil {
+			played := int(e.EndedAt.Sub(e.StartedAt).Milliseconds())
+			e.PlayedMs = &played
+		}
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// GET /playlists/{id}/history/stats?since=&until=
+// Aggregates the play history over an optional time window. The skip rate
+// only counts playbacks that finished or were skipped; removed tracks and
+// the one currently playing are left out.
+func (s *Server) handleHistoryStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	playlistID := chi.URLParam(r, "id")
+	if playlistID == "" {
+		writeError(w, http.StatusBadRequest, "missing playlist id")
+		return
+	}
+
+	since, ok := parseTimeParam(r, "since")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+		return
+	}
+	until, ok := parseTimeParam(r, "until")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "until must be an RFC 3339 timestamp")
+		return
+	}
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "history stats access")
+		return
+	}
+
+	stats, err := s.store.PlayStats(ctx, playlistID, since, until, topArtistsLimit)
+	if err != nil {
+		writeHTTPError(w, err, "history stats")
+		return
+	}
+	if ended := stats.Finished + stats.Skipped; ended > 0 {
+		stats.SkipRate = float64(stats.Skipped) / float64(ended)
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,249 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func publicPlaylistRow(sql string) pgx.Row {
+	if strings.Contains(sql, "SELECT owner_id, is_public, edit_mode") {
+		return &MockRow{ScanFunc: func(dest ...any) error {
+			*dest[0].(*string) = "owner-1"
+			*dest[1].(*bool) = true
+			*dest[2].(*string) = editModeEveryone
+			return nil
+		}}
+	}
+	return nil
+}
+
+func TestNextTrack_RecordsHistory(t *testing.T) {
+	mockDB := &MockDB{}
+	srv := NewServer(mockDB, nil)
+
+	var ended, started bool
+	mockDB.BeginTxFunc = func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+		return &MockTx{
+			QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+				if strings.Contains(sql, "current_track_id FROM playlists") {
+					return &MockRow{ScanFunc: func(dest ...any) error {
+						current := "track-old"
+						*dest[0].(**string) = &current
+						return nil
+					}}
+				}
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "track-new"
+					*dest[1].(*int) = 180000
+					return nil
+				}}
+			},
+			ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+				if strings.Contains(sql, "UPDATE play_history") {
+					ended = true
+					if args[1] != "track-old" || args[3] != playEndFinished {
+						t.Errorf("unexpected history end args: %v", args)
+					}
+				}
+				if strings.Contains(sql, "INSERT INTO play_history") {
+					started = true
+					if args[0] != "track-new" {
+						t.Errorf("unexpected history start args: %v", args)
+					}
+				}
+				return pgconn.CommandTag{}, nil
+			},
+			CommitFunc: func(ctx context.Context) error { return nil },
+		}, nil
+	}
+
+	if _, err := srv.NextTrack(context.Background(), "pl-1", playEndFinished); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ended || !started {
+		t.Errorf("expected history to be closed and opened, got ended=%v started=%v", ended, started)
+	}
+}
+
+func TestHandleListHistory(t *testing.T) {
+	mockDB := &MockDB{}
+	srv := NewServer(mockDB, nil)
+
+	start := time.Date(2024, 5, 1, 21, 0, 0, 0, time.UTC)
+	end := start.Add(90 * time.Second)
+
+	mockDB.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+		if row := publicPlaylistRow(sql); row != nil {
+			return row
+		}
+		return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected query") }}
+	}
+	mockDB.QueryFunc = func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		if !strings.Contains(sql, "FROM play_history") {
+			return nil, errors.New("unexpected query: " + sql)
+		}
+		if args[2] != 2 {
+			t.Errorf("expected limit 2, got %v", args[2])
+		}
+		return &MockRows{
+			Data: [][]any{
+				{"h-2", "pl-1", "t-2", "Song B", "Artist", "", "", 200000, 1, end, nil, nil},
+				{"h-1", "pl-1", "t-1", "Song A", "Artist", "", "", 180000, 3, start, end, playEndSkipped},
+			},
+			Idx: -1,
+		}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/playlists/pl-1/history?limit=2", nil)
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var entries []PlayHistoryEntry
+	if err := json.NewDecoder(w.Body).Decode(&entries); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].EndedAt != nil || entries[0].PlayedMs != nil {
+		t.Errorf("expected open entry for the playing track, got %+v", entries[0])
+	}
+	if entries[1].PlayedMs == nil || *entries[1].PlayedMs != 90000 {
+		t.Errorf("expected playedMs 90000, got %v", entries[1].PlayedMs)
+	}
+	if entries[1].EndReason == nil || *entries[1].EndReason != playEndSkipped {
+		t.Errorf("expected skipped end reason, got %v", entries[1].EndReason)
+	}
+}
+
+func TestHandleListHistory_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		row      func(sql string) pgx.Row
+		wantCode int
+	}{
+		{
+			name:     "Invalid limit",
+			url:      "/playlists/pl-1/history?limit=0",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid before",
+			url:      "/playlists/pl-1/history?before=yesterday",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "Playlist not found",
+			url:  "/playlists/pl-1/history",
+			row: func(sql string) pgx.Row {
+				return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name: "Private playlist",
+			url:  "/playlists/pl-1/history",
+			row: func(sql string) pgx.Row {
+				if strings.Contains(sql, "FROM playlist_members") {
+					return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+				}
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "owner-1"
+					*dest[1].(*bool) = false
+					*dest[2].(*string) = editModeInvited
+					return nil
+				}}
+			},
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := &MockDB{}
+			if tt.row != nil {
+				mockDB.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+					return tt.row(sql)
+				}
+			}
+			srv := NewServer(mockDB, nil)
+
+			req := httptest.NewRequest("GET", tt.url, nil)
+			req.Header.Set("X-User-Id", "outsider")
+			w := httptest.NewRecorder()
+			srv.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("expected %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleHistoryStats(t *testing.T) {
+	mockDB := &MockDB{}
+	srv := NewServer(mockDB, nil)
+
+	mockDB.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+		if row := publicPlaylistRow(sql); row != nil {
+			return row
+		}
+		if strings.Contains(sql, "FROM play_history") {
+			if args[1] == nil {
+				t.Errorf("expected since to be passed")
+			}
+			return &MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*int) = 5
+				*dest[1].(*int) = 3
+				*dest[2].(*int) = 1
+				*dest[3].(*float64) = 2.4
+				*dest[4].(*int64) = 600000
+				return nil
+			}}
+		}
+		return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected query") }}
+	}
+	mockDB.QueryFunc = func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		if strings.Contains(sql, "GROUP BY artist") {
+			return &MockRows{
+				Data: [][]any{{"Daft Punk", 3}, {"Justice", 2}},
+				Idx:  -1,
+			}, nil
+		}
+		return nil, errors.New("unexpected query: " + sql)
+	}
+
+	req := httptest.NewRequest("GET", "/playlists/pl-1/history/stats?since=2024-05-01T20:00:00Z", nil)
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stats PlayStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if stats.TotalPlays != 5 || stats.Skipped != 1 || stats.Finished != 3 {
+		t.Errorf("unexpected counts: %+v", stats)
+	}
+	if stats.SkipRate != 0.25 {
+		t.Errorf("expected skip rate 0.25, got %v", stats.SkipRate)
+	}
+	if len(stats.TopArtists) != 2 || stats.TopArtists[0].Artist != "Daft Punk" || stats.TopArtists[0].Plays != 3 {
+		t.Errorf("unexpected top artists: %+v", stats.TopArtists)
+	}
+}
@@ -135,26 +135,10 @@ func TestVotingAndPlaybackFlow(t *testing.T) {
 	callNextTrack(t, router, userID, playlistID, trackB.ID, "playing")
 
 	// Verify Order in GET:
-	// Played/Playing tracks are sometimes returned at top or bottom?
-	// `handlers_playlists.go` orders by `position ASC`.
-	// Our `next_track` logic does existing `stopped` -> `played`.
-	// Does it change position of the playing track?
-	// `handleNextTrack`:
-	// 	  UPDATE tracks SET status = 'playing' WHERE id = ...
-	//    Does NOT change position.
-	// `handleVoteTrack`:
-	//    Only selects `status = 'queued'`.
-	//    Only re-orders queued tracks.
-	//    It puts them AFTER existing playing/played tracks?
-	//    `SELECT COALESCE(MAX(position) + 1, 0) FROM tracks WHERE ... status != 'queued'`
-	//    So queued tracks start AFTER playing/played.
-	// Since B was at pos 0, and became playing, it stays at pos 0 (or close).
-	// Queued tracks (C, A) are re-ordered after B.
-
-	// Actually, `handleVoteTrack` recalculates positions for ALL queued tracks starting from `startPos`.
-	// `startPos` = MAX(position of non-queued) + 1.
-	// If B is playing, it is non-queued. Its position is likely 0 (from before).
-	// So queued tracks C and A will start at 1.
+	// `handlers_playlists.go` orders by `sort_key, id`.
+	// `NextTrack` only changes the status of B, not its sort key, so B keeps
+	// its place at the top. `reorderQueuedByVotes` only re-ranks queued tracks
+	// and keeps them after the last playing/played track, so C and A follow B.
 
 	// Let's verify status of B is playing.
 	checkTrackStatus(t, pool, trackB.ID, "playing")
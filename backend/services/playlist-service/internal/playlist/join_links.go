@@ -0,0 +1,219 @@
+package playlist
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// Join codes are short enough to type from a venue screen. The first
+	// character tells the api-gateway which service minted the code
+	// (vote-service uses "E" for events).
+	joinCodePrefix   = "P"
+	joinCodeLength   = 8
+	joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O, 1/I
+
+	maxJoinLinkUses = 10000
+	maxJoinLinkTTL  = 30 * 24 * time.Hour
+
+	// joinCodeAttempts bounds retries on the (unlikely) code collision.
+	joinCodeAttempts = 3
+)
+
+// newJoinCode returns a random code made of joinCodePrefix and
+// joinCodeAlphabet characters.
+func newJoinCode() (string, error) {
+	buf := make([]byte, joinCodeLength-len(joinCodePrefix))
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := []byte(joinCodePrefix)
+	for _, b := range buf {
+		// len(joinCodeAlphabet) divides 256, so there is no modulo bias.
+		code = append(code, joinCodeAlphabet[int(b)%len(joinCodeAlphabet)])
+	}
+	return string(code), nil
+}
+
+// normalizeJoinCode accepts codes typed in lower case or with separators.
+func normalizeJoinCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// errBannedFromPlaylist is returned by RedeemJoinLink for banned users.
+var errBannedFromPlaylist = errors.New("banned from playlist")
+
+// handleCreateJoinLink mints a join code for a playlist. Owner only.
+// POST /playlists/{id}/join-links
+func (s *Server) handleCreateJoinLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	var body struct {
+		Role           *string `json:"role"`           // optional, default "editor"
+		MaxUses        *int    `json:"maxUses"`        // optional, unlimited
+		ExpiresInHours *int    `json:"expiresInHours"` // optional, never
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+	}
+
+	role := memberRoleEditor
+	if body.Role != nil {
+		role = strings.ToLower(strings.TrimSpace(*body.Role))
+		if role != memberRoleEditor && role != memberRoleViewer {
+			writeError(w, http.StatusBadRequest, `invalid role (must be "editor" or "viewer")`)
+			return
+		}
+	}
+	if body.MaxUses != nil && (*body.MaxUses < 1 || *body.MaxUses > maxJoinLinkUses) {
+		writeError(w, http.StatusBadRequest, "maxUses must be between 1 and 10000")
+		return
+	}
+	var expiresAt *time.Time
+	if body.ExpiresInHours != nil {
+		ttl := time.Duration(*body.ExpiresInHours) * time.Hour
+		if ttl <= 0 || ttl > maxJoinLinkTTL {
+			writeError(w, http.StatusBadRequest, "expiresInHours must be between 1 and 720")
+			return
+		}
+		t := time.Now().Add(ttl)
+		expiresAt = &t
+	}
+
+	if err := s.checkOwnerAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "create join link")
+		return
+	}
+
+	link := JoinLink{PlaylistID: playlistID, CreatedBy: userID, Role: role, MaxUses: body.MaxUses, ExpiresAt: expiresAt}
+	if err := s.store.CreateJoinLink(ctx, &link); err != nil {
+		log.Printf("playlist-service: create join link: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, link)
+}
+
+// handleListJoinLinks lists the join codes of a playlist, newest first,
+// including revoked and used-up ones. Owner only.
+// GET /playlists/{id}/join-links
+func (s *Server) handleListJoinLinks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkOwnerAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "list join links")
+		return
+	}
+
+	links, err := s.store.ListJoinLinks(ctx, playlistID)
+	if err != nil {
+		log.Printf("playlist-service: list join links: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, links)
+}
+
+// handleRevokeJoinLink stops a join code from being redeemed. Members who
+// already joined with it stay. Owner only.
+// DELETE /playlists/{id}/join-links/{code}
+func (s *Server) handleRevokeJoinLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+	code := normalizeJoinCode(chi.URLParam(r, "code"))
+
+	if err := s.checkOwnerAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "revoke join link")
+		return
+	}
+
+	err := s.store.RevokeJoinLink(ctx, playlistID, code)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "join link not found")
+		return
+	}
+	if err != nil {
+		log.Printf("playlist-service: revoke join link: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleJoin redeems a join code: the caller becomes a member with the
+// code's role. The owner and existing members keep their access and do not
+// use up the code.
+// POST /join/{code}
+func (s *Server) handleJoin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	code := normalizeJoinCode(chi.URLParam(r, "code"))
+
+	res, err := s.store.RedeemJoinLink(ctx, code, userID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "join code not found or no longer valid")
+		return
+	}
+	if errors.Is(err, errBannedFromPlaylist) {
+		writeError(w, http.StatusForbidden, "you are banned from this playlist")
+		return
+	}
+	if err != nil {
+		log.Printf("playlist-service: join: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if res.Joined {
+		s.publishEvent(ctx, map[string]any{
+			"type": "playlist.member_joined",
+			"payload": map[string]any{
+				"playlistId": res.ID,
+				"userId":     userID,
+				"role":       res.Role,
+			},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,318 @@
+package playlist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestNewJoinCode(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		code, err := newJoinCode()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(code) != joinCodeLength || !strings.HasPrefix(code, joinCodePrefix) {
+			t.Fatalf("unexpected code %q", code)
+		}
+		for _, c := range code[1:] {
+			if !strings.ContainsRune(joinCodeAlphabet, c) {
+				t.Fatalf("code %q has character %q outside the alphabet", code, c)
+			}
+		}
+		seen[code] = true
+	}
+	if len(seen) < 100 {
+		t.Errorf("expected distinct codes, got %d of 100", len(seen))
+	}
+}
+
+func TestNormalizeJoinCode(t *testing.T) {
+	if got := normalizeJoinCode("pab-cd 2345"); got != "PABCD2345" {
+		t.Errorf("normalizeJoinCode() = %q", got)
+	}
+}
+
+func ownerRow(ownerID string) pgx.Row {
+	return &MockRow{ScanFunc: func(dest ...any) error {
+		*dest[0].(*string) = ownerID
+		*dest[1].(*bool) = false
+		*dest[2].(*string) = editModeInvited
+		return nil
+	}}
+}
+
+func TestHandleCreateJoinLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		body     string
+		wantCode int
+	}{
+		{name: "Defaults", userID: "owner-1", body: `{}`, wantCode: http.StatusCreated},
+		{name: "Viewer With Limits", userID: "owner-1", body: `{"role":"viewer","maxUses":50,"expiresInHours":4}`, wantCode: http.StatusCreated},
+		{name: "Invalid Role", userID: "owner-1", body: `{"role":"owner"}`, wantCode: http.StatusBadRequest},
+		{name: "Invalid Max Uses", userID: "owner-1", body: `{"maxUses":0}`, wantCode: http.StatusBadRequest},
+		{name: "Not Owner", userID: "u-2", body: `{}`, wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var insertArgs []any
+			mockDB := &MockDB{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+					if strings.Contains(sql, "INSERT INTO playlist_join_links") {
+						insertArgs = args
+						return &MockRow{ScanFunc: func(dest ...any) error {
+							*dest[0].(*string) = args[0].(string)
+							*dest[1].(*string) = args[1].(string)
+							*dest[2].(*string) = args[2].(string)
+							*dest[3].(*string) = args[3].(string)
+							*dest[4].(**int) = args[4].(*int)
+							*dest[6].(**time.Time) = args[5].(*time.Time)
+							*dest[8].(*time.Time) = time.Now()
+							return nil
+						}}
+					}
+					return ownerRow("owner-1")
+				},
+			}
+			srv := NewServer(mockDB, nil)
+
+			req := httptest.NewRequest("POST", "/playlists/pl-1/join-links", strings.NewReader(tt.body))
+			req.Header.Set("X-User-Id", tt.userID)
+			w := httptest.NewRecorder()
+			srv.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d. Body: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if tt.wantCode != http.StatusCreated {
+				if insertArgs != nil {
+					t.Error("expected no join link to be created")
+				}
+				return
+			}
+			var link JoinLink
+			_ = json.NewDecoder(w.Body).Decode(&link)
+			if !strings.HasPrefix(link.Code, joinCodePrefix) || link.PlaylistID != "pl-1" || link.CreatedBy != "owner-1" {
+				t.Errorf("unexpected link: %+v", link)
+			}
+			if tt.name == "Viewer With Limits" &&
+				(link.Role != memberRoleViewer || link.MaxUses == nil || *link.MaxUses != 50 || link.ExpiresAt == nil) {
+				t.Errorf("limits not applied: %+v", link)
+			}
+		})
+	}
+}
+
+func TestHandleCreateJoinLink_RetriesOnCollision(t *testing.T) {
+	var codes []string
+	mockDB := &MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if strings.Contains(sql, "INSERT INTO playlist_join_links") {
+				codes = append(codes, args[0].(string))
+				if len(codes) == 1 {
+					return &MockRow{ScanFunc: func(dest ...any) error { return &pgconn.PgError{Code: "23505"} }}
+				}
+				return &MockRow{}
+			}
+			return ownerRow("owner-1")
+		},
+	}
+	srv := NewServer(mockDB, nil)
+
+	req := httptest.NewRequest("POST", "/playlists/pl-1/join-links", nil)
+	req.Header.Set("X-User-Id", "owner-1")
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if len(codes) != 2 {
+		t.Errorf("expected a second attempt, got %v", codes)
+	}
+}
+
+func TestHandleRevokeJoinLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected string
+		wantCode int
+	}{
+		{name: "Revoked", affected: "UPDATE 1", wantCode: http.StatusNoContent},
+		{name: "Unknown", affected: "UPDATE 0", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := &MockDB{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+					return ownerRow("owner-1")
+				},
+				ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+					if !strings.Contains(sql, "revoked_at") || args[1] != "PABCD234" {
+						t.Errorf("unexpected exec: %s %v", sql, args)
+					}
+					return pgconn.NewCommandTag(tt.affected), nil
+				},
+			}
+			srv := NewServer(mockDB, nil)
+
+			req := httptest.NewRequest("DELETE", "/playlists/pl-1/join-links/pabcd234", nil)
+			req.Header.Set("X-User-Id", "owner-1")
+			w := httptest.NewRecorder()
+			srv.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleJoin(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     string
+		memberRole string
+		lookupErr  error
+		wantCode   int
+		wantResult JoinResult
+		wantUse    bool
+	}{
+		{
+			name:       "Joins With Code Role",
+			userID:     "u-2",
+			wantCode:   http.StatusOK,
+			wantResult: JoinResult{Type: "playlist", ID: "pl-1", Role: memberRoleViewer, Joined: true},
+			wantUse:    true,
+		},
+		{
+			name:       "Existing Member Keeps Role",
+			userID:     "u-2",
+			memberRole: memberRoleEditor,
+			wantCode:   http.StatusOK,
+			wantResult: JoinResult{Type: "playlist", ID: "pl-1", Role: memberRoleEditor},
+		},
+		{
+			name:       "Owner",
+			userID:     "owner-1",
+			wantCode:   http.StatusOK,
+			wantResult: JoinResult{Type: "playlist", ID: "pl-1", Role: "owner"},
+		},
+		{
+			name:      "Invalid Code",
+			userID:    "u-2",
+			lookupErr: pgx.ErrNoRows,
+			wantCode:  http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			used, inserted, committed := false, false, false
+			mockDB := &MockDB{
+				BeginTxFunc: func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+					return &MockTx{
+						QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+							if strings.Contains(sql, "UPDATE playlist_join_links") {
+								used = true
+								return &MockRow{ScanFunc: func(dest ...any) error {
+									*dest[0].(*string) = memberRoleViewer
+									return nil
+								}}
+							}
+							if args[0] != "PABCD234" {
+								t.Errorf("expected normalized code, got %v", args[0])
+							}
+							return &MockRow{ScanFunc: func(dest ...any) error {
+								if tt.lookupErr != nil {
+									return tt.lookupErr
+								}
+								*dest[0].(*string) = "pl-1"
+								*dest[1].(*string) = "owner-1"
+								*dest[2].(*string) = tt.memberRole
+								return nil
+							}}
+						},
+						ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+							if strings.Contains(sql, "INSERT INTO playlist_members") && args[2] == memberRoleViewer {
+								inserted = true
+							}
+							return pgconn.CommandTag{}, nil
+						},
+						CommitFunc: func(ctx context.Context) error {
+							committed = true
+							return nil
+						},
+					}, nil
+				},
+			}
+			srv := NewServer(mockDB, nil)
+
+			req := httptest.NewRequest("POST", "/join/pabcd-234", bytes.NewReader(nil))
+			req.Header.Set("X-User-Id", tt.userID)
+			w := httptest.NewRecorder()
+			srv.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d. Body: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if used != tt.wantUse || inserted != tt.wantUse || committed != tt.wantUse {
+				t.Errorf("used=%v inserted=%v committed=%v, want %v", used, inserted, committed, tt.wantUse)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var got JoinResult
+			_ = json.NewDecoder(w.Body).Decode(&got)
+			if got != tt.wantResult {
+				t.Errorf("got %+v, want %+v", got, tt.wantResult)
+			}
+		})
+	}
+}
+
+func TestHandleJoin_CodeUsedUpConcurrently(t *testing.T) {
+	mockDB := &MockDB{
+		BeginTxFunc: func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+			return &MockTx{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+					if strings.Contains(sql, "UPDATE playlist_join_links") {
+						return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+					}
+					return &MockRow{ScanFunc: func(dest ...any) error {
+						*dest[0].(*string) = "pl-1"
+						*dest[1].(*string) = "owner-1"
+						*dest[2].(*string) = ""
+						return nil
+					}}
+				},
+				ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+					return pgconn.CommandTag{}, errors.New("unexpected exec: " + sql)
+				},
+			}, nil
+		},
+	}
+	srv := NewServer(mockDB, nil)
+
+	req := httptest.NewRequest("POST", "/join/PABCD234", nil)
+	req.Header.Set("X-User-Id", "u-2")
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+}
@@ -30,7 +30,7 @@ func AutoMigrate(ctx context.Context, pool *pgxpool.Pool) error {
           playlist_id uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
           title       TEXT NOT NULL,
           artist      TEXT NOT NULL,
-          position    INT NOT NULL,
+          sort_key    BIGINT NOT NULL,
           provider    TEXT NOT NULL DEFAULT '',
           provider_track_id TEXT NOT NULL DEFAULT '',
           thumbnail_url TEXT NOT NULL DEFAULT '',
@@ -62,13 +62,225 @@ func AutoMigrate(ctx context.Context, pool *pgxpool.Pool) error {
 		return err
 	}
 
+	// 3. Duplicate track policy
 	if _, err := pool.Exec(ctx, `
-      CREATE UNIQUE INDEX IF NOT EXISTS idx_tracks_playlist_position
-      ON tracks(playlist_id, position)
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS duplicate_policy TEXT NOT NULL DEFAULT 'allow';
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS fuzzy_duplicates BOOLEAN NOT NULL DEFAULT FALSE;
+	`); err != nil {
+		return err
+	}
+
+	// Full-text search on playlist name/description (see buildListPlaylistsSQL).
+	if _, err := pool.Exec(ctx, `
+      CREATE INDEX IF NOT EXISTS idx_playlists_search
+      ON playlists USING GIN (to_tsvector('simple', name || ' ' || description))
     `); err != nil {
 		return err
 	}
 
+	// 4. Sparse sort keys replace dense positions (see
+	// postgres_ordering.go). Existing positions are converted once and the
+	// column, with its unique index, is dropped.
+	if _, err := pool.Exec(ctx, `
+		ALTER TABLE tracks ADD COLUMN IF NOT EXISTS sort_key BIGINT;
+		DO $$
+		BEGIN
+			IF EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_name = 'tracks' AND column_name = 'position'
+			) THEN
+				UPDATE tracks SET sort_key = (position + 1)::bigint * 65536 WHERE sort_key IS NULL;
+				ALTER TABLE tracks DROP COLUMN position;
+			END IF;
+		END $$;
+		ALTER TABLE tracks ALTER COLUMN sort_key SET NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_tracks_playlist_sort_key ON tracks(playlist_id, sort_key, id);
+	`); err != nil {
+		return err
+	}
+
+	// 5. Radio mode
+	if _, err := pool.Exec(ctx, `
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS radio_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS radio_min_queue INT NOT NULL DEFAULT 3;
+		ALTER TABLE tracks ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'user';
+	`); err != nil {
+		return err
+	}
+
+	// 6. Provider lookups: resolved_at is set once metadata was checked
+	// against the music provider, resolving_at while a backfill has claimed
+	// the track (see resolve.go).
+	if _, err := pool.Exec(ctx, `
+		ALTER TABLE tracks ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMPTZ;
+		ALTER TABLE tracks ADD COLUMN IF NOT EXISTS resolving_at TIMESTAMPTZ;
+		ALTER TABLE tracks ADD COLUMN IF NOT EXISTS unavailable BOOLEAN NOT NULL DEFAULT FALSE;
+		CREATE INDEX IF NOT EXISTS idx_tracks_unresolved ON tracks(created_at)
+		WHERE resolved_at IS NULL AND provider_track_id <> '';
+	`); err != nil {
+		return err
+	}
+
+	// 7. Trash: deleted playlists keep their rows until the purge job removes
+	// them after the retention period (see trash.go).
+	if _, err := pool.Exec(ctx, `
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+		CREATE INDEX IF NOT EXISTS idx_playlists_trash ON playlists(owner_id, deleted_at DESC)
+		WHERE deleted_at IS NOT NULL;
+	`); err != nil {
+		return err
+	}
+
+	// 8. Moderation: who queued each track, and temporary queue/voting locks
+	// (see moderation.go).
+	if _, err := pool.Exec(ctx, `
+		ALTER TABLE tracks ADD COLUMN IF NOT EXISTS added_by TEXT NOT NULL DEFAULT '';
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS queue_locked_until TIMESTAMPTZ;
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS voting_locked_until TIMESTAMPTZ;
+	`); err != nil {
+		return err
+	}
+
+	// 9. Content rules (see content_rules.go), stored as a ContentRules
+	// document.
+	if _, err := pool.Exec(ctx, `
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS content_rules JSONB NOT NULL DEFAULT '{}';
+	`); err != nil {
+		return err
+	}
+
+	// 10. Suggestions of "suggest" playlists (see suggestions.go). track_id
+	// is the queued track of an approved suggestion and is kept after the
+	// track is deleted.
+	if _, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS playlist_suggestions (
+			id                uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+			playlist_id       uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			suggested_by      TEXT NOT NULL,
+			title             TEXT NOT NULL,
+			artist            TEXT NOT NULL DEFAULT '',
+			provider          TEXT NOT NULL DEFAULT '',
+			provider_track_id TEXT NOT NULL DEFAULT '',
+			thumbnail_url     TEXT NOT NULL DEFAULT '',
+			duration_ms       INT NOT NULL DEFAULT 0,
+			status            TEXT NOT NULL DEFAULT 'pending',
+			reason            TEXT NOT NULL DEFAULT '',
+			decided_by        TEXT NOT NULL DEFAULT '',
+			decided_at        TIMESTAMPTZ,
+			track_id          TEXT NOT NULL DEFAULT '',
+			created_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS idx_playlist_suggestions_playlist
+		ON playlist_suggestions(playlist_id, status, created_at);
+	`); err != nil {
+		return err
+	}
+
+	// 11. Vote-to-skip (see skip_votes.go): settings, listener presence and
+	// skip votes on the playing track.
+	if _, err := pool.Exec(ctx, `
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS skip_vote_percent INT NOT NULL DEFAULT 50;
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS skip_vote_threshold INT NOT NULL DEFAULT 0;
+
+		CREATE TABLE IF NOT EXISTS playlist_presence (
+			playlist_id uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			user_id     TEXT NOT NULL,
+			seen_at     TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (playlist_id, user_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS track_skip_votes (
+			track_id    uuid NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
+			playlist_id uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			user_id     TEXT NOT NULL,
+			created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (track_id, user_id)
+		);
+	`); err != nil {
+		return err
+	}
+
+	// 12. The attached player of each playlist and its latest heartbeat
+	// (see player.go).
+	if _, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS playlist_players (
+			playlist_id uuid PRIMARY KEY REFERENCES playlists(id) ON DELETE CASCADE,
+			player_id   TEXT NOT NULL,
+			user_id     TEXT NOT NULL,
+			track_id    TEXT NOT NULL DEFAULT '',
+			position_ms INT NOT NULL DEFAULT 0,
+			state       TEXT NOT NULL,
+			error       TEXT NOT NULL DEFAULT '',
+			seen_at     TIMESTAMPTZ NOT NULL
+		);
+	`); err != nil {
+		return err
+	}
+
+	// 13. Playback control delegated by owners (see delegations.go); a NULL
+	// expires_at lasts until revoked.
+	if _, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS playlist_delegations (
+			playlist_id uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			user_id     TEXT NOT NULL,
+			granted_by  TEXT NOT NULL,
+			expires_at  TIMESTAMPTZ,
+			created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (playlist_id, user_id)
+		);
+	`); err != nil {
+		return err
+	}
+
+	// 14. Devices of users and the active output device of each playlist
+	// (see devices.go).
+	if _, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS user_devices (
+			id           uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id      TEXT NOT NULL,
+			name         TEXT NOT NULL,
+			platform     TEXT NOT NULL,
+			capabilities TEXT[] NOT NULL DEFAULT '{}',
+			created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+			last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS idx_user_devices_user ON user_devices(user_id);
+
+		CREATE TABLE IF NOT EXISTS playlist_active_devices (
+			playlist_id uuid PRIMARY KEY REFERENCES playlists(id) ON DELETE CASCADE,
+			device_id   uuid NOT NULL REFERENCES user_devices(id) ON DELETE CASCADE,
+			selected_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+	`); err != nil {
+		return err
+	}
+
+	// 15. Location- and time-restricted editing (see edit_license.go),
+	// stored as an EditLicense document.
+	if _, err := pool.Exec(ctx, `
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS edit_license JSONB NOT NULL DEFAULT '{}';
+	`); err != nil {
+		return err
+	}
+
+	// 16. Friends-only playlists (see visibility.go). is_public stays in
+	// sync with visibility = 'public' for the queries that only tell public
+	// playlists apart.
+	if _, err := pool.Exec(ctx, `
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public';
+		UPDATE playlists SET visibility = 'private' WHERE NOT is_public AND visibility = 'public';
+	`); err != nil {
+		return err
+	}
+
+	// 17. Smart playlists (see smart.go); NULL rules mean a normal playlist.
+	if _, err := pool.Exec(ctx, `
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS smart_rules JSONB;
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS smart_refreshed_at TIMESTAMPTZ;
+	`); err != nil {
+		return err
+	}
+
 	if _, err := pool.Exec(ctx, `
       CREATE TABLE IF NOT EXISTS playlist_members (
           playlist_id uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
@@ -80,6 +292,80 @@ func AutoMigrate(ctx context.Context, pool *pgxpool.Pool) error {
 		return err
 	}
 
+	// Invitations are pending until the invitee accepts (which moves them to
+	// playlist_members) or declines; expired ones are cleaned up.
+	if _, err := pool.Exec(ctx, `
+		ALTER TABLE playlist_members ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'editor';
+		CREATE TABLE IF NOT EXISTS playlist_invitations (
+			playlist_id uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			user_id     TEXT NOT NULL,
+			invited_by  TEXT NOT NULL,
+			role        TEXT NOT NULL DEFAULT 'editor',
+			created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+			expires_at  TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (playlist_id, user_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_playlist_invitations_user ON playlist_invitations(user_id, expires_at);
+	`); err != nil {
+		return err
+	}
+
+	// Join links: shareable codes that add whoever redeems them as a member
+	// (see join_links.go). NULL max_uses / expires_at mean unlimited.
+	if _, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS playlist_join_links (
+			code        TEXT PRIMARY KEY,
+			playlist_id uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			created_by  TEXT NOT NULL,
+			role        TEXT NOT NULL DEFAULT 'editor',
+			max_uses    INT,
+			uses        INT NOT NULL DEFAULT 0,
+			expires_at  TIMESTAMPTZ,
+			revoked_at  TIMESTAMPTZ,
+			created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS idx_playlist_join_links_playlist ON playlist_join_links(playlist_id, created_at DESC);
+	`); err != nil {
+		return err
+	}
+
+	// Follows: users following playlists get their activity in a feed.
+	if _, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS playlist_follows (
+			playlist_id uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			user_id     TEXT NOT NULL,
+			created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (playlist_id, user_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_playlist_follows_user ON playlist_follows(user_id, created_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_tracks_playlist_created ON tracks(playlist_id, created_at DESC);
+	`); err != nil {
+		return err
+	}
+
+	// Play history: one row per playback, closed when the track stops.
+	// Track metadata is copied so entries survive track deletion.
+	if _, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS play_history (
+			id                uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+			playlist_id       uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			track_id          uuid,
+			title             TEXT NOT NULL,
+			artist            TEXT NOT NULL,
+			provider          TEXT NOT NULL DEFAULT '',
+			provider_track_id TEXT NOT NULL DEFAULT '',
+			duration_ms       INT NOT NULL DEFAULT 0,
+			vote_count        INT NOT NULL DEFAULT 0,
+			started_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+			ended_at          TIMESTAMPTZ,
+			end_reason        TEXT
+		);
+		CREATE INDEX IF NOT EXISTS idx_play_history_playlist_started
+		ON play_history(playlist_id, started_at DESC);
+	`); err != nil {
+		return err
+	}
+
 	if _, err := pool.Exec(ctx, `
 		CREATE TABLE IF NOT EXISTS track_votes (
 			track_id uuid NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
@@ -91,5 +377,113 @@ func AutoMigrate(ctx context.Context, pool *pgxpool.Pool) error {
 		return err
 	}
 
+	// Reactions and comments on tracks (see reactions.go). playlist_id is
+	// kept on both so ListTracks can count them per playlist.
+	if _, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS track_reactions (
+			track_id    uuid NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
+			playlist_id uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			user_id     TEXT NOT NULL,
+			emoji       TEXT NOT NULL,
+			created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (track_id, user_id, emoji)
+		);
+		CREATE INDEX IF NOT EXISTS idx_track_reactions_playlist ON track_reactions(playlist_id);
+
+		CREATE TABLE IF NOT EXISTS track_comments (
+			id          uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+			track_id    uuid NOT NULL REFERENCES tracks(id) ON DELETE CASCADE,
+			playlist_id uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			user_id     TEXT NOT NULL,
+			body        TEXT NOT NULL,
+			created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS idx_track_comments_track ON track_comments(track_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_track_comments_playlist ON track_comments(playlist_id);
+	`); err != nil {
+		return err
+	}
+
+	// Bans and the moderation log. Log entries have no foreign key on the
+	// actor or target; they are kept for the lifetime of the playlist.
+	if _, err := pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS playlist_bans (
+			playlist_id uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			user_id     TEXT NOT NULL,
+			banned_by   TEXT NOT NULL,
+			reason      TEXT NOT NULL DEFAULT '',
+			created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (playlist_id, user_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS playlist_moderation_log (
+			id             uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+			playlist_id    uuid NOT NULL REFERENCES playlists(id) ON DELETE CASCADE,
+			actor_id       TEXT NOT NULL,
+			action         TEXT NOT NULL,
+			target_user_id TEXT NOT NULL DEFAULT '',
+			details        TEXT NOT NULL DEFAULT '',
+			created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS idx_playlist_moderation_log_playlist
+		ON playlist_moderation_log(playlist_id, created_at DESC);
+	`); err != nil {
+		return err
+	}
+
+	// Listing sort keys (see buildListPlaylistsSQL): the track count and the
+	// time of the last activity (created, track added or playback started)
+	// are kept up to date by triggers, so listings sort on indexed columns.
+	// Playlists from before are backfilled once.
+	if _, err := pool.Exec(ctx, `
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS track_count INT NOT NULL DEFAULT 0;
+		ALTER TABLE playlists ADD COLUMN IF NOT EXISTS last_activity_at TIMESTAMPTZ;
+
+		CREATE OR REPLACE FUNCTION playlists_track_stats() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'INSERT' THEN
+				UPDATE playlists
+				SET track_count = track_count + 1,
+				    last_activity_at = GREATEST(last_activity_at, NEW.created_at)
+				WHERE id = NEW.playlist_id;
+			ELSE
+				UPDATE playlists SET track_count = track_count - 1 WHERE id = OLD.playlist_id;
+			END IF;
+			RETURN NULL;
+		END $$ LANGUAGE plpgsql;
+		CREATE OR REPLACE TRIGGER tracks_playlist_stats
+		AFTER INSERT OR DELETE ON tracks
+		FOR EACH ROW EXECUTE FUNCTION playlists_track_stats();
+
+		CREATE OR REPLACE FUNCTION playlists_playback_activity() RETURNS trigger AS $$
+		BEGIN
+			NEW.last_activity_at := GREATEST(NEW.last_activity_at, NEW.created_at, NEW.playing_started_at);
+			RETURN NEW;
+		END $$ LANGUAGE plpgsql;
+		CREATE OR REPLACE TRIGGER playlists_playback_activity
+		BEFORE INSERT OR UPDATE OF playing_started_at ON playlists
+		FOR EACH ROW EXECUTE FUNCTION playlists_playback_activity();
+
+		UPDATE playlists p
+		SET track_count = (SELECT COUNT(*) FROM tracks t WHERE t.playlist_id = p.id),
+		    last_activity_at = GREATEST(
+		        p.created_at,
+		        p.playing_started_at,
+		        (SELECT MAX(t.created_at) FROM tracks t WHERE t.playlist_id = p.id)
+		    )
+		WHERE p.last_activity_at IS NULL;
+		ALTER TABLE playlists ALTER COLUMN last_activity_at SET NOT NULL;
+
+		CREATE INDEX IF NOT EXISTS idx_playlists_list_recent ON playlists(created_at, id)
+		WHERE deleted_at IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_playlists_list_tracks ON playlists(track_count, id)
+		WHERE deleted_at IS NULL;
+		DROP INDEX IF EXISTS idx_playlists_list_active;
+		CREATE INDEX IF NOT EXISTS idx_playlists_list_activity ON playlists((COALESCE(last_activity_at, created_at)), id)
+		WHERE deleted_at IS NULL;
+	`); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -3,6 +3,7 @@ package playlist
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -100,7 +101,7 @@ func (m *MockTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows,
 	if m.QueryFunc != nil {
 		return m.QueryFunc(ctx, sql, args...)
 	}
-	return nil, nil // Return nil or empty rows
+	return &MockRows{Idx: -1}, nil
 }
 
 // MockRows Helper for list queries
@@ -133,6 +134,24 @@ func (m *MockRows) Scan(dest ...any) error {
 			*d = v.(bool)
 		case *int:
 			*d = v.(int)
+		case *int64:
+			*d = v.(int64)
+		case *float64:
+			*d = v.(float64)
+		case **string:
+			if v == nil {
+				*d = nil
+			} else {
+				s := v.(string)
+				*d = &s
+			}
+		case **time.Time:
+			if v == nil {
+				*d = nil
+			} else {
+				t := v.(time.Time)
+				*d = &t
+			}
 		}
 	}
 	return nil
@@ -145,3 +164,30 @@ func (m *MockRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
 func (m *MockRows) Values() ([]any, error)                       { return nil, nil }
 func (m *MockRows) RawValues() [][]byte                          { return nil }
 func (m *MockRows) Conn() *pgx.Conn                              { return nil }
+
+// moderationStateRow answers PostgresStore.ModerationState for handlers that
+// check bans and locks: the playlist is unlocked and the user not banned.
+func moderationStateRow(sql string) (pgx.Row, bool) {
+	if !strings.Contains(sql, "queue_locked_until, p.voting_locked_until") {
+		return nil, false
+	}
+	return &MockRow{}, true
+}
+
+// smartRulesRow answers the smart playlist check of handlers that change
+// the track list: the playlist is a normal one.
+func smartRulesRow(sql string) (pgx.Row, bool) {
+	if !strings.Contains(sql, "SELECT smart_rules") {
+		return nil, false
+	}
+	return &MockRow{}, true
+}
+
+// contentRulesRow answers getContentRules for handlers that check content
+// rules: the playlist has none.
+func contentRulesRow(sql string) (pgx.Row, bool) {
+	if !strings.Contains(sql, "SELECT content_rules") {
+		return nil, false
+	}
+	return &MockRow{}, true
+}
@@ -11,11 +11,38 @@ type Playlist struct {
 	OwnerID          string     `json:"ownerId"`
 	Name             string     `json:"name"`
 	Description      string     `json:"description"`
-	IsPublic         bool       `json:"isPublic"`
-	EditMode         string     `json:"editMode"` // "everyone" | "invited"
+	IsPublic         bool       `json:"isPublic"`   // Visibility is "public"
+	Visibility       string     `json:"visibility"` // "public" | "friends" | "private"
+	EditMode         string     `json:"editMode"`   // "everyone" | "invited"
+	DuplicatePolicy  string     `json:"duplicatePolicy,omitempty"`
+	FuzzyDuplicates  bool       `json:"fuzzyDuplicates,omitempty"`
+	RadioEnabled     bool       `json:"radioEnabled,omitempty"`
+	RadioMinQueue    int        `json:"radioMinQueue,omitempty"` // queued tracks radio mode keeps
 	CreatedAt        time.Time  `json:"createdAt"`
 	CurrentTrackID   *string    `json:"currentTrackId,omitempty"`
 	PlayingStartedAt *time.Time `json:"playingStartedAt,omitempty"`
+	TrackCount       int        `json:"trackCount,omitempty"`
+	LastActivityAt   *time.Time `json:"lastActivityAt,omitempty"`
+	FollowerCount    int        `json:"followerCount"`
+	DeletedAt        *time.Time `json:"deletedAt,omitempty"` // set while in the trash
+
+	// Set by GET /playlists/{id} while a moderator has locked the queue or
+	// voting.
+	QueueLockedUntil  *time.Time `json:"queueLockedUntil,omitempty"`
+	VotingLockedUntil *time.Time `json:"votingLockedUntil,omitempty"`
+
+	// Vote-to-skip (see skip_votes.go): the playing track is skipped once
+	// its skip votes exceed SkipVotePercent of the present listeners or
+	// reach SkipVoteThreshold. 0 turns a rule off.
+	SkipVotePercent   int `json:"skipVotePercent"`
+	SkipVoteThreshold int `json:"skipVoteThreshold,omitempty"`
+}
+
+// TrashedPlaylist is a deleted playlist in its owner's trash. It can be
+// restored until PurgeAt, when it is deleted for good.
+type TrashedPlaylist struct {
+	Playlist
+	PurgeAt time.Time `json:"purgeAt"`
 }
 
 // Track belongs to a playlist. Tracks are ordered by Position (0-based).
@@ -32,17 +59,379 @@ type Track struct {
 	ThumbnailURL    string `json:"thumbnailUrl,omitempty"`    // постер с YouTube
 	DurationMs      int    `json:"durationMs"`
 	VoteCount       int    `json:"voteCount"`
-	Status          string `json:"status"` // "queued", "playing", "played"
+	Status          string `json:"status"`                // "queued", "playing", "played"
+	Source          string `json:"source,omitempty"`      // "user" or "radio" (added by radio mode)
+	Unavailable     bool   `json:"unavailable,omitempty"` // removed or not embeddable at the provider
 	IsVoted         bool   `json:"isVoted,omitempty"`
+	AddedBy         string `json:"addedBy,omitempty"` // user who queued it; empty for radio tracks
+
+	Reactions    map[string]int `json:"reactions,omitempty"` // emoji -> count
+	CommentCount int            `json:"commentCount,omitempty"`
+}
+
+// PlaylistQueue is the playing track of a playlist and its queue in play
+// order, as returned by GET /playlists/{id}/queue.
+type PlaylistQueue struct {
+	PlaylistID  string        `json:"playlistId"`
+	Current     *Track        `json:"current,omitempty"`
+	StartedAt   *time.Time    `json:"startedAt,omitempty"`
+	EndsAt      *time.Time    `json:"endsAt,omitempty"` // nil when the current track has no duration
+	Tracks      []QueuedTrack `json:"tracks"`
+	GeneratedAt time.Time     `json:"generatedAt"`
+}
+
+// QueuedTrack is a queued track with its estimated start time, nil when it
+// cannot be estimated.
+type QueuedTrack struct {
+	Track
+	EstimatedStartAt *time.Time `json:"estimatedStartAt,omitempty"`
+}
+
+// PlayerState is the player device attached to a playlist and its latest
+// heartbeat. While heartbeats keep coming, the playing track ends when the
+// player reports it ended rather than after its duration.
+type PlayerState struct {
+	PlayerID   string    `json:"playerId"`
+	UserID     string    `json:"userId"`
+	TrackID    string    `json:"trackId"`
+	PositionMs int       `json:"positionMs"`
+	State      string    `json:"state"`           // "playing", "buffering", "paused", "ended" or "error"
+	Error      string    `json:"error,omitempty"` // set with State "error"
+	SeenAt     time.Time `json:"seenAt"`
+}
+
+// Device is an app instance of a user, e.g. the web app, a phone or the
+// venue PC. One device per playlist is the active output device; the others
+// act as remotes.
+type Device struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"userId"`
+	Name         string    `json:"name"`
+	Platform     string    `json:"platform"`     // "web", "ios", "android", "desktop" or "speaker"
+	Capabilities []string  `json:"capabilities"` // see deviceCapabilities
+	CreatedAt    time.Time `json:"createdAt"`
+	LastSeenAt   time.Time `json:"lastSeenAt"`
+}
+
+// PlaybackDelegation lets a user control the player of a playlist like its
+// owner, until ExpiresAt or until revoked when ExpiresAt is nil.
+type PlaybackDelegation struct {
+	PlaylistID string     `json:"playlistId"`
+	UserID     string     `json:"userId"`
+	GrantedBy  string     `json:"grantedBy"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// TrackComment is a short comment on a track.
+type TrackComment struct {
+	ID         string    `json:"id"`
+	PlaylistID string    `json:"playlistId"`
+	TrackID    string    `json:"trackId"`
+	UserID     string    `json:"userId"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// TrackSuggestion is a track proposed for a "suggest" playlist by a user
+// who may not edit it. Editors approve it, queueing it as a track added by
+// the suggester, or reject it with a reason.
+type TrackSuggestion struct {
+	ID              string     `json:"id"`
+	PlaylistID      string     `json:"playlistId"`
+	SuggestedBy     string     `json:"suggestedBy"`
+	Title           string     `json:"title"`
+	Artist          string     `json:"artist"`
+	Provider        string     `json:"provider,omitempty"`
+	ProviderTrackID string     `json:"providerTrackId,omitempty"`
+	ThumbnailURL    string     `json:"thumbnailUrl,omitempty"`
+	DurationMs      int        `json:"durationMs,omitempty"`
+	Status          string     `json:"status"` // "pending" | "approved" | "rejected"
+	Reason          string     `json:"reason,omitempty"`
+	DecidedBy       string     `json:"decidedBy,omitempty"`
+	DecidedAt       *time.Time `json:"decidedAt,omitempty"`
+	TrackID         string     `json:"trackId,omitempty"` // set once approved
+	CreatedAt       time.Time  `json:"createdAt"`
+}
+
+// Suggestion statuses.
+const (
+	suggestionPending  = "pending"
+	suggestionApproved = "approved"
+	suggestionRejected = "rejected"
+)
+
+// PlayHistoryEntry is one playback of a track. EndedAt and EndReason are
+// nil while the track is still playing.
+type PlayHistoryEntry struct {
+	ID              string     `json:"id"`
+	PlaylistID      string     `json:"playlistId"`
+	TrackID         *string    `json:"trackId,omitempty"`
+	Title           string     `json:"title"`
+	Artist          string     `json:"artist"`
+	Provider        string     `json:"provider,omitempty"`
+	ProviderTrackID string     `json:"providerTrackId,omitempty"`
+	DurationMs      int        `json:"durationMs"`
+	VoteCount       int        `json:"voteCount"` // votes when playback started
+	StartedAt       time.Time  `json:"startedAt"`
+	EndedAt         *time.Time `json:"endedAt,omitempty"`
+	PlayedMs        *int       `json:"playedMs,omitempty"`
+	EndReason       *string    `json:"endReason,omitempty"` // "finished", "skipped", "removed", "deleted"
+}
+
+// PlayStats aggregates the play history of a playlist.
+type PlayStats struct {
+	TotalPlays   int              `json:"totalPlays"`
+	Finished     int              `json:"finished"`
+	Skipped      int              `json:"skipped"`
+	SkipRate     float64          `json:"skipRate"`
+	AverageVotes float64          `json:"averageVotes"`
+	TotalPlayMs  int64            `json:"totalPlayMs"`
+	TopArtists   []ArtistPlayStat `json:"topArtists"`
+}
+
+// ArtistPlayStat is the number of plays of one artist.
+type ArtistPlayStat struct {
+	Artist string `json:"artist"`
+	Plays  int    `json:"plays"`
 }
 
 // PlaylistInvite represents an invited user to a playlist.
 type PlaylistInvite struct {
 	UserID    string    `json:"userId"`
+	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// PlaylistInvitation is an invitation the invitee has not answered yet.
+type PlaylistInvitation struct {
+	PlaylistID   string    `json:"playlistId"`
+	PlaylistName string    `json:"playlistName,omitempty"`
+	UserID       string    `json:"userId"`
+	InvitedBy    string    `json:"invitedBy"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"createdAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// FeedItem is one change in a followed playlist: a track added by a user
+// or a track that started playing.
+type FeedItem struct {
+	Type         string    `json:"type"` // "track.added" | "playback.started"
+	PlaylistID   string    `json:"playlistId"`
+	PlaylistName string    `json:"playlistName"`
+	TrackID      string    `json:"trackId,omitempty"`
+	Title        string    `json:"title"`
+	Artist       string    `json:"artist"`
+	At           time.Time `json:"at"`
+}
+
+// JoinLink is a shareable code that adds whoever redeems it to a playlist.
+// MaxUses and ExpiresAt are unlimited when nil.
+type JoinLink struct {
+	Code       string     `json:"code"`
+	PlaylistID string     `json:"playlistId"`
+	CreatedBy  string     `json:"createdBy"`
+	Role       string     `json:"role"`
+	MaxUses    *int       `json:"maxUses,omitempty"`
+	Uses       int        `json:"uses"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// JoinResult is returned by POST /join/{code}. vote-service answers event
+// codes with the same shape.
+type JoinResult struct {
+	Type   string `json:"type"` // "playlist"
+	ID     string `json:"id"`
+	Role   string `json:"role"`
+	Joined bool   `json:"joined"` // false if the user already had access
+}
+
+// Member roles. Editors may change the track list of "invited" and
+// "suggest" playlists and review suggestions;
+// viewers may only see private playlists. Co-owners have editor rights and
+// may moderate the playlist like its owner.
+const (
+	memberRoleEditor  = "editor"
+	memberRoleViewer  = "viewer"
+	memberRoleCoOwner = "co-owner"
+)
+
+// roleCanEdit reports whether a member role may change the track list of an
+// "invited" or "suggest" playlist.
+func roleCanEdit(role string) bool {
+	return role == memberRoleEditor || role == memberRoleCoOwner
+}
+
+// Visibilities decide who may see a playlist besides the owner and its
+// members: everyone, the owner's friends (see visibility.go) or nobody.
+// Friends may also join it themselves, like anyone may join public ones.
+const (
+	visibilityPublic  = "public"
+	visibilityFriends = "friends"
+	visibilityPrivate = "private"
+)
+
+const invalidVisibilityMsg = `invalid visibility (must be "public", "friends" or "private")`
+
+func isValidVisibility(v string) bool {
+	return v == visibilityPublic || v == visibilityFriends || v == visibilityPrivate
+}
+
+// normalizeVisibility keeps Visibility and IsPublic consistent; playlists
+// created before Visibility existed only set IsPublic.
+func (pl *Playlist) normalizeVisibility() {
+	if pl.Visibility == "" {
+		pl.Visibility = visibilityPrivate
+		if pl.IsPublic {
+			pl.Visibility = visibilityPublic
+		}
+	}
+	pl.IsPublic = pl.Visibility == visibilityPublic
+}
+
+// syncVisibility normalizes the visibility after an update of old that may
+// have set either field; one that only changed IsPublic decides it.
+func (pl *Playlist) syncVisibility(old Playlist) {
+	if pl.Visibility == old.Visibility && pl.IsPublic != old.IsPublic {
+		pl.Visibility = ""
+	}
+	pl.normalizeVisibility()
+}
+
+// Edit modes decide who may change the track list besides the owner:
+// everyone with access, or only members with an editor role. "suggest" is
+// "invited" where everyone else with access may suggest tracks for editors
+// to approve (see suggestions.go).
 const (
 	editModeEveryone = "everyone"
 	editModeInvited  = "invited"
+	editModeSuggest  = "suggest"
+)
+
+const invalidEditModeMsg = `invalid editMode (must be "everyone", "invited" or "suggest")`
+
+func isValidEditMode(m string) bool {
+	return m == editModeEveryone || m == editModeInvited || m == editModeSuggest
+}
+
+// editModeNeedsRole reports whether only the owner and members with an
+// editor role may change the track list under an edit mode.
+func editModeNeedsRole(m string) bool {
+	return m == editModeInvited || m == editModeSuggest
+}
+
+// Duplicate policies decide what happens when a track that is already in the
+// playlist is added again.
+const (
+	duplicatePolicyAllow            = "allow"
+	duplicatePolicyReject           = "reject"
+	duplicatePolicyMergeVote        = "merge_vote"
+	duplicatePolicyAllowAfterPlayed = "allow_after_played"
+)
+
+// Track sources. Radio tracks are added automatically and always rank after
+// user-added ones; smart tracks are generated from a smart playlist's rules.
+const (
+	trackSourceUser  = "user"
+	trackSourceRadio = "radio"
+	trackSourceSmart = "smart"
+)
+
+// Reasons a playback ended, stored in play_history.end_reason.
+const (
+	playEndFinished = "finished"
+	playEndSkipped  = "skipped"
+	playEndRemoved  = "removed"
+	playEndDeleted  = "deleted" // the playlist was moved to the trash
+)
+
+// PlaylistBan keeps a user out of a playlist: a banned user cannot join,
+// vote or add tracks until unbanned.
+type PlaylistBan struct {
+	PlaylistID string    `json:"playlistId"`
+	UserID     string    `json:"userId"`
+	BannedBy   string    `json:"bannedBy"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// ModerationEntry is one action in the moderation log of a playlist.
+type ModerationEntry struct {
+	ID           string    `json:"id"`
+	PlaylistID   string    `json:"playlistId"`
+	ActorID      string    `json:"actorId"`
+	Action       string    `json:"action"`
+	TargetUserID string    `json:"targetUserId,omitempty"`
+	Details      string    `json:"details,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// ModerationState is what moderation currently restricts in a playlist for
+// one user. Locks are stored with their end time and may have expired.
+type ModerationState struct {
+	QueueLockedUntil  *time.Time
+	VotingLockedUntil *time.Time
+	Banned            bool
+}
+
+// Moderation log actions.
+const (
+	moderationKick         = "kick"
+	moderationBan          = "ban"
+	moderationUnban        = "unban"
+	moderationLock         = "lock"
+	moderationUnlock       = "unlock"
+	moderationRemoveTracks = "remove_tracks"
+	moderationContentRules = "content_rules"
+	moderationEditLicense  = "edit_license"
+)
+
+// ContentRules restrict what may be queued in a playlist, e.g. to follow a
+// venue's rules. Empty fields allow anything. Moderators may override them
+// per track.
+type ContentRules struct {
+	BlockedArtists   []string `json:"blockedArtists"`
+	BlockedKeywords  []string `json:"blockedKeywords"` // matched in titles
+	MaxDurationMs    int      `json:"maxDurationMs,omitempty"`
+	AllowedProviders []string `json:"allowedProviders"` // "manual" for tracks without a provider
+}
+
+// EditLicense restricts who may add, move and delete tracks to people at a
+// venue and/or during its opening hours. Lat, Lng and RadiusM are set
+// together; OpensAt and ClosesAt ("HH:MM" in TimeZone, UTC by default) are
+// set together and may wrap past midnight. The zero value restricts nothing.
+type EditLicense struct {
+	Lat      *float64 `json:"lat,omitempty"`
+	Lng      *float64 `json:"lng,omitempty"`
+	RadiusM  *int     `json:"radiusM,omitempty"`
+	OpensAt  string   `json:"opensAt,omitempty"`
+	ClosesAt string   `json:"closesAt,omitempty"`
+	TimeZone string   `json:"timeZone,omitempty"`
+}
+
+// SmartRules turn a playlist into a smart playlist, whose queue is generated
+// from the rules (see smart.go) instead of edited by hand. Each refresh
+// queues up to Limit tracks, taking the rules in order.
+type SmartRules struct {
+	Rules        []SmartRule `json:"rules"`
+	Limit        int         `json:"limit,omitempty"`        // default 50
+	RefreshHours int         `json:"refreshHours,omitempty"` // 0 refreshes on demand only
+	RefreshedAt  *time.Time  `json:"refreshedAt,omitempty"`  // set by refreshes
+}
+
+// SmartRule selects tracks for a smart playlist.
+type SmartRule struct {
+	Type     string `json:"type"` // "played" | "followed" | "preferred_artists"
+	MinVotes int    `json:"minVotes,omitempty"`
+	Days     int    `json:"days,omitempty"` // "played" only, default 30
+}
+
+// Lock targets. A locked queue rejects track changes, locked voting rejects
+// votes, both only for users who do not moderate the playlist.
+const (
+	lockQueue  = "queue"
+	lockVoting = "voting"
 )
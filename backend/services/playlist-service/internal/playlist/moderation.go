@@ -0,0 +1,453 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultLockMinutes  = 15
+	maxLockMinutes      = 24 * 60
+	maxBanReasonLength  = 500
+	defaultModLogLimit  = 50
+	maxModerationLogLen = 200
+)
+
+// lockedMessages are the rejections of a locked queue or locked voting.
+var lockedMessages = map[string]string{
+	lockQueue:  "the queue is locked by a moderator",
+	lockVoting: "voting is locked by a moderator",
+}
+
+// isModerator reports whether a user moderates a playlist: its owner and
+// its co-owners.
+func isModerator(userID, ownerID, role string) bool {
+	return userID == ownerID || role == memberRoleCoOwner
+}
+
+// checkModeratorAccess allows the owner and co-owners and returns the owner
+// ID. Denials are returned as *httpError.
+func (s *Server) checkModeratorAccess(ctx context.Context, playlistID, userID string) (string, error) {
+	ownerID, _, _, err := s.getPlaylistAccessInfo(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		return "", &httpError{status: http.StatusNotFound, msg: "playlist not found"}
+	}
+	if err != nil {
+		return "", err
+	}
+	if userID == ownerID {
+		return ownerID, nil
+	}
+	role, err := s.memberRole(ctx, playlistID, userID)
+	if err != nil {
+		return "", err
+	}
+	if role != memberRoleCoOwner {
+		return "", &httpError{status: http.StatusForbidden, msg: "only the owner and co-owners can moderate"}
+	}
+	return ownerID, nil
+}
+
+// checkModerationTarget rejects actions against the owner, and against
+// co-owners unless the owner acts.
+func (s *Server) checkModerationTarget(ctx context.Context, playlistID, ownerID, actorID, targetID string) error {
+	if targetID == ownerID {
+		return &httpError{status: http.StatusForbidden, msg: "cannot moderate the playlist owner"}
+	}
+	if targetID == actorID {
+		return &httpError{status: http.StatusBadRequest, msg: "cannot moderate yourself"}
+	}
+	if actorID == ownerID {
+		return nil
+	}
+	role, err := s.memberRole(ctx, playlistID, targetID)
+	if err != nil {
+		return err
+	}
+	if role == memberRoleCoOwner {
+		return &httpError{status: http.StatusForbidden, msg: "only the owner can moderate co-owners"}
+	}
+	return nil
+}
+
+// checkRestrictions rejects banned users and, unless the user moderates the
+// playlist, actions on a locked target (lockQueue or lockVoting; "" checks
+// the ban only). Denials are returned as *httpError.
+func (s *Server) checkRestrictions(ctx context.Context, playlistID, userID string, moderator bool, target string) error {
+	st, err := s.store.ModerationState(ctx, playlistID, userID)
+	if errors.Is(err, ErrNotFound) {
+		return &httpError{status: http.StatusNotFound, msg: "playlist not found"}
+	}
+	if err != nil {
+		return err
+	}
+	if st.Banned {
+		return &httpError{status: http.StatusForbidden, msg: "you are banned from this playlist"}
+	}
+	if moderator || target == "" {
+		return nil
+	}
+	until := st.QueueLockedUntil
+	if target == lockVoting {
+		until = st.VotingLockedUntil
+	}
+	if lockActive(until, time.Now()) {
+		return &httpError{status: http.StatusLocked, msg: lockedMessages[target]}
+	}
+	return nil
+}
+
+func lockActive(until *time.Time, now time.Time) bool {
+	return until != nil && until.After(now)
+}
+
+// recordModeration appends to the moderation log. The action has already
+// happened, so failures are only logged.
+func (s *Server) recordModeration(ctx context.Context, playlistID, actorID, action, targetID, details string) {
+	e := ModerationEntry{PlaylistID: playlistID, ActorID: actorID, Action: action, TargetUserID: targetID, Details: details}
+	if err := s.store.AddModerationEntry(ctx, &e); err != nil {
+		log.Printf("playlist-service: moderation log %s on %s: %v", action, playlistID, err)
+		return
+	}
+	s.publishEvent(ctx, map[string]any{
+		"type":    "playlist.moderated",
+		"payload": e,
+	})
+}
+
+// moderationTarget decodes {"userId": ...} request bodies.
+type moderationTarget struct {
+	UserID string `json:"userId"`
+	Reason string `json:"reason"`
+}
+
+// startModeration runs the checks shared by the actions on a user: a valid
+// body, moderator access and an allowed target. It writes the error
+// response itself and returns ok=false on failure.
+func (s *Server) startModeration(w http.ResponseWriter, r *http.Request) (body moderationTarget, actorID string, ok bool) {
+	ctx := r.Context()
+	actorID = r.Header.Get("X-User-Id")
+	if actorID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return body, "", false
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return body, "", false
+	}
+	body.UserID = strings.TrimSpace(body.UserID)
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.UserID == "" {
+		writeError(w, http.StatusBadRequest, "userId is required")
+		return body, "", false
+	}
+	if len(body.Reason) > maxBanReasonLength {
+		writeError(w, http.StatusBadRequest, "reason is too long")
+		return body, "", false
+	}
+
+	ownerID, err := s.checkModeratorAccess(ctx, playlistID, actorID)
+	if err != nil {
+		writeHTTPError(w, err, "moderation access")
+		return body, "", false
+	}
+	if err := s.checkModerationTarget(ctx, playlistID, ownerID, actorID, body.UserID); err != nil {
+		writeHTTPError(w, err, "moderation target")
+		return body, "", false
+	}
+	return body, actorID, true
+}
+
+// handleKickMember removes a member from the playlist. Unlike a ban, the
+// user may join again.
+// POST /playlists/{id}/moderation/kick
+// Body: {"userId": "..."}
+func (s *Server) handleKickMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	playlistID := chi.URLParam(r, "id")
+	body, actorID, ok := s.startModeration(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.store.RemoveMember(ctx, playlistID, body.UserID); err != nil {
+		writeHTTPError(w, err, "kick member")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.member_kicked",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"userId":     body.UserID,
+		},
+	})
+	s.recordModeration(ctx, playlistID, actorID, moderationKick, body.UserID, body.Reason)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleBanUser bans a user from the playlist and removes their membership.
+// POST /playlists/{id}/moderation/bans
+// Body: {"userId": "...", "reason": "optional"}
+func (s *Server) handleBanUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	playlistID := chi.URLParam(r, "id")
+	body, actorID, ok := s.startModeration(w, r)
+	if !ok {
+		return
+	}
+
+	ban := PlaylistBan{PlaylistID: playlistID, UserID: body.UserID, BannedBy: actorID, Reason: body.Reason}
+	if err := s.store.BanUser(ctx, &ban); err != nil {
+		writeHTTPError(w, err, "ban user")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.member_banned",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"userId":     body.UserID,
+		},
+	})
+	s.recordModeration(ctx, playlistID, actorID, moderationBan, body.UserID, body.Reason)
+
+	writeJSON(w, http.StatusCreated, ban)
+}
+
+// handleListBans lists the users banned from the playlist, newest first.
+// GET /playlists/{id}/moderation/bans
+func (s *Server) handleListBans(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if _, err := s.checkModeratorAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "list bans access")
+		return
+	}
+	bans, err := s.store.ListBans(ctx, playlistID)
+	if err != nil {
+		writeHTTPError(w, err, "list bans")
+		return
+	}
+	writeJSON(w, http.StatusOK, bans)
+}
+
+// handleUnbanUser lifts a ban.
+// DELETE /playlists/{id}/moderation/bans/{userId}
+func (s *Server) handleUnbanUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actorID := r.Header.Get("X-User-Id")
+	if actorID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+	targetID := chi.URLParam(r, "userId")
+
+	if _, err := s.checkModeratorAccess(ctx, playlistID, actorID); err != nil {
+		writeHTTPError(w, err, "unban access")
+		return
+	}
+	err := s.store.UnbanUser(ctx, playlistID, targetID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "user is not banned")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "unban user")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.member_unbanned",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"userId":     targetID,
+		},
+	})
+	s.recordModeration(ctx, playlistID, actorID, moderationUnban, targetID, "")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLock temporarily locks the queue or voting for everyone but the
+// moderators.
+// PUT /playlists/{id}/moderation/locks/{target}
+// Body: {"minutes": 15} (optional, 1-1440)
+func (s *Server) handleLock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actorID := r.Header.Get("X-User-Id")
+	if actorID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+	target := chi.URLParam(r, "target")
+	if target != lockQueue && target != lockVoting {
+		writeError(w, http.StatusBadRequest, `invalid lock (must be "queue" or "voting")`)
+		return
+	}
+
+	var body struct {
+		Minutes *int `json:"minutes"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+	}
+	minutes := defaultLockMinutes
+	if body.Minutes != nil {
+		minutes = *body.Minutes
+		if minutes < 1 || minutes > maxLockMinutes {
+			writeError(w, http.StatusBadRequest, "minutes must be between 1 and "+strconv.Itoa(maxLockMinutes))
+			return
+		}
+	}
+
+	if _, err := s.checkModeratorAccess(ctx, playlistID, actorID); err != nil {
+		writeHTTPError(w, err, "lock access")
+		return
+	}
+	until := time.Now().Add(time.Duration(minutes) * time.Minute).UTC()
+	if err := s.store.SetLock(ctx, playlistID, target, &until); err != nil {
+		writeHTTPError(w, err, "lock")
+		return
+	}
+
+	resp := map[string]any{
+		"playlistId":  playlistID,
+		"target":      target,
+		"lockedUntil": until,
+	}
+	s.publishEvent(ctx, map[string]any{
+		"type":    "playlist.locked",
+		"payload": resp,
+	})
+	s.recordModeration(ctx, playlistID, actorID, moderationLock, "", fmt.Sprintf("%s for %d min", target, minutes))
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleUnlock lifts a queue or voting lock early.
+// DELETE /playlists/{id}/moderation/locks/{target}
+func (s *Server) handleUnlock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	actorID := r.Header.Get("X-User-Id")
+	if actorID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+	target := chi.URLParam(r, "target")
+	if target != lockQueue && target != lockVoting {
+		writeError(w, http.StatusBadRequest, `invalid lock (must be "queue" or "voting")`)
+		return
+	}
+
+	if _, err := s.checkModeratorAccess(ctx, playlistID, actorID); err != nil {
+		writeHTTPError(w, err, "unlock access")
+		return
+	}
+	if err := s.store.SetLock(ctx, playlistID, target, nil); err != nil {
+		writeHTTPError(w, err, "unlock")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.unlocked",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"target":     target,
+		},
+	})
+	s.recordModeration(ctx, playlistID, actorID, moderationUnlock, "", target)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRemoveUserTracks removes every queued track a user added at once,
+// publishing a single playlist.tracks_removed event. Tracks that already
+// played or are playing stay.
+// POST /playlists/{id}/moderation/remove-tracks
+// Body: {"userId": "..."}
+func (s *Server) handleRemoveUserTracks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	playlistID := chi.URLParam(r, "id")
+	body, actorID, ok := s.startModeration(w, r)
+	if !ok {
+		return
+	}
+
+	removed, err := s.store.RemoveTracksByUser(ctx, playlistID, body.UserID)
+	if err != nil {
+		writeHTTPError(w, err, "remove tracks")
+		return
+	}
+	if len(removed) > 0 {
+		s.publishEvent(ctx, map[string]any{
+			"type": "playlist.tracks_removed",
+			"payload": map[string]any{
+				"playlistId": playlistID,
+				"userId":     body.UserID,
+				"trackIds":   removed,
+			},
+		})
+	}
+
+	s.recordModeration(ctx, playlistID, actorID, moderationRemoveTracks, body.UserID, fmt.Sprintf("%d tracks", len(removed)))
+
+	writeJSON(w, http.StatusOK, map[string]any{"removed": removed})
+}
+
+// handleModerationLog returns the latest moderation actions, newest first.
+// GET /playlists/{id}/moderation/log?limit=50
+func (s *Server) handleModerationLog(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	limit := defaultModLogLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxModerationLogLen {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxModerationLogLen))
+			return
+		}
+		limit = n
+	}
+
+	if _, err := s.checkModeratorAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "moderation log access")
+		return
+	}
+	entries, err := s.store.ListModerationLog(ctx, playlistID, limit)
+	if err != nil {
+		writeHTTPError(w, err, "moderation log")
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
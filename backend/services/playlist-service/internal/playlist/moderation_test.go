@@ -0,0 +1,209 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// newModerationTestServer returns a memory-backed server with a public
+// playlist owned by owner-1, with co-1 as co-owner and ed-1 as editor.
+func newModerationTestServer(t *testing.T) (http.Handler, Playlist) {
+	t.Helper()
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	pl := Playlist{OwnerID: "owner-1", Name: "Friday", IsPublic: true, EditMode: editModeEveryone}
+	if err := store.CreatePlaylist(ctx, &pl, nil); err != nil {
+		t.Fatal(err)
+	}
+	store.AddMember(ctx, pl.ID, "co-1", memberRoleCoOwner)
+	store.AddMember(ctx, pl.ID, "ed-1", memberRoleEditor)
+	return NewServerWithStore(store, nil).Router(), pl
+}
+
+func TestHandleModerationAccess(t *testing.T) {
+	h, pl := newModerationTestServer(t)
+	kick := "/playlists/" + pl.ID + "/moderation/kick"
+
+	tests := []struct {
+		name     string
+		userID   string
+		target   string
+		wantCode int
+	}{
+		{name: "No User", target: "ed-1", wantCode: http.StatusUnauthorized},
+		{name: "Missing Target", userID: "owner-1", wantCode: http.StatusBadRequest},
+		{name: "Not A Moderator", userID: "ed-1", target: "troll", wantCode: http.StatusForbidden},
+		{name: "Co-owner Kicks Owner", userID: "co-1", target: "owner-1", wantCode: http.StatusForbidden},
+		{name: "Self", userID: "co-1", target: "co-1", wantCode: http.StatusBadRequest},
+		{name: "Co-owner Kicks Editor", userID: "co-1", target: "ed-1", wantCode: http.StatusNoContent},
+		{name: "Owner Kicks Co-owner", userID: "owner-1", target: "co-1", wantCode: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAs(h, tt.userID, "POST", kick, map[string]string{"userId": tt.target})
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected %d, got %d. Body: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// ed-1 was kicked but may rejoin a public playlist, unlike a banned user.
+	if w := serveAs(h, "ed-1", "POST", "/playlists/"+pl.ID+"/tracks", map[string]string{"title": "Song", "artist": "Band"}); w.Code != http.StatusCreated {
+		t.Errorf("kicked user adding to a public playlist: got %d", w.Code)
+	}
+	if w := serveAs(h, "co-1", "GET", "/playlists/"+pl.ID+"/moderation/log", nil); w.Code != http.StatusForbidden {
+		t.Errorf("kicked co-owner reading the log: got %d", w.Code)
+	}
+}
+
+func TestHandleBan(t *testing.T) {
+	h, pl := newModerationTestServer(t)
+	base := "/playlists/" + pl.ID
+
+	w := serveAs(h, "ed-1", "POST", base+"/tracks", map[string]string{"title": "Song", "artist": "Band"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("add track: %d", w.Code)
+	}
+	var tr Track
+	_ = json.NewDecoder(w.Body).Decode(&tr)
+	if tr.AddedBy != "ed-1" {
+		t.Errorf("AddedBy = %q", tr.AddedBy)
+	}
+
+	w = serveAs(h, "co-1", "POST", base+"/moderation/bans", map[string]string{"userId": "troll", "reason": "spam"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	if w := serveAs(h, "troll", "POST", base+"/tracks", map[string]string{"title": "Spam", "artist": "Bot"}); w.Code != http.StatusForbidden {
+		t.Errorf("banned user adding a track: got %d", w.Code)
+	}
+	if w := serveAs(h, "troll", "POST", base+"/tracks/"+tr.ID+"/vote", nil); w.Code != http.StatusForbidden {
+		t.Errorf("banned user voting: got %d", w.Code)
+	}
+	if w := serveAs(h, "troll", "DELETE", base+"/tracks/"+tr.ID, nil); w.Code != http.StatusForbidden {
+		t.Errorf("banned user deleting a track: got %d", w.Code)
+	}
+	if w := serveAs(h, "troll", "POST", base+"/next", nil); w.Code != http.StatusForbidden {
+		t.Errorf("banned user skipping: got %d", w.Code)
+	}
+	if w := serveAs(h, "troll", "POST", base+"/player/heartbeat", map[string]any{"playerId": "pc-1", "state": playerPlaying}); w.Code != http.StatusForbidden {
+		t.Errorf("banned user attaching a player: got %d", w.Code)
+	}
+
+	w = serveAs(h, "owner-1", "GET", base+"/moderation/bans", nil)
+	var bans []PlaylistBan
+	_ = json.NewDecoder(w.Body).Decode(&bans)
+	if w.Code != http.StatusOK || len(bans) != 1 || bans[0].UserID != "troll" || bans[0].BannedBy != "co-1" {
+		t.Errorf("list bans = %d %+v", w.Code, bans)
+	}
+	if w := serveAs(h, "ed-1", "GET", base+"/moderation/bans", nil); w.Code != http.StatusForbidden {
+		t.Errorf("editor listing bans: got %d", w.Code)
+	}
+
+	if w := serveAs(h, "owner-1", "DELETE", base+"/moderation/bans/troll", nil); w.Code != http.StatusNoContent {
+		t.Errorf("unban: got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "DELETE", base+"/moderation/bans/troll", nil); w.Code != http.StatusNotFound {
+		t.Errorf("unban again: got %d", w.Code)
+	}
+	if w := serveAs(h, "troll", "POST", base+"/tracks/"+tr.ID+"/vote", nil); w.Code != http.StatusOK {
+		t.Errorf("unbanned user voting: got %d", w.Code)
+	}
+
+	w = serveAs(h, "owner-1", "GET", base+"/moderation/log", nil)
+	var entries []ModerationEntry
+	_ = json.NewDecoder(w.Body).Decode(&entries)
+	if len(entries) != 2 || entries[0].Action != moderationUnban || entries[1].Action != moderationBan || entries[1].Details != "spam" {
+		t.Errorf("moderation log = %+v", entries)
+	}
+}
+
+func TestHandleLocks(t *testing.T) {
+	h, pl := newModerationTestServer(t)
+	base := "/playlists/" + pl.ID
+	track := map[string]string{"title": "Song", "artist": "Band"}
+
+	if w := serveAs(h, "co-1", "PUT", base+"/moderation/locks/everything", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown lock: got %d", w.Code)
+	}
+	if w := serveAs(h, "co-1", "PUT", base+"/moderation/locks/queue", map[string]int{"minutes": 0}); w.Code != http.StatusBadRequest {
+		t.Errorf("zero minutes: got %d", w.Code)
+	}
+	if w := serveAs(h, "ed-1", "PUT", base+"/moderation/locks/queue", nil); w.Code != http.StatusForbidden {
+		t.Errorf("editor locking: got %d", w.Code)
+	}
+	if w := serveAs(h, "co-1", "PUT", base+"/moderation/locks/queue", map[string]int{"minutes": 5}); w.Code != http.StatusOK {
+		t.Fatalf("lock queue: got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	if w := serveAs(h, "ed-1", "POST", base+"/tracks", track); w.Code != http.StatusLocked {
+		t.Errorf("adding to a locked queue: got %d", w.Code)
+	}
+	w := serveAs(h, "owner-1", "POST", base+"/tracks", track)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("owner adding to a locked queue: got %d", w.Code)
+	}
+	var tr Track
+	_ = json.NewDecoder(w.Body).Decode(&tr)
+
+	w = serveAs(h, "ed-1", "GET", base, nil)
+	var got struct {
+		Playlist Playlist `json:"playlist"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if got.Playlist.QueueLockedUntil == nil || got.Playlist.VotingLockedUntil != nil {
+		t.Errorf("playlist locks = %v, %v", got.Playlist.QueueLockedUntil, got.Playlist.VotingLockedUntil)
+	}
+
+	// Voting is locked separately.
+	if w := serveAs(h, "ed-1", "POST", base+"/tracks/"+tr.ID+"/vote", nil); w.Code != http.StatusOK {
+		t.Errorf("voting with a locked queue: got %d", w.Code)
+	}
+	serveAs(h, "co-1", "PUT", base+"/moderation/locks/voting", nil)
+	if w := serveAs(h, "u-2", "POST", base+"/tracks/"+tr.ID+"/vote", nil); w.Code != http.StatusLocked {
+		t.Errorf("voting while locked: got %d", w.Code)
+	}
+
+	if w := serveAs(h, "co-1", "DELETE", base+"/moderation/locks/queue", nil); w.Code != http.StatusNoContent {
+		t.Errorf("unlock: got %d", w.Code)
+	}
+	if w := serveAs(h, "ed-1", "POST", base+"/tracks", map[string]string{"title": "Other", "artist": "Band"}); w.Code != http.StatusCreated {
+		t.Errorf("adding after unlock: got %d", w.Code)
+	}
+}
+
+func TestHandleRemoveUserTracks(t *testing.T) {
+	h, pl := newModerationTestServer(t)
+	base := "/playlists/" + pl.ID
+
+	for _, title := range []string{"Spam 1", "Spam 2"} {
+		serveAs(h, "troll", "POST", base+"/tracks", map[string]string{"title": title, "artist": "Bot"})
+	}
+	serveAs(h, "ed-1", "POST", base+"/tracks", map[string]string{"title": "Song", "artist": "Band"})
+
+	if w := serveAs(h, "ed-1", "POST", base+"/moderation/remove-tracks", map[string]string{"userId": "troll"}); w.Code != http.StatusForbidden {
+		t.Errorf("editor removing tracks: got %d", w.Code)
+	}
+	w := serveAs(h, "owner-1", "POST", base+"/moderation/remove-tracks", map[string]string{"userId": "troll"})
+	var resp struct {
+		Removed []string `json:"removed"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || len(resp.Removed) != 2 {
+		t.Fatalf("remove tracks = %d %+v", w.Code, resp)
+	}
+
+	w = serveAs(h, "owner-1", "GET", base, nil)
+	var got struct {
+		Tracks []Track `json:"tracks"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if len(got.Tracks) != 1 || got.Tracks[0].AddedBy != "ed-1" {
+		t.Errorf("remaining tracks = %+v", got.Tracks)
+	}
+}
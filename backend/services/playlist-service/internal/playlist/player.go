@@ -0,0 +1,283 @@
+package playlist
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Player states reported in heartbeats.
+const (
+	playerPlaying   = "playing"
+	playerBuffering = "buffering"
+	playerPaused    = "paused"
+	playerEnded     = "ended"
+	playerError     = "error"
+)
+
+const (
+	// playerHeartbeatTimeout is how long after its latest heartbeat a player
+	// still drives playback. Players send one every few seconds; when they
+	// stop, the scheduler falls back to track durations.
+	playerHeartbeatTimeout = 15 * time.Second
+
+	maxPlayerIDLength    = 100
+	maxPlayerErrorLength = 500
+)
+
+// A player device, e.g. the venue PC, attaches to a playlist by sending
+// heartbeats with the track it plays and its position. While it does, the
+// playing track ends when the player reports it ended or failed, however
+// long it buffered or was paused; errors skip the track with a
+// "player.error" event. Only one player is attached at a time and, once an
+// active output device is selected (see devices.go), only that device.
+
+func isValidPlayerState(s string) bool {
+	switch s {
+	case playerPlaying, playerBuffering, playerPaused, playerEnded, playerError:
+		return true
+	}
+	return false
+}
+
+// fresh reports whether p still drives playback at now.
+func (p *PlayerState) fresh(now time.Time) bool {
+	return p != nil && now.Sub(p.SeenAt) < playerHeartbeatTimeout
+}
+
+// trackEnded reports whether the playing track trackID has ended. While a
+// player is attached only its reports count; otherwise the track ends after
+// its duration (durationDue).
+func trackEnded(player *PlayerState, trackID string, durationDue bool, now time.Time) bool {
+	if !player.fresh(now) {
+		return durationDue
+	}
+	return player.TrackID == trackID && (player.State == playerEnded || player.State == playerError)
+}
+
+// handleGetPlayer returns the player attached to a playlist and whether it
+// drives playback.
+// GET /playlists/{id}/player
+func (s *Server) handleGetPlayer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "player access")
+		return
+	}
+	player, err := s.store.Player(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "get player")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"player": player,
+		"active": player.fresh(time.Now()),
+	})
+}
+
+// handlePlayerHeartbeat records a heartbeat of the player device, attaching
+// it unless another player is attached. A report that the playing track
+// ended or failed advances the playlist.
+// POST /playlists/{id}/player/heartbeat
+// Body: {"playerId": "...", "trackId": "...", "positionMs": 0, "state": "playing", "error": ""}
+func (s *Server) handlePlayerHeartbeat(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	var body struct {
+		PlayerID   string `json:"playerId"`
+		TrackID    string `json:"trackId"`
+		PositionMs int    `json:"positionMs"`
+		State      string `json:"state"`
+		Error      string `json:"error"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	body.PlayerID = strings.TrimSpace(body.PlayerID)
+	body.Error = strings.TrimSpace(body.Error)
+	switch {
+	case body.PlayerID == "" || len(body.PlayerID) > maxPlayerIDLength:
+		writeError(w, http.StatusBadRequest, "playerId must be between 1 and 100 characters")
+		return
+	case !isValidPlayerState(body.State):
+		writeError(w, http.StatusBadRequest, `invalid state (must be "playing", "buffering", "paused", "ended" or "error")`)
+		return
+	case body.PositionMs < 0:
+		writeError(w, http.StatusBadRequest, "positionMs must be >= 0")
+		return
+	case len(body.Error) > maxPlayerErrorLength:
+		writeError(w, http.StatusBadRequest, "error is too long")
+		return
+	}
+
+	if err := s.checkPlaybackControl(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "player access")
+		return
+	}
+	activeDeviceID, err := s.store.ActiveDevice(ctx, playlistID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		writeHTTPError(w, err, "get active device")
+		return
+	}
+	if activeDeviceID != "" && body.PlayerID != activeDeviceID {
+		writeError(w, http.StatusConflict, "another device is the active output device")
+		return
+	}
+	pl, err := s.store.GetPlaylist(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "get playlist")
+		return
+	}
+
+	now := time.Now()
+	var attached bool
+	player, err := s.store.UpdatePlayer(ctx, playlistID, func(cur *PlayerState) (*PlayerState, error) {
+		if cur.fresh(now) && cur.PlayerID != body.PlayerID {
+			return nil, &httpError{status: http.StatusConflict, msg: "another player is attached to this playlist"}
+		}
+		attached = !cur.fresh(now)
+		return &PlayerState{
+			PlayerID:   body.PlayerID,
+			UserID:     userID,
+			TrackID:    body.TrackID,
+			PositionMs: body.PositionMs,
+			State:      body.State,
+			Error:      body.Error,
+			SeenAt:     now,
+		}, nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "update player")
+		return
+	}
+
+	if attached {
+		s.publishEvent(ctx, map[string]any{
+			"type": "player.attached",
+			"payload": map[string]any{
+				"playlistId": playlistID,
+				"player":     player,
+			},
+		})
+	}
+
+	currentTrackID := pl.CurrentTrackID
+	advanced := false
+	if currentTrackID != nil && *currentTrackID == body.TrackID && (body.State == playerEnded || body.State == playerError) {
+		reason := playEndFinished
+		if body.State == playerError {
+			reason = playEndSkipped
+			s.publishEvent(ctx, map[string]any{
+				"type": "player.error",
+				"payload": map[string]any{
+					"playlistId": playlistID,
+					"trackId":    body.TrackID,
+					"playerId":   body.PlayerID,
+					"error":      body.Error,
+				},
+			})
+		}
+
+		// Retried reports of the same track advance only once.
+		state, err := s.advancePlayback(ctx, playlistID, reason, body.TrackID)
+		switch {
+		case err == nil:
+			advanced = true
+			currentTrackID = nil
+			if id, ok := state["currentTrackId"].(string); ok {
+				currentTrackID = &id
+			}
+		case !errors.Is(err, errPlaybackChanged):
+			log.Printf("playlist-service: player advance: %v", err)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"player":         player,
+		"currentTrackId": currentTrackID,
+		"advanced":       advanced,
+	})
+}
+
+// handleDetachPlayer detaches the player of a playlist; the playing track
+// then ends after its duration again. Anyone checkPlaybackControl allows may
+// detach it.
+// DELETE /playlists/{id}/player
+func (s *Server) handleDetachPlayer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkPlaybackControl(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "player access")
+		return
+	}
+
+	var detached *PlayerState
+	_, err := s.store.UpdatePlayer(ctx, playlistID, func(cur *PlayerState) (*PlayerState, error) {
+		if cur == nil {
+			return nil, &httpError{status: http.StatusNotFound, msg: "no player is attached"}
+		}
+		detached = cur
+		return nil, nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "detach player")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "player.detached",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"playerId":   detached.PlayerID,
+		},
+	})
+	if s.scheduler != nil {
+		s.scheduler.refresh(playlistID, time.Now())
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
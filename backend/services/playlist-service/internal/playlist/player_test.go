@@ -0,0 +1,176 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTrackEnded(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		player *PlayerState
+		due    bool
+		want   bool
+	}{
+		{name: "No Player Before The End", want: false},
+		{name: "No Player After The End", due: true, want: true},
+		{name: "Playing Past The Duration", player: &PlayerState{TrackID: "t-1", State: playerBuffering, SeenAt: now}, due: true, want: false},
+		{name: "Ended Early", player: &PlayerState{TrackID: "t-1", State: playerEnded, SeenAt: now}, want: true},
+		{name: "Failed", player: &PlayerState{TrackID: "t-1", State: playerError, SeenAt: now}, want: true},
+		{name: "Ended Another Track", player: &PlayerState{TrackID: "t-0", State: playerEnded, SeenAt: now}, due: true, want: false},
+		{name: "Stale Player", player: &PlayerState{TrackID: "t-1", State: playerPlaying, SeenAt: now.Add(-time.Minute)}, due: true, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trackEnded(tt.player, "t-1", tt.due, now); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// newPlayerTestServer returns a memory-backed server with a public playlist
+// owned by owner-1 whose first track, "One", is playing before "Two".
+func newPlayerTestServer(t *testing.T) (http.Handler, *MemoryStore, Playlist) {
+	t.Helper()
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	pl := Playlist{OwnerID: "owner-1", Name: "Venue", IsPublic: true, EditMode: editModeInvited}
+	if err := store.CreatePlaylist(ctx, &pl, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"One", "Two"} {
+		in := trackInput{Title: title, Artist: "Band", DurationMs: int(time.Hour / time.Millisecond)}
+		if _, err := store.AddTrack(ctx, pl.ID, in, trackSourceUser); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	pl, _ = store.GetPlaylist(ctx, pl.ID)
+	return NewServerWithStore(store, nil).Router(), store, pl
+}
+
+type heartbeatResult struct {
+	Player         *PlayerState `json:"player"`
+	CurrentTrackID *string      `json:"currentTrackId"`
+	Advanced       bool         `json:"advanced"`
+}
+
+func heartbeat(t *testing.T, h http.Handler, userID, playlistID string, body map[string]any) (int, heartbeatResult) {
+	t.Helper()
+	w := serveAs(h, userID, "POST", "/playlists/"+playlistID+"/player/heartbeat", body)
+	var res heartbeatResult
+	_ = json.NewDecoder(w.Body).Decode(&res)
+	return w.Code, res
+}
+
+func TestHandlePlayerHeartbeat(t *testing.T) {
+	h, _, pl := newPlayerTestServer(t)
+	one := *pl.CurrentTrackID
+
+	code, res := heartbeat(t, h, "owner-1", pl.ID, map[string]any{"playerId": "pc-1", "trackId": one, "positionMs": 5000, "state": playerBuffering})
+	if code != http.StatusOK || res.Player == nil || res.Player.PositionMs != 5000 || res.Advanced {
+		t.Fatalf("attach = %d %+v", code, res)
+	}
+	if code, _ := heartbeat(t, h, "owner-1", pl.ID, map[string]any{"playerId": "pc-2", "state": playerPlaying}); code != http.StatusConflict {
+		t.Errorf("second player: got %d", code)
+	}
+
+	w := serveAs(h, "listener", "GET", "/playlists/"+pl.ID+"/player", nil)
+	var got struct {
+		Player *PlayerState `json:"player"`
+		Active bool         `json:"active"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if w.Code != http.StatusOK || got.Player == nil || got.Player.PlayerID != "pc-1" || !got.Active {
+		t.Errorf("GET player = %d %+v", w.Code, got)
+	}
+
+	code, res = heartbeat(t, h, "owner-1", pl.ID, map[string]any{"playerId": "pc-1", "trackId": one, "state": playerEnded})
+	if code != http.StatusOK || !res.Advanced || res.CurrentTrackID == nil || *res.CurrentTrackID == one {
+		t.Fatalf("ended = %d %+v", code, res)
+	}
+	two := *res.CurrentTrackID
+
+	// A retried report of the ended track does not advance again.
+	if code, res := heartbeat(t, h, "owner-1", pl.ID, map[string]any{"playerId": "pc-1", "trackId": one, "state": playerEnded}); code != http.StatusOK || res.Advanced {
+		t.Errorf("retried report = %d %+v", code, res)
+	}
+
+	code, res = heartbeat(t, h, "owner-1", pl.ID, map[string]any{"playerId": "pc-1", "trackId": two, "state": playerError, "error": "decode failed"})
+	if code != http.StatusOK || !res.Advanced || res.CurrentTrackID != nil {
+		t.Errorf("error = %d %+v", code, res)
+	}
+}
+
+func TestHandlePlayerHeartbeat_Validation(t *testing.T) {
+	h, _, pl := newPlayerTestServer(t)
+
+	tests := []struct {
+		name   string
+		userID string
+		id     string
+		body   map[string]any
+		want   int
+	}{
+		{name: "No User", id: pl.ID, body: map[string]any{"playerId": "pc-1", "state": playerPlaying}, want: http.StatusUnauthorized},
+		{name: "No Player ID", userID: "owner-1", id: pl.ID, body: map[string]any{"state": playerPlaying}, want: http.StatusBadRequest},
+		{name: "Invalid State", userID: "owner-1", id: pl.ID, body: map[string]any{"playerId": "pc-1", "state": "stopped"}, want: http.StatusBadRequest},
+		{name: "Negative Position", userID: "owner-1", id: pl.ID, body: map[string]any{"playerId": "pc-1", "state": playerPlaying, "positionMs": -1}, want: http.StatusBadRequest},
+		{name: "Listener", userID: "listener", id: pl.ID, body: map[string]any{"playerId": "pc-1", "state": playerPlaying}, want: http.StatusForbidden},
+		{name: "Missing Playlist", userID: "owner-1", id: missingID, body: map[string]any{"playerId": "pc-1", "state": playerPlaying}, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := heartbeat(t, h, tt.userID, tt.id, tt.body); code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, code)
+			}
+		})
+	}
+}
+
+func TestHandleDetachPlayer(t *testing.T) {
+	h, store, pl := newPlayerTestServer(t)
+	path := "/playlists/" + pl.ID + "/player"
+
+	if w := serveAs(h, "owner-1", "DELETE", path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("nothing attached: got %d", w.Code)
+	}
+	if err := store.AddMember(context.Background(), pl.ID, "dj-1", memberRoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := heartbeat(t, h, "dj-1", pl.ID, map[string]any{"playerId": "pc-1", "state": playerPlaying}); code != http.StatusOK {
+		t.Fatalf("editor attaching: got %d", code)
+	}
+
+	if w := serveAs(h, "listener", "DELETE", path, nil); w.Code != http.StatusForbidden {
+		t.Errorf("listener: got %d", w.Code)
+	}
+	// A ban also keeps the player's user from detaching it.
+	if err := store.BanUser(context.Background(), &PlaylistBan{PlaylistID: pl.ID, UserID: "dj-1", BannedBy: "owner-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if w := serveAs(h, "dj-1", "DELETE", path, nil); w.Code != http.StatusForbidden {
+		t.Errorf("banned player's user: got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "DELETE", path, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("owner: got %d", w.Code)
+	}
+	if player, _ := store.Player(context.Background(), pl.ID); player != nil {
+		t.Errorf("player after detaching = %+v", player)
+	}
+
+	// Once detached, another player may attach.
+	if code, _ := heartbeat(t, h, "owner-1", pl.ID, map[string]any{"playerId": "pc-2", "state": playerPlaying}); code != http.StatusOK {
+		t.Errorf("attaching after detach: got %d", code)
+	}
+}
@@ -0,0 +1,161 @@
+package playlist
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+
+	listFilterOwned  = "owned"
+	listFilterMember = "member"
+	listFilterPublic = "public"
+
+	listSortRecent = "recent"
+	listSortTracks = "tracks"
+	listSortActive = "active"
+)
+
+// listPlaylistsQuery holds the parsed query string of GET /playlists.
+type listPlaylistsQuery struct {
+	Filter   string
+	EditMode string
+	Sort     string
+	Search   string
+	Limit    int
+	Cursor   *playlistCursor
+
+	// FriendIDs are the caller's friends, whose friends-only playlists are
+	// listed too; set by the handler.
+	FriendIDs []string
+}
+
+// playlistCursor marks the last row of a page. Key is the sort key of that
+// row (RFC 3339 time or integer, depending on Sort) and ID breaks ties.
+type playlistCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   string `json:"id"`
+}
+
+func (c playlistCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodePlaylistCursor(raw string) (*playlistCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var c playlistCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if c.ID == "" || c.Key == "" {
+		return nil, fmt.Errorf("incomplete cursor")
+	}
+	return &c, nil
+}
+
+// sortKey parses Key: a track count for the "tracks" sort, a time
+// otherwise.
+func (c playlistCursor) sortKey() (int64, time.Time, error) {
+	if c.Sort == listSortTracks {
+		n, err := strconv.ParseInt(c.Key, 10, 64)
+		return n, time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, c.Key)
+	return 0, t, err
+}
+
+// parseListPlaylistsQuery validates the listing parameters. It returns a
+// client-facing error message, or "" when the query is valid.
+func parseListPlaylistsQuery(v url.Values) (listPlaylistsQuery, string) {
+	q := listPlaylistsQuery{
+		Filter:   strings.ToLower(strings.TrimSpace(v.Get("filter"))),
+		EditMode: strings.ToLower(strings.TrimSpace(v.Get("editMode"))),
+		Sort:     strings.ToLower(strings.TrimSpace(v.Get("sort"))),
+		Search:   strings.TrimSpace(v.Get("q")),
+		Limit:    defaultListLimit,
+	}
+
+	switch q.Filter {
+	case "", listFilterOwned, listFilterMember, listFilterPublic:
+	default:
+		return q, `invalid filter (must be "owned", "member" or "public")`
+	}
+
+	switch q.EditMode {
+	case "", editModeEveryone, editModeInvited, editModeSuggest:
+	default:
+		return q, invalidEditModeMsg
+	}
+
+	switch q.Sort {
+	case "":
+		q.Sort = listSortRecent
+	case listSortRecent, listSortTracks, listSortActive:
+	default:
+		return q, `invalid sort (must be "recent", "tracks" or "active")`
+	}
+
+	if len(q.Search) > 200 {
+		return q, "q is too long"
+	}
+
+	if raw := v.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxListLimit {
+			return q, fmt.Sprintf("limit must be between 1 and %d", maxListLimit)
+		}
+		q.Limit = n
+	}
+
+	if raw := v.Get("cursor"); raw != "" {
+		c, err := decodePlaylistCursor(raw)
+		if err != nil {
+			return q, "invalid cursor"
+		}
+		if c.Sort != q.Sort {
+			return q, "cursor does not match sort"
+		}
+		if _, _, err := c.sortKey(); err != nil {
+			return q, "invalid cursor"
+		}
+		q.Cursor = c
+	}
+
+	return q, ""
+}
+
+// cursorFor returns the cursor pointing after pl for the given sort.
+func cursorFor(sort string, pl Playlist) playlistCursor {
+	c := playlistCursor{Sort: sort, ID: pl.ID}
+	switch sort {
+	case listSortTracks:
+		c.Key = strconv.Itoa(pl.TrackCount)
+	case listSortActive:
+		c.Key = activityAt(pl).UTC().Format(time.RFC3339Nano)
+	default:
+		c.Key = pl.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return c
+}
+
+// activityAt returns the key of pl for the "active" sort. A playlist without
+// recorded activity counts as active since its creation, as in the listing
+// SQL.
+func activityAt(pl Playlist) time.Time {
+	if pl.LastActivityAt != nil {
+		return *pl.LastActivityAt
+	}
+	return pl.CreatedAt
+}
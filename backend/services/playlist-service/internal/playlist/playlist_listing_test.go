@@ -0,0 +1,142 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestParseListPlaylistsQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{name: "Defaults", query: ""},
+		{name: "All Params", query: "filter=owned&editMode=invited&sort=tracks&q=party&limit=10"},
+		{name: "Invalid Filter", query: "filter=friends", wantErr: true},
+		{name: "Invalid Sort", query: "sort=random", wantErr: true},
+		{name: "Invalid Edit Mode", query: "editMode=nobody", wantErr: true},
+		{name: "Limit Too Large", query: "limit=1000", wantErr: true},
+		{name: "Invalid Cursor", query: "cursor=!!!", wantErr: true},
+		{name: "Malformed Cursor Key", query: "sort=tracks&cursor=" + playlistCursor{Sort: listSortTracks, Key: "not-a-number", ID: "pl-1"}.encode(), wantErr: true},
+		{name: "Cursor Sort Mismatch", query: "sort=tracks&cursor=" + playlistCursor{Sort: listSortRecent, Key: "2024-01-01T00:00:00Z", ID: "pl-1"}.encode(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, _ := url.ParseQuery(tt.query)
+			q, msg := parseListPlaylistsQuery(v)
+			if (msg != "") != tt.wantErr {
+				t.Fatalf("unexpected result %q", msg)
+			}
+			if !tt.wantErr && tt.query == "" && (q.Sort != listSortRecent || q.Limit != defaultListLimit) {
+				t.Errorf("unexpected defaults: %+v", q)
+			}
+		})
+	}
+}
+
+func TestHandleListPlaylists_Pagination(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mockDB := &MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			return &MockRows{
+				Data: [][]any{
+					{"pl-2", "user-1", "Second", "", true, "everyone", created, 4, nil, 0, "public"},
+					{"pl-1", "user-1", "First", "", true, "everyone", created.Add(-time.Hour), 1, nil, 0, "public"},
+				},
+				Idx: -1,
+			}, nil
+		},
+	}
+	srv := NewServer(mockDB, nil)
+	r := chi.NewRouter()
+	r.Get("/playlists", srv.handleListPlaylists)
+
+	req := httptest.NewRequest("GET", "/playlists?limit=1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 OK, got %d", w.Code)
+	}
+	var playlists []Playlist
+	_ = json.NewDecoder(w.Body).Decode(&playlists)
+	if len(playlists) != 1 || playlists[0].ID != "pl-2" {
+		t.Fatalf("unexpected page: %+v", playlists)
+	}
+
+	next, err := decodePlaylistCursor(w.Header().Get("X-Next-Cursor"))
+	if err != nil {
+		t.Fatalf("invalid next cursor: %v", err)
+	}
+	if next.ID != "pl-2" || next.Sort != listSortRecent || next.Key != created.Format(time.RFC3339Nano) {
+		t.Errorf("unexpected cursor: %+v", next)
+	}
+}
+
+func TestHandleListPlaylists_ActiveWithoutActivity(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var gotSQL string
+	mockDB := &MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			gotSQL = sql
+			return &MockRows{
+				Data: [][]any{
+					{"pl-2", "user-1", "Quiet", "", true, "everyone", created, 0, nil, 0, "public"},
+					{"pl-1", "user-1", "Older", "", true, "everyone", created.Add(-time.Hour), 0, nil, 0, "public"},
+				},
+				Idx: -1,
+			}, nil
+		},
+	}
+	srv := NewServer(mockDB, nil)
+	r := chi.NewRouter()
+	r.Get("/playlists", srv.handleListPlaylists)
+
+	req := httptest.NewRequest("GET", "/playlists?sort=active&limit=1", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 OK, got %d", w.Code)
+	}
+	if !strings.Contains(gotSQL, "ORDER BY COALESCE(p.last_activity_at, p.created_at) DESC") {
+		t.Errorf("unexpected sort:\n%s", gotSQL)
+	}
+
+	// A playlist that never had activity sorts by its creation time.
+	next, err := decodePlaylistCursor(w.Header().Get("X-Next-Cursor"))
+	if err != nil {
+		t.Fatalf("invalid next cursor: %v", err)
+	}
+	if next.ID != "pl-2" || next.Sort != listSortActive || next.Key != created.Format(time.RFC3339Nano) {
+		t.Errorf("unexpected cursor: %+v", next)
+	}
+	q, msg := parseListPlaylistsQuery(url.Values{"sort": {listSortActive}, "cursor": {next.encode()}})
+	if msg != "" || q.Cursor == nil {
+		t.Errorf("next cursor rejected: %q", msg)
+	}
+}
+
+func TestHandleListPlaylists_OwnedRequiresAuth(t *testing.T) {
+	srv := NewServer(&MockDB{}, nil)
+	r := chi.NewRouter()
+	r.Get("/playlists", srv.handleListPlaylists)
+
+	req := httptest.NewRequest("GET", "/playlists?filter=owned", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", w.Code)
+	}
+}
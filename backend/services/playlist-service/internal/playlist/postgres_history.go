@@ -0,0 +1,29 @@
+package playlist
+
+import (
+	"context"
+	"time"
+)
+
+// startPlayHistory opens a history entry for a track that starts playing,
+// snapshotting its metadata and current vote count.
+func startPlayHistory(ctx context.Context, q querier, trackID string, at time.Time) error {
+	_, err := q.Exec(ctx, `
+		INSERT INTO play_history (playlist_id, track_id, title, artist, provider, provider_track_id,
+		                          duration_ms, vote_count, started_at)
+		SELECT playlist_id, id, title, artist, provider, provider_track_id, duration_ms, vote_count, $2
+		FROM tracks
+		WHERE id = $1
+	`, trackID, at)
+	return err
+}
+
+// endPlayHistory closes the open history entry of a track, if any.
+func endPlayHistory(ctx context.Context, q querier, playlistID, trackID, reason string, at time.Time) error {
+	_, err := q.Exec(ctx, `
+		UPDATE play_history
+		SET ended_at = $3, end_reason = $4
+		WHERE playlist_id = $1 AND track_id = $2 AND ended_at IS NULL
+	`, playlistID, trackID, at, reason)
+	return err
+}
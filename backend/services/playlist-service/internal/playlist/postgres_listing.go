@@ -0,0 +1,75 @@
+package playlist
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// buildListPlaylistsSQL renders the listing query for userID. The first
+// seven columns match the Playlist metadata; then come the track count, the
+// time of the last activity (track added or playback started), both kept on
+// the playlist by triggers (see AutoMigrate), the follower count and the
+// visibility. The cursor must have been validated by
+// parseListPlaylistsQuery.
+func buildListPlaylistsSQL(q listPlaylistsQuery, userID string) (string, []any) {
+	args := []any{userID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	visible := `p.is_public = TRUE
+		   OR ($1 <> '' AND p.owner_id = $1)
+		   OR ($1 <> '' AND pm.user_id IS NOT NULL)`
+	if len(q.FriendIDs) > 0 {
+		visible += `
+		   OR (p.visibility = 'friends' AND p.owner_id = ANY(` + arg(q.FriendIDs) + `))`
+	}
+	where := []string{"p.deleted_at IS NULL", "(" + visible + ")"}
+
+	switch q.Filter {
+	case listFilterOwned:
+		where = append(where, "p.owner_id = $1")
+	case listFilterMember:
+		where = append(where, "pm.user_id IS NOT NULL AND p.owner_id <> $1")
+	case listFilterPublic:
+		where = append(where, "p.is_public = TRUE")
+	}
+
+	if q.EditMode != "" {
+		where = append(where, "p.edit_mode = "+arg(q.EditMode))
+	}
+
+	if q.Search != "" {
+		where = append(where, "to_tsvector('simple', p.name || ' ' || p.description) @@ plainto_tsquery('simple', "+arg(q.Search)+")")
+	}
+
+	var sortExpr, castType string
+	switch q.Sort {
+	case listSortTracks:
+		sortExpr, castType = "p.track_count", "bigint"
+	case listSortActive:
+		// Like activityAt and idx_playlists_list_activity: playlists without
+		// activity sort by their creation.
+		sortExpr, castType = "COALESCE(p.last_activity_at, p.created_at)", "timestamptz"
+	default:
+		sortExpr, castType = "p.created_at", "timestamptz"
+	}
+
+	if q.Cursor != nil {
+		where = append(where, fmt.Sprintf("(%s, p.id) < (%s::%s, %s::uuid)", sortExpr, arg(q.Cursor.Key), castType, arg(q.Cursor.ID)))
+	}
+
+	sql := `
+		SELECT p.id, p.owner_id, p.name, p.description, p.is_public, p.edit_mode, p.created_at,
+		       p.track_count, p.last_activity_at,
+		       (SELECT COUNT(*) FROM playlist_follows f WHERE f.playlist_id = p.id)::int, p.visibility
+		FROM playlists p
+		LEFT JOIN playlist_members pm ON p.id = pm.playlist_id AND pm.user_id = $1
+		WHERE ` + strings.Join(where, "\n		  AND ") + `
+		ORDER BY ` + sortExpr + ` DESC, p.id DESC
+		LIMIT ` + arg(q.Limit+1)
+
+	return sql, args
+}
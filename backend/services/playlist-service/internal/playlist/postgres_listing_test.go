@@ -0,0 +1,53 @@
+package playlist
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildListPlaylistsSQL(t *testing.T) {
+	c := playlistCursor{Sort: listSortTracks, Key: "12", ID: "pl-9"}
+	sql, args := buildListPlaylistsSQL(listPlaylistsQuery{
+		Filter:   listFilterOwned,
+		EditMode: editModeInvited,
+		Sort:     listSortTracks,
+		Search:   "party",
+		Limit:    20,
+		Cursor:   &c,
+	}, "user-1")
+	for _, want := range []string{
+		"FROM playlists p\n",
+		"p.deleted_at IS NULL",
+		"p.owner_id = $1",
+		"p.edit_mode = $2",
+		"plainto_tsquery('simple', $3)",
+		"(p.track_count, p.id) < ($4::bigint, $5::uuid)",
+		"ORDER BY p.track_count DESC, p.id DESC",
+		"LIMIT $6",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("expected SQL to contain %q:\n%s", want, sql)
+		}
+	}
+	if len(args) != 6 || args[5] != 21 {
+		t.Errorf("unexpected args: %v", args)
+	}
+}
+
+func TestBuildListPlaylistsSQL_Friends(t *testing.T) {
+	sql, args := buildListPlaylistsSQL(listPlaylistsQuery{
+		Sort:      listSortRecent,
+		Limit:     20,
+		FriendIDs: []string{"friend-1"},
+	}, "user-1")
+	if !strings.Contains(sql, "p.visibility = 'friends' AND p.owner_id = ANY($2)") {
+		t.Errorf("expected friends' playlists to be visible:\n%s", sql)
+	}
+	if len(args) != 3 {
+		t.Errorf("unexpected args: %v", args)
+	}
+
+	if sql, _ := buildListPlaylistsSQL(listPlaylistsQuery{Sort: listSortRecent, Limit: 20}, "user-1"); strings.Contains(sql, "'friends'") {
+		t.Errorf("expected no friends clause without friends:\n%s", sql)
+	}
+}
@@ -0,0 +1,116 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Tracks are ordered by a sparse sort_key rather than a dense position, so
+// moving or re-ranking a track rewrites only that track: it takes a key
+// halfway between its new neighbours. When two neighbours have no key left
+// between them the whole playlist is renumbered (rebalanced) once.
+//
+// The API keeps exposing a dense 0-based Position, computed on read as the
+// rank of the track by (sort_key, id).
+
+// sortKeyGap is the distance between consecutive keys after an append or a
+// rebalance. It allows 16 consecutive bisections before a rebalance.
+const sortKeyGap int64 = 1 << 16
+
+var errNoSortKeyRoom = errors.New("no room between sort keys")
+
+// rankedTracks is a FROM item exposing the tracks of the playlist bound to
+// param with their 0-based position and the playlist track count.
+func rankedTracks(param string) string {
+	return `(
+		SELECT t.*,
+		       (ROW_NUMBER() OVER (ORDER BY t.sort_key, t.id) - 1)::int AS position,
+		       (COUNT(*) OVER ())::int AS total
+		FROM tracks t
+		WHERE t.playlist_id = ` + param + `
+	) ranked`
+}
+
+// keyBetween returns a key strictly between lower and upper. A nil upper
+// means "after lower". ok is false when no integer fits.
+func keyBetween(lower int64, upper *int64) (key int64, ok bool) {
+	if upper == nil {
+		return lower + sortKeyGap, true
+	}
+	if *upper-lower < 2 {
+		return 0, false
+	}
+	return lower + (*upper-lower)/2, true
+}
+
+// lockPlaylistOrder serializes reorders of a playlist for the rest of tx.
+func lockPlaylistOrder(ctx context.Context, tx pgx.Tx, playlistID string) error {
+	_, err := tx.Exec(ctx, `SELECT 1 FROM playlists WHERE id = $1 FOR UPDATE`, playlistID)
+	return err
+}
+
+func sortKeyOf(ctx context.Context, q querier, trackID string) (int64, error) {
+	var key int64
+	err := q.QueryRow(ctx, `SELECT sort_key FROM tracks WHERE id = $1`, trackID).Scan(&key)
+	return key, err
+}
+
+// placeTrack gives trackID a key between the tracks prevID and nextID; an
+// empty ID means the start or the end of the playlist respectively.
+func placeTrack(ctx context.Context, q querier, playlistID, trackID, prevID, nextID string) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		var lower int64
+		var upper *int64
+		if prevID != "" {
+			k, err := sortKeyOf(ctx, q, prevID)
+			if err != nil {
+				return fmt.Errorf("prev key: %w", err)
+			}
+			lower = k
+		}
+		if nextID != "" {
+			k, err := sortKeyOf(ctx, q, nextID)
+			if err != nil {
+				return fmt.Errorf("next key: %w", err)
+			}
+			upper = &k
+		}
+
+		if key, ok := keyBetween(lower, upper); ok {
+			if _, err := q.Exec(ctx, `
+				UPDATE tracks
+				SET sort_key = $3
+				WHERE id = $2 AND playlist_id = $1
+			`, playlistID, trackID, key); err != nil {
+				return fmt.Errorf("set sort key: %w", err)
+			}
+			return nil
+		}
+
+		if err := rebalanceSortKeys(ctx, q, playlistID); err != nil {
+			return err
+		}
+	}
+	return errNoSortKeyRoom
+}
+
+// rebalanceSortKeys renumbers the keys of a playlist evenly, keeping the
+// current order.
+func rebalanceSortKeys(ctx context.Context, q querier, playlistID string) error {
+	if _, err := q.Exec(ctx, `
+		UPDATE tracks t
+		SET sort_key = r.rn * $2
+		FROM (
+			SELECT id, ROW_NUMBER() OVER (ORDER BY sort_key, id) AS rn
+			FROM tracks
+			WHERE playlist_id = $1
+		) r
+		WHERE t.id = r.id
+	`, playlistID, sortKeyGap); err != nil {
+		return fmt.Errorf("rebalance: %w", err)
+	}
+	return nil
+}
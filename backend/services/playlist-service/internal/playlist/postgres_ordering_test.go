@@ -0,0 +1,214 @@
+package playlist
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestKeyBetween(t *testing.T) {
+	upper := func(v int64) *int64 { return &v }
+
+	tests := []struct {
+		name   string
+		lower  int64
+		upper  *int64
+		want   int64
+		wantOK bool
+	}{
+		{name: "Append", lower: 3 * sortKeyGap, upper: nil, want: 4 * sortKeyGap, wantOK: true},
+		{name: "Empty Playlist", lower: 0, upper: nil, want: sortKeyGap, wantOK: true},
+		{name: "Midpoint", lower: 10, upper: upper(20), want: 15, wantOK: true},
+		{name: "Before First", lower: 0, upper: upper(sortKeyGap), want: sortKeyGap / 2, wantOK: true},
+		{name: "Adjacent Keys", lower: 10, upper: upper(11), wantOK: false},
+		{name: "Equal Keys", lower: 10, upper: upper(10), wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := keyBetween(tt.lower, tt.upper)
+			if ok != tt.wantOK || (ok && got != tt.want) {
+				t.Errorf("keyBetween(%d, %v) = %d, %v; want %d, %v", tt.lower, tt.upper, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPlaceTrack_RebalancesWhenFull(t *testing.T) {
+	rebalanced := false
+	var setKey any
+
+	tx := &MockTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &MockRow{ScanFunc: func(dest ...any) error {
+				// Adjacent keys until the playlist is renumbered.
+				key := map[string]int64{"prev": 10, "next": 11}[args[0].(string)]
+				if rebalanced {
+					key = map[string]int64{"prev": sortKeyGap, "next": 2 * sortKeyGap}[args[0].(string)]
+				}
+				*dest[0].(*int64) = key
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "ROW_NUMBER()") {
+				rebalanced = true
+			} else if strings.Contains(sql, "SET sort_key = $3") {
+				setKey = args[2]
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+
+	if err := placeTrack(context.Background(), tx, "pl-1", "moved", "prev", "next"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !rebalanced {
+		t.Error("expected the playlist to be rebalanced")
+	}
+	if setKey != sortKeyGap+sortKeyGap/2 {
+		t.Errorf("expected key %d, got %v", sortKeyGap+sortKeyGap/2, setKey)
+	}
+}
+
+func TestSingleMove(t *testing.T) {
+	now := time.Now()
+	q := func(id string, votes int, key int64) queuedTrack {
+		return queuedTrack{ID: id, VoteCount: votes, CreatedAt: now, SortKey: key}
+	}
+
+	tests := []struct {
+		name       string
+		current    []queuedTrack
+		desired    []string
+		floor      int64
+		wantIndex  int
+		wantSingle bool
+	}{
+		{
+			name:       "Voted Track Moves Up",
+			current:    []queuedTrack{q("a", 2, 10), q("b", 1, 20), q("c", 2, 30)},
+			desired:    []string{"a", "c", "b"},
+			wantIndex:  1,
+			wantSingle: true,
+		},
+		{
+			name:       "Already In Place",
+			current:    []queuedTrack{q("a", 3, 10), q("c", 2, 20), q("b", 1, 30)},
+			desired:    []string{"a", "c", "b"},
+			wantIndex:  -1,
+			wantSingle: true,
+		},
+		{
+			name:       "Queue Out Of Order",
+			current:    []queuedTrack{q("b", 1, 10), q("a", 2, 20), q("c", 2, 30)},
+			desired:    []string{"a", "c", "b"},
+			wantSingle: false,
+		},
+		{
+			name:       "Queue Behind Played Track",
+			current:    []queuedTrack{q("a", 2, 10), q("b", 1, 20), q("c", 2, 30)},
+			desired:    []string{"a", "c", "b"},
+			floor:      15,
+			wantSingle: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			byID := map[string]queuedTrack{}
+			for _, c := range tt.current {
+				byID[c.ID] = c
+			}
+			var desired []queuedTrack
+			for _, id := range tt.desired {
+				desired = append(desired, byID[id])
+			}
+
+			i, ok := singleMove(tt.current, desired, "c", tt.floor)
+			if ok != tt.wantSingle || (ok && i != tt.wantIndex) {
+				t.Errorf("singleMove() = %d, %v; want %d, %v", i, ok, tt.wantIndex, tt.wantSingle)
+			}
+		})
+	}
+}
+
+func TestReorderQueuedByVotes_TouchesOneRow(t *testing.T) {
+	base := time.Now()
+	var updates [][]any
+
+	tx := &MockTx{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			// c just got its 2nd vote and must pass b.
+			return &MockRows{Data: [][]any{
+				{"a", 3, base, 2 * sortKeyGap, trackSourceUser},
+				{"b", 1, base.Add(time.Second), 3 * sortKeyGap, trackSourceUser},
+				{"c", 2, base.Add(2 * time.Second), 4 * sortKeyGap, trackSourceUser},
+			}, Idx: -1}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if strings.Contains(sql, "status != 'queued'") {
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "playing"
+					*dest[1].(*int64) = sortKeyGap
+					return nil
+				}}
+			}
+			return &MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*int64) = map[string]int64{"a": 2 * sortKeyGap, "b": 3 * sortKeyGap}[args[0].(string)]
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "UPDATE tracks") {
+				updates = append(updates, args)
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+
+	if err := reorderQueuedByVotes(context.Background(), tx, "pl-1", "c"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("expected 1 update, got %d: %v", len(updates), updates)
+	}
+	if updates[0][1] != "c" || updates[0][2] != 2*sortKeyGap+sortKeyGap/2 {
+		t.Errorf("unexpected update: %v", updates[0])
+	}
+}
+
+func TestReorderQueuedByVotes_RadioStaysBehindUserTracks(t *testing.T) {
+	base := time.Now()
+	var updates int
+
+	tx := &MockTx{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			// r is a radio track with more votes than the user track u.
+			return &MockRows{Data: [][]any{
+				{"u", 0, base.Add(time.Second), 2 * sortKeyGap, trackSourceUser},
+				{"r", 5, base, 3 * sortKeyGap, trackSourceRadio},
+			}, Idx: -1}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "UPDATE tracks") {
+				updates++
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+
+	if err := reorderQueuedByVotes(context.Background(), notFoundTx{tx}, "pl-1", "r"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updates != 0 {
+		t.Errorf("expected radio track to stay behind user track, got %d updates", updates)
+	}
+}
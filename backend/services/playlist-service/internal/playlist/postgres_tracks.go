@@ -0,0 +1,589 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// loadTrack fetches a single track of a playlist.
+func loadTrack(ctx context.Context, q querier, playlistID, trackID string) (Track, error) {
+	var tr Track
+	err := q.QueryRow(ctx, `
+		SELECT id, playlist_id, title, artist, position, created_at,
+		       provider, provider_track_id, thumbnail_url, duration_ms, vote_count, status, source, unavailable,
+		       added_by
+		FROM `+rankedTracks("$2")+`
+		WHERE id = $1
+	`, trackID, playlistID).Scan(
+		&tr.ID,
+		&tr.PlaylistID,
+		&tr.Title,
+		&tr.Artist,
+		&tr.Position,
+		&tr.CreatedAt,
+		&tr.Provider,
+		&tr.ProviderTrackID,
+		&tr.ThumbnailURL,
+		&tr.DurationMs,
+		&tr.VoteCount,
+		&tr.Status,
+		&tr.Source,
+		&tr.Unavailable,
+		&tr.AddedBy,
+	)
+	return tr, err
+}
+
+// insertTrack appends a queued track at the end of the playlist. User tracks
+// are then moved ahead of any queued radio track.
+func insertTrack(ctx context.Context, q querier, playlistID string, in trackInput, source string) (Track, error) {
+	var tr Track
+	err := q.QueryRow(ctx, `
+      WITH last AS (
+          SELECT COALESCE(MAX(sort_key), 0) AS sort_key, COUNT(*)::int AS n
+          FROM tracks
+          WHERE playlist_id = $1
+      ), ins AS (
+          INSERT INTO tracks (
+              playlist_id,
+              title,
+              artist,
+              sort_key,
+              provider,
+              provider_track_id,
+              thumbnail_url,
+              duration_ms,
+              vote_count,
+              status,
+              source,
+              resolved_at,
+              added_by
+          )
+          SELECT $1, $2, $3, last.sort_key + $8, $4, $5, $6, $7, 0, 'queued', $9,
+                 CASE WHEN $10 THEN NOW() END, $11
+          FROM last
+          RETURNING id, playlist_id, title, artist, created_at,
+                    provider, provider_track_id, thumbnail_url, duration_ms, vote_count, status, source, added_by
+      )
+      SELECT ins.id, ins.playlist_id, ins.title, ins.artist, last.n, ins.created_at,
+             ins.provider, ins.provider_track_id, ins.thumbnail_url, ins.duration_ms, ins.vote_count, ins.status, ins.source,
+             ins.added_by
+      FROM ins, last
+  `,
+		playlistID,
+		in.Title,
+		in.Artist,
+		in.Provider,
+		in.ProviderTrack,
+		in.ThumbnailURL,
+		in.DurationMs,
+		sortKeyGap,
+		source,
+		in.resolved,
+		in.addedBy,
+	).Scan(
+		&tr.ID,
+		&tr.PlaylistID,
+		&tr.Title,
+		&tr.Artist,
+		&tr.Position,
+		&tr.CreatedAt,
+		&tr.Provider,
+		&tr.ProviderTrackID,
+		&tr.ThumbnailURL,
+		&tr.DurationMs,
+		&tr.VoteCount,
+		&tr.Status,
+		&tr.Source,
+		&tr.AddedBy,
+	)
+	if err != nil || source == trackSourceRadio {
+		return tr, err
+	}
+
+	moved, err := placeBeforeRadioTracks(ctx, q, playlistID, tr.ID)
+	if err != nil || !moved {
+		return tr, err
+	}
+	return loadTrack(ctx, q, playlistID, tr.ID)
+}
+
+// moveTrackTx moves a track to newPos (clamped to the playlist length) by
+// giving it a sort key between its new neighbours. It returns the old and
+// new positions.
+func moveTrackTx(ctx context.Context, tx pgx.Tx, playlistID, trackID string, newPos int) (int, int, error) {
+	if err := lockPlaylistOrder(ctx, tx, playlistID); err != nil {
+		return 0, 0, fmt.Errorf("lock: %w", err)
+	}
+
+	var currentPos, total int
+	err := tx.QueryRow(ctx, `
+		SELECT position, total
+		FROM `+rankedTracks("$1")+`
+		WHERE id = $2
+	`, playlistID, trackID).Scan(&currentPos, &total)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if newPos >= total {
+		newPos = total - 1
+	}
+	if newPos == currentPos {
+		return currentPos, newPos, nil
+	}
+
+	// Neighbours at newPos once the track is taken out of the list.
+	offset := newPos - 1
+	if offset < 0 {
+		offset = 0
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT id
+		FROM tracks
+		WHERE playlist_id = $1 AND id <> $2
+		ORDER BY sort_key, id
+		OFFSET $3
+		LIMIT 2
+	`, playlistID, trackID, offset)
+	if err != nil {
+		return 0, 0, fmt.Errorf("neighbours: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("scan neighbour: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("neighbours: %w", err)
+	}
+
+	var prevID, nextID string
+	if newPos == 0 {
+		if len(ids) > 0 {
+			nextID = ids[0]
+		}
+	} else {
+		if len(ids) > 0 {
+			prevID = ids[0]
+		}
+		if len(ids) > 1 {
+			nextID = ids[1]
+		}
+	}
+
+	if err := placeTrack(ctx, tx, playlistID, trackID, prevID, nextID); err != nil {
+		return 0, 0, err
+	}
+	return currentPos, newPos, nil
+}
+
+// deleteTrackTx removes a track. It returns the position the track
+// occupied; the tracks after it move up without being rewritten.
+func deleteTrackTx(ctx context.Context, tx pgx.Tx, playlistID, trackID string) (int, error) {
+	var pos int
+	err := tx.QueryRow(ctx, `
+		SELECT position
+		FROM `+rankedTracks("$2")+`
+		WHERE id = $1
+	`, trackID, playlistID).Scan(&pos)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := endPlayHistory(ctx, tx, playlistID, trackID, playEndRemoved, time.Now()); err != nil {
+		return 0, fmt.Errorf("end history: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM tracks
+		WHERE id = $1 AND playlist_id = $2
+	`, trackID, playlistID); err != nil {
+		return 0, fmt.Errorf("delete: %w", err)
+	}
+	return pos, nil
+}
+
+// deleteUserTracksTx removes the queued tracks userID added with a single
+// statement and returns their IDs in playlist order. Queued tracks have no
+// open play history to end.
+func deleteUserTracksTx(ctx context.Context, tx pgx.Tx, playlistID, userID string) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+		WITH removed AS (
+			DELETE FROM tracks
+			WHERE playlist_id = $1 AND added_by = $2 AND status = 'queued'
+			RETURNING id, sort_key
+		)
+		SELECT id FROM removed ORDER BY sort_key, id
+	`, playlistID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("delete: %w", err)
+	}
+	removed := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan removed: %w", err)
+		}
+		removed = append(removed, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("delete: %w", err)
+	}
+	return removed, nil
+}
+
+// applyTrackVote records userID's vote for a track inside tx and, for queued
+// tracks, re-sorts the queue by votes. It returns errAlreadyVoted when the
+// user has voted for this track before and ErrNotFound when the track does
+// not belong to the playlist.
+func applyTrackVote(ctx context.Context, tx pgx.Tx, playlistID, trackID, userID string) (int, string, error) {
+	// Prevent duplicate votes
+	_, err := tx.Exec(ctx, `
+		INSERT INTO track_votes (track_id, user_id)
+		VALUES ($1, $2)
+	`, trackID, userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return 0, "", errAlreadyVoted
+		}
+		return 0, "", fmt.Errorf("insert vote: %w", err)
+	}
+
+	// Increment vote count
+	var newVoteCount int
+	var status string
+	err = tx.QueryRow(ctx, `
+		UPDATE tracks
+		SET vote_count = vote_count + 1
+		WHERE id = $1 AND playlist_id = $2
+		RETURNING vote_count, status
+	`, trackID, playlistID).Scan(&newVoteCount, &status)
+	if errors.Is(err, ErrNotFound) {
+		return 0, "", ErrNotFound
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("update vote count: %w", err)
+	}
+
+	if status == "queued" {
+		if err := reorderQueuedByVotes(ctx, tx, playlistID, trackID); err != nil {
+			return 0, "", err
+		}
+	}
+
+	return newVoteCount, status, nil
+}
+
+// reorderQueuedByVotes keeps the queued tracks of a playlist sorted by vote
+// count (ties broken by insertion time), after any playing/played tracks.
+// Radio tracks always come after user-added ones.
+// Normally only votedID is out of place and it alone gets a new sort key;
+// the whole queue is renumbered only when it was out of vote order already,
+// e.g. after a manual move.
+func reorderQueuedByVotes(ctx context.Context, tx pgx.Tx, playlistID, votedID string) error {
+	if err := lockPlaylistOrder(ctx, tx, playlistID); err != nil {
+		return fmt.Errorf("lock: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, vote_count, created_at, sort_key, source
+		FROM tracks
+		WHERE playlist_id = $1 AND status = 'queued'
+		ORDER BY sort_key, id
+	`, playlistID)
+	if err != nil {
+		return fmt.Errorf("select queued: %w", err)
+	}
+
+	var current []queuedTrack
+	for rows.Next() {
+		var t queuedTrack
+		if err := rows.Scan(&t.ID, &t.VoteCount, &t.CreatedAt, &t.SortKey, &t.Source); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan queued: %w", err)
+		}
+		current = append(current, t)
+	}
+	rows.Close()
+
+	desired := make([]queuedTrack, len(current))
+	copy(desired, current)
+	sort.SliceStable(desired, func(i, j int) bool {
+		if ri, rj := desired[i].Source == trackSourceRadio, desired[j].Source == trackSourceRadio; ri != rj {
+			return rj
+		}
+		if desired[i].VoteCount != desired[j].VoteCount {
+			return desired[i].VoteCount > desired[j].VoteCount
+		}
+		return desired[i].CreatedAt.Before(desired[j].CreatedAt)
+	})
+
+	// Last track that is not queued; the queue starts after it.
+	var floorID string
+	var floorKey int64
+	err = tx.QueryRow(ctx, `
+		SELECT id, sort_key
+		FROM tracks
+		WHERE playlist_id = $1 AND status != 'queued'
+		ORDER BY sort_key DESC, id DESC
+		LIMIT 1
+	`, playlistID).Scan(&floorID, &floorKey)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("get queue start: %w", err)
+	}
+
+	if i, ok := singleMove(current, desired, votedID, floorKey); ok {
+		if i < 0 {
+			return nil
+		}
+		prevID, nextID := floorID, ""
+		if i > 0 {
+			prevID = desired[i-1].ID
+		}
+		if i+1 < len(desired) {
+			nextID = desired[i+1].ID
+		}
+		return placeTrack(ctx, tx, playlistID, votedID, prevID, nextID)
+	}
+
+	for i, t := range desired {
+		if _, err := tx.Exec(ctx, `UPDATE tracks SET sort_key = $1 WHERE id = $2`, floorKey+sortKeyGap*int64(i+1), t.ID); err != nil {
+			return fmt.Errorf("renumber queue: %w", err)
+		}
+	}
+	return nil
+}
+
+func getDuplicatePolicy(ctx context.Context, q querier, playlistID string) (policy string, fuzzy bool, err error) {
+	err = q.QueryRow(ctx, `
+		SELECT duplicate_policy, fuzzy_duplicates
+		FROM playlists
+		WHERE id = $1 AND deleted_at IS NULL
+	`, playlistID).Scan(&policy, &fuzzy)
+	if policy == "" {
+		policy = duplicatePolicyAllow
+	}
+	return
+}
+
+// findDuplicateTrack returns the existing track that c duplicates under the
+// given policy, or nil when c may be added. Provider IDs are compared exactly;
+// tracks without a provider ID fall back to fuzzy title/artist matching when
+// the playlist enables it.
+func findDuplicateTrack(ctx context.Context, q querier, playlistID, policy string, fuzzy bool, c candidateTrack) (*duplicateMatch, error) {
+	statuses := duplicateStatuses(policy)
+	if statuses == nil {
+		return nil, nil
+	}
+
+	if c.ProviderTrackID != "" {
+		var m duplicateMatch
+		err := q.QueryRow(ctx, `
+			SELECT id, status
+			FROM tracks
+			WHERE playlist_id = $1
+			  AND provider = $2
+			  AND provider_track_id = $3
+			  AND status = ANY($4)
+			ORDER BY sort_key, id
+			LIMIT 1
+		`, playlistID, c.Provider, c.ProviderTrackID, statuses).Scan(&m.ID, &m.Status)
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &m, nil
+	}
+
+	if !fuzzy || normalizeTrackText(c.Title) == "" {
+		return nil, nil
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT id, status, title, artist
+		FROM tracks
+		WHERE playlist_id = $1 AND status = ANY($2)
+		ORDER BY sort_key, id
+	`, playlistID, statuses)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m duplicateMatch
+		var exTitle, exArtist string
+		if err := rows.Scan(&m.ID, &m.Status, &exTitle, &exArtist); err != nil {
+			return nil, err
+		}
+		if c.fuzzyMatches(exTitle, exArtist) {
+			return &m, nil
+		}
+	}
+	return nil, rows.Err()
+}
+
+// applyBatchOperation runs a single operation inside the transaction of
+// PostgresStore.ApplyTrackBatch or AddTrackUnlessDuplicate and fills res.
+// Operation-level failures are returned as *httpError.
+func applyBatchOperation(ctx context.Context, tx pgx.Tx, playlistID, userID, policy string, fuzzy bool, mergeDenied *httpError, op batchOperation, res *batchResult) error {
+	switch op.Op {
+	case batchOpAdd:
+		dup, err := findDuplicateTrack(ctx, tx, playlistID, policy, fuzzy, op.Track.candidate())
+		if err != nil {
+			return err
+		}
+		if dup != nil {
+			if policy != duplicatePolicyMergeVote || dup.Status != "queued" {
+				return &httpError{status: http.StatusConflict, msg: duplicateRejectReason(policy, dup.Status)}
+			}
+			if mergeDenied != nil {
+				return mergeDenied
+			}
+			_, _, err := applyTrackVote(ctx, tx, playlistID, dup.ID, userID)
+			if errors.Is(err, errAlreadyVoted) {
+				return &httpError{status: http.StatusConflict, msg: "track is already queued and you already voted for it"}
+			}
+			if err != nil {
+				return err
+			}
+			tr, err := loadTrack(ctx, tx, playlistID, dup.ID)
+			if err != nil {
+				return err
+			}
+			res.Status = "merged"
+			res.TrackID = tr.ID
+			res.Track = &tr
+			return nil
+		}
+
+		tr, err := insertTrack(ctx, tx, playlistID, *op.Track, trackSourceUser)
+		if err != nil {
+			return err
+		}
+		res.Status = "ok"
+		res.TrackID = tr.ID
+		res.Track = &tr
+		return nil
+
+	case batchOpRemove:
+		if _, err := deleteTrackTx(ctx, tx, playlistID, op.TrackID); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return &httpError{status: http.StatusNotFound, msg: "track not found"}
+			}
+			return err
+		}
+		res.Status = "ok"
+		return nil
+
+	case batchOpMove:
+		from, to, err := moveTrackTx(ctx, tx, playlistID, op.TrackID, *op.NewPosition)
+		if errors.Is(err, ErrNotFound) {
+			return &httpError{status: http.StatusNotFound, msg: "track not found"}
+		}
+		if err != nil {
+			return err
+		}
+		res.Status = "ok"
+		res.From = &from
+		res.To = &to
+		return nil
+	}
+	return &httpError{status: http.StatusBadRequest, msg: "unsupported operation"}
+}
+
+// knownTracks returns the provider IDs ("provider:id") and normalized
+// "title|artist" keys of every track of the playlist, so radio never
+// repeats a track.
+func knownTracks(ctx context.Context, q querier, playlistID string) (map[string]bool, error) {
+	rows, err := q.Query(ctx, `
+		SELECT provider, provider_track_id, title, artist
+		FROM tracks
+		WHERE playlist_id = $1
+	`, playlistID)
+	if err != nil {
+		return nil, fmt.Errorf("known tracks: %w", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var provider, providerID, title, artist string
+		if err := rows.Scan(&provider, &providerID, &title, &artist); err != nil {
+			return nil, fmt.Errorf("scan known track: %w", err)
+		}
+		if providerID != "" {
+			seen[provider+":"+providerID] = true
+		}
+		seen[normalizeTrackText(title)+"|"+normalizeArtist(artist)] = true
+	}
+	return seen, rows.Err()
+}
+
+// placeBeforeRadioTracks moves a user track ahead of the first queued radio
+// track, if any. It reports whether the track moved.
+func placeBeforeRadioTracks(ctx context.Context, q querier, playlistID, trackID string) (bool, error) {
+	var radioID string
+	err := q.QueryRow(ctx, `
+		SELECT id
+		FROM tracks
+		WHERE playlist_id = $1 AND status = 'queued' AND source = 'radio'
+		ORDER BY sort_key, id
+		LIMIT 1
+	`, playlistID).Scan(&radioID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("first radio track: %w", err)
+	}
+
+	var prevID string
+	err = q.QueryRow(ctx, `
+		SELECT id
+		FROM tracks
+		WHERE playlist_id = $1
+		  AND (sort_key, id) < (SELECT sort_key, id FROM tracks WHERE id = $2)
+		ORDER BY sort_key DESC, id DESC
+		LIMIT 1
+	`, playlistID, radioID).Scan(&prevID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return false, fmt.Errorf("track before radio: %w", err)
+	}
+
+	if err := placeTrack(ctx, q, playlistID, trackID, prevID, radioID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getContentRules reads the content rules of a playlist.
+func getContentRules(ctx context.Context, q querier, playlistID string) (ContentRules, error) {
+	var doc []byte
+	err := q.QueryRow(ctx, `
+		SELECT content_rules
+		FROM playlists
+		WHERE id = $1 AND deleted_at IS NULL
+	`, playlistID).Scan(&doc)
+	if err != nil {
+		return ContentRules{}, err
+	}
+	return decodeContentRules(doc)
+}
@@ -0,0 +1,84 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestFindDuplicateTrack_Fuzzy(t *testing.T) {
+	mockDB := &MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			return &MockRows{
+				Data: [][]any{
+					{"tr-1", "queued", "Other Song", "Queen"},
+					{"tr-2", "queued", "Bohemian Rhapsody (Official Video)", "Queen - Topic"},
+				},
+				Idx: -1,
+			}, nil
+		},
+	}
+
+	m, err := findDuplicateTrack(context.Background(), mockDB, "pl-1", duplicatePolicyReject, true, candidateTrack{
+		Title:  "bohemian rhapsody",
+		Artist: "Queen",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m == nil || m.ID != "tr-2" {
+		t.Fatalf("expected match tr-2, got %+v", m)
+	}
+
+	m, err = findDuplicateTrack(context.Background(), mockDB, "pl-1", duplicatePolicyReject, false, candidateTrack{
+		Title: "bohemian rhapsody",
+	})
+	if err != nil || m != nil {
+		t.Fatalf("expected no match without fuzzy matching, got %+v, %v", m, err)
+	}
+}
+
+func TestPlaceBeforeRadioTracks(t *testing.T) {
+	keys := map[string]int64{"tr-user": 1 << 16, "tr-radio": 2 << 16}
+	var newKey int64
+	tx := &MockTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			switch {
+			case strings.Contains(sql, "source = 'radio'"):
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "tr-radio"
+					return nil
+				}}
+			case strings.Contains(sql, "ORDER BY sort_key DESC"):
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "tr-user"
+					return nil
+				}}
+			case strings.Contains(sql, "SELECT sort_key FROM tracks"):
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*int64) = keys[args[0].(string)]
+					return nil
+				}}
+			}
+			return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected query: " + sql) }}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "SET sort_key") {
+				newKey = args[2].(int64)
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+
+	moved, err := placeBeforeRadioTracks(context.Background(), tx, "pl-1", "tr-new")
+	if err != nil || !moved {
+		t.Fatalf("expected move, got %v, %v", moved, err)
+	}
+	if newKey <= keys["tr-user"] || newKey >= keys["tr-radio"] {
+		t.Errorf("expected key between user and radio tracks, got %d", newKey)
+	}
+}
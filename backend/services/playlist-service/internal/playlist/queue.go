@@ -0,0 +1,162 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// upNextClaimTTL is how long the first instance to announce a track keeps
+// the others from announcing it again.
+const upNextClaimTTL = 10 * time.Minute
+
+// EnableUpNext makes the scheduler publish a "player.up_next" event lead
+// before the playing track ends, so players can pre-buffer the next one.
+func (s *Server) EnableUpNext(lead time.Duration) {
+	s.upNextLead = lead
+}
+
+// buildQueue estimates when each queued track of pl starts. The queue runs
+// from the end of the playing track; once a track of unknown duration is
+// ahead, or nothing is playing, start times are unknown. Unavailable tracks
+// are skipped by playback and get no estimate.
+func buildQueue(pl Playlist, tracks []Track, now time.Time) PlaylistQueue {
+	q := PlaylistQueue{PlaylistID: pl.ID, Tracks: []QueuedTrack{}, GeneratedAt: now}
+
+	var cursor *time.Time
+	for i := range tracks {
+		tr := tracks[i]
+		if tr.Status == "playing" && pl.PlayingStartedAt != nil {
+			q.Current = &tr
+			q.StartedAt = pl.PlayingStartedAt
+			if tr.DurationMs > 0 {
+				end := pl.PlayingStartedAt.Add(time.Duration(tr.DurationMs) * time.Millisecond)
+				q.EndsAt = &end
+				// An overdue track is about to be advanced.
+				next := end
+				if next.Before(now) {
+					next = now
+				}
+				cursor = &next
+			}
+		}
+	}
+
+	for _, tr := range tracks {
+		if tr.Status != "queued" {
+			continue
+		}
+		qt := QueuedTrack{Track: tr}
+		if cursor != nil && !tr.Unavailable {
+			start := *cursor
+			qt.EstimatedStartAt = &start
+			if tr.DurationMs > 0 {
+				next := start.Add(time.Duration(tr.DurationMs) * time.Millisecond)
+				cursor = &next
+			} else {
+				cursor = nil
+			}
+		}
+		q.Tracks = append(q.Tracks, qt)
+	}
+	return q
+}
+
+// handleGetQueue returns the playing track and the queue with estimated
+// start times.
+// GET /playlists/{id}/queue
+func (s *Server) handleGetQueue(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "queue access")
+		return
+	}
+	pl, err := s.store.GetPlaylist(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "get playlist")
+		return
+	}
+	tracks, err := s.store.ListTracks(ctx, playlistID, userID)
+	if err != nil {
+		writeHTTPError(w, err, "list tracks")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, buildQueue(pl, tracks, time.Now()))
+}
+
+// upNextEvent builds the "player.up_next" event announcing the track that
+// follows trackID. It returns nil when trackID is no longer playing or
+// nothing is queued after it.
+func (s *Server) upNextEvent(ctx context.Context, playlistID, trackID string) (map[string]any, error) {
+	pl, err := s.store.GetPlaylist(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pl.CurrentTrackID == nil || *pl.CurrentTrackID != trackID {
+		return nil, nil
+	}
+	tracks, err := s.store.ListTracks(ctx, playlistID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	q := buildQueue(pl, tracks, time.Now())
+	for _, qt := range q.Tracks {
+		if qt.Unavailable {
+			continue
+		}
+		return map[string]any{
+			"type": "player.up_next",
+			"payload": map[string]any{
+				"playlistId":     playlistID,
+				"currentTrackId": trackID,
+				"endsAt":         q.EndsAt,
+				"track":          qt.Track,
+			},
+		}, nil
+	}
+	return nil, nil
+}
+
+// announceUpNext publishes the "player.up_next" event of trackID. Every
+// instance schedules it; a Redis key makes sure it goes out once.
+func (s *Server) announceUpNext(ctx context.Context, playlistID, trackID string) {
+	if s.rdb == nil {
+		return
+	}
+	event, err := s.upNextEvent(ctx, playlistID, trackID)
+	if err != nil {
+		log.Printf("playlist-service: up next %s: %v", playlistID, err)
+		return
+	}
+	if event == nil {
+		return
+	}
+	claimed, err := s.rdb.SetNX(ctx, "playlist:up_next:"+playlistID+":"+trackID, 1, upNextClaimTTL).Result()
+	if err != nil {
+		log.Printf("playlist-service: up next claim: %v", err)
+		return
+	}
+	if claimed {
+		s.publishEvent(ctx, event)
+	}
+}
@@ -0,0 +1,201 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBuildQueue(t *testing.T) {
+	now := time.Date(2026, 5, 1, 20, 0, 0, 0, time.UTC)
+	minute := int(time.Minute / time.Millisecond)
+	current := "cur"
+
+	tests := []struct {
+		name      string
+		startedAt time.Time
+		tracks    []Track
+		wantEnds  *time.Time
+		want      []time.Duration // offsets from now; -1 for no estimate
+	}{
+		{
+			name:      "From The Remaining Time",
+			startedAt: now.Add(-time.Minute),
+			tracks: []Track{
+				{ID: "old", Status: "played", DurationMs: minute},
+				{ID: "cur", Status: "playing", DurationMs: 3 * minute},
+				{ID: "a", Status: "queued", DurationMs: minute},
+				{ID: "b", Status: "queued", DurationMs: 2 * minute},
+				{ID: "c", Status: "queued"},
+			},
+			wantEnds: ptrTime(now.Add(2 * time.Minute)),
+			want:     []time.Duration{2 * time.Minute, 3 * time.Minute, 5 * time.Minute},
+		},
+		{
+			name:      "Unknown Duration Ahead",
+			startedAt: now,
+			tracks: []Track{
+				{ID: "cur", Status: "playing", DurationMs: minute},
+				{ID: "a", Status: "queued"},
+				{ID: "b", Status: "queued", DurationMs: minute},
+			},
+			wantEnds: ptrTime(now.Add(time.Minute)),
+			want:     []time.Duration{time.Minute, -1},
+		},
+		{
+			name:      "Unavailable Tracks Are Skipped",
+			startedAt: now,
+			tracks: []Track{
+				{ID: "cur", Status: "playing", DurationMs: minute},
+				{ID: "a", Status: "queued", DurationMs: minute, Unavailable: true},
+				{ID: "b", Status: "queued", DurationMs: minute},
+			},
+			wantEnds: ptrTime(now.Add(time.Minute)),
+			want:     []time.Duration{-1, time.Minute},
+		},
+		{
+			name:      "Overdue Track",
+			startedAt: now.Add(-time.Hour),
+			tracks: []Track{
+				{ID: "cur", Status: "playing", DurationMs: minute},
+				{ID: "a", Status: "queued", DurationMs: minute},
+			},
+			wantEnds: ptrTime(now.Add(-59 * time.Minute)),
+			want:     []time.Duration{0},
+		},
+		{
+			name:   "Stopped",
+			tracks: []Track{{ID: "a", Status: "queued", DurationMs: minute}},
+			want:   []time.Duration{-1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl := Playlist{ID: "pl-1"}
+			if !tt.startedAt.IsZero() {
+				pl.CurrentTrackID, pl.PlayingStartedAt = &current, &tt.startedAt
+			}
+			q := buildQueue(pl, tt.tracks, now)
+
+			if (q.EndsAt == nil) != (tt.wantEnds == nil) || (q.EndsAt != nil && !q.EndsAt.Equal(*tt.wantEnds)) {
+				t.Errorf("endsAt = %v, want %v", q.EndsAt, tt.wantEnds)
+			}
+			if len(q.Tracks) != len(tt.want) {
+				t.Fatalf("expected %d queued tracks, got %+v", len(tt.want), q.Tracks)
+			}
+			for i, off := range tt.want {
+				got := q.Tracks[i].EstimatedStartAt
+				if off < 0 {
+					if got != nil {
+						t.Errorf("track %s: expected no estimate, got %v", q.Tracks[i].ID, got)
+					}
+					continue
+				}
+				if got == nil || !got.Equal(now.Add(off)) {
+					t.Errorf("track %s: expected %v, got %v", q.Tracks[i].ID, now.Add(off), got)
+				}
+			}
+		})
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
+
+func TestHandleGetQueue(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	pl := Playlist{OwnerID: "owner-1", Name: "Bar", IsPublic: false, EditMode: editModeEveryone}
+	if err := store.CreatePlaylist(ctx, &pl, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"One", "Two", "Three"} {
+		in := trackInput{Title: title, Artist: "Band", DurationMs: int(time.Minute / time.Millisecond)}
+		if _, err := store.AddTrack(ctx, pl.ID, in, trackSourceUser); err != nil {
+			t.Fatal(err)
+		}
+	}
+	started := time.Now()
+	if _, err := store.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", started); err != nil {
+		t.Fatal(err)
+	}
+	h := NewServerWithStore(store, nil).Router()
+	path := "/playlists/" + pl.ID + "/queue"
+
+	if w := serveAs(h, "stranger", "GET", path, nil); w.Code != http.StatusForbidden {
+		t.Errorf("private playlist: got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "GET", "/playlists/"+missingID+"/queue", nil); w.Code != http.StatusNotFound {
+		t.Errorf("missing playlist: got %d", w.Code)
+	}
+
+	w := serveAs(h, "owner-1", "GET", path, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var q PlaylistQueue
+	_ = json.NewDecoder(w.Body).Decode(&q)
+	if q.Current == nil || q.Current.Title != "One" || len(q.Tracks) != 2 {
+		t.Fatalf("queue = %+v", q)
+	}
+	if q.Tracks[1].EstimatedStartAt == nil || q.Tracks[1].EstimatedStartAt.Sub(started).Round(time.Second) != 2*time.Minute {
+		t.Errorf("estimate of the last track = %v", q.Tracks[1].EstimatedStartAt)
+	}
+}
+
+func TestUpNextEvent(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	pl := Playlist{OwnerID: "owner-1", Name: "Bar", IsPublic: true, EditMode: editModeEveryone}
+	if err := store.CreatePlaylist(ctx, &pl, nil); err != nil {
+		t.Fatal(err)
+	}
+	one, _ := store.AddTrack(ctx, pl.ID, trackInput{Title: "One", DurationMs: 60000}, trackSourceUser)
+	broken, _ := store.AddTrack(ctx, pl.ID, trackInput{Title: "Broken"}, trackSourceUser)
+	store.playlists[pl.ID].tracks[1].Unavailable = true
+	srv := NewServerWithStore(store, nil)
+
+	if ev, err := srv.upNextEvent(ctx, pl.ID, one.ID); err != nil || ev != nil {
+		t.Errorf("nothing playing: %v, %v", ev, err)
+	}
+	if _, err := store.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if ev, err := srv.upNextEvent(ctx, pl.ID, one.ID); err != nil || ev != nil {
+		t.Errorf("only an unavailable track queued: %v, %v", ev, err)
+	}
+
+	two, _ := store.AddTrack(ctx, pl.ID, trackInput{Title: "Two"}, trackSourceUser)
+	ev, err := srv.upNextEvent(ctx, pl.ID, one.ID)
+	if err != nil || ev == nil {
+		t.Fatalf("upNextEvent = %v, %v", ev, err)
+	}
+	payload := ev["payload"].(map[string]any)
+	if ev["type"] != "player.up_next" || payload["track"].(Track).ID != two.ID || payload["currentTrackId"] != one.ID {
+		t.Errorf("event = %+v", ev)
+	}
+	if ev, _ := srv.upNextEvent(ctx, pl.ID, broken.ID); ev != nil {
+		t.Errorf("announcing a track that is not playing: %v", ev)
+	}
+}
+
+func TestScheduler_ArmsUpNext(t *testing.T) {
+	srv := NewServer(&MockDB{}, nil)
+	sc := newTestScheduler(srv)
+	defer sc.stopAll()
+
+	sc.arm("pl-1", "track-1", time.Now().Add(time.Hour))
+	if sc.timers["pl-1"].upNext != nil {
+		t.Errorf("expected no up-next timer without EnableUpNext")
+	}
+
+	srv.EnableUpNext(10 * time.Second)
+	sc.arm("pl-1", "track-2", time.Now().Add(time.Hour))
+	if sc.timers["pl-1"].upNext == nil {
+		t.Errorf("expected an up-next timer")
+	}
+}
@@ -0,0 +1,194 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRadioMinQueue = 3
+	maxRadioMinQueue     = 20
+
+	radioSeedQueries = 3
+	radioSearchLimit = 10
+	radioFillTimeout = 20 * time.Second
+)
+
+// EnableRadio lets playlists with radio mode on refill their queue from
+// music-provider-service, seeded with recently played artists and the
+// owner's preferences from user-service.
+func (s *Server) EnableRadio(musicProviderURL, userServiceURL string) {
+	s.music = &musicProviderClient{baseURL: musicProviderURL}
+	s.prefs = &userServiceClient{baseURL: userServiceURL}
+}
+
+// triggerRadioFill tops up the queue of playlistID in the background. At
+// most one fill per playlist runs at a time on this instance.
+func (s *Server) triggerRadioFill(playlistID string) {
+	if s.music == nil {
+		return
+	}
+	if _, busy := s.radioBusy.LoadOrStore(playlistID, struct{}{}); busy {
+		return
+	}
+	go func() {
+		defer s.radioBusy.Delete(playlistID)
+		ctx, cancel := context.WithTimeout(context.Background(), radioFillTimeout)
+		defer cancel()
+		if _, err := s.fillRadio(ctx, playlistID); err != nil {
+			log.Printf("playlist-service: radio fill %s: %v", playlistID, err)
+		}
+	}()
+}
+
+// fillRadio appends radio tracks until the playlist's RadioMinQueue tracks
+// are queued. It is a no-op unless the playlist has radio mode on and is not
+// a smart playlist. It returns the number of tracks added.
+func (s *Server) fillRadio(ctx context.Context, playlistID string) (int, error) {
+	if s.music == nil {
+		return 0, nil
+	}
+
+	settings, err := s.store.RadioSettings(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("radio settings: %w", err)
+	}
+	if !settings.Enabled || settings.Queued >= settings.MinQueue {
+		return 0, nil
+	}
+
+	// Provider calls happen before the store locks the playlist, so no lock
+	// is held while waiting on the network.
+	var candidates []trackInput
+	for _, q := range s.radioSeeds(ctx, playlistID, settings.OwnerID) {
+		items, err := s.music.SearchTracks(ctx, q, radioSearchLimit)
+		if err != nil {
+			log.Printf("playlist-service: radio search %q: %v", q, err)
+			continue
+		}
+		candidates = append(candidates, items...)
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	added, err := s.store.AddRadioTracks(ctx, playlistID, settings.MinQueue, candidates, settings.Rules)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, tr := range added {
+		s.publishEvent(ctx, map[string]any{
+			"type": "track.added",
+			"payload": map[string]any{
+				"playlistId": playlistID,
+				"track":      tr,
+			},
+		})
+	}
+	return len(added), nil
+}
+
+// radioSettings is what a radio fill needs to know about a playlist.
+type radioSettings struct {
+	OwnerID  string
+	Enabled  bool // radio mode is on and it is not a smart playlist
+	MinQueue int
+	Queued   int
+	Rules    ContentRules
+}
+
+// pickRadioTracks picks the candidates that bring a queue of queued tracks
+// up to want. It skips invalid candidates, those without a provider or
+// breaking rules, and the ones seen already: seen holds the keys of the
+// playlist's tracks (see knownTracks) and gets the picked tracks' keys
+// added.
+func pickRadioTracks(candidates []trackInput, rules ContentRules, seen map[string]bool, queued, want int) []trackInput {
+	var picked []trackInput
+	for _, c := range candidates {
+		if queued+len(picked) >= want {
+			break
+		}
+		if c.normalize() != "" || c.Provider == "" || rules.violation(c) != "" {
+			continue
+		}
+		key := normalizeTrackText(c.Title) + "|" + normalizeArtist(c.Artist)
+		if seen[c.Provider+":"+c.ProviderTrack] || seen[key] {
+			continue
+		}
+		seen[c.Provider+":"+c.ProviderTrack] = true
+		seen[key] = true
+		picked = append(picked, c)
+	}
+	return picked
+}
+
+// radioSeeds builds search queries from the artists played most recently in
+// the playlist and the owner's preferred artists, genres and moods.
+func (s *Server) radioSeeds(ctx context.Context, playlistID, ownerID string) []string {
+	var seeds []string
+	add := func(q string) {
+		q = strings.TrimSpace(q)
+		if q == "" {
+			return
+		}
+		for _, existing := range seeds {
+			if strings.EqualFold(existing, q) {
+				return
+			}
+		}
+		seeds = append(seeds, q)
+	}
+
+	artists, err := s.store.RecentArtists(ctx, playlistID, 5)
+	if err != nil {
+		log.Printf("playlist-service: radio seeds: %v", err)
+	}
+	for _, artist := range artists {
+		add(artist)
+	}
+
+	var prefSeeds []string
+	if s.prefs != nil {
+		prefs, err := s.prefs.Preferences(ctx, ownerID)
+		if err != nil {
+			log.Printf("playlist-service: radio owner preferences: %v", err)
+		}
+		prefSeeds = append(prefSeeds, prefs.Artists...)
+		for _, g := range prefs.Genres {
+			prefSeeds = append(prefSeeds, g+" music")
+		}
+		for _, m := range prefs.Moods {
+			prefSeeds = append(prefSeeds, m+" music")
+		}
+	}
+
+	// Recent plays keep the mood of the night; preferences add variety.
+	rand.Shuffle(len(seeds), func(i, j int) { seeds[i], seeds[j] = seeds[j], seeds[i] })
+	rand.Shuffle(len(prefSeeds), func(i, j int) { prefSeeds[i], prefSeeds[j] = prefSeeds[j], prefSeeds[i] })
+	if len(seeds) > radioSeedQueries-1 && len(prefSeeds) > 0 {
+		seeds = seeds[:radioSeedQueries-1]
+	}
+	for _, q := range prefSeeds {
+		add(q)
+	}
+	if len(seeds) > radioSeedQueries {
+		seeds = seeds[:radioSeedQueries]
+	}
+	return seeds
+}
+
+func isValidRadioMinQueue(n int) bool {
+	return n >= 1 && n <= maxRadioMinQueue
+}
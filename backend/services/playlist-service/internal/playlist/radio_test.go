@@ -0,0 +1,209 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type fakeSearcher struct {
+	items   []trackInput
+	queries []string
+}
+
+func (f *fakeSearcher) SearchTracks(ctx context.Context, query string, limit int) ([]trackInput, error) {
+	f.queries = append(f.queries, query)
+	return f.items, nil
+}
+
+type fakePrefs struct {
+	prefs musicPreferences
+}
+
+func (f fakePrefs) Preferences(ctx context.Context, userID string) (musicPreferences, error) {
+	return f.prefs, nil
+}
+
+// radioDB answers the settings and seed queries of fillRadio.
+func radioDB(enabled bool, minQueue, queued int, tx *MockTx) *MockDB {
+	return &MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if strings.Contains(sql, "radio_enabled") {
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "owner-1"
+					*dest[1].(*bool) = enabled
+					*dest[2].(*int) = minQueue
+					*dest[3].(*int) = queued
+					return nil
+				}}
+			}
+			return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected query: " + sql) }}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if strings.Contains(sql, "FROM play_history") {
+				return &MockRows{Data: [][]any{{"Daft Punk"}}, Idx: -1}, nil
+			}
+			return nil, errors.New("unexpected query: " + sql)
+		},
+		BeginTxFunc: func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+			if tx == nil {
+				return nil, errors.New("unexpected transaction")
+			}
+			return tx, nil
+		},
+	}
+}
+
+func TestFillRadio_AddsUntilThreshold(t *testing.T) {
+	var inserted []string
+	var sources []any
+	committed := false
+	tx := &MockTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			switch {
+			case strings.Contains(sql, "SELECT COUNT(*)::int FROM tracks"):
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*int) = 1
+					return nil
+				}}
+			case strings.Contains(sql, "INSERT INTO tracks"):
+				inserted = append(inserted, args[4].(string))
+				sources = append(sources, args[8])
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "tr-" + args[4].(string)
+					return nil
+				}}
+			}
+			return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected query: " + sql) }}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			// The playlist already holds vid-1.
+			return &MockRows{Data: [][]any{{"youtube", "vid-1", "Around the World", "Daft Punk"}}, Idx: -1}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			return pgconn.CommandTag{}, nil
+		},
+		CommitFunc: func(ctx context.Context) error {
+			committed = true
+			return nil
+		},
+	}
+
+	srv := NewServer(radioDB(true, 3, 1, tx), nil)
+	searcher := &fakeSearcher{items: []trackInput{
+		{Title: "Around the World", Artist: "Daft Punk", Provider: "youtube", ProviderTrack: "vid-1"},
+		{Title: "Around the World (Official Video)", Artist: "Daft Punk", Provider: "youtube", ProviderTrack: "vid-9"},
+		{Title: "", Artist: "Nobody", Provider: "youtube", ProviderTrack: "vid-2"},
+		{Title: "One More Time", Artist: "Daft Punk", Provider: "youtube", ProviderTrack: "vid-3"},
+		{Title: "Digital Love", Artist: "Daft Punk", Provider: "youtube", ProviderTrack: "vid-4"},
+		{Title: "Aerodynamic", Artist: "Daft Punk", Provider: "youtube", ProviderTrack: "vid-5"},
+	}}
+	srv.music = searcher
+	srv.prefs = fakePrefs{prefs: musicPreferences{Genres: []string{"house"}}}
+
+	added, err := srv.fillRadio(context.Background(), "pl-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if added != 2 || len(inserted) != 2 {
+		t.Fatalf("expected 2 tracks to reach the threshold of 3, got %d (%v)", added, inserted)
+	}
+	for i, id := range inserted {
+		if id == "vid-1" || id == "vid-9" || id == "vid-2" {
+			t.Errorf("inserted known or invalid track %s", id)
+		}
+		if sources[i] != trackSourceRadio {
+			t.Errorf("expected source %q, got %v", trackSourceRadio, sources[i])
+		}
+	}
+	if !committed {
+		t.Error("expected transaction to be committed")
+	}
+	if len(searcher.queries) != 2 {
+		t.Errorf("expected history and preference seeds, got %v", searcher.queries)
+	}
+}
+
+func TestFillRadio_SkipsBlockedTracks(t *testing.T) {
+	var inserted []string
+	tx := &MockTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if strings.Contains(sql, "INSERT INTO tracks") {
+				inserted = append(inserted, args[4].(string))
+			}
+			return &MockRow{}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			return &MockRows{Idx: -1}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	db := radioDB(true, 3, 0, tx)
+	settings := db.QueryRowFunc
+	db.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+		row := settings(ctx, sql, args...)
+		return &MockRow{ScanFunc: func(dest ...any) error {
+			if err := row.Scan(dest...); err != nil {
+				return err
+			}
+			*dest[4].(*[]byte) = []byte(`{"blockedArtists":["Daft Punk"],"maxDurationMs":300000}`)
+			return nil
+		}}
+	}
+
+	srv := NewServer(db, nil)
+	srv.music = &fakeSearcher{items: []trackInput{
+		{Title: "One More Time", Artist: "Daft Punk", Provider: "youtube", ProviderTrack: "vid-1"},
+		{Title: "Long Mix", Artist: "Justice", Provider: "youtube", ProviderTrack: "vid-2", DurationMs: 600000},
+		{Title: "Genesis", Artist: "Justice", Provider: "youtube", ProviderTrack: "vid-3", DurationMs: 240000},
+	}}
+
+	added, err := srv.fillRadio(context.Background(), "pl-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if added != 1 || len(inserted) != 1 || inserted[0] != "vid-3" {
+		t.Errorf("expected only vid-3 to pass the content rules, got %d (%v)", added, inserted)
+	}
+}
+
+func TestFillRadio_NoOp(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		minQueue int
+		queued   int
+	}{
+		{name: "Radio disabled", enabled: false, minQueue: 3, queued: 0},
+		{name: "Queue above threshold", enabled: true, minQueue: 3, queued: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(radioDB(tt.enabled, tt.minQueue, tt.queued, nil), nil)
+			searcher := &fakeSearcher{}
+			srv.music = searcher
+
+			added, err := srv.fillRadio(context.Background(), "pl-1")
+			if err != nil || added != 0 {
+				t.Fatalf("expected no-op, got %d, %v", added, err)
+			}
+			if len(searcher.queries) != 0 {
+				t.Errorf("expected no search, got %v", searcher.queries)
+			}
+		})
+	}
+}
+
+func TestFillRadio_WithoutProvider(t *testing.T) {
+	srv := NewServer(&MockDB{}, nil)
+	if added, err := srv.fillRadio(context.Background(), "pl-1"); err != nil || added != 0 {
+		t.Fatalf("expected no-op, got %d, %v", added, err)
+	}
+}
@@ -0,0 +1,63 @@
+package playlist
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a fixed-window counter per key, the same scheme the gateway
+// uses. Counts are per instance, so with several replicas the effective
+// limit is a multiple of the configured one.
+type rateLimiter struct {
+	mu              sync.Mutex
+	data            map[string]*rateInfo
+	lastCleanup     time.Time
+	cleanupInterval time.Duration
+}
+
+type rateInfo struct {
+	count   int
+	resetAt time.Time
+}
+
+func newRateLimiter(cleanupInterval time.Duration) *rateLimiter {
+	return &rateLimiter{
+		data:            map[string]*rateInfo{},
+		cleanupInterval: cleanupInterval,
+	}
+}
+
+// allow counts one request for key and reports whether it is within limit
+// for the current window. Otherwise it also returns the seconds until the
+// window resets.
+func (l *rateLimiter) allow(key string, window time.Duration, limit int) (ok bool, retryAfterSeconds int) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lastCleanup.IsZero() || now.Sub(l.lastCleanup) > l.cleanupInterval {
+		for k, info := range l.data {
+			if now.After(info.resetAt.Add(l.cleanupInterval)) {
+				delete(l.data, k)
+			}
+		}
+		l.lastCleanup = now
+	}
+
+	ri, exists := l.data[key]
+	if !exists || now.After(ri.resetAt) {
+		ri = &rateInfo{resetAt: now.Add(window)}
+		l.data[key] = ri
+	}
+
+	ri.count++
+	if ri.count > limit {
+		sec := int(ri.resetAt.Sub(now).Seconds())
+		if sec < 0 {
+			sec = 0
+		}
+		return false, sec
+	}
+	return true, 0
+}
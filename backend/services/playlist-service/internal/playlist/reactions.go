@@ -0,0 +1,243 @@
+package playlist
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// trackReactionEmojis are the reactions guests can leave on a track.
+var trackReactionEmojis = map[string]bool{
+	"🔥": true, "❤️": true, "😂": true, "👏": true, "😮": true, "😢": true, "🎉": true,
+}
+
+const maxCommentLength = 280 // characters
+
+// Per-user rate limits, per playlist.
+const (
+	reactionRateWindow = 10 * time.Second
+	reactionRateLimit  = 20
+	commentRateWindow  = time.Minute
+	commentRateLimit   = 5
+)
+
+// handleAddReaction adds the caller's emoji reaction to a track.
+// POST /playlists/{id}/tracks/{trackId}/reactions
+// Body: {"emoji": "🔥"}
+func (s *Server) handleAddReaction(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Emoji string `json:"emoji"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+	s.setReaction(w, r, body.Emoji, true)
+}
+
+// handleRemoveReaction removes the caller's emoji reaction from a track.
+// DELETE /playlists/{id}/tracks/{trackId}/reactions?emoji=🔥
+func (s *Server) handleRemoveReaction(w http.ResponseWriter, r *http.Request) {
+	s.setReaction(w, r, r.URL.Query().Get("emoji"), false)
+}
+
+func (s *Server) setReaction(w http.ResponseWriter, r *http.Request, emoji string, on bool) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+	trackID := chi.URLParam(r, "trackId")
+	if !trackReactionEmojis[emoji] {
+		writeError(w, http.StatusBadRequest, "unsupported emoji")
+		return
+	}
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "reaction access")
+		return
+	}
+	if !s.allowRate(w, "reaction:"+playlistID+":"+userID, reactionRateWindow, reactionRateLimit) {
+		return
+	}
+
+	counts, err := s.store.SetReaction(ctx, playlistID, trackID, userID, emoji, on)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "track not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "set reaction")
+		return
+	}
+
+	action := "added"
+	if !on {
+		action = "removed"
+	}
+	s.publishEvent(ctx, map[string]any{
+		"type": "track.reaction",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"trackId":    trackID,
+			"userId":     userID,
+			"emoji":      emoji,
+			"action":     action,
+			"reactions":  counts,
+		},
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{"reactions": counts})
+}
+
+// handleListComments returns the comments on a track, oldest first.
+// GET /playlists/{id}/tracks/{trackId}/comments
+func (s *Server) handleListComments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	playlistID := chi.URLParam(r, "id")
+	trackID := chi.URLParam(r, "trackId")
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "list comments access")
+		return
+	}
+	if _, err := s.store.GetTrack(ctx, playlistID, trackID); errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "track not found")
+		return
+	} else if err != nil {
+		writeHTTPError(w, err, "list comments track")
+		return
+	}
+
+	comments, err := s.store.ListComments(ctx, playlistID, trackID)
+	if err != nil {
+		writeHTTPError(w, err, "list comments")
+		return
+	}
+	writeJSON(w, http.StatusOK, comments)
+}
+
+// handleAddComment posts a short comment on a track.
+// POST /playlists/{id}/tracks/{trackId}/comments
+// Body: {"body": "what a tune"}
+func (s *Server) handleAddComment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+	trackID := chi.URLParam(r, "trackId")
+
+	var body struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+	text := strings.TrimSpace(body.Body)
+	if text == "" || utf8.RuneCountInString(text) > maxCommentLength {
+		writeError(w, http.StatusBadRequest, "comment must be between 1 and "+strconv.Itoa(maxCommentLength)+" characters")
+		return
+	}
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "add comment access")
+		return
+	}
+	if !s.allowRate(w, "comment:"+playlistID+":"+userID, commentRateWindow, commentRateLimit) {
+		return
+	}
+
+	c := TrackComment{PlaylistID: playlistID, TrackID: trackID, UserID: userID, Body: text}
+	err := s.store.AddComment(ctx, &c)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "track not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "add comment")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type":    "track.comment",
+		"payload": c,
+	})
+
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// handleDeleteComment removes a comment. Authors may delete their own
+// comments; the playlist owner may delete any.
+// DELETE /playlists/{id}/tracks/{trackId}/comments/{commentId}
+func (s *Server) handleDeleteComment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+	trackID := chi.URLParam(r, "trackId")
+	commentID := chi.URLParam(r, "commentId")
+
+	ownerID, _, _, err := s.getPlaylistAccessInfo(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "delete comment access")
+		return
+	}
+
+	err = s.store.DeleteComment(ctx, playlistID, trackID, commentID, func(c TrackComment) error {
+		if c.UserID != userID && userID != ownerID {
+			return &httpError{status: http.StatusForbidden, msg: "forbidden"}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "comment not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "delete comment")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "track.comment.deleted",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"trackId":    trackID,
+			"commentId":  commentID,
+			"deletedBy":  userID,
+		},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowRate counts one request against key and responds 429 with a
+// Retry-After header once the limit is reached.
+func (s *Server) allowRate(w http.ResponseWriter, key string, window time.Duration, limit int) bool {
+	ok, retry := s.limiter.allow(key, window, limit)
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(retry))
+		writeError(w, http.StatusTooManyRequests, "too many requests")
+	}
+	return ok
+}
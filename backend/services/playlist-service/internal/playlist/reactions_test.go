@@ -0,0 +1,172 @@
+package playlist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newReactionTestServer returns a memory-backed server with a public and a
+// private playlist of one track each, both owned by owner-1.
+func newReactionTestServer(t *testing.T) (http.Handler, Track, Track) {
+	t.Helper()
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	var tracks []Track
+	for _, public := range []bool{true, false} {
+		pl := Playlist{OwnerID: "owner-1", Name: "Friday", IsPublic: public, EditMode: editModeEveryone}
+		if err := store.CreatePlaylist(ctx, &pl, nil); err != nil {
+			t.Fatal(err)
+		}
+		tr, err := store.AddTrack(ctx, pl.ID, trackInput{Title: "Song", Artist: "Band"}, trackSourceUser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tracks = append(tracks, tr)
+	}
+	return NewServerWithStore(store, nil).Router(), tracks[0], tracks[1]
+}
+
+func serveAs(h http.Handler, userID, method, target string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, target, &buf)
+	if userID != "" {
+		req.Header.Set("X-User-Id", userID)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleReactions(t *testing.T) {
+	h, public, private := newReactionTestServer(t)
+	path := "/playlists/" + public.PlaylistID + "/tracks/" + public.ID + "/reactions"
+
+	tests := []struct {
+		name     string
+		userID   string
+		method   string
+		target   string
+		body     any
+		wantCode int
+		want     map[string]int
+	}{
+		{name: "Add", userID: "u-1", method: "POST", target: path, body: map[string]string{"emoji": "🔥"}, wantCode: http.StatusOK, want: map[string]int{"🔥": 1}},
+		{name: "Add Again", userID: "u-1", method: "POST", target: path, body: map[string]string{"emoji": "🔥"}, wantCode: http.StatusOK, want: map[string]int{"🔥": 1}},
+		{name: "Other User", userID: "u-2", method: "POST", target: path, body: map[string]string{"emoji": "🔥"}, wantCode: http.StatusOK, want: map[string]int{"🔥": 2}},
+		{name: "Remove", userID: "u-1", method: "DELETE", target: path + "?emoji=%F0%9F%94%A5", wantCode: http.StatusOK, want: map[string]int{"🔥": 1}},
+		{name: "Unsupported Emoji", userID: "u-1", method: "POST", target: path, body: map[string]string{"emoji": "💩"}, wantCode: http.StatusBadRequest},
+		{name: "No User", method: "POST", target: path, body: map[string]string{"emoji": "🔥"}, wantCode: http.StatusUnauthorized},
+		{name: "Missing Track", userID: "u-1", method: "POST", target: "/playlists/" + public.PlaylistID + "/tracks/" + missingID + "/reactions", body: map[string]string{"emoji": "🔥"}, wantCode: http.StatusNotFound},
+		{name: "Private", userID: "u-1", method: "POST", target: "/playlists/" + private.PlaylistID + "/tracks/" + private.ID + "/reactions", body: map[string]string{"emoji": "🔥"}, wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAs(h, tt.userID, tt.method, tt.target, tt.body)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d. Body: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if tt.want == nil {
+				return
+			}
+			var resp struct {
+				Reactions map[string]int `json:"reactions"`
+			}
+			_ = json.NewDecoder(w.Body).Decode(&resp)
+			if len(resp.Reactions) != len(tt.want) || resp.Reactions["🔥"] != tt.want["🔥"] {
+				t.Errorf("reactions = %v, want %v", resp.Reactions, tt.want)
+			}
+		})
+	}
+
+	w := serveAs(h, "u-1", "GET", "/playlists/"+public.PlaylistID, nil)
+	var got struct {
+		Tracks []Track `json:"tracks"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if len(got.Tracks) != 1 || got.Tracks[0].Reactions["🔥"] != 1 {
+		t.Errorf("track payload = %+v", got.Tracks)
+	}
+}
+
+func TestHandleReactionsRateLimit(t *testing.T) {
+	h, public, _ := newReactionTestServer(t)
+	path := "/playlists/" + public.PlaylistID + "/tracks/" + public.ID + "/reactions"
+
+	for i := 0; i < reactionRateLimit; i++ {
+		if w := serveAs(h, "u-1", "POST", path, map[string]string{"emoji": "👏"}); w.Code != http.StatusOK {
+			t.Fatalf("reaction %d: got %d", i, w.Code)
+		}
+	}
+	w := serveAs(h, "u-1", "POST", path, map[string]string{"emoji": "👏"})
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	if w := serveAs(h, "u-2", "POST", path, map[string]string{"emoji": "👏"}); w.Code != http.StatusOK {
+		t.Errorf("other users are not limited, got %d", w.Code)
+	}
+}
+
+func TestHandleComments(t *testing.T) {
+	h, public, private := newReactionTestServer(t)
+	path := "/playlists/" + public.PlaylistID + "/tracks/" + public.ID + "/comments"
+
+	if w := serveAs(h, "u-1", "POST", path, map[string]string{"body": "   "}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an empty comment, got %d", w.Code)
+	}
+	if w := serveAs(h, "u-1", "POST", path, map[string]string{"body": strings.Repeat("é", maxCommentLength+1)}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a long comment, got %d", w.Code)
+	}
+	privatePath := "/playlists/" + private.PlaylistID + "/tracks/" + private.ID + "/comments"
+	if w := serveAs(h, "u-1", "POST", privatePath, map[string]string{"body": "hi"}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 on a private playlist, got %d", w.Code)
+	}
+
+	w := serveAs(h, "u-1", "POST", path, map[string]string{"body": " what a tune "})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var c TrackComment
+	_ = json.NewDecoder(w.Body).Decode(&c)
+	if c.ID == "" || c.Body != "what a tune" || c.UserID != "u-1" || c.TrackID != public.ID {
+		t.Errorf("unexpected comment: %+v", c)
+	}
+	serveAs(h, "u-2", "POST", path, map[string]string{"body": "agreed"})
+
+	w = serveAs(h, "", "GET", path, nil)
+	var comments []TrackComment
+	_ = json.NewDecoder(w.Body).Decode(&comments)
+	if w.Code != http.StatusOK || len(comments) != 2 || comments[1].Body != "agreed" {
+		t.Fatalf("list = %d %+v", w.Code, comments)
+	}
+
+	// Authors delete their own comments, the owner any.
+	if w := serveAs(h, "u-2", "DELETE", path+"/"+c.ID, nil); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for another user, got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "DELETE", path+"/"+c.ID, nil); w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 for the owner, got %d", w.Code)
+	}
+	if w := serveAs(h, "u-2", "DELETE", path+"/"+comments[1].ID, nil); w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 for the author, got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "DELETE", path+"/"+c.ID, nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a deleted comment, got %d", w.Code)
+	}
+
+	for i := 0; i < commentRateLimit-1; i++ {
+		serveAs(h, "u-1", "POST", path, map[string]string{"body": "again"})
+	}
+	if w := serveAs(h, "u-1", "POST", path, map[string]string{"body": "again"}); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 past the comment limit, got %d", w.Code)
+	}
+}
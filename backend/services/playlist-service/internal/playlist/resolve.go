@@ -0,0 +1,141 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// backfillInterval is how often tracks added without a provider lookup
+	// (before lookups existed, or while the provider was down) are resolved.
+	backfillInterval = time.Minute
+	backfillBatch    = 25
+	// backfillClaim is how long a backfill batch is reserved for the
+	// instance that claimed it; the tracks it did not resolve by then are
+	// claimed again.
+	backfillClaim = 5 * time.Minute
+)
+
+// EnableTrackLookup makes added tracks resolve their provider ID against
+// music-provider-service, which supplies canonical title, artist, thumbnail
+// and duration.
+func (s *Server) EnableTrackLookup(musicProviderURL string) {
+	s.resolver = &musicProviderClient{baseURL: musicProviderURL}
+}
+
+// resolveTrack replaces the client-supplied metadata of a provider track with
+// the provider's. Tracks that do not exist or cannot be embedded are rejected
+// with an *httpError. When the provider is unreachable the client metadata is
+// kept and the backfill resolves the track later.
+func (s *Server) resolveTrack(ctx context.Context, in *trackInput) error {
+	if s.resolver == nil || in.Provider == "" {
+		return nil
+	}
+
+	meta, err := s.resolver.ResolveTrack(ctx, in.Provider, in.ProviderTrack)
+	switch {
+	case errors.Is(err, errTrackNotFound), errors.Is(err, errTrackNotEmbeddable):
+		return &httpError{status: http.StatusUnprocessableEntity, msg: err.Error()}
+	case err != nil:
+		log.Printf("playlist-service: resolve track %s:%s: %v", in.Provider, in.ProviderTrack, err)
+		return nil
+	}
+
+	in.applyCanonical(meta)
+	return nil
+}
+
+// applyCanonical overwrites the metadata the provider knows about.
+func (in *trackInput) applyCanonical(meta trackInput) {
+	if meta.Title != "" {
+		in.Title = meta.Title
+	}
+	if meta.Artist != "" {
+		in.Artist = meta.Artist
+	}
+	if meta.ThumbnailURL != "" {
+		in.ThumbnailURL = meta.ThumbnailURL
+	}
+	if meta.DurationMs > 0 {
+		in.DurationMs = meta.DurationMs
+	}
+	in.resolved = true
+}
+
+// StartTrackBackfill resolves unresolved tracks in the background until ctx
+// is cancelled.
+func (s *Server) StartTrackBackfill(ctx context.Context) {
+	if s.resolver == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(backfillInterval)
+		defer ticker.Stop()
+		for {
+			for {
+				n, err := s.backfillTracks(ctx)
+				if err != nil {
+					log.Printf("playlist-service: track backfill: %v", err)
+				}
+				if err != nil || n < backfillBatch {
+					break
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// unresolvedTrack is a track awaiting a provider lookup.
+type unresolvedTrack struct {
+	ID, PlaylistID, Provider, ProviderTrack string
+}
+
+// backfillTracks resolves one batch of tracks, newest first. Tracks unknown
+// to the provider are flagged unavailable, so playback skips them. It
+// returns the number of tracks resolved and stops at the first lookup
+// failure.
+func (s *Server) backfillTracks(ctx context.Context) (int, error) {
+	pending, err := s.store.ClaimUnresolvedTracks(ctx, backfillBatch, backfillClaim)
+	if err != nil {
+		return 0, fmt.Errorf("claim unresolved: %w", err)
+	}
+
+	for i, t := range pending {
+		meta, err := s.resolver.ResolveTrack(ctx, t.Provider, t.ProviderTrack)
+		unavailable := errors.Is(err, errTrackNotFound) || errors.Is(err, errTrackNotEmbeddable)
+		if err != nil && !unavailable {
+			return i, fmt.Errorf("resolve %s: %w", t.ID, err)
+		}
+
+		var resolved *trackInput
+		if !unavailable {
+			resolved = &meta
+		}
+		tr, err := s.store.MarkTrackResolved(ctx, t.PlaylistID, t.ID, resolved)
+		if errors.Is(err, ErrNotFound) {
+			// Deleted meanwhile.
+			continue
+		}
+		if err != nil {
+			return i, fmt.Errorf("update %s: %w", t.ID, err)
+		}
+		s.publishEvent(ctx, map[string]any{
+			"type": "track.updated",
+			"payload": map[string]any{
+				"playlistId": t.PlaylistID,
+				"trackId":    t.ID,
+				"track":      tr,
+			},
+		})
+	}
+	return len(pending), nil
+}
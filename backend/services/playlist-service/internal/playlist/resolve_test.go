@@ -0,0 +1,255 @@
+package playlist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type fakeResolver struct {
+	meta  trackInput
+	err   error
+	calls int
+}
+
+func (f *fakeResolver) ResolveTrack(ctx context.Context, provider, trackID string) (trackInput, error) {
+	f.calls++
+	return f.meta, f.err
+}
+
+// addTrackDB lets user-1 add tracks to pl-1 and records the insert arguments.
+func addTrackDB(inserted *[]any) *MockDB {
+	db := &MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if row, ok := smartRulesRow(sql); ok {
+				return row
+			}
+			if row, ok := moderationStateRow(sql); ok {
+				return row
+			}
+			if row, ok := contentRulesRow(sql); ok {
+				return row
+			}
+			switch {
+			case strings.Contains(sql, "SELECT owner_id"):
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "user-1"
+					*dest[1].(*bool) = true
+					*dest[2].(*string) = "everyone"
+					return nil
+				}}
+			case strings.Contains(sql, "SELECT duplicate_policy"):
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "allow"
+					return nil
+				}}
+			case strings.Contains(sql, "source = 'radio'"):
+				return &MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+			case strings.Contains(sql, "INSERT INTO tracks"):
+				*inserted = args
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "tr-1"
+					return nil
+				}}
+			}
+			return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected query: " + sql) }}
+		},
+	}
+	// The duplicate check and the insert run in a transaction.
+	db.BeginTxFunc = func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+		return &MockTx{QueryRowFunc: db.QueryRowFunc}, nil
+	}
+	return db
+}
+
+func postTrack(srv *Server, body map[string]any) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Post("/playlists/{id}/tracks", srv.handleAddTrack)
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/playlists/pl-1/tracks", bytes.NewReader(data))
+	req.Header.Set("X-User-Id", "user-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleAddTrack_ResolvesProviderMetadata(t *testing.T) {
+	var inserted []any
+	srv := NewServer(addTrackDB(&inserted), nil)
+	srv.resolver = &fakeResolver{meta: trackInput{
+		Title:        "Canonical Title",
+		Artist:       "Canonical Artist",
+		ThumbnailURL: "http://img/canonical.jpg",
+		DurationMs:   213000,
+	}}
+
+	w := postTrack(srv, map[string]any{
+		"title":           "whatever the client says",
+		"provider":        "youtube",
+		"providerTrackId": "vid123",
+		"durationMs":      0,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 Created, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	// $2 title, $3 artist, $6 thumbnail, $7 duration, $10 resolved
+	if inserted[1] != "Canonical Title" || inserted[2] != "Canonical Artist" ||
+		inserted[5] != "http://img/canonical.jpg" || inserted[6] != 213000 || inserted[9] != true {
+		t.Errorf("expected canonical metadata to be inserted, got %v", inserted)
+	}
+}
+
+func TestHandleAddTrack_RejectsUnplayable(t *testing.T) {
+	for _, lookupErr := range []error{errTrackNotFound, errTrackNotEmbeddable} {
+		t.Run(lookupErr.Error(), func(t *testing.T) {
+			var inserted []any
+			srv := NewServer(addTrackDB(&inserted), nil)
+			srv.resolver = &fakeResolver{err: lookupErr}
+
+			w := postTrack(srv, map[string]any{"title": "Song", "provider": "youtube", "providerTrackId": "gone"})
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("Expected 422, got %d. Body: %s", w.Code, w.Body.String())
+			}
+			if inserted != nil {
+				t.Errorf("expected no insert")
+			}
+		})
+	}
+}
+
+func TestHandleAddTrack_ProviderDownKeepsClientMetadata(t *testing.T) {
+	var inserted []any
+	srv := NewServer(addTrackDB(&inserted), nil)
+	srv.resolver = &fakeResolver{err: errors.New("connection refused")}
+
+	w := postTrack(srv, map[string]any{"title": "Song", "provider": "youtube", "providerTrackId": "vid123", "durationMs": 1000})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 Created, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if inserted[1] != "Song" || inserted[9] != false {
+		t.Errorf("expected client metadata and unresolved track, got %v", inserted)
+	}
+}
+
+func TestHandleAddTrack_NoProviderSkipsLookup(t *testing.T) {
+	var inserted []any
+	srv := NewServer(addTrackDB(&inserted), nil)
+	resolver := &fakeResolver{}
+	srv.resolver = resolver
+
+	w := postTrack(srv, map[string]any{"title": "Live jam"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 Created, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if resolver.calls != 0 {
+		t.Errorf("expected no lookup for a track without provider")
+	}
+}
+
+func TestBackfillTracks(t *testing.T) {
+	var updates []string
+	mockDB := &MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if strings.Contains(sql, "resolved_at IS NULL") {
+				return &MockRows{Data: [][]any{
+					{"tr-ok", "pl-1", "youtube", "vid-ok"},
+					{"tr-gone", "pl-1", "youtube", "vid-gone"},
+				}, Idx: -1}, nil
+			}
+			return nil, errors.New("unexpected query: " + sql)
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &MockRow{}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "unavailable = TRUE"):
+				updates = append(updates, "unavailable:"+args[0].(string))
+			case strings.Contains(sql, "SET title"):
+				updates = append(updates, "resolved:"+args[0].(string))
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	srv := NewServer(mockDB, nil)
+	srv.resolver = resolverFunc(func(provider, trackID string) (trackInput, error) {
+		if trackID == "vid-gone" {
+			return trackInput{}, errTrackNotFound
+		}
+		return trackInput{Title: "Canonical", DurationMs: 1000}, nil
+	})
+
+	n, err := srv.backfillTracks(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 tracks resolved, got %d, %v", n, err)
+	}
+	want := []string{"resolved:tr-ok", "unavailable:tr-gone"}
+	if strings.Join(updates, ",") != strings.Join(want, ",") {
+		t.Errorf("expected updates %v, got %v", want, updates)
+	}
+}
+
+func TestBackfillTracks_StopsWhenProviderDown(t *testing.T) {
+	mockDB := &MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			return &MockRows{Data: [][]any{{"tr-1", "pl-1", "youtube", "vid-1"}}, Idx: -1}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			t.Errorf("unexpected update: %s", sql)
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	srv := NewServer(mockDB, nil)
+	srv.resolver = &fakeResolver{err: errors.New("provider down")}
+
+	if n, err := srv.backfillTracks(context.Background()); err == nil || n != 0 {
+		t.Fatalf("expected error and no progress, got %d, %v", n, err)
+	}
+}
+
+type resolverFunc func(provider, trackID string) (trackInput, error)
+
+func (f resolverFunc) ResolveTrack(ctx context.Context, provider, trackID string) (trackInput, error) {
+	return f(provider, trackID)
+}
+
+func TestMusicProviderClient_ResolveTrack(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/music/tracks/youtube/ok":
+			w.Write([]byte(`{"title":"T","artist":"A","provider":"youtube","providerTrackId":"ok","durationMs":5000}`))
+		case "/music/tracks/youtube/blocked":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		case "/music/tracks/youtube/down":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	c := &musicProviderClient{baseURL: ts.URL}
+
+	in, err := c.ResolveTrack(context.Background(), "youtube", "ok")
+	if err != nil || in.Title != "T" || in.DurationMs != 5000 {
+		t.Errorf("unexpected result: %+v, %v", in, err)
+	}
+	if _, err := c.ResolveTrack(context.Background(), "youtube", "missing"); !errors.Is(err, errTrackNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if _, err := c.ResolveTrack(context.Background(), "youtube", "blocked"); !errors.Is(err, errTrackNotEmbeddable) {
+		t.Errorf("expected not embeddable, got %v", err)
+	}
+	_, err = c.ResolveTrack(context.Background(), "youtube", "down")
+	if err == nil || errors.Is(err, errTrackNotFound) {
+		t.Errorf("expected transient error, got %v", err)
+	}
+}
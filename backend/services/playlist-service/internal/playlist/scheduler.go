@@ -0,0 +1,223 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// schedulerResyncInterval is how often timers are reconciled with the
+	// database, picking up playback started by other instances.
+	schedulerResyncInterval = 30 * time.Second
+	// schedulerRetryDelay delays a retry after a failed or contended advance.
+	schedulerRetryDelay = time.Second
+)
+
+// playbackScheduler advances playlists when their current track ends. It
+// keeps one timer per playing playlist instead of polling. Every instance
+// runs one; advancePlayback claims the playlist row, so only one of them
+// advances a given track.
+type playbackScheduler struct {
+	s      *Server
+	ctx    context.Context
+	mu     sync.Mutex
+	timers map[string]*scheduledAdvance
+}
+
+// scheduledAdvance is the armed timer of one playlist, and the timer that
+// announces its next track when EnableUpNext was called. armedAt tells
+// resyncs whether the timer is newer than the state they read.
+type scheduledAdvance struct {
+	trackID string
+	at      time.Time
+	armedAt time.Time
+	timer   *time.Timer
+	upNext  *time.Timer
+}
+
+func (e *scheduledAdvance) stop() {
+	e.timer.Stop()
+	if e.upNext != nil {
+		e.upNext.Stop()
+	}
+}
+
+// StartScheduler arms timers for every playlist currently playing and keeps
+// them in sync with the database until ctx is cancelled.
+func (s *Server) StartScheduler(ctx context.Context) {
+	sc := &playbackScheduler{
+		s:      s,
+		ctx:    ctx,
+		timers: make(map[string]*scheduledAdvance),
+	}
+	s.scheduler = sc
+	sc.resync()
+
+	go func() {
+		ticker := time.NewTicker(schedulerResyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				sc.stopAll()
+				return
+			case <-ticker.C:
+				sc.resync()
+			}
+		}
+	}()
+}
+
+// arm schedules playlistID to advance past trackID at the given time,
+// replacing any previous timer of the playlist. It is a no-op on a nil
+// scheduler, so NextTrack works without one.
+func (sc *playbackScheduler) arm(playlistID, trackID string, at time.Time) {
+	if sc == nil {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.armLocked(playlistID, trackID, at)
+}
+
+// armLocked is arm with sc.mu held.
+func (sc *playbackScheduler) armLocked(playlistID, trackID string, at time.Time) {
+	if cur, ok := sc.timers[playlistID]; ok {
+		// Postgres keeps microseconds, so times read back from the database
+		// differ slightly from the ones computed locally.
+		if cur.trackID == trackID && cur.at.Sub(at).Abs() < time.Millisecond {
+			return
+		}
+		cur.stop()
+	}
+
+	entry := &scheduledAdvance{trackID: trackID, at: at, armedAt: time.Now()}
+	entry.timer = time.AfterFunc(time.Until(at), func() { sc.fire(playlistID, entry) })
+	if lead := sc.s.upNextLead; lead > 0 {
+		entry.upNext = time.AfterFunc(time.Until(at.Add(-lead)), func() { sc.announce(playlistID, entry) })
+	}
+	sc.timers[playlistID] = entry
+}
+
+// disarm cancels the timer of playlistID.
+func (sc *playbackScheduler) disarm(playlistID string) {
+	if sc == nil {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if cur, ok := sc.timers[playlistID]; ok {
+		cur.stop()
+		delete(sc.timers, playlistID)
+	}
+}
+
+func (sc *playbackScheduler) stopAll() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for id, cur := range sc.timers {
+		cur.stop()
+		delete(sc.timers, id)
+	}
+}
+
+func (sc *playbackScheduler) fire(playlistID string, entry *scheduledAdvance) {
+	sc.mu.Lock()
+	if sc.timers[playlistID] != entry {
+		// Re-armed or disarmed in the meantime.
+		sc.mu.Unlock()
+		return
+	}
+	delete(sc.timers, playlistID)
+	sc.mu.Unlock()
+
+	if sc.ctx.Err() != nil {
+		return
+	}
+
+	_, err := sc.s.advancePlayback(sc.ctx, playlistID, playEndFinished, entry.trackID)
+	if errors.Is(err, errPlaybackChanged) {
+		sc.refresh(playlistID, time.Now().Add(schedulerRetryDelay))
+		return
+	}
+	if err != nil {
+		log.Printf("playlist-service: scheduler advance %s: %v", playlistID, err)
+		sc.arm(playlistID, entry.trackID, time.Now().Add(schedulerRetryDelay))
+	}
+}
+
+// announce publishes the track that follows entry's once entry's track is
+// about to end.
+func (sc *playbackScheduler) announce(playlistID string, entry *scheduledAdvance) {
+	sc.mu.Lock()
+	armed := sc.timers[playlistID] == entry
+	sc.mu.Unlock()
+	if !armed || sc.ctx.Err() != nil {
+		return
+	}
+	sc.s.announceUpNext(sc.ctx, playlistID, entry.trackID)
+}
+
+// playingTrack is a playlist whose current track has a known end. While a
+// player is attached the track ends when the player reports it, so the
+// timer only checks back once the player might have stopped heartbeating.
+type playingTrack struct {
+	PlaylistID string
+	TrackID    string
+	EndsAt     time.Time
+}
+
+func (sc *playbackScheduler) loadPlaying(playlistID string) ([]playingTrack, error) {
+	return sc.s.store.PlayingTracks(sc.ctx, playlistID, playerHeartbeatTimeout)
+}
+
+// resync arms a timer for every playing playlist and drops timers of
+// playlists that stopped. Timers armed while the playing playlists were
+// being read, e.g. by NextTrack, are newer than what was read and are kept.
+func (sc *playbackScheduler) resync() {
+	readAt := time.Now()
+	playing, err := sc.loadPlaying("")
+	if err != nil {
+		log.Printf("playlist-service: scheduler resync: %v", err)
+		return
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	seen := make(map[string]bool, len(playing))
+	for _, pt := range playing {
+		seen[pt.PlaylistID] = true
+		if cur, ok := sc.timers[pt.PlaylistID]; ok && cur.armedAt.After(readAt) {
+			continue
+		}
+		sc.armLocked(pt.PlaylistID, pt.TrackID, pt.EndsAt)
+	}
+	for id, cur := range sc.timers {
+		if !seen[id] && !cur.armedAt.After(readAt) {
+			cur.stop()
+			delete(sc.timers, id)
+		}
+	}
+}
+
+// refresh re-reads the playback state of one playlist after a contended or
+// premature advance, arming its timer no earlier than notBefore.
+func (sc *playbackScheduler) refresh(playlistID string, notBefore time.Time) {
+	playing, err := sc.loadPlaying(playlistID)
+	if err != nil {
+		log.Printf("playlist-service: scheduler refresh %s: %v", playlistID, err)
+		return
+	}
+	if len(playing) == 0 {
+		sc.disarm(playlistID)
+		return
+	}
+	pt := playing[0]
+	if pt.EndsAt.Before(notBefore) {
+		pt.EndsAt = notBefore
+	}
+	sc.arm(playlistID, pt.TrackID, pt.EndsAt)
+}
@@ -0,0 +1,195 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func newTestScheduler(srv *Server) *playbackScheduler {
+	sc := &playbackScheduler{
+		s:      srv,
+		ctx:    context.Background(),
+		timers: make(map[string]*scheduledAdvance),
+	}
+	srv.scheduler = sc
+	return sc
+}
+
+func TestScheduler_ResyncArmsTimers(t *testing.T) {
+	mockDB := &MockDB{}
+	srv := NewServer(mockDB, nil)
+	sc := newTestScheduler(srv)
+	defer sc.stopAll()
+
+	later := time.Now().Add(time.Hour)
+	data := [][]any{
+		{"pl-1", "track-1", later},
+		{"pl-2", "track-2", later},
+	}
+	mockDB.QueryFunc = func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		if strings.Contains(sql, "JOIN tracks t ON t.id = p.current_track_id") {
+			return &MockRows{Data: data, Idx: -1}, nil
+		}
+		return nil, errors.New("unexpected query: " + sql)
+	}
+
+	sc.resync()
+	if len(sc.timers) != 2 {
+		t.Fatalf("expected 2 timers, got %d", len(sc.timers))
+	}
+	first := sc.timers["pl-1"]
+
+	// pl-2 stopped elsewhere; pl-1 is unchanged and keeps its timer.
+	data = data[:1]
+	sc.resync()
+	if len(sc.timers) != 1 {
+		t.Fatalf("expected 1 timer, got %d", len(sc.timers))
+	}
+	if sc.timers["pl-1"] != first {
+		t.Errorf("expected unchanged timer to be kept")
+	}
+}
+
+func TestScheduler_ResyncKeepsNewerTimers(t *testing.T) {
+	mockDB := &MockDB{}
+	srv := NewServer(mockDB, nil)
+	sc := newTestScheduler(srv)
+	defer sc.stopAll()
+
+	later := time.Now().Add(time.Hour)
+	sc.arm("pl-1", "track-1", later)
+	// While the resync reads the stale state, NextTrack starts pl-2 and
+	// moves pl-1 on to its next track.
+	mockDB.QueryFunc = func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		sc.arm("pl-1", "track-2", later)
+		sc.arm("pl-2", "track-3", later)
+		return &MockRows{Data: [][]any{{"pl-1", "track-1", later}}, Idx: -1}, nil
+	}
+
+	sc.resync()
+	if len(sc.timers) != 2 || sc.timers["pl-1"].trackID != "track-2" || sc.timers["pl-2"] == nil {
+		t.Errorf("timers after resync = %+v", sc.timers)
+	}
+}
+
+func TestScheduler_FireAdvancesDueTrack(t *testing.T) {
+	mockDB := &MockDB{}
+	srv := NewServer(mockDB, nil)
+	sc := newTestScheduler(srv)
+	defer sc.stopAll()
+
+	committed := make(chan struct{})
+	mockDB.BeginTxFunc = func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+		return &MockTx{
+			QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+				if strings.Contains(sql, "SKIP LOCKED") {
+					return &MockRow{ScanFunc: func(dest ...any) error {
+						current := "track-old"
+						*dest[0].(**string) = &current
+						*dest[1].(*bool) = true
+						return nil
+					}}
+				}
+				if strings.Contains(sql, "status = 'queued'") {
+					return &MockRow{ScanFunc: func(dest ...any) error {
+						*dest[0].(*string) = "track-new"
+						*dest[1].(*int) = 180000
+						return nil
+					}}
+				}
+				return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected query: " + sql) }}
+			},
+			ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+				return pgconn.CommandTag{}, nil
+			},
+			CommitFunc: func(ctx context.Context) error {
+				close(committed)
+				return nil
+			},
+		}, nil
+	}
+
+	sc.arm("pl-1", "track-old", time.Now())
+
+	select {
+	case <-committed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("scheduler did not advance the playlist")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		sc.mu.Lock()
+		entry := sc.timers["pl-1"]
+		sc.mu.Unlock()
+		if entry != nil && entry.trackID == "track-new" {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("expected timer to be re-armed for the next track")
+}
+
+func TestAdvancePlayback_NotDue(t *testing.T) {
+	tests := []struct {
+		name    string
+		scan    func(dest ...any) error
+		wantErr error
+	}{
+		{
+			name:    "Claimed by another instance",
+			scan:    func(dest ...any) error { return pgx.ErrNoRows },
+			wantErr: errPlaybackChanged,
+		},
+		{
+			name: "Track was skipped",
+			scan: func(dest ...any) error {
+				current := "track-other"
+				*dest[0].(**string) = &current
+				*dest[1].(*bool) = true
+				return nil
+			},
+			wantErr: errPlaybackChanged,
+		},
+		{
+			name: "Track still playing",
+			scan: func(dest ...any) error {
+				current := "track-old"
+				*dest[0].(**string) = &current
+				*dest[1].(*bool) = false
+				return nil
+			},
+			wantErr: errPlaybackChanged,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := &MockDB{}
+			srv := NewServer(mockDB, nil)
+
+			mockDB.BeginTxFunc = func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+				return &MockTx{
+					QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+						return &MockRow{ScanFunc: tt.scan}
+					},
+					ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+						t.Errorf("unexpected exec: %s", sql)
+						return pgconn.CommandTag{}, nil
+					},
+				}, nil
+			}
+
+			_, err := srv.advancePlayback(context.Background(), "pl-1", playEndFinished, "track-old")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -1,33 +1,58 @@
 package playlist
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/redis/go-redis/v9"
 )
 
-// DB interface abstracts the database connection (pool or mock).
-type DB interface {
-	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
-	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
-	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
-	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
-}
-
 type Server struct {
-	db  DB
-	rdb *redis.Client
+	store     PlaylistStore
+	rdb       *redis.Client
+	scheduler *playbackScheduler
+	limiter   *rateLimiter // reactions and comments
+
+	// Radio mode; music is nil unless EnableRadio was called.
+	music     trackSearcher
+	prefs     preferencesFetcher
+	radioBusy sync.Map
+
+	// Background refreshes of smart playlists in progress.
+	smartBusy sync.Map
+
+	// Provider lookups of added tracks; nil unless EnableTrackLookup was called.
+	resolver trackResolver
+
+	// Restores linked events; nil unless EnableEventRestore was called.
+	events eventRestorer
+
+	// Friend lists of owners; nil unless EnableFriends was called.
+	friends friendLister
+
+	// How long before a track ends the scheduler announces the next one;
+	// 0 unless EnableUpNext was called.
+	upNextLead time.Duration
 }
 
 func NewServer(db DB, rdb *redis.Client) *Server {
 	return &Server{
-		db:  db,
-		rdb: rdb,
+		store:   NewPostgresStore(db),
+		rdb:     rdb,
+		limiter: newRateLimiter(10 * time.Minute),
+	}
+}
+
+// NewServerWithStore creates a server on the given store, e.g. a
+// MemoryStore.
+func NewServerWithStore(store PlaylistStore, rdb *redis.Client) *Server {
+	return &Server{
+		store:   store,
+		rdb:     rdb,
+		limiter: newRateLimiter(10 * time.Minute),
 	}
 }
 
@@ -48,18 +73,93 @@ func (s *Server) Router(middlewares ...func(http.Handler) http.Handler) chi.Rout
 		r.Patch("/playlists/{id}", s.handlePatchPlaylist)
 		r.Delete("/playlists/{id}", s.handleDeletePlaylist)
 		r.Get("/playlists/{id}", s.handleGetPlaylist)
+		r.Post("/playlists/{id}/restore", s.handleRestorePlaylist)
+		r.Get("/users/me/trash", s.handleListTrash)
+
+		r.Get("/users/me/devices", s.handleListDevices)
+		r.Post("/users/me/devices", s.handleRegisterDevice)
+		r.Patch("/users/me/devices/{deviceId}", s.handleUpdateDevice)
+		r.Post("/users/me/devices/{deviceId}/heartbeat", s.handleDeviceHeartbeat)
+		r.Delete("/users/me/devices/{deviceId}", s.handleDeleteDevice)
 
 		r.Post("/playlists/{id}/tracks", s.handleAddTrack)
 		r.Patch("/playlists/{id}/tracks/{trackId}", s.handleMoveTrack)
 		r.Delete("/playlists/{id}/tracks/{trackId}", s.handleDeleteTrack)
+		r.Post("/playlists/{id}/tracks:batch", s.handleBatchTracks)
 
 		r.Get("/playlists/{id}/invites", s.handleListInvites)
 		r.Post("/playlists/{id}/invites", s.handleAddInvite)
 		r.Delete("/playlists/{id}/invites/{userId}", s.handleDeleteInvite)
+		r.Get("/playlists/{id}/invites/pending", s.handleListPendingInvites)
+		r.Post("/playlists/{id}/invites/accept", s.handleAcceptInvite)
+		r.Post("/playlists/{id}/invites/decline", s.handleDeclineInvite)
+		r.Get("/users/me/playlist-invites", s.handleListMyInvites)
+
+		r.Post("/playlists/{id}/join-links", s.handleCreateJoinLink)
+		r.Get("/playlists/{id}/join-links", s.handleListJoinLinks)
+		r.Delete("/playlists/{id}/join-links/{code}", s.handleRevokeJoinLink)
+		r.Post("/join/{code}", s.handleJoin)
+
+		r.Post("/playlists/{id}/follow", s.handleFollowPlaylist)
+		r.Delete("/playlists/{id}/follow", s.handleUnfollowPlaylist)
+		r.Get("/users/me/followed-playlists", s.handleListFollowedPlaylists)
+		r.Get("/users/me/feed", s.handleFeed)
 
 		// Playback & Voting
 		r.Post("/playlists/{id}/tracks/{trackId}/vote", s.handleVoteTrack)
 		r.Post("/playlists/{id}/next", s.handleNextTrack)
+		r.Get("/playlists/{id}/queue", s.handleGetQueue)
+		r.Get("/playlists/{id}/player", s.handleGetPlayer)
+		r.Delete("/playlists/{id}/player", s.handleDetachPlayer)
+		r.Post("/playlists/{id}/player/heartbeat", s.handlePlayerHeartbeat)
+		r.Get("/playlists/{id}/active-device", s.handleGetActiveDevice)
+		r.Put("/playlists/{id}/active-device", s.handleSetActiveDevice)
+		r.Delete("/playlists/{id}/active-device", s.handleClearActiveDevice)
+		r.Get("/playlists/{id}/delegations", s.handleListDelegations)
+		r.Post("/playlists/{id}/delegations", s.handleCreateDelegation)
+		r.Delete("/playlists/{id}/delegations/{userId}", s.handleDeleteDelegation)
+		r.Post("/playlists/{id}/presence", s.handlePresence)
+		r.Get("/playlists/{id}/skip-votes", s.handleGetSkipVotes)
+		r.Post("/playlists/{id}/skip-votes", s.handleSkipVote)
+		r.Get("/playlists/{id}/history", s.handleListHistory)
+		r.Get("/playlists/{id}/history/stats", s.handleHistoryStats)
+
+		// Reactions & comments
+		r.Post("/playlists/{id}/tracks/{trackId}/reactions", s.handleAddReaction)
+		r.Delete("/playlists/{id}/tracks/{trackId}/reactions", s.handleRemoveReaction)
+		r.Get("/playlists/{id}/tracks/{trackId}/comments", s.handleListComments)
+		r.Post("/playlists/{id}/tracks/{trackId}/comments", s.handleAddComment)
+		r.Delete("/playlists/{id}/tracks/{trackId}/comments/{commentId}", s.handleDeleteComment)
+
+		// Moderation
+		r.Post("/playlists/{id}/moderation/kick", s.handleKickMember)
+		r.Get("/playlists/{id}/moderation/bans", s.handleListBans)
+		r.Post("/playlists/{id}/moderation/bans", s.handleBanUser)
+		r.Delete("/playlists/{id}/moderation/bans/{userId}", s.handleUnbanUser)
+		r.Put("/playlists/{id}/moderation/locks/{target}", s.handleLock)
+		r.Delete("/playlists/{id}/moderation/locks/{target}", s.handleUnlock)
+		r.Post("/playlists/{id}/moderation/remove-tracks", s.handleRemoveUserTracks)
+		r.Get("/playlists/{id}/moderation/log", s.handleModerationLog)
+
+		// Content rules
+		r.Get("/playlists/{id}/content-rules", s.handleGetContentRules)
+		r.Put("/playlists/{id}/content-rules", s.handlePutContentRules)
+
+		// Edit license
+		r.Get("/playlists/{id}/edit-license", s.handleGetEditLicense)
+		r.Put("/playlists/{id}/edit-license", s.handlePutEditLicense)
+
+		// Smart playlists
+		r.Get("/playlists/{id}/smart", s.handleGetSmartRules)
+		r.Put("/playlists/{id}/smart", s.handlePutSmartRules)
+		r.Delete("/playlists/{id}/smart", s.handleConvertSmartPlaylist)
+		r.Post("/playlists/{id}/smart/refresh", s.handleRefreshSmartPlaylist)
+
+		// Suggestions
+		r.Get("/playlists/{id}/suggestions", s.handleListSuggestions)
+		r.Post("/playlists/{id}/suggestions", s.handleCreateSuggestion)
+		r.Post("/playlists/{id}/suggestions/{suggestionId}/approve", s.handleApproveSuggestion)
+		r.Post("/playlists/{id}/suggestions/{suggestionId}/reject", s.handleRejectSuggestion)
 	})
 
 	return r
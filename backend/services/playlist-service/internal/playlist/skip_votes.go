@@ -0,0 +1,260 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultSkipVotePercent = 50
+	maxSkipVotePercent     = 99
+	maxSkipVoteThreshold   = 1000
+
+	// presenceWindow is how long a presence heartbeat counts. Clients send
+	// one about every 30 seconds while the playlist is open.
+	presenceWindow = 90 * time.Second
+)
+
+// Vote-to-skip lets listeners skip the playing track without being allowed
+// to call POST /playlists/{id}/next. Anyone who may vote for tracks may vote
+// once per playing track; the track is skipped as soon as its votes exceed
+// SkipVotePercent of the present listeners or reach SkipVoteThreshold.
+// Listeners count as present while they send presence heartbeats; voting
+// counts as one.
+
+func isValidSkipVotePercent(n int) bool {
+	return n >= 0 && n <= maxSkipVotePercent
+}
+
+func isValidSkipVoteThreshold(n int) bool {
+	return n >= 0 && n <= maxSkipVoteThreshold
+}
+
+// skipVotesNeeded returns how many skip votes skip the playing track with
+// present listeners, or 0 when vote-to-skip is off.
+func skipVotesNeeded(pl Playlist, present int) int {
+	needed := 0
+	if pl.SkipVotePercent > 0 {
+		needed = pl.SkipVotePercent*present/100 + 1
+	}
+	if pl.SkipVoteThreshold > 0 && (needed == 0 || pl.SkipVoteThreshold < needed) {
+		needed = pl.SkipVoteThreshold
+	}
+	return needed
+}
+
+// skipVoteState is the vote-to-skip progress of the playing track.
+type skipVoteState struct {
+	TrackID string `json:"trackId,omitempty"`
+	Votes   int    `json:"votes"`
+	Present int    `json:"present"`
+	Needed  int    `json:"needed"` // 0 when vote-to-skip is off
+	Voted   bool   `json:"voted"`
+	Skipped bool   `json:"skipped,omitempty"`
+}
+
+// presentListeners returns how many listeners sent a heartbeat within
+// presenceWindow before now.
+func (s *Server) presentListeners(ctx context.Context, playlistID string, now time.Time) (int, error) {
+	return s.store.CountPresent(ctx, playlistID, now.Add(-presenceWindow))
+}
+
+// handlePresence records a presence heartbeat of the caller and returns how
+// many listeners are present.
+// POST /playlists/{id}/presence
+func (s *Server) handlePresence(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "presence access")
+		return
+	}
+	if err := s.checkRestrictions(ctx, playlistID, userID, false, ""); err != nil {
+		writeHTTPError(w, err, "presence restrictions")
+		return
+	}
+
+	now := time.Now()
+	if err := s.store.TouchPresence(ctx, playlistID, userID, now); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "playlist not found")
+			return
+		}
+		writeHTTPError(w, err, "touch presence")
+		return
+	}
+	present, err := s.presentListeners(ctx, playlistID, now)
+	if err != nil {
+		writeHTTPError(w, err, "count present")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"present": present})
+}
+
+// handleGetSkipVotes returns the vote-to-skip progress of the playing track.
+// GET /playlists/{id}/skip-votes
+func (s *Server) handleGetSkipVotes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "skip votes access")
+		return
+	}
+	pl, err := s.store.GetPlaylist(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "get playlist")
+		return
+	}
+
+	var st skipVoteState
+	st.Present, err = s.presentListeners(ctx, playlistID, time.Now())
+	if err != nil {
+		writeHTTPError(w, err, "count present")
+		return
+	}
+	st.Needed = skipVotesNeeded(pl, st.Present)
+	if pl.CurrentTrackID != nil {
+		st.TrackID = *pl.CurrentTrackID
+		st.Votes, st.Voted, err = s.store.SkipVotes(ctx, playlistID, st.TrackID, userID)
+		if err != nil {
+			writeHTTPError(w, err, "get skip votes")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, st)
+}
+
+// handleSkipVote votes to skip the playing track and skips it once enough
+// listeners voted, publishing player.skipped_by_vote. The optional trackId
+// guards against voting on a track that stopped meanwhile.
+// POST /playlists/{id}/skip-votes
+// Body: {"trackId": "..."} (optional)
+func (s *Server) handleSkipVote(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	var body struct {
+		TrackID string `json:"trackId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	if _, err := s.checkEditAccess(ctx, playlistID, userID, lockVoting, clientLocation{}); err != nil {
+		writeHTTPError(w, err, "skip vote access")
+		return
+	}
+	pl, err := s.store.GetPlaylist(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "get playlist")
+		return
+	}
+	if skipVotesNeeded(pl, 0) == 0 {
+		writeError(w, http.StatusForbidden, "vote-to-skip is off for this playlist")
+		return
+	}
+	if pl.CurrentTrackID == nil {
+		writeError(w, http.StatusConflict, "nothing is playing")
+		return
+	}
+	trackID := *pl.CurrentTrackID
+	if body.TrackID != "" && body.TrackID != trackID {
+		writeError(w, http.StatusConflict, "track is no longer playing")
+		return
+	}
+
+	now := time.Now()
+	if err := s.store.TouchPresence(ctx, playlistID, userID, now); err != nil {
+		writeHTTPError(w, err, "touch presence")
+		return
+	}
+	st := skipVoteState{TrackID: trackID, Voted: true}
+	st.Votes, err = s.store.AddSkipVote(ctx, playlistID, trackID, userID)
+	switch {
+	case errors.Is(err, errAlreadyVoted):
+		writeError(w, http.StatusConflict, "already voted to skip this track")
+		return
+	case errors.Is(err, errPlaybackChanged):
+		writeError(w, http.StatusConflict, "track is no longer playing")
+		return
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	case err != nil:
+		writeHTTPError(w, err, "add skip vote")
+		return
+	}
+	st.Present, err = s.presentListeners(ctx, playlistID, now)
+	if err != nil {
+		writeHTTPError(w, err, "count present")
+		return
+	}
+	st.Needed = skipVotesNeeded(pl, st.Present)
+
+	payload := map[string]any{
+		"playlistId": playlistID,
+		"trackId":    trackID,
+		"votes":      st.Votes,
+		"present":    st.Present,
+		"needed":     st.Needed,
+	}
+	s.publishEvent(ctx, map[string]any{
+		"type":    "player.skip_votes_updated",
+		"payload": payload,
+	})
+
+	if st.Votes >= st.Needed {
+		// Concurrent votes may all reach the threshold; expectTrackID lets
+		// only one of them skip.
+		_, err := s.advancePlayback(ctx, playlistID, playEndSkipped, trackID)
+		switch {
+		case err == nil:
+			st.Skipped = true
+			s.publishEvent(ctx, map[string]any{
+				"type":    "player.skipped_by_vote",
+				"payload": payload,
+			})
+		case !errors.Is(err, errPlaybackChanged):
+			log.Printf("playlist-service: skip by vote: %v", err)
+			writeError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, st)
+}
@@ -0,0 +1,167 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSkipVotesNeeded(t *testing.T) {
+	tests := []struct {
+		name      string
+		percent   int
+		threshold int
+		present   int
+		want      int
+	}{
+		{name: "Off", present: 4, want: 0},
+		{name: "Half Of Four", percent: 50, present: 4, want: 3},
+		{name: "Half Of Three", percent: 50, present: 3, want: 2},
+		{name: "Nobody Present", percent: 50, want: 1},
+		{name: "Threshold Only", threshold: 2, present: 10, want: 2},
+		{name: "Threshold Lower", percent: 50, threshold: 3, present: 10, want: 3},
+		{name: "Percent Lower", percent: 50, threshold: 5, present: 2, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl := Playlist{SkipVotePercent: tt.percent, SkipVoteThreshold: tt.threshold}
+			if got := skipVotesNeeded(pl, tt.present); got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+// newSkipVoteTestServer returns a memory-backed server with a public
+// playlist owned by owner-1 whose first track, "One", is playing before
+// "Two".
+func newSkipVoteTestServer(t *testing.T, percent, threshold int) (http.Handler, Playlist) {
+	t.Helper()
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	pl := Playlist{
+		OwnerID:           "owner-1",
+		Name:              "Bar",
+		IsPublic:          true,
+		EditMode:          editModeEveryone,
+		SkipVotePercent:   percent,
+		SkipVoteThreshold: threshold,
+	}
+	if err := store.CreatePlaylist(ctx, &pl, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"One", "Two"} {
+		if _, err := store.AddTrack(ctx, pl.ID, trackInput{Title: title, Artist: "Band"}, trackSourceUser); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	pl, _ = store.GetPlaylist(ctx, pl.ID)
+	return NewServerWithStore(store, nil).Router(), pl
+}
+
+func skipVote(t *testing.T, h http.Handler, userID, playlistID string, body any) (int, skipVoteState) {
+	t.Helper()
+	w := serveAs(h, userID, "POST", "/playlists/"+playlistID+"/skip-votes", body)
+	var st skipVoteState
+	_ = json.NewDecoder(w.Body).Decode(&st)
+	return w.Code, st
+}
+
+func TestHandleSkipVote(t *testing.T) {
+	h, pl := newSkipVoteTestServer(t, 50, 0)
+	playing := *pl.CurrentTrackID
+
+	// Four listeners: more than half of them is three votes.
+	for _, u := range []string{"owner-1", "u-1", "u-2", "u-3"} {
+		if w := serveAs(h, u, "POST", "/playlists/"+pl.ID+"/presence", nil); w.Code != http.StatusOK {
+			t.Fatalf("presence of %s: got %d", u, w.Code)
+		}
+	}
+
+	code, st := skipVote(t, h, "u-1", pl.ID, map[string]string{"trackId": playing})
+	if code != http.StatusOK || st.Votes != 1 || st.Present != 4 || st.Needed != 3 || st.Skipped {
+		t.Fatalf("first vote = %d %+v", code, st)
+	}
+	if code, _ := skipVote(t, h, "u-1", pl.ID, nil); code != http.StatusConflict {
+		t.Errorf("second vote: got %d", code)
+	}
+	if code, st := skipVote(t, h, "u-2", pl.ID, nil); code != http.StatusOK || st.Votes != 2 || st.Skipped {
+		t.Errorf("vote below the threshold = %d %+v", code, st)
+	}
+
+	w := serveAs(h, "u-3", "GET", "/playlists/"+pl.ID+"/skip-votes", nil)
+	var got skipVoteState
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if got.TrackID != playing || got.Votes != 2 || got.Needed != 3 || got.Voted {
+		t.Errorf("skip vote state = %+v", got)
+	}
+
+	if code, st := skipVote(t, h, "u-3", pl.ID, nil); code != http.StatusOK || st.Votes != 3 || !st.Skipped {
+		t.Fatalf("deciding vote = %d %+v", code, st)
+	}
+	w = serveAs(h, "u-3", "GET", "/playlists/"+pl.ID, nil)
+	var after struct {
+		Playlist Playlist `json:"playlist"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&after)
+	if after.Playlist.CurrentTrackID == nil || *after.Playlist.CurrentTrackID == playing {
+		t.Errorf("playing after the skip = %v", after.Playlist.CurrentTrackID)
+	}
+
+	if code, _ := skipVote(t, h, "u-1", pl.ID, map[string]string{"trackId": playing}); code != http.StatusConflict {
+		t.Errorf("vote for the skipped track: got %d", code)
+	}
+}
+
+func TestHandleSkipVote_Threshold(t *testing.T) {
+	h, pl := newSkipVoteTestServer(t, 0, 2)
+
+	if code, st := skipVote(t, h, "u-1", pl.ID, nil); code != http.StatusOK || st.Needed != 2 || st.Skipped {
+		t.Fatalf("first vote = %d %+v", code, st)
+	}
+	if code, st := skipVote(t, h, "u-2", pl.ID, nil); code != http.StatusOK || !st.Skipped {
+		t.Errorf("second vote = %d %+v", code, st)
+	}
+}
+
+func TestHandleSkipVote_Access(t *testing.T) {
+	h, pl := newSkipVoteTestServer(t, 50, 0)
+	base := "/playlists/" + pl.ID
+
+	if code, _ := skipVote(t, h, "", pl.ID, nil); code != http.StatusUnauthorized {
+		t.Errorf("no user: got %d", code)
+	}
+	if code, _ := skipVote(t, h, "u-1", missingID, nil); code != http.StatusNotFound {
+		t.Errorf("missing playlist: got %d", code)
+	}
+
+	serveAs(h, "owner-1", "PUT", base+"/moderation/locks/voting", nil)
+	if code, _ := skipVote(t, h, "u-1", pl.ID, nil); code != http.StatusLocked {
+		t.Errorf("voting locked: got %d", code)
+	}
+	serveAs(h, "owner-1", "DELETE", base+"/moderation/locks/voting", nil)
+
+	if w := serveAs(h, "owner-1", "PATCH", base, map[string]string{"editMode": editModeInvited}); w.Code != http.StatusOK {
+		t.Fatalf("switch to invited: got %d", w.Code)
+	}
+	if code, _ := skipVote(t, h, "u-1", pl.ID, nil); code != http.StatusForbidden {
+		t.Errorf("uninvited listener: got %d", code)
+	}
+
+	if w := serveAs(h, "owner-1", "PATCH", base, map[string]int{"skipVotePercent": 0}); w.Code != http.StatusOK {
+		t.Fatalf("turn vote-to-skip off: got %d", w.Code)
+	}
+	if code, _ := skipVote(t, h, "owner-1", pl.ID, nil); code != http.StatusForbidden {
+		t.Errorf("vote-to-skip off: got %d", code)
+	}
+	if w := serveAs(h, "owner-1", "PATCH", base, map[string]int{"skipVotePercent": 100}); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid percent: got %d", w.Code)
+	}
+}
@@ -0,0 +1,531 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxSmartRules        = 5
+	defaultSmartLimit    = 50
+	maxSmartLimit        = 200
+	maxSmartRefreshHours = 7 * 24
+	defaultSmartDays     = 30
+	maxSmartDays         = 365
+	maxSmartMinVotes     = 1000
+	maxSmartArtists      = 5
+
+	smartRefreshInterval = 5 * time.Minute
+	smartRefreshBatch    = 20
+	smartRefreshTimeout  = 30 * time.Second
+)
+
+// Smart rule types.
+const (
+	// smartRulePlayed picks tracks played in the owner's other playlists,
+	// event playlists included, within the last Days days with at least
+	// MinVotes votes, most voted first.
+	smartRulePlayed = "played"
+	// smartRuleFollowed picks the most voted tracks of the playlists the
+	// owner follows that have at least MinVotes votes.
+	smartRuleFollowed = "followed"
+	// smartRuleArtists searches the music provider for the artists in the
+	// owner's preferences; it needs EnableRadio.
+	smartRuleArtists = "preferred_artists"
+)
+
+// A smart playlist's queue is replaced by every refresh: queued tracks are
+// removed, the playing and played ones stay. Refreshes run when the rules
+// are set, on demand and, with RefreshHours, from StartSmartRefresh. Tracks
+// cannot be added, moved or deleted by hand, suggested or approved until
+// the playlist is converted back into a normal one, which keeps its tracks.
+// Votes and playback work as usual. A refresh publishes track.deleted for
+// every removed track, last first so the positions stay valid when applied
+// in order, then track.added for every added one and finally
+// playlist.smart_refreshed.
+
+var errNotSmart = &httpError{status: http.StatusNotFound, msg: "playlist is not a smart playlist"}
+
+func (r *SmartRules) clone() *SmartRules {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	c.Rules = append([]SmartRule(nil), r.Rules...)
+	if r.RefreshedAt != nil {
+		at := *r.RefreshedAt
+		c.RefreshedAt = &at
+	}
+	return &c
+}
+
+// normalize applies the defaults and validates the rules. It returns a
+// client-facing error message, or "" when the rules are valid.
+func (r *SmartRules) normalize() string {
+	r.RefreshedAt = nil
+	if len(r.Rules) == 0 || len(r.Rules) > maxSmartRules {
+		return fmt.Sprintf("rules must have between 1 and %d entries", maxSmartRules)
+	}
+	if r.Limit == 0 {
+		r.Limit = defaultSmartLimit
+	}
+	if r.Limit < 1 || r.Limit > maxSmartLimit {
+		return fmt.Sprintf("limit must be between 1 and %d", maxSmartLimit)
+	}
+	if r.RefreshHours < 0 || r.RefreshHours > maxSmartRefreshHours {
+		return fmt.Sprintf("refreshHours must be between 0 and %d", maxSmartRefreshHours)
+	}
+
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		rule.Type = strings.ToLower(strings.TrimSpace(rule.Type))
+		switch rule.Type {
+		case smartRulePlayed:
+			if rule.Days == 0 {
+				rule.Days = defaultSmartDays
+			}
+			if rule.Days < 1 || rule.Days > maxSmartDays {
+				return fmt.Sprintf("days must be between 1 and %d", maxSmartDays)
+			}
+		case smartRuleFollowed, smartRuleArtists:
+			if rule.Days != 0 {
+				return `days only applies to "played" rules`
+			}
+		default:
+			return `invalid rule type (must be "played", "followed" or "preferred_artists")`
+		}
+		if rule.MinVotes < 0 || rule.MinVotes > maxSmartMinVotes {
+			return fmt.Sprintf("minVotes must be between 0 and %d", maxSmartMinVotes)
+		}
+		if rule.Type == smartRuleArtists && rule.MinVotes != 0 {
+			return `minVotes does not apply to "preferred_artists" rules`
+		}
+	}
+	return ""
+}
+
+// checkNotSmart rejects hand edits of a smart playlist's tracks. Denials are
+// returned as *httpError.
+func (s *Server) checkNotSmart(ctx context.Context, playlistID string) error {
+	rules, err := s.store.SmartRules(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		return &httpError{status: http.StatusNotFound, msg: "playlist not found"}
+	}
+	if err != nil {
+		return err
+	}
+	if rules != nil {
+		return &httpError{status: http.StatusConflict, msg: "the tracks of a smart playlist are generated from its rules; convert it to edit them"}
+	}
+	return nil
+}
+
+// smartRefresh is the outcome of a refresh.
+type smartRefresh struct {
+	Added       int       `json:"added"`
+	Removed     int       `json:"removed"`
+	RefreshedAt time.Time `json:"refreshedAt"`
+}
+
+// triggerSmartRefresh refreshes playlistID in the background. At most one
+// background refresh per playlist runs at a time on this instance.
+func (s *Server) triggerSmartRefresh(playlistID string) {
+	if _, busy := s.smartBusy.LoadOrStore(playlistID, struct{}{}); busy {
+		return
+	}
+	go func() {
+		defer s.smartBusy.Delete(playlistID)
+		ctx, cancel := context.WithTimeout(context.Background(), smartRefreshTimeout)
+		defer cancel()
+		if _, err := s.refreshSmartPlaylist(ctx, playlistID); err != nil && !errors.Is(err, errNotSmart) {
+			log.Printf("playlist-service: smart refresh %s: %v", playlistID, err)
+		}
+	}()
+}
+
+// refreshSmartPlaylist replaces the queue of a smart playlist with the
+// tracks its rules select now. It returns errNotSmart for normal playlists.
+func (s *Server) refreshSmartPlaylist(ctx context.Context, playlistID string) (smartRefresh, error) {
+	ownerID, _, _, err := s.getPlaylistAccessInfo(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		return smartRefresh{}, &httpError{status: http.StatusNotFound, msg: "playlist not found"}
+	}
+	if err != nil {
+		return smartRefresh{}, err
+	}
+	rules, err := s.store.SmartRules(ctx, playlistID)
+	if err != nil {
+		return smartRefresh{}, err
+	}
+	if rules == nil {
+		return smartRefresh{}, errNotSmart
+	}
+	contentRules, err := s.store.ContentRules(ctx, playlistID)
+	if err != nil {
+		return smartRefresh{}, err
+	}
+
+	// Provider calls happen before the transaction so no lock is held
+	// while waiting on the network.
+	candidates, err := s.smartCandidates(ctx, playlistID, ownerID, *rules)
+	if err != nil {
+		return smartRefresh{}, err
+	}
+
+	update, err := s.store.ReplaceSmartQueue(ctx, playlistID, rules.Limit, candidates, contentRules)
+	if err != nil {
+		return smartRefresh{}, err
+	}
+	res := smartRefresh{Added: len(update.Added), Removed: len(update.Removed), RefreshedAt: update.RefreshedAt}
+
+	for _, rm := range update.Removed {
+		s.publishEvent(ctx, map[string]any{
+			"type": "track.deleted",
+			"payload": map[string]any{
+				"playlistId": playlistID,
+				"trackId":    rm.ID,
+				"position":   rm.Position,
+			},
+		})
+	}
+	for _, tr := range update.Added {
+		s.publishEvent(ctx, map[string]any{
+			"type": "track.added",
+			"payload": map[string]any{
+				"playlistId": playlistID,
+				"track":      tr,
+			},
+		})
+	}
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.smart_refreshed",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"added":      res.Added,
+			"removed":    res.Removed,
+		},
+	})
+	return res, nil
+}
+
+// smartQueueUpdate is what PlaylistStore.ReplaceSmartQueue changed.
+type smartQueueUpdate struct {
+	Removed     []removedTrack // last first
+	Added       []Track
+	RefreshedAt time.Time
+}
+
+// removedTrack is a track removed from a playlist and the position it had.
+type removedTrack struct {
+	ID       string
+	Position int
+}
+
+// pickSmartTracks picks up to limit candidates that are valid, keep to rules
+// and duplicate neither each other nor the tracks whose keys are in seen
+// (see smartTrackKeys). seen gets the picked tracks' keys added.
+func pickSmartTracks(candidates []trackInput, rules ContentRules, seen map[string]bool, limit int) []trackInput {
+	var picked []trackInput
+	for _, c := range candidates {
+		if len(picked) >= limit {
+			break
+		}
+		if c.normalize() != "" || rules.violation(c) != "" {
+			continue
+		}
+		dup := false
+		for _, key := range smartTrackKeys(c) {
+			dup = dup || seen[key]
+			seen[key] = true
+		}
+		if !dup {
+			picked = append(picked, c)
+		}
+	}
+	return picked
+}
+
+// smartTrackKeys returns the keys a track is deduplicated by: its provider
+// ID, if any, and its normalized "title|artist".
+func smartTrackKeys(in trackInput) []string {
+	key := normalizeTrackText(in.Title) + "|" + normalizeArtist(in.Artist)
+	if in.ProviderTrack == "" {
+		return []string{key}
+	}
+	return []string{in.Provider + ":" + in.ProviderTrack, key}
+}
+
+// smartCandidates collects the tracks selected by each rule, in rule order.
+// Rules select up to Limit tracks each; duplicates and tracks breaking the
+// content rules are dropped by the refresh.
+func (s *Server) smartCandidates(ctx context.Context, playlistID, ownerID string, rules SmartRules) ([]trackInput, error) {
+	var out []trackInput
+	for _, rule := range rules.Rules {
+		var (
+			items []trackInput
+			err   error
+		)
+		switch rule.Type {
+		case smartRulePlayed:
+			items, err = s.store.PlayedTracks(ctx, ownerID, playlistID, rule.Days, rule.MinVotes, rules.Limit)
+		case smartRuleFollowed:
+			items, err = s.store.FollowedTracks(ctx, ownerID, playlistID, rule.MinVotes, rules.Limit)
+		case smartRuleArtists:
+			items = s.preferredArtistTracks(ctx, ownerID, rules.Limit)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("smart rule %s: %w", rule.Type, err)
+		}
+		out = append(out, items...)
+	}
+	return out, nil
+}
+
+// preferredArtistTracks searches the music provider for the owner's
+// preferred artists and interleaves their tracks, so one artist does not
+// fill the playlist. Lookup failures are logged and skipped, like radio
+// seeds.
+func (s *Server) preferredArtistTracks(ctx context.Context, ownerID string, limit int) []trackInput {
+	if s.music == nil || s.prefs == nil {
+		return nil
+	}
+	prefs, err := s.prefs.Preferences(ctx, ownerID)
+	if err != nil {
+		log.Printf("playlist-service: smart owner preferences: %v", err)
+		return nil
+	}
+	artists := prefs.Artists
+	if len(artists) > maxSmartArtists {
+		artists = artists[:maxSmartArtists]
+	}
+
+	var perArtist [][]trackInput
+	for _, artist := range artists {
+		want := normalizeArtist(artist)
+		if want == "" {
+			continue
+		}
+		items, err := s.music.SearchTracks(ctx, artist, radioSearchLimit)
+		if err != nil {
+			log.Printf("playlist-service: smart search %q: %v", artist, err)
+			continue
+		}
+		var matching []trackInput
+		for _, it := range items {
+			// Searches also return covers and features by other artists.
+			if strings.Contains(normalizeArtist(it.Artist), want) {
+				matching = append(matching, it)
+			}
+		}
+		perArtist = append(perArtist, matching)
+	}
+
+	var out []trackInput
+	for i := 0; len(out) < limit; i++ {
+		added := false
+		for _, items := range perArtist {
+			if i < len(items) && len(out) < limit {
+				out = append(out, items[i])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return out
+}
+
+// StartSmartRefresh refreshes smart playlists with RefreshHours when they
+// are due, until ctx is cancelled. Each instance claims the due playlists
+// it refreshes, so only one of them refreshes a playlist.
+func (s *Server) StartSmartRefresh(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(smartRefreshInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.refreshDueSmartPlaylists(ctx); err != nil {
+				log.Printf("playlist-service: smart refresh: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Server) refreshDueSmartPlaylists(ctx context.Context) error {
+	ids, err := s.store.ClaimDueSmartPlaylists(ctx, smartRefreshBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return nil
+		}
+		refreshCtx, cancel := context.WithTimeout(ctx, smartRefreshTimeout)
+		if _, err := s.refreshSmartPlaylist(refreshCtx, id); err != nil && !errors.Is(err, errNotSmart) {
+			log.Printf("playlist-service: smart refresh %s: %v", id, err)
+		}
+		cancel()
+	}
+	return nil
+}
+
+// handleGetSmartRules returns the rules of a smart playlist to anyone who
+// can see it.
+// GET /playlists/{id}/smart
+func (s *Server) handleGetSmartRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkViewAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "smart rules access")
+		return
+	}
+
+	rules, err := s.store.SmartRules(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "get smart rules")
+		return
+	}
+	if rules == nil {
+		writeHTTPError(w, errNotSmart, "get smart rules")
+		return
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// handlePutSmartRules sets the rules of a playlist, turning a normal
+// playlist into a smart one, and refreshes it in the background. Only the
+// owner may change them.
+// PUT /playlists/{id}/smart
+// Body: SmartRules
+func (s *Server) handlePutSmartRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	var rules SmartRules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if msg := rules.normalize(); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := s.checkOwnerAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "smart rules access")
+		return
+	}
+	if err := s.store.SetSmartRules(ctx, playlistID, &rules); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "playlist not found")
+			return
+		}
+		writeHTTPError(w, err, "set smart rules")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.smart_rules_updated",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"rules":      rules,
+		},
+	})
+	s.triggerSmartRefresh(playlistID)
+
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// handleConvertSmartPlaylist turns a smart playlist into a normal, editable
+// one with the tracks of its last refresh. Only the owner may convert it.
+// DELETE /playlists/{id}/smart
+func (s *Server) handleConvertSmartPlaylist(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if err := s.checkOwnerAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "convert smart playlist access")
+		return
+	}
+	rules, err := s.store.SmartRules(ctx, playlistID)
+	if err == nil && rules == nil {
+		err = errNotSmart
+	}
+	if err == nil {
+		err = s.store.SetSmartRules(ctx, playlistID, nil)
+	}
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "convert smart playlist")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "playlist.smart_converted",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+		},
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRefreshSmartPlaylist refreshes a smart playlist now. The owner and
+// co-owners may refresh it.
+// POST /playlists/{id}/smart/refresh
+func (s *Server) handleRefreshSmartPlaylist(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	if _, err := s.checkModeratorAccess(ctx, playlistID, userID); err != nil {
+		writeHTTPError(w, err, "refresh smart playlist access")
+		return
+	}
+	res, err := s.refreshSmartPlaylist(ctx, playlistID)
+	if err != nil {
+		writeHTTPError(w, err, "refresh smart playlist")
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,249 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestSmartRulesNormalize(t *testing.T) {
+	rules := SmartRules{Rules: []SmartRule{{Type: " Played ", MinVotes: 5}, {Type: smartRuleFollowed}}}
+	if msg := rules.normalize(); msg != "" {
+		t.Fatalf("normalize: %s", msg)
+	}
+	if rules.Limit != defaultSmartLimit || rules.Rules[0].Type != smartRulePlayed || rules.Rules[0].Days != defaultSmartDays {
+		t.Errorf("normalized = %+v", rules)
+	}
+
+	now := time.Now()
+	rules.RefreshedAt = &now
+	if rules.normalize(); rules.RefreshedAt != nil {
+		t.Error("normalize kept refreshedAt")
+	}
+
+	for _, bad := range []SmartRules{
+		{},
+		{Rules: make([]SmartRule, maxSmartRules+1)},
+		{Rules: []SmartRule{{Type: "random"}}},
+		{Rules: []SmartRule{{Type: smartRulePlayed, Days: maxSmartDays + 1}}},
+		{Rules: []SmartRule{{Type: smartRuleFollowed, Days: 7}}},
+		{Rules: []SmartRule{{Type: smartRuleFollowed, MinVotes: -1}}},
+		{Rules: []SmartRule{{Type: smartRuleArtists, MinVotes: 5}}},
+		{Rules: []SmartRule{{Type: smartRuleArtists}}, Limit: maxSmartLimit + 1},
+		{Rules: []SmartRule{{Type: smartRuleArtists}}, RefreshHours: -1},
+	} {
+		if msg := bad.normalize(); msg == "" {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestHandleSmartRules(t *testing.T) {
+	h, pl := newModerationTestServer(t)
+	base := "/playlists/" + pl.ID
+	path := base + "/smart"
+	rules := map[string]any{"rules": []map[string]any{{"type": "played", "minVotes": 5}}, "refreshHours": 24}
+
+	if w := serveAs(h, "u-2", "GET", path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("rules of a normal playlist: got %d", w.Code)
+	}
+	if w := serveAs(h, "co-1", "PUT", path, rules); w.Code != http.StatusForbidden {
+		t.Errorf("co-owner setting rules: got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "PUT", path, map[string]any{"rules": []map[string]any{{"type": "random"}}}); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid rules: got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "PUT", "/playlists/"+missingID+"/smart", rules); w.Code != http.StatusNotFound {
+		t.Errorf("rules of missing playlist: got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "PUT", path, rules); w.Code != http.StatusOK {
+		t.Fatalf("set rules: got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	w := serveAs(h, "u-2", "GET", path, nil)
+	var got SmartRules
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if w.Code != http.StatusOK || len(got.Rules) != 1 || got.Rules[0].Days != defaultSmartDays || got.RefreshHours != 24 {
+		t.Errorf("get rules = %d %+v", w.Code, got)
+	}
+
+	w = serveAs(h, "ed-1", "GET", base, nil)
+	var resp struct {
+		CanEdit bool        `json:"canEdit"`
+		Smart   *SmartRules `json:"smart"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if resp.CanEdit || resp.Smart == nil {
+		t.Errorf("get smart playlist = %+v", resp)
+	}
+
+	// Nobody edits the tracks by hand, not even the owner.
+	for _, userID := range []string{"owner-1", "ed-1"} {
+		w := serveAs(h, userID, "POST", base+"/tracks", map[string]any{"title": "Song"})
+		if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "smart playlist") {
+			t.Errorf("%s adding a track: got %d. Body: %s", userID, w.Code, w.Body.String())
+		}
+	}
+
+	if w := serveAs(h, "co-1", "DELETE", path, nil); w.Code != http.StatusForbidden {
+		t.Errorf("co-owner converting: got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "DELETE", path, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("convert: got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if w := serveAs(h, "owner-1", "DELETE", path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("converting a normal playlist: got %d", w.Code)
+	}
+	if w := serveAs(h, "ed-1", "POST", base+"/tracks", map[string]any{"title": "Song"}); w.Code != http.StatusCreated {
+		t.Errorf("adding after converting: got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleSuggestions_SmartPlaylist(t *testing.T) {
+	h, pl := newSuggestionTestServer(t)
+	base := "/playlists/" + pl.ID
+	sg := suggest(t, h, "guest", pl.ID, "Song")
+
+	rules := map[string]any{"rules": []map[string]any{{"type": "followed"}}}
+	if w := serveAs(h, "owner-1", "PUT", base+"/smart", rules); w.Code != http.StatusOK {
+		t.Fatalf("set rules: got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	if w := serveAs(h, "guest", "POST", base+"/suggestions", map[string]string{"title": "Other"}); w.Code != http.StatusConflict {
+		t.Errorf("suggesting: got %d", w.Code)
+	}
+	if w := serveAs(h, "owner-1", "POST", base+"/suggestions/"+sg.ID+"/approve", nil); w.Code != http.StatusConflict {
+		t.Errorf("approving: got %d", w.Code)
+	}
+}
+
+func TestHandleCreatePlaylist_Smart(t *testing.T) {
+	h := NewServerWithStore(NewMemoryStore(), nil).Router()
+
+	bad := map[string]any{"name": "Mix", "smart": map[string]any{"rules": []any{}}}
+	if w := serveAs(h, "user-1", "POST", "/playlists", bad); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid rules: got %d", w.Code)
+	}
+
+	body := map[string]any{"name": "Mix", "smart": map[string]any{"rules": []map[string]any{{"type": "preferred_artists"}}}}
+	w := serveAs(h, "user-1", "POST", "/playlists", body)
+	var pl Playlist
+	_ = json.NewDecoder(w.Body).Decode(&pl)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	w = serveAs(h, "user-1", "GET", "/playlists/"+pl.ID+"/smart", nil)
+	var got SmartRules
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if w.Code != http.StatusOK || len(got.Rules) != 1 || got.Limit != defaultSmartLimit {
+		t.Errorf("get rules = %d %+v", w.Code, got)
+	}
+}
+
+func TestRefreshSmartPlaylist(t *testing.T) {
+	var inserted []string
+	var deleted, committed bool
+	tx := &MockTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			switch {
+			case strings.Contains(sql, "smart_rules IS NOT NULL"):
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*bool) = true
+					return nil
+				}}
+			case strings.Contains(sql, "status = 'playing'"):
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[2].(*string) = "Now"
+					*dest[3].(*string) = "Band"
+					return nil
+				}}
+			case strings.Contains(sql, "INSERT INTO tracks"):
+				if args[8] != trackSourceSmart {
+					t.Errorf("inserted with source %v", args[8])
+				}
+				inserted = append(inserted, args[1].(string))
+				return &MockRow{}
+			case strings.Contains(sql, "SET smart_refreshed_at = now()"):
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*time.Time) = time.Now()
+					return nil
+				}}
+			}
+			return &MockRow{}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if !strings.Contains(sql, "status = 'queued'") {
+				t.Errorf("unexpected tx query %q", sql)
+			}
+			return &MockRows{Idx: -1, Data: [][]any{
+				{"q-4", 4}, {"q-3", 3}, {"q-2", 2}, {"q-1", 1},
+			}}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "DELETE FROM tracks") {
+				deleted = true
+				return pgconn.NewCommandTag("DELETE 4"), nil
+			}
+			return pgconn.CommandTag{}, nil
+		},
+		CommitFunc: func(ctx context.Context) error {
+			committed = true
+			return nil
+		},
+	}
+	mockDB := &MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if row, ok := contentRulesRow(sql); ok {
+				return row
+			}
+			switch {
+			case strings.Contains(sql, "SELECT owner_id"):
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "owner-1"
+					return nil
+				}}
+			case strings.Contains(sql, "SELECT smart_rules"):
+				return &MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*[]byte) = []byte(`{"rules":[{"type":"played","minVotes":5,"days":30}],"limit":2}`)
+					return nil
+				}}
+			}
+			return &MockRow{}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if !strings.Contains(sql, "FROM play_history") || args[0] != "owner-1" || args[2] != 30 || args[3] != 5 {
+				t.Errorf("unexpected candidate query %q %v", sql, args)
+			}
+			return &MockRows{Idx: -1, Data: [][]any{
+				{"Now", "Band", "", "", "", 0},
+				{"Hit", "Band", "youtube", "1", "", 180000},
+				{"hit", "band", "", "", "", 0},
+				{"Deep Cut", "Band", "youtube", "2", "", 200000},
+				{"Encore", "Band", "youtube", "3", "", 190000},
+			}}, nil
+		},
+		BeginTxFunc: func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+			return tx, nil
+		},
+	}
+
+	res, err := NewServer(mockDB, nil).refreshSmartPlaylist(context.Background(), "pl-1")
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if !deleted || !committed {
+		t.Errorf("deleted queue = %v, committed = %v", deleted, committed)
+	}
+	// The playing track and the duplicate are skipped, and the limit stops
+	// before the last one.
+	if res.Added != 2 || res.Removed != 4 || strings.Join(inserted, ",") != "Hit,Deep Cut" {
+		t.Errorf("refresh = %+v, inserted %v", res, inserted)
+	}
+}
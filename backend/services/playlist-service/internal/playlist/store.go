@@ -0,0 +1,331 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by PlaylistStore methods when the playlist, track
+// or other row they need does not exist.
+var ErrNotFound = errors.New("not found")
+
+// PlaylistStore is the typed persistence layer behind every playlist
+// endpoint and background job.
+// PostgresStore is the production implementation; MemoryStore keeps
+// everything in process for tests and local tooling. Both must pass the
+// conformance suite in store_conformance_test.go.
+//
+// Missing playlists and tracks are reported as ErrNotFound. Playlists in
+// the trash count as missing everywhere except in the trash methods.
+type PlaylistStore interface {
+	// Playlists
+	// CreatePlaylist stores a new playlist, filling in its ID and CreatedAt.
+	// A non-nil smart makes it a smart playlist in the same step (see
+	// SetSmartRules).
+	CreatePlaylist(ctx context.Context, pl *Playlist, smart *SmartRules) error
+	GetPlaylist(ctx context.Context, id string) (Playlist, error)
+	// UpdatePlaylist loads the playlist, lets update change it and saves the
+	// result atomically. An error from update aborts the change and is
+	// returned as is.
+	UpdatePlaylist(ctx context.Context, id string, update func(*Playlist) error) (Playlist, error)
+	// DeletePlaylist moves the playlist to the trash, stopping its playback,
+	// unless check returns an error. check is only guaranteed to see the ID
+	// and OwnerID.
+	DeletePlaylist(ctx context.Context, id string, check func(Playlist) error) error
+	PlaylistAccess(ctx context.Context, id string) (ownerID string, isPublic bool, editMode string, err error)
+	DuplicatePolicy(ctx context.Context, id string) (policy string, fuzzy bool, err error)
+	// ListPlaylists returns the playlists userID can see that match q, in
+	// q.Sort order after q.Cursor, with TrackCount, LastActivityAt and
+	// FollowerCount set. It returns up to q.Limit+1 playlists, so callers
+	// can tell whether another page follows.
+	ListPlaylists(ctx context.Context, q listPlaylistsQuery, userID string) ([]Playlist, error)
+
+	// Trash
+	// ListTrash returns the trashed playlists of ownerID, most recently
+	// deleted first, with DeletedAt and TrackCount set.
+	ListTrash(ctx context.Context, ownerID string) ([]Playlist, error)
+	// RestorePlaylist takes a playlist out of the trash with its tracks and
+	// members unless check returns an error. check sees the ID, OwnerID and
+	// DeletedAt.
+	RestorePlaylist(ctx context.Context, id string, check func(Playlist) error) (Playlist, error)
+	// PurgeTrash permanently deletes the playlists trashed before cutoff and
+	// returns their IDs.
+	PurgeTrash(ctx context.Context, cutoff time.Time) ([]string, error)
+
+	// Tracks
+	// ListTracks returns the tracks in play order with their reaction and
+	// comment counts; IsVoted is set for viewerID's votes.
+	ListTracks(ctx context.Context, playlistID, viewerID string) ([]Track, error)
+	GetTrack(ctx context.Context, playlistID, trackID string) (Track, error)
+	// AddTrack queues a track at the end of the playlist. User tracks go
+	// before any queued radio track.
+	AddTrack(ctx context.Context, playlistID string, in trackInput, source string) (Track, error)
+	// AddTrackUnlessDuplicate adds userID's track like AddTrack, checking
+	// the playlist's duplicate policy atomically with the insert. Under
+	// merge_vote a queued duplicate gets userID's vote instead and merged is
+	// true, unless mergeDenied is non-nil. Rejections are *httpError.
+	AddTrackUnlessDuplicate(ctx context.Context, playlistID, userID string, in trackInput, mergeDenied *httpError) (tr Track, merged bool, err error)
+	// MoveTrack moves a track to newPos, clamped to the playlist length, and
+	// returns its old and new positions.
+	MoveTrack(ctx context.Context, playlistID, trackID string, newPos int) (from, to int, err error)
+	// DeleteTrack removes a track and returns the position it occupied.
+	DeleteTrack(ctx context.Context, playlistID, trackID string) (int, error)
+	// RemoveTracksByUser removes every queued track userID added in one
+	// step and returns their IDs in playlist order.
+	RemoveTracksByUser(ctx context.Context, playlistID, userID string) ([]string, error)
+	FindDuplicateTrack(ctx context.Context, playlistID, policy string, fuzzy bool, c candidateTrack) (*duplicateMatch, error)
+	// ApplyTrackBatch applies ops in order under the playlist's duplicate
+	// policy and fills results. Either every operation is applied or, when
+	// one fails, none is and a *batchOpError is returned. A non-nil
+	// mergeDenied fails adds that would be merged as a vote.
+	ApplyTrackBatch(ctx context.Context, playlistID, userID string, ops []batchOperation, mergeDenied *httpError, results []batchResult) error
+
+	// Provider lookups
+	// ClaimUnresolvedTracks claims and returns up to limit tracks with a
+	// provider ID that were never looked up, newest first. Other callers
+	// skip claimed tracks until claimFor has passed.
+	ClaimUnresolvedTracks(ctx context.Context, limit int, claimFor time.Duration) ([]unresolvedTrack, error)
+	// MarkTrackResolved stores the outcome of a provider lookup: the
+	// provider's metadata, or nil when the track is unavailable. It returns
+	// the updated track.
+	MarkTrackResolved(ctx context.Context, playlistID, trackID string, meta *trackInput) (Track, error)
+
+	// Radio
+	RadioSettings(ctx context.Context, playlistID string) (radioSettings, error)
+	// RecentArtists returns up to limit artists played in the playlist, most
+	// recently played first.
+	RecentArtists(ctx context.Context, playlistID string, limit int) ([]string, error)
+	// AddRadioTracks queues radio tracks picked from candidates (see
+	// pickRadioTracks) until want tracks are queued, and returns them.
+	AddRadioTracks(ctx context.Context, playlistID string, want int, candidates []trackInput, rules ContentRules) ([]Track, error)
+
+	// Members
+	// MemberRole returns the role of a member, or "" for non-members.
+	MemberRole(ctx context.Context, playlistID, userID string) (string, error)
+	ListMembers(ctx context.Context, playlistID string) ([]PlaylistInvite, error)
+	// AddMember is a no-op if the user already is a member.
+	AddMember(ctx context.Context, playlistID, userID, role string) error
+	// RemoveMember is a no-op if the user is not a member.
+	RemoveMember(ctx context.Context, playlistID, userID string) error
+
+	// Invitations
+	// InviteMember stores a pending invitation that expires after ttl,
+	// replacing an earlier one, and sets CreatedAt, ExpiresAt and
+	// PlaylistName.
+	InviteMember(ctx context.Context, inv *PlaylistInvitation, ttl time.Duration) error
+	// ListInvitations returns the pending invitations of a playlist, oldest
+	// first.
+	ListInvitations(ctx context.Context, playlistID string) ([]PlaylistInvitation, error)
+	// ListUserInvitations returns the pending invitations of userID to
+	// playlists not in the trash, newest first.
+	ListUserInvitations(ctx context.Context, userID string) ([]PlaylistInvitation, error)
+	// AcceptInvitation makes userID a member with the role of their pending
+	// invitation and returns the membership, or ErrNotFound without one.
+	AcceptInvitation(ctx context.Context, playlistID, userID string) (PlaylistInvite, error)
+	// DeclineInvitation drops a pending invitation and returns
+	// ErrNotFound if there was none.
+	DeclineInvitation(ctx context.Context, playlistID, userID string) error
+	// DeleteExpiredInvitations removes the invitations that expired.
+	DeleteExpiredInvitations(ctx context.Context) error
+
+	// Join links
+	// CreateJoinLink stores l under a fresh code, setting Code, Uses and
+	// CreatedAt.
+	CreateJoinLink(ctx context.Context, l *JoinLink) error
+	// ListJoinLinks returns the join links of a playlist, newest first,
+	// including revoked and used-up ones.
+	ListJoinLinks(ctx context.Context, playlistID string) ([]JoinLink, error)
+	// RevokeJoinLink revokes a join link, keeping the time of an earlier
+	// revocation, and returns ErrNotFound if the playlist has no such code.
+	RevokeJoinLink(ctx context.Context, playlistID, code string) error
+	// RedeemJoinLink makes userID a member with the role of a valid join
+	// link, counting a use and answering a pending invitation. The owner and
+	// members get their current role without using up the link. It returns
+	// ErrNotFound for invalid codes and errBannedFromPlaylist for banned
+	// users.
+	RedeemJoinLink(ctx context.Context, code, userID string) (JoinResult, error)
+
+	// Follows
+	// SetFollow makes userID follow (on) or unfollow a playlist and reports
+	// whether that changed anything.
+	SetFollow(ctx context.Context, playlistID, userID string, on bool) (changed bool, err error)
+	FollowerCount(ctx context.Context, playlistID string) (int, error)
+	// ListFollowedPlaylists returns the playlists userID follows and can
+	// still see (see followedVisible), most recently followed first, with
+	// TrackCount and FollowerCount set.
+	ListFollowedPlaylists(ctx context.Context, userID string) ([]Playlist, error)
+	// Feed returns up to limit items of the playlists userID follows and can
+	// see from before the given time, newest first.
+	Feed(ctx context.Context, userID string, before time.Time, limit int) ([]FeedItem, error)
+	// Followers returns the name of a playlist and the followers who can
+	// see it.
+	Followers(ctx context.Context, playlistID string) (name string, userIDs []string, err error)
+
+	// Votes
+	// VoteTrack records userID's vote and returns the updated track. Queued
+	// tracks are re-sorted by votes. It returns errAlreadyVoted for a second
+	// vote by the same user.
+	VoteTrack(ctx context.Context, playlistID, trackID, userID string) (Track, error)
+
+	// Reactions and comments
+	// SetReaction adds (on) or removes userID's emoji reaction to a track and
+	// returns the track's reaction counts by emoji. Adding a reaction twice
+	// or removing a missing one changes nothing.
+	SetReaction(ctx context.Context, playlistID, trackID, userID, emoji string, on bool) (map[string]int, error)
+	// ListComments returns the comments on a track, oldest first.
+	ListComments(ctx context.Context, playlistID, trackID string) ([]TrackComment, error)
+	// AddComment stores a comment on c.TrackID and sets its ID and CreatedAt.
+	AddComment(ctx context.Context, c *TrackComment) error
+	// DeleteComment removes a comment unless check returns an error.
+	DeleteComment(ctx context.Context, playlistID, trackID, commentID string, check func(TrackComment) error) error
+
+	// Moderation
+	// ModerationState returns the locks of a playlist and whether userID is
+	// banned from it.
+	ModerationState(ctx context.Context, playlistID, userID string) (ModerationState, error)
+	// SetLock locks the queue or voting of a playlist until the given time;
+	// a nil until lifts the lock.
+	SetLock(ctx context.Context, playlistID, target string, until *time.Time) error
+	// BanUser records a ban, replacing an earlier one, and removes the user
+	// from the members and delegates.
+	BanUser(ctx context.Context, ban *PlaylistBan) error
+	// UnbanUser lifts a ban and returns ErrNotFound if there was none.
+	UnbanUser(ctx context.Context, playlistID, userID string) error
+	ListBans(ctx context.Context, playlistID string) ([]PlaylistBan, error)
+	// AddModerationEntry appends to the moderation log, setting ID and
+	// CreatedAt.
+	AddModerationEntry(ctx context.Context, e *ModerationEntry) error
+	// ListModerationLog returns the latest limit entries, newest first.
+	ListModerationLog(ctx context.Context, playlistID string, limit int) ([]ModerationEntry, error)
+
+	// Content rules
+	ContentRules(ctx context.Context, playlistID string) (ContentRules, error)
+	SetContentRules(ctx context.Context, playlistID string, rules ContentRules) error
+
+	// Edit license
+	EditLicense(ctx context.Context, playlistID string) (EditLicense, error)
+	SetEditLicense(ctx context.Context, playlistID string, license EditLicense) error
+
+	// Smart playlists
+	// SmartRules returns the rules of a smart playlist, or nil for a normal
+	// playlist.
+	SmartRules(ctx context.Context, playlistID string) (*SmartRules, error)
+	// SetSmartRules replaces the rules of a playlist, ignoring RefreshedAt,
+	// which is cleared until the next refresh; nil turns the playlist into a
+	// normal one, keeping its tracks.
+	SetSmartRules(ctx context.Context, playlistID string, rules *SmartRules) error
+	// PlayedTracks returns the tracks played within the last days days in
+	// ownerID's playlists other than playlistID with at least minVotes
+	// votes, most voted first.
+	PlayedTracks(ctx context.Context, ownerID, playlistID string, days, minVotes, limit int) ([]trackInput, error)
+	// FollowedTracks returns the tracks with at least minVotes votes of the
+	// playlists userID follows and sees, other than playlistID, most voted
+	// first.
+	FollowedTracks(ctx context.Context, userID, playlistID string, minVotes, limit int) ([]trackInput, error)
+	// ReplaceSmartQueue replaces the queued tracks of a smart playlist with
+	// the candidates pickSmartTracks picks, skipping the playing track, and
+	// stamps the refresh time. It returns errNotSmart for a normal playlist.
+	ReplaceSmartQueue(ctx context.Context, playlistID string, limit int, candidates []trackInput, rules ContentRules) (smartQueueUpdate, error)
+	// ClaimDueSmartPlaylists stamps up to limit smart playlists due for a
+	// refresh as refreshed and returns their IDs. Concurrent callers claim
+	// different playlists.
+	ClaimDueSmartPlaylists(ctx context.Context, limit int) ([]string, error)
+
+	// Suggestions
+	// AddSuggestion stores a pending suggestion and sets its ID, Status and
+	// CreatedAt.
+	AddSuggestion(ctx context.Context, sg *TrackSuggestion) error
+	// ListSuggestions returns the suggestions of a playlist, oldest first.
+	// Non-empty status and suggestedBy filter them.
+	ListSuggestions(ctx context.Context, playlistID, status, suggestedBy string) ([]TrackSuggestion, error)
+	GetSuggestion(ctx context.Context, playlistID, suggestionID string) (TrackSuggestion, error)
+	// ApproveSuggestion queues a pending suggestion as a user track added by
+	// the suggester. Deciding a suggestion that is no longer pending returns
+	// errSuggestionDecided.
+	ApproveSuggestion(ctx context.Context, playlistID, suggestionID, decidedBy string) (TrackSuggestion, Track, error)
+	RejectSuggestion(ctx context.Context, playlistID, suggestionID, decidedBy, reason string) (TrackSuggestion, error)
+
+	// Skip votes
+	// TouchPresence records that userID has the playlist open at the given
+	// time.
+	TouchPresence(ctx context.Context, playlistID, userID string, at time.Time) error
+	// CountPresent returns how many users were present since the given time.
+	CountPresent(ctx context.Context, playlistID string, since time.Time) (int, error)
+	// AddSkipVote records userID's vote to skip trackID and returns the
+	// track's skip votes. It returns errPlaybackChanged if trackID is not the
+	// playing track and errAlreadyVoted for a second vote by the same user.
+	AddSkipVote(ctx context.Context, playlistID, trackID, userID string) (int, error)
+	// SkipVotes returns the skip votes of a track and whether userID cast
+	// one of them.
+	SkipVotes(ctx context.Context, playlistID, trackID, userID string) (votes int, voted bool, err error)
+
+	// Player
+	// Player returns the player attached to a playlist, or nil.
+	Player(ctx context.Context, playlistID string) (*PlayerState, error)
+	// UpdatePlayer lets update change the attached player (nil when none is)
+	// and saves the result atomically; returning nil detaches the player. An
+	// error from update aborts the change and is returned as is.
+	UpdatePlayer(ctx context.Context, playlistID string, update func(*PlayerState) (*PlayerState, error)) (*PlayerState, error)
+
+	// Devices
+	// RegisterDevice saves a new device, setting ID, CreatedAt and
+	// LastSeenAt.
+	RegisterDevice(ctx context.Context, d *Device) error
+	// Device returns a device of any user.
+	Device(ctx context.Context, deviceID string) (Device, error)
+	// ListDevices returns the devices of a user, most recently seen first.
+	ListDevices(ctx context.Context, userID string) ([]Device, error)
+	// UpdateDevice applies update to a device of userID and saves it; an
+	// error from update aborts the change and is returned as is.
+	UpdateDevice(ctx context.Context, userID, deviceID string, update func(*Device) error) (Device, error)
+	// DeleteDevice removes a device of userID, which stops being the active
+	// device of any playlist.
+	DeleteDevice(ctx context.Context, userID, deviceID string) error
+	// ActiveDevice returns the ID of the active output device of a playlist,
+	// or "" when none is selected.
+	ActiveDevice(ctx context.Context, playlistID string) (string, error)
+	// SetActiveDevice selects the active output device; "" clears it.
+	SetActiveDevice(ctx context.Context, playlistID, deviceID string) error
+
+	// Delegations
+	// SetDelegation grants or renews a delegation, setting CreatedAt.
+	SetDelegation(ctx context.Context, d *PlaybackDelegation) error
+	// RemoveDelegation revokes a delegation and returns ErrNotFound if
+	// there was none.
+	RemoveDelegation(ctx context.Context, playlistID, userID string) error
+	// ListDelegations returns the delegations still valid at now, newest
+	// first.
+	ListDelegations(ctx context.Context, playlistID string, now time.Time) ([]PlaybackDelegation, error)
+	// HasDelegation reports whether userID holds a delegation valid at now.
+	HasDelegation(ctx context.Context, playlistID, userID string, now time.Time) (bool, error)
+
+	// Playback
+	// AdvancePlayback marks the current track played (ending its playback
+	// with reason) and starts the next available queued track at now. With
+	// expectTrackID set it only advances if that track is still playing and,
+	// for playEndFinished, has ended (see trackEnded); it returns
+	// errPlaybackChanged otherwise.
+	AdvancePlayback(ctx context.Context, playlistID, reason, expectTrackID string, now time.Time) (PlaybackState, error)
+	// PlayingTracks returns the playing tracks with a known duration and
+	// when they end, of one playlist or, for "", of all of them. While a
+	// player heartbeated within heartbeatTimeout the end is pushed back to
+	// when it would time out.
+	PlayingTracks(ctx context.Context, playlistID string, heartbeatTimeout time.Duration) ([]playingTrack, error)
+
+	// Play history
+	// ListPlayHistory returns up to limit playbacks started before the given
+	// time (nil for no bound), most recent first.
+	ListPlayHistory(ctx context.Context, playlistID string, before *time.Time, limit int) ([]PlayHistoryEntry, error)
+	// PlayStats aggregates the playbacks started in [since, until), either
+	// bound open when nil, with the topArtists most played artists. SkipRate
+	// is left to the caller.
+	PlayStats(ctx context.Context, playlistID string, since, until *time.Time, topArtists int) (PlayStats, error)
+}
+
+// PlaybackState is the player state of a playlist after it advanced.
+// Current is nil when the queue ran out and playback stopped.
+type PlaybackState struct {
+	Current   *Track
+	StartedAt time.Time
+}
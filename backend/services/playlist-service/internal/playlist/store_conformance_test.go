@@ -0,0 +1,2082 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// missingID is a well-formed UUID that no row has.
+const missingID = "00000000-0000-0000-0000-000000000000"
+
+func TestMemoryStore(t *testing.T) {
+	testPlaylistStore(t, func(t *testing.T) PlaylistStore { return NewMemoryStore() })
+}
+
+func TestPostgresStore(t *testing.T) {
+	_, cleanup, pool := setupIntegrationTest(t)
+	defer cleanup()
+
+	testPlaylistStore(t, func(t *testing.T) PlaylistStore { return NewPostgresStore(pool) })
+}
+
+// testPlaylistStore is the conformance suite every PlaylistStore must pass.
+// Each test works on its own playlists, so stores may be shared.
+func testPlaylistStore(t *testing.T, newStore func(t *testing.T) PlaylistStore) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s PlaylistStore)
+	}{
+		{"Playlist CRUD", testStorePlaylistCRUD},
+		{"Missing Playlist", testStoreMissingPlaylist},
+		{"Trash", testStoreTrash},
+		{"Purge", testStorePurge},
+		{"Tracks", testStoreTracks},
+		{"Radio Tracks Stay Last", testStoreRadioTracks},
+		{"Duplicates", testStoreDuplicates},
+		{"Add Unless Duplicate", testStoreAddUnlessDuplicate},
+		{"Members", testStoreMembers},
+		{"Votes", testStoreVotes},
+		{"Reactions", testStoreReactions},
+		{"Comments", testStoreComments},
+		{"Moderation", testStoreModeration},
+		{"Remove Tracks By User", testStoreRemoveTracksByUser},
+		{"Content Rules", testStoreContentRules},
+		{"Edit License", testStoreEditLicense},
+		{"Smart Rules", testStoreSmartRules},
+		{"Smart Refresh", testStoreSmartRefresh},
+		{"Suggestions", testStoreSuggestions},
+		{"Skip Votes", testStoreSkipVotes},
+		{"Player", testStorePlayer},
+		{"Devices", testStoreDevices},
+		{"Delegations", testStoreDelegations},
+		{"Playback", testStorePlayback},
+		{"Scheduled Playback", testStoreScheduledPlayback},
+		{"Playing Tracks", testStorePlayingTracks},
+		{"Play History", testStorePlayHistory},
+		{"Listing", testStoreListing},
+		{"Track Batch", testStoreTrackBatch},
+		{"Track Lookups", testStoreTrackLookups},
+		{"Radio", testStoreRadio},
+		{"Invitations", testStoreInvitations},
+		{"Join Links", testStoreJoinLinks},
+		{"Follows", testStoreFollows},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func createTestPlaylist(t *testing.T, s PlaylistStore) Playlist {
+	t.Helper()
+	pl := Playlist{
+		OwnerID:         "owner-" + newMemoryID(),
+		Name:            "Conformance",
+		Description:     "store test",
+		IsPublic:        true,
+		EditMode:        editModeEveryone,
+		DuplicatePolicy: duplicatePolicyAllow,
+		RadioMinQueue:   defaultRadioMinQueue,
+	}
+	if err := s.CreatePlaylist(context.Background(), &pl, nil); err != nil {
+		t.Fatalf("CreatePlaylist: %v", err)
+	}
+	return pl
+}
+
+func addTestTrack(t *testing.T, s PlaylistStore, playlistID, title, source string) Track {
+	t.Helper()
+	tr, err := s.AddTrack(context.Background(), playlistID, trackInput{
+		Title:         title,
+		Artist:        "Artist",
+		Provider:      "youtube",
+		ProviderTrack: "vid-" + title,
+	}, source)
+	if err != nil {
+		t.Fatalf("AddTrack(%s): %v", title, err)
+	}
+	return tr
+}
+
+// trackTitles lists the titles of a playlist in play order and checks that
+// positions are dense.
+func trackTitles(t *testing.T, s PlaylistStore, playlistID string) []string {
+	t.Helper()
+	tracks, err := s.ListTracks(context.Background(), playlistID, "")
+	if err != nil {
+		t.Fatalf("ListTracks: %v", err)
+	}
+	titles := make([]string, len(tracks))
+	for i, tr := range tracks {
+		if tr.Position != i {
+			t.Errorf("track %s: position %d at index %d", tr.Title, tr.Position, i)
+		}
+		titles[i] = tr.Title
+	}
+	return titles
+}
+
+func assertTitles(t *testing.T, s PlaylistStore, playlistID string, want ...string) {
+	t.Helper()
+	if got := trackTitles(t, s, playlistID); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("tracks = %v, want %v", got, want)
+	}
+}
+
+func testStorePlaylistCRUD(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	if pl.ID == "" || pl.CreatedAt.IsZero() {
+		t.Fatalf("CreatePlaylist did not set ID and CreatedAt: %+v", pl)
+	}
+
+	got, err := s.GetPlaylist(ctx, pl.ID)
+	if err != nil {
+		t.Fatalf("GetPlaylist: %v", err)
+	}
+	if got.OwnerID != pl.OwnerID || got.Name != "Conformance" || !got.IsPublic || got.EditMode != editModeEveryone ||
+		got.RadioMinQueue != defaultRadioMinQueue || got.CurrentTrackID != nil {
+		t.Errorf("GetPlaylist = %+v", got)
+	}
+
+	ownerID, isPublic, editMode, err := s.PlaylistAccess(ctx, pl.ID)
+	if err != nil || ownerID != pl.OwnerID || !isPublic || editMode != editModeEveryone {
+		t.Errorf("PlaylistAccess = %q, %v, %q, %v", ownerID, isPublic, editMode, err)
+	}
+
+	updated, err := s.UpdatePlaylist(ctx, pl.ID, func(p *Playlist) error {
+		p.Name = "Renamed"
+		p.IsPublic = false
+		p.EditMode = editModeInvited
+		p.DuplicatePolicy = duplicatePolicyReject
+		p.FuzzyDuplicates = true
+		return nil
+	})
+	if err != nil || updated.Name != "Renamed" {
+		t.Fatalf("UpdatePlaylist = %+v, %v", updated, err)
+	}
+	policy, fuzzy, err := s.DuplicatePolicy(ctx, pl.ID)
+	if err != nil || policy != duplicatePolicyReject || !fuzzy {
+		t.Errorf("DuplicatePolicy = %q, %v, %v", policy, fuzzy, err)
+	}
+
+	errAbort := errors.New("abort")
+	if _, err := s.UpdatePlaylist(ctx, pl.ID, func(p *Playlist) error {
+		p.Name = "Not saved"
+		return errAbort
+	}); !errors.Is(err, errAbort) {
+		t.Errorf("UpdatePlaylist abort = %v", err)
+	}
+	if got, _ := s.GetPlaylist(ctx, pl.ID); got.Name != "Renamed" || got.IsPublic || got.EditMode != editModeInvited {
+		t.Errorf("after aborted update = %+v", got)
+	}
+
+	if got.Visibility != visibilityPublic || updated.Visibility != visibilityPrivate {
+		t.Errorf("visibility = %q, then %q", got.Visibility, updated.Visibility)
+	}
+	if _, err := s.UpdatePlaylist(ctx, pl.ID, func(p *Playlist) error {
+		p.Visibility = visibilityFriends
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdatePlaylist visibility: %v", err)
+	}
+	if got, _ := s.GetPlaylist(ctx, pl.ID); got.Visibility != visibilityFriends || got.IsPublic {
+		t.Errorf("friends-only playlist = %+v", got)
+	}
+
+	if err := s.DeletePlaylist(ctx, pl.ID, func(p Playlist) error {
+		if p.OwnerID != pl.OwnerID {
+			t.Errorf("check got owner %q", p.OwnerID)
+		}
+		return errAbort
+	}); !errors.Is(err, errAbort) {
+		t.Errorf("DeletePlaylist abort = %v", err)
+	}
+	if err := s.DeletePlaylist(ctx, pl.ID, func(Playlist) error { return nil }); err != nil {
+		t.Fatalf("DeletePlaylist: %v", err)
+	}
+	if _, err := s.GetPlaylist(ctx, pl.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPlaylist after delete = %v", err)
+	}
+}
+
+func testStoreMissingPlaylist(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	if _, err := s.GetPlaylist(ctx, missingID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPlaylist = %v", err)
+	}
+	if _, _, _, err := s.PlaylistAccess(ctx, missingID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("PlaylistAccess = %v", err)
+	}
+	if _, err := s.UpdatePlaylist(ctx, missingID, func(*Playlist) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdatePlaylist = %v", err)
+	}
+	if err := s.DeletePlaylist(ctx, missingID, func(Playlist) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeletePlaylist = %v", err)
+	}
+	if _, err := s.AdvancePlayback(ctx, missingID, playEndSkipped, "", time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AdvancePlayback = %v", err)
+	}
+	if tracks, err := s.ListTracks(ctx, missingID, ""); err != nil || len(tracks) != 0 {
+		t.Errorf("ListTracks = %v, %v", tracks, err)
+	}
+}
+
+func testStoreTrash(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	a := addTestTrack(t, s, pl.ID, "A", trackSourceUser)
+	addTestTrack(t, s, pl.ID, "B", trackSourceUser)
+	if err := s.AddMember(ctx, pl.ID, "u-1", memberRoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeletePlaylist(ctx, pl.ID, func(Playlist) error { return nil }); err != nil {
+		t.Fatalf("DeletePlaylist: %v", err)
+	}
+	if _, err := s.GetPlaylist(ctx, pl.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetPlaylist of a trashed playlist = %v", err)
+	}
+	if _, _, _, err := s.PlaylistAccess(ctx, pl.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("PlaylistAccess of a trashed playlist = %v", err)
+	}
+	if err := s.DeletePlaylist(ctx, pl.ID, func(Playlist) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeletePlaylist twice = %v", err)
+	}
+
+	trash, err := s.ListTrash(ctx, pl.OwnerID)
+	if err != nil || len(trash) != 1 || trash[0].ID != pl.ID || trash[0].DeletedAt == nil || trash[0].TrackCount != 2 {
+		t.Fatalf("ListTrash = %+v, %v", trash, err)
+	}
+	if other, _ := s.ListTrash(ctx, "u-1"); len(other) != 0 {
+		t.Errorf("ListTrash of another user = %+v", other)
+	}
+
+	if _, err := s.PurgeTrash(ctx, trash[0].DeletedAt.Add(-time.Minute)); err != nil {
+		t.Fatalf("PurgeTrash: %v", err)
+	}
+
+	errAbort := errors.New("abort")
+	if _, err := s.RestorePlaylist(ctx, pl.ID, func(p Playlist) error {
+		if p.OwnerID != pl.OwnerID || p.DeletedAt == nil {
+			t.Errorf("check got %+v", p)
+		}
+		return errAbort
+	}); !errors.Is(err, errAbort) {
+		t.Errorf("RestorePlaylist abort = %v", err)
+	}
+	restored, err := s.RestorePlaylist(ctx, pl.ID, func(Playlist) error { return nil })
+	if err != nil || restored.ID != pl.ID || restored.Name != pl.Name || restored.DeletedAt != nil {
+		t.Fatalf("RestorePlaylist = %+v, %v", restored, err)
+	}
+	if restored.CurrentTrackID != nil {
+		t.Errorf("playback survived the trash: %v", *restored.CurrentTrackID)
+	}
+	if _, err := s.RestorePlaylist(ctx, pl.ID, func(Playlist) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RestorePlaylist twice = %v", err)
+	}
+
+	assertTitles(t, s, pl.ID, "A", "B")
+	if tr, _ := s.GetTrack(ctx, pl.ID, a.ID); tr.Status != "played" {
+		t.Errorf("track playing when trashed has status %q", tr.Status)
+	}
+	if role, _ := s.MemberRole(ctx, pl.ID, "u-1"); role != memberRoleViewer {
+		t.Errorf("member role after restore = %q", role)
+	}
+	if trash, _ := s.ListTrash(ctx, pl.OwnerID); len(trash) != 0 {
+		t.Errorf("ListTrash after restore = %+v", trash)
+	}
+}
+
+func testStorePurge(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	kept := createTestPlaylist(t, s)
+	purged := createTestPlaylist(t, s)
+	addTestTrack(t, s, purged.ID, "A", trackSourceUser)
+
+	if err := s.DeletePlaylist(ctx, purged.ID, func(Playlist) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := s.PurgeTrash(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("PurgeTrash: %v", err)
+	}
+	if !containsString(ids, purged.ID) || containsString(ids, kept.ID) {
+		t.Errorf("PurgeTrash = %v", ids)
+	}
+	if trash, _ := s.ListTrash(ctx, purged.OwnerID); len(trash) != 0 {
+		t.Errorf("ListTrash after purge = %+v", trash)
+	}
+	if _, err := s.RestorePlaylist(ctx, purged.ID, func(Playlist) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RestorePlaylist after purge = %v", err)
+	}
+	if _, err := s.GetPlaylist(ctx, kept.ID); err != nil {
+		t.Errorf("GetPlaylist of a live playlist after purge = %v", err)
+	}
+}
+
+func testStoreTracks(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+
+	a := addTestTrack(t, s, pl.ID, "A", trackSourceUser)
+	addTestTrack(t, s, pl.ID, "B", trackSourceUser)
+	c := addTestTrack(t, s, pl.ID, "C", trackSourceUser)
+	if a.ID == "" || a.PlaylistID != pl.ID || a.Status != "queued" || a.Source != trackSourceUser || a.VoteCount != 0 {
+		t.Errorf("AddTrack = %+v", a)
+	}
+	if c.Position != 2 {
+		t.Errorf("third track position = %d", c.Position)
+	}
+	assertTitles(t, s, pl.ID, "A", "B", "C")
+
+	got, err := s.GetTrack(ctx, pl.ID, c.ID)
+	if err != nil || got.Title != "C" || got.Position != 2 || got.ProviderTrackID != "vid-C" {
+		t.Errorf("GetTrack = %+v, %v", got, err)
+	}
+	if _, err := s.GetTrack(ctx, pl.ID, missingID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetTrack missing = %v", err)
+	}
+
+	from, to, err := s.MoveTrack(ctx, pl.ID, c.ID, 0)
+	if err != nil || from != 2 || to != 0 {
+		t.Errorf("MoveTrack = %d, %d, %v", from, to, err)
+	}
+	assertTitles(t, s, pl.ID, "C", "A", "B")
+
+	from, to, err = s.MoveTrack(ctx, pl.ID, a.ID, 10)
+	if err != nil || from != 1 || to != 2 {
+		t.Errorf("MoveTrack past the end = %d, %d, %v", from, to, err)
+	}
+	assertTitles(t, s, pl.ID, "C", "B", "A")
+
+	if _, _, err := s.MoveTrack(ctx, pl.ID, missingID, 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MoveTrack missing = %v", err)
+	}
+
+	pos, err := s.DeleteTrack(ctx, pl.ID, c.ID)
+	if err != nil || pos != 0 {
+		t.Errorf("DeleteTrack = %d, %v", pos, err)
+	}
+	assertTitles(t, s, pl.ID, "B", "A")
+	if _, err := s.DeleteTrack(ctx, pl.ID, c.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteTrack twice = %v", err)
+	}
+}
+
+func testStoreRadioTracks(t *testing.T, s PlaylistStore) {
+	pl := createTestPlaylist(t, s)
+
+	addTestTrack(t, s, pl.ID, "A", trackSourceUser)
+	addTestTrack(t, s, pl.ID, "R1", trackSourceRadio)
+	addTestTrack(t, s, pl.ID, "R2", trackSourceRadio)
+	b := addTestTrack(t, s, pl.ID, "B", trackSourceUser)
+
+	if b.Position != 1 {
+		t.Errorf("user track added after radio tracks: position %d", b.Position)
+	}
+	assertTitles(t, s, pl.ID, "A", "B", "R1", "R2")
+}
+
+func testStoreDuplicates(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	tr := addTestTrack(t, s, pl.ID, "Bohemian Rhapsody (Official Video)", trackSourceUser)
+
+	byProvider := candidateTrack{Title: "anything", Provider: "youtube", ProviderTrackID: tr.ProviderTrackID}
+	m, err := s.FindDuplicateTrack(ctx, pl.ID, duplicatePolicyReject, false, byProvider)
+	if err != nil || m == nil || m.ID != tr.ID || m.Status != "queued" {
+		t.Errorf("provider match = %+v, %v", m, err)
+	}
+	if m, _ := s.FindDuplicateTrack(ctx, pl.ID, duplicatePolicyAllow, false, byProvider); m != nil {
+		t.Errorf("allow policy matched %+v", m)
+	}
+
+	byTitle := candidateTrack{Title: "bohemian rhapsody", Artist: "artist - Topic"}
+	if m, _ := s.FindDuplicateTrack(ctx, pl.ID, duplicatePolicyReject, false, byTitle); m != nil {
+		t.Errorf("matched by title without fuzzy matching: %+v", m)
+	}
+	m, err = s.FindDuplicateTrack(ctx, pl.ID, duplicatePolicyReject, true, byTitle)
+	if err != nil || m == nil || m.ID != tr.ID {
+		t.Errorf("fuzzy match = %+v, %v", m, err)
+	}
+
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := s.FindDuplicateTrack(ctx, pl.ID, duplicatePolicyAllowAfterPlayed, false, byProvider); m != nil {
+		t.Errorf("played track matched under allow_after_played: %+v", m)
+	}
+	if m, _ := s.FindDuplicateTrack(ctx, pl.ID, duplicatePolicyReject, false, byProvider); m == nil || m.Status != "played" {
+		t.Errorf("played track under reject = %+v", m)
+	}
+}
+
+func testStoreAddUnlessDuplicate(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	setPolicy := func(policy string) {
+		t.Helper()
+		if _, err := s.UpdatePlaylist(ctx, pl.ID, func(p *Playlist) error {
+			p.DuplicatePolicy = policy
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	in := trackInput{Title: "Song", Artist: "Artist", Provider: "youtube", ProviderTrack: "vid-Song"}
+
+	// Concurrent adds of the same track: only one passes the check.
+	setPolicy(duplicatePolicyReject)
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, errs[i] = s.AddTrackUnlessDuplicate(ctx, pl.ID, fmt.Sprintf("u-%d", i), in, nil)
+		}(i)
+	}
+	wg.Wait()
+	added := 0
+	for _, err := range errs {
+		var he *httpError
+		switch {
+		case err == nil:
+			added++
+		case !errors.As(err, &he) || he.status != http.StatusConflict:
+			t.Errorf("concurrent add = %v", err)
+		}
+	}
+	if added != 1 {
+		t.Errorf("%d concurrent adds passed the duplicate check", added)
+	}
+	assertTitles(t, s, pl.ID, "Song")
+
+	// Under merge_vote a queued duplicate gets a vote instead.
+	setPolicy(duplicatePolicyMergeVote)
+	tr, merged, err := s.AddTrackUnlessDuplicate(ctx, pl.ID, "voter", in, nil)
+	if err != nil || !merged || tr.VoteCount != 1 {
+		t.Errorf("merging = %+v, %v, %v", tr, merged, err)
+	}
+	var he *httpError
+	if _, _, err := s.AddTrackUnlessDuplicate(ctx, pl.ID, "voter", in, nil); !errors.As(err, &he) || he.status != http.StatusConflict {
+		t.Errorf("merging a second vote = %v", err)
+	}
+	locked := &httpError{status: http.StatusLocked, msg: lockedMessages[lockVoting]}
+	if _, _, err := s.AddTrackUnlessDuplicate(ctx, pl.ID, "other", in, locked); err != locked {
+		t.Errorf("merging while voting is locked = %v", err)
+	}
+
+	other := in
+	other.ProviderTrack = "vid-Other"
+	other.Title = "Other"
+	if tr, merged, err := s.AddTrackUnlessDuplicate(ctx, pl.ID, "u-1", other, locked); err != nil || merged || tr.Title != "Other" {
+		t.Errorf("adding a new track = %+v, %v, %v", tr, merged, err)
+	}
+	assertTitles(t, s, pl.ID, "Song", "Other")
+
+	if _, _, err := s.AddTrackUnlessDuplicate(ctx, missingID, "u-1", in, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("adding to missing playlist = %v", err)
+	}
+}
+
+func testStoreMembers(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+
+	if role, err := s.MemberRole(ctx, pl.ID, "u-1"); err != nil || role != "" {
+		t.Errorf("MemberRole before joining = %q, %v", role, err)
+	}
+	if err := s.AddMember(ctx, pl.ID, "u-1", memberRoleViewer); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	if err := s.AddMember(ctx, pl.ID, "u-2", memberRoleEditor); err != nil {
+		t.Fatalf("AddMember: %v", err)
+	}
+	if err := s.AddMember(ctx, pl.ID, "u-1", memberRoleEditor); err != nil {
+		t.Fatalf("AddMember again: %v", err)
+	}
+	if role, _ := s.MemberRole(ctx, pl.ID, "u-1"); role != memberRoleViewer {
+		t.Errorf("AddMember again changed the role to %q", role)
+	}
+	if role, _ := s.MemberRole(ctx, pl.ID, ""); role != "" {
+		t.Errorf("MemberRole of no user = %q", role)
+	}
+
+	members, err := s.ListMembers(ctx, pl.ID)
+	if err != nil || len(members) != 2 || members[0].UserID != "u-1" || members[1].UserID != "u-2" ||
+		members[1].Role != memberRoleEditor || members[0].CreatedAt.IsZero() {
+		t.Errorf("ListMembers = %+v, %v", members, err)
+	}
+
+	if err := s.RemoveMember(ctx, pl.ID, "u-1"); err != nil {
+		t.Fatalf("RemoveMember: %v", err)
+	}
+	if err := s.RemoveMember(ctx, pl.ID, "u-1"); err != nil {
+		t.Fatalf("RemoveMember again: %v", err)
+	}
+	if role, _ := s.MemberRole(ctx, pl.ID, "u-1"); role != "" {
+		t.Errorf("MemberRole after removal = %q", role)
+	}
+	if members, _ := s.ListMembers(ctx, pl.ID); len(members) != 1 {
+		t.Errorf("ListMembers after removal = %+v", members)
+	}
+}
+
+func testStoreVotes(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	a := addTestTrack(t, s, pl.ID, "A", trackSourceUser)
+	b := addTestTrack(t, s, pl.ID, "B", trackSourceUser)
+	c := addTestTrack(t, s, pl.ID, "C", trackSourceUser)
+	r := addTestTrack(t, s, pl.ID, "R", trackSourceRadio)
+
+	tr, err := s.VoteTrack(ctx, pl.ID, c.ID, "u-1")
+	if err != nil || tr.VoteCount != 1 || tr.Position != 0 {
+		t.Fatalf("VoteTrack = %+v, %v", tr, err)
+	}
+	assertTitles(t, s, pl.ID, "C", "A", "B", "R")
+
+	if _, err := s.VoteTrack(ctx, pl.ID, c.ID, "u-1"); !errors.Is(err, errAlreadyVoted) {
+		t.Errorf("second vote = %v", err)
+	}
+
+	s.VoteTrack(ctx, pl.ID, b.ID, "u-1")
+	s.VoteTrack(ctx, pl.ID, b.ID, "u-2")
+	// Votes never lift a radio track above user tracks.
+	s.VoteTrack(ctx, pl.ID, r.ID, "u-1")
+	s.VoteTrack(ctx, pl.ID, r.ID, "u-2")
+	s.VoteTrack(ctx, pl.ID, r.ID, "u-3")
+	assertTitles(t, s, pl.ID, "B", "C", "A", "R")
+
+	tracks, _ := s.ListTracks(ctx, pl.ID, "u-2")
+	for _, tr := range tracks {
+		if want := tr.ID == b.ID || tr.ID == r.ID; tr.IsVoted != want {
+			t.Errorf("%s: IsVoted = %v for u-2", tr.Title, tr.IsVoted)
+		}
+	}
+
+	other := createTestPlaylist(t, s)
+	if _, err := s.VoteTrack(ctx, other.ID, a.ID, "u-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("vote through another playlist = %v", err)
+	}
+}
+
+func testStoreReactions(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	a := addTestTrack(t, s, pl.ID, "A", trackSourceUser)
+	b := addTestTrack(t, s, pl.ID, "B", trackSourceUser)
+
+	s.SetReaction(ctx, pl.ID, a.ID, "u-1", "🔥", true)
+	s.SetReaction(ctx, pl.ID, a.ID, "u-1", "🔥", true)
+	s.SetReaction(ctx, pl.ID, a.ID, "u-2", "🔥", true)
+	counts, err := s.SetReaction(ctx, pl.ID, a.ID, "u-2", "🎉", true)
+	if err != nil || counts["🔥"] != 2 || counts["🎉"] != 1 || len(counts) != 2 {
+		t.Fatalf("SetReaction = %v, %v", counts, err)
+	}
+	counts, err = s.SetReaction(ctx, pl.ID, a.ID, "u-2", "🎉", false)
+	if err != nil || counts["🔥"] != 2 || len(counts) != 1 {
+		t.Errorf("remove reaction = %v, %v", counts, err)
+	}
+	if counts, err := s.SetReaction(ctx, pl.ID, b.ID, "u-1", "😂", false); err != nil || len(counts) != 0 {
+		t.Errorf("remove missing reaction = %v, %v", counts, err)
+	}
+
+	tracks, _ := s.ListTracks(ctx, pl.ID, "")
+	if len(tracks) != 2 || tracks[0].Reactions["🔥"] != 2 || len(tracks[1].Reactions) != 0 {
+		t.Errorf("ListTracks reactions = %+v", tracks)
+	}
+
+	other := createTestPlaylist(t, s)
+	if _, err := s.SetReaction(ctx, other.ID, a.ID, "u-1", "🔥", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("react through another playlist = %v", err)
+	}
+	if _, err := s.SetReaction(ctx, pl.ID, missingID, "u-1", "🔥", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("react to missing track = %v", err)
+	}
+}
+
+func testStoreComments(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	a := addTestTrack(t, s, pl.ID, "A", trackSourceUser)
+
+	first := TrackComment{PlaylistID: pl.ID, TrackID: a.ID, UserID: "u-1", Body: "first"}
+	if err := s.AddComment(ctx, &first); err != nil || first.ID == "" || first.CreatedAt.IsZero() {
+		t.Fatalf("AddComment = %+v, %v", first, err)
+	}
+	second := TrackComment{PlaylistID: pl.ID, TrackID: a.ID, UserID: "u-2", Body: "second"}
+	s.AddComment(ctx, &second)
+
+	comments, err := s.ListComments(ctx, pl.ID, a.ID)
+	if err != nil || len(comments) != 2 || comments[0].Body != "first" || comments[1].UserID != "u-2" {
+		t.Fatalf("ListComments = %+v, %v", comments, err)
+	}
+	if tracks, _ := s.ListTracks(ctx, pl.ID, ""); len(tracks) != 1 || tracks[0].CommentCount != 2 {
+		t.Errorf("ListTracks comment count = %+v", tracks)
+	}
+
+	denied := errors.New("denied")
+	if err := s.DeleteComment(ctx, pl.ID, a.ID, first.ID, func(TrackComment) error { return denied }); err != denied {
+		t.Errorf("DeleteComment with failing check = %v", err)
+	}
+	var seen TrackComment
+	if err := s.DeleteComment(ctx, pl.ID, a.ID, first.ID, func(c TrackComment) error { seen = c; return nil }); err != nil {
+		t.Fatalf("DeleteComment: %v", err)
+	}
+	if seen.UserID != "u-1" || seen.Body != "first" {
+		t.Errorf("check saw %+v", seen)
+	}
+	if err := s.DeleteComment(ctx, pl.ID, a.ID, first.ID, func(TrackComment) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete deleted comment = %v", err)
+	}
+	if comments, _ := s.ListComments(ctx, pl.ID, a.ID); len(comments) != 1 {
+		t.Errorf("comments after delete = %+v", comments)
+	}
+
+	bad := TrackComment{PlaylistID: pl.ID, TrackID: missingID, UserID: "u-1", Body: "lost"}
+	if err := s.AddComment(ctx, &bad); !errors.Is(err, ErrNotFound) {
+		t.Errorf("comment on missing track = %v", err)
+	}
+
+	// Deleting a track drops its comments and reactions.
+	s.SetReaction(ctx, pl.ID, a.ID, "u-1", "🔥", true)
+	s.DeleteTrack(ctx, pl.ID, a.ID)
+	if comments, _ := s.ListComments(ctx, pl.ID, a.ID); len(comments) != 0 {
+		t.Errorf("comments of a deleted track = %+v", comments)
+	}
+}
+
+func testStoreModeration(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+
+	st, err := s.ModerationState(ctx, pl.ID, "u-1")
+	if err != nil || st.Banned || st.QueueLockedUntil != nil || st.VotingLockedUntil != nil {
+		t.Fatalf("initial ModerationState = %+v, %v", st, err)
+	}
+	if _, err := s.ModerationState(ctx, missingID, "u-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ModerationState of missing playlist = %v", err)
+	}
+
+	until := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	if err := s.SetLock(ctx, pl.ID, lockVoting, &until); err != nil {
+		t.Fatalf("SetLock: %v", err)
+	}
+	st, _ = s.ModerationState(ctx, pl.ID, "u-1")
+	if st.QueueLockedUntil != nil || st.VotingLockedUntil == nil || !st.VotingLockedUntil.Equal(until) {
+		t.Errorf("locked state = %+v", st)
+	}
+	s.SetLock(ctx, pl.ID, lockVoting, nil)
+	if st, _ = s.ModerationState(ctx, pl.ID, "u-1"); st.VotingLockedUntil != nil {
+		t.Errorf("unlocked state = %+v", st)
+	}
+	if err := s.SetLock(ctx, missingID, lockQueue, &until); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetLock on missing playlist = %v", err)
+	}
+
+	// Banning removes the membership.
+	s.AddMember(ctx, pl.ID, "u-1", memberRoleEditor)
+	ban := PlaylistBan{PlaylistID: pl.ID, UserID: "u-1", BannedBy: pl.OwnerID, Reason: "spam"}
+	if err := s.BanUser(ctx, &ban); err != nil || ban.CreatedAt.IsZero() {
+		t.Fatalf("BanUser = %+v, %v", ban, err)
+	}
+	if role, _ := s.MemberRole(ctx, pl.ID, "u-1"); role != "" {
+		t.Errorf("banned user is still a %q", role)
+	}
+	if st, _ = s.ModerationState(ctx, pl.ID, "u-1"); !st.Banned {
+		t.Errorf("state of banned user = %+v", st)
+	}
+	if st, _ = s.ModerationState(ctx, pl.ID, "u-2"); st.Banned {
+		t.Errorf("state of other user = %+v", st)
+	}
+	bans, err := s.ListBans(ctx, pl.ID)
+	if err != nil || len(bans) != 1 || bans[0].UserID != "u-1" || bans[0].Reason != "spam" {
+		t.Fatalf("ListBans = %+v, %v", bans, err)
+	}
+	if err := s.UnbanUser(ctx, pl.ID, "u-1"); err != nil {
+		t.Fatalf("UnbanUser: %v", err)
+	}
+	if err := s.UnbanUser(ctx, pl.ID, "u-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second UnbanUser = %v", err)
+	}
+
+	for _, action := range []string{moderationKick, moderationBan, moderationUnban} {
+		e := ModerationEntry{PlaylistID: pl.ID, ActorID: pl.OwnerID, Action: action, TargetUserID: "u-1"}
+		if err := s.AddModerationEntry(ctx, &e); err != nil || e.ID == "" || e.CreatedAt.IsZero() {
+			t.Fatalf("AddModerationEntry = %+v, %v", e, err)
+		}
+	}
+	entries, err := s.ListModerationLog(ctx, pl.ID, 2)
+	if err != nil || len(entries) != 2 || entries[0].Action != moderationUnban || entries[1].Action != moderationBan {
+		t.Errorf("ListModerationLog = %+v, %v", entries, err)
+	}
+}
+
+func testStoreRemoveTracksByUser(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+
+	add := func(title, userID string) Track {
+		t.Helper()
+		tr, err := s.AddTrack(ctx, pl.ID, trackInput{Title: title, Artist: "Artist", addedBy: userID}, trackSourceUser)
+		if err != nil {
+			t.Fatalf("AddTrack(%s): %v", title, err)
+		}
+		return tr
+	}
+	add("Playing", "troll")
+	spam1 := add("Spam 1", "troll")
+	add("Song", "u-1")
+	spam2 := add("Spam 2", "troll")
+	add("Other", "u-1")
+	// The playing track stays.
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatalf("AdvancePlayback: %v", err)
+	}
+
+	removed, err := s.RemoveTracksByUser(ctx, pl.ID, "troll")
+	if err != nil || fmt.Sprint(removed) != fmt.Sprint([]string{spam1.ID, spam2.ID}) {
+		t.Fatalf("RemoveTracksByUser = %v, %v", removed, err)
+	}
+	assertTitles(t, s, pl.ID, "Playing", "Song", "Other")
+
+	removed, err = s.RemoveTracksByUser(ctx, pl.ID, "troll")
+	if err != nil || len(removed) != 0 {
+		t.Errorf("second RemoveTracksByUser = %v, %v", removed, err)
+	}
+	if _, err := s.RemoveTracksByUser(ctx, missingID, "troll"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RemoveTracksByUser on missing playlist = %v", err)
+	}
+}
+
+func testStoreContentRules(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+
+	rules, err := s.ContentRules(ctx, pl.ID)
+	if err != nil || len(rules.BlockedArtists) != 0 || rules.MaxDurationMs != 0 {
+		t.Fatalf("initial ContentRules = %+v, %v", rules, err)
+	}
+	if _, err := s.ContentRules(ctx, missingID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ContentRules of missing playlist = %v", err)
+	}
+
+	want := ContentRules{
+		BlockedArtists:   []string{"Band"},
+		BlockedKeywords:  []string{"explicit", "remix"},
+		MaxDurationMs:    300000,
+		AllowedProviders: []string{"youtube"},
+	}
+	if err := s.SetContentRules(ctx, pl.ID, want); err != nil {
+		t.Fatalf("SetContentRules: %v", err)
+	}
+	want.BlockedKeywords[0] = "changed by the caller"
+	rules, err = s.ContentRules(ctx, pl.ID)
+	if err != nil || len(rules.BlockedKeywords) != 2 || rules.BlockedKeywords[0] != "explicit" ||
+		rules.BlockedArtists[0] != "Band" || rules.MaxDurationMs != 300000 || rules.AllowedProviders[0] != "youtube" {
+		t.Errorf("ContentRules = %+v, %v", rules, err)
+	}
+	if err := s.SetContentRules(ctx, missingID, want); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetContentRules on missing playlist = %v", err)
+	}
+}
+
+func testStoreEditLicense(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+
+	license, err := s.EditLicense(ctx, pl.ID)
+	if err != nil || license.Lat != nil || license.OpensAt != "" {
+		t.Fatalf("initial EditLicense = %+v, %v", license, err)
+	}
+	if _, err := s.EditLicense(ctx, missingID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("EditLicense of missing playlist = %v", err)
+	}
+
+	lat, lng, radius := 48.85, 2.35, 150
+	want := EditLicense{Lat: &lat, Lng: &lng, RadiusM: &radius, OpensAt: "18:00", ClosesAt: "02:00", TimeZone: "Europe/Paris"}
+	if err := s.SetEditLicense(ctx, pl.ID, want); err != nil {
+		t.Fatalf("SetEditLicense: %v", err)
+	}
+	radius = 1
+	license, err = s.EditLicense(ctx, pl.ID)
+	if err != nil || license.Lat == nil || *license.Lat != 48.85 || license.RadiusM == nil || *license.RadiusM != 150 ||
+		license.OpensAt != "18:00" || license.ClosesAt != "02:00" || license.TimeZone != "Europe/Paris" {
+		t.Errorf("EditLicense = %+v, %v", license, err)
+	}
+
+	if err := s.SetEditLicense(ctx, pl.ID, EditLicense{}); err != nil {
+		t.Fatalf("lifting the license: %v", err)
+	}
+	if license, err := s.EditLicense(ctx, pl.ID); err != nil || license.Lat != nil || license.OpensAt != "" {
+		t.Errorf("EditLicense after lifting = %+v, %v", license, err)
+	}
+	if err := s.SetEditLicense(ctx, missingID, want); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetEditLicense on missing playlist = %v", err)
+	}
+}
+
+func testStoreSmartRules(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+
+	if rules, err := s.SmartRules(ctx, pl.ID); err != nil || rules != nil {
+		t.Fatalf("initial SmartRules = %+v, %v", rules, err)
+	}
+	if _, err := s.SmartRules(ctx, missingID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SmartRules of missing playlist = %v", err)
+	}
+
+	refreshed := time.Now()
+	want := &SmartRules{
+		Rules:        []SmartRule{{Type: smartRulePlayed, MinVotes: 5, Days: 30}, {Type: smartRuleArtists}},
+		Limit:        20,
+		RefreshHours: 24,
+		RefreshedAt:  &refreshed,
+	}
+	if err := s.SetSmartRules(ctx, pl.ID, want); err != nil {
+		t.Fatalf("SetSmartRules: %v", err)
+	}
+	want.Rules[0].MinVotes = 1
+	rules, err := s.SmartRules(ctx, pl.ID)
+	if err != nil || rules == nil || len(rules.Rules) != 2 || rules.Rules[0].MinVotes != 5 || rules.Rules[0].Days != 30 ||
+		rules.Rules[1].Type != smartRuleArtists || rules.Limit != 20 || rules.RefreshHours != 24 || rules.RefreshedAt != nil {
+		t.Errorf("SmartRules = %+v, %v", rules, err)
+	}
+
+	if err := s.SetSmartRules(ctx, pl.ID, nil); err != nil {
+		t.Fatalf("converting: %v", err)
+	}
+	if rules, err := s.SmartRules(ctx, pl.ID); err != nil || rules != nil {
+		t.Errorf("SmartRules after converting = %+v, %v", rules, err)
+	}
+	if err := s.SetSmartRules(ctx, missingID, want); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetSmartRules on missing playlist = %v", err)
+	}
+
+	// Creating a smart playlist stores its rules in the same step.
+	smart := Playlist{
+		OwnerID:         pl.OwnerID,
+		Name:            "Smart",
+		IsPublic:        true,
+		EditMode:        editModeEveryone,
+		DuplicatePolicy: duplicatePolicyAllow,
+		RadioMinQueue:   defaultRadioMinQueue,
+	}
+	if err := s.CreatePlaylist(ctx, &smart, want); err != nil {
+		t.Fatalf("CreatePlaylist with rules: %v", err)
+	}
+	rules, err = s.SmartRules(ctx, smart.ID)
+	if err != nil || rules == nil || len(rules.Rules) != 2 || rules.Rules[0].MinVotes != 1 || rules.Limit != 20 || rules.RefreshedAt != nil {
+		t.Errorf("SmartRules of created playlist = %+v, %v", rules, err)
+	}
+}
+
+func testStoreSmartRefresh(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	source := createTestPlaylist(t, s)
+	hit := addTestTrack(t, s, source.ID, "Hit", trackSourceUser)
+	addTestTrack(t, s, source.ID, "Filler", trackSourceUser)
+	if _, err := s.VoteTrack(ctx, source.ID, hit.ID, "u-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AdvancePlayback(ctx, source.ID, playEndSkipped, "", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	smart := Playlist{
+		OwnerID:         source.OwnerID,
+		Name:            "Smart",
+		IsPublic:        true,
+		EditMode:        editModeEveryone,
+		DuplicatePolicy: duplicatePolicyAllow,
+		RadioMinQueue:   defaultRadioMinQueue,
+	}
+	if err := s.CreatePlaylist(ctx, &smart, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	played, err := s.PlayedTracks(ctx, source.OwnerID, smart.ID, 30, 1, 10)
+	if err != nil || len(played) != 1 || played[0].Title != "Hit" || played[0].ProviderTrack != "vid-Hit" {
+		t.Errorf("PlayedTracks = %+v, %v", played, err)
+	}
+	if played, _ := s.PlayedTracks(ctx, source.OwnerID, source.ID, 30, 1, 10); len(played) != 0 {
+		t.Errorf("PlayedTracks of the playlist itself = %+v", played)
+	}
+
+	fan := "fan-" + newMemoryID()
+	if _, err := s.SetFollow(ctx, source.ID, fan, true); err != nil {
+		t.Fatal(err)
+	}
+	followed, err := s.FollowedTracks(ctx, fan, smart.ID, 1, 10)
+	if err != nil || len(followed) != 1 || followed[0].Title != "Hit" {
+		t.Errorf("FollowedTracks = %+v, %v", followed, err)
+	}
+
+	if _, err := s.ReplaceSmartQueue(ctx, smart.ID, 10, played, ContentRules{}); !errors.Is(err, errNotSmart) {
+		t.Errorf("ReplaceSmartQueue of a normal playlist = %v", err)
+	}
+	if _, err := s.ReplaceSmartQueue(ctx, missingID, 10, played, ContentRules{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReplaceSmartQueue of missing playlist = %v", err)
+	}
+
+	if err := s.SetSmartRules(ctx, smart.ID, &SmartRules{
+		Rules:        []SmartRule{{Type: smartRulePlayed, MinVotes: 1, Days: 30}},
+		Limit:        10,
+		RefreshHours: 1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	addTestTrack(t, s, smart.ID, "Now", trackSourceUser)
+	old1 := addTestTrack(t, s, smart.ID, "Old 1", trackSourceUser)
+	old2 := addTestTrack(t, s, smart.ID, "Old 2", trackSourceUser)
+	if _, err := s.AdvancePlayback(ctx, smart.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	candidates := []trackInput{
+		{Title: "Now", Artist: "Artist", Provider: "youtube", ProviderTrack: "vid-Now"},
+		played[0],
+		{Title: "Blocked", Artist: "Artist", Provider: "spotify", ProviderTrack: "b"},
+		{Title: "New", Artist: "Artist", Provider: "youtube", ProviderTrack: "vid-New"},
+		{Title: "Over", Artist: "Artist", Provider: "youtube", ProviderTrack: "vid-Over"},
+	}
+	update, err := s.ReplaceSmartQueue(ctx, smart.ID, 2, candidates, ContentRules{AllowedProviders: []string{"youtube"}})
+	if err != nil {
+		t.Fatalf("ReplaceSmartQueue: %v", err)
+	}
+	// Removals come last first so their positions stay valid.
+	if fmt.Sprint(update.Removed) != fmt.Sprint([]removedTrack{{old2.ID, 2}, {old1.ID, 1}}) {
+		t.Errorf("removed = %+v", update.Removed)
+	}
+	if len(update.Added) != 2 || update.Added[0].Title != "Hit" || update.Added[1].Title != "New" ||
+		update.Added[0].Source != trackSourceSmart || update.RefreshedAt.IsZero() {
+		t.Errorf("update = %+v", update)
+	}
+	if got := trackTitles(t, s, smart.ID); fmt.Sprint(got) != "[Now Hit New]" {
+		t.Errorf("tracks after refresh = %v", got)
+	}
+	if rules, _ := s.SmartRules(ctx, smart.ID); rules == nil || rules.RefreshedAt == nil {
+		t.Errorf("SmartRules after refresh = %+v", rules)
+	}
+
+	// The refresh just happened, so the playlist is not due; once it is due,
+	// only one caller claims it.
+	claimed := func() bool {
+		t.Helper()
+		ids, err := s.ClaimDueSmartPlaylists(ctx, 1000)
+		if err != nil {
+			t.Fatalf("ClaimDueSmartPlaylists: %v", err)
+		}
+		for _, id := range ids {
+			if id == smart.ID {
+				return true
+			}
+		}
+		return false
+	}
+	if claimed() {
+		t.Errorf("claimed a freshly refreshed playlist")
+	}
+	// Saving the rules again resets the refresh time.
+	if err := s.SetSmartRules(ctx, smart.ID, &SmartRules{
+		Rules:        []SmartRule{{Type: smartRulePlayed, MinVotes: 1, Days: 30}},
+		Limit:        10,
+		RefreshHours: 1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !claimed() {
+		t.Errorf("due playlist was not claimed")
+	}
+	if claimed() {
+		t.Errorf("playlist was claimed twice")
+	}
+}
+
+func testStoreSuggestions(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+
+	var ids []string
+	for _, by := range []string{"u-1", "u-2", "u-1"} {
+		sg := TrackSuggestion{PlaylistID: pl.ID, SuggestedBy: by, Title: "Song by " + by, Artist: "Artist", DurationMs: 1000}
+		if err := s.AddSuggestion(ctx, &sg); err != nil || sg.ID == "" || sg.Status != suggestionPending || sg.CreatedAt.IsZero() {
+			t.Fatalf("AddSuggestion = %+v, %v", sg, err)
+		}
+		ids = append(ids, sg.ID)
+	}
+	missing := TrackSuggestion{PlaylistID: missingID, SuggestedBy: "u-1", Title: "Song"}
+	if err := s.AddSuggestion(ctx, &missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AddSuggestion to missing playlist = %v", err)
+	}
+
+	mine, err := s.ListSuggestions(ctx, pl.ID, suggestionPending, "u-1")
+	if err != nil || len(mine) != 2 || mine[0].ID != ids[0] || mine[1].ID != ids[2] {
+		t.Fatalf("ListSuggestions of u-1 = %+v, %v", mine, err)
+	}
+
+	sg, tr, err := s.ApproveSuggestion(ctx, pl.ID, ids[0], pl.OwnerID)
+	if err != nil || sg.Status != suggestionApproved || sg.DecidedBy != pl.OwnerID || sg.DecidedAt == nil || sg.TrackID != tr.ID {
+		t.Fatalf("ApproveSuggestion = %+v, %v", sg, err)
+	}
+	if tr.Title != "Song by u-1" || tr.AddedBy != "u-1" || tr.Source != trackSourceUser || tr.Status != "queued" || tr.DurationMs != 1000 {
+		t.Errorf("approved track = %+v", tr)
+	}
+	if tracks, _ := s.ListTracks(ctx, pl.ID, ""); len(tracks) != 1 || tracks[0].ID != tr.ID {
+		t.Errorf("tracks after approval = %+v", tracks)
+	}
+	if _, _, err := s.ApproveSuggestion(ctx, pl.ID, ids[0], pl.OwnerID); !errors.Is(err, errSuggestionDecided) {
+		t.Errorf("second ApproveSuggestion = %v", err)
+	}
+
+	sg, err = s.RejectSuggestion(ctx, pl.ID, ids[1], pl.OwnerID, "not tonight")
+	if err != nil || sg.Status != suggestionRejected || sg.Reason != "not tonight" || sg.DecidedAt == nil {
+		t.Fatalf("RejectSuggestion = %+v, %v", sg, err)
+	}
+	if _, err := s.RejectSuggestion(ctx, pl.ID, ids[1], pl.OwnerID, ""); !errors.Is(err, errSuggestionDecided) {
+		t.Errorf("second RejectSuggestion = %v", err)
+	}
+	if _, err := s.RejectSuggestion(ctx, pl.ID, missingID, pl.OwnerID, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RejectSuggestion of missing suggestion = %v", err)
+	}
+
+	got, err := s.GetSuggestion(ctx, pl.ID, ids[1])
+	if err != nil || got.Status != suggestionRejected || got.SuggestedBy != "u-2" {
+		t.Errorf("GetSuggestion = %+v, %v", got, err)
+	}
+	all, err := s.ListSuggestions(ctx, pl.ID, "", "")
+	if err != nil || len(all) != 3 {
+		t.Errorf("ListSuggestions = %+v, %v", all, err)
+	}
+	if pending, _ := s.ListSuggestions(ctx, pl.ID, suggestionPending, ""); len(pending) != 1 || pending[0].ID != ids[2] {
+		t.Errorf("pending suggestions = %+v", pending)
+	}
+}
+
+func testStoreSkipVotes(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	long, err := s.AddTrack(ctx, pl.ID, trackInput{Title: "Long", DurationMs: int(time.Hour / time.Millisecond)}, trackSourceUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := addTestTrack(t, s, pl.ID, "Next", trackSourceUser)
+
+	if _, err := s.UpdatePlaylist(ctx, pl.ID, func(p *Playlist) error {
+		p.SkipVotePercent = 30
+		p.SkipVoteThreshold = 5
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetPlaylist(ctx, pl.ID); got.SkipVotePercent != 30 || got.SkipVoteThreshold != 5 {
+		t.Errorf("skip settings = %d, %d", got.SkipVotePercent, got.SkipVoteThreshold)
+	}
+
+	now := time.Now().Truncate(time.Millisecond)
+	for _, u := range []string{"u-1", "u-2"} {
+		if err := s.TouchPresence(ctx, pl.ID, u, now.Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.TouchPresence(ctx, pl.ID, "u-1", now); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.CountPresent(ctx, pl.ID, now.Add(-time.Second)); err != nil || n != 1 {
+		t.Errorf("CountPresent recent = %d, %v", n, err)
+	}
+	if n, err := s.CountPresent(ctx, pl.ID, now.Add(-2*time.Minute)); err != nil || n != 2 {
+		t.Errorf("CountPresent all = %d, %v", n, err)
+	}
+	if err := s.TouchPresence(ctx, missingID, "u-1", now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("TouchPresence of missing playlist = %v", err)
+	}
+
+	if _, err := s.AddSkipVote(ctx, pl.ID, long.ID, "u-1"); !errors.Is(err, errPlaybackChanged) {
+		t.Errorf("skip vote before anything plays = %v", err)
+	}
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddSkipVote(ctx, pl.ID, next.ID, "u-1"); !errors.Is(err, errPlaybackChanged) {
+		t.Errorf("skip vote for a queued track = %v", err)
+	}
+	for i, u := range []string{"u-1", "u-2"} {
+		if votes, err := s.AddSkipVote(ctx, pl.ID, long.ID, u); err != nil || votes != i+1 {
+			t.Errorf("AddSkipVote(%s) = %d, %v", u, votes, err)
+		}
+	}
+	if _, err := s.AddSkipVote(ctx, pl.ID, long.ID, "u-1"); !errors.Is(err, errAlreadyVoted) {
+		t.Errorf("second skip vote = %v", err)
+	}
+	if votes, voted, err := s.SkipVotes(ctx, pl.ID, long.ID, "u-2"); err != nil || votes != 2 || !voted {
+		t.Errorf("SkipVotes = %d, %v, %v", votes, voted, err)
+	}
+	if votes, voted, err := s.SkipVotes(ctx, pl.ID, long.ID, "u-3"); err != nil || votes != 2 || voted {
+		t.Errorf("SkipVotes of a non-voter = %d, %v, %v", votes, voted, err)
+	}
+
+	// Skipping an expected track does not wait for its end.
+	state, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, long.ID, now)
+	if err != nil || state.Current == nil || state.Current.ID != next.ID {
+		t.Fatalf("skip of the expected track = %+v, %v", state, err)
+	}
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, long.ID, now); !errors.Is(err, errPlaybackChanged) {
+		t.Errorf("second skip of the expected track = %v", err)
+	}
+	if votes, _, err := s.SkipVotes(ctx, pl.ID, next.ID, "u-1"); err != nil || votes != 0 {
+		t.Errorf("SkipVotes of the next track = %d, %v", votes, err)
+	}
+}
+
+func testStorePlayer(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	one, err := s.AddTrack(ctx, pl.ID, trackInput{Title: "One", DurationMs: int(time.Hour / time.Millisecond)}, trackSourceUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	two := addTestTrack(t, s, pl.ID, "Two", trackSourceUser)
+
+	if player, err := s.Player(ctx, pl.ID); err != nil || player != nil {
+		t.Errorf("Player before attaching = %+v, %v", player, err)
+	}
+	if _, err := s.Player(ctx, missingID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Player of missing playlist = %v", err)
+	}
+	attach := func(cur *PlayerState) (*PlayerState, error) {
+		return &PlayerState{PlayerID: "pc-1", UserID: "owner-1", State: playerPlaying, SeenAt: time.Now()}, nil
+	}
+	if _, err := s.UpdatePlayer(ctx, missingID, attach); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdatePlayer of missing playlist = %v", err)
+	}
+
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdatePlayer(ctx, pl.ID, attach); err != nil {
+		t.Fatal(err)
+	}
+	report := func(state string) {
+		t.Helper()
+		_, err := s.UpdatePlayer(ctx, pl.ID, func(cur *PlayerState) (*PlayerState, error) {
+			if cur == nil || cur.PlayerID != "pc-1" {
+				t.Errorf("current player = %+v", cur)
+			}
+			next := *cur
+			next.TrackID, next.PositionMs, next.State, next.SeenAt = one.ID, 1000, state, time.Now()
+			return &next, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	report(playerPlaying)
+	if player, err := s.Player(ctx, pl.ID); err != nil || player == nil || player.TrackID != one.ID || player.PositionMs != 1000 || player.State != playerPlaying {
+		t.Errorf("Player = %+v, %v", player, err)
+	}
+
+	// The player decides when the track ends.
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndFinished, one.ID, time.Now()); !errors.Is(err, errPlaybackChanged) {
+		t.Errorf("finish while the player plays = %v", err)
+	}
+	report(playerEnded)
+	state, err := s.AdvancePlayback(ctx, pl.ID, playEndFinished, one.ID, time.Now())
+	if err != nil || state.Current == nil || state.Current.ID != two.ID {
+		t.Fatalf("finish after the player ended = %+v, %v", state, err)
+	}
+
+	if _, err := s.UpdatePlayer(ctx, pl.ID, func(*PlayerState) (*PlayerState, error) { return nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if player, err := s.Player(ctx, pl.ID); err != nil || player != nil {
+		t.Errorf("Player after detaching = %+v, %v", player, err)
+	}
+}
+
+func testStoreDevices(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	userID := "user-" + newMemoryID()
+
+	pc := Device{UserID: userID, Name: "Bar PC", Platform: "desktop", Capabilities: []string{capabilityPlayback}}
+	phone := Device{UserID: userID, Name: "Phone", Platform: "ios", Capabilities: []string{}}
+	for _, d := range []*Device{&pc, &phone} {
+		if err := s.RegisterDevice(ctx, d); err != nil || d.ID == "" || d.LastSeenAt.IsZero() {
+			t.Fatalf("RegisterDevice = %v, %+v", err, d)
+		}
+	}
+	if got, err := s.Device(ctx, pc.ID); err != nil || got.Name != "Bar PC" || len(got.Capabilities) != 1 {
+		t.Errorf("Device = %+v, %v", got, err)
+	}
+	if _, err := s.Device(ctx, missingID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Device of missing ID = %v", err)
+	}
+
+	seen := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	got, err := s.UpdateDevice(ctx, userID, phone.ID, func(d *Device) error {
+		d.Name = "iPhone"
+		d.Capabilities = []string{capabilityRemote}
+		d.LastSeenAt = seen
+		return nil
+	})
+	if err != nil || got.Name != "iPhone" {
+		t.Fatalf("UpdateDevice = %+v, %v", got, err)
+	}
+	if _, err := s.UpdateDevice(ctx, "someone-else", phone.ID, func(*Device) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateDevice of another user's device = %v", err)
+	}
+	devices, err := s.ListDevices(ctx, userID)
+	if err != nil || len(devices) != 2 || devices[0].ID != phone.ID || !devices[0].LastSeenAt.Equal(seen) || devices[0].Capabilities[0] != capabilityRemote {
+		t.Errorf("ListDevices = %+v, %v", devices, err)
+	}
+
+	if id, err := s.ActiveDevice(ctx, pl.ID); err != nil || id != "" {
+		t.Errorf("ActiveDevice before selecting = %q, %v", id, err)
+	}
+	if _, err := s.ActiveDevice(ctx, missingID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ActiveDevice of missing playlist = %v", err)
+	}
+	if err := s.SetActiveDevice(ctx, pl.ID, missingID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetActiveDevice of missing device = %v", err)
+	}
+	if err := s.SetActiveDevice(ctx, pl.ID, pc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := s.ActiveDevice(ctx, pl.ID); id != pc.ID {
+		t.Errorf("ActiveDevice = %q", id)
+	}
+
+	if err := s.DeleteDevice(ctx, "someone-else", pc.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteDevice of another user's device = %v", err)
+	}
+	if err := s.DeleteDevice(ctx, userID, pc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := s.ActiveDevice(ctx, pl.ID); id != "" {
+		t.Errorf("ActiveDevice after deleting it = %q", id)
+	}
+	if err := s.SetActiveDevice(ctx, pl.ID, ""); err != nil {
+		t.Errorf("clearing the active device = %v", err)
+	}
+}
+
+func testStoreDelegations(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	for _, d := range []PlaybackDelegation{
+		{PlaylistID: pl.ID, UserID: "u-1", GrantedBy: pl.OwnerID},
+		{PlaylistID: pl.ID, UserID: "u-2", GrantedBy: pl.OwnerID, ExpiresAt: &future},
+		{PlaylistID: pl.ID, UserID: "u-3", GrantedBy: pl.OwnerID, ExpiresAt: &past},
+	} {
+		if err := s.SetDelegation(ctx, &d); err != nil || d.CreatedAt.IsZero() {
+			t.Fatalf("SetDelegation(%s) = %v, createdAt %v", d.UserID, err, d.CreatedAt)
+		}
+	}
+
+	delegations, err := s.ListDelegations(ctx, pl.ID, now)
+	if err != nil || len(delegations) != 2 {
+		t.Fatalf("ListDelegations = %+v, %v", delegations, err)
+	}
+	for _, d := range delegations {
+		if d.UserID == "u-2" && (d.ExpiresAt == nil || d.ExpiresAt.Sub(future).Abs() > time.Millisecond) {
+			t.Errorf("expiry of u-2 = %v", d.ExpiresAt)
+		}
+	}
+	for user, want := range map[string]bool{"u-1": true, "u-2": true, "u-3": false, "u-4": false} {
+		if ok, err := s.HasDelegation(ctx, pl.ID, user, now); err != nil || ok != want {
+			t.Errorf("HasDelegation(%s) = %v, %v", user, ok, err)
+		}
+	}
+	if ok, _ := s.HasDelegation(ctx, pl.ID, "u-2", future.Add(time.Second)); ok {
+		t.Errorf("expected the delegation of u-2 to expire")
+	}
+
+	// Renewing replaces the expiry.
+	if err := s.SetDelegation(ctx, &PlaybackDelegation{PlaylistID: pl.ID, UserID: "u-3", GrantedBy: pl.OwnerID}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.HasDelegation(ctx, pl.ID, "u-3", now); !ok {
+		t.Errorf("expected the renewed delegation of u-3")
+	}
+
+	if err := s.RemoveDelegation(ctx, pl.ID, "u-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveDelegation(ctx, pl.ID, "u-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second RemoveDelegation = %v", err)
+	}
+	if err := s.BanUser(ctx, &PlaylistBan{PlaylistID: pl.ID, UserID: "u-2", BannedBy: pl.OwnerID}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.HasDelegation(ctx, pl.ID, "u-2", now); ok {
+		t.Errorf("expected a ban to revoke the delegation")
+	}
+}
+
+func testStorePlayback(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	a := addTestTrack(t, s, pl.ID, "A", trackSourceUser)
+	b := addTestTrack(t, s, pl.ID, "B", trackSourceUser)
+
+	now := time.Now().Truncate(time.Millisecond)
+	state, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", now)
+	if err != nil || state.Current == nil || state.Current.ID != a.ID || !state.StartedAt.Equal(now) {
+		t.Fatalf("first AdvancePlayback = %+v, %v", state, err)
+	}
+	if state.Current.Title != "A" || state.Current.Artist != "Artist" {
+		t.Errorf("current track = %+v", state.Current)
+	}
+	got, _ := s.GetPlaylist(ctx, pl.ID)
+	if got.CurrentTrackID == nil || *got.CurrentTrackID != a.ID || got.PlayingStartedAt == nil || !got.PlayingStartedAt.Equal(now) {
+		t.Errorf("playlist after start = %+v", got)
+	}
+
+	state, err = s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", now)
+	if err != nil || state.Current == nil || state.Current.ID != b.ID {
+		t.Fatalf("second AdvancePlayback = %+v, %v", state, err)
+	}
+	if tr, _ := s.GetTrack(ctx, pl.ID, a.ID); tr.Status != "played" {
+		t.Errorf("previous track status = %q", tr.Status)
+	}
+	if tr, _ := s.GetTrack(ctx, pl.ID, b.ID); tr.Status != "playing" {
+		t.Errorf("current track status = %q", tr.Status)
+	}
+
+	// Votes for the playing track do not move it.
+	if _, err := s.VoteTrack(ctx, pl.ID, b.ID, "u-1"); err != nil {
+		t.Fatal(err)
+	}
+	assertTitles(t, s, pl.ID, "A", "B")
+
+	state, err = s.AdvancePlayback(ctx, pl.ID, playEndFinished, "", now)
+	if err != nil || state.Current != nil {
+		t.Fatalf("AdvancePlayback at the end = %+v, %v", state, err)
+	}
+	if got, _ := s.GetPlaylist(ctx, pl.ID); got.CurrentTrackID != nil {
+		t.Errorf("playlist still has a current track: %v", *got.CurrentTrackID)
+	}
+}
+
+func testStoreScheduledPlayback(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	long, err := s.AddTrack(ctx, pl.ID, trackInput{Title: "Long", DurationMs: int(time.Hour / time.Millisecond)}, trackSourceUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	short := addTestTrack(t, s, pl.ID, "Short", trackSourceUser)
+
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndFinished, long.ID, time.Now()); !errors.Is(err, errPlaybackChanged) {
+		t.Errorf("advance before anything plays = %v", err)
+	}
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndFinished, long.ID, time.Now()); !errors.Is(err, errPlaybackChanged) {
+		t.Errorf("advance before the track ended = %v", err)
+	}
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndFinished, short.ID, time.Now()); !errors.Is(err, errPlaybackChanged) {
+		t.Errorf("advance of a track that is not playing = %v", err)
+	}
+
+	// A track without a duration is due right away.
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	state, err := s.AdvancePlayback(ctx, pl.ID, playEndFinished, short.ID, time.Now())
+	if err != nil || state.Current != nil {
+		t.Errorf("advance of a finished track = %+v, %v", state, err)
+	}
+}
+
+func testStorePlayingTracks(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	if _, err := s.AddTrack(ctx, pl.ID, trackInput{Title: "Minute", DurationMs: 60000}, trackSourceUser); err != nil {
+		t.Fatal(err)
+	}
+	addTestTrack(t, s, pl.ID, "No Duration", trackSourceUser)
+
+	if playing, err := s.PlayingTracks(ctx, pl.ID, time.Minute); err != nil || len(playing) != 0 {
+		t.Errorf("PlayingTracks before playback = %+v, %v", playing, err)
+	}
+
+	now := time.Now().Truncate(time.Millisecond)
+	state, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	playing, err := s.PlayingTracks(ctx, pl.ID, time.Minute)
+	if err != nil || len(playing) != 1 || playing[0].PlaylistID != pl.ID || playing[0].TrackID != state.Current.ID ||
+		!playing[0].EndsAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("PlayingTracks = %+v, %v", playing, err)
+	}
+	if all, err := s.PlayingTracks(ctx, "", time.Minute); err != nil || !containsPlaying(all, pl.ID) {
+		t.Errorf("PlayingTracks of all playlists = %+v, %v", all, err)
+	}
+
+	// A live player holds the track until its heartbeat times out.
+	if _, err := s.UpdatePlayer(ctx, pl.ID, func(cur *PlayerState) (*PlayerState, error) {
+		return &PlayerState{PlayerID: "pc-1", UserID: pl.OwnerID, TrackID: state.Current.ID, State: playerPlaying, SeenAt: time.Now()}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	playing, err = s.PlayingTracks(ctx, pl.ID, time.Hour)
+	if err != nil || len(playing) != 1 || playing[0].EndsAt.Before(now.Add(59*time.Minute)) {
+		t.Errorf("PlayingTracks with a live player = %+v, %v", playing, err)
+	}
+
+	// Tracks without a duration are never scheduled.
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", now); err != nil {
+		t.Fatal(err)
+	}
+	if playing, err := s.PlayingTracks(ctx, pl.ID, time.Minute); err != nil || len(playing) != 0 {
+		t.Errorf("PlayingTracks of a track without duration = %+v, %v", playing, err)
+	}
+}
+
+func containsPlaying(playing []playingTrack, playlistID string) bool {
+	for _, pt := range playing {
+		if pt.PlaylistID == playlistID {
+			return true
+		}
+	}
+	return false
+}
+
+func testStorePlayHistory(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	a := addTestTrack(t, s, pl.ID, "A", trackSourceUser)
+	b := addTestTrack(t, s, pl.ID, "B", trackSourceUser)
+	c := addTestTrack(t, s, pl.ID, "C", trackSourceUser)
+	if _, err := s.VoteTrack(ctx, pl.ID, b.ID, "u-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries, err := s.ListPlayHistory(ctx, pl.ID, nil, 10); err != nil || len(entries) != 0 {
+		t.Errorf("ListPlayHistory before playback = %+v, %v", entries, err)
+	}
+
+	start := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	for i, reason := range []string{playEndSkipped, playEndSkipped, playEndFinished} {
+		if _, err := s.AdvancePlayback(ctx, pl.ID, reason, "", start.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// B had a vote, so it played first and was skipped; A finished and C
+	// is playing now and gets removed.
+	if _, err := s.DeleteTrack(ctx, pl.ID, c.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.ListPlayHistory(ctx, pl.ID, nil, 10)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("ListPlayHistory = %+v, %v", entries, err)
+	}
+	want := []struct {
+		track  Track
+		reason string
+		votes  int
+	}{{c, playEndRemoved, 0}, {a, playEndFinished, 0}, {b, playEndSkipped, 1}}
+	for i, w := range want {
+		e := entries[i]
+		if e.TrackID == nil || *e.TrackID != w.track.ID || e.Title != w.track.Title || e.Artist != "Artist" ||
+			e.Provider != "youtube" || e.VoteCount != w.votes || e.EndReason == nil || *e.EndReason != w.reason ||
+			e.EndedAt == nil || e.PlaylistID != pl.ID {
+			t.Errorf("entry %d = %+v, want %s ended %s", i, e, w.track.Title, w.reason)
+		}
+	}
+	if !entries[2].StartedAt.Equal(start) || !entries[2].EndedAt.Equal(start.Add(time.Second)) {
+		t.Errorf("first entry ran %v - %v", entries[2].StartedAt, *entries[2].EndedAt)
+	}
+
+	before := entries[1].StartedAt
+	if page, err := s.ListPlayHistory(ctx, pl.ID, &before, 1); err != nil || len(page) != 1 || page[0].ID != entries[2].ID {
+		t.Errorf("ListPlayHistory before %v = %+v, %v", before, page, err)
+	}
+
+	stats, err := s.PlayStats(ctx, pl.ID, nil, nil, 5)
+	if err != nil || stats.TotalPlays != 3 || stats.Finished != 1 || stats.Skipped != 1 || stats.TotalPlayMs < 2000 ||
+		len(stats.TopArtists) != 1 || stats.TopArtists[0] != (ArtistPlayStat{Artist: "Artist", Plays: 3}) {
+		t.Errorf("PlayStats = %+v, %v", stats, err)
+	}
+	if stats.AverageVotes < 0.33 || stats.AverageVotes > 0.34 {
+		t.Errorf("AverageVotes = %v", stats.AverageVotes)
+	}
+	since := start.Add(time.Second)
+	if stats, err := s.PlayStats(ctx, pl.ID, &since, nil, 5); err != nil || stats.TotalPlays != 2 {
+		t.Errorf("PlayStats since %v = %+v, %v", since, stats, err)
+	}
+	if stats, err := s.PlayStats(ctx, pl.ID, nil, &start, 5); err != nil || stats.TotalPlays != 0 || stats.TopArtists == nil {
+		t.Errorf("PlayStats until %v = %+v, %v", start, stats, err)
+	}
+
+	// Trashing the playlist ends the current playback.
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	d := addTestTrack(t, s, pl.ID, "D", trackSourceUser)
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeletePlaylist(ctx, pl.ID, func(Playlist) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ = s.ListPlayHistory(ctx, pl.ID, nil, 1)
+	if len(entries) != 1 || *entries[0].TrackID != d.ID || entries[0].EndReason == nil || *entries[0].EndReason != playEndDeleted {
+		t.Errorf("history after trashing = %+v", entries)
+	}
+}
+
+func testStoreListing(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	owner := "owner-" + newMemoryID()
+	create := func(name, description string, public bool, tracks int) Playlist {
+		t.Helper()
+		pl := Playlist{OwnerID: owner, Name: name, Description: description, IsPublic: public, EditMode: editModeEveryone}
+		if err := s.CreatePlaylist(ctx, &pl, nil); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < tracks; i++ {
+			addTestTrack(t, s, pl.ID, fmt.Sprintf("%s %d", name, i), trackSourceUser)
+		}
+		return pl
+	}
+	one := create("One", "quiet evening", true, 1)
+	three := create("Three", "sunset drive", false, 3)
+	two := create("Two", "Sunset, beach", true, 2)
+
+	list := func(q listPlaylistsQuery, userID string) []string {
+		t.Helper()
+		if q.Sort == "" {
+			q.Sort = listSortRecent
+		}
+		if q.Limit == 0 {
+			q.Limit = 10
+		}
+		playlists, err := s.ListPlaylists(ctx, q, userID)
+		if err != nil {
+			t.Fatalf("ListPlaylists(%+v): %v", q, err)
+		}
+		var names []string
+		for _, pl := range playlists {
+			if pl.OwnerID == owner {
+				names = append(names, pl.Name)
+			}
+		}
+		return names
+	}
+
+	if got := list(listPlaylistsQuery{Filter: listFilterOwned}, owner); fmt.Sprint(got) != "[Two Three One]" {
+		t.Errorf("owned playlists = %v", got)
+	}
+	if got := list(listPlaylistsQuery{Filter: listFilterOwned, Sort: listSortTracks}, owner); fmt.Sprint(got) != "[Three Two One]" {
+		t.Errorf("owned playlists by tracks = %v", got)
+	}
+	if got := list(listPlaylistsQuery{Filter: listFilterOwned, Search: "sunset"}, owner); fmt.Sprint(got) != "[Two Three]" {
+		t.Errorf("search = %v", got)
+	}
+	if got := list(listPlaylistsQuery{Filter: listFilterOwned, Search: "sunset beach"}, owner); fmt.Sprint(got) != "[Two]" {
+		t.Errorf("search of two words = %v", got)
+	}
+
+	// Private playlists are listed for their owner, members and nobody else.
+	if got := list(listPlaylistsQuery{}, "stranger"); fmt.Sprint(got) != "[Two One]" {
+		t.Errorf("playlists of a stranger = %v", got)
+	}
+	if err := s.AddMember(ctx, three.ID, "member-1", memberRoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(listPlaylistsQuery{Filter: listFilterMember}, "member-1"); fmt.Sprint(got) != "[Three]" {
+		t.Errorf("member playlists = %v", got)
+	}
+	if got := list(listPlaylistsQuery{Filter: listFilterPublic}, "member-1"); fmt.Sprint(got) != "[Two One]" {
+		t.Errorf("public playlists = %v", got)
+	}
+	if _, err := s.UpdatePlaylist(ctx, one.ID, func(pl *Playlist) error {
+		pl.Visibility = visibilityFriends
+		pl.EditMode = editModeInvited
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(listPlaylistsQuery{FriendIDs: []string{owner}}, "friend-1"); fmt.Sprint(got) != "[Two One]" {
+		t.Errorf("playlists of a friend = %v", got)
+	}
+	if got := list(listPlaylistsQuery{}, "stranger"); fmt.Sprint(got) != "[Two]" {
+		t.Errorf("playlists of a stranger after hiding one = %v", got)
+	}
+	if got := list(listPlaylistsQuery{Filter: listFilterOwned, EditMode: editModeInvited}, owner); fmt.Sprint(got) != "[One]" {
+		t.Errorf("playlists by edit mode = %v", got)
+	}
+
+	// Counts and the last activity come with every row.
+	playlists, err := s.ListPlaylists(ctx, listPlaylistsQuery{Filter: listFilterOwned, Sort: listSortActive, Limit: 10}, owner)
+	if err != nil || len(playlists) != 3 {
+		t.Fatalf("ListPlaylists by activity = %+v, %v", playlists, err)
+	}
+	if playlists[0].ID != two.ID || playlists[0].TrackCount != 2 || playlists[0].LastActivityAt == nil ||
+		playlists[0].LastActivityAt.Before(two.CreatedAt) || playlists[0].Visibility != visibilityPublic {
+		t.Errorf("first playlist by activity = %+v", playlists[0])
+	}
+
+	// Pages return one row more than asked for and continue after the cursor.
+	q := listPlaylistsQuery{Filter: listFilterOwned, Sort: listSortTracks, Limit: 1}
+	var pages []string
+	for i := 0; i < 4; i++ {
+		page, err := s.ListPlaylists(ctx, q, owner)
+		if err != nil || len(page) == 0 {
+			t.Fatalf("page %d = %+v, %v", i, page, err)
+		}
+		pages = append(pages, page[0].Name)
+		if len(page) == 1 {
+			break
+		}
+		c := cursorFor(q.Sort, page[0])
+		q.Cursor = &c
+	}
+	if fmt.Sprint(pages) != "[Three Two One]" {
+		t.Errorf("pages = %v", pages)
+	}
+}
+
+func testStoreTrackBatch(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	a := addTestTrack(t, s, pl.ID, "A", trackSourceUser)
+	b := addTestTrack(t, s, pl.ID, "B", trackSourceUser)
+	if _, err := s.UpdatePlaylist(ctx, pl.ID, func(p *Playlist) error {
+		p.DuplicatePolicy = duplicatePolicyMergeVote
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	newPos := 0
+	ops := []batchOperation{
+		{Op: batchOpAdd, Track: &trackInput{Title: "C", Artist: "Artist", Provider: "youtube", ProviderTrack: "vid-C"}},
+		{Op: batchOpMove, TrackID: b.ID, NewPosition: &newPos},
+		{Op: batchOpRemove, TrackID: a.ID},
+		{Op: batchOpAdd, Track: &trackInput{Title: "B again", Provider: "youtube", ProviderTrack: b.ProviderTrackID}},
+	}
+	results := make([]batchResult, len(ops))
+	if err := s.ApplyTrackBatch(ctx, pl.ID, "u-1", ops, nil, results); err != nil {
+		t.Fatalf("ApplyTrackBatch: %v", err)
+	}
+	if results[0].Status != "ok" || results[0].Track == nil || results[0].Track.Title != "C" {
+		t.Errorf("add result = %+v", results[0])
+	}
+	if results[1].Status != "ok" || *results[1].From != 1 || *results[1].To != 0 {
+		t.Errorf("move result = %+v", results[1])
+	}
+	if results[2].Status != "ok" || results[3].Status != "merged" || results[3].TrackID != b.ID || results[3].Track.VoteCount != 1 {
+		t.Errorf("results = %+v", results)
+	}
+	assertTitles(t, s, pl.ID, "B", "C")
+
+	// A failing operation rolls back the ones before it.
+	c := results[0].TrackID
+	ops = []batchOperation{
+		{Op: batchOpRemove, TrackID: c},
+		{Op: batchOpAdd, Track: &trackInput{Title: "D", Provider: "youtube", ProviderTrack: "vid-D"}},
+		{Op: batchOpAdd, Track: &trackInput{Title: "B once more", Provider: "youtube", ProviderTrack: b.ProviderTrackID}},
+	}
+	results = make([]batchResult, len(ops))
+	err := s.ApplyTrackBatch(ctx, pl.ID, "u-1", ops, nil, results)
+	var opErr *batchOpError
+	if !errors.As(err, &opErr) || opErr.index != 2 || opErr.err.status != http.StatusConflict {
+		t.Fatalf("ApplyTrackBatch with a second vote = %v", err)
+	}
+	if results[0].Status != "skipped" || results[1].Status != "skipped" || results[1].Track != nil || results[2].Status != "failed" {
+		t.Errorf("results of the failed batch = %+v", results)
+	}
+	assertTitles(t, s, pl.ID, "B", "C")
+	if tr, _ := s.GetTrack(ctx, pl.ID, b.ID); tr.VoteCount != 1 {
+		t.Errorf("votes after rollback = %d", tr.VoteCount)
+	}
+
+	// Merges are denied while voting is locked for the user.
+	locked := &httpError{status: http.StatusLocked, msg: lockedMessages[lockVoting]}
+	ops = []batchOperation{{Op: batchOpAdd, Track: &trackInput{Title: "B again", Provider: "youtube", ProviderTrack: b.ProviderTrackID}}}
+	results = make([]batchResult, len(ops))
+	err = s.ApplyTrackBatch(ctx, pl.ID, "u-2", ops, locked, results)
+	if !errors.As(err, &opErr) || opErr.err != locked || results[0].Status != "failed" {
+		t.Errorf("ApplyTrackBatch merging while voting is locked = %v, %+v", err, results)
+	}
+	if tr, _ := s.GetTrack(ctx, pl.ID, b.ID); tr.VoteCount != 1 {
+		t.Errorf("votes after a denied merge = %d", tr.VoteCount)
+	}
+
+	results = make([]batchResult, 1)
+	err = s.ApplyTrackBatch(ctx, pl.ID, "u-1", []batchOperation{{Op: batchOpRemove, TrackID: missingID}}, nil, results)
+	if !errors.As(err, &opErr) || opErr.err.status != http.StatusNotFound || results[0].Status != "failed" {
+		t.Errorf("ApplyTrackBatch removing a missing track = %v, %+v", err, results)
+	}
+}
+
+func testStoreTrackLookups(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	found := addTestTrack(t, s, pl.ID, "Found", trackSourceUser)
+	gone := addTestTrack(t, s, pl.ID, "Gone", trackSourceUser)
+	if _, err := s.AddTrack(ctx, pl.ID, trackInput{Title: "Resolved", Provider: "youtube", ProviderTrack: "vid-R", resolved: true}, trackSourceUser); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddTrack(ctx, pl.ID, trackInput{Title: "Manual"}, trackSourceUser); err != nil {
+		t.Fatal(err)
+	}
+
+	claim := func(claimFor time.Duration) []string {
+		t.Helper()
+		pending, err := s.ClaimUnresolvedTracks(ctx, 1000, claimFor)
+		if err != nil {
+			t.Fatalf("ClaimUnresolvedTracks: %v", err)
+		}
+		var ids []string
+		for _, u := range pending {
+			if u.PlaylistID == pl.ID {
+				if u.Provider != "youtube" || u.ProviderTrack == "" {
+					t.Errorf("unresolved track = %+v", u)
+				}
+				ids = append(ids, u.ID)
+			}
+		}
+		return ids
+	}
+	if got := claim(time.Hour); fmt.Sprint(got) != fmt.Sprint([]string{gone.ID, found.ID}) {
+		t.Errorf("claimed = %v, want Gone and Found", got)
+	}
+	// Claimed tracks are skipped until the claim expires.
+	if got := claim(time.Hour); len(got) != 0 {
+		t.Errorf("claimed twice = %v", got)
+	}
+	if got := claim(0); len(got) != 2 {
+		t.Errorf("claimed after expiry = %v", got)
+	}
+
+	tr, err := s.MarkTrackResolved(ctx, pl.ID, found.ID, &trackInput{Title: "Found (Remastered)", ThumbnailURL: "https://img/1", DurationMs: 1000})
+	if err != nil || tr.Title != "Found (Remastered)" || tr.Artist != "Artist" || tr.ThumbnailURL != "https://img/1" ||
+		tr.DurationMs != 1000 || tr.Unavailable {
+		t.Errorf("MarkTrackResolved = %+v, %v", tr, err)
+	}
+	tr, err = s.MarkTrackResolved(ctx, pl.ID, gone.ID, nil)
+	if err != nil || !tr.Unavailable || tr.Title != "Gone" {
+		t.Errorf("MarkTrackResolved unavailable = %+v, %v", tr, err)
+	}
+	if got := claim(0); len(got) != 0 {
+		t.Errorf("claimed after resolving = %v", got)
+	}
+	if _, err := s.MarkTrackResolved(ctx, pl.ID, missingID, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MarkTrackResolved of a missing track = %v", err)
+	}
+}
+
+func testStoreRadio(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	addTestTrack(t, s, pl.ID, "Seed", trackSourceUser)
+
+	if rs, err := s.RadioSettings(ctx, pl.ID); err != nil || rs.Enabled || rs.OwnerID != pl.OwnerID ||
+		rs.MinQueue != defaultRadioMinQueue || rs.Queued != 1 {
+		t.Errorf("RadioSettings = %+v, %v", rs, err)
+	}
+	if _, err := s.UpdatePlaylist(ctx, pl.ID, func(p *Playlist) error {
+		p.RadioEnabled = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rules := ContentRules{BlockedArtists: []string{"Blocked"}}
+	if err := s.SetContentRules(ctx, pl.ID, rules); err != nil {
+		t.Fatal(err)
+	}
+	rs, err := s.RadioSettings(ctx, pl.ID)
+	if err != nil || !rs.Enabled || len(rs.Rules.BlockedArtists) != 1 {
+		t.Errorf("RadioSettings after enabling = %+v, %v", rs, err)
+	}
+	if _, err := s.RadioSettings(ctx, missingID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RadioSettings of a missing playlist = %v", err)
+	}
+
+	candidates := []trackInput{
+		{Title: "Seed", Artist: "Artist", Provider: "youtube", ProviderTrack: "other-id"},
+		{Title: "Banned", Artist: "Blocked", Provider: "youtube", ProviderTrack: "vid-banned"},
+		{Title: "No Provider", Artist: "Someone"},
+		{Title: "Fresh", Artist: "Someone", Provider: "youtube", ProviderTrack: "vid-fresh"},
+		{Title: "Fresh", Artist: "Someone", Provider: "youtube", ProviderTrack: "vid-fresh-2"},
+		{Title: "Later", Artist: "Someone", Provider: "youtube", ProviderTrack: "vid-later"},
+		{Title: "Too Many", Artist: "Someone", Provider: "youtube", ProviderTrack: "vid-extra"},
+	}
+	added, err := s.AddRadioTracks(ctx, pl.ID, 3, candidates, rs.Rules)
+	if err != nil || len(added) != 2 || added[0].Title != "Fresh" || added[1].Title != "Later" ||
+		added[0].Source != trackSourceRadio {
+		t.Fatalf("AddRadioTracks = %+v, %v", added, err)
+	}
+	addTestTrack(t, s, pl.ID, "User", trackSourceUser)
+	assertTitles(t, s, pl.ID, "Seed", "User", "Fresh", "Later")
+	if added, err := s.AddRadioTracks(ctx, pl.ID, 3, candidates, rs.Rules); err != nil || len(added) != 0 {
+		t.Errorf("AddRadioTracks with a full queue = %+v, %v", added, err)
+	}
+
+	if artists, err := s.RecentArtists(ctx, pl.ID, 5); err != nil || len(artists) != 0 {
+		t.Errorf("RecentArtists before playback = %v, %v", artists, err)
+	}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := s.AdvancePlayback(ctx, pl.ID, playEndFinished, "", now.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if artists, err := s.RecentArtists(ctx, pl.ID, 5); err != nil || fmt.Sprint(artists) != "[Someone Artist]" {
+		t.Errorf("RecentArtists = %v, %v", artists, err)
+	}
+	if artists, _ := s.RecentArtists(ctx, pl.ID, 1); fmt.Sprint(artists) != "[Someone]" {
+		t.Errorf("RecentArtists limited = %v", artists)
+	}
+}
+
+func testStoreInvitations(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	guest := "guest-" + newMemoryID()
+
+	inv := PlaylistInvitation{PlaylistID: pl.ID, UserID: guest, InvitedBy: pl.OwnerID, Role: memberRoleViewer}
+	if err := s.InviteMember(ctx, &inv, time.Hour); err != nil {
+		t.Fatalf("InviteMember: %v", err)
+	}
+	if inv.PlaylistName != pl.Name || inv.CreatedAt.IsZero() || inv.ExpiresAt.Sub(inv.CreatedAt) < 59*time.Minute {
+		t.Errorf("invitation = %+v", inv)
+	}
+	// Inviting again replaces the invitation.
+	inv.Role = memberRoleEditor
+	if err := s.InviteMember(ctx, &inv, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	expired := PlaylistInvitation{PlaylistID: pl.ID, UserID: "late-" + newMemoryID(), InvitedBy: pl.OwnerID, Role: memberRoleEditor}
+	if err := s.InviteMember(ctx, &expired, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	invites, err := s.ListInvitations(ctx, pl.ID)
+	if err != nil || len(invites) != 1 || invites[0].UserID != guest || invites[0].Role != memberRoleEditor ||
+		invites[0].PlaylistName != pl.Name {
+		t.Errorf("ListInvitations = %+v, %v", invites, err)
+	}
+	mine, err := s.ListUserInvitations(ctx, guest)
+	if err != nil || len(mine) != 1 || mine[0].PlaylistID != pl.ID {
+		t.Errorf("ListUserInvitations = %+v, %v", mine, err)
+	}
+
+	if _, err := s.AcceptInvitation(ctx, pl.ID, expired.UserID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AcceptInvitation of an expired invitation = %v", err)
+	}
+	if err := s.DeleteExpiredInvitations(ctx); err != nil {
+		t.Fatalf("DeleteExpiredInvitations: %v", err)
+	}
+
+	member, err := s.AcceptInvitation(ctx, pl.ID, guest)
+	if err != nil || member.UserID != guest || member.Role != memberRoleEditor || member.CreatedAt.IsZero() {
+		t.Fatalf("AcceptInvitation = %+v, %v", member, err)
+	}
+	if role, _ := s.MemberRole(ctx, pl.ID, guest); role != memberRoleEditor {
+		t.Errorf("role after accepting = %q", role)
+	}
+	// The membership is returned as stored.
+	members, err := s.ListMembers(ctx, pl.ID)
+	if err != nil || len(members) != 1 || !members[0].CreatedAt.Equal(member.CreatedAt) {
+		t.Errorf("ListMembers after accepting = %+v, %v", members, err)
+	}
+	if _, err := s.AcceptInvitation(ctx, pl.ID, guest); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AcceptInvitation twice = %v", err)
+	}
+	if invites, _ := s.ListInvitations(ctx, pl.ID); len(invites) != 0 {
+		t.Errorf("ListInvitations after accepting = %+v", invites)
+	}
+
+	if err := s.InviteMember(ctx, &inv, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeclineInvitation(ctx, pl.ID, guest); err != nil {
+		t.Errorf("DeclineInvitation: %v", err)
+	}
+	if err := s.DeclineInvitation(ctx, pl.ID, guest); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeclineInvitation twice = %v", err)
+	}
+
+	// Removed members lose their pending invitation, and trashed playlists
+	// drop out of the invitee's list.
+	if err := s.InviteMember(ctx, &inv, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeletePlaylist(ctx, pl.ID, func(Playlist) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if mine, _ := s.ListUserInvitations(ctx, guest); len(mine) != 0 {
+		t.Errorf("ListUserInvitations of a trashed playlist = %+v", mine)
+	}
+	if _, err := s.AcceptInvitation(ctx, pl.ID, guest); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AcceptInvitation of a trashed playlist = %v", err)
+	}
+	if _, err := s.RestorePlaylist(ctx, pl.ID, func(Playlist) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveMember(ctx, pl.ID, guest); err != nil {
+		t.Fatal(err)
+	}
+	if invites, _ := s.ListInvitations(ctx, pl.ID); len(invites) != 0 {
+		t.Errorf("ListInvitations after removing the member = %+v", invites)
+	}
+}
+
+func testStoreJoinLinks(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+
+	one := 1
+	link := JoinLink{PlaylistID: pl.ID, CreatedBy: pl.OwnerID, Role: memberRoleViewer, MaxUses: &one}
+	if err := s.CreateJoinLink(ctx, &link); err != nil {
+		t.Fatalf("CreateJoinLink: %v", err)
+	}
+	if link.Code == "" || link.Uses != 0 || link.CreatedAt.IsZero() || link.RevokedAt != nil {
+		t.Errorf("join link = %+v", link)
+	}
+
+	res, err := s.RedeemJoinLink(ctx, link.Code, pl.OwnerID)
+	if err != nil || res != (JoinResult{Type: "playlist", ID: pl.ID, Role: "owner"}) {
+		t.Errorf("RedeemJoinLink by the owner = %+v, %v", res, err)
+	}
+	res, err = s.RedeemJoinLink(ctx, link.Code, "u-1")
+	if err != nil || res != (JoinResult{Type: "playlist", ID: pl.ID, Role: memberRoleViewer, Joined: true}) {
+		t.Fatalf("RedeemJoinLink = %+v, %v", res, err)
+	}
+	if role, _ := s.MemberRole(ctx, pl.ID, "u-1"); role != memberRoleViewer {
+		t.Errorf("role after joining = %q", role)
+	}
+	if _, err := s.RedeemJoinLink(ctx, link.Code, "u-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RedeemJoinLink after the last use = %v", err)
+	}
+
+	open := JoinLink{PlaylistID: pl.ID, CreatedBy: pl.OwnerID, Role: memberRoleEditor}
+	if err := s.CreateJoinLink(ctx, &open); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := s.RedeemJoinLink(ctx, open.Code, "u-1"); err != nil || res.Role != memberRoleViewer || res.Joined {
+		t.Errorf("RedeemJoinLink by a member = %+v, %v", res, err)
+	}
+	if err := s.BanUser(ctx, &PlaylistBan{PlaylistID: pl.ID, UserID: "u-3", BannedBy: pl.OwnerID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RedeemJoinLink(ctx, open.Code, "u-3"); !errors.Is(err, errBannedFromPlaylist) {
+		t.Errorf("RedeemJoinLink by a banned user = %v", err)
+	}
+	if _, err := s.RedeemJoinLink(ctx, "nope", "u-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RedeemJoinLink of an unknown code = %v", err)
+	}
+
+	links, err := s.ListJoinLinks(ctx, pl.ID)
+	if err != nil || len(links) != 2 || links[0].Code != open.Code || links[1].Uses != 1 {
+		t.Errorf("ListJoinLinks = %+v, %v", links, err)
+	}
+
+	if err := s.RevokeJoinLink(ctx, pl.ID, open.Code); err != nil {
+		t.Fatalf("RevokeJoinLink: %v", err)
+	}
+	if err := s.RevokeJoinLink(ctx, pl.ID, open.Code); err != nil {
+		t.Errorf("RevokeJoinLink twice: %v", err)
+	}
+	if err := s.RevokeJoinLink(ctx, missingID, open.Code); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RevokeJoinLink of another playlist = %v", err)
+	}
+	if _, err := s.RedeemJoinLink(ctx, open.Code, "u-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RedeemJoinLink of a revoked link = %v", err)
+	}
+	if links, _ := s.ListJoinLinks(ctx, pl.ID); links[0].RevokedAt == nil {
+		t.Errorf("revoked link = %+v", links[0])
+	}
+}
+
+func testStoreFollows(t *testing.T, s PlaylistStore) {
+	ctx := context.Background()
+	pl := createTestPlaylist(t, s)
+	fan := "fan-" + newMemoryID()
+
+	if changed, err := s.SetFollow(ctx, pl.ID, fan, true); err != nil || !changed {
+		t.Fatalf("SetFollow = %v, %v", changed, err)
+	}
+	if changed, err := s.SetFollow(ctx, pl.ID, fan, true); err != nil || changed {
+		t.Errorf("SetFollow twice = %v, %v", changed, err)
+	}
+	if n, err := s.FollowerCount(ctx, pl.ID); err != nil || n != 1 {
+		t.Errorf("FollowerCount = %d, %v", n, err)
+	}
+	followed, err := s.ListFollowedPlaylists(ctx, fan)
+	if err != nil || len(followed) != 1 || followed[0].ID != pl.ID || followed[0].FollowerCount != 1 {
+		t.Errorf("ListFollowedPlaylists = %+v, %v", followed, err)
+	}
+	if name, ids, err := s.Followers(ctx, pl.ID); err != nil || name != pl.Name || fmt.Sprint(ids) != "["+fan+"]" {
+		t.Errorf("Followers = %q, %v, %v", name, ids, err)
+	}
+
+	tr := addTestTrack(t, s, pl.ID, "Fresh", trackSourceUser)
+	addTestTrack(t, s, pl.ID, "Radio", trackSourceRadio)
+	if _, err := s.AdvancePlayback(ctx, pl.ID, playEndSkipped, "", time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	items, err := s.Feed(ctx, fan, time.Now().Add(time.Hour), 10)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("Feed = %+v, %v", items, err)
+	}
+	if items[0].Type != "playback.started" || items[1].Type != "track.added" || items[1].TrackID != tr.ID ||
+		items[0].TrackID != tr.ID || items[1].PlaylistName != pl.Name {
+		t.Errorf("Feed = %+v", items)
+	}
+	if items, _ := s.Feed(ctx, fan, items[0].At, 10); len(items) != 1 {
+		t.Errorf("Feed before the playback = %+v", items)
+	}
+
+	// Followers who lost access see nothing of the playlist.
+	if _, err := s.UpdatePlaylist(ctx, pl.ID, func(p *Playlist) error {
+		p.IsPublic = false
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if followed, _ := s.ListFollowedPlaylists(ctx, fan); len(followed) != 0 {
+		t.Errorf("ListFollowedPlaylists of a private playlist = %+v", followed)
+	}
+	if items, _ := s.Feed(ctx, fan, time.Now().Add(time.Hour), 10); len(items) != 0 {
+		t.Errorf("Feed of a private playlist = %+v", items)
+	}
+	if _, ids, _ := s.Followers(ctx, pl.ID); len(ids) != 0 {
+		t.Errorf("Followers of a private playlist = %v", ids)
+	}
+
+	if changed, err := s.SetFollow(ctx, pl.ID, fan, false); err != nil || !changed {
+		t.Errorf("SetFollow off = %v, %v", changed, err)
+	}
+	if n, _ := s.FollowerCount(ctx, pl.ID); n != 0 {
+		t.Errorf("FollowerCount after unfollowing = %d", n)
+	}
+}
@@ -0,0 +1,2370 @@
+package playlist
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// MemoryStore is a PlaylistStore that keeps everything in process memory.
+type MemoryStore struct {
+	mu        sync.Mutex
+	playlists map[string]*memPlaylist
+	devices   map[string]*Device
+}
+
+type memPlaylist struct {
+	pl      Playlist
+	tracks  []*Track // in play order
+	members []PlaylistInvite
+	votes   map[string]map[string]bool // track ID -> voter IDs
+
+	reactions map[string]map[string]map[string]bool // track ID -> emoji -> user IDs
+	comments  []TrackComment                        // oldest first
+
+	queueLockedUntil  *time.Time
+	votingLockedUntil *time.Time
+	bans              map[string]PlaylistBan // by user ID
+	modLog            []ModerationEntry      // oldest first
+	contentRules      ContentRules
+	editLicense       EditLicense
+	smartRules        *SmartRules
+	suggestions       []*TrackSuggestion         // oldest first
+	presence          map[string]time.Time       // user ID -> last seen
+	skipVotes         map[string]map[string]bool // track ID -> voter IDs
+	player            *PlayerState
+	delegations       map[string]PlaybackDelegation // by user ID
+	activeDeviceID    string
+	invitations       map[string]PlaylistInvitation // by user ID
+	joinLinks         []*JoinLink                   // oldest first
+	followers         map[string]time.Time          // user ID -> followed at
+	history           []*PlayHistoryEntry           // oldest first
+	unresolved        map[string]time.Time          // tracks awaiting a provider lookup, by ID, and when a backfill claimed them
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{playlists: make(map[string]*memPlaylist), devices: make(map[string]*Device)}
+}
+
+// newMemoryID returns a random UUID, the same shape Postgres generates.
+func newMemoryID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// playlist returns the playlist with the given ID unless it is in the
+// trash. The caller holds mu.
+func (m *MemoryStore) playlist(id string) (*memPlaylist, error) {
+	p, ok := m.playlists[id]
+	if !ok || p.pl.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return p, nil
+}
+
+// track returns a track of p and its position.
+func (p *memPlaylist) track(id string) (*Track, int, error) {
+	for i, tr := range p.tracks {
+		if tr.ID == id {
+			return tr, i, nil
+		}
+	}
+	return nil, 0, ErrNotFound
+}
+
+// snapshot copies a track with its current position.
+func (p *memPlaylist) snapshot(tr *Track) Track {
+	out := *tr
+	_, out.Position, _ = p.track(tr.ID)
+	return out
+}
+
+func (m *MemoryStore) CreatePlaylist(ctx context.Context, pl *Playlist, smart *SmartRules) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pl.ID = newMemoryID()
+	pl.CreatedAt = time.Now()
+	pl.normalizeVisibility()
+	pl.CurrentTrackID = nil
+	pl.PlayingStartedAt = nil
+	p := &memPlaylist{pl: *pl, votes: make(map[string]map[string]bool), smartRules: smart.clone()}
+	if p.smartRules != nil {
+		p.smartRules.RefreshedAt = nil
+	}
+	m.playlists[pl.ID] = p
+	return nil
+}
+
+func (m *MemoryStore) GetPlaylist(ctx context.Context, id string) (Playlist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(id)
+	if err != nil {
+		return Playlist{}, err
+	}
+	return p.pl, nil
+}
+
+func (m *MemoryStore) UpdatePlaylist(ctx context.Context, id string, update func(*Playlist) error) (Playlist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(id)
+	if err != nil {
+		return Playlist{}, err
+	}
+	pl := p.pl
+	pl.CurrentTrackID, pl.PlayingStartedAt = nil, nil
+	if err := update(&pl); err != nil {
+		return Playlist{}, err
+	}
+	pl.syncVisibility(p.pl)
+
+	// Only the metadata is writable.
+	p.pl.Name = pl.Name
+	p.pl.Description = pl.Description
+	p.pl.IsPublic = pl.IsPublic
+	p.pl.Visibility = pl.Visibility
+	p.pl.EditMode = pl.EditMode
+	p.pl.DuplicatePolicy = pl.DuplicatePolicy
+	p.pl.FuzzyDuplicates = pl.FuzzyDuplicates
+	p.pl.RadioEnabled = pl.RadioEnabled
+	p.pl.RadioMinQueue = pl.RadioMinQueue
+	p.pl.SkipVotePercent = pl.SkipVotePercent
+	p.pl.SkipVoteThreshold = pl.SkipVoteThreshold
+	return pl, nil
+}
+
+func (m *MemoryStore) DeletePlaylist(ctx context.Context, id string, check func(Playlist) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(id)
+	if err != nil {
+		return err
+	}
+	if err := check(p.pl); err != nil {
+		return err
+	}
+	now := time.Now()
+	if p.pl.CurrentTrackID != nil {
+		if tr, _, err := p.track(*p.pl.CurrentTrackID); err == nil {
+			tr.Status = "played"
+		}
+		p.endHistory(*p.pl.CurrentTrackID, playEndDeleted, now)
+	}
+	p.pl.CurrentTrackID, p.pl.PlayingStartedAt = nil, nil
+	p.pl.DeletedAt = &now
+	return nil
+}
+
+func (m *MemoryStore) ListTrash(ctx context.Context, ownerID string) ([]Playlist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	playlists := []Playlist{}
+	for _, p := range m.playlists {
+		if p.pl.DeletedAt == nil || p.pl.OwnerID != ownerID {
+			continue
+		}
+		pl := p.pl
+		pl.TrackCount = len(p.tracks)
+		playlists = append(playlists, pl)
+	}
+	sort.Slice(playlists, func(i, j int) bool {
+		if !playlists[i].DeletedAt.Equal(*playlists[j].DeletedAt) {
+			return playlists[i].DeletedAt.After(*playlists[j].DeletedAt)
+		}
+		return playlists[i].ID < playlists[j].ID
+	})
+	return playlists, nil
+}
+
+func (m *MemoryStore) RestorePlaylist(ctx context.Context, id string, check func(Playlist) error) (Playlist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[id]
+	if !ok || p.pl.DeletedAt == nil {
+		return Playlist{}, ErrNotFound
+	}
+	if err := check(p.pl); err != nil {
+		return Playlist{}, err
+	}
+	p.pl.DeletedAt = nil
+	return p.pl, nil
+}
+
+func (m *MemoryStore) PurgeTrash(ctx context.Context, cutoff time.Time) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ids []string
+	for id, p := range m.playlists {
+		if p.pl.DeletedAt != nil && p.pl.DeletedAt.Before(cutoff) {
+			delete(m.playlists, id)
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *MemoryStore) PlaylistAccess(ctx context.Context, id string) (string, bool, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(id)
+	if err != nil {
+		return "", false, "", err
+	}
+	return p.pl.OwnerID, p.pl.IsPublic, p.pl.EditMode, nil
+}
+
+func (m *MemoryStore) DuplicatePolicy(ctx context.Context, id string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(id)
+	if err != nil {
+		return "", false, err
+	}
+	policy := p.pl.DuplicatePolicy
+	if policy == "" {
+		policy = duplicatePolicyAllow
+	}
+	return policy, p.pl.FuzzyDuplicates, nil
+}
+
+func (m *MemoryStore) ListTracks(ctx context.Context, playlistID, viewerID string) ([]Track, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tracks := []Track{}
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return tracks, nil
+	}
+	for i, tr := range p.tracks {
+		out := *tr
+		out.Position = i
+		out.IsVoted = p.votes[tr.ID][viewerID]
+		out.Reactions = p.reactionCounts(tr.ID)
+		for _, c := range p.comments {
+			if c.TrackID == tr.ID {
+				out.CommentCount++
+			}
+		}
+		tracks = append(tracks, out)
+	}
+	return tracks, nil
+}
+
+func (m *MemoryStore) GetTrack(ctx context.Context, playlistID, trackID string) (Track, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return Track{}, err
+	}
+	tr, _, err := p.track(trackID)
+	if err != nil {
+		return Track{}, err
+	}
+	return p.snapshot(tr), nil
+}
+
+func (m *MemoryStore) AddTrack(ctx context.Context, playlistID string, in trackInput, source string) (Track, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return Track{}, err
+	}
+	return p.snapshot(p.addTrack(in, source)), nil
+}
+
+func (m *MemoryStore) AddTrackUnlessDuplicate(ctx context.Context, playlistID, userID string, in trackInput, mergeDenied *httpError) (Track, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return Track{}, false, err
+	}
+	policy := p.pl.DuplicatePolicy
+	if policy == "" {
+		policy = duplicatePolicyAllow
+	}
+	var res batchResult
+	if err := p.applyBatchOperation(userID, policy, mergeDenied, batchOperation{Op: batchOpAdd, Track: &in}, &res); err != nil {
+		return Track{}, false, err
+	}
+	return *res.Track, res.Status == "merged", nil
+}
+
+// addTrack queues a track, user tracks before any queued radio track. The
+// caller holds mu.
+func (p *memPlaylist) addTrack(in trackInput, source string) *Track {
+	tr := &Track{
+		ID:              newMemoryID(),
+		PlaylistID:      p.pl.ID,
+		Title:           in.Title,
+		Artist:          in.Artist,
+		CreatedAt:       time.Now(),
+		Provider:        in.Provider,
+		ProviderTrackID: in.ProviderTrack,
+		ThumbnailURL:    in.ThumbnailURL,
+		DurationMs:      in.DurationMs,
+		Status:          "queued",
+		Source:          source,
+		AddedBy:         in.addedBy,
+	}
+
+	at := len(p.tracks)
+	if source != trackSourceRadio {
+		for i, t := range p.tracks {
+			if t.Status == "queued" && t.Source == trackSourceRadio {
+				at = i
+				break
+			}
+		}
+	}
+	p.tracks = append(p.tracks, nil)
+	copy(p.tracks[at+1:], p.tracks[at:])
+	p.tracks[at] = tr
+
+	if !in.resolved && tr.Provider != "" && tr.ProviderTrackID != "" {
+		if p.unresolved == nil {
+			p.unresolved = make(map[string]time.Time)
+		}
+		p.unresolved[tr.ID] = time.Time{}
+	}
+	return tr
+}
+
+func (m *MemoryStore) MoveTrack(ctx context.Context, playlistID, trackID string, newPos int) (int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return p.moveTrack(trackID, newPos)
+}
+
+func (p *memPlaylist) moveTrack(trackID string, newPos int) (int, int, error) {
+	tr, from, err := p.track(trackID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if newPos >= len(p.tracks) {
+		newPos = len(p.tracks) - 1
+	}
+	if newPos == from {
+		return from, newPos, nil
+	}
+
+	rest := append(p.tracks[:from:from], p.tracks[from+1:]...)
+	p.tracks = append(rest[:newPos:newPos], append([]*Track{tr}, rest[newPos:]...)...)
+	return from, newPos, nil
+}
+
+func (m *MemoryStore) DeleteTrack(ctx context.Context, playlistID, trackID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return 0, err
+	}
+	return p.deleteTrack(trackID)
+}
+
+func (m *MemoryStore) RemoveTracksByUser(ctx context.Context, playlistID, userID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	return p.removeTracks(func(tr *Track) bool {
+		return tr.Status == "queued" && tr.AddedBy == userID
+	}), nil
+}
+
+// deleteTrack removes a track like removeTracks and returns the position it
+// occupied.
+func (p *memPlaylist) deleteTrack(trackID string) (int, error) {
+	_, pos, err := p.track(trackID)
+	if err != nil {
+		return 0, err
+	}
+	p.removeTracks(func(tr *Track) bool { return tr.ID == trackID })
+	return pos, nil
+}
+
+// removeTracks removes the tracks matching remove with their votes,
+// reactions and comments and ends their playback. It returns their IDs in
+// playlist order.
+func (p *memPlaylist) removeTracks(remove func(*Track) bool) []string {
+	now := time.Now()
+	removed := []string{}
+	kept := make([]*Track, 0, len(p.tracks))
+	for _, tr := range p.tracks {
+		if !remove(tr) {
+			kept = append(kept, tr)
+			continue
+		}
+		removed = append(removed, tr.ID)
+		p.endHistory(tr.ID, playEndRemoved, now)
+		delete(p.unresolved, tr.ID)
+		delete(p.votes, tr.ID)
+		delete(p.reactions, tr.ID)
+		if p.pl.CurrentTrackID != nil && *p.pl.CurrentTrackID == tr.ID {
+			p.pl.CurrentTrackID = nil
+		}
+	}
+	if len(removed) == 0 {
+		return removed
+	}
+	p.tracks = kept
+
+	comments := p.comments[:0]
+	for _, c := range p.comments {
+		if !slices.Contains(removed, c.TrackID) {
+			comments = append(comments, c)
+		}
+	}
+	p.comments = comments
+	return removed
+}
+
+func (m *MemoryStore) FindDuplicateTrack(ctx context.Context, playlistID, policy string, fuzzy bool, c candidateTrack) (*duplicateMatch, error) {
+	statuses := duplicateStatuses(policy)
+	if statuses == nil {
+		return nil, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return nil, nil
+	}
+	return p.findDuplicate(statuses, fuzzy, c), nil
+}
+
+func (p *memPlaylist) findDuplicate(statuses []string, fuzzy bool, c candidateTrack) *duplicateMatch {
+	for _, tr := range p.tracks {
+		if !containsString(statuses, tr.Status) {
+			continue
+		}
+		var match bool
+		if c.ProviderTrackID != "" {
+			match = tr.Provider == c.Provider && tr.ProviderTrackID == c.ProviderTrackID
+		} else {
+			match = fuzzy && normalizeTrackText(c.Title) != "" && c.fuzzyMatches(tr.Title, tr.Artist)
+		}
+		if match {
+			return &duplicateMatch{ID: tr.ID, Status: tr.Status}
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) MemberRole(ctx context.Context, playlistID, userID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if p, ok := m.playlists[playlistID]; ok {
+		for _, mem := range p.members {
+			if mem.UserID == userID {
+				return mem.Role, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func (m *MemoryStore) ListMembers(ctx context.Context, playlistID string) ([]PlaylistInvite, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members := []PlaylistInvite{}
+	if p, ok := m.playlists[playlistID]; ok {
+		members = append(members, p.members...)
+	}
+	return members, nil
+}
+
+func (m *MemoryStore) AddMember(ctx context.Context, playlistID, userID, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return err
+	}
+	for _, mem := range p.members {
+		if mem.UserID == userID {
+			return nil
+		}
+	}
+	if role == "" {
+		role = memberRoleEditor
+	}
+	p.members = append(p.members, PlaylistInvite{UserID: userID, Role: role, CreatedAt: time.Now()})
+	return nil
+}
+
+func (m *MemoryStore) RemoveMember(ctx context.Context, playlistID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return nil
+	}
+	delete(p.invitations, userID)
+	for i, mem := range p.members {
+		if mem.UserID == userID {
+			p.members = append(p.members[:i:i], p.members[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) VoteTrack(ctx context.Context, playlistID, trackID, userID string) (Track, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return Track{}, err
+	}
+	return p.voteTrack(trackID, userID)
+}
+
+func (p *memPlaylist) voteTrack(trackID, userID string) (Track, error) {
+	tr, _, err := p.track(trackID)
+	if err != nil {
+		return Track{}, err
+	}
+	if p.votes[trackID][userID] {
+		return Track{}, errAlreadyVoted
+	}
+	if p.votes[trackID] == nil {
+		p.votes[trackID] = make(map[string]bool)
+	}
+	p.votes[trackID][userID] = true
+	tr.VoteCount++
+
+	if tr.Status == "queued" {
+		p.sortQueue()
+	}
+	return p.snapshot(tr), nil
+}
+
+func (m *MemoryStore) SetReaction(ctx context.Context, playlistID, trackID, userID, emoji string, on bool) (map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := p.track(trackID); err != nil {
+		return nil, err
+	}
+
+	if on {
+		if p.reactions == nil {
+			p.reactions = make(map[string]map[string]map[string]bool)
+		}
+		if p.reactions[trackID] == nil {
+			p.reactions[trackID] = make(map[string]map[string]bool)
+		}
+		if p.reactions[trackID][emoji] == nil {
+			p.reactions[trackID][emoji] = make(map[string]bool)
+		}
+		p.reactions[trackID][emoji][userID] = true
+	} else {
+		delete(p.reactions[trackID][emoji], userID)
+	}
+
+	counts := p.reactionCounts(trackID)
+	if counts == nil {
+		counts = map[string]int{}
+	}
+	return counts, nil
+}
+
+// reactionCounts returns the reaction counts of a track by emoji, or nil if
+// it has none.
+func (p *memPlaylist) reactionCounts(trackID string) map[string]int {
+	var counts map[string]int
+	for emoji, users := range p.reactions[trackID] {
+		if len(users) == 0 {
+			continue
+		}
+		if counts == nil {
+			counts = make(map[string]int)
+		}
+		counts[emoji] = len(users)
+	}
+	return counts
+}
+
+func (m *MemoryStore) ListComments(ctx context.Context, playlistID, trackID string) ([]TrackComment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comments := []TrackComment{}
+	if p, ok := m.playlists[playlistID]; ok {
+		for _, c := range p.comments {
+			if c.TrackID == trackID {
+				comments = append(comments, c)
+			}
+		}
+	}
+	return comments, nil
+}
+
+func (m *MemoryStore) AddComment(ctx context.Context, c *TrackComment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(c.PlaylistID)
+	if err != nil {
+		return err
+	}
+	if _, _, err := p.track(c.TrackID); err != nil {
+		return err
+	}
+	c.ID = newMemoryID()
+	c.CreatedAt = time.Now()
+	p.comments = append(p.comments, *c)
+	return nil
+}
+
+func (m *MemoryStore) DeleteComment(ctx context.Context, playlistID, trackID, commentID string, check func(TrackComment) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return ErrNotFound
+	}
+	for i, c := range p.comments {
+		if c.ID != commentID || c.TrackID != trackID {
+			continue
+		}
+		if err := check(c); err != nil {
+			return err
+		}
+		p.comments = append(p.comments[:i:i], p.comments[i+1:]...)
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (m *MemoryStore) ModerationState(ctx context.Context, playlistID, userID string) (ModerationState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return ModerationState{}, err
+	}
+	_, banned := p.bans[userID]
+	return ModerationState{
+		QueueLockedUntil:  p.queueLockedUntil,
+		VotingLockedUntil: p.votingLockedUntil,
+		Banned:            banned,
+	}, nil
+}
+
+func (m *MemoryStore) SetLock(ctx context.Context, playlistID, target string, until *time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return err
+	}
+	if target == lockVoting {
+		p.votingLockedUntil = until
+	} else {
+		p.queueLockedUntil = until
+	}
+	return nil
+}
+
+func (m *MemoryStore) BanUser(ctx context.Context, ban *PlaylistBan) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(ban.PlaylistID)
+	if err != nil {
+		return err
+	}
+	if p.bans == nil {
+		p.bans = make(map[string]PlaylistBan)
+	}
+	ban.CreatedAt = time.Now()
+	p.bans[ban.UserID] = *ban
+	delete(p.delegations, ban.UserID)
+	delete(p.invitations, ban.UserID)
+	for i, mem := range p.members {
+		if mem.UserID == ban.UserID {
+			p.members = append(p.members[:i:i], p.members[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) UnbanUser(ctx context.Context, playlistID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := p.bans[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(p.bans, userID)
+	return nil
+}
+
+func (m *MemoryStore) ListBans(ctx context.Context, playlistID string) ([]PlaylistBan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bans := []PlaylistBan{}
+	if p, ok := m.playlists[playlistID]; ok {
+		for _, b := range p.bans {
+			bans = append(bans, b)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		if !bans[i].CreatedAt.Equal(bans[j].CreatedAt) {
+			return bans[i].CreatedAt.After(bans[j].CreatedAt)
+		}
+		return bans[i].UserID < bans[j].UserID
+	})
+	return bans, nil
+}
+
+func (m *MemoryStore) AddModerationEntry(ctx context.Context, e *ModerationEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(e.PlaylistID)
+	if err != nil {
+		return err
+	}
+	e.ID = newMemoryID()
+	e.CreatedAt = time.Now()
+	p.modLog = append(p.modLog, *e)
+	return nil
+}
+
+func (m *MemoryStore) ListModerationLog(ctx context.Context, playlistID string, limit int) ([]ModerationEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := []ModerationEntry{}
+	if p, ok := m.playlists[playlistID]; ok {
+		for i := len(p.modLog) - 1; i >= 0 && len(entries) < limit; i-- {
+			entries = append(entries, p.modLog[i])
+		}
+	}
+	return entries, nil
+}
+
+func (m *MemoryStore) ContentRules(ctx context.Context, playlistID string) (ContentRules, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return ContentRules{}, err
+	}
+	return p.contentRules.clone(), nil
+}
+
+func (m *MemoryStore) SetContentRules(ctx context.Context, playlistID string, rules ContentRules) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return err
+	}
+	p.contentRules = rules.clone()
+	return nil
+}
+
+func (m *MemoryStore) EditLicense(ctx context.Context, playlistID string) (EditLicense, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return EditLicense{}, err
+	}
+	return p.editLicense.clone(), nil
+}
+
+func (m *MemoryStore) SetEditLicense(ctx context.Context, playlistID string, license EditLicense) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return err
+	}
+	p.editLicense = license.clone()
+	return nil
+}
+
+func (m *MemoryStore) SmartRules(ctx context.Context, playlistID string) (*SmartRules, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	return p.smartRules.clone(), nil
+}
+
+func (m *MemoryStore) SetSmartRules(ctx context.Context, playlistID string, rules *SmartRules) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return err
+	}
+	p.smartRules = rules.clone()
+	if p.smartRules != nil {
+		p.smartRules.RefreshedAt = nil
+	}
+	return nil
+}
+
+func (m *MemoryStore) AddSuggestion(ctx context.Context, sg *TrackSuggestion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(sg.PlaylistID)
+	if err != nil {
+		return err
+	}
+	sg.ID = newMemoryID()
+	sg.Status = suggestionPending
+	sg.CreatedAt = time.Now()
+	stored := *sg
+	p.suggestions = append(p.suggestions, &stored)
+	return nil
+}
+
+func (m *MemoryStore) ListSuggestions(ctx context.Context, playlistID, status, suggestedBy string) ([]TrackSuggestion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := []TrackSuggestion{}
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return out, nil
+	}
+	for _, sg := range p.suggestions {
+		if (status == "" || sg.Status == status) && (suggestedBy == "" || sg.SuggestedBy == suggestedBy) {
+			out = append(out, *sg)
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) GetSuggestion(ctx context.Context, playlistID, suggestionID string) (TrackSuggestion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sg, err := m.suggestion(playlistID, suggestionID)
+	if err != nil {
+		return TrackSuggestion{}, err
+	}
+	return *sg, nil
+}
+
+func (m *MemoryStore) ApproveSuggestion(ctx context.Context, playlistID, suggestionID, decidedBy string) (TrackSuggestion, Track, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sg, err := m.pendingSuggestion(playlistID, suggestionID)
+	if err != nil {
+		return TrackSuggestion{}, Track{}, err
+	}
+	p := m.playlists[playlistID]
+	tr := p.addTrack(sg.trackInput(), trackSourceUser)
+	now := time.Now()
+	sg.Status, sg.DecidedBy, sg.DecidedAt, sg.TrackID = suggestionApproved, decidedBy, &now, tr.ID
+	return *sg, p.snapshot(tr), nil
+}
+
+func (m *MemoryStore) RejectSuggestion(ctx context.Context, playlistID, suggestionID, decidedBy, reason string) (TrackSuggestion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sg, err := m.pendingSuggestion(playlistID, suggestionID)
+	if err != nil {
+		return TrackSuggestion{}, err
+	}
+	now := time.Now()
+	sg.Status, sg.DecidedBy, sg.DecidedAt, sg.Reason = suggestionRejected, decidedBy, &now, reason
+	return *sg, nil
+}
+
+// suggestion finds a suggestion of a playlist that is not in the trash.
+// The caller holds mu.
+func (m *MemoryStore) suggestion(playlistID, suggestionID string) (*TrackSuggestion, error) {
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	for _, sg := range p.suggestions {
+		if sg.ID == suggestionID {
+			return sg, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// pendingSuggestion is suggestion for suggestions that may still be
+// decided. The caller holds mu.
+func (m *MemoryStore) pendingSuggestion(playlistID, suggestionID string) (*TrackSuggestion, error) {
+	sg, err := m.suggestion(playlistID, suggestionID)
+	if err != nil {
+		return nil, err
+	}
+	if sg.Status != suggestionPending {
+		return nil, errSuggestionDecided
+	}
+	return sg, nil
+}
+
+// sortQueue moves the queued tracks after all other tracks, ordered the way
+// reorderQueuedByVotes orders them: user tracks before radio tracks, then by
+// votes, then by insertion time.
+func (p *memPlaylist) sortQueue() {
+	var rest, queued []*Track
+	for _, tr := range p.tracks {
+		if tr.Status == "queued" {
+			queued = append(queued, tr)
+		} else {
+			rest = append(rest, tr)
+		}
+	}
+	sort.SliceStable(queued, func(i, j int) bool {
+		if ri, rj := queued[i].Source == trackSourceRadio, queued[j].Source == trackSourceRadio; ri != rj {
+			return rj
+		}
+		if queued[i].VoteCount != queued[j].VoteCount {
+			return queued[i].VoteCount > queued[j].VoteCount
+		}
+		return queued[i].CreatedAt.Before(queued[j].CreatedAt)
+	})
+	p.tracks = append(rest, queued...)
+}
+
+func (m *MemoryStore) TouchPresence(ctx context.Context, playlistID, userID string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return err
+	}
+	if p.presence == nil {
+		p.presence = make(map[string]time.Time)
+	}
+	if at.After(p.presence[userID]) {
+		p.presence[userID] = at
+	}
+	return nil
+}
+
+func (m *MemoryStore) CountPresent(ctx context.Context, playlistID string, since time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, seen := range p.presence {
+		if !seen.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) AddSkipVote(ctx context.Context, playlistID, trackID, userID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return 0, err
+	}
+	if p.pl.CurrentTrackID == nil || *p.pl.CurrentTrackID != trackID {
+		return 0, errPlaybackChanged
+	}
+	if p.skipVotes[trackID][userID] {
+		return 0, errAlreadyVoted
+	}
+	if p.skipVotes == nil {
+		p.skipVotes = make(map[string]map[string]bool)
+	}
+	if p.skipVotes[trackID] == nil {
+		p.skipVotes[trackID] = make(map[string]bool)
+	}
+	p.skipVotes[trackID][userID] = true
+	return len(p.skipVotes[trackID]), nil
+}
+
+func (m *MemoryStore) SkipVotes(ctx context.Context, playlistID, trackID, userID string) (int, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return 0, false, err
+	}
+	return len(p.skipVotes[trackID]), p.skipVotes[trackID][userID], nil
+}
+
+func (m *MemoryStore) Player(ctx context.Context, playlistID string) (*PlayerState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil || p.player == nil {
+		return nil, err
+	}
+	player := *p.player
+	return &player, nil
+}
+
+func (m *MemoryStore) UpdatePlayer(ctx context.Context, playlistID string, update func(*PlayerState) (*PlayerState, error)) (*PlayerState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	var cur *PlayerState
+	if p.player != nil {
+		player := *p.player
+		cur = &player
+	}
+	next, err := update(cur)
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		p.player = nil
+		return nil, nil
+	}
+	player := *next
+	p.player = &player
+	return next, nil
+}
+
+func (m *MemoryStore) AdvancePlayback(ctx context.Context, playlistID, reason, expectTrackID string, now time.Time) (PlaybackState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return PlaybackState{}, err
+	}
+
+	var current *Track
+	if p.pl.CurrentTrackID != nil {
+		current, _, _ = p.track(*p.pl.CurrentTrackID)
+	}
+	if expectTrackID != "" {
+		if current == nil || current.Status != "playing" || current.ID != expectTrackID {
+			return PlaybackState{}, errPlaybackChanged
+		}
+		due := !p.pl.PlayingStartedAt.Add(time.Duration(current.DurationMs) * time.Millisecond).After(time.Now())
+		if reason == playEndFinished && !trackEnded(p.player, expectTrackID, due, time.Now()) {
+			return PlaybackState{}, errPlaybackChanged
+		}
+	}
+	if current != nil {
+		current.Status = "played"
+		p.endHistory(current.ID, reason, now)
+	}
+
+	p.pl.CurrentTrackID, p.pl.PlayingStartedAt = nil, nil
+	for _, tr := range p.tracks {
+		if tr.Status != "queued" || tr.Unavailable {
+			continue
+		}
+		tr.Status = "playing"
+		id, startedAt := tr.ID, now
+		p.pl.CurrentTrackID, p.pl.PlayingStartedAt = &id, &startedAt
+		p.history = append(p.history, &PlayHistoryEntry{
+			ID:              newMemoryID(),
+			PlaylistID:      p.pl.ID,
+			TrackID:         &id,
+			Title:           tr.Title,
+			Artist:          tr.Artist,
+			Provider:        tr.Provider,
+			ProviderTrackID: tr.ProviderTrackID,
+			DurationMs:      tr.DurationMs,
+			VoteCount:       tr.VoteCount,
+			StartedAt:       now,
+		})
+
+		next := p.snapshot(tr)
+		return PlaybackState{Current: &next, StartedAt: now}, nil
+	}
+	return PlaybackState{}, nil
+}
+
+func (m *MemoryStore) SetDelegation(ctx context.Context, d *PlaybackDelegation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(d.PlaylistID)
+	if err != nil {
+		return err
+	}
+	if p.delegations == nil {
+		p.delegations = make(map[string]PlaybackDelegation)
+	}
+	d.CreatedAt = time.Now()
+	p.delegations[d.UserID] = *d
+	return nil
+}
+
+func (m *MemoryStore) RemoveDelegation(ctx context.Context, playlistID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, ok := p.delegations[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(p.delegations, userID)
+	return nil
+}
+
+func (m *MemoryStore) ListDelegations(ctx context.Context, playlistID string, now time.Time) ([]PlaybackDelegation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delegations := []PlaybackDelegation{}
+	if p, ok := m.playlists[playlistID]; ok {
+		for _, d := range p.delegations {
+			if d.ExpiresAt == nil || d.ExpiresAt.After(now) {
+				delegations = append(delegations, d)
+			}
+		}
+	}
+	sort.Slice(delegations, func(i, j int) bool {
+		if !delegations[i].CreatedAt.Equal(delegations[j].CreatedAt) {
+			return delegations[i].CreatedAt.After(delegations[j].CreatedAt)
+		}
+		return delegations[i].UserID < delegations[j].UserID
+	})
+	return delegations, nil
+}
+
+func (m *MemoryStore) HasDelegation(ctx context.Context, playlistID, userID string, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return false, nil
+	}
+	d, ok := p.delegations[userID]
+	return ok && (d.ExpiresAt == nil || d.ExpiresAt.After(now)), nil
+}
+
+// copyDevice returns d with its own copy of the capabilities.
+func copyDevice(d *Device) Device {
+	out := *d
+	out.Capabilities = append([]string{}, d.Capabilities...)
+	return out
+}
+
+func (m *MemoryStore) RegisterDevice(ctx context.Context, d *Device) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d.ID = newMemoryID()
+	d.CreatedAt = time.Now()
+	d.LastSeenAt = d.CreatedAt
+	stored := copyDevice(d)
+	m.devices[d.ID] = &stored
+	return nil
+}
+
+func (m *MemoryStore) Device(ctx context.Context, deviceID string) (Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.devices[deviceID]
+	if !ok {
+		return Device{}, ErrNotFound
+	}
+	return copyDevice(d), nil
+}
+
+func (m *MemoryStore) ListDevices(ctx context.Context, userID string) ([]Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	devices := []Device{}
+	for _, d := range m.devices {
+		if d.UserID == userID {
+			devices = append(devices, copyDevice(d))
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if !devices[i].LastSeenAt.Equal(devices[j].LastSeenAt) {
+			return devices[i].LastSeenAt.After(devices[j].LastSeenAt)
+		}
+		return devices[i].ID < devices[j].ID
+	})
+	return devices, nil
+}
+
+func (m *MemoryStore) UpdateDevice(ctx context.Context, userID, deviceID string, update func(*Device) error) (Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.devices[deviceID]
+	if !ok || d.UserID != userID {
+		return Device{}, ErrNotFound
+	}
+	next := copyDevice(d)
+	if err := update(&next); err != nil {
+		return Device{}, err
+	}
+	stored := copyDevice(&next)
+	m.devices[deviceID] = &stored
+	return next, nil
+}
+
+func (m *MemoryStore) DeleteDevice(ctx context.Context, userID, deviceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.devices[deviceID]
+	if !ok || d.UserID != userID {
+		return ErrNotFound
+	}
+	delete(m.devices, deviceID)
+	for _, p := range m.playlists {
+		if p.activeDeviceID == deviceID {
+			p.activeDeviceID = ""
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) ActiveDevice(ctx context.Context, playlistID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return "", err
+	}
+	return p.activeDeviceID, nil
+}
+
+func (m *MemoryStore) SetActiveDevice(ctx context.Context, playlistID, deviceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.devices[deviceID]; deviceID != "" && !ok {
+		return ErrNotFound
+	}
+	p.activeDeviceID = deviceID
+	return nil
+}
+
+// memberRole returns the role of a member, or "" for non-members.
+func (p *memPlaylist) memberRole(userID string) string {
+	for _, mem := range p.members {
+		if mem.UserID == userID {
+			return mem.Role
+		}
+	}
+	return ""
+}
+
+// listed returns the playlist as the listing endpoints report it.
+func (p *memPlaylist) listed() Playlist {
+	return Playlist{
+		ID:            p.pl.ID,
+		OwnerID:       p.pl.OwnerID,
+		Name:          p.pl.Name,
+		Description:   p.pl.Description,
+		IsPublic:      p.pl.IsPublic,
+		EditMode:      p.pl.EditMode,
+		CreatedAt:     p.pl.CreatedAt,
+		TrackCount:    len(p.tracks),
+		FollowerCount: len(p.followers),
+	}
+}
+
+// lastActivity returns when a track was last added or playback last
+// started, or the creation time if neither happened.
+func (p *memPlaylist) lastActivity() time.Time {
+	last := p.pl.CreatedAt
+	if p.pl.PlayingStartedAt != nil && p.pl.PlayingStartedAt.After(last) {
+		last = *p.pl.PlayingStartedAt
+	}
+	for _, tr := range p.tracks {
+		if tr.CreatedAt.After(last) {
+			last = tr.CreatedAt
+		}
+	}
+	return last
+}
+
+// searchWords splits text into the lowercase words a search matches.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matchesSearch reports whether every word of the query occurs in the
+// playlist's name or description.
+func (p *memPlaylist) matchesSearch(query string) bool {
+	words := searchWords(query)
+	if len(words) == 0 {
+		return false
+	}
+	have := searchWords(p.pl.Name + " " + p.pl.Description)
+	for _, w := range words {
+		if !containsString(have, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// listSortKey returns the sort key of a listed playlist.
+func listSortKey(sort string, pl Playlist) (int64, time.Time) {
+	switch sort {
+	case listSortTracks:
+		return int64(pl.TrackCount), time.Time{}
+	case listSortActive:
+		return 0, activityAt(pl)
+	}
+	return 0, pl.CreatedAt
+}
+
+func (m *MemoryStore) ListPlaylists(ctx context.Context, q listPlaylistsQuery, userID string) ([]Playlist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// before reports whether pl sorts after the row with the given key.
+	before := func(pl Playlist, n int64, t time.Time, id string) bool {
+		pn, pt := listSortKey(q.Sort, pl)
+		switch {
+		case pn != n:
+			return pn < n
+		case !pt.Equal(t):
+			return pt.Before(t)
+		}
+		return pl.ID < id
+	}
+	var curN int64
+	var curT time.Time
+	if q.Cursor != nil {
+		curN, curT, _ = q.Cursor.sortKey()
+	}
+
+	playlists := []Playlist{}
+	for _, p := range m.playlists {
+		if p.pl.DeletedAt != nil {
+			continue
+		}
+		owner := userID != "" && p.pl.OwnerID == userID
+		member := userID != "" && p.memberRole(userID) != ""
+		friends := p.pl.Visibility == visibilityFriends && containsString(q.FriendIDs, p.pl.OwnerID)
+		if !p.pl.IsPublic && !owner && !member && !friends {
+			continue
+		}
+		switch q.Filter {
+		case listFilterOwned:
+			if !owner {
+				continue
+			}
+		case listFilterMember:
+			if !member || owner {
+				continue
+			}
+		case listFilterPublic:
+			if !p.pl.IsPublic {
+				continue
+			}
+		}
+		if q.EditMode != "" && p.pl.EditMode != q.EditMode {
+			continue
+		}
+		if q.Search != "" && !p.matchesSearch(q.Search) {
+			continue
+		}
+
+		pl := p.listed()
+		last := p.lastActivity()
+		pl.LastActivityAt = &last
+		pl.Visibility = p.pl.Visibility
+		if q.Cursor != nil && !before(pl, curN, curT, q.Cursor.ID) {
+			continue
+		}
+		playlists = append(playlists, pl)
+	}
+
+	sort.Slice(playlists, func(i, j int) bool {
+		n, t := listSortKey(q.Sort, playlists[i])
+		return before(playlists[j], n, t, playlists[i].ID)
+	})
+	if len(playlists) > q.Limit+1 {
+		playlists = playlists[:q.Limit+1]
+	}
+	return playlists, nil
+}
+
+// checkpoint saves the tracks and everything hanging off them. The returned
+// func restores them.
+func (p *memPlaylist) checkpoint() func() {
+	tracks := make([]*Track, len(p.tracks))
+	for i, tr := range p.tracks {
+		c := *tr
+		tracks[i] = &c
+	}
+	votes := make(map[string]map[string]bool, len(p.votes))
+	for id, voters := range p.votes {
+		votes[id] = make(map[string]bool, len(voters))
+		for u := range voters {
+			votes[id][u] = true
+		}
+	}
+	reactions := make(map[string]map[string]map[string]bool, len(p.reactions))
+	for id, r := range p.reactions {
+		reactions[id] = r
+	}
+	comments := append([]TrackComment(nil), p.comments...)
+	history := make([]*PlayHistoryEntry, len(p.history))
+	for i, e := range p.history {
+		c := *e
+		history[i] = &c
+	}
+	unresolved := make(map[string]time.Time, len(p.unresolved))
+	for id, claimed := range p.unresolved {
+		unresolved[id] = claimed
+	}
+	current := p.pl.CurrentTrackID
+
+	return func() {
+		p.tracks, p.votes, p.reactions, p.comments = tracks, votes, reactions, comments
+		p.history, p.unresolved, p.pl.CurrentTrackID = history, unresolved, current
+	}
+}
+
+func (m *MemoryStore) ApplyTrackBatch(ctx context.Context, playlistID, userID string, ops []batchOperation, mergeDenied *httpError, results []batchResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return err
+	}
+	policy := p.pl.DuplicatePolicy
+	if policy == "" {
+		policy = duplicatePolicyAllow
+	}
+
+	restore := p.checkpoint()
+	for i, op := range ops {
+		err := p.applyBatchOperation(userID, policy, mergeDenied, op, &results[i])
+		if err == nil {
+			continue
+		}
+		restore()
+		var he *httpError
+		if errors.As(err, &he) {
+			return failBatch(results, i, he)
+		}
+		return fmt.Errorf("operation %d: %w", i, err)
+	}
+	return nil
+}
+
+// applyBatchOperation is the in-memory counterpart of applyBatchOperation.
+func (p *memPlaylist) applyBatchOperation(userID, policy string, mergeDenied *httpError, op batchOperation, res *batchResult) error {
+	switch op.Op {
+	case batchOpAdd:
+		if dup := p.findDuplicate(duplicateStatuses(policy), p.pl.FuzzyDuplicates, op.Track.candidate()); dup != nil {
+			if policy != duplicatePolicyMergeVote || dup.Status != "queued" {
+				return &httpError{status: http.StatusConflict, msg: duplicateRejectReason(policy, dup.Status)}
+			}
+			if mergeDenied != nil {
+				return mergeDenied
+			}
+			tr, err := p.voteTrack(dup.ID, userID)
+			if errors.Is(err, errAlreadyVoted) {
+				return &httpError{status: http.StatusConflict, msg: "track is already queued and you already voted for it"}
+			}
+			if err != nil {
+				return err
+			}
+			res.Status = "merged"
+			res.TrackID = tr.ID
+			res.Track = &tr
+			return nil
+		}
+
+		tr := p.snapshot(p.addTrack(*op.Track, trackSourceUser))
+		res.Status = "ok"
+		res.TrackID = tr.ID
+		res.Track = &tr
+		return nil
+
+	case batchOpRemove:
+		if _, err := p.deleteTrack(op.TrackID); err != nil {
+			return &httpError{status: http.StatusNotFound, msg: "track not found"}
+		}
+		res.Status = "ok"
+		return nil
+
+	case batchOpMove:
+		from, to, err := p.moveTrack(op.TrackID, *op.NewPosition)
+		if err != nil {
+			return &httpError{status: http.StatusNotFound, msg: "track not found"}
+		}
+		res.Status = "ok"
+		res.From = &from
+		res.To = &to
+		return nil
+	}
+	return &httpError{status: http.StatusBadRequest, msg: "unsupported operation"}
+}
+
+func (m *MemoryStore) ClaimUnresolvedTracks(ctx context.Context, limit int, claimFor time.Duration) ([]unresolvedTrack, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var tracks []*Track
+	for _, p := range m.playlists {
+		for _, tr := range p.tracks {
+			if claimed, ok := p.unresolved[tr.ID]; ok && !claimed.After(now.Add(-claimFor)) {
+				tracks = append(tracks, tr)
+			}
+		}
+	}
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].CreatedAt.After(tracks[j].CreatedAt) })
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+
+	var pending []unresolvedTrack
+	for _, tr := range tracks {
+		m.playlists[tr.PlaylistID].unresolved[tr.ID] = now
+		pending = append(pending, unresolvedTrack{
+			ID:            tr.ID,
+			PlaylistID:    tr.PlaylistID,
+			Provider:      tr.Provider,
+			ProviderTrack: tr.ProviderTrackID,
+		})
+	}
+	return pending, nil
+}
+
+func (m *MemoryStore) MarkTrackResolved(ctx context.Context, playlistID, trackID string, meta *trackInput) (Track, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return Track{}, ErrNotFound
+	}
+	tr, _, err := p.track(trackID)
+	if err != nil {
+		return Track{}, err
+	}
+	if meta == nil {
+		tr.Unavailable = true
+	} else {
+		if meta.Title != "" {
+			tr.Title = meta.Title
+		}
+		if meta.Artist != "" {
+			tr.Artist = meta.Artist
+		}
+		if meta.ThumbnailURL != "" {
+			tr.ThumbnailURL = meta.ThumbnailURL
+		}
+		if meta.DurationMs > 0 {
+			tr.DurationMs = meta.DurationMs
+		}
+		tr.Unavailable = false
+	}
+	delete(p.unresolved, trackID)
+	return p.snapshot(tr), nil
+}
+
+func (p *memPlaylist) queuedCount() int {
+	n := 0
+	for _, tr := range p.tracks {
+		if tr.Status == "queued" {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *MemoryStore) RadioSettings(ctx context.Context, playlistID string) (radioSettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return radioSettings{}, err
+	}
+	return radioSettings{
+		OwnerID:  p.pl.OwnerID,
+		Enabled:  p.pl.RadioEnabled && p.smartRules == nil,
+		MinQueue: p.pl.RadioMinQueue,
+		Queued:   p.queuedCount(),
+		Rules:    p.contentRules,
+	}, nil
+}
+
+func (m *MemoryStore) RecentArtists(ctx context.Context, playlistID string, limit int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return nil, nil
+	}
+	// History is oldest first, so walking it backwards finds each artist's
+	// latest play first.
+	var artists []string
+	for i := len(p.history) - 1; i >= 0 && len(artists) < limit; i-- {
+		if a := p.history[i].Artist; a != "" && !containsString(artists, a) {
+			artists = append(artists, a)
+		}
+	}
+	return artists, nil
+}
+
+func (m *MemoryStore) AddRadioTracks(ctx context.Context, playlistID string, want int, candidates []trackInput, rules ContentRules) ([]Track, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, tr := range p.tracks {
+		if tr.ProviderTrackID != "" {
+			seen[tr.Provider+":"+tr.ProviderTrackID] = true
+		}
+		seen[normalizeTrackText(tr.Title)+"|"+normalizeArtist(tr.Artist)] = true
+	}
+
+	var added []Track
+	for _, c := range pickRadioTracks(candidates, rules, seen, p.queuedCount(), want) {
+		added = append(added, p.snapshot(p.addTrack(c, trackSourceRadio)))
+	}
+	return added, nil
+}
+
+func (m *MemoryStore) InviteMember(ctx context.Context, inv *PlaylistInvitation, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[inv.PlaylistID]
+	if !ok {
+		return ErrNotFound
+	}
+	if p.invitations == nil {
+		p.invitations = make(map[string]PlaylistInvitation)
+	}
+	inv.CreatedAt = time.Now()
+	inv.ExpiresAt = inv.CreatedAt.Add(ttl)
+	inv.PlaylistName = p.pl.Name
+	p.invitations[inv.UserID] = *inv
+	return nil
+}
+
+// pendingInvitations returns the unexpired invitations of p.
+func (p *memPlaylist) pendingInvitations(now time.Time) []PlaylistInvitation {
+	var invites []PlaylistInvitation
+	for _, inv := range p.invitations {
+		if inv.ExpiresAt.After(now) {
+			inv.PlaylistName = p.pl.Name
+			invites = append(invites, inv)
+		}
+	}
+	return invites
+}
+
+func (m *MemoryStore) ListInvitations(ctx context.Context, playlistID string) ([]PlaylistInvitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invites := []PlaylistInvitation{}
+	if p, ok := m.playlists[playlistID]; ok {
+		invites = append(invites, p.pendingInvitations(time.Now())...)
+	}
+	sort.Slice(invites, func(i, j int) bool { return invites[i].CreatedAt.Before(invites[j].CreatedAt) })
+	return invites, nil
+}
+
+func (m *MemoryStore) ListUserInvitations(ctx context.Context, userID string) ([]PlaylistInvitation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	invites := []PlaylistInvitation{}
+	for _, p := range m.playlists {
+		if p.pl.DeletedAt != nil {
+			continue
+		}
+		for _, inv := range p.pendingInvitations(now) {
+			if inv.UserID == userID {
+				invites = append(invites, inv)
+			}
+		}
+	}
+	sort.Slice(invites, func(i, j int) bool { return invites[i].CreatedAt.After(invites[j].CreatedAt) })
+	return invites, nil
+}
+
+func (m *MemoryStore) AcceptInvitation(ctx context.Context, playlistID, userID string) (PlaylistInvite, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return PlaylistInvite{}, err
+	}
+	inv, ok := p.invitations[userID]
+	if !ok || !inv.ExpiresAt.After(time.Now()) {
+		return PlaylistInvite{}, ErrNotFound
+	}
+	delete(p.invitations, userID)
+
+	for i, mem := range p.members {
+		if mem.UserID == userID {
+			p.members[i].Role = inv.Role
+			return p.members[i], nil
+		}
+	}
+	member := PlaylistInvite{UserID: userID, Role: inv.Role, CreatedAt: time.Now()}
+	p.members = append(p.members, member)
+	return member, nil
+}
+
+func (m *MemoryStore) DeclineInvitation(ctx context.Context, playlistID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return ErrNotFound
+	}
+	inv, ok := p.invitations[userID]
+	if !ok || !inv.ExpiresAt.After(time.Now()) {
+		return ErrNotFound
+	}
+	delete(p.invitations, userID)
+	return nil
+}
+
+func (m *MemoryStore) DeleteExpiredInvitations(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, p := range m.playlists {
+		for userID, inv := range p.invitations {
+			if !inv.ExpiresAt.After(now) {
+				delete(p.invitations, userID)
+			}
+		}
+	}
+	return nil
+}
+
+// joinLink returns a join link and its playlist. The caller holds mu.
+func (m *MemoryStore) joinLink(code string) (*memPlaylist, *JoinLink) {
+	for _, p := range m.playlists {
+		for _, l := range p.joinLinks {
+			if l.Code == code {
+				return p, l
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) CreateJoinLink(ctx context.Context, l *JoinLink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[l.PlaylistID]
+	if !ok {
+		return ErrNotFound
+	}
+	for {
+		code, err := newJoinCode()
+		if err != nil {
+			return err
+		}
+		if _, taken := m.joinLink(code); taken == nil {
+			l.Code = code
+			break
+		}
+	}
+	l.Uses = 0
+	l.RevokedAt = nil
+	l.CreatedAt = time.Now()
+	link := *l
+	p.joinLinks = append(p.joinLinks, &link)
+	return nil
+}
+
+func (m *MemoryStore) ListJoinLinks(ctx context.Context, playlistID string) ([]JoinLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	links := []JoinLink{}
+	if p, ok := m.playlists[playlistID]; ok {
+		for i := len(p.joinLinks) - 1; i >= 0; i-- {
+			links = append(links, *p.joinLinks[i])
+		}
+	}
+	return links, nil
+}
+
+func (m *MemoryStore) RevokeJoinLink(ctx context.Context, playlistID, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, l := m.joinLink(code)
+	if l == nil || p.pl.ID != playlistID {
+		return ErrNotFound
+	}
+	if l.RevokedAt == nil {
+		now := time.Now()
+		l.RevokedAt = &now
+	}
+	return nil
+}
+
+func (m *MemoryStore) RedeemJoinLink(ctx context.Context, code, userID string) (JoinResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	p, l := m.joinLink(code)
+	if l == nil || p.pl.DeletedAt != nil || l.RevokedAt != nil ||
+		(l.ExpiresAt != nil && !l.ExpiresAt.After(now)) ||
+		(l.MaxUses != nil && l.Uses >= *l.MaxUses) {
+		return JoinResult{}, ErrNotFound
+	}
+
+	res := JoinResult{Type: "playlist", ID: p.pl.ID}
+	if userID == p.pl.OwnerID {
+		res.Role = "owner"
+		return res, nil
+	}
+	if role := p.memberRole(userID); role != "" {
+		res.Role = role
+		return res, nil
+	}
+	if _, banned := p.bans[userID]; banned {
+		return JoinResult{}, errBannedFromPlaylist
+	}
+
+	l.Uses++
+	delete(p.invitations, userID)
+	p.members = append(p.members, PlaylistInvite{UserID: userID, Role: l.Role, CreatedAt: now})
+	res.Role = l.Role
+	res.Joined = true
+	return res, nil
+}
+
+// followedBy reports whether userID may still see a playlist they follow,
+// like followedVisible.
+func (p *memPlaylist) followedBy(userID string) bool {
+	return p.pl.DeletedAt == nil && (p.pl.IsPublic || p.pl.OwnerID == userID || p.memberRole(userID) != "")
+}
+
+func (m *MemoryStore) SetFollow(ctx context.Context, playlistID, userID string, on bool) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		if on {
+			return false, ErrNotFound
+		}
+		return false, nil
+	}
+	_, following := p.followers[userID]
+	if on == following {
+		return false, nil
+	}
+	if !on {
+		delete(p.followers, userID)
+		return true, nil
+	}
+	if p.followers == nil {
+		p.followers = make(map[string]time.Time)
+	}
+	p.followers[userID] = time.Now()
+	return true, nil
+}
+
+func (m *MemoryStore) FollowerCount(ctx context.Context, playlistID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if p, ok := m.playlists[playlistID]; ok {
+		return len(p.followers), nil
+	}
+	return 0, nil
+}
+
+// followed returns the playlists userID follows and still sees, most
+// recently followed first. The caller holds mu.
+func (m *MemoryStore) followed(userID string) []*memPlaylist {
+	var out []*memPlaylist
+	for _, p := range m.playlists {
+		if _, ok := p.followers[userID]; ok && p.followedBy(userID) {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].followers[userID].After(out[j].followers[userID])
+	})
+	return out
+}
+
+func (m *MemoryStore) ListFollowedPlaylists(ctx context.Context, userID string) ([]Playlist, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	playlists := []Playlist{}
+	for _, p := range m.followed(userID) {
+		playlists = append(playlists, p.listed())
+	}
+	return playlists, nil
+}
+
+func (m *MemoryStore) Feed(ctx context.Context, userID string, before time.Time, limit int) ([]FeedItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	items := []FeedItem{}
+	for _, p := range m.followed(userID) {
+		for _, tr := range p.tracks {
+			if tr.Source == trackSourceUser && tr.CreatedAt.Before(before) {
+				items = append(items, FeedItem{
+					Type:         "track.added",
+					PlaylistID:   p.pl.ID,
+					PlaylistName: p.pl.Name,
+					TrackID:      tr.ID,
+					Title:        tr.Title,
+					Artist:       tr.Artist,
+					At:           tr.CreatedAt,
+				})
+			}
+		}
+		for _, e := range p.history {
+			if !e.StartedAt.Before(before) {
+				continue
+			}
+			it := FeedItem{
+				Type:         "playback.started",
+				PlaylistID:   p.pl.ID,
+				PlaylistName: p.pl.Name,
+				Title:        e.Title,
+				Artist:       e.Artist,
+				At:           e.StartedAt,
+			}
+			if e.TrackID != nil {
+				it.TrackID = *e.TrackID
+			}
+			items = append(items, it)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].At.After(items[j].At) })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (m *MemoryStore) Followers(ctx context.Context, playlistID string) (string, []string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return "", nil, nil
+	}
+	var followerIDs []string
+	for userID := range p.followers {
+		if p.followedBy(userID) {
+			followerIDs = append(followerIDs, userID)
+		}
+	}
+	if len(followerIDs) == 0 {
+		return "", nil, nil
+	}
+	return p.pl.Name, followerIDs, nil
+}
+
+func (m *MemoryStore) PlayingTracks(ctx context.Context, playlistID string, heartbeatTimeout time.Duration) ([]playingTrack, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var out []playingTrack
+	for id, p := range m.playlists {
+		if playlistID != "" && id != playlistID {
+			continue
+		}
+		if p.pl.CurrentTrackID == nil || p.pl.PlayingStartedAt == nil {
+			continue
+		}
+		tr, _, err := p.track(*p.pl.CurrentTrackID)
+		if err != nil || tr.Status != "playing" || tr.DurationMs <= 0 {
+			continue
+		}
+		endsAt := p.pl.PlayingStartedAt.Add(time.Duration(tr.DurationMs) * time.Millisecond)
+		if p.player != nil && p.player.SeenAt.After(now.Add(-heartbeatTimeout)) {
+			if hold := p.player.SeenAt.Add(heartbeatTimeout); hold.After(endsAt) {
+				endsAt = hold
+			}
+		}
+		out = append(out, playingTrack{PlaylistID: id, TrackID: tr.ID, EndsAt: endsAt})
+	}
+	return out, nil
+}
+
+// endHistory closes the open history entry of a track, if any.
+func (p *memPlaylist) endHistory(trackID, reason string, at time.Time) {
+	for _, e := range p.history {
+		if e.TrackID != nil && *e.TrackID == trackID && e.EndedAt == nil {
+			ended, r := at, reason
+			e.EndedAt, e.EndReason = &ended, &r
+		}
+	}
+}
+
+func (m *MemoryStore) ListPlayHistory(ctx context.Context, playlistID string, before *time.Time, limit int) ([]PlayHistoryEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := []PlayHistoryEntry{}
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return entries, nil
+	}
+	for i := len(p.history) - 1; i >= 0 && len(entries) < limit; i-- {
+		if e := p.history[i]; before == nil || e.StartedAt.Before(*before) {
+			entries = append(entries, *e)
+		}
+	}
+	return entries, nil
+}
+
+func (m *MemoryStore) PlayStats(ctx context.Context, playlistID string, since, until *time.Time, topArtists int) (PlayStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := PlayStats{TopArtists: []ArtistPlayStat{}}
+	p, ok := m.playlists[playlistID]
+	if !ok {
+		return stats, nil
+	}
+	votes := 0
+	plays := make(map[string]int)
+	for _, e := range p.history {
+		if (since != nil && e.StartedAt.Before(*since)) || (until != nil && !e.StartedAt.Before(*until)) {
+			continue
+		}
+		stats.TotalPlays++
+		votes += e.VoteCount
+		plays[e.Artist]++
+		if e.EndReason != nil {
+			switch *e.EndReason {
+			case playEndFinished:
+				stats.Finished++
+			case playEndSkipped:
+				stats.Skipped++
+			}
+		}
+		if e.EndedAt != nil {
+			stats.TotalPlayMs += e.EndedAt.Sub(e.StartedAt).Milliseconds()
+		}
+	}
+	if stats.TotalPlays > 0 {
+		stats.AverageVotes = float64(votes) / float64(stats.TotalPlays)
+	}
+
+	for artist, n := range plays {
+		stats.TopArtists = append(stats.TopArtists, ArtistPlayStat{Artist: artist, Plays: n})
+	}
+	sort.Slice(stats.TopArtists, func(i, j int) bool {
+		a, b := stats.TopArtists[i], stats.TopArtists[j]
+		if a.Plays != b.Plays {
+			return a.Plays > b.Plays
+		}
+		return a.Artist < b.Artist
+	})
+	if len(stats.TopArtists) > topArtists {
+		stats.TopArtists = stats.TopArtists[:topArtists]
+	}
+	return stats, nil
+}
+
+func (m *MemoryStore) PlayedTracks(ctx context.Context, ownerID, playlistID string, days, minVotes, limit int) ([]trackInput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type played struct {
+		in      trackInput
+		votes   int
+		plays   int
+		lastRun time.Time
+	}
+	var order []*played
+	byKey := make(map[[4]string]*played)
+	since := time.Now().AddDate(0, 0, -days)
+	for _, p := range m.playlists {
+		if p.pl.OwnerID != ownerID || p.pl.ID == playlistID || p.pl.DeletedAt != nil {
+			continue
+		}
+		for _, e := range p.history {
+			if !e.StartedAt.After(since) || e.VoteCount < minVotes {
+				continue
+			}
+			key := [4]string{e.Title, e.Artist, e.Provider, e.ProviderTrackID}
+			pt := byKey[key]
+			if pt == nil {
+				pt = &played{in: trackInput{Title: e.Title, Artist: e.Artist, Provider: e.Provider, ProviderTrack: e.ProviderTrackID}}
+				byKey[key] = pt
+				order = append(order, pt)
+			}
+			pt.plays++
+			pt.votes = max(pt.votes, e.VoteCount)
+			pt.in.DurationMs = max(pt.in.DurationMs, e.DurationMs)
+			if e.StartedAt.After(pt.lastRun) {
+				pt.lastRun = e.StartedAt
+			}
+			if e.TrackID != nil {
+				if tr, _, err := p.track(*e.TrackID); err == nil && tr.ThumbnailURL > pt.in.ThumbnailURL {
+					pt.in.ThumbnailURL = tr.ThumbnailURL
+				}
+			}
+		}
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if a.votes != b.votes {
+			return a.votes > b.votes
+		}
+		if a.plays != b.plays {
+			return a.plays > b.plays
+		}
+		return a.lastRun.After(b.lastRun)
+	})
+	var out []trackInput
+	for _, pt := range order {
+		if len(out) >= limit {
+			break
+		}
+		out = append(out, pt.in)
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) FollowedTracks(ctx context.Context, userID, playlistID string, minVotes, limit int) ([]trackInput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var tracks []*Track
+	for _, p := range m.followed(userID) {
+		if p.pl.ID == playlistID {
+			continue
+		}
+		for _, tr := range p.tracks {
+			if tr.VoteCount >= minVotes {
+				tracks = append(tracks, tr)
+			}
+		}
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		if tracks[i].VoteCount != tracks[j].VoteCount {
+			return tracks[i].VoteCount > tracks[j].VoteCount
+		}
+		return tracks[i].CreatedAt.After(tracks[j].CreatedAt)
+	})
+
+	var out []trackInput
+	for _, tr := range tracks {
+		if len(out) >= limit {
+			break
+		}
+		out = append(out, trackInput{
+			Title:         tr.Title,
+			Artist:        tr.Artist,
+			Provider:      tr.Provider,
+			ProviderTrack: tr.ProviderTrackID,
+			ThumbnailURL:  tr.ThumbnailURL,
+			DurationMs:    tr.DurationMs,
+		})
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) ReplaceSmartQueue(ctx context.Context, playlistID string, limit int, candidates []trackInput, rules ContentRules) (smartQueueUpdate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := m.playlist(playlistID)
+	if err != nil {
+		return smartQueueUpdate{}, err
+	}
+	if p.smartRules == nil {
+		return smartQueueUpdate{}, errNotSmart
+	}
+
+	seen := make(map[string]bool)
+	var update smartQueueUpdate
+	for i := len(p.tracks) - 1; i >= 0; i-- {
+		tr := p.tracks[i]
+		switch tr.Status {
+		case "playing":
+			in := trackInput{Title: tr.Title, Artist: tr.Artist, Provider: tr.Provider, ProviderTrack: tr.ProviderTrackID}
+			for _, key := range smartTrackKeys(in) {
+				seen[key] = true
+			}
+		case "queued":
+			update.Removed = append(update.Removed, removedTrack{ID: tr.ID, Position: i})
+		}
+	}
+	for _, rm := range update.Removed {
+		if _, err := p.deleteTrack(rm.ID); err != nil {
+			return smartQueueUpdate{}, err
+		}
+	}
+
+	for _, c := range pickSmartTracks(candidates, rules, seen, limit) {
+		update.Added = append(update.Added, p.snapshot(p.addTrack(c, trackSourceSmart)))
+	}
+	update.RefreshedAt = time.Now()
+	at := update.RefreshedAt
+	p.smartRules.RefreshedAt = &at
+	return update, nil
+}
+
+func (m *MemoryStore) ClaimDueSmartPlaylists(ctx context.Context, limit int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var due []*memPlaylist
+	for _, p := range m.playlists {
+		r := p.smartRules
+		if p.pl.DeletedAt != nil || r == nil || r.RefreshHours <= 0 {
+			continue
+		}
+		if r.RefreshedAt == nil || r.RefreshedAt.Before(now.Add(-time.Duration(r.RefreshHours)*time.Hour)) {
+			due = append(due, p)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		a, b := due[i].smartRules.RefreshedAt, due[j].smartRules.RefreshedAt
+		return a == nil && b != nil || a != nil && b != nil && a.Before(*b)
+	})
+
+	var ids []string
+	for _, p := range due {
+		if len(ids) >= limit {
+			break
+		}
+		at := now
+		p.smartRules.RefreshedAt = &at
+		ids = append(ids, p.pl.ID)
+	}
+	return ids, nil
+}
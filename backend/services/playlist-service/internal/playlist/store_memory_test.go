@@ -0,0 +1,71 @@
+package playlist
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestMemoryServerFlow runs the voting and playback flow of
+// TestVotingAndPlaybackFlow end to end against a MemoryStore.
+func TestMemoryServerFlow(t *testing.T) {
+	router := NewServerWithStore(NewMemoryStore(), nil).Router()
+	userID := "test-user-1"
+
+	body, _ := json.Marshal(map[string]any{"name": "Memory Playlist", "isPublic": true})
+	req := httptest.NewRequest("POST", "/playlists", bytes.NewReader(body))
+	req.Header.Set("X-User-Id", userID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Failed to create playlist: %d %s", w.Code, w.Body.String())
+	}
+	var pl Playlist
+	json.Unmarshal(w.Body.Bytes(), &pl)
+
+	trackA := addTrack(t, router, userID, pl.ID, "Track A", 0)
+	trackB := addTrack(t, router, userID, pl.ID, "Track B", 0)
+	trackC := addTrack(t, router, userID, pl.ID, "Track C", 0)
+	checkOrder(t, router, userID, pl.ID, []string{trackA.ID, trackB.ID, trackC.ID})
+
+	voteForTrack(t, router, userID, pl.ID, trackC.ID)
+	checkOrder(t, router, userID, pl.ID, []string{trackC.ID, trackA.ID, trackB.ID})
+
+	voteForTrack(t, router, "test-user-2", pl.ID, trackB.ID)
+	voteForTrack(t, router, "test-user-3", pl.ID, trackB.ID)
+	checkOrder(t, router, userID, pl.ID, []string{trackB.ID, trackC.ID, trackA.ID})
+
+	callNextTrack(t, router, userID, pl.ID, trackB.ID, "playing")
+	callNextTrack(t, router, userID, pl.ID, trackC.ID, "playing")
+
+	req = httptest.NewRequest("DELETE", "/playlists/"+pl.ID+"/tracks/"+trackA.ID, nil)
+	req.Header.Set("X-User-Id", userID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Delete track failed: %d %s", w.Code, w.Body.String())
+	}
+	checkOrder(t, router, userID, pl.ID, []string{trackB.ID, trackC.ID})
+
+	req = httptest.NewRequest("GET", "/playlists/"+pl.ID+"/history", nil)
+	req.Header.Set("X-User-Id", userID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("List history failed: %d %s", w.Code, w.Body.String())
+	}
+	var history []PlayHistoryEntry
+	json.Unmarshal(w.Body.Bytes(), &history)
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 history entries, got %d", len(history))
+	}
+	if *history[0].TrackID != trackC.ID || history[0].EndedAt != nil {
+		t.Errorf("Expected Track C to be playing, got %+v", history[0])
+	}
+	if *history[1].TrackID != trackB.ID || history[1].EndReason == nil || *history[1].EndReason != playEndSkipped ||
+		history[1].PlayedMs == nil || history[1].VoteCount != 2 {
+		t.Errorf("Expected Track B to be skipped, got %+v", history[1])
+	}
+}
@@ -0,0 +1,2510 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DB interface abstracts the database connection (pool or mock).
+type DB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// querier is satisfied by both DB and pgx.Tx, so helpers can run inside or
+// outside of a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// PostgresStore implements PlaylistStore on the schema created by
+// AutoMigrate. It also keeps play_history in step with playback.
+type PostgresStore struct {
+	db DB
+}
+
+func NewPostgresStore(db DB) *PostgresStore {
+	return &PostgresStore{db: notFoundDB{db}}
+}
+
+// notFoundDB reports the pgx.ErrNoRows of single-row queries as
+// ErrNotFound, inside transactions too, so that callers of the store never
+// see pgx errors.
+type notFoundDB struct{ DB }
+
+func (d notFoundDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return notFoundRow{d.DB.QueryRow(ctx, sql, args...)}
+}
+
+func (d notFoundDB) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	tx, err := d.DB.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, err
+	}
+	return notFoundTx{tx}, nil
+}
+
+type notFoundTx struct{ pgx.Tx }
+
+func (tx notFoundTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return notFoundRow{tx.Tx.QueryRow(ctx, sql, args...)}
+}
+
+type notFoundRow struct{ pgx.Row }
+
+func (r notFoundRow) Scan(dest ...any) error {
+	err := r.Row.Scan(dest...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (p *PostgresStore) CreatePlaylist(ctx context.Context, pl *Playlist, smart *SmartRules) error {
+	pl.normalizeVisibility()
+	doc, err := smartRulesDoc(smart)
+	if err != nil {
+		return err
+	}
+	return p.db.QueryRow(ctx, `
+		INSERT INTO playlists (owner_id, name, description, is_public, edit_mode, duplicate_policy, fuzzy_duplicates,
+		                       radio_enabled, radio_min_queue, skip_vote_percent, skip_vote_threshold, visibility, smart_rules)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
+		RETURNING id, owner_id, name, description, is_public, edit_mode, created_at, duplicate_policy, fuzzy_duplicates,
+		          radio_enabled, radio_min_queue, skip_vote_percent, skip_vote_threshold
+	`, pl.OwnerID, pl.Name, pl.Description, pl.IsPublic, pl.EditMode, pl.DuplicatePolicy, pl.FuzzyDuplicates,
+		pl.RadioEnabled, pl.RadioMinQueue, pl.SkipVotePercent, pl.SkipVoteThreshold, pl.Visibility, doc).Scan(
+		&pl.ID,
+		&pl.OwnerID,
+		&pl.Name,
+		&pl.Description,
+		&pl.IsPublic,
+		&pl.EditMode,
+		&pl.CreatedAt,
+		&pl.DuplicatePolicy,
+		&pl.FuzzyDuplicates,
+		&pl.RadioEnabled,
+		&pl.RadioMinQueue,
+		&pl.SkipVotePercent,
+		&pl.SkipVoteThreshold,
+	)
+}
+
+func (p *PostgresStore) GetPlaylist(ctx context.Context, id string) (Playlist, error) {
+	var pl Playlist
+	err := p.db.QueryRow(ctx, `
+		SELECT id, owner_id, name, description, is_public, edit_mode, created_at, current_track_id, playing_started_at,
+		       duplicate_policy, fuzzy_duplicates, radio_enabled, radio_min_queue,
+		       skip_vote_percent, skip_vote_threshold,
+		       (SELECT COUNT(*) FROM playlist_follows f WHERE f.playlist_id = playlists.id)::int, visibility
+		FROM playlists
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id).Scan(
+		&pl.ID,
+		&pl.OwnerID,
+		&pl.Name,
+		&pl.Description,
+		&pl.IsPublic,
+		&pl.EditMode,
+		&pl.CreatedAt,
+		&pl.CurrentTrackID,
+		&pl.PlayingStartedAt,
+		&pl.DuplicatePolicy,
+		&pl.FuzzyDuplicates,
+		&pl.RadioEnabled,
+		&pl.RadioMinQueue,
+		&pl.SkipVotePercent,
+		&pl.SkipVoteThreshold,
+		&pl.FollowerCount,
+		&pl.Visibility,
+	)
+	return pl, err
+}
+
+func (p *PostgresStore) UpdatePlaylist(ctx context.Context, id string, update func(*Playlist) error) (Playlist, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Playlist{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var pl Playlist
+	err = tx.QueryRow(ctx, `
+		SELECT id, owner_id, name, description, is_public, edit_mode, created_at,
+		       duplicate_policy, fuzzy_duplicates, radio_enabled, radio_min_queue,
+		       skip_vote_percent, skip_vote_threshold,
+		       (SELECT COUNT(*) FROM playlist_follows f WHERE f.playlist_id = playlists.id)::int, visibility
+		FROM playlists
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id).Scan(
+		&pl.ID,
+		&pl.OwnerID,
+		&pl.Name,
+		&pl.Description,
+		&pl.IsPublic,
+		&pl.EditMode,
+		&pl.CreatedAt,
+		&pl.DuplicatePolicy,
+		&pl.FuzzyDuplicates,
+		&pl.RadioEnabled,
+		&pl.RadioMinQueue,
+		&pl.SkipVotePercent,
+		&pl.SkipVoteThreshold,
+		&pl.FollowerCount,
+		&pl.Visibility,
+	)
+	if err != nil {
+		return Playlist{}, err
+	}
+
+	old := pl
+	if err := update(&pl); err != nil {
+		return Playlist{}, err
+	}
+	pl.syncVisibility(old)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE playlists
+		SET name = $2,
+			description = $3,
+			is_public = $4,
+			edit_mode = $5,
+			duplicate_policy = $6,
+			fuzzy_duplicates = $7,
+			radio_enabled = $8,
+			radio_min_queue = $9,
+			skip_vote_percent = $10,
+			skip_vote_threshold = $11,
+			visibility = $12
+		WHERE id = $1
+	`, pl.ID, pl.Name, pl.Description, pl.IsPublic, pl.EditMode,
+		pl.DuplicatePolicy, pl.FuzzyDuplicates, pl.RadioEnabled, pl.RadioMinQueue,
+		pl.SkipVotePercent, pl.SkipVoteThreshold, pl.Visibility)
+	if err != nil {
+		return Playlist{}, fmt.Errorf("update: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Playlist{}, fmt.Errorf("commit: %w", err)
+	}
+	return pl, nil
+}
+
+func (p *PostgresStore) DeletePlaylist(ctx context.Context, id string, check func(Playlist) error) error {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	pl := Playlist{ID: id}
+	err = tx.QueryRow(ctx, `
+		SELECT owner_id, current_track_id FROM playlists
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, id).Scan(&pl.OwnerID, &pl.CurrentTrackID)
+	if err != nil {
+		return err
+	}
+	if err := check(pl); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if pl.CurrentTrackID != nil {
+		if _, err := tx.Exec(ctx, `UPDATE tracks SET status = 'played' WHERE id = $1`, *pl.CurrentTrackID); err != nil {
+			return fmt.Errorf("stop playback: %w", err)
+		}
+		if err := endPlayHistory(ctx, tx, id, *pl.CurrentTrackID, playEndDeleted, now); err != nil {
+			return fmt.Errorf("end history: %w", err)
+		}
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE playlists
+		SET deleted_at = $2, current_track_id = NULL, playing_started_at = NULL
+		WHERE id = $1
+	`, id, now)
+	if err != nil {
+		return fmt.Errorf("trash: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) PlaylistAccess(ctx context.Context, id string) (ownerID string, isPublic bool, editMode string, err error) {
+	err = p.db.QueryRow(ctx, `
+		SELECT owner_id, is_public, edit_mode
+		FROM playlists
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id).Scan(&ownerID, &isPublic, &editMode)
+	return
+}
+
+func (p *PostgresStore) ListTrash(ctx context.Context, ownerID string) ([]Playlist, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT id, owner_id, name, description, is_public, edit_mode, created_at, deleted_at,
+		       (SELECT COUNT(*) FROM tracks t WHERE t.playlist_id = playlists.id)::int
+		FROM playlists
+		WHERE owner_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id
+	`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := []Playlist{}
+	for rows.Next() {
+		var pl Playlist
+		if err := rows.Scan(
+			&pl.ID,
+			&pl.OwnerID,
+			&pl.Name,
+			&pl.Description,
+			&pl.IsPublic,
+			&pl.EditMode,
+			&pl.CreatedAt,
+			&pl.DeletedAt,
+			&pl.TrackCount,
+		); err != nil {
+			return nil, err
+		}
+		playlists = append(playlists, pl)
+	}
+	return playlists, rows.Err()
+}
+
+func (p *PostgresStore) RestorePlaylist(ctx context.Context, id string, check func(Playlist) error) (Playlist, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Playlist{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	pl := Playlist{ID: id}
+	err = tx.QueryRow(ctx, `
+		SELECT owner_id, deleted_at FROM playlists
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		FOR UPDATE
+	`, id).Scan(&pl.OwnerID, &pl.DeletedAt)
+	if err != nil {
+		return Playlist{}, err
+	}
+	if err := check(pl); err != nil {
+		return Playlist{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE playlists SET deleted_at = NULL WHERE id = $1`, id); err != nil {
+		return Playlist{}, fmt.Errorf("restore: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Playlist{}, fmt.Errorf("commit: %w", err)
+	}
+	return p.GetPlaylist(ctx, id)
+}
+
+func (p *PostgresStore) PurgeTrash(ctx context.Context, cutoff time.Time) ([]string, error) {
+	rows, err := p.db.Query(ctx, `
+		DELETE FROM playlists
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1
+		RETURNING id
+	`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (p *PostgresStore) DuplicatePolicy(ctx context.Context, id string) (string, bool, error) {
+	return getDuplicatePolicy(ctx, p.db, id)
+}
+
+func (p *PostgresStore) ListTracks(ctx context.Context, playlistID, viewerID string) ([]Track, error) {
+	rows, err := p.db.Query(ctx, `
+    SELECT t.id, t.playlist_id, t.title, t.artist,
+           (ROW_NUMBER() OVER (ORDER BY t.sort_key, t.id) - 1)::int AS position, t.created_at,
+           t.provider, t.provider_track_id, t.thumbnail_url, t.duration_ms, t.vote_count, t.status, t.source, t.unavailable,
+           (tv.user_id IS NOT NULL) as is_voted, t.added_by
+    FROM tracks t
+    LEFT JOIN track_votes tv ON t.id = tv.track_id AND tv.user_id = $2
+    WHERE t.playlist_id = $1
+    ORDER BY t.sort_key, t.id
+  `, playlistID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tracks := []Track{}
+	for rows.Next() {
+		var tr Track
+		if err := rows.Scan(
+			&tr.ID,
+			&tr.PlaylistID,
+			&tr.Title,
+			&tr.Artist,
+			&tr.Position,
+			&tr.CreatedAt,
+			&tr.Provider,
+			&tr.ProviderTrackID,
+			&tr.ThumbnailURL,
+			&tr.DurationMs,
+			&tr.VoteCount,
+			&tr.Status,
+			&tr.Source,
+			&tr.Unavailable,
+			&tr.IsVoted,
+			&tr.AddedBy,
+		); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		tracks = append(tracks, tr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := loadTrackEngagement(ctx, p.db, playlistID, tracks); err != nil {
+		return nil, fmt.Errorf("engagement: %w", err)
+	}
+	return tracks, nil
+}
+
+// loadTrackEngagement sets the reaction and comment counts of tracks, which
+// all belong to playlistID.
+func loadTrackEngagement(ctx context.Context, q querier, playlistID string, tracks []Track) error {
+	if len(tracks) == 0 {
+		return nil
+	}
+	index := make(map[string]*Track, len(tracks))
+	for i := range tracks {
+		index[tracks[i].ID] = &tracks[i]
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT track_id, emoji, COUNT(*)::int
+		FROM track_reactions
+		WHERE playlist_id = $1
+		GROUP BY track_id, emoji
+	`, playlistID)
+	if err != nil {
+		return fmt.Errorf("reactions: %w", err)
+	}
+	for rows.Next() {
+		var trackID, emoji string
+		var n int
+		if err := rows.Scan(&trackID, &emoji, &n); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan reaction: %w", err)
+		}
+		if tr, ok := index[trackID]; ok {
+			if tr.Reactions == nil {
+				tr.Reactions = make(map[string]int)
+			}
+			tr.Reactions[emoji] = n
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = q.Query(ctx, `
+		SELECT track_id, COUNT(*)::int
+		FROM track_comments
+		WHERE playlist_id = $1
+		GROUP BY track_id
+	`, playlistID)
+	if err != nil {
+		return fmt.Errorf("comments: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var trackID string
+		var n int
+		if err := rows.Scan(&trackID, &n); err != nil {
+			return fmt.Errorf("scan comments: %w", err)
+		}
+		if tr, ok := index[trackID]; ok {
+			tr.CommentCount = n
+		}
+	}
+	return rows.Err()
+}
+
+func (p *PostgresStore) GetTrack(ctx context.Context, playlistID, trackID string) (Track, error) {
+	return loadTrack(ctx, p.db, playlistID, trackID)
+}
+
+func (p *PostgresStore) AddTrack(ctx context.Context, playlistID string, in trackInput, source string) (Track, error) {
+	return insertTrack(ctx, p.db, playlistID, in, source)
+}
+
+func (p *PostgresStore) AddTrackUnlessDuplicate(ctx context.Context, playlistID, userID string, in trackInput, mergeDenied *httpError) (Track, bool, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Track{}, false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize adds on the same playlist, so two of them cannot both pass
+	// the duplicate check.
+	if err := lockPlaylistOrder(ctx, tx, playlistID); err != nil {
+		return Track{}, false, fmt.Errorf("lock: %w", err)
+	}
+	policy, fuzzy, err := getDuplicatePolicy(ctx, tx, playlistID)
+	if err != nil {
+		return Track{}, false, fmt.Errorf("duplicate policy: %w", err)
+	}
+	var res batchResult
+	op := batchOperation{Op: batchOpAdd, Track: &in}
+	if err := applyBatchOperation(ctx, tx, playlistID, userID, policy, fuzzy, mergeDenied, op, &res); err != nil {
+		return Track{}, false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Track{}, false, fmt.Errorf("commit: %w", err)
+	}
+	return *res.Track, res.Status == "merged", nil
+}
+
+func (p *PostgresStore) MoveTrack(ctx context.Context, playlistID, trackID string, newPos int) (int, int, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	from, to, err := moveTrackTx(ctx, tx, playlistID, trackID, newPos)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, fmt.Errorf("commit: %w", err)
+	}
+	return from, to, nil
+}
+
+func (p *PostgresStore) DeleteTrack(ctx context.Context, playlistID, trackID string) (int, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Deleting updates the playlist's track count; lock the playlist first,
+	// like playback does, so the two cannot deadlock.
+	if err := lockPlaylistOrder(ctx, tx, playlistID); err != nil {
+		return 0, fmt.Errorf("lock: %w", err)
+	}
+	pos, err := deleteTrackTx(ctx, tx, playlistID, trackID)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return pos, nil
+}
+
+func (p *PostgresStore) RemoveTracksByUser(ctx context.Context, playlistID, userID string) ([]string, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the playlist like DeleteTrack does; unlike lockPlaylistOrder this
+	// reports a missing playlist.
+	var locked bool
+	if err := tx.QueryRow(ctx, `SELECT true FROM playlists WHERE id = $1 FOR UPDATE`, playlistID).Scan(&locked); err != nil {
+		return nil, fmt.Errorf("lock: %w", err)
+	}
+	removed, err := deleteUserTracksTx(ctx, tx, playlistID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return removed, nil
+}
+
+func (p *PostgresStore) FindDuplicateTrack(ctx context.Context, playlistID, policy string, fuzzy bool, c candidateTrack) (*duplicateMatch, error) {
+	return findDuplicateTrack(ctx, p.db, playlistID, policy, fuzzy, c)
+}
+
+func (p *PostgresStore) MemberRole(ctx context.Context, playlistID, userID string) (string, error) {
+	if userID == "" {
+		return "", nil
+	}
+	var role string
+	err := p.db.QueryRow(ctx, `
+		SELECT role
+		FROM playlist_members
+		WHERE playlist_id = $1 AND user_id = $2
+	`, playlistID, userID).Scan(&role)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if role == "" {
+		// Blank means the column default.
+		role = memberRoleEditor
+	}
+	return role, nil
+}
+
+func (p *PostgresStore) ListMembers(ctx context.Context, playlistID string) ([]PlaylistInvite, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT user_id, role, created_at
+		FROM playlist_members
+		WHERE playlist_id = $1
+		ORDER BY created_at ASC
+	`, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []PlaylistInvite{}
+	for rows.Next() {
+		var m PlaylistInvite
+		if err := rows.Scan(&m.UserID, &m.Role, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (p *PostgresStore) AddMember(ctx context.Context, playlistID, userID, role string) error {
+	_, err := p.db.Exec(ctx, `
+		INSERT INTO playlist_members (playlist_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (playlist_id, user_id) DO NOTHING
+	`, playlistID, userID, role)
+	return err
+}
+
+// RemoveMember also revokes a pending invitation of the user.
+func (p *PostgresStore) RemoveMember(ctx context.Context, playlistID, userID string) error {
+	_, err := p.db.Exec(ctx, `
+		WITH revoked AS (
+			DELETE FROM playlist_invitations
+			WHERE playlist_id = $1 AND user_id = $2
+		)
+		DELETE FROM playlist_members
+		WHERE playlist_id = $1 AND user_id = $2
+	`, playlistID, userID)
+	return err
+}
+
+func (p *PostgresStore) VoteTrack(ctx context.Context, playlistID, trackID, userID string) (Track, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Track{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, _, err := applyTrackVote(ctx, tx, playlistID, trackID, userID); err != nil {
+		return Track{}, err
+	}
+	tr, err := loadTrack(ctx, tx, playlistID, trackID)
+	if err != nil {
+		return Track{}, fmt.Errorf("load track: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Track{}, fmt.Errorf("commit: %w", err)
+	}
+	return tr, nil
+}
+
+func (p *PostgresStore) SetReaction(ctx context.Context, playlistID, trackID, userID, emoji string, on bool) (map[string]int, error) {
+	var exists bool
+	err := p.db.QueryRow(ctx, `
+		SELECT true FROM tracks WHERE id = $1 AND playlist_id = $2
+	`, trackID, playlistID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if on {
+		_, err = p.db.Exec(ctx, `
+			INSERT INTO track_reactions (track_id, playlist_id, user_id, emoji)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT DO NOTHING
+		`, trackID, playlistID, userID, emoji)
+	} else {
+		_, err = p.db.Exec(ctx, `
+			DELETE FROM track_reactions
+			WHERE track_id = $1 AND user_id = $2 AND emoji = $3
+		`, trackID, userID, emoji)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("set reaction: %w", err)
+	}
+
+	rows, err := p.db.Query(ctx, `
+		SELECT emoji, COUNT(*)::int
+		FROM track_reactions
+		WHERE track_id = $1
+		GROUP BY emoji
+	`, trackID)
+	if err != nil {
+		return nil, fmt.Errorf("count reactions: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var e string
+		var n int
+		if err := rows.Scan(&e, &n); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		counts[e] = n
+	}
+	return counts, rows.Err()
+}
+
+func (p *PostgresStore) ListComments(ctx context.Context, playlistID, trackID string) ([]TrackComment, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT id, playlist_id, track_id, user_id, body, created_at
+		FROM track_comments
+		WHERE track_id = $1 AND playlist_id = $2
+		ORDER BY created_at, id
+	`, trackID, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []TrackComment{}
+	for rows.Next() {
+		var c TrackComment
+		if err := rows.Scan(&c.ID, &c.PlaylistID, &c.TrackID, &c.UserID, &c.Body, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+func (p *PostgresStore) AddComment(ctx context.Context, c *TrackComment) error {
+	return p.db.QueryRow(ctx, `
+		INSERT INTO track_comments (track_id, playlist_id, user_id, body)
+		SELECT id, playlist_id, $3, $4
+		FROM tracks
+		WHERE id = $1 AND playlist_id = $2
+		RETURNING id, created_at
+	`, c.TrackID, c.PlaylistID, c.UserID, c.Body).Scan(&c.ID, &c.CreatedAt)
+}
+
+func (p *PostgresStore) DeleteComment(ctx context.Context, playlistID, trackID, commentID string, check func(TrackComment) error) error {
+	var c TrackComment
+	err := p.db.QueryRow(ctx, `
+		SELECT id, playlist_id, track_id, user_id, body, created_at
+		FROM track_comments
+		WHERE id = $1 AND track_id = $2 AND playlist_id = $3
+	`, commentID, trackID, playlistID).Scan(&c.ID, &c.PlaylistID, &c.TrackID, &c.UserID, &c.Body, &c.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := check(c); err != nil {
+		return err
+	}
+	if _, err := p.db.Exec(ctx, `DELETE FROM track_comments WHERE id = $1`, commentID); err != nil {
+		return fmt.Errorf("delete comment: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) ModerationState(ctx context.Context, playlistID, userID string) (ModerationState, error) {
+	var st ModerationState
+	err := p.db.QueryRow(ctx, `
+		SELECT p.queue_locked_until, p.voting_locked_until,
+		       EXISTS (SELECT 1 FROM playlist_bans b WHERE b.playlist_id = p.id AND b.user_id = $2)
+		FROM playlists p
+		WHERE p.id = $1 AND p.deleted_at IS NULL
+	`, playlistID, userID).Scan(&st.QueueLockedUntil, &st.VotingLockedUntil, &st.Banned)
+	return st, err
+}
+
+func (p *PostgresStore) SetLock(ctx context.Context, playlistID, target string, until *time.Time) error {
+	column := "queue_locked_until"
+	if target == lockVoting {
+		column = "voting_locked_until"
+	}
+	tag, err := p.db.Exec(ctx, `
+		UPDATE playlists SET `+column+` = $2
+		WHERE id = $1 AND deleted_at IS NULL
+	`, playlistID, until)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) BanUser(ctx context.Context, ban *PlaylistBan) error {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO playlist_bans (playlist_id, user_id, banned_by, reason)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (playlist_id, user_id) DO UPDATE
+		SET banned_by = EXCLUDED.banned_by,
+		    reason = EXCLUDED.reason,
+		    created_at = now()
+		RETURNING created_at
+	`, ban.PlaylistID, ban.UserID, ban.BannedBy, ban.Reason).Scan(&ban.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert ban: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM playlist_members WHERE playlist_id = $1 AND user_id = $2
+	`, ban.PlaylistID, ban.UserID); err != nil {
+		return fmt.Errorf("remove member: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM playlist_invitations WHERE playlist_id = $1 AND user_id = $2
+	`, ban.PlaylistID, ban.UserID); err != nil {
+		return fmt.Errorf("remove invitation: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM playlist_delegations WHERE playlist_id = $1 AND user_id = $2
+	`, ban.PlaylistID, ban.UserID); err != nil {
+		return fmt.Errorf("remove delegation: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) UnbanUser(ctx context.Context, playlistID, userID string) error {
+	tag, err := p.db.Exec(ctx, `
+		DELETE FROM playlist_bans WHERE playlist_id = $1 AND user_id = $2
+	`, playlistID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) ListBans(ctx context.Context, playlistID string) ([]PlaylistBan, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT playlist_id, user_id, banned_by, reason, created_at
+		FROM playlist_bans
+		WHERE playlist_id = $1
+		ORDER BY created_at DESC, user_id
+	`, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := []PlaylistBan{}
+	for rows.Next() {
+		var b PlaylistBan
+		if err := rows.Scan(&b.PlaylistID, &b.UserID, &b.BannedBy, &b.Reason, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		bans = append(bans, b)
+	}
+	return bans, rows.Err()
+}
+
+const deviceColumns = `id, user_id, name, platform, capabilities, created_at, last_seen_at`
+
+func scanDevice(row pgx.Row) (Device, error) {
+	var d Device
+	err := row.Scan(&d.ID, &d.UserID, &d.Name, &d.Platform, &d.Capabilities, &d.CreatedAt, &d.LastSeenAt)
+	if d.Capabilities == nil {
+		d.Capabilities = []string{}
+	}
+	return d, err
+}
+
+func (p *PostgresStore) RegisterDevice(ctx context.Context, d *Device) error {
+	return p.db.QueryRow(ctx, `
+		INSERT INTO user_devices (user_id, name, platform, capabilities)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, last_seen_at
+	`, d.UserID, d.Name, d.Platform, d.Capabilities).Scan(&d.ID, &d.CreatedAt, &d.LastSeenAt)
+}
+
+func (p *PostgresStore) Device(ctx context.Context, deviceID string) (Device, error) {
+	return scanDevice(p.db.QueryRow(ctx, `
+		SELECT `+deviceColumns+` FROM user_devices WHERE id = $1
+	`, deviceID))
+}
+
+func (p *PostgresStore) ListDevices(ctx context.Context, userID string) ([]Device, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT `+deviceColumns+`
+		FROM user_devices
+		WHERE user_id = $1
+		ORDER BY last_seen_at DESC, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []Device{}
+	for rows.Next() {
+		d, err := scanDevice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+func (p *PostgresStore) UpdateDevice(ctx context.Context, userID, deviceID string, update func(*Device) error) (Device, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Device{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	d, err := scanDevice(tx.QueryRow(ctx, `
+		SELECT `+deviceColumns+`
+		FROM user_devices
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE
+	`, deviceID, userID))
+	if err != nil {
+		return Device{}, err
+	}
+
+	if err := update(&d); err != nil {
+		return Device{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE user_devices
+		SET name = $2, platform = $3, capabilities = $4, last_seen_at = $5
+		WHERE id = $1
+	`, d.ID, d.Name, d.Platform, d.Capabilities, d.LastSeenAt); err != nil {
+		return Device{}, fmt.Errorf("update device: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Device{}, fmt.Errorf("commit: %w", err)
+	}
+	return d, nil
+}
+
+func (p *PostgresStore) DeleteDevice(ctx context.Context, userID, deviceID string) error {
+	tag, err := p.db.Exec(ctx, `
+		DELETE FROM user_devices WHERE id = $1 AND user_id = $2
+	`, deviceID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) ActiveDevice(ctx context.Context, playlistID string) (string, error) {
+	var deviceID *string
+	err := p.db.QueryRow(ctx, `
+		SELECT ad.device_id::text
+		FROM playlists p
+		LEFT JOIN playlist_active_devices ad ON ad.playlist_id = p.id
+		WHERE p.id = $1 AND p.deleted_at IS NULL
+	`, playlistID).Scan(&deviceID)
+	if err != nil || deviceID == nil {
+		return "", err
+	}
+	return *deviceID, nil
+}
+
+func (p *PostgresStore) SetActiveDevice(ctx context.Context, playlistID, deviceID string) error {
+	if deviceID == "" {
+		_, err := p.db.Exec(ctx, `DELETE FROM playlist_active_devices WHERE playlist_id = $1`, playlistID)
+		return err
+	}
+	tag, err := p.db.Exec(ctx, `
+		INSERT INTO playlist_active_devices (playlist_id, device_id)
+		SELECT p.id, d.id
+		FROM playlists p, user_devices d
+		WHERE p.id = $1 AND p.deleted_at IS NULL AND d.id = $2
+		ON CONFLICT (playlist_id) DO UPDATE
+		SET device_id = EXCLUDED.device_id, selected_at = now()
+	`, playlistID, deviceID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) SetDelegation(ctx context.Context, d *PlaybackDelegation) error {
+	return p.db.QueryRow(ctx, `
+		INSERT INTO playlist_delegations (playlist_id, user_id, granted_by, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (playlist_id, user_id) DO UPDATE
+		SET granted_by = EXCLUDED.granted_by,
+		    expires_at = EXCLUDED.expires_at,
+		    created_at = now()
+		RETURNING created_at
+	`, d.PlaylistID, d.UserID, d.GrantedBy, d.ExpiresAt).Scan(&d.CreatedAt)
+}
+
+func (p *PostgresStore) RemoveDelegation(ctx context.Context, playlistID, userID string) error {
+	tag, err := p.db.Exec(ctx, `
+		DELETE FROM playlist_delegations WHERE playlist_id = $1 AND user_id = $2
+	`, playlistID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) ListDelegations(ctx context.Context, playlistID string, now time.Time) ([]PlaybackDelegation, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT playlist_id, user_id, granted_by, expires_at, created_at
+		FROM playlist_delegations
+		WHERE playlist_id = $1 AND (expires_at IS NULL OR expires_at > $2)
+		ORDER BY created_at DESC, user_id
+	`, playlistID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	delegations := []PlaybackDelegation{}
+	for rows.Next() {
+		var d PlaybackDelegation
+		if err := rows.Scan(&d.PlaylistID, &d.UserID, &d.GrantedBy, &d.ExpiresAt, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		delegations = append(delegations, d)
+	}
+	return delegations, rows.Err()
+}
+
+func (p *PostgresStore) HasDelegation(ctx context.Context, playlistID, userID string, now time.Time) (bool, error) {
+	var ok bool
+	err := p.db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM playlist_delegations
+			WHERE playlist_id = $1 AND user_id = $2 AND (expires_at IS NULL OR expires_at > $3)
+		)
+	`, playlistID, userID, now).Scan(&ok)
+	return ok, err
+}
+
+func (p *PostgresStore) AddModerationEntry(ctx context.Context, e *ModerationEntry) error {
+	return p.db.QueryRow(ctx, `
+		INSERT INTO playlist_moderation_log (playlist_id, actor_id, action, target_user_id, details)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, e.PlaylistID, e.ActorID, e.Action, e.TargetUserID, e.Details).Scan(&e.ID, &e.CreatedAt)
+}
+
+func (p *PostgresStore) ListModerationLog(ctx context.Context, playlistID string, limit int) ([]ModerationEntry, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT id, playlist_id, actor_id, action, target_user_id, details, created_at
+		FROM playlist_moderation_log
+		WHERE playlist_id = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2
+	`, playlistID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []ModerationEntry{}
+	for rows.Next() {
+		var e ModerationEntry
+		if err := rows.Scan(&e.ID, &e.PlaylistID, &e.ActorID, &e.Action, &e.TargetUserID, &e.Details, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (p *PostgresStore) ContentRules(ctx context.Context, playlistID string) (ContentRules, error) {
+	return getContentRules(ctx, p.db, playlistID)
+}
+
+func (p *PostgresStore) SetContentRules(ctx context.Context, playlistID string, rules ContentRules) error {
+	doc, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	tag, err := p.db.Exec(ctx, `
+		UPDATE playlists SET content_rules = $2
+		WHERE id = $1 AND deleted_at IS NULL
+	`, playlistID, doc)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) EditLicense(ctx context.Context, playlistID string) (EditLicense, error) {
+	var doc []byte
+	err := p.db.QueryRow(ctx, `
+		SELECT edit_license
+		FROM playlists
+		WHERE id = $1 AND deleted_at IS NULL
+	`, playlistID).Scan(&doc)
+	if err != nil {
+		return EditLicense{}, err
+	}
+	var license EditLicense
+	if len(doc) == 0 {
+		return license, nil
+	}
+	if err := json.Unmarshal(doc, &license); err != nil {
+		return EditLicense{}, fmt.Errorf("decode edit license: %w", err)
+	}
+	return license, nil
+}
+
+func (p *PostgresStore) SetEditLicense(ctx context.Context, playlistID string, license EditLicense) error {
+	doc, err := json.Marshal(license)
+	if err != nil {
+		return err
+	}
+	tag, err := p.db.Exec(ctx, `
+		UPDATE playlists SET edit_license = $2
+		WHERE id = $1 AND deleted_at IS NULL
+	`, playlistID, doc)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) SmartRules(ctx context.Context, playlistID string) (*SmartRules, error) {
+	var doc []byte
+	var refreshedAt *time.Time
+	err := p.db.QueryRow(ctx, `
+		SELECT smart_rules, smart_refreshed_at
+		FROM playlists
+		WHERE id = $1 AND deleted_at IS NULL
+	`, playlistID).Scan(&doc, &refreshedAt)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc) == 0 {
+		return nil, nil
+	}
+	var rules SmartRules
+	if err := json.Unmarshal(doc, &rules); err != nil {
+		return nil, fmt.Errorf("decode smart rules: %w", err)
+	}
+	rules.RefreshedAt = refreshedAt
+	return &rules, nil
+}
+
+// smartRulesDoc encodes rules for the smart_rules column without
+// RefreshedAt, which smart_refreshed_at keeps; nil stays NULL.
+func smartRulesDoc(rules *SmartRules) ([]byte, error) {
+	if rules == nil {
+		return nil, nil
+	}
+	stored := *rules
+	stored.RefreshedAt = nil
+	return json.Marshal(stored)
+}
+
+func (p *PostgresStore) SetSmartRules(ctx context.Context, playlistID string, rules *SmartRules) error {
+	doc, err := smartRulesDoc(rules)
+	if err != nil {
+		return err
+	}
+	tag, err := p.db.Exec(ctx, `
+		UPDATE playlists SET smart_rules = $2, smart_refreshed_at = NULL
+		WHERE id = $1 AND deleted_at IS NULL
+	`, playlistID, doc)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const suggestionColumns = `id, playlist_id, suggested_by, title, artist, provider, provider_track_id,
+		thumbnail_url, duration_ms, status, reason, decided_by, decided_at, track_id, created_at`
+
+func scanSuggestion(row pgx.Row) (TrackSuggestion, error) {
+	var sg TrackSuggestion
+	err := row.Scan(&sg.ID, &sg.PlaylistID, &sg.SuggestedBy, &sg.Title, &sg.Artist, &sg.Provider, &sg.ProviderTrackID,
+		&sg.ThumbnailURL, &sg.DurationMs, &sg.Status, &sg.Reason, &sg.DecidedBy, &sg.DecidedAt, &sg.TrackID, &sg.CreatedAt)
+	return sg, err
+}
+
+func (p *PostgresStore) AddSuggestion(ctx context.Context, sg *TrackSuggestion) error {
+	sg.Status = suggestionPending
+	return p.db.QueryRow(ctx, `
+		INSERT INTO playlist_suggestions (playlist_id, suggested_by, title, artist, provider, provider_track_id, thumbnail_url, duration_ms)
+		SELECT id, $2, $3, $4, $5, $6, $7, $8
+		FROM playlists
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING id, created_at
+	`, sg.PlaylistID, sg.SuggestedBy, sg.Title, sg.Artist, sg.Provider, sg.ProviderTrackID, sg.ThumbnailURL, sg.DurationMs,
+	).Scan(&sg.ID, &sg.CreatedAt)
+}
+
+func (p *PostgresStore) ListSuggestions(ctx context.Context, playlistID, status, suggestedBy string) ([]TrackSuggestion, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT `+suggestionColumns+`
+		FROM playlist_suggestions
+		WHERE playlist_id = $1
+		  AND ($2 = '' OR status = $2)
+		  AND ($3 = '' OR suggested_by = $3)
+		ORDER BY created_at, id
+	`, playlistID, status, suggestedBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []TrackSuggestion{}
+	for rows.Next() {
+		sg, err := scanSuggestion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		suggestions = append(suggestions, sg)
+	}
+	return suggestions, rows.Err()
+}
+
+func (p *PostgresStore) GetSuggestion(ctx context.Context, playlistID, suggestionID string) (TrackSuggestion, error) {
+	return scanSuggestion(p.db.QueryRow(ctx, `
+		SELECT `+suggestionColumns+`
+		FROM playlist_suggestions s
+		WHERE s.id = $1 AND s.playlist_id = $2
+		  AND EXISTS (SELECT 1 FROM playlists p WHERE p.id = s.playlist_id AND p.deleted_at IS NULL)
+	`, suggestionID, playlistID))
+}
+
+// lockPendingSuggestion locks a suggestion for a decision.
+func lockPendingSuggestion(ctx context.Context, tx pgx.Tx, playlistID, suggestionID string) (TrackSuggestion, error) {
+	sg, err := scanSuggestion(tx.QueryRow(ctx, `
+		SELECT `+suggestionColumns+`
+		FROM playlist_suggestions s
+		WHERE s.id = $1 AND s.playlist_id = $2
+		  AND EXISTS (SELECT 1 FROM playlists p WHERE p.id = s.playlist_id AND p.deleted_at IS NULL)
+		FOR UPDATE
+	`, suggestionID, playlistID))
+	if err != nil {
+		return TrackSuggestion{}, err
+	}
+	if sg.Status != suggestionPending {
+		return TrackSuggestion{}, errSuggestionDecided
+	}
+	return sg, nil
+}
+
+func (p *PostgresStore) ApproveSuggestion(ctx context.Context, playlistID, suggestionID, decidedBy string) (TrackSuggestion, Track, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return TrackSuggestion{}, Track{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sg, err := lockPendingSuggestion(ctx, tx, playlistID, suggestionID)
+	if err != nil {
+		return TrackSuggestion{}, Track{}, err
+	}
+	tr, err := insertTrack(ctx, tx, playlistID, sg.trackInput(), trackSourceUser)
+	if err != nil {
+		return TrackSuggestion{}, Track{}, fmt.Errorf("insert track: %w", err)
+	}
+	sg.Status, sg.DecidedBy, sg.TrackID = suggestionApproved, decidedBy, tr.ID
+	if err := tx.QueryRow(ctx, `
+		UPDATE playlist_suggestions
+		SET status = $2, decided_by = $3, decided_at = now(), track_id = $4
+		WHERE id = $1
+		RETURNING decided_at
+	`, suggestionID, sg.Status, decidedBy, tr.ID).Scan(&sg.DecidedAt); err != nil {
+		return TrackSuggestion{}, Track{}, fmt.Errorf("update suggestion: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return TrackSuggestion{}, Track{}, fmt.Errorf("commit: %w", err)
+	}
+	return sg, tr, nil
+}
+
+func (p *PostgresStore) RejectSuggestion(ctx context.Context, playlistID, suggestionID, decidedBy, reason string) (TrackSuggestion, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return TrackSuggestion{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sg, err := lockPendingSuggestion(ctx, tx, playlistID, suggestionID)
+	if err != nil {
+		return TrackSuggestion{}, err
+	}
+	sg.Status, sg.DecidedBy, sg.Reason = suggestionRejected, decidedBy, reason
+	if err := tx.QueryRow(ctx, `
+		UPDATE playlist_suggestions
+		SET status = $2, decided_by = $3, decided_at = now(), reason = $4
+		WHERE id = $1
+		RETURNING decided_at
+	`, suggestionID, sg.Status, decidedBy, reason).Scan(&sg.DecidedAt); err != nil {
+		return TrackSuggestion{}, fmt.Errorf("update suggestion: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return TrackSuggestion{}, fmt.Errorf("commit: %w", err)
+	}
+	return sg, nil
+}
+
+func (p *PostgresStore) TouchPresence(ctx context.Context, playlistID, userID string, at time.Time) error {
+	tag, err := p.db.Exec(ctx, `
+		INSERT INTO playlist_presence (playlist_id, user_id, seen_at)
+		SELECT id, $2, $3 FROM playlists WHERE id = $1 AND deleted_at IS NULL
+		ON CONFLICT (playlist_id, user_id) DO UPDATE
+		SET seen_at = GREATEST(playlist_presence.seen_at, EXCLUDED.seen_at)
+	`, playlistID, userID, at)
+	if err != nil {
+		return fmt.Errorf("touch presence: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) CountPresent(ctx context.Context, playlistID string, since time.Time) (int, error) {
+	var n int
+	err := p.db.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM playlist_presence pr
+		        WHERE pr.playlist_id = playlists.id AND pr.seen_at >= $2)::int
+		FROM playlists
+		WHERE id = $1 AND deleted_at IS NULL
+	`, playlistID, since).Scan(&n)
+	return n, err
+}
+
+func (p *PostgresStore) AddSkipVote(ctx context.Context, playlistID, trackID, userID string) (int, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Hold the playlist row so the track cannot stop playing before the
+	// vote is in.
+	var currentTrackID *string
+	err = tx.QueryRow(ctx, `
+		SELECT current_track_id FROM playlists
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR SHARE
+	`, playlistID).Scan(&currentTrackID)
+	if err != nil {
+		return 0, err
+	}
+	if currentTrackID == nil || *currentTrackID != trackID {
+		return 0, errPlaybackChanged
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO track_skip_votes (track_id, playlist_id, user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, trackID, playlistID, userID)
+	if err != nil {
+		return 0, fmt.Errorf("insert skip vote: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, errAlreadyVoted
+	}
+
+	var votes int
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*)::int FROM track_skip_votes WHERE track_id = $1
+	`, trackID).Scan(&votes)
+	if err != nil {
+		return 0, fmt.Errorf("count skip votes: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return votes, nil
+}
+
+func (p *PostgresStore) SkipVotes(ctx context.Context, playlistID, trackID, userID string) (int, bool, error) {
+	var votes int
+	var voted bool
+	err := p.db.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM track_skip_votes v
+		        WHERE v.track_id = $2 AND v.playlist_id = playlists.id)::int,
+		       EXISTS (SELECT 1 FROM track_skip_votes v
+		               WHERE v.track_id = $2 AND v.playlist_id = playlists.id AND v.user_id = $3)
+		FROM playlists
+		WHERE id = $1 AND deleted_at IS NULL
+	`, playlistID, trackID, userID).Scan(&votes, &voted)
+	return votes, voted, err
+}
+
+// getPlayer reads the player attached to a playlist, or nil.
+func getPlayer(ctx context.Context, q querier, playlistID string) (*PlayerState, error) {
+	var player PlayerState
+	err := q.QueryRow(ctx, `
+		SELECT player_id, user_id, track_id, position_ms, state, error, seen_at
+		FROM playlist_players
+		WHERE playlist_id = $1
+	`, playlistID).Scan(&player.PlayerID, &player.UserID, &player.TrackID, &player.PositionMs,
+		&player.State, &player.Error, &player.SeenAt)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &player, nil
+}
+
+func (p *PostgresStore) Player(ctx context.Context, playlistID string) (*PlayerState, error) {
+	var exists bool
+	err := p.db.QueryRow(ctx, `
+		SELECT true FROM playlists WHERE id = $1 AND deleted_at IS NULL
+	`, playlistID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	return getPlayer(ctx, p.db, playlistID)
+}
+
+func (p *PostgresStore) UpdatePlayer(ctx context.Context, playlistID string, update func(*PlayerState) (*PlayerState, error)) (*PlayerState, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	err = tx.QueryRow(ctx, `
+		SELECT true FROM playlists WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+	`, playlistID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := getPlayer(ctx, tx, playlistID)
+	if err != nil {
+		return nil, fmt.Errorf("get player: %w", err)
+	}
+
+	next, err := update(cur)
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		_, err = tx.Exec(ctx, `DELETE FROM playlist_players WHERE playlist_id = $1`, playlistID)
+	} else {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO playlist_players (playlist_id, player_id, user_id, track_id, position_ms, state, error, seen_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (playlist_id) DO UPDATE
+			SET player_id = EXCLUDED.player_id,
+			    user_id = EXCLUDED.user_id,
+			    track_id = EXCLUDED.track_id,
+			    position_ms = EXCLUDED.position_ms,
+			    state = EXCLUDED.state,
+			    error = EXCLUDED.error,
+			    seen_at = EXCLUDED.seen_at
+		`, playlistID, next.PlayerID, next.UserID, next.TrackID, next.PositionMs, next.State, next.Error, next.SeenAt)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("save player: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return next, nil
+}
+
+func (p *PostgresStore) AdvancePlayback(ctx context.Context, playlistID, reason, expectTrackID string, now time.Time) (PlaybackState, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return PlaybackState{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// 1. Get current state
+	var currentTrackID *string
+	if expectTrackID == "" {
+		err = tx.QueryRow(ctx, `
+			SELECT current_track_id FROM playlists
+			WHERE id = $1 AND deleted_at IS NULL
+			FOR UPDATE
+		`, playlistID).Scan(&currentTrackID)
+		if err != nil {
+			return PlaybackState{}, fmt.Errorf("get current: %w", err)
+		}
+	} else {
+		var due bool
+		var playerTrackID, playerState *string
+		var playerSeenAt *time.Time
+		err = tx.QueryRow(ctx, `
+			SELECT p.current_track_id,
+			       (p.playing_started_at + (t.duration_ms * interval '1 millisecond')) <= NOW(),
+			       pp.track_id, pp.state, pp.seen_at
+			FROM playlists p
+			JOIN tracks t ON t.id = p.current_track_id
+			LEFT JOIN playlist_players pp ON pp.playlist_id = p.id
+			WHERE p.id = $1 AND p.deleted_at IS NULL AND t.status = 'playing'
+			FOR UPDATE OF p SKIP LOCKED
+		`, playlistID).Scan(&currentTrackID, &due, &playerTrackID, &playerState, &playerSeenAt)
+		if errors.Is(err, ErrNotFound) {
+			return PlaybackState{}, errPlaybackChanged
+		}
+		if err != nil {
+			return PlaybackState{}, fmt.Errorf("claim: %w", err)
+		}
+		if currentTrackID == nil || *currentTrackID != expectTrackID {
+			return PlaybackState{}, errPlaybackChanged
+		}
+		var player *PlayerState
+		if playerSeenAt != nil {
+			player = &PlayerState{TrackID: *playerTrackID, State: *playerState, SeenAt: *playerSeenAt}
+		}
+		if reason == playEndFinished && !trackEnded(player, expectTrackID, due, time.Now()) {
+			return PlaybackState{}, errPlaybackChanged
+		}
+	}
+
+	// 2. Update old track to 'played'
+	if currentTrackID != nil {
+		_, err = tx.Exec(ctx, `UPDATE tracks SET status = 'played' WHERE id = $1`, *currentTrackID)
+		if err != nil {
+			return PlaybackState{}, fmt.Errorf("update old: %w", err)
+		}
+		if err := endPlayHistory(ctx, tx, playlistID, *currentTrackID, reason, now); err != nil {
+			return PlaybackState{}, fmt.Errorf("end history: %w", err)
+		}
+	}
+
+	// 3. Find next 'queued' track
+	next := Track{PlaylistID: playlistID, Status: "playing"}
+	err = tx.QueryRow(ctx, `
+		SELECT id, duration_ms, title, artist
+		FROM tracks
+		WHERE playlist_id = $1 AND status = 'queued' AND NOT unavailable
+		ORDER BY sort_key, id
+		LIMIT 1
+		FOR UPDATE
+	`, playlistID).Scan(&next.ID, &next.DurationMs, &next.Title, &next.Artist)
+
+	var state PlaybackState
+	if errors.Is(err, ErrNotFound) {
+		// End of playlist
+		_, err = tx.Exec(ctx, `
+			UPDATE playlists
+			SET current_track_id = NULL, playing_started_at = NULL
+			WHERE id = $1
+		`, playlistID)
+		if err != nil {
+			return PlaybackState{}, fmt.Errorf("clear playlist: %w", err)
+		}
+	} else if err != nil {
+		return PlaybackState{}, fmt.Errorf("find next: %w", err)
+	} else {
+		// Found next track
+		_, err = tx.Exec(ctx, `
+			UPDATE tracks SET status = 'playing' WHERE id = $1
+		`, next.ID)
+		if err != nil {
+			return PlaybackState{}, fmt.Errorf("set playing: %w", err)
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE playlists
+			SET current_track_id = $2, playing_started_at = $3
+			WHERE id = $1
+		`, playlistID, next.ID, now)
+		if err != nil {
+			return PlaybackState{}, fmt.Errorf("update playlist: %w", err)
+		}
+
+		if err := startPlayHistory(ctx, tx, next.ID, now); err != nil {
+			return PlaybackState{}, fmt.Errorf("start history: %w", err)
+		}
+
+		state = PlaybackState{Current: &next, StartedAt: now}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return PlaybackState{}, fmt.Errorf("commit: %w", err)
+	}
+	return state, nil
+}
+
+func (p *PostgresStore) ListPlaylists(ctx context.Context, q listPlaylistsQuery, userID string) ([]Playlist, error) {
+	sql, args := buildListPlaylistsSQL(q, userID)
+	rows, err := p.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := []Playlist{}
+	for rows.Next() {
+		var pl Playlist
+		if err := rows.Scan(
+			&pl.ID,
+			&pl.OwnerID,
+			&pl.Name,
+			&pl.Description,
+			&pl.IsPublic,
+			&pl.EditMode,
+			&pl.CreatedAt,
+			&pl.TrackCount,
+			&pl.LastActivityAt,
+			&pl.FollowerCount,
+			&pl.Visibility,
+		); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		playlists = append(playlists, pl)
+	}
+	return playlists, rows.Err()
+}
+
+func (p *PostgresStore) ApplyTrackBatch(ctx context.Context, playlistID, userID string, ops []batchOperation, mergeDenied *httpError, results []batchResult) error {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize batches (and duplicate checks) on the same playlist.
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM playlists WHERE id = $1 FOR UPDATE`, playlistID); err != nil {
+		return fmt.Errorf("lock playlist: %w", err)
+	}
+	policy, fuzzy, err := getDuplicatePolicy(ctx, tx, playlistID)
+	if err != nil {
+		return fmt.Errorf("duplicate policy: %w", err)
+	}
+
+	for i, op := range ops {
+		err := applyBatchOperation(ctx, tx, playlistID, userID, policy, fuzzy, mergeDenied, op, &results[i])
+		var he *httpError
+		if errors.As(err, &he) {
+			return failBatch(results, i, he)
+		}
+		if err != nil {
+			return fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) ClaimUnresolvedTracks(ctx context.Context, limit int, claimFor time.Duration) ([]unresolvedTrack, error) {
+	rows, err := p.db.Query(ctx, `
+		WITH claimed AS (
+			UPDATE tracks SET resolving_at = now()
+			WHERE id IN (
+				SELECT id
+				FROM tracks
+				WHERE resolved_at IS NULL AND provider <> '' AND provider_track_id <> ''
+				  AND (resolving_at IS NULL OR resolving_at <= now() - make_interval(secs => $2))
+				ORDER BY created_at DESC
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, playlist_id, provider, provider_track_id, created_at
+		)
+		SELECT id, playlist_id, provider, provider_track_id
+		FROM claimed
+		ORDER BY created_at DESC
+	`, limit, claimFor.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []unresolvedTrack
+	for rows.Next() {
+		var t unresolvedTrack
+		if err := rows.Scan(&t.ID, &t.PlaylistID, &t.Provider, &t.ProviderTrack); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		pending = append(pending, t)
+	}
+	return pending, rows.Err()
+}
+
+func (p *PostgresStore) MarkTrackResolved(ctx context.Context, playlistID, trackID string, meta *trackInput) (Track, error) {
+	var err error
+	if meta == nil {
+		_, err = p.db.Exec(ctx, `
+			UPDATE tracks SET unavailable = TRUE, resolved_at = NOW() WHERE id = $1
+		`, trackID)
+	} else {
+		_, err = p.db.Exec(ctx, `
+			UPDATE tracks
+			SET title = COALESCE(NULLIF($2, ''), title),
+			    artist = COALESCE(NULLIF($3, ''), artist),
+			    thumbnail_url = COALESCE(NULLIF($4, ''), thumbnail_url),
+			    duration_ms = CASE WHEN $5 > 0 THEN $5 ELSE duration_ms END,
+			    unavailable = FALSE,
+			    resolved_at = NOW()
+			WHERE id = $1
+		`, trackID, meta.Title, meta.Artist, meta.ThumbnailURL, meta.DurationMs)
+	}
+	if err != nil {
+		return Track{}, err
+	}
+	return loadTrack(ctx, p.db, playlistID, trackID)
+}
+
+func (p *PostgresStore) RadioSettings(ctx context.Context, playlistID string) (radioSettings, error) {
+	var rs radioSettings
+	var rulesDoc []byte
+	err := p.db.QueryRow(ctx, `
+		SELECT p.owner_id, p.radio_enabled AND p.smart_rules IS NULL, p.radio_min_queue,
+		       (SELECT COUNT(*) FROM tracks t WHERE t.playlist_id = p.id AND t.status = 'queued')::int,
+		       p.content_rules
+		FROM playlists p
+		WHERE p.id = $1 AND p.deleted_at IS NULL
+	`, playlistID).Scan(&rs.OwnerID, &rs.Enabled, &rs.MinQueue, &rs.Queued, &rulesDoc)
+	if err != nil {
+		return radioSettings{}, err
+	}
+	rs.Rules, err = decodeContentRules(rulesDoc)
+	return rs, err
+}
+
+func (p *PostgresStore) RecentArtists(ctx context.Context, playlistID string, limit int) ([]string, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT artist
+		FROM play_history
+		WHERE playlist_id = $1 AND artist <> ''
+		GROUP BY artist
+		ORDER BY MAX(started_at) DESC
+		LIMIT $2
+	`, playlistID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var artists []string
+	for rows.Next() {
+		var artist string
+		if err := rows.Scan(&artist); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		artists = append(artists, artist)
+	}
+	return artists, rows.Err()
+}
+
+func (p *PostgresStore) AddRadioTracks(ctx context.Context, playlistID string, want int, candidates []trackInput, rules ContentRules) ([]Track, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Another fill may have run meanwhile; count again under the lock.
+	if err := lockPlaylistOrder(ctx, tx, playlistID); err != nil {
+		return nil, fmt.Errorf("lock: %w", err)
+	}
+	var queued int
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*)::int FROM tracks WHERE playlist_id = $1 AND status = 'queued'
+	`, playlistID).Scan(&queued); err != nil {
+		return nil, fmt.Errorf("count queued: %w", err)
+	}
+
+	seen, err := knownTracks(ctx, tx, playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	var added []Track
+	for _, c := range pickRadioTracks(candidates, rules, seen, queued, want) {
+		tr, err := insertTrack(ctx, tx, playlistID, c, trackSourceRadio)
+		if err != nil {
+			return nil, fmt.Errorf("insert radio track: %w", err)
+		}
+		added = append(added, tr)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return added, nil
+}
+
+const invitationColumns = `i.playlist_id, p.name, i.user_id, i.invited_by, i.role, i.created_at, i.expires_at`
+
+func (p *PostgresStore) InviteMember(ctx context.Context, inv *PlaylistInvitation, ttl time.Duration) error {
+	return p.db.QueryRow(ctx, `
+		INSERT INTO playlist_invitations (playlist_id, user_id, invited_by, role, expires_at)
+		VALUES ($1, $2, $3, $4, now() + $5 * interval '1 second')
+		ON CONFLICT (playlist_id, user_id) DO UPDATE
+		SET invited_by = EXCLUDED.invited_by,
+		    role = EXCLUDED.role,
+		    created_at = now(),
+		    expires_at = EXCLUDED.expires_at
+		RETURNING invited_by, role, created_at, expires_at,
+		          (SELECT name FROM playlists WHERE id = $1)
+	`, inv.PlaylistID, inv.UserID, inv.InvitedBy, inv.Role, int(ttl.Seconds())).Scan(
+		&inv.InvitedBy,
+		&inv.Role,
+		&inv.CreatedAt,
+		&inv.ExpiresAt,
+		&inv.PlaylistName,
+	)
+}
+
+func (p *PostgresStore) ListInvitations(ctx context.Context, playlistID string) ([]PlaylistInvitation, error) {
+	return p.queryInvitations(ctx, `
+		SELECT `+invitationColumns+`
+		FROM playlist_invitations i
+		JOIN playlists p ON p.id = i.playlist_id
+		WHERE i.playlist_id = $1 AND i.expires_at > now()
+		ORDER BY i.created_at ASC
+	`, playlistID)
+}
+
+func (p *PostgresStore) ListUserInvitations(ctx context.Context, userID string) ([]PlaylistInvitation, error) {
+	return p.queryInvitations(ctx, `
+		SELECT `+invitationColumns+`
+		FROM playlist_invitations i
+		JOIN playlists p ON p.id = i.playlist_id
+		WHERE i.user_id = $1 AND i.expires_at > now() AND p.deleted_at IS NULL
+		ORDER BY i.created_at DESC
+	`, userID)
+}
+
+func (p *PostgresStore) queryInvitations(ctx context.Context, sql string, arg string) ([]PlaylistInvitation, error) {
+	rows, err := p.db.Query(ctx, sql, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []PlaylistInvitation{}
+	for rows.Next() {
+		var inv PlaylistInvitation
+		if err := rows.Scan(
+			&inv.PlaylistID,
+			&inv.PlaylistName,
+			&inv.UserID,
+			&inv.InvitedBy,
+			&inv.Role,
+			&inv.CreatedAt,
+			&inv.ExpiresAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		invites = append(invites, inv)
+	}
+	return invites, rows.Err()
+}
+
+func (p *PostgresStore) AcceptInvitation(ctx context.Context, playlistID, userID string) (PlaylistInvite, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return PlaylistInvite{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var role string
+	err = tx.QueryRow(ctx, `
+		DELETE FROM playlist_invitations i
+		USING playlists p
+		WHERE i.playlist_id = $1 AND i.user_id = $2 AND i.expires_at > now()
+		  AND p.id = i.playlist_id AND p.deleted_at IS NULL
+		RETURNING i.role
+	`, playlistID, userID).Scan(&role)
+	if err != nil {
+		return PlaylistInvite{}, err
+	}
+
+	var member PlaylistInvite
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO playlist_members (playlist_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (playlist_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		RETURNING user_id, role, created_at
+	`, playlistID, userID, role).Scan(&member.UserID, &member.Role, &member.CreatedAt); err != nil {
+		return PlaylistInvite{}, fmt.Errorf("insert member: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return PlaylistInvite{}, fmt.Errorf("commit: %w", err)
+	}
+	return member, nil
+}
+
+func (p *PostgresStore) DeclineInvitation(ctx context.Context, playlistID, userID string) error {
+	tag, err := p.db.Exec(ctx, `
+		DELETE FROM playlist_invitations
+		WHERE playlist_id = $1 AND user_id = $2 AND expires_at > now()
+	`, playlistID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) DeleteExpiredInvitations(ctx context.Context) error {
+	_, err := p.db.Exec(ctx, `DELETE FROM playlist_invitations WHERE expires_at <= now()`)
+	return err
+}
+
+const joinLinkColumns = `code, playlist_id, created_by, role, max_uses, uses, expires_at, revoked_at, created_at`
+
+func scanJoinLink(row pgx.Row) (JoinLink, error) {
+	var l JoinLink
+	err := row.Scan(
+		&l.Code,
+		&l.PlaylistID,
+		&l.CreatedBy,
+		&l.Role,
+		&l.MaxUses,
+		&l.Uses,
+		&l.ExpiresAt,
+		&l.RevokedAt,
+		&l.CreatedAt,
+	)
+	return l, err
+}
+
+func (p *PostgresStore) CreateJoinLink(ctx context.Context, l *JoinLink) error {
+	var err error
+	for attempt := 0; attempt < joinCodeAttempts; attempt++ {
+		var code string
+		code, err = newJoinCode()
+		if err != nil {
+			return err
+		}
+		var link JoinLink
+		link, err = scanJoinLink(p.db.QueryRow(ctx, `
+			INSERT INTO playlist_join_links (code, playlist_id, created_by, role, max_uses, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+joinLinkColumns,
+			code, l.PlaylistID, l.CreatedBy, l.Role, l.MaxUses, l.ExpiresAt,
+		))
+		if err == nil {
+			*l = link
+			return nil
+		}
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+			break
+		}
+	}
+	return err
+}
+
+func (p *PostgresStore) ListJoinLinks(ctx context.Context, playlistID string) ([]JoinLink, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT `+joinLinkColumns+`
+		FROM playlist_join_links
+		WHERE playlist_id = $1
+		ORDER BY created_at DESC
+	`, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []JoinLink{}
+	for rows.Next() {
+		l, err := scanJoinLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+func (p *PostgresStore) RevokeJoinLink(ctx context.Context, playlistID, code string) error {
+	tag, err := p.db.Exec(ctx, `
+		UPDATE playlist_join_links
+		SET revoked_at = COALESCE(revoked_at, now())
+		WHERE playlist_id = $1 AND code = $2
+	`, playlistID, code)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) RedeemJoinLink(ctx context.Context, code, userID string) (JoinResult, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return JoinResult{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	res := JoinResult{Type: "playlist"}
+	var ownerID, memberRole string
+	var banned bool
+	err = tx.QueryRow(ctx, `
+		SELECT l.playlist_id, p.owner_id, COALESCE(m.role, ''),
+		       EXISTS (SELECT 1 FROM playlist_bans b WHERE b.playlist_id = l.playlist_id AND b.user_id = $2)
+		FROM playlist_join_links l
+		JOIN playlists p ON p.id = l.playlist_id
+		LEFT JOIN playlist_members m ON m.playlist_id = l.playlist_id AND m.user_id = $2
+		WHERE l.code = $1
+		  AND p.deleted_at IS NULL
+		  AND l.revoked_at IS NULL
+		  AND (l.expires_at IS NULL OR l.expires_at > now())
+		  AND (l.max_uses IS NULL OR l.uses < l.max_uses)
+	`, code, userID).Scan(&res.ID, &ownerID, &memberRole, &banned)
+	if err != nil {
+		return JoinResult{}, err
+	}
+
+	switch {
+	case userID == ownerID:
+		res.Role = "owner"
+		return res, nil
+	case memberRole != "":
+		res.Role = memberRole
+		return res, nil
+	case banned:
+		return JoinResult{}, errBannedFromPlaylist
+	}
+
+	// The use is counted with the same guards, so concurrent redemptions
+	// cannot exceed max_uses.
+	err = tx.QueryRow(ctx, `
+		UPDATE playlist_join_links
+		SET uses = uses + 1
+		WHERE code = $1
+		  AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > now())
+		  AND (max_uses IS NULL OR uses < max_uses)
+		RETURNING role
+	`, code).Scan(&res.Role)
+	if err != nil {
+		return JoinResult{}, err
+	}
+
+	// A pending invitation is answered by joining.
+	if _, err := tx.Exec(ctx, `
+		WITH answered AS (
+			DELETE FROM playlist_invitations
+			WHERE playlist_id = $1 AND user_id = $2
+		)
+		INSERT INTO playlist_members (playlist_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (playlist_id, user_id) DO NOTHING
+	`, res.ID, userID, res.Role); err != nil {
+		return JoinResult{}, fmt.Errorf("insert member: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return JoinResult{}, fmt.Errorf("commit: %w", err)
+	}
+	res.Joined = true
+	return res, nil
+}
+
+// followedVisible restricts playlist_follows f (joined to playlists p) to
+// playlists the follower can still see. A playlist that turned private drops
+// out for followers who are not members, a trashed one for everyone.
+// Friends-only playlists count as private here, since friendships live in
+// user-service: friends see them once they joined.
+const followedVisible = `p.deleted_at IS NULL AND (p.is_public OR p.owner_id = f.user_id OR EXISTS (
+		SELECT 1 FROM playlist_members m WHERE m.playlist_id = p.id AND m.user_id = f.user_id
+	))`
+
+func (p *PostgresStore) SetFollow(ctx context.Context, playlistID, userID string, on bool) (bool, error) {
+	sql := `
+		DELETE FROM playlist_follows
+		WHERE playlist_id = $1 AND user_id = $2
+	`
+	if on {
+		sql = `
+			INSERT INTO playlist_follows (playlist_id, user_id)
+			VALUES ($1, $2)
+			ON CONFLICT (playlist_id, user_id) DO NOTHING
+		`
+	}
+	tag, err := p.db.Exec(ctx, sql, playlistID, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (p *PostgresStore) FollowerCount(ctx context.Context, playlistID string) (int, error) {
+	var count int
+	err := p.db.QueryRow(ctx, `
+		SELECT COUNT(*)::int FROM playlist_follows WHERE playlist_id = $1
+	`, playlistID).Scan(&count)
+	return count, err
+}
+
+func (p *PostgresStore) ListFollowedPlaylists(ctx context.Context, userID string) ([]Playlist, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT p.id, p.owner_id, p.name, p.description, p.is_public, p.edit_mode, p.created_at,
+		       (SELECT COUNT(*) FROM tracks t WHERE t.playlist_id = p.id)::int,
+		       (SELECT COUNT(*) FROM playlist_follows c WHERE c.playlist_id = p.id)::int
+		FROM playlist_follows f
+		JOIN playlists p ON p.id = f.playlist_id
+		WHERE f.user_id = $1 AND `+followedVisible+`
+		ORDER BY f.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := []Playlist{}
+	for rows.Next() {
+		var pl Playlist
+		if err := rows.Scan(
+			&pl.ID,
+			&pl.OwnerID,
+			&pl.Name,
+			&pl.Description,
+			&pl.IsPublic,
+			&pl.EditMode,
+			&pl.CreatedAt,
+			&pl.TrackCount,
+			&pl.FollowerCount,
+		); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		playlists = append(playlists, pl)
+	}
+	return playlists, rows.Err()
+}
+
+func (p *PostgresStore) Feed(ctx context.Context, userID string, before time.Time, limit int) ([]FeedItem, error) {
+	rows, err := p.db.Query(ctx, `
+		WITH followed AS (
+			SELECT p.id, p.name
+			FROM playlist_follows f
+			JOIN playlists p ON p.id = f.playlist_id
+			WHERE f.user_id = $1 AND `+followedVisible+`
+		)
+		SELECT type, playlist_id, playlist_name, track_id, title, artist, at
+		FROM (
+			SELECT 'track.added' AS type, fl.id AS playlist_id, fl.name AS playlist_name,
+			       t.id::text AS track_id, t.title, t.artist, t.created_at AS at
+			FROM tracks t
+			JOIN followed fl ON fl.id = t.playlist_id
+			WHERE t.source = 'user' AND t.created_at < $2
+			UNION ALL
+			SELECT 'playback.started', fl.id, fl.name,
+			       COALESCE(h.track_id::text, ''), h.title, h.artist, h.started_at
+			FROM play_history h
+			JOIN followed fl ON fl.id = h.playlist_id
+			WHERE h.started_at < $2
+		) items
+		ORDER BY at DESC
+		LIMIT $3
+	`, userID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FeedItem{}
+	for rows.Next() {
+		var it FeedItem
+		if err := rows.Scan(
+			&it.Type,
+			&it.PlaylistID,
+			&it.PlaylistName,
+			&it.TrackID,
+			&it.Title,
+			&it.Artist,
+			&it.At,
+		); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+func (p *PostgresStore) Followers(ctx context.Context, playlistID string) (string, []string, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT f.user_id, p.name
+		FROM playlist_follows f
+		JOIN playlists p ON p.id = f.playlist_id
+		WHERE f.playlist_id = $1 AND `+followedVisible+`
+	`, playlistID)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	var name string
+	var followerIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id, &name); err != nil {
+			return "", nil, fmt.Errorf("scan: %w", err)
+		}
+		followerIDs = append(followerIDs, id)
+	}
+	return name, followerIDs, rows.Err()
+}
+
+const playingTracksSQL = `
+	SELECT p.id, t.id,
+	       CASE WHEN pp.seen_at > NOW() - make_interval(secs => $1)
+	            THEN GREATEST(p.playing_started_at + (t.duration_ms * interval '1 millisecond'),
+	                          pp.seen_at + make_interval(secs => $1))
+	            ELSE p.playing_started_at + (t.duration_ms * interval '1 millisecond')
+	       END
+	FROM playlists p
+	JOIN tracks t ON t.id = p.current_track_id
+	LEFT JOIN playlist_players pp ON pp.playlist_id = p.id
+	WHERE p.playing_started_at IS NOT NULL
+	  AND t.status = 'playing'
+	  AND t.duration_ms > 0`
+
+func (p *PostgresStore) PlayingTracks(ctx context.Context, playlistID string, heartbeatTimeout time.Duration) ([]playingTrack, error) {
+	sql, args := playingTracksSQL, []any{heartbeatTimeout.Seconds()}
+	if playlistID != "" {
+		sql += " AND p.id = $2"
+		args = append(args, playlistID)
+	}
+
+	rows, err := p.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []playingTrack
+	for rows.Next() {
+		var pt playingTrack
+		if err := rows.Scan(&pt.PlaylistID, &pt.TrackID, &pt.EndsAt); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		out = append(out, pt)
+	}
+	return out, rows.Err()
+}
+
+func (p *PostgresStore) ListPlayHistory(ctx context.Context, playlistID string, before *time.Time, limit int) ([]PlayHistoryEntry, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT id, playlist_id, track_id, title, artist, provider, provider_track_id,
+		       duration_ms, vote_count, started_at, ended_at, end_reason
+		FROM play_history
+		WHERE playlist_id = $1
+		  AND ($2::timestamptz IS NULL OR started_at < $2)
+		ORDER BY started_at DESC, id DESC
+		LIMIT $3
+	`, playlistID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []PlayHistoryEntry{}
+	for rows.Next() {
+		var e PlayHistoryEntry
+		if err := rows.Scan(
+			&e.ID,
+			&e.PlaylistID,
+			&e.TrackID,
+			&e.Title,
+			&e.Artist,
+			&e.Provider,
+			&e.ProviderTrackID,
+			&e.DurationMs,
+			&e.VoteCount,
+			&e.StartedAt,
+			&e.EndedAt,
+			&e.EndReason,
+		); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (p *PostgresStore) PlayStats(ctx context.Context, playlistID string, since, until *time.Time, topArtists int) (PlayStats, error) {
+	stats := PlayStats{TopArtists: []ArtistPlayStat{}}
+	err := p.db.QueryRow(ctx, `
+		SELECT COUNT(*)::int,
+		       (COUNT(*) FILTER (WHERE end_reason = 'finished'))::int,
+		       (COUNT(*) FILTER (WHERE end_reason = 'skipped'))::int,
+		       COALESCE(AVG(vote_count), 0)::float8,
+		       COALESCE(SUM(EXTRACT(EPOCH FROM (ended_at - started_at)) * 1000), 0)::bigint
+		FROM play_history
+		WHERE playlist_id = $1
+		  AND ($2::timestamptz IS NULL OR started_at >= $2)
+		  AND ($3::timestamptz IS NULL OR started_at < $3)
+	`, playlistID, since, until).Scan(
+		&stats.TotalPlays,
+		&stats.Finished,
+		&stats.Skipped,
+		&stats.AverageVotes,
+		&stats.TotalPlayMs,
+	)
+	if err != nil {
+		return PlayStats{}, err
+	}
+
+	rows, err := p.db.Query(ctx, `
+		SELECT artist, COUNT(*)::int AS plays
+		FROM play_history
+		WHERE playlist_id = $1
+		  AND ($2::timestamptz IS NULL OR started_at >= $2)
+		  AND ($3::timestamptz IS NULL OR started_at < $3)
+		GROUP BY artist
+		ORDER BY plays DESC, artist ASC
+		LIMIT $4
+	`, playlistID, since, until, topArtists)
+	if err != nil {
+		return PlayStats{}, fmt.Errorf("top artists: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a ArtistPlayStat
+		if err := rows.Scan(&a.Artist, &a.Plays); err != nil {
+			return PlayStats{}, fmt.Errorf("scan top artist: %w", err)
+		}
+		stats.TopArtists = append(stats.TopArtists, a)
+	}
+	return stats, rows.Err()
+}
+
+func (p *PostgresStore) PlayedTracks(ctx context.Context, ownerID, playlistID string, days, minVotes, limit int) ([]trackInput, error) {
+	return queryTrackInputs(ctx, p.db, `
+		SELECT h.title, h.artist, h.provider, h.provider_track_id,
+		       COALESCE(MAX(t.thumbnail_url), ''), MAX(h.duration_ms)
+		FROM play_history h
+		JOIN playlists p ON p.id = h.playlist_id
+		LEFT JOIN tracks t ON t.id = h.track_id
+		WHERE p.owner_id = $1 AND p.id <> $2 AND p.deleted_at IS NULL
+		  AND h.started_at > now() - make_interval(days => $3)
+		  AND h.vote_count >= $4
+		GROUP BY h.title, h.artist, h.provider, h.provider_track_id
+		ORDER BY MAX(h.vote_count) DESC, COUNT(*) DESC, MAX(h.started_at) DESC
+		LIMIT $5
+	`, ownerID, playlistID, days, minVotes, limit)
+}
+
+func (p *PostgresStore) FollowedTracks(ctx context.Context, userID, playlistID string, minVotes, limit int) ([]trackInput, error) {
+	return queryTrackInputs(ctx, p.db, `
+		SELECT t.title, t.artist, t.provider, t.provider_track_id, t.thumbnail_url, t.duration_ms
+		FROM playlist_follows f
+		JOIN playlists p ON p.id = f.playlist_id
+		JOIN tracks t ON t.playlist_id = p.id
+		WHERE f.user_id = $1 AND p.id <> $2 AND `+followedVisible+`
+		  AND t.vote_count >= $3
+		ORDER BY t.vote_count DESC, t.created_at DESC
+		LIMIT $4
+	`, userID, playlistID, minVotes, limit)
+}
+
+// queryTrackInputs scans title, artist, provider, provider ID, thumbnail and
+// duration rows.
+func queryTrackInputs(ctx context.Context, q querier, sql string, args ...any) ([]trackInput, error) {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []trackInput
+	for rows.Next() {
+		var in trackInput
+		if err := rows.Scan(&in.Title, &in.Artist, &in.Provider, &in.ProviderTrack, &in.ThumbnailURL, &in.DurationMs); err != nil {
+			return nil, err
+		}
+		out = append(out, in)
+	}
+	return out, rows.Err()
+}
+
+func (p *PostgresStore) ReplaceSmartQueue(ctx context.Context, playlistID string, limit int, candidates []trackInput, rules ContentRules) (smartQueueUpdate, error) {
+	tx, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return smartQueueUpdate{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockPlaylistOrder(ctx, tx, playlistID); err != nil {
+		return smartQueueUpdate{}, fmt.Errorf("lock: %w", err)
+	}
+	// The playlist may have been converted meanwhile.
+	var smart bool
+	if err := tx.QueryRow(ctx, `
+		SELECT smart_rules IS NOT NULL FROM playlists WHERE id = $1
+	`, playlistID).Scan(&smart); err != nil {
+		return smartQueueUpdate{}, fmt.Errorf("smart check: %w", err)
+	}
+	if !smart {
+		return smartQueueUpdate{}, errNotSmart
+	}
+
+	seen := make(map[string]bool)
+	var playing trackInput
+	err = tx.QueryRow(ctx, `
+		SELECT provider, provider_track_id, title, artist
+		FROM tracks
+		WHERE playlist_id = $1 AND status = 'playing'
+	`, playlistID).Scan(&playing.Provider, &playing.ProviderTrack, &playing.Title, &playing.Artist)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return smartQueueUpdate{}, fmt.Errorf("playing track: %w", err)
+	}
+	if err == nil {
+		for _, key := range smartTrackKeys(playing) {
+			seen[key] = true
+		}
+	}
+
+	var update smartQueueUpdate
+	rows, err := tx.Query(ctx, `
+		SELECT id, position
+		FROM `+rankedTracks("$1")+`
+		WHERE status = 'queued'
+		ORDER BY position DESC
+	`, playlistID)
+	if err != nil {
+		return smartQueueUpdate{}, fmt.Errorf("queued tracks: %w", err)
+	}
+	for rows.Next() {
+		var rm removedTrack
+		if err := rows.Scan(&rm.ID, &rm.Position); err != nil {
+			rows.Close()
+			return smartQueueUpdate{}, fmt.Errorf("scan queued track: %w", err)
+		}
+		update.Removed = append(update.Removed, rm)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return smartQueueUpdate{}, fmt.Errorf("queued tracks: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM tracks WHERE playlist_id = $1 AND status = 'queued'`, playlistID); err != nil {
+		return smartQueueUpdate{}, fmt.Errorf("clear queue: %w", err)
+	}
+
+	for _, c := range pickSmartTracks(candidates, rules, seen, limit) {
+		tr, err := insertTrack(ctx, tx, playlistID, c, trackSourceSmart)
+		if err != nil {
+			return smartQueueUpdate{}, fmt.Errorf("insert smart track: %w", err)
+		}
+		update.Added = append(update.Added, tr)
+	}
+
+	if err := tx.QueryRow(ctx, `
+		UPDATE playlists SET smart_refreshed_at = now() WHERE id = $1 RETURNING smart_refreshed_at
+	`, playlistID).Scan(&update.RefreshedAt); err != nil {
+		return smartQueueUpdate{}, fmt.Errorf("mark refreshed: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return smartQueueUpdate{}, fmt.Errorf("commit: %w", err)
+	}
+	return update, nil
+}
+
+func (p *PostgresStore) ClaimDueSmartPlaylists(ctx context.Context, limit int) ([]string, error) {
+	rows, err := p.db.Query(ctx, `
+		UPDATE playlists SET smart_refreshed_at = now()
+		WHERE id IN (
+			SELECT id
+			FROM playlists
+			WHERE smart_rules IS NOT NULL AND deleted_at IS NULL
+			  AND COALESCE((smart_rules->>'refreshHours')::int, 0) > 0
+			  AND (smart_refreshed_at IS NULL
+			       OR smart_refreshed_at < now() - make_interval(hours => (smart_rules->>'refreshHours')::int))
+			ORDER BY smart_refreshed_at NULLS FIRST
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,398 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// In a "suggest" playlist, users who may see the playlist but not edit it
+// suggest tracks instead of adding them. Suggestions wait in an inbox until
+// the owner, a co-owner or an editor approves one, queueing it as a track
+// added by the suggester, or rejects it with a reason. Suggesters follow the
+// outcome through the suggestion.approved and suggestion.rejected realtime
+// events, whose payload carries their userId.
+
+const (
+	maxPendingSuggestions     = 10 // per user and playlist
+	maxSuggestionReasonLength = 500
+)
+
+var errSuggestionDecided = errors.New("suggestion already decided")
+
+// trackInput is the track an approved suggestion queues.
+func (sg TrackSuggestion) trackInput() trackInput {
+	return trackInput{
+		Title:         sg.Title,
+		Artist:        sg.Artist,
+		Provider:      sg.Provider,
+		ProviderTrack: sg.ProviderTrackID,
+		ThumbnailURL:  sg.ThumbnailURL,
+		DurationMs:    sg.DurationMs,
+		addedBy:       sg.SuggestedBy,
+	}
+}
+
+// suggestionAccess is what a user may do with the suggestions of a
+// playlist.
+type suggestionAccess struct {
+	ownerID  string
+	editMode string
+	role     string
+	reviewer bool // owner, co-owner or editor
+}
+
+// checkSuggestionAccess applies the playlist visibility rule and tells
+// whether userID reviews suggestions. Denials are returned as *httpError.
+func (s *Server) checkSuggestionAccess(ctx context.Context, playlistID, userID string) (suggestionAccess, error) {
+	ownerID, isPublic, editMode, err := s.getPlaylistAccessInfo(ctx, playlistID)
+	if errors.Is(err, ErrNotFound) {
+		return suggestionAccess{}, &httpError{status: http.StatusNotFound, msg: "playlist not found"}
+	}
+	if err != nil {
+		return suggestionAccess{}, err
+	}
+	acc := suggestionAccess{ownerID: ownerID, editMode: editMode, reviewer: userID == ownerID}
+	if userID == ownerID {
+		return acc, nil
+	}
+	acc.role, err = s.memberRole(ctx, playlistID, userID)
+	if err != nil {
+		return suggestionAccess{}, err
+	}
+	if !isPublic && acc.role == "" {
+		return suggestionAccess{}, &httpError{status: http.StatusForbidden, msg: "forbidden"}
+	}
+	acc.reviewer = roleCanEdit(acc.role)
+	return acc, nil
+}
+
+// handleCreateSuggestion suggests a track for a "suggest" playlist. The track
+// is checked like an added one: metadata lookup and content rules.
+// POST /playlists/{id}/suggestions
+// Body: AddTrackRequest
+func (s *Server) handleCreateSuggestion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	acc, err := s.checkSuggestionAccess(ctx, playlistID, userID)
+	if err != nil {
+		writeHTTPError(w, err, "create suggestion access")
+		return
+	}
+	if acc.editMode != editModeSuggest {
+		writeError(w, http.StatusForbidden, "this playlist does not take suggestions")
+		return
+	}
+	if err := s.checkRestrictions(ctx, playlistID, userID, isModerator(userID, acc.ownerID, acc.role), lockQueue); err != nil {
+		writeHTTPError(w, err, "create suggestion restrictions")
+		return
+	}
+	if err := s.checkNotSmart(ctx, playlistID); err != nil {
+		writeHTTPError(w, err, "create suggestion smart check")
+		return
+	}
+
+	var body trackInput
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if msg := body.normalize(); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if err := s.resolveTrack(ctx, &body); err != nil {
+		writeHTTPError(w, err, "create suggestion resolve")
+		return
+	}
+
+	// Suggestions cannot override the rules; an editor decides on approval.
+	body.OverrideRules = false
+	rules, err := s.store.ContentRules(ctx, playlistID)
+	if err != nil {
+		writeHTTPError(w, err, "create suggestion content rules")
+		return
+	}
+	if err := checkContentRules(rules, body, false); err != nil {
+		writeHTTPError(w, err, "create suggestion content rules")
+		return
+	}
+
+	pending, err := s.store.ListSuggestions(ctx, playlistID, suggestionPending, userID)
+	if err != nil {
+		writeHTTPError(w, err, "create suggestion count pending")
+		return
+	}
+	if len(pending) >= maxPendingSuggestions {
+		writeError(w, http.StatusTooManyRequests,
+			fmt.Sprintf("you already have %d suggestions waiting for review", len(pending)))
+		return
+	}
+
+	sg := TrackSuggestion{
+		PlaylistID:      playlistID,
+		SuggestedBy:     userID,
+		Title:           body.Title,
+		Artist:          body.Artist,
+		Provider:        body.Provider,
+		ProviderTrackID: body.ProviderTrack,
+		ThumbnailURL:    body.ThumbnailURL,
+		DurationMs:      body.DurationMs,
+	}
+	if err := s.store.AddSuggestion(ctx, &sg); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, "playlist not found")
+			return
+		}
+		writeHTTPError(w, err, "create suggestion")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "suggestion.created",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"suggestion": sg,
+		},
+	})
+
+	writeJSON(w, http.StatusCreated, sg)
+}
+
+// handleListSuggestions lists the suggestions of a playlist, oldest first.
+// Reviewers see everyone's; other users only their own.
+// GET /playlists/{id}/suggestions?status=pending|approved|rejected|all
+func (s *Server) handleListSuggestions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	status := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("status")))
+	switch status {
+	case "":
+		status = suggestionPending
+	case "all":
+		status = ""
+	case suggestionPending, suggestionApproved, suggestionRejected:
+	default:
+		writeError(w, http.StatusBadRequest, `invalid status (must be "pending", "approved", "rejected" or "all")`)
+		return
+	}
+
+	acc, err := s.checkSuggestionAccess(ctx, playlistID, userID)
+	if err != nil {
+		writeHTTPError(w, err, "list suggestions access")
+		return
+	}
+	suggestedBy := ""
+	if !acc.reviewer {
+		suggestedBy = userID
+	}
+
+	suggestions, err := s.store.ListSuggestions(ctx, playlistID, status, suggestedBy)
+	if err != nil {
+		writeHTTPError(w, err, "list suggestions")
+		return
+	}
+	writeJSON(w, http.StatusOK, suggestions)
+}
+
+// startSuggestionReview runs the checks shared by approving and rejecting:
+// reviewer access, restrictions and a pending suggestion. It writes the
+// error response itself and returns ok=false on failure.
+func (s *Server) startSuggestionReview(w http.ResponseWriter, r *http.Request) (sg TrackSuggestion, acc suggestionAccess, ok bool) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return sg, acc, false
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	acc, err := s.checkSuggestionAccess(ctx, playlistID, userID)
+	if err != nil {
+		writeHTTPError(w, err, "review suggestion access")
+		return sg, acc, false
+	}
+	if !acc.reviewer {
+		writeError(w, http.StatusForbidden, "only the owner and editors can review suggestions")
+		return sg, acc, false
+	}
+	if err := s.checkRestrictions(ctx, playlistID, userID, isModerator(userID, acc.ownerID, acc.role), lockQueue); err != nil {
+		writeHTTPError(w, err, "review suggestion restrictions")
+		return sg, acc, false
+	}
+
+	sg, err = s.store.GetSuggestion(ctx, playlistID, chi.URLParam(r, "suggestionId"))
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "suggestion not found")
+		return sg, acc, false
+	}
+	if err != nil {
+		writeHTTPError(w, err, "get suggestion")
+		return sg, acc, false
+	}
+	if sg.Status != suggestionPending {
+		writeError(w, http.StatusConflict, "suggestion was already "+sg.Status)
+		return sg, acc, false
+	}
+	return sg, acc, true
+}
+
+// writeDecisionError maps the errors of deciding a suggestion.
+func writeDecisionError(w http.ResponseWriter, err error, op string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, "suggestion not found")
+	case errors.Is(err, errSuggestionDecided):
+		writeError(w, http.StatusConflict, "suggestion was already decided")
+	default:
+		writeHTTPError(w, err, op)
+	}
+}
+
+// handleApproveSuggestion queues a suggested track. Content rules and the
+// duplicate policy apply as if the suggester added it; moderators may
+// override the content rules.
+// POST /playlists/{id}/suggestions/{suggestionId}/approve
+// Body: {"overrideRules": false} (optional)
+func (s *Server) handleApproveSuggestion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	playlistID := chi.URLParam(r, "id")
+
+	var body struct {
+		OverrideRules bool `json:"overrideRules"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+	}
+
+	sg, acc, ok := s.startSuggestionReview(w, r)
+	if !ok {
+		return
+	}
+	if err := s.checkNotSmart(ctx, playlistID); err != nil {
+		writeHTTPError(w, err, "approve suggestion smart check")
+		return
+	}
+
+	in := sg.trackInput()
+	in.OverrideRules = body.OverrideRules
+	rules, err := s.store.ContentRules(ctx, playlistID)
+	if err != nil {
+		writeHTTPError(w, err, "approve suggestion content rules")
+		return
+	}
+	if err := checkContentRules(rules, in, isModerator(userID, acc.ownerID, acc.role)); err != nil {
+		writeHTTPError(w, err, "approve suggestion content rules")
+		return
+	}
+
+	policy, fuzzy, err := s.store.DuplicatePolicy(ctx, playlistID)
+	if err != nil {
+		writeHTTPError(w, err, "approve suggestion duplicate policy")
+		return
+	}
+	dup, err := s.store.FindDuplicateTrack(ctx, playlistID, policy, fuzzy, in.candidate())
+	if err != nil {
+		writeHTTPError(w, err, "approve suggestion duplicate check")
+		return
+	}
+	if dup != nil {
+		writeError(w, http.StatusConflict, duplicateRejectReason(policy, dup.Status))
+		return
+	}
+
+	sg, tr, err := s.store.ApproveSuggestion(ctx, playlistID, sg.ID, userID)
+	if err != nil {
+		writeDecisionError(w, err, "approve suggestion")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "track.added",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"track":      tr,
+		},
+	})
+	s.notifyFollowers(ctx, trackAddedItem(tr))
+	s.publishEvent(ctx, map[string]any{
+		"type": "suggestion.approved",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"userId":     sg.SuggestedBy,
+			"suggestion": sg,
+		},
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"suggestion": sg,
+		"track":      tr,
+	})
+}
+
+// handleRejectSuggestion turns a suggestion down.
+// POST /playlists/{id}/suggestions/{suggestionId}/reject
+// Body: {"reason": "optional"}
+func (s *Server) handleRejectSuggestion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	playlistID := chi.URLParam(r, "id")
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if len(body.Reason) > maxSuggestionReasonLength {
+		writeError(w, http.StatusBadRequest, "reason is too long")
+		return
+	}
+
+	sg, _, ok := s.startSuggestionReview(w, r)
+	if !ok {
+		return
+	}
+
+	sg, err := s.store.RejectSuggestion(ctx, playlistID, sg.ID, userID, body.Reason)
+	if err != nil {
+		writeDecisionError(w, err, "reject suggestion")
+		return
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type": "suggestion.rejected",
+		"payload": map[string]any{
+			"playlistId": playlistID,
+			"userId":     sg.SuggestedBy,
+			"suggestion": sg,
+		},
+	})
+
+	writeJSON(w, http.StatusOK, sg)
+}
@@ -0,0 +1,205 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// newSuggestionTestServer returns a memory-backed server with a public
+// "suggest" playlist owned by owner-1, with ed-1 as editor and view-1 as
+// viewer.
+func newSuggestionTestServer(t *testing.T) (http.Handler, Playlist) {
+	t.Helper()
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	pl := Playlist{OwnerID: "owner-1", Name: "Bar", IsPublic: true, EditMode: editModeSuggest}
+	if err := store.CreatePlaylist(ctx, &pl, nil); err != nil {
+		t.Fatal(err)
+	}
+	store.AddMember(ctx, pl.ID, "ed-1", memberRoleEditor)
+	store.AddMember(ctx, pl.ID, "view-1", memberRoleViewer)
+	return NewServerWithStore(store, nil).Router(), pl
+}
+
+func suggest(t *testing.T, h http.Handler, userID, playlistID, title string) TrackSuggestion {
+	t.Helper()
+	w := serveAs(h, userID, "POST", "/playlists/"+playlistID+"/suggestions", map[string]string{"title": title, "artist": "Band"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("suggest %q: got %d. Body: %s", title, w.Code, w.Body.String())
+	}
+	var sg TrackSuggestion
+	_ = json.NewDecoder(w.Body).Decode(&sg)
+	return sg
+}
+
+func TestHandleCreateSuggestion(t *testing.T) {
+	h, pl := newSuggestionTestServer(t)
+	base := "/playlists/" + pl.ID
+	track := map[string]string{"title": "Song", "artist": "Band"}
+
+	// Non-editors cannot add directly, but may suggest.
+	w := serveAs(h, "view-1", "POST", base+"/tracks", track)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("viewer adding a track: got %d", w.Code)
+	}
+	if w := serveAs(h, "ed-1", "POST", base+"/tracks", track); w.Code != http.StatusCreated {
+		t.Errorf("editor adding a track: got %d", w.Code)
+	}
+
+	sg := suggest(t, h, "guest", pl.ID, "Song")
+	if sg.Status != suggestionPending || sg.SuggestedBy != "guest" || sg.Artist != "Band" {
+		t.Errorf("suggestion = %+v", sg)
+	}
+
+	if w := serveAs(h, "", "POST", base+"/suggestions", track); w.Code != http.StatusUnauthorized {
+		t.Errorf("no user: got %d", w.Code)
+	}
+	if w := serveAs(h, "guest", "POST", base+"/suggestions", map[string]string{"title": ""}); w.Code != http.StatusBadRequest {
+		t.Errorf("empty title: got %d", w.Code)
+	}
+	if w := serveAs(h, "guest", "POST", "/playlists/"+missingID+"/suggestions", track); w.Code != http.StatusNotFound {
+		t.Errorf("missing playlist: got %d", w.Code)
+	}
+
+	serveAs(h, "owner-1", "PUT", base+"/content-rules", ContentRules{BlockedArtists: []string{"Band"}})
+	if w := serveAs(h, "guest", "POST", base+"/suggestions", track); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("suggestion breaking the content rules: got %d", w.Code)
+	}
+	serveAs(h, "owner-1", "PUT", base+"/content-rules", ContentRules{})
+
+	for i := 1; i < maxPendingSuggestions; i++ {
+		suggest(t, h, "guest", pl.ID, fmt.Sprintf("Song %d", i))
+	}
+	if w := serveAs(h, "guest", "POST", base+"/suggestions", track); w.Code != http.StatusTooManyRequests {
+		t.Errorf("too many pending suggestions: got %d", w.Code)
+	}
+
+	if w := serveAs(h, "owner-1", "PATCH", base, map[string]string{"editMode": editModeInvited}); w.Code != http.StatusOK {
+		t.Fatalf("switch to invited: got %d", w.Code)
+	}
+	if w := serveAs(h, "other", "POST", base+"/suggestions", track); w.Code != http.StatusForbidden {
+		t.Errorf("suggesting to an invited playlist: got %d", w.Code)
+	}
+}
+
+func TestHandleListSuggestions(t *testing.T) {
+	h, pl := newSuggestionTestServer(t)
+	path := "/playlists/" + pl.ID + "/suggestions"
+	suggest(t, h, "guest-1", pl.ID, "One")
+	suggest(t, h, "guest-2", pl.ID, "Two")
+
+	tests := []struct {
+		name      string
+		userID    string
+		query     string
+		wantCode  int
+		wantCount int
+	}{
+		{name: "Owner Sees All", userID: "owner-1", wantCode: http.StatusOK, wantCount: 2},
+		{name: "Editor Sees All", userID: "ed-1", wantCode: http.StatusOK, wantCount: 2},
+		{name: "Viewer Sees Own", userID: "view-1", wantCode: http.StatusOK, wantCount: 0},
+		{name: "Suggester Sees Own", userID: "guest-1", wantCode: http.StatusOK, wantCount: 1},
+		{name: "Approved Only", userID: "owner-1", query: "?status=approved", wantCode: http.StatusOK, wantCount: 0},
+		{name: "Invalid Status", userID: "owner-1", query: "?status=maybe", wantCode: http.StatusBadRequest},
+		{name: "No User", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAs(h, tt.userID, "GET", path+tt.query, nil)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d. Body: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var got []TrackSuggestion
+			_ = json.NewDecoder(w.Body).Decode(&got)
+			if len(got) != tt.wantCount {
+				t.Errorf("expected %d suggestions, got %+v", tt.wantCount, got)
+			}
+		})
+	}
+}
+
+func TestHandleReviewSuggestion(t *testing.T) {
+	h, pl := newSuggestionTestServer(t)
+	base := "/playlists/" + pl.ID
+	one := suggest(t, h, "guest", pl.ID, "One")
+	two := suggest(t, h, "guest", pl.ID, "Two")
+
+	if w := serveAs(h, "view-1", "POST", base+"/suggestions/"+one.ID+"/approve", nil); w.Code != http.StatusForbidden {
+		t.Errorf("viewer approving: got %d", w.Code)
+	}
+	if w := serveAs(h, "guest", "POST", base+"/suggestions/"+one.ID+"/reject", nil); w.Code != http.StatusForbidden {
+		t.Errorf("suggester rejecting: got %d", w.Code)
+	}
+	if w := serveAs(h, "ed-1", "POST", base+"/suggestions/"+missingID+"/approve", nil); w.Code != http.StatusNotFound {
+		t.Errorf("missing suggestion: got %d", w.Code)
+	}
+
+	w := serveAs(h, "ed-1", "POST", base+"/suggestions/"+one.ID+"/approve", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("approve: got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var approved struct {
+		Suggestion TrackSuggestion `json:"suggestion"`
+		Track      Track           `json:"track"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&approved)
+	if approved.Suggestion.Status != suggestionApproved || approved.Suggestion.DecidedBy != "ed-1" ||
+		approved.Track.Title != "One" || approved.Track.AddedBy != "guest" {
+		t.Errorf("approved = %+v", approved)
+	}
+	if w := serveAs(h, "ed-1", "POST", base+"/suggestions/"+one.ID+"/reject", nil); w.Code != http.StatusConflict {
+		t.Errorf("rejecting an approved suggestion: got %d", w.Code)
+	}
+
+	if w := serveAs(h, "owner-1", "POST", base+"/suggestions/"+two.ID+"/reject", map[string]string{"reason": "wrong vibe"}); w.Code != http.StatusOK {
+		t.Fatalf("reject: got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	w = serveAs(h, "guest", "GET", base+"/suggestions?status=all", nil)
+	var mine []TrackSuggestion
+	_ = json.NewDecoder(w.Body).Decode(&mine)
+	if len(mine) != 2 || mine[0].TrackID != approved.Track.ID || mine[1].Status != suggestionRejected || mine[1].Reason != "wrong vibe" {
+		t.Errorf("suggester's view = %+v", mine)
+	}
+}
+
+func TestHandleApproveSuggestion_Checks(t *testing.T) {
+	h, pl := newSuggestionTestServer(t)
+	base := "/playlists/" + pl.ID
+	sg := suggest(t, h, "guest", pl.ID, "Song")
+	approve := base + "/suggestions/" + sg.ID + "/approve"
+
+	// The rules changed after the suggestion was made.
+	serveAs(h, "owner-1", "PUT", base+"/content-rules", ContentRules{BlockedArtists: []string{"Band"}})
+	if w := serveAs(h, "ed-1", "POST", approve, nil); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("approving a blocked track: got %d", w.Code)
+	}
+	if w := serveAs(h, "ed-1", "POST", approve, map[string]bool{"overrideRules": true}); w.Code != http.StatusForbidden {
+		t.Errorf("editor overriding the rules: got %d", w.Code)
+	}
+	serveAs(h, "owner-1", "PUT", base+"/content-rules", ContentRules{})
+
+	serveAs(h, "owner-1", "PATCH", base, map[string]any{"duplicatePolicy": duplicatePolicyReject, "fuzzyDuplicates": true})
+	serveAs(h, "ed-1", "POST", base+"/tracks", map[string]string{"title": "Song", "artist": "Band"})
+	if w := serveAs(h, "ed-1", "POST", approve, nil); w.Code != http.StatusConflict {
+		t.Errorf("approving a duplicate: got %d", w.Code)
+	}
+
+	w := serveAs(h, "view-1", "GET", base, nil)
+	var got struct {
+		CanEdit    bool `json:"canEdit"`
+		CanSuggest bool `json:"canSuggest"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if got.CanEdit || !got.CanSuggest {
+		t.Errorf("viewer of a suggest playlist: %+v", got)
+	}
+}
@@ -1,73 +0,0 @@
-package playlist
-
-import (
-	"context"
-	"errors"
-	"strings"
-	"testing"
-
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
-)
-
-func TestCheckAndAdvanceTracks(t *testing.T) {
-	mockDB := &MockDB{}
-	srv := NewServer(mockDB, nil)
-
-	// 1. Ticker Query: Find playlists to advance
-	mockDB.QueryFunc = func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
-		if strings.Contains(sql, "FROM playlists p") && strings.Contains(sql, "JOIN tracks t") {
-			return &MockRows{
-				Data: [][]any{
-					{"pl-1"},
-				},
-				Idx: -1,
-			}, nil
-		}
-		return nil, errors.New("unexpected query: " + sql)
-	}
-
-	// 2. NextTrack (called for pl-1)
-	// Needs to match the logic inside NextTrack()
-	mockDB.BeginTxFunc = func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
-		return &MockTx{
-			QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
-				// Current track
-				if strings.Contains(sql, "current_track_id FROM playlists") {
-					return &MockRow{
-						ScanFunc: func(dest ...any) error {
-							current := "track-old"
-							*dest[0].(**string) = &current
-							return nil
-						},
-					}
-				}
-				// Next track (simulate end of playlist for simplicity, or finding one)
-				if strings.Contains(sql, "FROM tracks") {
-					return &MockRow{
-						ScanFunc: func(dest ...any) error {
-							return pgx.ErrNoRows // End of playlist
-						},
-					}
-				}
-				return &MockRow{}
-			},
-			ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
-				// Updates
-				return pgconn.CommandTag{}, nil
-			},
-			CommitFunc: func(ctx context.Context) error {
-				return nil
-			},
-		}, nil
-	}
-
-	// Calls the internal method directly
-	srv.checkAndAdvanceTracks(context.Background())
-
-	// If no panic/error log (which we can't easily capture without redirecting log output,
-	// but MockDB unexpected calls would error out), we assume success traverse.
-	// Ideally we'd verify that BeginTx was called.
-	// Since we are mocking manually, if BeginTx wasn't called, the test would pass trivially
-	// unless we added a counter. But for coverage, this executes the code paths.
-}
@@ -0,0 +1,135 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// trashRetention is how long a deleted playlist can be restored before
+	// the purge job deletes it for good.
+	trashRetention = 30 * 24 * time.Hour
+	// trashPurgeInterval is how often the purge job runs.
+	trashPurgeInterval = time.Hour
+	// eventRestoreTimeout bounds the call restoring a playlist's event.
+	eventRestoreTimeout = 10 * time.Second
+)
+
+// EnableEventRestore makes restoring a playlist also restore the
+// vote-service event sharing its ID.
+func (s *Server) EnableEventRestore(voteServiceURL string) {
+	s.events = &voteServiceClient{baseURL: voteServiceURL}
+}
+
+// handleListTrash lists the deleted playlists of the current user that can
+// still be restored.
+// GET /users/me/trash
+func (s *Server) handleListTrash(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+
+	playlists, err := s.store.ListTrash(r.Context(), userID)
+	if err != nil {
+		log.Printf("playlist-service: list trash: %v", err)
+		writeError(w, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	now := time.Now()
+	items := make([]TrashedPlaylist, 0, len(playlists))
+	for _, pl := range playlists {
+		purgeAt := pl.DeletedAt.Add(trashRetention)
+		if !purgeAt.After(now) {
+			continue // due for the next purge
+		}
+		items = append(items, TrashedPlaylist{Playlist: pl, PurgeAt: purgeAt})
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleRestorePlaylist takes a playlist out of the trash with its tracks
+// and members, along with the event linked to it. Only the owner can
+// restore.
+// POST /playlists/{id}/restore
+func (s *Server) handleRestorePlaylist(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing user context")
+		return
+	}
+	playlistID := chi.URLParam(r, "id")
+
+	pl, err := s.store.RestorePlaylist(ctx, playlistID, func(pl Playlist) error {
+		if pl.OwnerID != userID {
+			return &httpError{status: http.StatusForbidden, msg: "forbidden"}
+		}
+		if time.Since(*pl.DeletedAt) >= trashRetention {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		writeError(w, http.StatusNotFound, "playlist not found in trash")
+		return
+	}
+	if err != nil {
+		writeHTTPError(w, err, "restore playlist")
+		return
+	}
+
+	if s.events != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), eventRestoreTimeout)
+			defer cancel()
+			if err := s.events.RestoreEvent(ctx, userID, playlistID); err != nil {
+				log.Printf("playlist-service: restore event %s: %v", playlistID, err)
+			}
+		}()
+	}
+
+	s.publishEvent(ctx, map[string]any{
+		"type":    "playlist.restored",
+		"payload": map[string]any{"playlistId": playlistID},
+	})
+
+	writeJSON(w, http.StatusOK, pl)
+}
+
+// StartTrashPurge permanently deletes playlists that have been in the trash
+// for trashRetention, until ctx is cancelled.
+func (s *Server) StartTrashPurge(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.purgeTrash(ctx); err != nil {
+				log.Printf("playlist-service: trash purge: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Server) purgeTrash(ctx context.Context) error {
+	ids, err := s.store.PurgeTrash(ctx, time.Now().Add(-trashRetention))
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		log.Printf("playlist-service: purged %d playlists from the trash", len(ids))
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestHandleDeletePlaylist(t *testing.T) {
+	tests := []struct {
+		name        string
+		userID      string
+		missing     bool
+		wantCode    int
+		wantTrashed bool
+	}{
+		{name: "Owner", userID: "owner-1", wantCode: http.StatusNoContent, wantTrashed: true},
+		{name: "Not Owner", userID: "u-2", wantCode: http.StatusForbidden},
+		{name: "Missing", userID: "owner-1", missing: true, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var execs []string
+			tx := &MockTx{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+					if !strings.Contains(sql, "deleted_at IS NULL") || !strings.Contains(sql, "FOR UPDATE") {
+						return &MockRow{ScanFunc: func(dest ...any) error { return errors.New("unexpected query: " + sql) }}
+					}
+					return &MockRow{ScanFunc: func(dest ...any) error {
+						if tt.missing {
+							return pgx.ErrNoRows
+						}
+						*dest[0].(*string) = "owner-1"
+						current := "tr-1"
+						*dest[1].(**string) = &current
+						return nil
+					}}
+				},
+				ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+					execs = append(execs, sql)
+					return pgconn.NewCommandTag("UPDATE 1"), nil
+				},
+			}
+			srv := NewServer(&MockDB{
+				BeginTxFunc: func(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) { return tx, nil },
+			}, nil)
+
+			req := httptest.NewRequest("DELETE", "/playlists/pl-1", nil)
+			req.Header.Set("X-User-Id", tt.userID)
+			w := httptest.NewRecorder()
+			srv.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d. Body: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			all := strings.Join(execs, "\n")
+			if strings.Contains(all, "SET deleted_at") != tt.wantTrashed {
+				t.Errorf("trashed = %v, want %v", !tt.wantTrashed, tt.wantTrashed)
+			}
+			if strings.Contains(all, "DELETE FROM playlists") {
+				t.Error("playlist was deleted for good")
+			}
+			if tt.wantTrashed && (!strings.Contains(all, "SET status = 'played'") || !strings.Contains(all, "UPDATE play_history")) {
+				t.Errorf("playback was not stopped:\n%s", all)
+			}
+		})
+	}
+}
+
+func TestHandleListTrash(t *testing.T) {
+	recent := time.Now().Add(-time.Hour)
+	expired := time.Now().Add(-trashRetention - time.Minute)
+
+	var gotOwner any
+	mockDB := &MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if !strings.Contains(sql, "deleted_at IS NOT NULL") {
+				return nil, errors.New("unexpected query: " + sql)
+			}
+			gotOwner = args[0]
+			return &MockRows{Data: [][]any{
+				{"pl-1", "owner-1", "Friday", "", true, editModeEveryone, recent.Add(-time.Hour), recent, 7},
+				{"pl-2", "owner-1", "Old", "", true, editModeEveryone, expired.Add(-time.Hour), expired, 2},
+			}, Idx: -1}, nil
+		},
+	}
+	srv := NewServer(mockDB, nil)
+
+	req := httptest.NewRequest("GET", "/users/me/trash", nil)
+	req.Header.Set("X-User-Id", "owner-1")
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if gotOwner != "owner-1" {
+		t.Errorf("listed the trash of %v", gotOwner)
+	}
+	var items []TrashedPlaylist
+	_ = json.NewDecoder(w.Body).Decode(&items)
+	if len(items) != 1 || items[0].ID != "pl-1" || items[0].TrackCount != 7 {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+	if !items[0].PurgeAt.Equal(recent.Add(trashRetention)) || items[0].DeletedAt == nil {
+		t.Errorf("unexpected dates: %+v", items[0])
+	}
+}
+
+// fakeEventRestorer records the events it was asked to restore.
+type fakeEventRestorer struct {
+	calls chan string
+}
+
+func (f *fakeEventRestorer) RestoreEvent(ctx context.Context, userID, eventID string) error {
+	f.calls <- userID + ":" + eventID
+	return nil
+}
+
+func TestHandleRestorePlaylist(t *testing.T) {
+	store := NewMemoryStore()
+	events := &fakeEventRestorer{calls: make(chan string, 1)}
+	srv := NewServerWithStore(store, nil)
+	srv.events = events
+	router := srv.Router()
+
+	newTrashed := func(deletedAt time.Time) Playlist {
+		pl := Playlist{OwnerID: "owner-1", Name: "Friday", IsPublic: true, EditMode: editModeEveryone}
+		if err := store.CreatePlaylist(context.Background(), &pl, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.DeletePlaylist(context.Background(), pl.ID, func(Playlist) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+		store.playlists[pl.ID].pl.DeletedAt = &deletedAt
+		return pl
+	}
+	restore := func(userID, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/playlists/"+id+"/restore", nil)
+		req.Header.Set("X-User-Id", userID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	pl := newTrashed(time.Now().Add(-time.Hour))
+	if w := restore("u-2", pl.ID); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for another user, got %d", w.Code)
+	}
+	w := restore("owner-1", pl.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var restored Playlist
+	_ = json.NewDecoder(w.Body).Decode(&restored)
+	if restored.ID != pl.ID || restored.DeletedAt != nil {
+		t.Errorf("unexpected playlist: %+v", restored)
+	}
+	select {
+	case call := <-events.calls:
+		if call != "owner-1:"+pl.ID {
+			t.Errorf("restored event %s", call)
+		}
+	case <-time.After(time.Second):
+		t.Error("linked event was not restored")
+	}
+	if w := restore("owner-1", pl.ID); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a playlist not in the trash, got %d", w.Code)
+	}
+
+	expired := newTrashed(time.Now().Add(-trashRetention - time.Minute))
+	if w := restore("owner-1", expired.ID); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 past the retention period, got %d", w.Code)
+	}
+}
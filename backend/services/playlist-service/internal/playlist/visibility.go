@@ -0,0 +1,102 @@
+package playlist
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// friendCacheTTL is how long friend lists read from user-service are
+// reused; a new or removed friendship takes effect within it.
+const friendCacheTTL = time.Minute
+
+// Friends-only playlists are visible to the owner's friends from
+// user-service, who may also join them as editors. Friendships are
+// symmetric, so the caller's own friend list decides: it is cached per user
+// and sent along with playlist listings. Without EnableFriends, friends-only
+// playlists are as private as private ones.
+
+// friendCache is a friendLister that remembers the lists of another one for
+// ttl. The returned lists are shared and must not be modified.
+type friendCache struct {
+	next friendLister
+	ttl  time.Duration
+
+	mu          sync.Mutex
+	entries     map[string]friendCacheEntry
+	lastCleanup time.Time
+}
+
+type friendCacheEntry struct {
+	friends []string
+	expires time.Time
+}
+
+func newFriendCache(next friendLister, ttl time.Duration) *friendCache {
+	return &friendCache{next: next, ttl: ttl, entries: map[string]friendCacheEntry{}}
+}
+
+func (c *friendCache) Friends(ctx context.Context, userID string) ([]string, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[userID]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.friends, nil
+	}
+
+	friends, err := c.next.Friends(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastCleanup) > c.ttl {
+		for id, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, id)
+			}
+		}
+		c.lastCleanup = now
+	}
+	c.entries[userID] = friendCacheEntry{friends: friends, expires: now.Add(c.ttl)}
+	return friends, nil
+}
+
+// friendIDs returns the friends of userID, or nil without a user or
+// without EnableFriends.
+func (s *Server) friendIDs(ctx context.Context, userID string) ([]string, error) {
+	if userID == "" || s.friends == nil {
+		return nil, nil
+	}
+	return s.friends.Friends(ctx, userID)
+}
+
+// viewableAsFriend reports whether userID may see a playlist that is not
+// public as a friend of its owner.
+func (s *Server) viewableAsFriend(ctx context.Context, playlistID, userID string) (bool, error) {
+	if userID == "" || s.friends == nil {
+		return false, nil
+	}
+	pl, err := s.store.GetPlaylist(ctx, playlistID)
+	if err != nil {
+		return false, err
+	}
+	return s.isFriendOfOwner(ctx, pl.Visibility, pl.OwnerID, userID)
+}
+
+// isFriendOfOwner reports whether userID may see a playlist with the given
+// visibility as a friend of its owner.
+func (s *Server) isFriendOfOwner(ctx context.Context, visibility, ownerID, userID string) (bool, error) {
+	if visibility != visibilityFriends {
+		return false, nil
+	}
+	friends, err := s.friendIDs(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(friends, ownerID), nil
+}
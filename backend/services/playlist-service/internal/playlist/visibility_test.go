@@ -0,0 +1,184 @@
+package playlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type countingFriends struct {
+	calls int
+}
+
+func (f *countingFriends) Friends(ctx context.Context, userID string) ([]string, error) {
+	f.calls++
+	return []string{"friend-of-" + userID}, nil
+}
+
+func TestFriendCache(t *testing.T) {
+	next := &countingFriends{}
+	cache := newFriendCache(next, time.Hour)
+	for range 3 {
+		if friends, err := cache.Friends(context.Background(), "u-1"); err != nil || len(friends) != 1 {
+			t.Fatalf("Friends = %v, %v", friends, err)
+		}
+	}
+	cache.Friends(context.Background(), "u-2")
+	if next.calls != 2 {
+		t.Errorf("expected one lookup per user, got %d", next.calls)
+	}
+
+	next = &countingFriends{}
+	cache = newFriendCache(next, 0)
+	cache.Friends(context.Background(), "u-1")
+	cache.Friends(context.Background(), "u-1")
+	if next.calls != 2 {
+		t.Errorf("expected expired lists to be fetched again, got %d lookups", next.calls)
+	}
+}
+
+// newVisibilityTestServer returns a memory-backed server with a friends-only
+// playlist owned by owner-1, who is friends with friend-1.
+func newVisibilityTestServer(t *testing.T) (http.Handler, Playlist) {
+	t.Helper()
+	store := NewMemoryStore()
+	pl := Playlist{OwnerID: "owner-1", Name: "Friends", Visibility: visibilityFriends, EditMode: editModeEveryone}
+	if err := store.CreatePlaylist(context.Background(), &pl, nil); err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServerWithStore(store, nil)
+	srv.friends = fakeFriends{friends: map[string][]string{
+		"owner-1":  {"friend-1"},
+		"friend-1": {"owner-1"},
+	}}
+	return srv.Router(), pl
+}
+
+func TestHandleGetPlaylist_FriendsOnly(t *testing.T) {
+	h, pl := newVisibilityTestServer(t)
+	path := "/playlists/" + pl.ID
+
+	if w := serveAs(h, "stranger-1", "GET", path, nil); w.Code != http.StatusForbidden {
+		t.Errorf("stranger: got %d", w.Code)
+	}
+
+	w := serveAs(h, "friend-1", "GET", path, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("friend: got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var got struct {
+		Playlist Playlist `json:"playlist"`
+		CanEdit  bool     `json:"canEdit"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&got)
+	if got.Playlist.Visibility != visibilityFriends || got.Playlist.IsPublic || got.CanEdit {
+		t.Errorf("friend view = %+v", got)
+	}
+
+	if w := serveAs(h, "friend-1", "GET", path+"/edit-license", nil); w.Code != http.StatusOK {
+		t.Errorf("friend viewing the edit license: got %d", w.Code)
+	}
+	if w := serveAs(h, "stranger-1", "GET", path+"/edit-license", nil); w.Code != http.StatusForbidden {
+		t.Errorf("stranger viewing the edit license: got %d", w.Code)
+	}
+}
+
+func TestHandleAddInvite_FriendsOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   string
+		invitee  string
+		wantCode int
+	}{
+		{name: "Friend Joins", userID: "friend-1", invitee: "friend-1", wantCode: http.StatusNoContent},
+		{name: "Friend Invites Someone Else", userID: "friend-1", invitee: "u-2", wantCode: http.StatusForbidden},
+		{name: "Stranger Joins", userID: "u-2", invitee: "u-2", wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			joined := false
+			mockDB := &MockDB{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+					if row, ok := moderationStateRow(sql); ok {
+						return row
+					}
+					if strings.Contains(sql, "skip_vote_threshold") {
+						// GetPlaylist: only the owner and the visibility matter.
+						return &MockRow{ScanFunc: func(dest ...any) error {
+							*dest[1].(*string) = "owner-1"
+							*dest[len(dest)-1].(*string) = visibilityFriends
+							return nil
+						}}
+					}
+					return &MockRow{ScanFunc: func(dest ...any) error {
+						*dest[0].(*string) = "owner-1"
+						*dest[1].(*bool) = false
+						*dest[2].(*string) = editModeEveryone
+						return nil
+					}}
+				},
+				ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+					if strings.Contains(sql, "INSERT INTO playlist_members") {
+						joined = true
+					}
+					return pgconn.CommandTag{}, nil
+				},
+			}
+			srv := NewServer(mockDB, nil)
+			srv.friends = fakeFriends{friends: map[string][]string{"friend-1": {"owner-1"}}}
+
+			w := serveAs(srv.Router(), tt.userID, "POST", "/playlists/pl-1/invites", map[string]string{"userId": tt.invitee})
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected %d, got %d. Body: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if joined != (tt.wantCode == http.StatusNoContent) {
+				t.Errorf("joined = %v", joined)
+			}
+		})
+	}
+}
+
+func TestHandlePlaylistVisibility(t *testing.T) {
+	h := NewServerWithStore(NewMemoryStore(), nil).Router()
+
+	if w := serveAs(h, "owner-1", "POST", "/playlists", map[string]any{"name": "Bad", "visibility": "secret"}); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid visibility: got %d", w.Code)
+	}
+
+	w := serveAs(h, "owner-1", "POST", "/playlists", map[string]any{"name": "Mine", "visibility": "Friends"})
+	var pl Playlist
+	_ = json.NewDecoder(w.Body).Decode(&pl)
+	if w.Code != http.StatusCreated || pl.Visibility != visibilityFriends || pl.IsPublic {
+		t.Fatalf("create = %d %+v", w.Code, pl)
+	}
+
+	patch := func(body map[string]any) Playlist {
+		t.Helper()
+		w := serveAs(h, "owner-1", "PATCH", "/playlists/"+pl.ID, body)
+		var got Playlist
+		_ = json.NewDecoder(w.Body).Decode(&got)
+		if w.Code != http.StatusOK {
+			t.Fatalf("patch %v: got %d. Body: %s", body, w.Code, w.Body.String())
+		}
+		return got
+	}
+	if got := patch(map[string]any{"isPublic": true}); got.Visibility != visibilityPublic || !got.IsPublic {
+		t.Errorf("made public = %+v", got)
+	}
+	if got := patch(map[string]any{"visibility": "private"}); got.Visibility != visibilityPrivate || got.IsPublic {
+		t.Errorf("made private = %+v", got)
+	}
+	if got := patch(map[string]any{"name": "Renamed"}); got.Visibility != visibilityPrivate {
+		t.Errorf("rename changed the visibility: %+v", got)
+	}
+	if w := serveAs(h, "owner-1", "PATCH", "/playlists/"+pl.ID, map[string]any{"visibility": "secret"}); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid visibility patch: got %d", w.Code)
+	}
+}
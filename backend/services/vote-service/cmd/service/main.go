@@ -39,6 +39,9 @@ func main() {
 	rdb := redis.NewClient(opt)
 	defer rdb.Close()
 
+	// Events deleted longer than the retention period ago are purged
+	vote.StartTrashPurge(ctx, vote.NewPostgresStore(pool))
+
 	router := vote.NewRouter(pool, rdb, userServiceURL, playlistServiceURL, realtimeServiceURL)
 
 	log.Printf("vote-service listening on :%s", port)
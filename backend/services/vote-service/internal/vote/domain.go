@@ -7,10 +7,15 @@ import (
 
 var (
 	ErrVoteConflict = errors.New("vote conflict")
+	// ErrJoinCodeConflict means a freshly generated join code is already taken.
+	ErrJoinCodeConflict = errors.New("join code conflict")
+	// ErrBanned means the user is banned from the event.
+	ErrBanned = errors.New("banned")
 )
 
 const (
 	visibilityPublic  = "public"
+	visibilityFriends = "friends" // the owner's friends may view and self-join
 	visibilityPrivate = "private"
 
 	licenseEveryone = "everyone"
@@ -21,6 +26,10 @@ const (
 	RoleContributor = "contributor"
 )
 
+func isValidVisibility(v string) bool {
+	return v == visibilityPublic || v == visibilityFriends || v == visibilityPrivate
+}
+
 type Event struct {
 	ID          string     `json:"id"`
 	Name        string     `json:"name"`
@@ -34,8 +43,35 @@ type Event struct {
 	VoteEnd     *time.Time `json:"voteEnd,omitempty"`
 	CreatedAt   time.Time  `json:"createdAt"`
 	UpdatedAt   time.Time  `json:"updatedAt"`
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"` // set while in the trash
 	IsJoined    bool       `json:"isJoined"`
 	CanVote     bool       `json:"canVote"`
+
+	// VotingLockedUntil is set while a moderator has locked voting.
+	VotingLockedUntil *time.Time `json:"votingLockedUntil,omitempty"`
+}
+
+// JoinLink is a shareable code that adds whoever redeems it to an event.
+// MaxUses and ExpiresAt are unlimited when nil.
+type JoinLink struct {
+	Code      string     `json:"code"`
+	EventID   string     `json:"eventId"`
+	CreatedBy string     `json:"createdBy"`
+	Role      string     `json:"role"`
+	MaxUses   *int       `json:"maxUses,omitempty"`
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// JoinResult is returned by POST /join/{code}, in the same shape as
+// playlist-service answers playlist codes.
+type JoinResult struct {
+	Type   string `json:"type"` // "event"
+	ID     string `json:"id"`
+	Role   string `json:"role"`
+	Joined bool   `json:"joined"` // false if the user already had access
 }
 
 type VoteResponse struct {
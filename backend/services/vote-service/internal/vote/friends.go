@@ -0,0 +1,108 @@
+package vote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// friendCacheTTL is how long friend lists read from user-service are reused.
+const friendCacheTTL = time.Minute
+
+// friendCache remembers the friend lists of users for friendCacheTTL, so
+// friends-only events do not cost a user-service call per request. The zero
+// value is ready to use.
+type friendCache struct {
+	mu      sync.Mutex
+	entries map[string]friendCacheEntry
+}
+
+type friendCacheEntry struct {
+	friends []string
+	expires time.Time
+}
+
+func (c *friendCache) get(userID string, now time.Time) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[userID]
+	if !ok || !now.Before(e.expires) {
+		return nil, false
+	}
+	return e.friends, true
+}
+
+func (c *friendCache) put(userID string, friends []string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]friendCacheEntry{}
+	}
+	for id, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, id)
+		}
+	}
+	c.entries[userID] = friendCacheEntry{friends: friends, expires: now.Add(friendCacheTTL)}
+}
+
+// isFriendOfOwner reports whether userID may see a friends-only event as a
+// friend of its owner. Friendships are symmetric, so the caller's own friend
+// list decides.
+func (s *HTTPServer) isFriendOfOwner(ctx context.Context, ev *Event, userID string) (bool, error) {
+	if ev.Visibility != visibilityFriends || userID == "" {
+		return false, nil
+	}
+	now := time.Now()
+	friends, ok := s.friends.get(userID, now)
+	if !ok {
+		var err error
+		if friends, err = listFriends(ctx, s.httpClient, s.userServiceURL, userID); err != nil {
+			return false, err
+		}
+		s.friends.put(userID, friends, now)
+	}
+	return slices.Contains(friends, ev.OwnerID), nil
+}
+
+// listFriends calls GET /users/me/friends on behalf of userID.
+func listFriends(ctx context.Context, client *http.Client, baseURL, userID string) ([]string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = "/users/me/friends"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-User-Id", userID)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("user-service returned %d", resp.StatusCode)
+	}
+	var res struct {
+		Items []struct {
+			UserID string `json:"userId"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	friends := make([]string, 0, len(res.Items))
+	for _, it := range res.Items {
+		friends = append(friends, it.UserID)
+	}
+	return friends, nil
+}
@@ -0,0 +1,166 @@
+package vote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newFriendsUserService fakes user-service: "friend" is friends with
+// "owner", and every user exists.
+func newFriendsUserService(t *testing.T, calls *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/me/friends" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		*calls++
+		items := []map[string]string{}
+		if r.Header.Get("X-User-Id") == "friend" {
+			items = append(items, map[string]string{"userId": "owner"})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestIsFriendOfOwner(t *testing.T) {
+	var calls int
+	userSrv := newFriendsUserService(t, &calls)
+	server := &HTTPServer{httpClient: http.DefaultClient, userServiceURL: userSrv.URL}
+	ev := &Event{ID: "ev1", OwnerID: "owner", Visibility: visibilityFriends}
+
+	for range 2 {
+		ok, err := server.isFriendOfOwner(context.Background(), ev, "friend")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+	assert.Equal(t, 1, calls, "friend lists should be cached")
+
+	ok, err := server.isFriendOfOwner(context.Background(), ev, "stranger")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, _ = server.isFriendOfOwner(context.Background(), &Event{OwnerID: "owner", Visibility: visibilityPrivate}, "friend")
+	assert.False(t, ok, "private events are not visible to friends")
+
+	server = &HTTPServer{httpClient: http.DefaultClient, userServiceURL: "http://127.0.0.1:0"}
+	_, err = server.isFriendOfOwner(context.Background(), ev, "friend")
+	assert.Error(t, err)
+}
+
+func TestFriendCacheExpiry(t *testing.T) {
+	var c friendCache
+	now := time.Now()
+	c.put("u1", []string{"u2"}, now)
+
+	friends, ok := c.get("u1", now.Add(friendCacheTTL-time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"u2"}, friends)
+
+	_, ok = c.get("u1", now.Add(friendCacheTTL))
+	assert.False(t, ok)
+}
+
+func TestFriendsOnlyEvent(t *testing.T) {
+	t.Run("friend can view", func(t *testing.T) {
+		var calls int
+		mockStore := new(MockStore)
+		server := &HTTPServer{store: mockStore, httpClient: http.DefaultClient, userServiceURL: newFriendsUserService(t, &calls).URL}
+		r := chi.NewRouter()
+		r.Get("/events/{id}", server.handleGetEvent)
+
+		ev := &Event{ID: "ev1", OwnerID: "owner", Visibility: visibilityFriends, LicenseMode: licenseEveryone}
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(ev, nil)
+		mockStore.On("IsInvited", mock.Anything, "ev1", mock.Anything).Return(false, nil)
+		mockStore.On("GetParticipantRole", mock.Anything, "ev1", "friend").Return("", nil)
+
+		req := httptest.NewRequest("GET", "/events/ev1", nil)
+		req.Header.Set("X-User-Id", "friend")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var got Event
+		_ = json.NewDecoder(rec.Body).Decode(&got)
+		assert.False(t, got.IsJoined)
+		assert.False(t, got.CanVote)
+
+		req = httptest.NewRequest("GET", "/events/ev1", nil)
+		req.Header.Set("X-User-Id", "stranger")
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("friend can self-join", func(t *testing.T) {
+		var calls int
+		mockStore := new(MockStore)
+		server := &HTTPServer{store: mockStore, httpClient: http.DefaultClient, userServiceURL: newFriendsUserService(t, &calls).URL}
+		r := chi.NewRouter()
+		r.Post("/events/{id}/invites", server.handleCreateInvite)
+
+		ev := &Event{ID: "ev1", OwnerID: "owner", Visibility: visibilityFriends, LicenseMode: licenseEveryone}
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(ev, nil)
+		mockStore.On("IsBanned", mock.Anything, "ev1", "friend").Return(false, nil)
+		mockStore.On("CreateInvite", mock.Anything, "ev1", "friend", RoleContributor).Return(nil)
+
+		join := func(userID string) int {
+			b, _ := json.Marshal(map[string]string{"userId": userID})
+			req := httptest.NewRequest("POST", "/events/ev1/invites", bytes.NewReader(b))
+			req.Header.Set("X-User-Id", userID)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			return rec.Code
+		}
+		assert.Equal(t, http.StatusNoContent, join("friend"))
+		assert.Equal(t, http.StatusForbidden, join("stranger"))
+		mockStore.AssertCalled(t, "CreateInvite", mock.Anything, "ev1", "friend", RoleContributor)
+	})
+
+	t.Run("friends must join to vote", func(t *testing.T) {
+		mockStore := new(MockStore)
+		ev := &Event{ID: "ev1", OwnerID: "owner", Visibility: visibilityFriends, LicenseMode: licenseEveryone}
+		mockStore.On("IsInvited", mock.Anything, "ev1", "friend").Return(false, nil)
+
+		ok, reason, err := canUserVote(context.Background(), mockStore, ev, "friend", nil, nil, time.Now())
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, "you must join the event to vote", reason)
+	})
+
+	t.Run("create accepts friends visibility", func(t *testing.T) {
+		var plReq map[string]any
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			_ = json.NewDecoder(req.Body).Decode(&plReq)
+			rec := httptest.NewRecorder()
+			writeJSON(rec, http.StatusCreated, map[string]any{"id": "ev1"})
+			return rec.Result()
+		})
+		mockStore := new(MockStore)
+		server := &HTTPServer{store: mockStore, httpClient: client, playlistServiceURL: "http://pl"}
+		mockStore.On("CreateEvent", mock.Anything, mock.Anything).Return("ev1", nil)
+		mockStore.On("CreateInvite", mock.Anything, "ev1", "owner", mock.Anything).Return(nil).Maybe()
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner", Visibility: visibilityFriends}, nil)
+
+		b, _ := json.Marshal(map[string]string{"name": "Party", "visibility": "friends"})
+		req := httptest.NewRequest("POST", "/events", bytes.NewReader(b))
+		req.Header.Set("X-User-Id", "owner")
+		rec := httptest.NewRecorder()
+		server.handleCreateEvent(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		assert.Equal(t, visibilityFriends, plReq["visibility"])
+		assert.Equal(t, "everyone", plReq["editMode"])
+	})
+}
@@ -17,6 +17,8 @@ type HTTPServer struct {
 	userServiceURL     string
 	playlistServiceURL string
 	realtimeServiceURL string
+
+	friends friendCache
 }
 
 func NewRouter(pool *pgxpool.Pool, rdb *redis.Client, userServiceURL, playlistServiceURL, realtimeServiceURL string) http.Handler {
@@ -46,6 +48,7 @@ func NewRouter(pool *pgxpool.Pool, rdb *redis.Client, userServiceURL, playlistSe
 	r.Post("/events", s.handleCreateEvent)
 	r.Get("/events/{id}", s.handleGetEvent)
 	r.Delete("/events/{id}", s.handleDeleteEvent)
+	r.Post("/events/{id}/restore", s.handleRestoreEvent)
 	r.Patch("/events/{id}", s.handlePatchEvent)
 	r.Post("/events/{id}/transfer-ownership", s.handleTransferOwnership)
 
@@ -54,6 +57,20 @@ func NewRouter(pool *pgxpool.Pool, rdb *redis.Client, userServiceURL, playlistSe
 	r.Delete("/events/{id}/invites/{userId}", s.handleDeleteInvite)
 	r.Get("/events/{id}/invites", s.handleListInvites)
 
+	// join links
+	r.Post("/events/{id}/join-links", s.handleCreateJoinLink)
+	r.Get("/events/{id}/join-links", s.handleListJoinLinks)
+	r.Delete("/events/{id}/join-links/{code}", s.handleRevokeJoinLink)
+	r.Post("/join/{code}", s.handleJoin)
+
+	// moderation
+	r.Post("/events/{id}/moderation/kick", s.handleKick)
+	r.Get("/events/{id}/moderation/bans", s.handleListBans)
+	r.Post("/events/{id}/moderation/bans", s.handleBan)
+	r.Delete("/events/{id}/moderation/bans/{userId}", s.handleUnban)
+	r.Put("/events/{id}/moderation/locks/{target}", s.handleLock)
+	r.Delete("/events/{id}/moderation/locks/{target}", s.handleUnlock)
+
 	// voting
 	r.Post("/events/{id}/vote", s.handleVote)
 	r.Delete("/events/{id}/vote", s.handleRemoveVote)
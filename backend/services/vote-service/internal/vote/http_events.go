@@ -59,7 +59,7 @@ func (s *HTTPServer) handleCreateEvent(w http.ResponseWriter, r *http.Request) {
 	}
 	if body.Visibility == "" {
 		body.Visibility = visibilityPublic
-	} else if body.Visibility != visibilityPublic && body.Visibility != visibilityPrivate {
+	} else if !isValidVisibility(body.Visibility) {
 		writeError(w, http.StatusBadRequest, "invalid visibility")
 		return
 	}
@@ -99,6 +99,7 @@ func (s *HTTPServer) handleCreateEvent(w http.ResponseWriter, r *http.Request) {
 		"name":        body.Name,
 		"description": "Event Playlist for " + body.Name,
 		"isPublic":    true, // Events are public by default logic here? Or match visibility?
+		"visibility":  body.Visibility,
 		"editMode":    "everyone",
 	}
 	if body.Visibility == visibilityPrivate || body.LicenseMode == licenseInvited {
@@ -189,7 +190,7 @@ func (s *HTTPServer) handleGetEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if ev.Visibility == visibilityPrivate {
+	if ev.Visibility == visibilityPrivate || ev.Visibility == visibilityFriends {
 		if userID == "" {
 			writeError(w, http.StatusUnauthorized, "missing X-User-Id")
 			return
@@ -202,8 +203,16 @@ func (s *HTTPServer) handleGetEvent(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			if !invited {
-				writeError(w, http.StatusForbidden, "event is private, invite required")
-				return
+				friend, err := s.isFriendOfOwner(r.Context(), ev, userID)
+				if err != nil {
+					log.Printf("vote-service: list friends: %v", err)
+					writeError(w, http.StatusBadGateway, "unable to verify friendship")
+					return
+				}
+				if !friend {
+					writeError(w, http.StatusForbidden, "event is private, invite required")
+					return
+				}
 			}
 		}
 	}
@@ -264,6 +273,9 @@ func (s *HTTPServer) handleDeleteEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The event's playlist goes to the trash with it.
+	s.propagateToPlaylist(http.MethodDelete, "/playlists/"+id, userID)
+
 	go s.publishEvent(context.Background(), "event.deleted", map[string]string{"id": id})
 
 	w.WriteHeader(http.StatusNoContent)
@@ -315,7 +327,7 @@ func (s *HTTPServer) handlePatchEvent(w http.ResponseWriter, r *http.Request) {
 		ev.Name = *body.Name
 	}
 	if body.Visibility != nil && *body.Visibility != "" {
-		if *body.Visibility != visibilityPublic && *body.Visibility != visibilityPrivate {
+		if !isValidVisibility(*body.Visibility) {
 			writeError(w, http.StatusBadRequest, "invalid visibility")
 			return
 		}
@@ -384,6 +396,7 @@ func (s *HTTPServer) handlePatchEvent(w http.ResponseWriter, r *http.Request) {
 		}
 		if body.Visibility != nil {
 			plUpdate["isPublic"] = (*body.Visibility == visibilityPublic)
+			plUpdate["visibility"] = *body.Visibility
 		}
 		if body.LicenseMode != nil || body.Visibility != nil {
 			// Recalculate editMode
@@ -49,13 +49,24 @@ func (s *HTTPServer) handleCreateInvite(w http.ResponseWriter, r *http.Request)
 	}
 	var role string
 
+	// Anyone may join a public event themselves, and the owner's friends a
+	// friends-only one.
+	selfJoin := body.UserID == userID && ev.Visibility == visibilityPublic
+	if body.UserID == userID && ev.Visibility == visibilityFriends && ev.OwnerID != userID {
+		if selfJoin, err = s.isFriendOfOwner(r.Context(), ev, userID); err != nil {
+			log.Printf("vote-service: list friends: %v", err)
+			writeError(w, http.StatusBadGateway, "unable to verify friendship")
+			return
+		}
+	}
+
 	if ev.OwnerID != userID {
 		// Strict check for Invited Only
 		if ev.LicenseMode == licenseInvited {
 			// If InvitedOnly, ONLY Owner can invite contributors.
 			// BUT, Public events allow listeners to join.
 			// If Self-Join + Public + InvitedOnly -> Guest
-			if ev.Visibility == visibilityPublic && body.UserID == userID {
+			if selfJoin {
 				role = RoleGuest
 			} else {
 				// Stranger trying to invite someone or join private?
@@ -63,7 +74,7 @@ func (s *HTTPServer) handleCreateInvite(w http.ResponseWriter, r *http.Request)
 				// Actually handleCreateInvite: "if ev.OwnerID != userID" ...
 				// If not owner, you can only self-join public events.
 				// (The previous logic allowed this).
-				if ev.Visibility == visibilityPublic && body.UserID == userID {
+				if selfJoin {
 					// Logic above handles this branch, but let's be explicit
 					// This block is redundant if we nested cleanly, but let's follow logic.
 				} else {
@@ -74,7 +85,7 @@ func (s *HTTPServer) handleCreateInvite(w http.ResponseWriter, r *http.Request)
 		} else {
 			// licenseEveryone or GeoTime
 			// Allow self-invite for public events (Joining)
-			if ev.Visibility == visibilityPublic && body.UserID == userID {
+			if selfJoin {
 				role = RoleContributor
 			} else {
 				writeError(w, http.StatusForbidden, "forbidden")
@@ -86,6 +97,20 @@ func (s *HTTPServer) handleCreateInvite(w http.ResponseWriter, r *http.Request)
 		role = RoleContributor
 	}
 
+	banned, err := s.store.IsBanned(r.Context(), id, body.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if banned && body.UserID == userID {
+		writeError(w, http.StatusForbidden, "you are banned from this event")
+		return
+	}
+	if banned {
+		writeError(w, http.StatusConflict, "user is banned from this event")
+		return
+	}
+
 	if err := checkUserExists(r.Context(), s.httpClient, s.userServiceURL, body.UserID); err != nil {
 		var ie *inviteError
 		if errors.As(err, &ie) {
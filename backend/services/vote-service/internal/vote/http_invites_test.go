@@ -112,6 +112,7 @@ func TestHandleCreateInvite(t *testing.T) {
 
 		ev := &Event{ID: "ev1", OwnerID: "owner", Visibility: "public"}
 		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(ev, nil)
+		mockStore.On("IsBanned", mock.Anything, "ev1", "user1").Return(false, nil)
 		mockStore.On("CreateInvite", mock.Anything, "ev1", "user1", "contributor").Return(nil)
 
 		r.ServeHTTP(rec, req)
@@ -139,6 +140,7 @@ func TestHandleCreateInvite(t *testing.T) {
 		// Event is public visibility but invited_only license
 		ev := &Event{ID: "ev1", OwnerID: "owner", Visibility: "public", LicenseMode: "invited_only"}
 		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(ev, nil)
+		mockStore.On("IsBanned", mock.Anything, "ev1", "user1").Return(false, nil)
 		mockStore.On("CreateInvite", mock.Anything, "ev1", "user1", "guest").Return(nil)
 
 		r.ServeHTTP(rec, req)
@@ -164,6 +166,7 @@ func TestHandleCreateInvite(t *testing.T) {
 
 		ev := &Event{ID: "ev1", OwnerID: "owner", Visibility: "private"}
 		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(ev, nil)
+		mockStore.On("IsBanned", mock.Anything, "ev1", "ghost").Return(false, nil)
 
 		r.ServeHTTP(rec, req)
 		assert.Equal(t, http.StatusNotFound, rec.Code)
@@ -188,6 +191,7 @@ func TestHandleCreateInvite(t *testing.T) {
 
 		ev := &Event{ID: "ev1", OwnerID: "owner", Visibility: "private"}
 		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(ev, nil)
+		mockStore.On("IsBanned", mock.Anything, "ev1", "user1").Return(false, nil)
 		mockStore.On("CreateInvite", mock.Anything, "ev1", "user1", "contributor").Return(errors.New("db error"))
 
 		r.ServeHTTP(rec, req)
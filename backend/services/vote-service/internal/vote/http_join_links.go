@@ -0,0 +1,212 @@
+package vote
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// Join codes are short enough to type from a venue screen. The first
+	// character tells the api-gateway which service minted the code
+	// (playlist-service uses "P" for playlists).
+	joinCodePrefix   = "E"
+	joinCodeLength   = 8
+	joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O, 1/I
+
+	maxJoinLinkUses  = 10000
+	maxJoinLinkTTL   = 30 * 24 * time.Hour
+	joinCodeAttempts = 3
+)
+
+func newJoinCode() (string, error) {
+	buf := make([]byte, joinCodeLength-len(joinCodePrefix))
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := []byte(joinCodePrefix)
+	for _, b := range buf {
+		// len(joinCodeAlphabet) divides 256, so there is no modulo bias.
+		code = append(code, joinCodeAlphabet[int(b)%len(joinCodeAlphabet)])
+	}
+	return string(code), nil
+}
+
+// normalizeJoinCode accepts codes typed in lower case or with separators.
+func normalizeJoinCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// loadOwnedEvent writes the error response and returns nil unless userID
+// owns the event.
+func (s *HTTPServer) loadOwnedEvent(w http.ResponseWriter, r *http.Request, userID string) *Event {
+	ev, err := s.store.LoadEvent(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "event not found")
+			return nil
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil
+	}
+	if ev.OwnerID != userID {
+		writeError(w, http.StatusForbidden, "forbidden")
+		return nil
+	}
+	return ev
+}
+
+func (s *HTTPServer) handleCreateJoinLink(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing X-User-Id")
+		return
+	}
+
+	var body struct {
+		Role           *string `json:"role"`
+		MaxUses        *int    `json:"maxUses"`
+		ExpiresInHours *int    `json:"expiresInHours"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	link := &JoinLink{CreatedBy: userID, Role: RoleContributor, MaxUses: body.MaxUses}
+	if body.Role != nil {
+		link.Role = strings.ToLower(strings.TrimSpace(*body.Role))
+		if link.Role != RoleContributor && link.Role != RoleGuest {
+			writeError(w, http.StatusBadRequest, `invalid role (must be "contributor" or "guest")`)
+			return
+		}
+	}
+	if body.MaxUses != nil && (*body.MaxUses < 1 || *body.MaxUses > maxJoinLinkUses) {
+		writeError(w, http.StatusBadRequest, "maxUses must be between 1 and 10000")
+		return
+	}
+	if body.ExpiresInHours != nil {
+		ttl := time.Duration(*body.ExpiresInHours) * time.Hour
+		if ttl <= 0 || ttl > maxJoinLinkTTL {
+			writeError(w, http.StatusBadRequest, "expiresInHours must be between 1 and 720")
+			return
+		}
+		t := time.Now().Add(ttl)
+		link.ExpiresAt = &t
+	}
+
+	ev := s.loadOwnedEvent(w, r, userID)
+	if ev == nil {
+		return
+	}
+	link.EventID = ev.ID
+
+	var err error
+	for attempt := 0; attempt < joinCodeAttempts; attempt++ {
+		if link.Code, err = newJoinCode(); err != nil {
+			break
+		}
+		if err = s.store.CreateJoinLink(r.Context(), link); !errors.Is(err, ErrJoinCodeConflict) {
+			break
+		}
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, link)
+}
+
+func (s *HTTPServer) handleListJoinLinks(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing X-User-Id")
+		return
+	}
+
+	ev := s.loadOwnedEvent(w, r, userID)
+	if ev == nil {
+		return
+	}
+
+	links, err := s.store.ListJoinLinks(r.Context(), ev.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, links)
+}
+
+func (s *HTTPServer) handleRevokeJoinLink(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing X-User-Id")
+		return
+	}
+
+	ev := s.loadOwnedEvent(w, r, userID)
+	if ev == nil {
+		return
+	}
+
+	code := normalizeJoinCode(chi.URLParam(r, "code"))
+	if err := s.store.RevokeJoinLink(r.Context(), ev.ID, code); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "join link not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleJoin redeems an event join code for the caller.
+func (s *HTTPServer) handleJoin(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing X-User-Id")
+		return
+	}
+
+	code := normalizeJoinCode(chi.URLParam(r, "code"))
+	res, err := s.store.RedeemJoinLink(r.Context(), code, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "join code not found or no longer valid")
+			return
+		}
+		if errors.Is(err, ErrBanned) {
+			writeError(w, http.StatusForbidden, "you are banned from this event")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if res.Joined {
+		go s.publishEvent(context.Background(), "event.joined", map[string]string{
+			"eventId": res.ID,
+			"userId":  userID,
+			"role":    res.Role,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,164 @@
+package vote
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func joinLinksRouter(server *HTTPServer) *chi.Mux {
+	r := chi.NewRouter()
+	r.Post("/events/{id}/join-links", server.handleCreateJoinLink)
+	r.Get("/events/{id}/join-links", server.handleListJoinLinks)
+	r.Delete("/events/{id}/join-links/{code}", server.handleRevokeJoinLink)
+	r.Post("/join/{code}", server.handleJoin)
+	return r
+}
+
+func TestNewJoinCode(t *testing.T) {
+	code, err := newJoinCode()
+	assert.NoError(t, err)
+	assert.Len(t, code, joinCodeLength)
+	assert.True(t, strings.HasPrefix(code, joinCodePrefix))
+	for _, c := range code[1:] {
+		assert.Contains(t, joinCodeAlphabet, string(c))
+	}
+}
+
+func TestHandleCreateJoinLink(t *testing.T) {
+	t.Run("owner creates guest link", func(t *testing.T) {
+		mockStore := new(MockStore)
+		r := joinLinksRouter(&HTTPServer{store: mockStore})
+
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner"}, nil)
+		mockStore.On("CreateJoinLink", mock.Anything, mock.MatchedBy(func(l *JoinLink) bool {
+			return l.EventID == "ev1" && l.Role == RoleGuest && *l.MaxUses == 20 && l.ExpiresAt != nil &&
+				strings.HasPrefix(l.Code, joinCodePrefix)
+		})).Return(nil)
+
+		b, _ := json.Marshal(map[string]any{"role": "guest", "maxUses": 20, "expiresInHours": 6})
+		req := httptest.NewRequest("POST", "/events/ev1/join-links", bytes.NewReader(b))
+		req.Header.Set("X-User-Id", "owner")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("retries on code collision", func(t *testing.T) {
+		mockStore := new(MockStore)
+		r := joinLinksRouter(&HTTPServer{store: mockStore})
+
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner"}, nil)
+		mockStore.On("CreateJoinLink", mock.Anything, mock.Anything).Return(ErrJoinCodeConflict).Once()
+		mockStore.On("CreateJoinLink", mock.Anything, mock.Anything).Return(nil).Once()
+
+		req := httptest.NewRequest("POST", "/events/ev1/join-links", nil)
+		req.Header.Set("X-User-Id", "owner")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		mockStore.AssertNumberOfCalls(t, "CreateJoinLink", 2)
+	})
+
+	t.Run("invalid role", func(t *testing.T) {
+		r := joinLinksRouter(&HTTPServer{store: new(MockStore)})
+
+		req := httptest.NewRequest("POST", "/events/ev1/join-links", strings.NewReader(`{"role":"owner"}`))
+		req.Header.Set("X-User-Id", "owner")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("not owner", func(t *testing.T) {
+		mockStore := new(MockStore)
+		r := joinLinksRouter(&HTTPServer{store: mockStore})
+
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner"}, nil)
+
+		req := httptest.NewRequest("POST", "/events/ev1/join-links", nil)
+		req.Header.Set("X-User-Id", "stranger")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		mockStore.AssertNotCalled(t, "CreateJoinLink", mock.Anything, mock.Anything)
+	})
+}
+
+func TestHandleRevokeJoinLink(t *testing.T) {
+	mockStore := new(MockStore)
+	r := joinLinksRouter(&HTTPServer{store: mockStore})
+
+	mockStore.On("LoadEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner"}, nil)
+	mockStore.On("RevokeJoinLink", mock.Anything, "ev1", "EABC2345").Return(nil)
+	mockStore.On("RevokeJoinLink", mock.Anything, "ev1", "EUNKNOWN").Return(pgx.ErrNoRows)
+
+	req := httptest.NewRequest("DELETE", "/events/ev1/join-links/eabc-2345", nil)
+	req.Header.Set("X-User-Id", "owner")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	req = httptest.NewRequest("DELETE", "/events/ev1/join-links/EUNKNOWN", nil)
+	req.Header.Set("X-User-Id", "owner")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandleJoin(t *testing.T) {
+	t.Run("joins event", func(t *testing.T) {
+		mockStore := new(MockStore)
+		r := joinLinksRouter(&HTTPServer{store: mockStore})
+
+		res := &JoinResult{Type: "event", ID: "ev1", Role: RoleGuest, Joined: true}
+		mockStore.On("RedeemJoinLink", mock.Anything, "EABC2345", "user1").Return(res, nil)
+
+		req := httptest.NewRequest("POST", "/join/eabc2345", nil)
+		req.Header.Set("X-User-Id", "user1")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var got JoinResult
+		_ = json.NewDecoder(rec.Body).Decode(&got)
+		assert.Equal(t, *res, got)
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		mockStore := new(MockStore)
+		r := joinLinksRouter(&HTTPServer{store: mockStore})
+
+		mockStore.On("RedeemJoinLink", mock.Anything, "EABC2345", "user1").Return(nil, pgx.ErrNoRows)
+
+		req := httptest.NewRequest("POST", "/join/EABC2345", nil)
+		req.Header.Set("X-User-Id", "user1")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("missing user id", func(t *testing.T) {
+		r := joinLinksRouter(&HTTPServer{store: new(MockStore)})
+
+		req := httptest.NewRequest("POST", "/join/EABC2345", nil)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
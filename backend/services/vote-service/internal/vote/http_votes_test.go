@@ -34,6 +34,7 @@ func TestHandleVote(t *testing.T) {
 		// Must return true for IsInvited
 		mockStore.On("IsInvited", mock.Anything, "ev1", "user1").Return(true, nil)
 
+		mockStore.On("IsBanned", mock.Anything, "ev1", "user1").Return(false, nil)
 		mockStore.On("CastVote", mock.Anything, "ev1", "tr1", "user1").Return(nil)
 		mockStore.On("GetVoteCount", mock.Anything, "ev1", "tr1").Return(10, nil)
 
@@ -28,6 +28,9 @@ func registerVote(ctx context.Context, store Store, rdb *redis.Client, eventID,
 	} else if !ok {
 		return nil, &voteError{status: http.StatusForbidden, msg: reason}
 	}
+	if err := checkModeration(ctx, store, ev, voterID, time.Now()); err != nil {
+		return nil, err
+	}
 
 	if err := store.CastVote(ctx, eventID, trackID, voterID); err != nil {
 		if errors.Is(err, ErrVoteConflict) {
@@ -61,6 +64,25 @@ func registerVote(ctx context.Context, store Store, rdb *redis.Client, eventID,
 	}, nil
 }
 
+// checkModeration rejects votes while a moderator has locked voting, except
+// the owner's, and votes by banned users.
+func checkModeration(ctx context.Context, store Store, ev *Event, voterID string, now time.Time) error {
+	if voterID == ev.OwnerID {
+		return nil
+	}
+	if ev.VotingLockedUntil != nil && ev.VotingLockedUntil.After(now) {
+		return &voteError{status: http.StatusLocked, msg: "voting is locked by a moderator"}
+	}
+	banned, err := store.IsBanned(ctx, ev.ID, voterID)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return &voteError{status: http.StatusForbidden, msg: "you are banned from this event"}
+	}
+	return nil
+}
+
 func removeVote(ctx context.Context, store Store, rdb *redis.Client, eventID, voterID, trackID string) (*VoteResponse, error) {
 	ev, err := store.LoadEvent(ctx, eventID)
 	if err != nil {
@@ -173,12 +195,16 @@ func validateVotingWindow(voteStart, voteEnd *time.Time, now time.Time) error {
 }
 
 func canUserVote(ctx context.Context, store Store, ev *Event, userID string, lat, lng *float64, now time.Time) (bool, string, error) {
-	if ev.Visibility == visibilityPrivate {
+	if ev.Visibility == visibilityPrivate || ev.Visibility == visibilityFriends {
 		invited, err := store.IsInvited(ctx, ev.ID, userID)
 		if err != nil {
 			return false, "", err
 		}
 		if !invited && ev.OwnerID != userID {
+			if ev.Visibility == visibilityFriends {
+				// Friends of the owner join first, like on public events.
+				return false, "you must join the event to vote", nil
+			}
 			return false, "event is private, invite required", nil
 		}
 	}
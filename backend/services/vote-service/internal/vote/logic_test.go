@@ -26,6 +26,7 @@ func TestRegisterVote(t *testing.T) {
 		}
 		mockStore.On("LoadEvent", ctx, "ev1").Return(ev, nil)
 		mockStore.On("IsInvited", ctx, "ev1", "user1").Return(true, nil)
+		mockStore.On("IsBanned", ctx, "ev1", "user1").Return(false, nil)
 		mockStore.On("CastVote", ctx, "ev1", "tr1", "user1").Return(nil)
 		mockStore.On("GetVoteCount", ctx, "ev1", "tr1").Return(5, nil)
 
@@ -50,6 +51,7 @@ func TestRegisterVote(t *testing.T) {
 		ev := &Event{ID: "ev1", OwnerID: "owner"}
 		mockStore.On("LoadEvent", ctx, "ev1").Return(ev, nil)
 		mockStore.On("IsInvited", ctx, "ev1", "user1").Return(true, nil)
+		mockStore.On("IsBanned", ctx, "ev1", "user1").Return(false, nil)
 		mockStore.On("CastVote", ctx, "ev1", "tr1", "user1").Return(errors.New("db error"))
 
 		_, err := registerVote(ctx, mockStore, nil, "ev1", "user1", "tr1", nil, nil)
@@ -510,6 +512,7 @@ func TestLogicErrorPaths(t *testing.T) {
 		ev := &Event{ID: "ev1", OwnerID: "owner"}
 		mockStore.On("LoadEvent", ctx, "ev1").Return(ev, nil)
 		mockStore.On("IsInvited", ctx, "ev1", "u1").Return(true, nil)
+		mockStore.On("IsBanned", ctx, "ev1", "u1").Return(false, nil)
 		mockStore.On("CastVote", ctx, "ev1", "t1", "u1").Return(nil)
 		mockStore.On("GetVoteCount", ctx, "ev1", "t1").Return(0, errors.New("count fail"))
 
@@ -628,6 +631,7 @@ func TestLogicEdgeCases(t *testing.T) {
 		ev := &Event{ID: "e1", LicenseMode: "everyone"}
 		m.On("LoadEvent", ctx, "e1").Return(ev, nil)
 		m.On("IsInvited", ctx, "e1", "u1").Return(true, nil)
+		m.On("IsBanned", ctx, "e1", "u1").Return(false, nil)
 		m.On("CastVote", ctx, "e1", "t1", "u1").Return(ErrVoteConflict)
 		_, err := registerVote(ctx, m, nil, "e1", "u1", "t1", nil, nil)
 		assert.Error(t, err)
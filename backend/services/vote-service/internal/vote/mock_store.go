@@ -2,6 +2,7 @@ package vote
 
 import (
 	"context"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -61,6 +62,24 @@ func (m *MockStore) DeleteEvent(ctx context.Context, id string) error {
 	return args.Error(0)
 }
 
+func (m *MockStore) LoadTrashedEvent(ctx context.Context, id string) (*Event, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Event), args.Error(1)
+}
+
+func (m *MockStore) RestoreEvent(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockStore) PurgeTrash(ctx context.Context, cutoff time.Time) (int64, error) {
+	args := m.Called(ctx, cutoff)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockStore) UpdateEvent(ctx context.Context, id string, updates map[string]any) error {
 	args := m.Called(ctx, id, updates)
 	return args.Error(0)
@@ -101,3 +120,49 @@ func (m *MockStore) GetUserStats(ctx context.Context, userID string) (*UserStats
 	}
 	return args.Get(0).(*UserStats), args.Error(1)
 }
+
+func (m *MockStore) CreateJoinLink(ctx context.Context, link *JoinLink) error {
+	args := m.Called(ctx, link)
+	return args.Error(0)
+}
+
+func (m *MockStore) ListJoinLinks(ctx context.Context, eventID string) ([]JoinLink, error) {
+	args := m.Called(ctx, eventID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]JoinLink), args.Error(1)
+}
+
+func (m *MockStore) RevokeJoinLink(ctx context.Context, eventID, code string) error {
+	args := m.Called(ctx, eventID, code)
+	return args.Error(0)
+}
+
+func (m *MockStore) RedeemJoinLink(ctx context.Context, code, userID string) (*JoinResult, error) {
+	args := m.Called(ctx, code, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*JoinResult), args.Error(1)
+}
+
+func (m *MockStore) IsBanned(ctx context.Context, eventID, userID string) (bool, error) {
+	args := m.Called(ctx, eventID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStore) BanUser(ctx context.Context, eventID, userID, bannedBy, reason string) error {
+	args := m.Called(ctx, eventID, userID, bannedBy, reason)
+	return args.Error(0)
+}
+
+func (m *MockStore) UnbanUser(ctx context.Context, eventID, userID string) error {
+	args := m.Called(ctx, eventID, userID)
+	return args.Error(0)
+}
+
+func (m *MockStore) SetVotingLock(ctx context.Context, eventID string, until *time.Time) error {
+	args := m.Called(ctx, eventID, until)
+	return args.Error(0)
+}
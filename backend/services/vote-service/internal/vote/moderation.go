@@ -0,0 +1,280 @@
+package vote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Moderation of an event goes through playlist-service, which owns the
+// event's playlist: it checks that the caller is the owner or a co-owner,
+// applies the action to the playlist and records it in the moderation log
+// (GET /playlists/{id}/moderation/log). vote-service then applies the
+// action to its own data. Rejections are relayed as is.
+
+const lockVoting = "voting"
+
+// moderationResult is a playlist-service response.
+type moderationResult struct {
+	status int
+	body   []byte
+}
+
+func (m moderationResult) ok() bool {
+	return m.status >= 200 && m.status < 300
+}
+
+// relay writes a playlist-service response back to the client.
+func (m moderationResult) relay(w http.ResponseWriter) {
+	if len(m.body) == 0 {
+		w.WriteHeader(m.status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(m.status)
+	_, _ = w.Write(m.body)
+}
+
+// forwardModeration sends a moderation request for the event's playlist to
+// playlist-service on behalf of userID.
+func (s *HTTPServer) forwardModeration(ctx context.Context, method, path, userID string, body []byte) (moderationResult, error) {
+	if s.httpClient == nil {
+		return moderationResult{}, errors.New("no playlist-service client")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.playlistServiceURL+path, bytes.NewReader(body))
+	if err != nil {
+		return moderationResult{}, err
+	}
+	req.Header.Set("X-User-Id", userID)
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return moderationResult{}, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return moderationResult{}, err
+	}
+	return moderationResult{status: resp.StatusCode, body: b}, nil
+}
+
+// moderate loads the event and forwards the request to the matching
+// playlist-service moderation endpoint. It writes the response itself
+// unless playlist-service accepted the action.
+func (s *HTTPServer) moderate(w http.ResponseWriter, r *http.Request, method, path string, body []byte) (moderationResult, bool) {
+	id := chi.URLParam(r, "id")
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing X-User-Id")
+		return moderationResult{}, false
+	}
+
+	if _, err := s.store.LoadEvent(r.Context(), id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "event not found")
+			return moderationResult{}, false
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return moderationResult{}, false
+	}
+
+	res, err := s.forwardModeration(r.Context(), method, "/playlists/"+id+"/moderation"+path, userID, body)
+	if err != nil {
+		log.Printf("vote-service: forward moderation %s %s: %v", method, path, err)
+		writeError(w, http.StatusBadGateway, "unable to reach playlist-service")
+		return moderationResult{}, false
+	}
+	if !res.ok() {
+		res.relay(w)
+		return res, false
+	}
+	return res, true
+}
+
+// readModerationTarget reads a {"userId": "...", "reason": "..."} body and
+// returns it for forwarding.
+func readModerationTarget(w http.ResponseWriter, r *http.Request) (userID, reason string, raw []byte, ok bool) {
+	raw, err := io.ReadAll(io.LimitReader(r.Body, 1<<16))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return "", "", nil, false
+	}
+	var body struct {
+		UserID string `json:"userId"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return "", "", nil, false
+	}
+	if body.UserID == "" {
+		writeError(w, http.StatusBadRequest, "userId is required")
+		return "", "", nil, false
+	}
+	return body.UserID, body.Reason, raw, true
+}
+
+// handleKick removes a participant from the event and its playlist. Unlike
+// a ban, they may join again.
+// POST /events/{id}/moderation/kick
+// Body: {"userId": "..."}
+func (s *HTTPServer) handleKick(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	target, _, raw, ok := readModerationTarget(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := s.moderate(w, r, http.MethodPost, "/kick", raw); !ok {
+		return
+	}
+
+	if err := s.store.DeleteInvite(r.Context(), id, target); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	go s.publishEvent(context.Background(), "event.member_kicked", map[string]string{
+		"eventId": id,
+		"userId":  target,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleBan bans a user from the event and its playlist: they can no longer
+// join, vote or add tracks.
+// POST /events/{id}/moderation/bans
+// Body: {"userId": "...", "reason": "optional"}
+func (s *HTTPServer) handleBan(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	target, reason, raw, ok := readModerationTarget(w, r)
+	if !ok {
+		return
+	}
+	res, ok := s.moderate(w, r, http.MethodPost, "/bans", raw)
+	if !ok {
+		return
+	}
+
+	if err := s.store.BanUser(r.Context(), id, target, r.Header.Get("X-User-Id"), reason); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	go s.publishEvent(context.Background(), "event.member_banned", map[string]string{
+		"eventId": id,
+		"userId":  target,
+	})
+
+	res.relay(w)
+}
+
+// handleListBans lists the users banned from the event.
+// GET /events/{id}/moderation/bans
+func (s *HTTPServer) handleListBans(w http.ResponseWriter, r *http.Request) {
+	if res, ok := s.moderate(w, r, http.MethodGet, "/bans", nil); ok {
+		res.relay(w)
+	}
+}
+
+// handleUnban lifts a ban.
+// DELETE /events/{id}/moderation/bans/{userId}
+func (s *HTTPServer) handleUnban(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	target := chi.URLParam(r, "userId")
+	if _, ok := s.moderate(w, r, http.MethodDelete, "/bans/"+url.PathEscape(target), nil); !ok {
+		return
+	}
+
+	// The playlist ban was the authoritative one.
+	if err := s.store.UnbanUser(r.Context(), id, target); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	go s.publishEvent(context.Background(), "event.member_unbanned", map[string]string{
+		"eventId": id,
+		"userId":  target,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLock temporarily locks the queue or voting of the event.
+// PUT /events/{id}/moderation/locks/{target}
+// Body: {"minutes": 15} (optional, 1-1440)
+func (s *HTTPServer) handleLock(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	target := chi.URLParam(r, "target")
+	raw, err := io.ReadAll(io.LimitReader(r.Body, 1<<16))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	res, ok := s.moderate(w, r, http.MethodPut, "/locks/"+url.PathEscape(target), raw)
+	if !ok {
+		return
+	}
+
+	var lock struct {
+		Target      string    `json:"target"`
+		LockedUntil time.Time `json:"lockedUntil"`
+	}
+	if err := json.Unmarshal(res.body, &lock); err != nil {
+		log.Printf("vote-service: decode lock of %s: %v", id, err)
+		writeError(w, http.StatusBadGateway, "invalid playlist-service response")
+		return
+	}
+	// The queue lives in playlist-service; only voting is locked here.
+	if lock.Target == lockVoting {
+		if err := s.store.SetVotingLock(r.Context(), id, &lock.LockedUntil); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	go s.publishEvent(context.Background(), "event.locked", map[string]any{
+		"eventId":     id,
+		"target":      lock.Target,
+		"lockedUntil": lock.LockedUntil,
+	})
+
+	res.relay(w)
+}
+
+// handleUnlock lifts a queue or voting lock early.
+// DELETE /events/{id}/moderation/locks/{target}
+func (s *HTTPServer) handleUnlock(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	target := chi.URLParam(r, "target")
+	if _, ok := s.moderate(w, r, http.MethodDelete, "/locks/"+url.PathEscape(target), nil); !ok {
+		return
+	}
+
+	if target == lockVoting {
+		if err := s.store.SetVotingLock(r.Context(), id, nil); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	go s.publishEvent(context.Background(), "event.unlocked", map[string]string{
+		"eventId": id,
+		"target":  target,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
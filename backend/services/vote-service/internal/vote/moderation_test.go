@@ -0,0 +1,181 @@
+package vote
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// moderationServer returns a router whose playlist-service answers every
+// request with status and body, recording the requests as "METHOD path user".
+func moderationServer(store Store, status int, body string) (http.Handler, *[]string) {
+	var calls []string
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		calls = append(calls, req.Method+" "+req.URL.Path+" "+req.Header.Get("X-User-Id"))
+		return &http.Response{
+			StatusCode: status,
+			Body:       io.NopCloser(bytes.NewBufferString(body)),
+			Header:     make(http.Header),
+		}
+	})
+	server := &HTTPServer{store: store, httpClient: client, playlistServiceURL: "http://pl"}
+	r := chi.NewRouter()
+	r.Post("/events/{id}/moderation/kick", server.handleKick)
+	r.Post("/events/{id}/moderation/bans", server.handleBan)
+	r.Delete("/events/{id}/moderation/bans/{userId}", server.handleUnban)
+	r.Put("/events/{id}/moderation/locks/{target}", server.handleLock)
+	r.Delete("/events/{id}/moderation/locks/{target}", server.handleUnlock)
+	return r, &calls
+}
+
+func serveModeration(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	req.Header.Set("X-User-Id", "owner")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandleKick(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockStore := new(MockStore)
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner"}, nil)
+		mockStore.On("DeleteInvite", mock.Anything, "ev1", "troll").Return(nil)
+		h, calls := moderationServer(mockStore, http.StatusNoContent, "")
+
+		rec := serveModeration(h, "POST", "/events/ev1/moderation/kick", `{"userId":"troll"}`)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, []string{"POST /playlists/ev1/moderation/kick owner"}, *calls)
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("rejected by playlist-service", func(t *testing.T) {
+		mockStore := new(MockStore)
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner"}, nil)
+		h, _ := moderationServer(mockStore, http.StatusForbidden, `{"error":"only the owner and co-owners can moderate"}`)
+
+		rec := serveModeration(h, "POST", "/events/ev1/moderation/kick", `{"userId":"troll"}`)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "co-owners")
+		mockStore.AssertNotCalled(t, "DeleteInvite", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("missing userId", func(t *testing.T) {
+		h, calls := moderationServer(new(MockStore), http.StatusNoContent, "")
+		rec := serveModeration(h, "POST", "/events/ev1/moderation/kick", `{}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Empty(t, *calls)
+	})
+
+	t.Run("event not found", func(t *testing.T) {
+		mockStore := new(MockStore)
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return((*Event)(nil), pgx.ErrNoRows)
+		h, calls := moderationServer(mockStore, http.StatusNoContent, "")
+
+		rec := serveModeration(h, "POST", "/events/ev1/moderation/kick", `{"userId":"troll"}`)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, *calls)
+	})
+}
+
+func TestHandleBanAndUnban(t *testing.T) {
+	mockStore := new(MockStore)
+	mockStore.On("LoadEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner"}, nil)
+	mockStore.On("BanUser", mock.Anything, "ev1", "troll", "owner", "spam").Return(nil)
+	mockStore.On("UnbanUser", mock.Anything, "ev1", "troll").Return(pgx.ErrNoRows)
+	h, calls := moderationServer(mockStore, http.StatusCreated, `{"userId":"troll"}`)
+
+	rec := serveModeration(h, "POST", "/events/ev1/moderation/bans", `{"userId":"troll","reason":"spam"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"userId":"troll"}`, rec.Body.String())
+
+	// A ban only playlist-service knew about is lifted without error.
+	rec = serveModeration(h, "DELETE", "/events/ev1/moderation/bans/troll", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, []string{
+		"POST /playlists/ev1/moderation/bans owner",
+		"DELETE /playlists/ev1/moderation/bans/troll owner",
+	}, *calls)
+	mockStore.AssertExpectations(t)
+}
+
+func TestHandleVotingLock(t *testing.T) {
+	until := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("voting", func(t *testing.T) {
+		mockStore := new(MockStore)
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner"}, nil)
+		mockStore.On("SetVotingLock", mock.Anything, "ev1", mock.MatchedBy(func(t *time.Time) bool {
+			return t != nil && t.Equal(until)
+		})).Return(nil)
+		mockStore.On("SetVotingLock", mock.Anything, "ev1", (*time.Time)(nil)).Return(nil)
+		h, _ := moderationServer(mockStore, http.StatusOK, `{"target":"voting","lockedUntil":"2030-01-01T12:00:00Z"}`)
+
+		rec := serveModeration(h, "PUT", "/events/ev1/moderation/locks/voting", `{"minutes":5}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		rec = serveModeration(h, "DELETE", "/events/ev1/moderation/locks/voting", "")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("queue is only locked in playlist-service", func(t *testing.T) {
+		mockStore := new(MockStore)
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner"}, nil)
+		h, calls := moderationServer(mockStore, http.StatusOK, `{"target":"queue","lockedUntil":"2030-01-01T12:00:00Z"}`)
+
+		rec := serveModeration(h, "PUT", "/events/ev1/moderation/locks/queue", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"PUT /playlists/ev1/moderation/locks/queue owner"}, *calls)
+		mockStore.AssertNotCalled(t, "SetVotingLock", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestRegisterVoteModeration(t *testing.T) {
+	locked := time.Now().Add(time.Hour)
+
+	t.Run("voting locked", func(t *testing.T) {
+		mockStore := new(MockStore)
+		ev := &Event{ID: "ev1", OwnerID: "owner", LicenseMode: licenseEveryone, VotingLockedUntil: &locked}
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(ev, nil)
+		mockStore.On("IsInvited", mock.Anything, "ev1", "u1").Return(true, nil)
+
+		_, err := registerVote(context.Background(), mockStore, nil, "ev1", "u1", "t1", nil, nil)
+		var ve *voteError
+		assert.ErrorAs(t, err, &ve)
+		assert.Equal(t, http.StatusLocked, ve.status)
+	})
+
+	t.Run("owner votes while locked", func(t *testing.T) {
+		mockStore := new(MockStore)
+		ev := &Event{ID: "ev1", OwnerID: "owner", LicenseMode: licenseEveryone, VotingLockedUntil: &locked}
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(ev, nil)
+		mockStore.On("CastVote", mock.Anything, "ev1", "t1", "owner").Return(nil)
+		mockStore.On("GetVoteCount", mock.Anything, "ev1", "t1").Return(1, nil)
+
+		_, err := registerVote(context.Background(), mockStore, nil, "ev1", "owner", "t1", nil, nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("banned", func(t *testing.T) {
+		mockStore := new(MockStore)
+		ev := &Event{ID: "ev1", OwnerID: "owner", LicenseMode: licenseEveryone}
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(ev, nil)
+		mockStore.On("IsInvited", mock.Anything, "ev1", "u1").Return(true, nil)
+		mockStore.On("IsBanned", mock.Anything, "ev1", "u1").Return(true, nil)
+
+		_, err := registerVote(context.Background(), mockStore, nil, "ev1", "u1", "t1", nil, nil)
+		var ve *voteError
+		assert.ErrorAs(t, err, &ve)
+		assert.Equal(t, http.StatusForbidden, ve.status)
+		mockStore.AssertNotCalled(t, "CastVote", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
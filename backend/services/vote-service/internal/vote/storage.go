@@ -23,13 +23,34 @@ type Store interface {
 	// Event Management
 	ListEvents(ctx context.Context, userID, visibility string) ([]Event, error)
 	CreateEvent(ctx context.Context, ev *Event) (string, error)
+	// DeleteEvent moves the event to the trash. Trashed events are not
+	// found by LoadEvent or ListEvents.
 	DeleteEvent(ctx context.Context, id string) error
+	// LoadTrashedEvent loads an event from the trash, with DeletedAt set.
+	LoadTrashedEvent(ctx context.Context, id string) (*Event, error)
+	RestoreEvent(ctx context.Context, id string) error
+	// PurgeTrash permanently deletes the events trashed before cutoff.
+	PurgeTrash(ctx context.Context, cutoff time.Time) (int64, error)
 	UpdateEvent(ctx context.Context, id string, updates map[string]any) error
 	TransferOwnership(ctx context.Context, id, newOwnerID string) error
 	// Invite Management
 	CreateInvite(ctx context.Context, eventID, userID, role string) error
 	DeleteInvite(ctx context.Context, eventID, userID string) error
 	ListInvites(ctx context.Context, eventID string) ([]Invite, error)
+	// Join links
+	CreateJoinLink(ctx context.Context, link *JoinLink) error
+	ListJoinLinks(ctx context.Context, eventID string) ([]JoinLink, error)
+	RevokeJoinLink(ctx context.Context, eventID, code string) error
+	RedeemJoinLink(ctx context.Context, code, userID string) (*JoinResult, error)
+	// Moderation (playlist-service keeps the moderation log)
+	IsBanned(ctx context.Context, eventID, userID string) (bool, error)
+	// BanUser records a ban, replacing an earlier one, and removes the
+	// user's invite.
+	BanUser(ctx context.Context, eventID, userID, bannedBy, reason string) error
+	// UnbanUser lifts a ban and returns pgx.ErrNoRows if there was none.
+	UnbanUser(ctx context.Context, eventID, userID string) error
+	// SetVotingLock locks voting until the given time; nil lifts the lock.
+	SetVotingLock(ctx context.Context, eventID string, until *time.Time) error
 	// Stats
 	GetUserStats(ctx context.Context, userID string) (*UserStats, error)
 }
@@ -86,6 +107,15 @@ func AutoMigrate(ctx context.Context, pool *pgxpool.Pool) error {
 	if _, err := pool.Exec(ctx, `ALTER TABLE events ADD COLUMN IF NOT EXISTS vote_end TIMESTAMPTZ`); err != nil {
 		log.Printf("migrate alter vote_end: %v", err)
 	}
+	// Deleted events stay in the trash until the purge job removes them.
+	if _, err := pool.Exec(ctx, `ALTER TABLE events ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ`); err != nil {
+		log.Printf("migrate alter deleted_at: %v", err)
+	}
+
+	// Moderators can lock voting for a while (see moderation.go).
+	if _, err := pool.Exec(ctx, `ALTER TABLE events ADD COLUMN IF NOT EXISTS voting_locked_until TIMESTAMPTZ`); err != nil {
+		log.Printf("migrate alter voting_locked_until: %v", err)
+	}
 
 	if _, err := pool.Exec(ctx, `
         CREATE TABLE IF NOT EXISTS votes(
@@ -119,6 +149,41 @@ func AutoMigrate(ctx context.Context, pool *pgxpool.Pool) error {
 		log.Printf("migrate alter event_invites role: %v", err)
 	}
 
+	// Join links: shareable codes that add whoever redeems them as a
+	// participant. NULL max_uses / expires_at mean unlimited.
+	if _, err := pool.Exec(ctx, `
+        CREATE TABLE IF NOT EXISTS event_join_links(
+            code TEXT PRIMARY KEY,
+            event_id uuid NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+            created_by TEXT NOT NULL,
+            role TEXT NOT NULL DEFAULT 'contributor',
+            max_uses INT,
+            uses INT NOT NULL DEFAULT 0,
+            expires_at TIMESTAMPTZ,
+            revoked_at TIMESTAMPTZ,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+        )
+    `); err != nil {
+		return err
+	}
+	if _, err := pool.Exec(ctx, `CREATE INDEX IF NOT EXISTS idx_event_join_links_event ON event_join_links(event_id, created_at DESC)`); err != nil {
+		log.Printf("migrate event_join_links index: %v", err)
+	}
+
+	// Banned users cannot join or vote until unbanned.
+	if _, err := pool.Exec(ctx, `
+        CREATE TABLE IF NOT EXISTS event_bans(
+            event_id uuid NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+            user_id TEXT NOT NULL,
+            banned_by TEXT NOT NULL,
+            reason TEXT NOT NULL DEFAULT '',
+            created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+            PRIMARY KEY(event_id, user_id)
+        )
+    `); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -130,12 +195,12 @@ func (s *PostgresStore) LoadEvent(ctx context.Context, id string) (*Event, error
 	err := s.pool.QueryRow(ctx, `
         SELECT id, name, visibility, owner_id, license_mode,
                geo_lat, geo_lng, geo_radius_m, vote_start, vote_end,
-               created_at, updated_at
-        FROM events WHERE id=$1
+               voting_locked_until, created_at, updated_at
+        FROM events WHERE id=$1 AND deleted_at IS NULL
     `, id).Scan(
 		&ev.ID, &ev.Name, &ev.Visibility, &ev.OwnerID, &ev.LicenseMode,
 		&geoLat, &geoLng, &geoRadius, &voteStart, &voteEnd,
-		&ev.CreatedAt, &ev.UpdatedAt,
+		&ev.VotingLockedUntil, &ev.CreatedAt, &ev.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -232,7 +297,7 @@ func (s *PostgresStore) ListEvents(ctx context.Context, userID, visibility strin
                    geo_lat, geo_lng, geo_radius_m, vote_start, vote_end,
                    created_at, updated_at
             FROM events
-            WHERE visibility = $1
+            WHERE visibility = $1 AND deleted_at IS NULL
             ORDER BY created_at DESC
         `, visibility)
 	} else {
@@ -244,9 +309,10 @@ func (s *PostgresStore) ListEvents(ctx context.Context, userID, visibility strin
             FROM events e
             LEFT JOIN event_invites i
               ON i.event_id = e.id AND i.user_id = $1
-            WHERE e.visibility = $2
+            WHERE e.deleted_at IS NULL
+              AND (e.visibility = $2
                OR e.owner_id = $1
-               OR i.user_id IS NOT NULL
+               OR i.user_id IS NOT NULL)
             ORDER BY e.created_at DESC
         `, userID, visibility)
 	}
@@ -305,7 +371,36 @@ func (s *PostgresStore) CreateEvent(ctx context.Context, ev *Event) (string, err
 }
 
 func (s *PostgresStore) DeleteEvent(ctx context.Context, id string) error {
-	res, err := s.pool.Exec(ctx, `DELETE FROM events WHERE id=$1`, id)
+	res, err := s.pool.Exec(ctx, `UPDATE events SET deleted_at = now() WHERE id=$1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (s *PostgresStore) LoadTrashedEvent(ctx context.Context, id string) (*Event, error) {
+	var ev Event
+	err := s.pool.QueryRow(ctx, `
+        SELECT id, name, visibility, owner_id, license_mode,
+               geo_lat, geo_lng, geo_radius_m, vote_start, vote_end,
+               created_at, updated_at, deleted_at
+        FROM events WHERE id=$1 AND deleted_at IS NOT NULL
+    `, id).Scan(
+		&ev.ID, &ev.Name, &ev.Visibility, &ev.OwnerID, &ev.LicenseMode,
+		&ev.GeoLat, &ev.GeoLng, &ev.GeoRadiusM, &ev.VoteStart, &ev.VoteEnd,
+		&ev.CreatedAt, &ev.UpdatedAt, &ev.DeletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
+
+func (s *PostgresStore) RestoreEvent(ctx context.Context, id string) error {
+	res, err := s.pool.Exec(ctx, `UPDATE events SET deleted_at = NULL, updated_at = now() WHERE id=$1 AND deleted_at IS NOT NULL`, id)
 	if err != nil {
 		return err
 	}
@@ -315,6 +410,14 @@ func (s *PostgresStore) DeleteEvent(ctx context.Context, id string) error {
 	return nil
 }
 
+func (s *PostgresStore) PurgeTrash(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.pool.Exec(ctx, `DELETE FROM events WHERE deleted_at IS NOT NULL AND deleted_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
+
 func (s *PostgresStore) UpdateEvent(ctx context.Context, id string, updates map[string]any) error {
 	if len(updates) == 0 {
 		return nil
@@ -413,7 +516,7 @@ func (s *PostgresStore) GetUserStats(ctx context.Context, userID string) (*UserS
 	var stats UserStats
 	err := s.pool.QueryRow(ctx, `
         SELECT 
-            (SELECT COUNT(*) FROM events WHERE owner_id=$1),
+            (SELECT COUNT(*) FROM events WHERE owner_id=$1 AND deleted_at IS NULL),
             (SELECT COUNT(*) FROM votes WHERE voter_id=$1)
     `, userID).Scan(&stats.EventsHosted, &stats.VotesCast)
 
@@ -422,3 +525,181 @@ func (s *PostgresStore) GetUserStats(ctx context.Context, userID string) (*UserS
 	}
 	return &stats, nil
 }
+
+const joinLinkColumns = `code, event_id, created_by, role, max_uses, uses, expires_at, revoked_at, created_at`
+
+func scanJoinLink(row pgx.Row, l *JoinLink) error {
+	return row.Scan(&l.Code, &l.EventID, &l.CreatedBy, &l.Role, &l.MaxUses, &l.Uses, &l.ExpiresAt, &l.RevokedAt, &l.CreatedAt)
+}
+
+func (s *PostgresStore) CreateJoinLink(ctx context.Context, link *JoinLink) error {
+	err := scanJoinLink(s.pool.QueryRow(ctx, `
+        INSERT INTO event_join_links(code, event_id, created_by, role, max_uses, expires_at)
+        VALUES($1,$2,$3,$4,$5,$6)
+        RETURNING `+joinLinkColumns,
+		link.Code, link.EventID, link.CreatedBy, link.Role, link.MaxUses, link.ExpiresAt,
+	), link)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrJoinCodeConflict
+	}
+	return err
+}
+
+func (s *PostgresStore) ListJoinLinks(ctx context.Context, eventID string) ([]JoinLink, error) {
+	rows, err := s.pool.Query(ctx, `
+        SELECT `+joinLinkColumns+`
+        FROM event_join_links WHERE event_id=$1 ORDER BY created_at DESC
+    `, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []JoinLink{}
+	for rows.Next() {
+		var l JoinLink
+		if err := scanJoinLink(rows, &l); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func (s *PostgresStore) RevokeJoinLink(ctx context.Context, eventID, code string) error {
+	res, err := s.pool.Exec(ctx, `
+        UPDATE event_join_links SET revoked_at = COALESCE(revoked_at, now())
+        WHERE event_id=$1 AND code=$2
+    `, eventID, code)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// RedeemJoinLink adds userID to the event of a valid join code with the
+// code's role and counts the use. The owner and existing participants keep
+// their role and do not use up the code. Returns pgx.ErrNoRows when the code
+// is unknown, revoked, expired or used up, and ErrBanned for banned users.
+func (s *PostgresStore) RedeemJoinLink(ctx context.Context, code, userID string) (*JoinResult, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	res := &JoinResult{Type: "event"}
+	var ownerID, currentRole string
+	var banned bool
+	err = tx.QueryRow(ctx, `
+        SELECT l.event_id, e.owner_id, COALESCE(i.role, ''),
+               EXISTS(SELECT 1 FROM event_bans b WHERE b.event_id = l.event_id AND b.user_id = $2)
+        FROM event_join_links l
+        JOIN events e ON e.id = l.event_id
+        LEFT JOIN event_invites i ON i.event_id = l.event_id AND i.user_id = $2
+        WHERE l.code = $1
+          AND e.deleted_at IS NULL
+          AND l.revoked_at IS NULL
+          AND (l.expires_at IS NULL OR l.expires_at > now())
+          AND (l.max_uses IS NULL OR l.uses < l.max_uses)
+    `, code, userID).Scan(&res.ID, &ownerID, &currentRole, &banned)
+	if err != nil {
+		return nil, err
+	}
+	if banned {
+		return nil, ErrBanned
+	}
+	if userID == ownerID {
+		res.Role = "owner"
+		return res, nil
+	}
+	if currentRole != "" {
+		res.Role = currentRole
+		return res, nil
+	}
+
+	// Counted with the same guards so concurrent redemptions cannot exceed
+	// max_uses.
+	if err := tx.QueryRow(ctx, `
+        UPDATE event_join_links SET uses = uses + 1
+        WHERE code = $1
+          AND revoked_at IS NULL
+          AND (expires_at IS NULL OR expires_at > now())
+          AND (max_uses IS NULL OR uses < max_uses)
+        RETURNING role
+    `, code).Scan(&res.Role); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+        INSERT INTO event_invites(event_id, user_id, role)
+        VALUES($1,$2,$3) ON CONFLICT(event_id, user_id) DO NOTHING
+    `, res.ID, userID, res.Role); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	res.Joined = true
+	return res, nil
+}
+
+func (s *PostgresStore) IsBanned(ctx context.Context, eventID, userID string) (bool, error) {
+	var banned bool
+	err := s.pool.QueryRow(ctx, `
+        SELECT EXISTS(SELECT 1 FROM event_bans WHERE event_id=$1 AND user_id=$2)
+    `, eventID, userID).Scan(&banned)
+	return banned, err
+}
+
+func (s *PostgresStore) BanUser(ctx context.Context, eventID, userID, bannedBy, reason string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+        INSERT INTO event_bans(event_id, user_id, banned_by, reason)
+        VALUES($1,$2,$3,$4)
+        ON CONFLICT(event_id, user_id) DO UPDATE
+        SET banned_by=EXCLUDED.banned_by, reason=EXCLUDED.reason, created_at=now()
+    `, eventID, userID, bannedBy, reason); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM event_invites WHERE event_id=$1 AND user_id=$2`, eventID, userID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *PostgresStore) UnbanUser(ctx context.Context, eventID, userID string) error {
+	res, err := s.pool.Exec(ctx, `DELETE FROM event_bans WHERE event_id=$1 AND user_id=$2`, eventID, userID)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (s *PostgresStore) SetVotingLock(ctx context.Context, eventID string, until *time.Time) error {
+	res, err := s.pool.Exec(ctx, `
+        UPDATE events SET voting_locked_until=$2, updated_at=now()
+        WHERE id=$1 AND deleted_at IS NULL
+    `, eventID, until)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
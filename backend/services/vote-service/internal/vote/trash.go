@@ -0,0 +1,118 @@
+package vote
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// trashRetention is how long a deleted event can be restored. It matches
+	// the retention of playlist-service, which keeps the event's playlist.
+	trashRetention = 30 * 24 * time.Hour
+	// trashPurgeInterval is how often the purge job runs.
+	trashPurgeInterval = time.Hour
+)
+
+// handleRestoreEvent takes an event out of the trash, along with its
+// playlist. Only the owner can restore.
+// POST /events/{id}/restore
+func (s *HTTPServer) handleRestoreEvent(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	userID := r.Header.Get("X-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "missing X-User-Id")
+		return
+	}
+
+	ev, err := s.store.LoadTrashedEvent(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "event not found in trash")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if ev.OwnerID != userID {
+		writeError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+	if time.Since(*ev.DeletedAt) >= trashRetention {
+		writeError(w, http.StatusNotFound, "event not found in trash")
+		return
+	}
+
+	if err := s.store.RestoreEvent(r.Context(), id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "event not found in trash")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// The playlist answers 404 if it was restored first, which ends the
+	// round trip when the restore started in playlist-service.
+	s.propagateToPlaylist(http.MethodPost, "/playlists/"+id+"/restore", userID)
+
+	go s.publishEvent(context.Background(), "event.restored", map[string]string{"id": id})
+
+	restored, err := s.store.LoadEvent(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, restored)
+}
+
+// propagateToPlaylist applies a trash change of an event to the playlist
+// sharing its ID, in the background.
+func (s *HTTPServer) propagateToPlaylist(method, path, userID string) {
+	if s.httpClient == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, method, s.playlistServiceURL+path, nil)
+		if err != nil {
+			return
+		}
+		req.Header.Set("X-User-Id", userID)
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			log.Printf("vote-service: failed to propagate %s %s to playlist-service: %v", method, path, err)
+			return
+		}
+		resp.Body.Close()
+	}()
+}
+
+// StartTrashPurge permanently deletes events that have been in the trash
+// for trashRetention, until ctx is cancelled.
+func StartTrashPurge(ctx context.Context, store Store) {
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for {
+			n, err := store.PurgeTrash(ctx, time.Now().Add(-trashRetention))
+			if err != nil {
+				log.Printf("vote-service: trash purge: %v", err)
+			} else if n > 0 {
+				log.Printf("vote-service: purged %d events from the trash", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,166 @@
+package vote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// playlistCalls returns a client recording the playlist-service requests it
+// receives as "METHOD path user".
+func playlistCalls() (*http.Client, chan string) {
+	calls := make(chan string, 1)
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		calls <- req.Method + " " + req.URL.Path + " " + req.Header.Get("X-User-Id")
+		return &http.Response{
+			StatusCode: http.StatusNoContent,
+			Body:       io.NopCloser(bytes.NewBuffer(nil)),
+			Header:     make(http.Header),
+		}
+	})
+	return client, calls
+}
+
+func expectCall(t *testing.T, calls chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-calls:
+		assert.Equal(t, want, got)
+	case <-time.After(time.Second):
+		t.Errorf("expected playlist-service call %q", want)
+	}
+}
+
+func TestHandleDeleteEventTrashesPlaylist(t *testing.T) {
+	mockStore := new(MockStore)
+	client, calls := playlistCalls()
+	server := &HTTPServer{store: mockStore, httpClient: client, playlistServiceURL: "http://pl"}
+	r := chi.NewRouter()
+	r.Delete("/events/{id}", server.handleDeleteEvent)
+
+	mockStore.On("LoadEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner"}, nil)
+	mockStore.On("DeleteEvent", mock.Anything, "ev1").Return(nil)
+
+	req := httptest.NewRequest("DELETE", "/events/ev1", nil)
+	req.Header.Set("X-User-Id", "owner")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	expectCall(t, calls, "DELETE /playlists/ev1 owner")
+}
+
+func TestHandleRestoreEvent(t *testing.T) {
+	deletedAt := time.Now().Add(-time.Hour)
+	expiredAt := time.Now().Add(-trashRetention - time.Minute)
+
+	newRequest := func(userID string) *http.Request {
+		req := httptest.NewRequest("POST", "/events/ev1/restore", nil)
+		req.Header.Set("X-User-Id", userID)
+		return req
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockStore := new(MockStore)
+		client, calls := playlistCalls()
+		server := &HTTPServer{store: mockStore, httpClient: client, playlistServiceURL: "http://pl"}
+		r := chi.NewRouter()
+		r.Post("/events/{id}/restore", server.handleRestoreEvent)
+
+		mockStore.On("LoadTrashedEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner", DeletedAt: &deletedAt}, nil)
+		mockStore.On("RestoreEvent", mock.Anything, "ev1").Return(nil)
+		mockStore.On("LoadEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner"}, nil)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, newRequest("owner"))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"id":"ev1"`)
+		expectCall(t, calls, "POST /playlists/ev1/restore owner")
+		mockStore.AssertExpectations(t)
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		mockStore := new(MockStore)
+		server := &HTTPServer{store: mockStore}
+		r := chi.NewRouter()
+		r.Post("/events/{id}/restore", server.handleRestoreEvent)
+
+		mockStore.On("LoadTrashedEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner", DeletedAt: &deletedAt}, nil)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, newRequest("other"))
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		mockStore.AssertNotCalled(t, "RestoreEvent", mock.Anything, mock.Anything)
+	})
+
+	t.Run("not in trash", func(t *testing.T) {
+		mockStore := new(MockStore)
+		server := &HTTPServer{store: mockStore}
+		r := chi.NewRouter()
+		r.Post("/events/{id}/restore", server.handleRestoreEvent)
+
+		mockStore.On("LoadTrashedEvent", mock.Anything, "ev1").Return((*Event)(nil), pgx.ErrNoRows)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, newRequest("owner"))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("past retention", func(t *testing.T) {
+		mockStore := new(MockStore)
+		server := &HTTPServer{store: mockStore}
+		r := chi.NewRouter()
+		r.Post("/events/{id}/restore", server.handleRestoreEvent)
+
+		mockStore.On("LoadTrashedEvent", mock.Anything, "ev1").Return(&Event{ID: "ev1", OwnerID: "owner", DeletedAt: &expiredAt}, nil)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, newRequest("owner"))
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		mockStore.AssertNotCalled(t, "RestoreEvent", mock.Anything, mock.Anything)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		server := &HTTPServer{}
+		r := chi.NewRouter()
+		r.Post("/events/{id}/restore", server.handleRestoreEvent)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("POST", "/events/ev1/restore", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestStartTrashPurge(t *testing.T) {
+	mockStore := new(MockStore)
+	purged := make(chan time.Time, 1)
+	mockStore.On("PurgeTrash", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		purged <- args.Get(1).(time.Time)
+	}).Return(int64(0), errors.New("db down"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartTrashPurge(ctx, mockStore)
+
+	select {
+	case cutoff := <-purged:
+		assert.WithinDuration(t, time.Now().Add(-trashRetention), cutoff, time.Minute)
+	case <-time.After(time.Second):
+		t.Fatal("purge did not run")
+	}
+}